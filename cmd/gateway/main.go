@@ -20,6 +20,7 @@ import (
 
 	"github.com/nulpointcorp/llm-gateway/internal/app"
 	"github.com/nulpointcorp/llm-gateway/internal/config"
+	reqlog "github.com/nulpointcorp/llm-gateway/internal/logger"
 )
 
 // version is overridden at build time via -ldflags="-X main.version=x.y.z".
@@ -36,8 +37,13 @@ func main() {
 		log.Fatalf("config: %v", err)
 	}
 
+	redactor, err := reqlog.NewRedactor(cfg.LogRedaction.Content, cfg.LogRedaction.Patterns)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
 	// Build the structured logger. All subsystems share this instance.
-	logger := buildLogger(cfg.LogLevel)
+	logger := buildLogger(cfg.LogLevel, redactor)
 	slog.SetDefault(logger)
 
 	// Initialise and run the application.
@@ -55,8 +61,9 @@ func main() {
 }
 
 // buildLogger constructs a JSON slog.Logger for the given level string.
-// Unknown level strings default to INFO.
-func buildLogger(level string) *slog.Logger {
+// Unknown level strings default to INFO. A non-nil redactor is applied to
+// content attributes of every record.
+func buildLogger(level string, redactor reqlog.Redactor) *slog.Logger {
 	var l slog.Level
 	switch level {
 	case "debug":
@@ -69,8 +76,12 @@ func buildLogger(level string) *slog.Logger {
 		l = slog.LevelInfo
 	}
 
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	var h slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level:     l,
 		AddSource: l == slog.LevelDebug, // include file:line only in debug mode
-	}))
+	})
+	if redactor != nil {
+		h = reqlog.NewRedactingHandler(h, redactor)
+	}
+	return slog.New(h)
 }
@@ -3,7 +3,7 @@
 // Startup order:
 //  1. initInfra  — external connections (Redis when needed)
 //  2. initProviders — LLM provider clients
-//  3. initServices — cache, metrics registry
+//  3. initServices — cache, metrics registry, trace exporter
 //  4. initGateway  — proxy + management routes
 package app
 
@@ -30,6 +30,8 @@ import (
 	openaicompatprov "github.com/nulpointcorp/llm-gateway/internal/providers/openaicompat"
 	vertexaiprov "github.com/nulpointcorp/llm-gateway/internal/providers/vertexai"
 	"github.com/nulpointcorp/llm-gateway/internal/proxy"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/internal/webhook"
 )
 
 // App owns all long-lived resources and exposes Run / Close.
@@ -40,12 +42,14 @@ type App struct {
 	log     *slog.Logger
 
 	// Optional external connections — nil when not configured.
-	rdb *redis.Client
+	rdb redis.UniversalClient
 
 	reqLogger *logger.Logger
 	memCache  *npCache.MemoryCache
 
-	prom *metrics.Registry
+	prom     *metrics.Registry
+	traces   *tracing.OTLPExporter
+	webhooks *webhook.Notifier
 
 	provs map[string]providers.Provider
 	mgmt  *proxy.ManagementRoutes
@@ -117,6 +121,18 @@ func (a *App) Close() {
 		}
 		a.reqLogger = nil
 	}
+	if a.traces != nil {
+		if err := a.traces.Close(); err != nil {
+			a.log.Error("trace exporter close error", slog.String("error", err.Error()))
+		}
+		a.traces = nil
+	}
+	if a.webhooks != nil {
+		if err := a.webhooks.Close(); err != nil {
+			a.log.Error("webhook notifier close error", slog.String("error", err.Error()))
+		}
+		a.webhooks = nil
+	}
 	if a.memCache != nil {
 		a.memCache.Close()
 		a.memCache = nil
@@ -131,15 +147,15 @@ func (a *App) Close() {
 
 // ── Private helpers ──────────────────────────────────────────────────────────
 
-// connectRedis parses the URL and verifies connectivity with a PING.
+// connectRedis builds a client for the topology REDIS_MODE selects and
+// verifies connectivity with a PING.
 // Returns an error — callers decide whether to fatal or degrade.
-func connectRedis(ctx context.Context, url string) (*redis.Client, error) {
-	opts, err := redis.ParseURL(url)
+func connectRedis(ctx context.Context, cfg config.RedisConfig) (redis.UniversalClient, error) {
+	rdb, err := newRedisClient(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("parse url: %w", err)
+		return nil, err
 	}
 
-	rdb := redis.NewClient(opts)
 	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -151,9 +167,38 @@ func connectRedis(ctx context.Context, url string) (*redis.Client, error) {
 	return rdb, nil
 }
 
+// newRedisClient parses cfg.URL with the go-redis parser for cfg.Mode.
+func newRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	switch cfg.Mode {
+	case "cluster":
+		opts, err := redis.ParseClusterURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse cluster url: %w", err)
+		}
+		return redis.NewClusterClient(opts), nil
+
+	case "sentinel":
+		opts, err := redis.ParseFailoverURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse sentinel url: %w", err)
+		}
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("sentinel url: master_name is required")
+		}
+		return redis.NewFailoverClient(opts), nil
+
+	default:
+		opts, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse url: %w", err)
+		}
+		return redis.NewClient(opts), nil
+	}
+}
+
 // redisPinger returns a zero-argument probe function suitable for the
 // HealthChecker. Reuses the existing client — no new connections.
-func redisPinger(ctx context.Context, rdb *redis.Client) func() bool {
+func redisPinger(ctx context.Context, rdb redis.UniversalClient) func() bool {
 	return func() bool {
 		pingCtx, cancel := context.WithTimeout(ctx, time.Second)
 		defer cancel()
@@ -225,6 +270,15 @@ func buildProviders(ctx context.Context, cfg *config.Config) map[string]provider
 		}
 	}
 
+	// ── Custom OpenAI-compatible endpoints ────────────────────────────────────
+	for _, cp := range cfg.CustomProviders {
+		opts := []openaicompatprov.Option{openaicompatprov.WithModelPrefix(cp.ModelPrefix)}
+		if cp.APIKey == "" {
+			opts = append(opts, openaicompatprov.WithNoAuth())
+		}
+		provs[cp.Name] = openaicompatprov.New(cp.Name, cp.APIKey, cp.BaseURL, opts...)
+	}
+
 	// ── Google Vertex AI ──────────────────────────────────────────────────────
 	if cfg.VertexAI.Project != "" {
 		loc := cfg.VertexAI.Location
@@ -246,6 +300,12 @@ func buildProviders(ctx context.Context, cfg *config.Config) map[string]provider
 		if cfg.Bedrock.EndpointURL != "" {
 			opts = append(opts, bedrockprov.WithEndpointURL(cfg.Bedrock.EndpointURL))
 		}
+		if cfg.Bedrock.GuardrailID != "" {
+			opts = append(opts, bedrockprov.WithGuardrail(cfg.Bedrock.GuardrailID, cfg.Bedrock.GuardrailVersion))
+		}
+		if len(cfg.Bedrock.InferenceProfiles) > 0 {
+			opts = append(opts, bedrockprov.WithInferenceProfiles(cfg.Bedrock.InferenceProfiles))
+		}
 		provs["bedrock"] = bedrockprov.New(
 			cfg.Bedrock.AccessKey, cfg.Bedrock.SecretKey, cfg.Bedrock.Region, opts...,
 		)
@@ -260,5 +320,13 @@ func buildProviders(ctx context.Context, cfg *config.Config) map[string]provider
 		provs["azure"] = azureprov.New(cfg.Azure.Endpoint, cfg.Azure.APIKey, apiVersion)
 	}
 
+	// ── Key pools ─────────────────────────────────────────────────────────────
+	// Providers with several keys rotate through them per request.
+	for name, keys := range cfg.ProviderKeyPools() {
+		if p, ok := provs[name]; ok {
+			provs[name] = providers.WithKeyPool(p, providers.NewKeyPool(keys, cfg.APIKeyCooldown))
+		}
+	}
+
 	return provs
 }
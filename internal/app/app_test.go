@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	npCache "github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/config"
+)
+
+func TestBuildProviders_CustomProviders(t *testing.T) {
+	cfg := &config.Config{CustomProviders: []config.CustomProviderConfig{
+		{Name: "mylocal", BaseURL: "http://localhost:11434/v1", ModelPrefix: "mylocal/"},
+		{Name: "vllm", BaseURL: "http://vllm.internal:8000/v1", APIKey: "vk", ModelPrefix: "vllm/"},
+	}}
+
+	provs := buildProviders(context.Background(), cfg)
+	for _, name := range []string{"mylocal", "vllm"} {
+		p, ok := provs[name]
+		if !ok {
+			t.Fatalf("custom provider %q not built; got %v", name, provs)
+		}
+		if p.Name() != name {
+			t.Errorf("provider %q reports name %q", name, p.Name())
+		}
+	}
+	if len(provs) != 2 {
+		t.Errorf("built %d providers, want only the 2 custom ones", len(provs))
+	}
+}
+
+// fakeSentinel serves the SENTINEL commands a failover client needs,
+// reporting master as the address of the master named "mymaster".
+func fakeSentinel(t *testing.T, master *miniredis.Miniredis) string {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	host, port := master.Host(), master.Port()
+	_ = srv.Register("PING", func(c *server.Peer, _ string, _ []string) { c.WriteInline("PONG") })
+	_ = srv.Register("SENTINEL", func(c *server.Peer, _ string, args []string) {
+		if len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == "mymaster" {
+			c.WriteStrings([]string{host, port})
+			return
+		}
+		c.WriteLen(0) // no other sentinels or replicas
+	})
+	return srv.Addr().String()
+}
+
+func TestConnectRedis_Modes(t *testing.T) {
+	tests := []struct {
+		mode string
+		url  func(mr *miniredis.Miniredis) string
+	}{
+		{"single", func(mr *miniredis.Miniredis) string { return "redis://" + mr.Addr() }},
+		{"cluster", func(mr *miniredis.Miniredis) string { return "redis://" + mr.Addr() }},
+		{"sentinel", func(mr *miniredis.Miniredis) string {
+			return "redis://" + fakeSentinel(t, mr) + "?master_name=mymaster"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+
+			rdb, err := connectRedis(ctx, config.RedisConfig{URL: tt.url(mr), Mode: tt.mode})
+			if err != nil {
+				t.Fatalf("connectRedis: %v", err)
+			}
+			defer rdb.Close()
+
+			c := npCache.NewExactCacheFromClient(rdb)
+			if err := c.Set(ctx, "cache:k", []byte("v"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := c.Get(ctx, "cache:k"); !ok || string(got) != "v" {
+				t.Errorf("Get = %q, %v; want v", got, ok)
+			}
+			if st, err := c.Stats(ctx); err != nil || st.Entries != 1 {
+				t.Errorf("Stats = %+v, %v; want 1 entry", st, err)
+			}
+
+			ready := redisPinger(ctx, rdb)
+			if !ready() {
+				t.Error("readiness probe failed with redis up")
+			}
+			mr.Close()
+			if ready() {
+				t.Error("readiness probe passed with redis down")
+			}
+		})
+	}
+}
+
+func TestConnectRedis_SentinelNeedsMasterName(t *testing.T) {
+	_, err := connectRedis(context.Background(), config.RedisConfig{URL: "redis://127.0.0.1:26379", Mode: "sentinel"})
+	if err == nil || !strings.Contains(err.Error(), "master_name") {
+		t.Errorf("err = %v, want master_name required", err)
+	}
+}
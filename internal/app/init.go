@@ -4,20 +4,30 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	npCache "github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/config"
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
 	"github.com/nulpointcorp/llm-gateway/internal/metrics"
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
 	"github.com/nulpointcorp/llm-gateway/internal/proxy"
 	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/internal/webhook"
 )
 
 // initInfra establishes optional external connections.
-// Redis is only required when CACHE_MODE=redis.
+// Redis is only required when CACHE_MODE=redis or tiered, or the RPM limiter
+// uses it.
 func (a *App) initInfra(ctx context.Context) error {
-	if a.cfg.Cache.Mode == "redis" {
-		a.log.Info("connecting to redis", slog.String("url", redactURL(a.cfg.Redis.URL)))
+	if a.cfg.NeedsRedis() {
+		a.log.Info("connecting to redis",
+			slog.String("url", redactURL(a.cfg.Redis.URL)),
+			slog.String("mode", a.cfg.Redis.Mode),
+		)
 
-		rdb, err := connectRedis(ctx, a.cfg.Redis.URL)
+		rdb, err := connectRedis(ctx, a.cfg.Redis)
 		if err != nil {
 			return fmt.Errorf("redis: %w", err)
 		}
@@ -31,6 +41,13 @@ func (a *App) initInfra(ctx context.Context) error {
 // initProviders builds the LLM provider map. At least one provider must be
 // configured — this is enforced by config.Validate() before we reach here.
 func (a *App) initProviders(_ context.Context) error {
+	providers.ApplyHTTPTransport(providers.HTTPTransportConfig{
+		MaxIdleConns:        a.cfg.UpstreamHTTP.MaxIdleConns,
+		MaxIdleConnsPerHost: a.cfg.UpstreamHTTP.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     a.cfg.UpstreamHTTP.MaxConnsPerHost,
+		IdleConnTimeout:     a.cfg.UpstreamHTTP.IdleConnTimeout,
+		DisableHTTP2:        !a.cfg.UpstreamHTTP.HTTP2,
+	})
 	a.provs = buildProviders(a.baseCtx, a.cfg)
 	if len(a.provs) == 0 {
 		return fmt.Errorf("no provider API keys configured")
@@ -42,10 +59,75 @@ func (a *App) initProviders(_ context.Context) error {
 	}
 	a.log.Info("providers loaded", slog.Any("providers", names))
 
+	// User aliases may only route to providers that were actually built.
+	for model, name := range a.cfg.ModelAliases {
+		if _, ok := a.provs[name]; !ok {
+			return fmt.Errorf("model alias %q targets provider %q, which is not configured", model, name)
+		}
+	}
+	if len(a.cfg.ModelAliases) > 0 {
+		providers.ApplyModelAliases(a.cfg.ModelAliases)
+		a.log.Info("model aliases loaded", slog.Int("aliases", len(a.cfg.ModelAliases)))
+	}
+	// Models mapped to a Bedrock inference profile route to Bedrock unless a
+	// built-in or user alias already sends them elsewhere.
+	if _, ok := a.provs["bedrock"]; ok && len(a.cfg.Bedrock.InferenceProfiles) > 0 {
+		routes := make(map[string]string)
+		for model := range a.cfg.Bedrock.InferenceProfiles {
+			if _, routed := providers.ModelAliases[model]; !routed {
+				routes[model] = "bedrock"
+			}
+		}
+		providers.ApplyModelAliases(routes)
+	}
+	for _, cp := range a.cfg.CustomProviders {
+		providers.ModelPrefixes[cp.ModelPrefix] = cp.Name
+	}
+	if len(a.cfg.ContextWindows) > 0 {
+		providers.ApplyContextWindows(a.cfg.ContextWindows)
+		a.log.Info("context windows loaded", slog.Int("models", len(a.cfg.ContextWindows)))
+	}
+	if len(a.cfg.EmbeddingBatchSizes) > 0 {
+		providers.ApplyEmbeddingBatchSizes(a.cfg.EmbeddingBatchSizes)
+	}
+
+	if name := a.cfg.Shadow.Provider; name != "" {
+		if _, ok := a.provs[name]; !ok {
+			return fmt.Errorf("shadow provider %q is not configured", name)
+		}
+	}
+	if name := a.cfg.Moderation.Provider; name != "" {
+		prov, ok := a.provs[name]
+		if !ok {
+			return fmt.Errorf("moderation provider %q is not configured", name)
+		}
+		if _, ok := providers.AsModerationProvider(prov); !ok {
+			return fmt.Errorf("moderation provider %q does not support moderation", name)
+		}
+	}
+
+	for name := range a.cfg.Concurrency.ProviderMax {
+		if _, ok := a.provs[name]; !ok {
+			return fmt.Errorf("concurrency limit set for provider %q, which is not configured", name)
+		}
+	}
+
+	// Likewise every provider named in a fallback chain must exist.
+	for _, chains := range []map[string][]string{a.cfg.Failover.Chains, a.cfg.Failover.ModelChains} {
+		for key, chain := range chains {
+			for _, name := range chain {
+				if _, ok := a.provs[name]; !ok {
+					return fmt.Errorf("fallback chain %q lists provider %q, which is not configured", key, name)
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
-// initServices creates the cache backend and Prometheus metrics registry.
+// initServices creates the cache backend, Prometheus metrics registry, the
+// request logger and, when an OTLP endpoint is configured, the trace exporter.
 func (a *App) initServices(ctx context.Context) error {
 	switch a.cfg.Cache.Mode {
 	case "redis":
@@ -54,9 +136,22 @@ func (a *App) initServices(ctx context.Context) error {
 
 	case "memory":
 		// MemoryCache — zero external dependencies, not shared across replicas.
-		a.memCache = npCache.NewMemoryCache(ctx)
+		a.memCache = a.newMemoryCache(ctx)
 		a.log.Info("cache backend: memory (in-process)")
 
+	case "tiered":
+		// MemoryCache in front of the already-connected Redis client.
+		a.memCache = a.newMemoryCache(ctx)
+		a.log.Info("cache backend: tiered (in-process + redis)")
+
+	case "semantic":
+		// SemanticCache indexes prompts in process and stores values in memory.
+		a.memCache = a.newMemoryCache(ctx)
+		a.log.Info("cache backend: semantic (in-process)",
+			slog.String("embedding_model", a.cfg.Cache.Semantic.EmbeddingModel),
+			slog.Float64("threshold", a.cfg.Cache.Semantic.Threshold),
+		)
+
 	case "none":
 		a.log.Info("cache backend: disabled")
 
@@ -66,22 +161,110 @@ func (a *App) initServices(ctx context.Context) error {
 
 	a.prom = metrics.New()
 	a.prom.SetBuildInfo(a.version)
+	if a.memCache != nil {
+		a.prom.ObserveCacheEvictions(a.memCache.Evictions)
+	}
+
+	if err := a.initRequestLogger(ctx); err != nil {
+		return fmt.Errorf("request logger: %w", err)
+	}
+
+	if endpoint := a.cfg.Tracing.OTLPEndpoint; endpoint != "" {
+		a.traces = tracing.NewOTLPExporter(endpoint, a.cfg.Tracing.ServiceName, a.log)
+		a.log.Info("tracing enabled", slog.String("otlp_endpoint", redactURL(endpoint)))
+	}
+
+	if wc := a.cfg.Webhook; wc.URL != "" {
+		n, err := webhook.New(webhook.Options{
+			URL:          wc.URL,
+			Secret:       wc.Secret,
+			BaseURL:      a.cfg.AppBaseURL,
+			MaxRetries:   wc.MaxRetries,
+			RetryBackoff: wc.RetryBackoff,
+			Timeout:      wc.Timeout,
+			QueueSize:    wc.QueueSize,
+		}, a.log)
+		if err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		a.webhooks = n
+		a.log.Info("webhooks enabled",
+			slog.String("url", redactURL(wc.URL)),
+			slog.Bool("signed", wc.Secret != ""),
+		)
+	}
 
 	return nil
 }
 
+// newMemoryCache returns the in-process response cache, bounded by
+// CACHE_MEMORY_MAX_ENTRIES and CACHE_MEMORY_MAX_BYTES.
+func (a *App) newMemoryCache(ctx context.Context) *npCache.MemoryCache {
+	opts := npCache.MemoryOptions{
+		MaxEntries: a.cfg.Cache.MemoryMaxEntries,
+		MaxBytes:   a.cfg.Cache.MemoryMaxBytes,
+	}
+	if opts.MaxEntries > 0 || opts.MaxBytes > 0 {
+		a.log.Info("in-process cache bounded",
+			slog.Int("max_entries", opts.MaxEntries),
+			slog.Int64("max_bytes", opts.MaxBytes),
+		)
+	}
+	return npCache.NewMemoryCacheWithOptions(ctx, opts)
+}
+
+// newExactCache wraps the Redis client in an ExactCache scoped to
+// REDIS_KEY_PREFIX.
+func (a *App) newExactCache() *npCache.ExactCache {
+	c := npCache.NewExactCacheFromClient(a.rdb)
+	c.SetNamespace(a.cfg.Redis.KeyPrefix)
+	return c
+}
+
+// redisKeyPrefix applies REDIS_KEY_PREFIX to a Redis limiter or store.
+func (a *App) redisKeyPrefix() ratelimit.Option {
+	return ratelimit.WithKeyPrefix(a.cfg.Redis.KeyPrefix)
+}
+
 // initGateway wires together the Gateway with all configured subsystems.
 func (a *App) initGateway(_ context.Context) error {
 	// ── Determine cache implementation ────────────────────────────────────────
 	var cacheImpl npCache.Cache
 	var cacheReady func() bool
 
+	// compressed applies CACHE_COMPRESS_MIN_BYTES to a storage backend.
+	compressed := func(store npCache.Cache) npCache.Cache {
+		minBytes := a.cfg.Cache.CompressMinBytes
+		if minBytes <= 0 {
+			return store
+		}
+		cc := npCache.NewCompressingCache(store, minBytes)
+		if a.prom != nil {
+			a.prom.ObserveCacheBytesSaved(cc.BytesSaved)
+		}
+		a.log.Info("cache compression enabled", slog.Int("min_bytes", minBytes))
+		return cc
+	}
+
 	switch a.cfg.Cache.Mode {
 	case "redis":
-		cacheImpl = npCache.NewExactCacheFromClient(a.rdb)
+		cacheImpl = compressed(a.newExactCache())
 		cacheReady = redisPinger(a.baseCtx, a.rdb)
 	case "memory":
-		cacheImpl = a.memCache
+		cacheImpl = compressed(a.memCache)
+		cacheReady = func() bool { return true }
+	case "tiered":
+		cacheImpl = compressed(npCache.NewTieredCache(a.memCache, a.newExactCache()))
+		cacheReady = redisPinger(a.baseCtx, a.rdb)
+	case "semantic":
+		embedder, err := a.semanticEmbedder()
+		if err != nil {
+			return fmt.Errorf("semantic cache: %w", err)
+		}
+		cacheImpl = npCache.NewSemanticCache(compressed(a.memCache), embedder, npCache.SemanticOptions{
+			Threshold:  a.cfg.Cache.Semantic.Threshold,
+			MaxEntries: a.cfg.Cache.Semantic.MaxEntries,
+		})
 		cacheReady = func() bool { return true }
 	case "none":
 		// nil cache — gateway handles nil gracefully (no caching)
@@ -93,28 +276,125 @@ func (a *App) initGateway(_ context.Context) error {
 		MaxRetries:         a.cfg.Failover.MaxRetries,
 		ProviderTimeout:    a.cfg.Failover.ProviderTimeout,
 		CacheTTL:           a.cfg.Cache.TTL,
+		CacheErrors:        a.cfg.Cache.CacheErrors,
+		ErrorCacheTTL:      a.cfg.Cache.ErrorTTL,
+		CacheStreams:       a.cfg.Cache.CacheStreams,
+		ReplayChunkSize:    a.cfg.Cache.ReplayChunkSize,
+		KeyNamespace:       a.cfg.Redis.KeyPrefix,
+		StreamKeepAlive:    a.cfg.StreamKeepAlive,
+		TPMLimit:           a.cfg.RateLimit.TPMLimit,
 		Metrics:            a.prom,
+		Tracer:             a.tracer(),
 		AllowClientAPIKeys: a.cfg.AllowClientAPIKeys,
 		CBConfig: proxy.CBConfig{
-			ErrorThreshold:  a.cfg.CircuitBreaker.ErrorThreshold,
-			TimeWindow:      a.cfg.CircuitBreaker.TimeWindow,
-			HalfOpenTimeout: a.cfg.CircuitBreaker.HalfOpenTimeout,
+			ErrorThreshold:           a.cfg.CircuitBreaker.ErrorThreshold,
+			TimeWindow:               a.cfg.CircuitBreaker.TimeWindow,
+			HalfOpenTimeout:          a.cfg.CircuitBreaker.HalfOpenTimeout,
+			HalfOpenMaxProbes:        a.cfg.CircuitBreaker.HalfOpenMaxProbes,
+			HalfOpenSuccessThreshold: a.cfg.CircuitBreaker.HalfOpenSuccessThreshold,
+			PerModel:                 a.cfg.CircuitBreaker.PerModel,
+		},
+		Backoff: proxy.BackoffConfig{
+			BaseDelay:  a.cfg.Failover.BackoffBase,
+			MaxDelay:   a.cfg.Failover.BackoffMax,
+			Multiplier: a.cfg.Failover.BackoffMultiplier,
 		},
+		FallbackChains:         a.cfg.Failover.Chains,
+		ModelFallbackChains:    a.cfg.Failover.ModelChains,
+		LatencyRouting:         a.cfg.Failover.LatencyRouting,
+		HealthRouting:          a.cfg.Failover.HealthRouting,
+		HedgeAfter:             a.cfg.Failover.HedgeAfter,
+		ShadowProvider:         a.cfg.Shadow.Provider,
+		ShadowSampleRate:       a.cfg.Shadow.SampleRate,
+		ModerationProvider:     a.cfg.Moderation.Provider,
+		ModerationModel:        a.cfg.Moderation.Model,
+		ModerationFailClosed:   a.cfg.Moderation.FailClosed,
+		MaxConcurrency:         a.cfg.Concurrency.Max,
+		ProviderMaxConcurrency: a.cfg.Concurrency.ProviderMax,
+		QueueDepth:             a.cfg.Concurrency.QueueDepth,
+		QueueTimeout:           a.cfg.Concurrency.QueueTimeout,
+		QueueAging:             a.cfg.Concurrency.QueueAging,
+		MaxRequestBytes:        a.cfg.MaxRequestBytes,
+		CompressResponses:      a.cfg.ResponseCompression,
+		CompressMinBytes:       a.cfg.ResponseCompressionMinBytes,
+		PassthroughHeaders:     a.cfg.PassthroughHeaders,
+		PassthroughUnavailable: a.cfg.PassthroughUnavailable,
+		SamplingPolicy:         a.cfg.SamplingPolicy,
+		StrictValidation:       a.cfg.StrictValidation,
+		CheckContextWindow:     a.cfg.ContextWindowCheck,
+		ContextFallbackModels:  a.cfg.ContextFallbackModels,
+		GatewayAPIKeys:         a.cfg.GatewayAPIKeys,
+		VirtualKeys:            virtualKeys(a.cfg.VirtualKeys),
+		Pricing:                pricing(a.cfg.Pricing),
+		RequestTransformers:    requestTransformers(a.cfg.Transforms),
 	}
 
 	gw := proxy.NewGatewayWithOptions(a.baseCtx, a.provs, cacheImpl, cacheReady, opts)
 
 	// ── Optional subsystems ──────────────────────────────────────────────────
 
-	// Rate limiting — only when Redis is available.
-	if a.rdb != nil && a.cfg.RateLimit.RPMLimit > 0 {
-		gw.SetRateLimiters(ratelimit.NewRPMLimiter(a.rdb, a.cfg.RateLimit.RPMLimit))
-		a.log.Info("rate limiting enabled", slog.Int("rpm_limit", a.cfg.RateLimit.RPMLimit))
+	// Rate limiting — Redis-backed (cluster-wide) or in-process.
+	if a.cfg.RateLimit.RPMLimit > 0 {
+		backend := a.cfg.RateLimitBackend()
+		algorithm := ratelimit.WithAlgorithm(ratelimit.Algorithm(a.cfg.RateLimit.Algorithm))
+		if backend == "redis" && a.rdb != nil {
+			gw.SetRateLimiters(ratelimit.NewRPMLimiter(a.rdb, a.cfg.RateLimit.RPMLimit, algorithm, a.redisKeyPrefix()))
+		} else {
+			backend = "memory"
+			gw.SetRateLimiters(ratelimit.NewMemoryRPMLimiter(a.cfg.RateLimit.RPMLimit, algorithm))
+		}
+		a.log.Info("rate limiting enabled",
+			slog.Int("rpm_limit", a.cfg.RateLimit.RPMLimit),
+			slog.String("backend", backend),
+			slog.String("algorithm", a.cfg.RateLimit.Algorithm),
+		)
+	}
+	if a.rdb != nil && a.cfg.RateLimit.TPMLimit >= 0 {
+		gw.SetTPMLimiter(ratelimit.NewTPMLimiter(a.rdb, a.redisKeyPrefix()))
+		a.log.Info("token rate limiting enabled", slog.Int("tpm_limit", a.cfg.RateLimit.TPMLimit))
+	}
+
+	if n := len(a.cfg.GatewayAPIKeys); n > 0 {
+		a.log.Info("gateway authentication enabled", slog.Int("keys", n))
+	}
+	if n := len(a.cfg.VirtualKeys); n > 0 {
+		backend := "memory"
+		if a.rdb != nil {
+			backend = "redis"
+			gw.SetVirtualKeyStores(
+				ratelimit.NewRedisBudgetStore(a.rdb, a.redisKeyPrefix()),
+				ratelimit.NewRPMLimiter(a.rdb, 0, a.redisKeyPrefix()),
+			)
+		}
+		a.log.Info("virtual keys loaded", slog.Int("keys", n), slog.String("backend", backend))
+	}
+	if n := len(a.cfg.Transforms); n > 0 {
+		a.log.Info("request transforms enabled", slog.Int("transforms", n))
 	}
 
-	// Async request logger — not wired in the open-source build.
-	// In the managed version this connects to ClickHouse for analytics.
-	// Request metadata is still written via slog (see gateway.go logRequest).
+	// Idempotency keys (IDEMPOTENCY_TTL), kept apart from the response cache.
+	if ttl := a.cfg.IdempotencyTTL; ttl > 0 {
+		backend := "memory"
+		var store npCache.Cache
+		if a.rdb != nil {
+			backend = "redis"
+			store = a.newExactCache()
+		} else {
+			store = npCache.NewMemoryCache(a.baseCtx)
+		}
+		gw.SetIdempotencyStore(store, ttl)
+		a.log.Info("idempotency keys enabled", slog.Duration("ttl", ttl), slog.String("backend", backend))
+	}
+
+	// Async request logger (LOG_SINK).
+	if a.reqLogger != nil {
+		gw.SetLogger(a.reqLogger)
+	}
+
+	// Request completion callbacks (WEBHOOK_URL).
+	if a.webhooks != nil {
+		gw.SetWebhook(a.webhooks)
+	}
 
 	// CORS.
 	gw.SetCORSOrigins(a.cfg.CORSOrigins)
@@ -129,9 +409,24 @@ func (a *App) initGateway(_ context.Context) error {
 		a.log.Info("cache exclusions loaded", slog.Int("rules", el.Len()))
 	}
 
+	// Per-model cache TTLs.
+	if len(a.cfg.Cache.TTLExact) > 0 || len(a.cfg.Cache.TTLPatterns) > 0 {
+		o, err := npCache.NewTTLOverrides(a.cfg.Cache.TTLExact, a.cfg.Cache.TTLPatterns)
+		if err != nil {
+			return fmt.Errorf("cache ttl overrides: %w", err)
+		}
+		gw.SetCacheTTLOverrides(o)
+		a.log.Info("cache ttl overrides loaded", slog.Int("rules", o.Len()))
+	}
+
 	// ── Management routes ────────────────────────────────────────────────────
 	a.mgmt = &proxy.ManagementRoutes{
 		Metrics: a.prom.Handler(),
+		MetricsAuth: proxy.MetricsAuth{
+			Token:    a.cfg.MetricsAuthToken,
+			User:     a.cfg.MetricsAuthUser,
+			Password: a.cfg.MetricsAuthPassword,
+		},
 	}
 
 	a.gw = gw
@@ -139,6 +434,30 @@ func (a *App) initGateway(_ context.Context) error {
 	return nil
 }
 
+// semanticEmbedder adapts the provider serving the configured embedding model
+// to the cache.Embedder interface used by the semantic cache.
+func (a *App) semanticEmbedder() (npCache.Embedder, error) {
+	model := a.cfg.Cache.Semantic.EmbeddingModel
+	name, ok := providers.EmbeddingModelAliases[model]
+	if !ok {
+		name = "openai"
+	}
+	ep, ok := a.provs[name].(providers.EmbeddingProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider %q for embedding model %q is not configured or does not support embeddings", name, model)
+	}
+	return npCache.EmbedderFunc(func(ctx context.Context, text string) ([]float32, error) {
+		resp, err := ep.Embed(ctx, &providers.EmbeddingRequest{Input: []string{text}, Model: model})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) == 0 {
+			return nil, fmt.Errorf("empty embedding response")
+		}
+		return resp.Data[0].Embedding, nil
+	}), nil
+}
+
 // redactURL replaces the userinfo portion of a URL with "***" for safe logging.
 // e.g. "redis://:secret@localhost:6379" → "redis://***@localhost:6379"
 func redactURL(raw string) string {
@@ -155,3 +474,97 @@ func redactURL(raw string) string {
 	}
 	return raw
 }
+
+// virtualKeys converts the configured virtual keys to the gateway's form.
+func virtualKeys(cfg []config.VirtualKeyConfig) []proxy.VirtualKey {
+	if len(cfg) == 0 {
+		return nil
+	}
+	keys := make([]proxy.VirtualKey, len(cfg))
+	for i, k := range cfg {
+		keys[i] = proxy.VirtualKey{
+			Name:         k.Name,
+			Key:          k.Key,
+			Models:       k.Models,
+			RPM:          k.RPM,
+			TPM:          k.TPM,
+			TokenBudget:  k.TokenBudget,
+			BudgetPeriod: time.Duration(k.BudgetPeriod),
+			Priority:     k.Priority,
+			SystemPrompt: k.SystemPrompt,
+			Workspace:    k.Workspace,
+		}
+	}
+	return keys
+}
+
+// requestTransformers builds the TRANSFORMS pipeline, in order.
+func requestTransformers(cfg []config.TransformConfig) []proxy.RequestTransformer {
+	var out []proxy.RequestTransformer
+	for _, t := range cfg {
+		switch t.Type {
+		case "default_system_prompt":
+			out = append(out, proxy.DefaultSystemPrompt{Prompt: t.SystemPrompt})
+		case "param_clamp":
+			out = append(out, proxy.ParamClamp{
+				MinTemperature: t.MinTemperature,
+				MaxTemperature: t.MaxTemperature,
+				MaxTopP:        t.MaxTopP,
+				MaxTokens:      t.MaxTokens,
+			})
+		}
+	}
+	return out
+}
+
+func pricing(cfg map[string]config.ModelPricing) map[string]proxy.ModelPrice {
+	if len(cfg) == 0 {
+		return nil
+	}
+	prices := make(map[string]proxy.ModelPrice, len(cfg))
+	for model, p := range cfg {
+		prices[model] = proxy.ModelPrice{InputPer1K: p.InputPer1K, OutputPer1K: p.OutputPer1K}
+	}
+	return prices
+}
+
+// initRequestLogger starts the async request logger for the configured sink.
+// LOG_SINK=none leaves it nil.
+func (a *App) initRequestLogger(ctx context.Context) error {
+	rc := a.cfg.RequestLog
+	opts := logger.Options{BatchSize: rc.BatchSize, FlushInterval: rc.FlushInterval}
+
+	var sink logger.Sink
+	switch rc.Sink {
+	case "stdout":
+		sink = logger.NewSlogSink(a.log)
+	case "clickhouse":
+		ch, err := logger.NewClickHouseSink(rc.ClickHouseDSN, rc.ClickHouseTable)
+		if err != nil {
+			return err
+		}
+		sink = ch
+	default:
+		return nil
+	}
+
+	l, err := logger.NewWithSink(ctx, sink, opts, a.log)
+	if err != nil {
+		return err
+	}
+	a.reqLogger = l
+	a.log.Info("request logging enabled",
+		slog.String("sink", rc.Sink),
+		slog.Int("batch_size", rc.BatchSize),
+		slog.Duration("flush_interval", rc.FlushInterval),
+	)
+	return nil
+}
+
+// tracer returns the gateway's tracer, or nil when trace export is disabled.
+func (a *App) tracer() tracing.Tracer {
+	if a.traces == nil {
+		return nil
+	}
+	return tracing.New(a.traces)
+}
@@ -7,6 +7,45 @@ import (
 
 type Cache interface {
 	Get(ctx context.Context, key string) ([]byte, bool)
+
+	// GetWithTTL is Get that also returns how long the entry has left to
+	// live; the TTL is zero when the backend cannot tell or the entry never
+	// expires.
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool)
+
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
 	Delete(ctx context.Context, key string) error
+
+	// Flush removes every cached entry.
+	Flush(ctx context.Context) error
+
+	// Stats reports the number of entries and, where the backend can tell
+	// cheaply, the memory they use.
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// Stats summarises a cache backend's contents.
+type Stats struct {
+	Backend string `json:"backend"`
+	Entries int64  `json:"entries"`
+	// MemoryBytes is the size of keys and values held in process; zero for
+	// backends that cannot report it.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+	// Indexed is the number of prompts in a semantic cache's vector index.
+	Indexed int `json:"indexed,omitempty"`
+}
+
+// SimilarityCache is a Cache that can additionally match requests by meaning
+// rather than by exact key. scope partitions the index so that only prompts
+// sent with identical parameters (model, temperature, …) can match each other.
+type SimilarityCache interface {
+	Cache
+
+	// GetSimilar returns the value stored for the prompt in scope that is most
+	// similar to prompt, provided it clears the similarity threshold.
+	GetSimilar(ctx context.Context, scope, prompt string) ([]byte, bool)
+
+	// SetSimilar stores value under key (like Set) and indexes prompt so that
+	// later GetSimilar calls in the same scope can find it.
+	SetSimilar(ctx context.Context, scope, prompt, key string, value []byte, ttl time.Duration) error
 }
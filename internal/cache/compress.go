@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gzipMagic starts every compressed value: compressed values are stored as
+// a bare gzip stream. Uncompressed values are stored unchanged. Cached
+// responses are JSON and negative entries start with a NUL byte (see
+// proxy.negativeEntryPrefix), so neither can begin with 0x1f and entries
+// written before compression was enabled still read back as-is.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// CompressingCache wraps a Cache and gzips values of at least minSize bytes
+// before storing them, decompressing transparently on read. Values that do
+// not shrink are stored raw. Delete, Flush and Stats pass through, so Stats
+// reports the compressed footprint.
+type CompressingCache struct {
+	Cache
+	minSize int
+	saved   atomic.Int64
+}
+
+// NewCompressingCache wraps inner, compressing values of minSize bytes or
+// more.
+func NewCompressingCache(inner Cache, minSize int) *CompressingCache {
+	return &CompressingCache{Cache: inner, minSize: minSize}
+}
+
+// Get returns the decompressed value for key.
+func (c *CompressingCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	v, ok := c.Cache.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	return decompress(v)
+}
+
+// GetWithTTL returns the decompressed value for key and its remaining TTL.
+func (c *CompressingCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool) {
+	v, ttl, ok := c.Cache.GetWithTTL(ctx, key)
+	if !ok {
+		return nil, 0, false
+	}
+	v, ok = decompress(v)
+	return v, ttl, ok
+}
+
+// Set stores value under key, compressed when it is large enough.
+func (c *CompressingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.Cache.Set(ctx, key, c.compress(value), ttl)
+}
+
+// BytesSaved returns the total number of bytes compression has saved across
+// all writes.
+func (c *CompressingCache) BytesSaved() int64 { return c.saved.Load() }
+
+func (c *CompressingCache) compress(value []byte) []byte {
+	if len(value) < c.minSize {
+		return value
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(value) / 4)
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(value); err != nil {
+		return value
+	}
+	if err := zw.Close(); err != nil {
+		return value
+	}
+	if buf.Len() >= len(value) {
+		return value
+	}
+	c.saved.Add(int64(len(value) - buf.Len()))
+	return buf.Bytes()
+}
+
+// decompress undoes compress. A corrupt compressed value reads as a miss.
+func decompress(v []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(v, gzipMagic) {
+		return v, true
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(v))
+	if err != nil {
+		return nil, false
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
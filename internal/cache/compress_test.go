@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressingCache_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryCache(ctx)
+	defer mem.Close()
+	c := NewCompressingCache(mem, 1024)
+
+	small := []byte(`{"id":"chatcmpl-1","choices":[]}`)
+	large := []byte(`{"content":"` + strings.Repeat("all work and no play ", 500) + `"}`)
+
+	for name, value := range map[string][]byte{"small": small, "large": large} {
+		if err := c.Set(ctx, name, value, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := c.Get(ctx, name)
+		if !ok || !bytes.Equal(got, value) {
+			t.Errorf("%s: Get returned %d bytes, ok=%v; want the original %d bytes", name, len(got), ok, len(value))
+		}
+		got, ttl, ok := c.GetWithTTL(ctx, name)
+		if !ok || !bytes.Equal(got, value) || ttl <= 0 {
+			t.Errorf("%s: GetWithTTL = %d bytes, %v, %v", name, len(got), ttl, ok)
+		}
+	}
+
+	// Below the threshold the value is stored raw; above it, compressed.
+	if raw, _ := mem.Get(ctx, "small"); !bytes.Equal(raw, small) {
+		t.Error("small value should be stored uncompressed")
+	}
+	stored, _ := mem.Get(ctx, "large")
+	if !bytes.HasPrefix(stored, gzipMagic) || len(stored) >= len(large) {
+		t.Errorf("large value stored as %d bytes, want compressed below %d", len(stored), len(large))
+	}
+	if got := c.BytesSaved(); got != int64(len(large)-len(stored)) {
+		t.Errorf("BytesSaved = %d, want %d", got, len(large)-len(stored))
+	}
+}
+
+func TestCompressingCache_ReadsRawEntries(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryCache(ctx)
+	defer mem.Close()
+
+	// An entry written before compression was enabled.
+	legacy := []byte(`{"content":"` + strings.Repeat("x", 4096) + `"}`)
+	_ = mem.Set(ctx, "k", legacy, time.Minute)
+
+	c := NewCompressingCache(mem, 1024)
+	if got, ok := c.Get(ctx, "k"); !ok || !bytes.Equal(got, legacy) {
+		t.Error("uncompressed entries must read back unchanged")
+	}
+
+	_ = mem.Set(ctx, "corrupt", append(append([]byte{}, gzipMagic...), 'n', 'o', 'p', 'e'), time.Minute)
+	if _, ok := c.Get(ctx, "corrupt"); ok {
+		t.Error("a corrupt compressed entry should be a miss")
+	}
+}
+
+func TestCompressingCache_Redis(t *testing.T) {
+	ctx := context.Background()
+	rc, mr := newTestCache(t)
+	c := NewCompressingCache(rc, 1024)
+
+	large := []byte(strings.Repeat(`{"embedding":[0.125,0.25,0.5]}`, 200))
+	if err := c.Set(ctx, "emb", large, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := mr.Get("emb"); len(raw) >= len(large) {
+		t.Errorf("Redis holds %d bytes, want fewer than %d", len(raw), len(large))
+	}
+	if got, ok := c.Get(ctx, "emb"); !ok || !bytes.Equal(got, large) {
+		t.Error("compressed Redis value did not round-trip")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -18,6 +19,14 @@ import (
 
 const defaultCacheTimeout = 500 * time.Millisecond
 
+// keyPattern matches the keys the gateway writes (see proxy.buildCacheKey),
+// so Flush and Stats leave rate-limit and other keys in the same database
+// alone. SetNamespace prefixes it.
+const keyPattern = "cache:*"
+
+// scanBatch is the SCAN COUNT hint used by Flush and Stats.
+const scanBatch = 500
+
 // ExactCache is a Redis-backed cache that implements the Cache interface.
 //
 // All operations degrade gracefully when Redis is unavailable:
@@ -25,14 +34,22 @@ const defaultCacheTimeout = 500 * time.Millisecond
 //   - Set returns nil even on error (silent degradation keeps proxy alive).
 //   - Delete returns the underlying error so callers can log/handle it.
 type ExactCache struct {
-	client       *redis.Client
+	client       redis.UniversalClient
 	queryTimeout time.Duration
+	keyPattern   string
 }
 
 // NewExactCacheFromClient wraps an existing Redis client in an ExactCache.
 // The caller owns the client lifecycle (creation and Close).
-func NewExactCacheFromClient(redisCli *redis.Client) *ExactCache {
-	return &ExactCache{client: redisCli, queryTimeout: defaultCacheTimeout}
+func NewExactCacheFromClient(redisCli redis.UniversalClient) *ExactCache {
+	return &ExactCache{client: redisCli, queryTimeout: defaultCacheTimeout, keyPattern: keyPattern}
+}
+
+// SetNamespace restricts Flush and Stats to keys under namespace, the
+// prefix the gateway puts before "cache:" (see proxy.GatewayOptions
+// .KeyNamespace). Call it before the cache is used.
+func (c *ExactCache) SetNamespace(namespace string) {
+	c.keyPattern = namespace + keyPattern
 }
 
 // NewExactCacheFromURL parses redisURL, creates a Redis client, verifies the
@@ -58,7 +75,7 @@ func NewExactCacheFromURL(ctx context.Context, redisURL string) (*ExactCache, er
 		return nil, fmt.Errorf("cache: ping: %w", err)
 	}
 
-	return &ExactCache{client: cli, queryTimeout: defaultCacheTimeout}, nil
+	return &ExactCache{client: cli, queryTimeout: defaultCacheTimeout, keyPattern: keyPattern}, nil
 }
 
 // Get retrieves the value for key from Redis.
@@ -82,6 +99,42 @@ func (c *ExactCache) Get(ctx context.Context, key string) ([]byte, bool) {
 	return val, true
 }
 
+// GetWithTTL is Get that also returns the key's remaining TTL, read with PTTL
+// in the same round trip. The TTL is zero for keys without an expiry.
+func (c *ExactCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	defer cancel()
+
+	var (
+		get  *redis.StringCmd
+		pttl *redis.DurationCmd
+	)
+	_, err := c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		get = p.Get(ctx, key)
+		pttl = p.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.WarnContext(ctx, "cache_get_error",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+		}
+		return nil, 0, false
+	}
+
+	val, err := get.Bytes()
+	if err != nil {
+		return nil, 0, false
+	}
+	ttl := pttl.Val()
+	if ttl < 0 { // -1: no expiry; -2: the key vanished between the commands
+		ttl = 0
+	}
+	return val, ttl, true
+}
+
 // Set stores value under key with the given TTL.
 // Returns nil even on Redis error — graceful degradation keeps the proxy
 // functioning when the cache layer is unavailable.
@@ -112,6 +165,77 @@ func (c *ExactCache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Flush deletes every cache key in the namespace, on every master of a
+// Redis Cluster. It scans rather than FLUSHDB because the database is shared
+// with the rate limiters. Keys are deleted one per command so that a batch
+// never spans cluster hash slots.
+func (c *ExactCache) Flush(ctx context.Context) error {
+	err := c.forEachNode(ctx, func(ctx context.Context, node redis.UniversalClient) error {
+		iter := node.Scan(ctx, 0, c.keyPattern, scanBatch).Iterator()
+		batch := make([]string, 0, scanBatch)
+		for iter.Next(ctx) {
+			batch = append(batch, iter.Val())
+			if len(batch) == scanBatch {
+				if err := deleteKeys(ctx, node, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		return deleteKeys(ctx, node, batch)
+	})
+	if err != nil {
+		return fmt.Errorf("cache: flush: %w", err)
+	}
+	return nil
+}
+
+// Stats counts the cache keys in the namespace across every master. Redis
+// memory is shared with other keys, so MemoryBytes is not reported.
+func (c *ExactCache) Stats(ctx context.Context) (Stats, error) {
+	var entries atomic.Int64
+	err := c.forEachNode(ctx, func(ctx context.Context, node redis.UniversalClient) error {
+		iter := node.Scan(ctx, 0, c.keyPattern, scanBatch).Iterator()
+		for iter.Next(ctx) {
+			entries.Add(1)
+		}
+		return iter.Err()
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("cache: stats: %w", err)
+	}
+	return Stats{Backend: "redis", Entries: entries.Load()}, nil
+}
+
+// forEachNode calls fn with each master of a Redis Cluster, concurrently, or
+// once with the client itself for a single node or Sentinel-managed master.
+// SCAN only sees the keys of the node it runs on.
+func (c *ExactCache) forEachNode(ctx context.Context, fn func(context.Context, redis.UniversalClient) error) error {
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return fn(ctx, node)
+		})
+	}
+	return fn(ctx, c.client)
+}
+
+// deleteKeys deletes keys from node in a single pipeline.
+func deleteKeys(ctx context.Context, node redis.UniversalClient, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := node.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, k := range keys {
+			p.Del(ctx, k)
+		}
+		return nil
+	})
+	return err
+}
+
 // Close releases the Redis connection pool.
 func (c *ExactCache) Close() error {
 	return c.client.Close()
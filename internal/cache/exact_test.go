@@ -84,6 +84,32 @@ func TestTTLIsSet(t *testing.T) {
 	}
 }
 
+// TestGetWithTTL verifies that GetWithTTL reports the key's remaining TTL.
+func TestGetWithTTL(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	if _, _, ok := c.GetWithTTL(ctx, "missing"); ok {
+		t.Fatal("missing key should be a miss")
+	}
+
+	if err := c.Set(ctx, "k", []byte("v"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(20 * time.Second)
+	val, ttl, ok := c.GetWithTTL(ctx, "k")
+	if !ok || string(val) != "v" || ttl != 40*time.Second {
+		t.Errorf("GetWithTTL = %q, %v, %v; want v, 40s, true", val, ttl, ok)
+	}
+
+	if err := c.Set(ctx, "forever", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ttl, ok := c.GetWithTTL(ctx, "forever"); !ok || ttl != 0 {
+		t.Errorf("key without expiry: ttl = %v, ok = %v; want 0, true", ttl, ok)
+	}
+}
+
 // TestDelete verifies that Delete removes an existing key.
 func TestDelete(t *testing.T) {
 	c, _ := newTestCache(t)
@@ -170,3 +196,35 @@ func TestNewExactCacheInvalidURL(t *testing.T) {
 func TestCacheImplementsInterface(t *testing.T) {
 	var _ Cache = (*ExactCache)(nil)
 }
+
+// TestFlushAndStats verifies that Flush and Stats only touch cache: keys.
+func TestFlushAndStats(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	for _, k := range []string{"cache:a", "cache:b", "cache:c"} {
+		_ = c.Set(ctx, k, []byte("v"), time.Minute)
+	}
+	_ = mr.Set("ratelimit:ws:rpm", "1")
+
+	st, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if st.Backend != "redis" || st.Entries != 3 {
+		t.Errorf("Stats = %+v, want 3 redis entries", st)
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, ok := c.Get(ctx, "cache:a"); ok {
+		t.Error("cache entries should be gone after Flush")
+	}
+	if !mr.Exists("ratelimit:ws:rpm") {
+		t.Error("Flush must not delete keys outside the cache: prefix")
+	}
+	if st, _ := c.Stats(ctx); st.Entries != 0 {
+		t.Errorf("Entries after Flush = %d, want 0", st.Entries)
+	}
+}
@@ -35,9 +35,9 @@ func NewExclusionList(exact, patterns []string) (*ExclusionList, error) {
 		if p == "" {
 			continue
 		}
-		re, err := regexp.Compile(p)
+		re, err := compileModelPattern("cache exclusion", p)
 		if err != nil {
-			return nil, fmt.Errorf("cache exclusion: invalid pattern %q: %w", p, err)
+			return nil, err
 		}
 		el.patterns = append(el.patterns, re)
 	}
@@ -69,3 +69,14 @@ func (el *ExclusionList) Len() int {
 	}
 	return len(el.exact) + len(el.patterns)
 }
+
+// compileModelPattern compiles a regular expression matched against model
+// names, naming the rule kind in the error so misconfiguration is easy to
+// trace at startup.
+func compileModelPattern(kind, p string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid pattern %q: %w", kind, p, err)
+	}
+	return re, nil
+}
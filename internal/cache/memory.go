@@ -6,41 +6,75 @@
 //     Ideal for single-instance deployments or local development.
 //
 // Both implement the Cache interface so they are fully interchangeable.
+// TieredCache combines them, serving hot keys from memory in front of Redis.
+// SemanticCache wraps either one to additionally match paraphrased prompts
+// by embedding similarity (see SimilarityCache).
 package cache
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// memItem stores a cached value together with its expiry time.
+// memItem stores a cached value together with its key and expiry time.
 type memItem struct {
+	key       string
 	data      []byte
 	expiresAt time.Time
 }
 
+// size is the number of bytes memItem counts against MemoryOptions.MaxBytes.
+func (it *memItem) size() int64 { return int64(len(it.key) + len(it.data)) }
+
+// MemoryOptions bounds a MemoryCache. Zero values mean unbounded.
+type MemoryOptions struct {
+	// MaxEntries caps the number of entries.
+	MaxEntries int
+
+	// MaxBytes caps the bytes held by keys and values. A single value
+	// larger than this is not stored.
+	MaxBytes int64
+}
+
 // MemoryCache is a simple in-process cache with per-entry TTL.
 //
 // It is safe for concurrent use. A background goroutine periodically
-// removes expired entries to prevent unbounded memory growth.
+// removes expired entries to prevent unbounded memory growth. When bounded
+// by MemoryOptions, the least recently used entries are evicted to make
+// room for new ones.
 //
 // Use this backend when Redis is not available — for local development,
 // single-instance deployments, or integration tests. For distributed
 // (multi-replica) deployments use ExactCache (Redis) instead so that
 // all replicas share the same cache.
 type MemoryCache struct {
-	mu    sync.RWMutex
-	items map[string]memItem
+	mu    sync.Mutex
+	items map[string]*list.Element // of *memItem
+	lru   *list.List               // most recently used first
+	bytes int64
+	opts  MemoryOptions
+
+	evictions atomic.Int64
 
 	done chan struct{}
 }
 
-// NewMemoryCache creates a MemoryCache and starts the background cleanup loop.
-// The cleanup goroutine stops when ctx is cancelled or Close is called.
+// NewMemoryCache creates an unbounded MemoryCache and starts the background
+// cleanup loop. The cleanup goroutine stops when ctx is cancelled or Close
+// is called.
 func NewMemoryCache(ctx context.Context) *MemoryCache {
+	return NewMemoryCacheWithOptions(ctx, MemoryOptions{})
+}
+
+// NewMemoryCacheWithOptions is NewMemoryCache bounded by opts.
+func NewMemoryCacheWithOptions(ctx context.Context, opts MemoryOptions) *MemoryCache {
 	c := &MemoryCache{
-		items: make(map[string]memItem),
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+		opts:  opts,
 		done:  make(chan struct{}),
 	}
 	go c.cleanup(ctx)
@@ -49,24 +83,32 @@ func NewMemoryCache(ctx context.Context) *MemoryCache {
 
 // Get returns the cached value for key. Returns (nil, false) on a miss or if
 // the entry has expired. Expired entries are removed lazily on access.
-func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, bool) {
-	c.mu.RLock()
-	item, ok := c.items[key]
-	c.mu.RUnlock()
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	data, _, ok := c.GetWithTTL(ctx, key)
+	return data, ok
+}
 
+// GetWithTTL is Get that also returns the time left until the entry expires.
+// A hit marks the entry as most recently used.
+func (c *MemoryCache) GetWithTTL(_ context.Context, key string) ([]byte, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
 	if !ok {
-		return nil, false
+		return nil, 0, false
 	}
+	item := el.Value.(*memItem)
 
-	if time.Now().After(item.expiresAt) {
-		// Lazy expiry — remove the stale entry without blocking reads.
-		c.mu.Lock()
-		delete(c.items, key)
-		c.mu.Unlock()
-		return nil, false
+	remaining := time.Until(item.expiresAt)
+	if remaining <= 0 {
+		// Lazy expiry.
+		c.removeLocked(el)
+		return nil, 0, false
 	}
 
-	return item.data, true
+	c.lru.MoveToFront(el)
+	return item.data, remaining, true
 }
 
 // Set stores value under key for the duration of ttl.
@@ -76,12 +118,20 @@ func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.
 		ttl = time.Hour
 	}
 
+	item := &memItem{key: key, data: value, expiresAt: time.Now().Add(ttl)}
+
 	c.mu.Lock()
-	c.items[key] = memItem{
-		data:      value,
-		expiresAt: time.Now().Add(ttl),
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
 	}
-	c.mu.Unlock()
+	if c.opts.MaxBytes > 0 && item.size() > c.opts.MaxBytes {
+		return nil // would evict everything else and still not fit
+	}
+	c.items[key] = c.lru.PushFront(item)
+	c.bytes += item.size()
+	c.evictLocked()
 
 	return nil
 }
@@ -89,24 +139,65 @@ func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.
 // Delete removes key from the cache. Returns nil if the key did not exist.
 func (c *MemoryCache) Delete(_ context.Context, key string) error {
 	c.mu.Lock()
-	delete(c.items, key)
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
+	}
 	c.mu.Unlock()
 	return nil
 }
 
+// Flush removes every entry.
+func (c *MemoryCache) Flush(_ context.Context) error {
+	c.mu.Lock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Stats reports the entry count and the bytes held by keys and values
+// (including entries that may have expired but not yet been evicted).
+func (c *MemoryCache) Stats(_ context.Context) (Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Backend: "memory", Entries: int64(len(c.items)), MemoryBytes: c.bytes}, nil
+}
+
 // Len returns the number of entries currently held in the cache
 // (including entries that may have expired but not yet been evicted).
 func (c *MemoryCache) Len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return len(c.items)
 }
 
+// Evictions returns the number of entries evicted to stay within
+// MemoryOptions. Expired entries are not counted.
+func (c *MemoryCache) Evictions() int64 { return c.evictions.Load() }
+
 // Close stops the background cleanup goroutine.
 func (c *MemoryCache) Close() {
 	close(c.done)
 }
 
+// removeLocked drops el from the cache. c.mu must be held.
+func (c *MemoryCache) removeLocked(el *list.Element) {
+	item := c.lru.Remove(el).(*memItem)
+	delete(c.items, item.key)
+	c.bytes -= item.size()
+}
+
+// evictLocked removes least recently used entries until the cache is
+// within its bounds. c.mu must be held.
+func (c *MemoryCache) evictLocked() {
+	for (c.opts.MaxEntries > 0 && len(c.items) > c.opts.MaxEntries) ||
+		(c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes) {
+		c.removeLocked(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
 // cleanup runs every 5 minutes and evicts all expired entries.
 func (c *MemoryCache) cleanup(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Minute)
@@ -128,9 +219,9 @@ func (c *MemoryCache) evictExpired() {
 	now := time.Now()
 
 	c.mu.Lock()
-	for k, v := range c.items {
-		if now.After(v.expiresAt) {
-			delete(c.items, k)
+	for _, el := range c.items {
+		if now.After(el.Value.(*memItem).expiresAt) {
+			c.removeLocked(el)
 		}
 	}
 	c.mu.Unlock()
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacheWithOptions(ctx, MemoryOptions{MaxEntries: 2})
+	defer c.Close()
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+	if _, ok := c.Get(ctx, "a"); !ok { // b is now least recently used
+		t.Fatal("a missing before the cap was reached")
+	}
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(ctx, k); !ok {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+	if n := c.Evictions(); n != 1 {
+		t.Errorf("Evictions = %d, want 1", n)
+	}
+}
+
+func TestMemoryCache_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	// Each entry is a 1-byte key plus a 4-byte value.
+	c := NewMemoryCacheWithOptions(ctx, MemoryOptions{MaxBytes: 12})
+	defer c.Close()
+
+	_ = c.Set(ctx, "a", []byte("aaaa"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("bbbb"), time.Minute)
+	_ = c.Set(ctx, "c", []byte("cccc"), time.Minute)
+
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Error("a was not evicted")
+	}
+	st, _ := c.Stats(ctx)
+	if st.Entries != 2 || st.MemoryBytes != 10 {
+		t.Errorf("Stats = %+v, want 2 entries, 10 bytes", st)
+	}
+
+	// A value that can never fit is not stored and evicts nothing.
+	_ = c.Set(ctx, "big", make([]byte, 64), time.Minute)
+	if _, ok := c.Get(ctx, "big"); ok {
+		t.Error("oversized value was stored")
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len = %d after oversized Set, want 2", n)
+	}
+}
+
+func TestMemoryCache_TTLWithEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCacheWithOptions(ctx, MemoryOptions{MaxEntries: 2})
+	defer c.Close()
+
+	_ = c.Set(ctx, "short", []byte("1"), time.Millisecond)
+	_ = c.Set(ctx, "long", []byte("2"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get(ctx, "short"); ok {
+		t.Error("expired entry was served")
+	}
+	if _, ok := c.Get(ctx, "long"); !ok {
+		t.Error("live entry missing")
+	}
+	// Lazy expiry freed a slot, so this Set evicts nothing.
+	_ = c.Set(ctx, "new", []byte("3"), time.Minute)
+	if n := c.Evictions(); n != 0 {
+		t.Errorf("Evictions = %d, want 0: expiry is not eviction", n)
+	}
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults for SemanticOptions.
+const (
+	DefaultSemanticThreshold  = 0.95
+	DefaultSemanticMaxEntries = 10_000
+)
+
+// Embedder turns text into a vector embedding.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbedderFunc adapts an ordinary function to the Embedder interface.
+type EmbedderFunc func(ctx context.Context, text string) ([]float32, error)
+
+// Embed calls f(ctx, text).
+func (f EmbedderFunc) Embed(ctx context.Context, text string) ([]float32, error) {
+	return f(ctx, text)
+}
+
+// SemanticOptions tunes a SemanticCache. Zero values use the defaults.
+type SemanticOptions struct {
+	// Threshold is the minimum cosine similarity (0, 1] for a prompt to be
+	// served another prompt's response. Default: 0.95.
+	Threshold float64
+
+	// MaxEntries caps the number of indexed prompts. When full, the oldest
+	// entry is evicted. Default: 10,000.
+	MaxEntries int
+}
+
+// semanticEntry is one indexed prompt. vec is L2-normalised so that cosine
+// similarity reduces to a dot product.
+type semanticEntry struct {
+	scope     string
+	key       string
+	vec       []float32
+	expiresAt time.Time
+}
+
+// SemanticCache matches prompts by embedding similarity.
+//
+// Values live in an underlying Cache under their exact keys, so exact-match
+// lookups keep working unchanged; the vector index is held in process and is
+// therefore not shared across replicas. Lookups are a linear scan, which is
+// adequate for the index sizes this is meant for.
+//
+// Embedding failures degrade to a cache miss, never to a request failure.
+type SemanticCache struct {
+	store      Cache
+	embedder   Embedder
+	threshold  float64
+	maxEntries int
+
+	mu      sync.Mutex
+	entries []semanticEntry // oldest first
+	// pending holds vectors computed by a GetSimilar miss so that the
+	// SetSimilar that usually follows does not embed the same prompt twice.
+	pending map[[sha256.Size]byte][]float32
+}
+
+// NewSemanticCache returns a SemanticCache storing values in store and
+// embedding prompts with embedder.
+func NewSemanticCache(store Cache, embedder Embedder, opts SemanticOptions) *SemanticCache {
+	if opts.Threshold <= 0 || opts.Threshold > 1 {
+		opts.Threshold = DefaultSemanticThreshold
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultSemanticMaxEntries
+	}
+	return &SemanticCache{
+		store:      store,
+		embedder:   embedder,
+		threshold:  opts.Threshold,
+		maxEntries: opts.MaxEntries,
+		pending:    make(map[[sha256.Size]byte][]float32),
+	}
+}
+
+// Get returns the value stored under the exact key.
+func (c *SemanticCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	return c.store.Get(ctx, key)
+}
+
+// GetWithTTL returns the value stored under the exact key and its remaining
+// TTL.
+func (c *SemanticCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool) {
+	return c.store.GetWithTTL(ctx, key)
+}
+
+// Set stores value under key without indexing it for similarity lookups.
+func (c *SemanticCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.store.Set(ctx, key, value, ttl)
+}
+
+// Delete removes key from the store and drops any index entries pointing at it.
+func (c *SemanticCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if e.key != key {
+			kept = append(kept, e)
+		}
+	}
+	c.entries = kept
+	c.mu.Unlock()
+
+	return c.store.Delete(ctx, key)
+}
+
+// Flush empties the store and the similarity index.
+func (c *SemanticCache) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	c.entries = nil
+	c.pending = make(map[[sha256.Size]byte][]float32)
+	c.mu.Unlock()
+
+	return c.store.Flush(ctx)
+}
+
+// Stats reports the store's stats along with the size of the index.
+func (c *SemanticCache) Stats(ctx context.Context) (Stats, error) {
+	st, err := c.store.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	st.Backend = "semantic+" + st.Backend
+	st.Indexed = c.Len()
+	return st, nil
+}
+
+// GetSimilar embeds prompt and returns the value of the most similar live
+// entry in scope, if its similarity is at least the configured threshold.
+func (c *SemanticCache) GetSimilar(ctx context.Context, scope, prompt string) ([]byte, bool) {
+	vec, err := c.embed(ctx, prompt)
+	if err != nil {
+		slog.WarnContext(ctx, "semantic_cache_embed_error", slog.String("error", err.Error()))
+		return nil, false
+	}
+
+	now := time.Now()
+	bestKey, bestSim := "", -1.0
+
+	c.mu.Lock()
+	if len(c.pending) >= c.maxEntries {
+		clear(c.pending)
+	}
+	c.pending[pendingKey(scope, prompt)] = vec
+	for _, e := range c.entries {
+		if e.scope != scope || now.After(e.expiresAt) {
+			continue
+		}
+		if sim := dot(vec, e.vec); sim > bestSim {
+			bestKey, bestSim = e.key, sim
+		}
+	}
+	c.mu.Unlock()
+
+	if bestKey == "" || bestSim < c.threshold {
+		return nil, false
+	}
+	return c.store.Get(ctx, bestKey)
+}
+
+// SetSimilar stores value under key and indexes prompt in scope for ttl.
+func (c *SemanticCache) SetSimilar(
+	ctx context.Context,
+	scope, prompt, key string,
+	value []byte,
+	ttl time.Duration,
+) error {
+	if err := c.store.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	pk := pendingKey(scope, prompt)
+	c.mu.Lock()
+	vec, ok := c.pending[pk]
+	delete(c.pending, pk)
+	c.mu.Unlock()
+
+	if !ok {
+		var err error
+		if vec, err = c.embed(ctx, prompt); err != nil {
+			slog.WarnContext(ctx, "semantic_cache_embed_error", slog.String("error", err.Error()))
+			return nil // the exact entry is stored; only the index is missing
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries and make room before appending.
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if now.Before(e.expiresAt) {
+			kept = append(kept, e)
+		}
+	}
+	if over := len(kept) - c.maxEntries + 1; over > 0 {
+		kept = append(kept[:0], kept[over:]...)
+	}
+	c.entries = append(kept, semanticEntry{
+		scope:     scope,
+		key:       key,
+		vec:       vec,
+		expiresAt: now.Add(ttl),
+	})
+	return nil
+}
+
+// Len returns the number of indexed prompts (including expired entries not
+// yet evicted).
+func (c *SemanticCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// embed returns the L2-normalised embedding of text.
+func (c *SemanticCache) embed(ctx context.Context, text string) ([]float32, error) {
+	vec, err := c.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("semantic cache: zero-length embedding")
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float32, len(vec))
+	for i, v := range vec {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out, nil
+}
+
+func pendingKey(scope, prompt string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(scope + "\x00" + prompt))
+}
+
+// dot returns the dot product of a and b. Vectors of different dimensions
+// (e.g. after an embedding model change) never match.
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return -1
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubEmbedder returns fixed vectors per text and counts calls.
+type stubEmbedder struct {
+	vecs  map[string][]float32
+	calls int
+}
+
+func (e *stubEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.calls++
+	v, ok := e.vecs[text]
+	if !ok {
+		return nil, errors.New("unknown text")
+	}
+	return v, nil
+}
+
+func newTestSemanticCache(t *testing.T, emb Embedder, opts SemanticOptions) *SemanticCache {
+	t.Helper()
+	mc := NewMemoryCache(context.Background())
+	t.Cleanup(mc.Close)
+	return NewSemanticCache(mc, emb, opts)
+}
+
+func TestSemanticCache_SimilarPromptHits(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{
+		"what is the capital of france": {1, 0.1, 0},
+		"capital city of france?":       {1, 0.12, 0},
+		"how do I bake bread":           {0, 0, 1},
+	}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{Threshold: 0.99})
+	ctx := context.Background()
+
+	if _, ok := c.GetSimilar(ctx, "s", "what is the capital of france"); ok {
+		t.Fatal("expected miss on empty index")
+	}
+	if err := c.SetSimilar(ctx, "s", "what is the capital of france", "k1", []byte("paris"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if emb.calls != 1 {
+		t.Errorf("embedder called %d times, want 1 (miss vector should be reused)", emb.calls)
+	}
+
+	got, ok := c.GetSimilar(ctx, "s", "capital city of france?")
+	if !ok || string(got) != "paris" {
+		t.Fatalf("GetSimilar = %q, %v; want paris, true", got, ok)
+	}
+
+	if _, ok := c.GetSimilar(ctx, "s", "how do I bake bread"); ok {
+		t.Error("dissimilar prompt must not hit")
+	}
+}
+
+func TestSemanticCache_ScopeIsolation(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{"hello": {1, 0}}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{})
+	ctx := context.Background()
+
+	_ = c.SetSimilar(ctx, "gpt-4o", "hello", "k1", []byte("v"), time.Hour)
+
+	if _, ok := c.GetSimilar(ctx, "claude", "hello"); ok {
+		t.Error("entry must not match across scopes")
+	}
+}
+
+func TestSemanticCache_ExactGetStillWorks(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{"hello": {1, 0}}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{})
+	ctx := context.Background()
+
+	_ = c.SetSimilar(ctx, "s", "hello", "k1", []byte("v"), time.Hour)
+
+	got, ok := c.Get(ctx, "k1")
+	if !ok || string(got) != "v" {
+		t.Fatalf("Get = %q, %v; want v, true", got, ok)
+	}
+}
+
+func TestSemanticCache_MaxEntriesEvictsOldest(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{
+		"a": {1, 0, 0},
+		"b": {0, 1, 0},
+		"c": {0, 0, 1},
+	}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{MaxEntries: 2})
+	ctx := context.Background()
+
+	_ = c.SetSimilar(ctx, "s", "a", "ka", []byte("A"), time.Hour)
+	_ = c.SetSimilar(ctx, "s", "b", "kb", []byte("B"), time.Hour)
+	_ = c.SetSimilar(ctx, "s", "c", "kc", []byte("C"), time.Hour)
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+	if _, ok := c.GetSimilar(ctx, "s", "a"); ok {
+		t.Error("oldest entry should have been evicted from the index")
+	}
+	if got, ok := c.GetSimilar(ctx, "s", "c"); !ok || string(got) != "C" {
+		t.Errorf("GetSimilar(c) = %q, %v; want C, true", got, ok)
+	}
+}
+
+func TestSemanticCache_EmbedErrorIsMiss(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{})
+	ctx := context.Background()
+
+	if _, ok := c.GetSimilar(ctx, "s", "anything"); ok {
+		t.Error("embedding failure must degrade to a miss")
+	}
+	if err := c.SetSimilar(ctx, "s", "anything", "k", []byte("v"), time.Hour); err != nil {
+		t.Errorf("SetSimilar must not fail on embedding error, got %v", err)
+	}
+	if _, ok := c.Get(ctx, "k"); !ok {
+		t.Error("exact entry should still be stored when embedding fails")
+	}
+}
+
+func TestSemanticCache_DeleteDropsIndexEntry(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{"hello": {1, 0}}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{})
+	ctx := context.Background()
+
+	_ = c.SetSimilar(ctx, "s", "hello", "k1", []byte("v"), time.Hour)
+	if err := c.Delete(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after Delete, want 0", c.Len())
+	}
+}
+
+func TestSemanticCache_FlushAndStats(t *testing.T) {
+	emb := &stubEmbedder{vecs: map[string][]float32{"hello": {1, 0, 0}}}
+	c := newTestSemanticCache(t, emb, SemanticOptions{Threshold: 0.99})
+	ctx := context.Background()
+
+	_ = c.SetSimilar(ctx, "s", "hello", "k1", []byte("world"), time.Hour)
+	st, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{Backend: "semantic+memory", Entries: 1, MemoryBytes: int64(len("k1") + len("world")), Indexed: 1}
+	if st != want {
+		t.Errorf("Stats = %+v, want %+v", st, want)
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.GetSimilar(ctx, "s", "hello"); ok {
+		t.Error("expected a miss after Flush")
+	}
+	if st, _ := c.Stats(ctx); st.Entries != 0 || st.Indexed != 0 {
+		t.Errorf("Stats after Flush = %+v, want empty", st)
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TieredCache serves reads from an in-process MemoryCache and falls back to
+// a shared remote cache (Redis) on a miss, promoting the value into memory
+// so later reads of the same key on this replica skip the round trip.
+// Writes go to both tiers.
+//
+// A promoted entry keeps the remote entry's remaining TTL, so it never
+// outlives the shared copy. Delete and Flush reach the memory tier of this
+// replica only; other replicas keep serving their copy until it expires.
+type TieredCache struct {
+	memory *MemoryCache
+	remote Cache
+}
+
+// NewTieredCache returns a TieredCache in front of remote. Bound memory with
+// NewMemoryCacheWithOptions so it keeps only the most recently used keys.
+func NewTieredCache(memory *MemoryCache, remote Cache) *TieredCache {
+	return &TieredCache{memory: memory, remote: remote}
+}
+
+// Get returns the value for key from memory, or from the remote tier.
+func (c *TieredCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	data, _, ok := c.GetWithTTL(ctx, key)
+	return data, ok
+}
+
+// GetWithTTL is Get that also returns the entry's remaining TTL. A remote
+// hit is copied into memory for that remaining TTL; a remote entry with no
+// expiry gets the memory tier's default.
+func (c *TieredCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool) {
+	if data, ttl, ok := c.memory.GetWithTTL(ctx, key); ok {
+		return data, ttl, true
+	}
+	data, ttl, ok := c.remote.GetWithTTL(ctx, key)
+	if !ok {
+		return nil, 0, false
+	}
+	_ = c.memory.Set(ctx, key, data, ttl)
+	return data, ttl, true
+}
+
+// Set writes value to both tiers with the same TTL.
+func (c *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_ = c.memory.Set(ctx, key, value, ttl)
+	return c.remote.Set(ctx, key, value, ttl)
+}
+
+// Delete removes key from both tiers.
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	return errors.Join(c.memory.Delete(ctx, key), c.remote.Delete(ctx, key))
+}
+
+// Flush empties both tiers.
+func (c *TieredCache) Flush(ctx context.Context) error {
+	return errors.Join(c.memory.Flush(ctx), c.remote.Flush(ctx))
+}
+
+// Stats reports the remote entry count, which is authoritative, and the
+// bytes held by this replica's memory tier.
+func (c *TieredCache) Stats(ctx context.Context) (Stats, error) {
+	remote, err := c.remote.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	local, _ := c.memory.Stats(ctx)
+	return Stats{Backend: "tiered", Entries: remote.Entries, MemoryBytes: local.MemoryBytes}, nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTieredTestCache(t *testing.T, maxEntries int) (*TieredCache, *MemoryCache, *ExactCache) {
+	t.Helper()
+	remote, _ := newTestCache(t)
+	mem := NewMemoryCacheWithOptions(context.Background(), MemoryOptions{MaxEntries: maxEntries})
+	t.Cleanup(mem.Close)
+	return NewTieredCache(mem, remote), mem, remote
+}
+
+func TestTieredCache_PromotesRemoteHit(t *testing.T) {
+	ctx := context.Background()
+	c, mem, remote := newTieredTestCache(t, 0)
+
+	// Written by another replica: only the remote tier has it.
+	if err := remote.Set(ctx, "cache:k", []byte("v"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	data, ttl, ok := c.GetWithTTL(ctx, "cache:k")
+	if !ok || string(data) != "v" {
+		t.Fatalf("GetWithTTL = %q, %v; want v, true", data, ok)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl = %v, want the remote entry's remaining TTL", ttl)
+	}
+
+	memData, memTTL, ok := mem.GetWithTTL(ctx, "cache:k")
+	if !ok || string(memData) != "v" {
+		t.Fatalf("memory tier = %q, %v; want the promoted value", memData, ok)
+	}
+	if memTTL > ttl {
+		t.Errorf("promoted TTL = %v, outlives the remote TTL %v", memTTL, ttl)
+	}
+
+	// Served from memory from now on.
+	if err := remote.Delete(ctx, "cache:k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(ctx, "cache:k"); !ok {
+		t.Error("second Get missed; want a memory-tier hit")
+	}
+}
+
+func TestTieredCache_WritesThrough(t *testing.T) {
+	ctx := context.Background()
+	c, mem, remote := newTieredTestCache(t, 0)
+
+	if err := c.Set(ctx, "cache:k", []byte("v"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for name, tier := range map[string]Cache{"memory": mem, "remote": remote} {
+		data, ttl, ok := tier.GetWithTTL(ctx, "cache:k")
+		if !ok || string(data) != "v" {
+			t.Errorf("%s tier = %q, %v; want v", name, data, ok)
+		}
+		if ttl <= 0 || ttl > time.Minute {
+			t.Errorf("%s tier ttl = %v, want ≤ 1m", name, ttl)
+		}
+	}
+
+	if err := c.Delete(ctx, "cache:k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(ctx, "cache:k"); ok {
+		t.Error("Get after Delete hit")
+	}
+}
+
+func TestTieredCache_MemoryTierIsBounded(t *testing.T) {
+	ctx := context.Background()
+	c, mem, _ := newTieredTestCache(t, 3)
+
+	for i := range 10 {
+		if err := c.Set(ctx, "cache:"+strconv.Itoa(i), []byte("v"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := mem.Len(); n != 3 {
+		t.Errorf("memory tier holds %d entries, want 3", n)
+	}
+	if _, ok := mem.Get(ctx, "cache:9"); !ok {
+		t.Error("newest entry was evicted")
+	}
+	if _, ok := mem.Get(ctx, "cache:0"); ok {
+		t.Error("least recently used entry was kept")
+	}
+
+	// An evicted entry is still served, from the remote tier.
+	if _, ok := c.Get(ctx, "cache:0"); !ok {
+		t.Error("Get of an evicted key missed; want a remote hit")
+	}
+	if st, err := c.Stats(ctx); err != nil || st.Backend != "tiered" || st.Entries != 10 {
+		t.Errorf("Stats = %+v, %v; want tiered with 10 entries", st, err)
+	}
+}
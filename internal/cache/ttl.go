@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// TTLOverrides maps model names to cache TTLs that replace the global one.
+// Like ExclusionList it supports exact names and regular expressions; an
+// exact rule wins over any pattern, and when several patterns match the
+// shortest TTL wins, so the result never depends on configuration order.
+//
+// A nil *TTLOverrides is safe to call — TTL always reports no override.
+type TTLOverrides struct {
+	exact    map[string]time.Duration
+	patterns []ttlPattern
+}
+
+type ttlPattern struct {
+	re  *regexp.Regexp
+	ttl time.Duration
+}
+
+// NewTTLOverrides compiles exact model names and regex patterns, each mapped
+// to a TTL. Returns an error if a pattern fails to compile or a TTL is not
+// positive.
+func NewTTLOverrides(exact, patterns map[string]time.Duration) (*TTLOverrides, error) {
+	o := &TTLOverrides{exact: make(map[string]time.Duration, len(exact))}
+
+	for model, ttl := range exact {
+		if ttl <= 0 {
+			return nil, fmt.Errorf("cache ttl override: TTL for %q must be positive, got %s", model, ttl)
+		}
+		o.exact[model] = ttl
+	}
+
+	for p, ttl := range patterns {
+		if ttl <= 0 {
+			return nil, fmt.Errorf("cache ttl override: TTL for %q must be positive, got %s", p, ttl)
+		}
+		re, err := compileModelPattern("cache ttl override", p)
+		if err != nil {
+			return nil, err
+		}
+		o.patterns = append(o.patterns, ttlPattern{re: re, ttl: ttl})
+	}
+
+	return o, nil
+}
+
+// TTL returns the override for model, and false when none applies.
+func (o *TTLOverrides) TTL(model string) (time.Duration, bool) {
+	if o == nil {
+		return 0, false
+	}
+	if ttl, ok := o.exact[model]; ok {
+		return ttl, true
+	}
+	var best time.Duration
+	for _, p := range o.patterns {
+		if p.re.MatchString(model) && (best == 0 || p.ttl < best) {
+			best = p.ttl
+		}
+	}
+	return best, best > 0
+}
+
+// Len returns the total number of override rules configured.
+func (o *TTLOverrides) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.exact) + len(o.patterns)
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLOverrides(t *testing.T) {
+	o, err := NewTTLOverrides(
+		map[string]time.Duration{"gpt-4o-mini": 24 * time.Hour},
+		map[string]time.Duration{
+			"^gpt-4o":    6 * time.Hour,
+			"-preview$":  time.Minute,
+			"^claude-3-": 12 * time.Hour,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model string
+		want  time.Duration
+		ok    bool
+	}{
+		{"gpt-4o-mini", 24 * time.Hour, true}, // exact beats the ^gpt-4o pattern
+		{"gpt-4o", 6 * time.Hour, true},       // pattern
+		{"gpt-4o-preview", time.Minute, true}, // shortest of two matching patterns
+		{"claude-3-haiku", 12 * time.Hour, true},
+		{"mistral-large", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := o.TTL(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("TTL(%q) = %v, %v; want %v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+	if o.Len() != 4 {
+		t.Errorf("Len = %d, want 4", o.Len())
+	}
+}
+
+func TestTTLOverrides_Invalid(t *testing.T) {
+	if _, err := NewTTLOverrides(nil, map[string]time.Duration{"[bad": time.Hour}); err == nil {
+		t.Error("invalid pattern should fail")
+	}
+	if _, err := NewTTLOverrides(map[string]time.Duration{"gpt-4o": 0}, nil); err == nil {
+		t.Error("non-positive TTL should fail")
+	}
+}
+
+func TestTTLOverrides_Nil(t *testing.T) {
+	var o *TTLOverrides
+	if _, ok := o.TTL("gpt-4o"); ok || o.Len() != 0 {
+		t.Error("nil overrides should never apply")
+	}
+}
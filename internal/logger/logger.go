@@ -1,9 +1,10 @@
 // Package logger implements a non-blocking, batched request logger.
 //
 // Log entries are written to an internal buffered channel and flushed in
-// batches by a background goroutine — so logging never blocks the proxy hot
-// path. If the channel fills up (> 10 000 entries), new entries are dropped
-// and counted in DroppedLogs.
+// batches to a Sink by a background goroutine — so logging never blocks the
+// proxy hot path. If the channel fills up (> 10 000 entries by default), new
+// entries are dropped and counted in DroppedLogs. Batches go to slog (New)
+// or to any Sink, such as ClickHouseSink (NewWithSink).
 package logger
 
 import (
@@ -22,18 +23,49 @@ const (
 	channelBuffer = 10_000
 	batchSize     = 100
 	flushInterval = time.Second
+	flushTimeout  = 10 * time.Second
 )
 
+// RequestLog is the metadata of one proxied request. It never carries prompt
+// or completion content.
 type RequestLog struct {
-	ID           uuid.UUID
-	Provider     string
-	Model        string
-	InputTokens  uint32
-	OutputTokens uint32
-	LatencyMs    uint16
-	Status       uint16
-	Cached       bool
-	CreatedAt    time.Time
+	ID        uuid.UUID
+	Caller    string // authenticated gateway caller; empty without gateway auth
+	Workspace string // tenant from the virtual key or X-Workspace-ID; may be empty
+	Provider  string
+	// PrimaryProvider is the provider the model routes to; Provider differs
+	// from it when the request failed over.
+	PrimaryProvider string
+	Attempts        uint8 // upstream calls made, hedges included; 0 for cache hits
+	FailedOver      bool
+	Model           string
+	InputTokens     uint32
+	OutputTokens    uint32
+	LatencyMs       uint32
+	Status          uint16
+	Cached          bool
+	// CostUSD is the request's cost under the configured pricing; 0 for
+	// cache hits and unpriced models.
+	CostUSD   float64
+	CreatedAt time.Time
+}
+
+// Sink persists batches of request logs. Write is only called from the
+// Logger's background goroutine, one batch at a time, and must not retain
+// batch after returning.
+type Sink interface {
+	Write(ctx context.Context, batch []RequestLog) error
+}
+
+// Options tunes a Logger's batching. Zero values use the defaults.
+type Options struct {
+	// BatchSize is the number of entries that triggers a flush. Default: 100.
+	BatchSize int
+	// FlushInterval flushes a partial batch after this long. Default: 1s.
+	FlushInterval time.Duration
+	// BufferSize is the number of entries queued before Log starts dropping
+	// them. Default: 10 000.
+	BufferSize int
 }
 
 type Logger struct {
@@ -43,26 +75,58 @@ type Logger struct {
 	wg        sync.WaitGroup
 
 	droppedLogs int64
+	failedLogs  int64
+
+	batchSize     int
+	flushInterval time.Duration
 
 	baseCtx context.Context
+	sink    Sink
 	log     *slog.Logger
 }
 
+// New creates a Logger that writes each entry to slogger (JSON on stdout
+// when nil).
 func New(ctx context.Context, slogger *slog.Logger) (*Logger, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("logger: context must not be nil")
-	}
 	if slogger == nil {
 		slogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelInfo,
 		}))
 	}
+	return NewWithSink(ctx, NewSlogSink(slogger), Options{}, slogger)
+}
+
+// NewWithSink creates a Logger that flushes batches to sink. Sink errors are
+// reported on errLog (slog.Default when nil) and the batch is counted in
+// FailedLogs.
+func NewWithSink(ctx context.Context, sink Sink, opts Options, errLog *slog.Logger) (*Logger, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("logger: context must not be nil")
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("logger: sink must not be nil")
+	}
+	if errLog == nil {
+		errLog = slog.Default()
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = batchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = flushInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = channelBuffer
+	}
 
 	l := &Logger{
-		ch:      make(chan RequestLog, channelBuffer),
-		done:    make(chan struct{}),
-		baseCtx: ctx,
-		log:     slogger,
+		ch:            make(chan RequestLog, opts.BufferSize),
+		done:          make(chan struct{}),
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		baseCtx:       ctx,
+		sink:          sink,
+		log:           errLog,
 	}
 
 	l.wg.Add(1)
@@ -83,6 +147,13 @@ func (l *Logger) DroppedLogs() int64 {
 	return atomic.LoadInt64(&l.droppedLogs)
 }
 
+// FailedLogs returns the number of entries lost because the sink failed to
+// write their batch.
+func (l *Logger) FailedLogs() int64 {
+	return atomic.LoadInt64(&l.failedLogs)
+}
+
+// Close flushes queued entries to the sink and stops the logger.
 func (l *Logger) Close() error {
 	l.closeOnce.Do(func() {
 		close(l.done)
@@ -94,26 +165,24 @@ func (l *Logger) Close() error {
 func (l *Logger) run() {
 	defer l.wg.Done()
 
-	ticker := time.NewTicker(flushInterval)
+	ticker := time.NewTicker(l.flushInterval)
 	defer ticker.Stop()
 
-	batch := make([]RequestLog, 0, batchSize)
+	batch := make([]RequestLog, 0, l.batchSize)
 
-	flush := func(ctx context.Context) {
+	flush := func() {
 		if len(batch) == 0 {
 			return
 		}
-		for _, e := range batch {
-			l.log.InfoContext(ctx, "request",
-				slog.String("id", e.ID.String()),
-				slog.String("provider", e.Provider),
-				slog.String("model", e.Model),
-				slog.Uint64("input_tokens", uint64(e.InputTokens)),
-				slog.Uint64("output_tokens", uint64(e.OutputTokens)),
-				slog.Uint64("latency_ms", uint64(e.LatencyMs)),
-				slog.Uint64("status", uint64(e.Status)),
-				slog.Bool("cached", e.Cached),
-				slog.Time("created_at", normalizeTime(e.CreatedAt)),
+		// The base context is usually cancelled by the time Close drains the
+		// final batch, so writes get their own deadline instead.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(l.baseCtx), flushTimeout)
+		defer cancel()
+		if err := l.sink.Write(ctx, batch); err != nil {
+			atomic.AddInt64(&l.failedLogs, int64(len(batch)))
+			l.log.WarnContext(ctx, "request_log_flush_failed",
+				slog.Int("entries", len(batch)),
+				slog.String("error", err.Error()),
 			)
 		}
 		batch = batch[:0]
@@ -123,23 +192,23 @@ func (l *Logger) run() {
 		select {
 		case entry := <-l.ch:
 			batch = append(batch, entry)
-			if len(batch) >= batchSize {
-				flush(l.baseCtx)
+			if len(batch) >= l.batchSize {
+				flush()
 			}
 
 		case <-ticker.C:
-			flush(l.baseCtx)
+			flush()
 
 		case <-l.done:
 			for {
 				select {
 				case entry := <-l.ch:
 					batch = append(batch, entry)
-					if len(batch) >= batchSize {
-						flush(l.baseCtx)
+					if len(batch) >= l.batchSize {
+						flush()
 					}
 				default:
-					flush(l.baseCtx)
+					flush()
 					return
 				}
 			}
@@ -147,6 +216,38 @@ func (l *Logger) run() {
 	}
 }
 
+// slogSink writes each entry as a structured log line.
+type slogSink struct {
+	log *slog.Logger
+}
+
+// NewSlogSink returns a Sink that writes each entry to log as a "request"
+// record.
+func NewSlogSink(log *slog.Logger) Sink { return slogSink{log: log} }
+
+func (s slogSink) Write(ctx context.Context, batch []RequestLog) error {
+	for _, e := range batch {
+		s.log.InfoContext(ctx, "request",
+			slog.String("id", e.ID.String()),
+			slog.String("caller", e.Caller),
+			slog.String("workspace", e.Workspace),
+			slog.String("provider", e.Provider),
+			slog.String("primary_provider", e.PrimaryProvider),
+			slog.Uint64("attempts", uint64(e.Attempts)),
+			slog.Bool("failed_over", e.FailedOver),
+			slog.String("model", e.Model),
+			slog.Uint64("input_tokens", uint64(e.InputTokens)),
+			slog.Uint64("output_tokens", uint64(e.OutputTokens)),
+			slog.Uint64("latency_ms", uint64(e.LatencyMs)),
+			slog.Uint64("status", uint64(e.Status)),
+			slog.Bool("cached", e.Cached),
+			slog.Float64("cost_usd", e.CostUSD),
+			slog.Time("created_at", normalizeTime(e.CreatedAt)),
+		)
+	}
+	return nil
+}
+
 func normalizeTime(t time.Time) time.Time {
 	if t.IsZero() {
 		return time.Now().UTC()
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeSink records the size of every batch it is given. When block is set,
+// Write waits on it first.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches []int
+	entries []RequestLog
+	block   chan struct{}
+}
+
+func (f *fakeSink) Write(_ context.Context, batch []RequestLog) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, len(batch))
+	f.entries = append(f.entries, batch...)
+	return nil
+}
+
+func (f *fakeSink) written() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.entries)
+}
+
+func TestLogger_BatchesAndFlushesOnClose(t *testing.T) {
+	sink := &fakeSink{}
+	l, err := NewWithSink(context.Background(), sink, Options{BatchSize: 3, FlushInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 7 {
+		l.Log(RequestLog{Model: "m", InputTokens: uint32(i)})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sink.batches; len(got) != 3 || got[0] != 3 || got[1] != 3 || got[2] != 1 {
+		t.Errorf("batches = %v, want [3 3 1]", got)
+	}
+	for i, e := range sink.entries {
+		if e.InputTokens != uint32(i) {
+			t.Fatalf("entry %d out of order: %+v", i, e)
+		}
+	}
+}
+
+func TestLogger_FlushesOnInterval(t *testing.T) {
+	sink := &fakeSink{}
+	l, err := NewWithSink(context.Background(), sink, Options{BatchSize: 100, FlushInterval: 10 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.Log(RequestLog{Model: "m"})
+	deadline := time.Now().Add(time.Second)
+	for sink.written() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("partial batch was not flushed on the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLogger_DropsWhenFull(t *testing.T) {
+	sink := &fakeSink{block: make(chan struct{})}
+	l, err := NewWithSink(context.Background(), sink, Options{BatchSize: 1, BufferSize: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first entry is taken by the stuck flush; two more fill the buffer.
+	for range 10 {
+		l.Log(RequestLog{Model: "m"})
+	}
+	if l.DroppedLogs() == 0 {
+		t.Error("Log should drop entries instead of blocking when the buffer is full")
+	}
+	close(sink.block)
+	_ = l.Close()
+	if got := int64(sink.written()) + l.DroppedLogs(); got != 10 {
+		t.Errorf("written + dropped = %d, want 10", got)
+	}
+}
+
+func TestClickHouseSink_Write(t *testing.T) {
+	var (
+		query, user, key string
+		rows             []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		user, key = r.Header.Get("X-ClickHouse-User"), r.Header.Get("X-ClickHouse-Key")
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var row map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+				t.Error(err)
+			}
+			rows = append(rows, row)
+		}
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://gw:secret@", 1) + "/analytics"
+	sink, err := NewClickHouseSink(dsn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := uuid.New()
+	err = sink.Write(context.Background(), []RequestLog{
+		{ID: id, Provider: "anthropic", PrimaryProvider: "openai", Attempts: 2, FailedOver: true,
+			Model: "gpt-4o", Status: 200, CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC)},
+		{ID: uuid.New(), Provider: "openai", Model: "gpt-4o", Cached: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(query, "INSERT INTO analytics.request_logs (") || !strings.HasSuffix(query, "FORMAT JSONEachRow") {
+		t.Errorf("query = %q", query)
+	}
+	if user != "gw" || key != "secret" {
+		t.Errorf("credentials = %q/%q", user, key)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("inserted %d rows, want 2", len(rows))
+	}
+	r := rows[0]
+	if r["id"] != id.String() || r["failed_over"] != true || r["attempts"] != float64(2) ||
+		r["created_at"] != "2026-01-02 03:04:05.006" {
+		t.Errorf("row = %v", r)
+	}
+}
+
+func TestClickHouseSink_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. Table does not exist", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	sink, err := NewClickHouseSink(srv.URL, "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), []RequestLog{{}}); err == nil || !strings.Contains(err.Error(), "Table does not exist") {
+		t.Errorf("err = %v, want the ClickHouse error", err)
+	}
+
+	for _, dsn := range []string{"clickhouse://host:9000/db", "http://host:8123/bad-db", "::"} {
+		if _, err := NewClickHouseSink(dsn, ""); err == nil {
+			t.Errorf("NewClickHouseSink(%q) should fail", dsn)
+		}
+	}
+	if _, err := NewClickHouseSink("http://host:8123", "logs; DROP TABLE x"); err == nil {
+		t.Error("table names must be plain identifiers")
+	}
+}
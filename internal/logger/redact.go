@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactedText replaces text removed by a Redactor.
+const RedactedText = "[REDACTED]"
+
+// Redactor rewrites free text before it is logged. Implementations may
+// suppress it entirely (RedactAll) or mask only sensitive parts
+// (PatternRedactor).
+type Redactor interface {
+	Redact(s string) string
+}
+
+// RedactAll replaces any non-empty text with RedactedText.
+type RedactAll struct{}
+
+// Redact implements Redactor.
+func (RedactAll) Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	return RedactedText
+}
+
+// redactPatterns are the built-in patterns NewRedactor accepts by name.
+var redactPatterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"card":  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+}
+
+// PatternRedactor masks every match of its patterns and leaves the rest of
+// the text intact.
+type PatternRedactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewPatternRedactor builds a PatternRedactor from regular expressions.
+func NewPatternRedactor(patterns ...*regexp.Regexp) *PatternRedactor {
+	return &PatternRedactor{patterns: patterns}
+}
+
+// Redact implements Redactor.
+func (p *PatternRedactor) Redact(s string) string {
+	for _, re := range p.patterns {
+		s = re.ReplaceAllString(s, RedactedText)
+	}
+	return s
+}
+
+// NewRedactor returns the Redactor for the LOG_REDACT_CONTENT and
+// LOG_REDACT_PATTERNS settings: nil when content is false, a PatternRedactor
+// over the named built-in patterns when any are given, RedactAll otherwise.
+func NewRedactor(content bool, patterns []string) (Redactor, error) {
+	if !content {
+		return nil, nil
+	}
+	if len(patterns) == 0 {
+		return RedactAll{}, nil
+	}
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, name := range patterns {
+		re, ok := redactPatterns[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("logger: unknown redaction pattern %q", name)
+		}
+		res = append(res, re)
+	}
+	return NewPatternRedactor(res...), nil
+}
+
+// contentKeys are the attribute keys whose values may carry prompt or
+// completion text. Provider errors are included because upstream APIs often
+// echo the offending input back in their messages.
+var contentKeys = map[string]bool{
+	"content":    true,
+	"prompt":     true,
+	"messages":   true,
+	"completion": true,
+	"input":      true,
+	"output":     true,
+	"text":       true,
+	"body":       true,
+	"error":      true,
+}
+
+// redactingHandler passes the values of content attributes through a
+// Redactor before handing records to the next handler.
+type redactingHandler struct {
+	next slog.Handler
+	r    Redactor
+}
+
+// NewRedactingHandler wraps next so that the values of content attributes
+// ("content", "prompt", "messages", "error", ...) never reach it unredacted,
+// including inside groups. Metadata such as model, provider, token counts and
+// status is left alone.
+func NewRedactingHandler(next slog.Handler, r Redactor) slog.Handler {
+	return &redactingHandler{next: next, r: r}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), r: h.r}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), r: h.r}
+}
+
+func (h *redactingHandler) redact(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch {
+	case v.Kind() == slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case contentKeys[strings.ToLower(a.Key)]:
+		return slog.String(a.Key, h.r.Redact(v.String()))
+	default:
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+}
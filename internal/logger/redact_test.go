@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil), RedactAll{})).
+		With(slog.String("prompt", "secret prompt"))
+
+	log.Info("provider_error",
+		slog.String("model", "gpt-4o"),
+		slog.Int("status", 400),
+		slog.String("error", "invalid input: secret prompt"),
+		slog.Group("request", slog.String("content", "secret prompt"), slog.String("provider", "openai")),
+	)
+
+	out := buf.String()
+	if strings.Contains(out, "secret") {
+		t.Errorf("content leaked: %s", out)
+	}
+	for _, want := range []string{`"model":"gpt-4o"`, `"status":400`, `"provider":"openai"`, `"error":"[REDACTED]"`, `"prompt":"[REDACTED]"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s: %s", want, out)
+		}
+	}
+}
+
+func TestNewRedactor(t *testing.T) {
+	if r, err := NewRedactor(false, []string{"email"}); r != nil || err != nil {
+		t.Errorf("redaction off: got %v, %v", r, err)
+	}
+	if r, _ := NewRedactor(true, nil); r.Redact("anything") != RedactedText || r.Redact("") != "" {
+		t.Error("without patterns all content should be suppressed")
+	}
+
+	r, err := NewRedactor(true, []string{"email", "CARD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Redact("mail jane.doe@example.com, card 4111-1111-1111-1111, order 12345")
+	want := "mail [REDACTED], card [REDACTED], order 12345"
+	if got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+
+	if _, err := NewRedactor(true, []string{"ssn"}); err == nil {
+		t.Error("unknown pattern should fail")
+	}
+}
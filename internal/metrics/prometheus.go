@@ -23,6 +23,9 @@ type Registry struct {
 	// gateway_inflight_requests
 	inFlight prometheus.Gauge
 
+	// gateway_queue_depth{scope,priority}
+	queueDepth *prometheus.GaugeVec
+
 	// gateway_http_requests_total{route,status}
 	httpRequestsTotal *prometheus.CounterVec
 
@@ -60,10 +63,11 @@ type Registry struct {
 	// provider_errors_total{provider, error_type}
 	providerErrors *prometheus.CounterVec
 
-	// circuit_breaker_state{provider} — 0=closed, 1=open, 2=half-open
+	// circuit_breaker_state{provider,model} — 0=closed, 1=open, 2=half-open;
+	// model is empty unless breakers are kept per model
 	circuitBreakerState *prometheus.GaugeVec
 
-	// gateway_circuit_breaker_transitions_total{provider,to_state}
+	// gateway_circuit_breaker_transitions_total{provider,model,to_state}
 	cbTransitions *prometheus.CounterVec
 
 	// gateway_circuit_breaker_rejections_total{provider,state}
@@ -75,15 +79,28 @@ type Registry struct {
 	// gateway_failover_success_total{primary,to}
 	failoverSuccess *prometheus.CounterVec
 
-	// gateway_failover_exhausted_total{primary}
+	// gateway_failover_exhausted_total{primary,reason} — reason is "attempts"
+	// or "circuit_open" when every breaker was open
 	failoverExhausted *prometheus.CounterVec
 
+	// gateway_hedged_requests_total{from,to,winner}
+	hedgedRequests *prometheus.CounterVec
+
 	// gateway_ratelimit_total{result}
 	rateLimitTotal *prometheus.CounterVec
 
+	// gateway_tpm_total{result}
+	tpmTotal *prometheus.CounterVec
+
+	// gateway_tpm_tokens_total{kind}
+	tpmTokensTotal *prometheus.CounterVec
+
 	// gateway_tokens_total{provider,route,direction,cache}
 	tokensTotal *prometheus.CounterVec
 
+	// gateway_cost_usd_total{provider,model}
+	costTotal *prometheus.CounterVec
+
 	// gateway_provider_health{provider}
 	providerHealth *prometheus.GaugeVec
 
@@ -112,6 +129,14 @@ func New() *Registry {
 			Help: "Current number of in-flight HTTP requests handled by the gateway",
 		}),
 
+		queueDepth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_queue_depth",
+				Help: "Requests waiting for a concurrency slot (scope is \"global\" or a provider name)",
+			},
+			[]string{"scope", "priority"},
+		),
+
 		httpRequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gateway_http_requests_total",
@@ -220,7 +245,7 @@ func New() *Registry {
 				Name: "circuit_breaker_state",
 				Help: "Circuit breaker state (0=closed,1=open,2=half-open)",
 			},
-			[]string{"provider"},
+			[]string{"provider", "model"},
 		),
 
 		cbTransitions: prometheus.NewCounterVec(
@@ -228,7 +253,7 @@ func New() *Registry {
 				Name: "gateway_circuit_breaker_transitions_total",
 				Help: "Circuit breaker transitions to a new state",
 			},
-			[]string{"provider", "to_state"},
+			[]string{"provider", "model", "to_state"},
 		),
 
 		cbRejections: prometheus.NewCounterVec(
@@ -260,7 +285,15 @@ func New() *Registry {
 				Name: "gateway_failover_exhausted_total",
 				Help: "Requests that exhausted failover attempts without success",
 			},
-			[]string{"primary"},
+			[]string{"primary", "reason"},
+		),
+
+		hedgedRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_hedged_requests_total",
+				Help: "Hedged requests: a slow attempt raced against a second provider (winner is \"none\" if both failed)",
+			},
+			[]string{"from", "to", "winner"},
 		),
 
 		rateLimitTotal: prometheus.NewCounterVec(
@@ -271,6 +304,22 @@ func New() *Registry {
 			[]string{"result"},
 		),
 
+		tpmTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_tpm_total",
+				Help: "Tokens-per-minute limit decisions",
+			},
+			[]string{"result"},
+		),
+
+		tpmTokensTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_tpm_tokens_total",
+				Help: "Tokens accounted by the TPM limiter (estimated on entry, actual after the response)",
+			},
+			[]string{"kind"},
+		),
+
 		tokensTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gateway_tokens_total",
@@ -279,6 +328,14 @@ func New() *Registry {
 			[]string{"provider", "route", "direction", "cache"},
 		),
 
+		costTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_cost_usd_total",
+				Help: "Request cost in USD under the configured pricing table",
+			},
+			[]string{"provider", "model"},
+		),
+
 		providerHealth: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "gateway_provider_health",
@@ -298,6 +355,7 @@ func New() *Registry {
 
 	reg.MustRegister(
 		r.inFlight,
+		r.queueDepth,
 		r.httpRequestsTotal,
 		r.httpDuration,
 		r.httpReqSize,
@@ -317,8 +375,12 @@ func New() *Registry {
 		r.failoverEvents,
 		r.failoverSuccess,
 		r.failoverExhausted,
+		r.hedgedRequests,
 		r.rateLimitTotal,
+		r.tpmTotal,
+		r.tpmTokensTotal,
 		r.tokensTotal,
+		r.costTotal,
 		r.providerHealth,
 		r.buildInfo,
 	)
@@ -337,6 +399,16 @@ func (r *Registry) RecordRequest(provider string, statusCode int, latencyMs int6
 func (r *Registry) IncInFlight() { r.inFlight.Inc() }
 func (r *Registry) DecInFlight() { r.inFlight.Dec() }
 
+// IncQueueDepth and DecQueueDepth track requests waiting on a concurrency
+// limiter; scope is "global" or the provider name, priority is the request's
+// tier ("high", "normal" or "low").
+func (r *Registry) IncQueueDepth(scope, priority string) {
+	r.queueDepth.WithLabelValues(scope, priority).Inc()
+}
+func (r *Registry) DecQueueDepth(scope, priority string) {
+	r.queueDepth.WithLabelValues(scope, priority).Dec()
+}
+
 // ObserveHTTP records end-to-end HTTP metrics.
 func (r *Registry) ObserveHTTP(route string, statusCode int, dur time.Duration, reqBytes, respBytes int) {
 	status := strconv.Itoa(statusCode)
@@ -369,14 +441,29 @@ func (r *Registry) RecordFailoverSuccess(primary, to string) {
 	r.failoverSuccess.WithLabelValues(primary, to).Inc()
 }
 
-func (r *Registry) RecordFailoverExhausted(primary string) {
-	r.failoverExhausted.WithLabelValues(primary).Inc()
+func (r *Registry) RecordFailoverExhausted(primary, reason string) {
+	r.failoverExhausted.WithLabelValues(primary, reason).Inc()
+}
+
+func (r *Registry) RecordHedge(from, to, winner string) {
+	r.hedgedRequests.WithLabelValues(from, to, winner).Inc()
 }
 
 func (r *Registry) RecordRateLimit(result string) {
 	r.rateLimitTotal.WithLabelValues(result).Inc()
 }
 
+func (r *Registry) RecordTPM(result string) {
+	r.tpmTotal.WithLabelValues(result).Inc()
+}
+
+func (r *Registry) AddTPMTokens(kind string, n int) {
+	if n <= 0 {
+		return
+	}
+	r.tpmTokensTotal.WithLabelValues(kind).Add(float64(n))
+}
+
 func (r *Registry) CacheGetHit() {
 	r.cacheHits.Inc()
 	r.cacheOps.WithLabelValues("get", "hit").Inc()
@@ -399,6 +486,25 @@ func (r *Registry) CacheSetError() {
 	r.cacheOps.WithLabelValues("set", "error").Inc()
 }
 
+// ObserveCacheBytesSaved exports total, the running count of bytes saved by
+// cache compression, as cache_bytes_saved_total. Call it at most once.
+func (r *Registry) ObserveCacheBytesSaved(total func() int64) {
+	r.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_bytes_saved_total",
+		Help: "Total bytes saved by compressing cached values",
+	}, func() float64 { return float64(total()) }))
+}
+
+// ObserveCacheEvictions exports total, the running count of entries the
+// in-process cache evicted to stay within its size bounds, as
+// cache_evictions_total. Call it at most once.
+func (r *Registry) ObserveCacheEvictions(total func() int64) {
+	r.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_evictions_total",
+		Help: "Total entries evicted from the in-process cache to stay within its size bounds",
+	}, func() float64 { return float64(total()) }))
+}
+
 func (r *Registry) AddTokens(provider, route string, inputTokens, outputTokens int, cached bool) {
 	cache := "miss"
 	if cached {
@@ -415,6 +521,11 @@ func (r *Registry) AddTokens(provider, route string, inputTokens, outputTokens i
 	}
 }
 
+// AddCost adds usd to the spend of provider and model.
+func (r *Registry) AddCost(provider, model string, usd float64) {
+	r.costTotal.WithLabelValues(provider, model).Add(usd)
+}
+
 func (r *Registry) SetProviderHealth(provider string, ok bool) {
 	if ok {
 		r.providerHealth.WithLabelValues(provider).Set(1)
@@ -433,16 +544,18 @@ func (r *Registry) RecordError(provider, errType string) {
 }
 
 // SetCircuitBreaker sets the circuit breaker state gauge and increments a
-// transition counter when the state changes.
-func (r *Registry) SetCircuitBreaker(provider string, state int64) {
-	r.circuitBreakerState.WithLabelValues(provider).Set(float64(state))
+// transition counter when the state changes. model is empty for a
+// provider-level breaker.
+func (r *Registry) SetCircuitBreaker(provider, model string, state int64) {
+	r.circuitBreakerState.WithLabelValues(provider, model).Set(float64(state))
 
+	key := provider + "/" + model
 	r.cbMu.Lock()
-	prev, ok := r.lastCBState[provider]
+	prev, ok := r.lastCBState[key]
 	if !ok || prev != float64(state) {
-		r.lastCBState[provider] = float64(state)
+		r.lastCBState[key] = float64(state)
 		toState := strconv.FormatInt(state, 10)
-		r.cbTransitions.WithLabelValues(provider, toState).Inc()
+		r.cbTransitions.WithLabelValues(provider, model, toState).Inc()
 	}
 	r.cbMu.Unlock()
 }
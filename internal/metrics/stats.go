@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Stats is a point-in-time summary of the gateway counters, for consumers
+// that do not scrape Prometheus.
+type Stats struct {
+	// RequestsByProvider counts proxy requests per provider, all statuses.
+	RequestsByProvider map[string]uint64 `json:"requests_by_provider"`
+	// ErrorsByProvider counts provider errors per provider, all types.
+	ErrorsByProvider map[string]uint64 `json:"errors_by_provider"`
+	Cache            CacheStats        `json:"cache"`
+	// CircuitBreakers maps providers, or "provider/model" for per-model
+	// breakers, to "closed", "open" or "half-open".
+	CircuitBreakers map[string]string `json:"circuit_breakers"`
+	InFlight        int64             `json:"in_flight"`
+}
+
+// CacheStats is the cache section of Stats.
+type CacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// circuitStateNames maps circuit_breaker_state values to their names.
+var circuitStateNames = map[float64]string{0: "closed", 1: "open", 2: "half-open"}
+
+// Stats gathers the current counters from the underlying registry.
+func (r *Registry) Stats() (Stats, error) {
+	families, err := r.reg.Gather()
+	if err != nil {
+		return Stats{}, err
+	}
+	s := Stats{
+		RequestsByProvider: make(map[string]uint64),
+		ErrorsByProvider:   make(map[string]uint64),
+		CircuitBreakers:    make(map[string]string),
+	}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			case "gateway_requests_total":
+				s.RequestsByProvider[label(m, "provider")] += uint64(m.GetCounter().GetValue())
+			case "provider_errors_total":
+				s.ErrorsByProvider[label(m, "provider")] += uint64(m.GetCounter().GetValue())
+			case "cache_hits_total":
+				s.Cache.Hits = uint64(m.GetCounter().GetValue())
+			case "cache_misses_total":
+				s.Cache.Misses = uint64(m.GetCounter().GetValue())
+			case "circuit_breaker_state":
+				key := label(m, "provider")
+				if model := label(m, "model"); model != "" {
+					key += "/" + model
+				}
+				s.CircuitBreakers[key] = circuitStateNames[m.GetGauge().GetValue()]
+			case "gateway_inflight_requests":
+				s.InFlight = int64(m.GetGauge().GetValue())
+			}
+		}
+	}
+	if total := s.Cache.Hits + s.Cache.Misses; total > 0 {
+		s.Cache.HitRate = float64(s.Cache.Hits) / float64(total)
+	}
+	return s, nil
+}
+
+// label returns the value of m's label name, or "".
+func label(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package anthropic
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -44,7 +45,7 @@ func New(apiKey string, opts ...Option) *Provider {
 		o(p)
 	}
 
-	httpClient := &http.Client{Timeout: providers.ProviderTimeout}
+	httpClient := providers.NewHTTPClient()
 
 	p.client = anthropic.NewClient(
 		option.WithAPIKey(p.apiKey),
@@ -69,7 +70,29 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
-	params := p.buildParams(req)
+	// The Messages API has no structured-output mode; refuse rather than
+	// return free-form text to a client that asked for JSON.
+	if providers.WantsStructuredOutput(req.ResponseFormat) {
+		return nil, &providers.Error{
+			Provider:   "anthropic",
+			StatusCode: 400,
+			Message:    "response_format json_object and json_schema are not supported",
+			Type:       "invalid_request_error",
+		}
+	}
+	if providers.WantsLogProbs(req) {
+		return nil, &providers.Error{
+			Provider:   "anthropic",
+			StatusCode: 400,
+			Message:    "logprobs are not supported",
+			Type:       "invalid_request_error",
+		}
+	}
+
+	params, err := p.buildParams(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
 
 	opts, err := p.requestOptions(req.APIKey)
 	if err != nil {
@@ -82,19 +105,28 @@ func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*p
 	return p.handleResponse(ctx, params, opts...)
 }
 
-func (p *Provider) buildParams(req *providers.ProxyRequest) anthropic.MessageNewParams {
+func (p *Provider) buildParams(req *providers.ProxyRequest) (anthropic.MessageNewParams, error) {
 	var systemPrompt string
 	msgs := make([]anthropic.MessageParam, 0, len(req.Messages))
 
-	for _, m := range req.Messages {
+	for i, m := range req.Messages {
 		switch strings.ToLower(m.Role) {
 		case "system", "developer":
 			if systemPrompt != "" {
 				systemPrompt += "\n"
 			}
 			systemPrompt += m.Content
+		case "tool":
+			// Tool results travel in a user turn; consecutive results share one.
+			block := anthropic.NewToolResultBlock(m.ToolCallID, m.Content, false)
+			if i > 0 && strings.EqualFold(req.Messages[i-1].Role, "tool") {
+				last := &msgs[len(msgs)-1]
+				last.Content = append(last.Content, block)
+				continue
+			}
+			msgs = append(msgs, anthropic.NewUserMessage(block))
 		default:
-			msgs = append(msgs, toSDKMessage(m.Role, m.Content))
+			msgs = append(msgs, toSDKMessage(m))
 		}
 	}
 
@@ -109,6 +141,15 @@ func (p *Provider) buildParams(req *providers.ProxyRequest) anthropic.MessageNew
 		Messages:  msgs,
 	}
 
+	if budget := providers.ThinkingBudgetFor(req); budget > 0 {
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+		// budget_tokens must stay below max_tokens; without an explicit
+		// max_tokens, leave the usual room for the answer on top of it.
+		if req.MaxTokens == 0 {
+			params.MaxTokens = int64(budget + defaultMaxTokens)
+		}
+	}
+
 	if systemPrompt != "" {
 		params.System = []anthropic.TextBlockParam{
 			{Text: systemPrompt},
@@ -121,57 +162,178 @@ func (p *Provider) buildParams(req *providers.ProxyRequest) anthropic.MessageNew
 		params.Temperature = anthropic.Float(req.Temperature)
 	}
 
-	return params
+	if req.TopP != nil {
+		params.TopP = anthropic.Float(*req.TopP)
+	}
+
+	if len(req.Stop) > 0 {
+		params.StopSequences = req.Stop
+	}
+
+	tools, err := providers.ParseFunctionTools(req.Tools)
+	if err != nil {
+		return params, err
+	}
+	for _, t := range tools {
+		tool, err := toSDKTool(t)
+		if err != nil {
+			return params, err
+		}
+		params.Tools = append(params.Tools, anthropic.ToolUnionParam{OfTool: tool})
+	}
+
+	if len(req.ToolChoice) > 0 {
+		mode, name, err := providers.ParseToolChoice(req.ToolChoice)
+		if err != nil {
+			return params, err
+		}
+		switch mode {
+		case providers.ToolChoiceAuto:
+			params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{}}
+		case providers.ToolChoiceNone:
+			params.ToolChoice = anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
+		case providers.ToolChoiceRequired:
+			params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+		case providers.ToolChoiceFunction:
+			params.ToolChoice = anthropic.ToolChoiceParamOfTool(name)
+		}
+	}
+
+	return params, nil
+}
+
+// toSDKTool translates an OpenAI function tool. Anthropic takes the JSON
+// Schema's properties and required list as typed fields; every other schema
+// keyword is passed through unchanged.
+func toSDKTool(t providers.FunctionTool) (*anthropic.ToolParam, error) {
+	tool := &anthropic.ToolParam{Name: t.Name}
+	if t.Description != "" {
+		tool.Description = anthropic.String(t.Description)
+	}
+	if len(t.Parameters) == 0 {
+		return tool, nil
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(t.Parameters, &schema); err != nil {
+		return nil, fmt.Errorf("tool %q: invalid parameters: %w", t.Name, err)
+	}
+	tool.InputSchema.Properties = schema["properties"]
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				tool.InputSchema.Required = append(tool.InputSchema.Required, name)
+			}
+		}
+	}
+	delete(schema, "type")
+	delete(schema, "properties")
+	delete(schema, "required")
+	if len(schema) > 0 {
+		tool.InputSchema.ExtraFields = schema
+	}
+	return tool, nil
 }
 
-func toSDKMessage(role, content string) anthropic.MessageParam {
-	r := strings.ToLower(role)
+func toSDKMessage(m providers.Message) anthropic.MessageParam {
+	r := strings.ToLower(m.Role)
 	anthRole := anthropic.MessageParamRoleUser
 	if r == "assistant" {
 		anthRole = anthropic.MessageParamRoleAssistant
 	}
 
+	if len(m.Parts) > 0 {
+		return anthropic.MessageParam{
+			Role:    anthRole,
+			Content: toSDKContentBlocks(m.Parts),
+		}
+	}
+
+	if len(m.ToolCalls) > 0 {
+		blocks := make([]anthropic.ContentBlockParamUnion, 0, len(m.ToolCalls)+1)
+		if m.Content != "" {
+			blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+		}
+		for _, c := range m.ToolCalls {
+			input := json.RawMessage(c.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, anthropic.NewToolUseBlock(c.ID, input, c.Name))
+		}
+		return anthropic.MessageParam{Role: anthRole, Content: blocks}
+	}
+
 	return anthropic.MessageParam{
 		Role: anthRole,
 		Content: []anthropic.ContentBlockParamUnion{
 			{
 				OfText: &anthropic.TextBlockParam{
-					Text: content,
+					Text: m.Content,
 				},
 			},
 		},
 	}
 }
 
+// toSDKContentBlocks maps multimodal parts onto Anthropic content blocks:
+// data URLs become base64 image sources, anything else a URL source.
+func toSDKContentBlocks(parts []providers.ContentPart) []anthropic.ContentBlockParamUnion {
+	out := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case providers.ContentPartImageURL:
+			if mediaType, data, ok := providers.ParseDataURL(part.ImageURL); ok {
+				out = append(out, anthropic.NewImageBlockBase64(mediaType, data))
+			} else {
+				out = append(out, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: part.ImageURL}))
+			}
+		default:
+			out = append(out, anthropic.NewTextBlock(part.Text))
+		}
+	}
+	return out
+}
+
 func (p *Provider) handleResponse(
 	ctx context.Context,
 	params anthropic.MessageNewParams,
 	opts ...option.RequestOption,
 ) (*providers.ProxyResponse, error) {
-	msg, err := p.client.Messages.New(ctx, params, opts...)
+	var httpResp *http.Response
+	msg, err := p.client.Messages.New(ctx, params, append(opts, option.WithResponseInto(&httpResp))...)
 	if err != nil {
 		return nil, toProviderError(err)
 	}
 
 	// Собираем весь текст из всех text-блоков.
-	var sb strings.Builder
+	var sb, reasoning strings.Builder
+	var toolCalls []providers.ToolCall
 	for _, b := range msg.Content {
 		switch v := b.AsAny().(type) {
 		case anthropic.TextBlock:
 			sb.WriteString(v.Text)
 		case *anthropic.TextBlock:
 			sb.WriteString(v.Text)
+		case anthropic.ThinkingBlock:
+			reasoning.WriteString(v.Thinking)
+		case anthropic.ToolUseBlock:
+			toolCalls = append(toolCalls, providers.ToolCall{ID: v.ID, Name: v.Name, Arguments: string(v.Input)})
 		}
 	}
 
 	return &providers.ProxyResponse{
-		ID:      msg.ID,
-		Model:   string(msg.Model),
-		Content: sb.String(),
+		ID:           msg.ID,
+		Model:        string(msg.Model),
+		Content:      sb.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: string(msg.StopReason),
+		Reasoning:    reasoning.String(),
 		Usage: providers.Usage{
 			InputTokens:  int(msg.Usage.InputTokens),
 			OutputTokens: int(msg.Usage.OutputTokens),
 		},
+		Headers: providers.RateLimitHeaders(httpResp),
 	}, nil
 }
 
@@ -182,15 +344,33 @@ func (p *Provider) handleStreaming(
 ) (*providers.ProxyResponse, error) {
 	ch := make(chan providers.StreamChunk, 64)
 
-	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	var httpResp *http.Response
+	stream := p.client.Messages.NewStreaming(ctx, params, append(opts, option.WithResponseInto(&httpResp))...)
 
 	go func() {
 		defer close(ch)
 
+		// message_start carries the prompt size, message_delta the cumulative
+		// output count; both are reported once the stream ends.
+		var usage *providers.Usage
+
 		for stream.Next() {
 			ev := stream.Current()
 
 			switch eventVariant := ev.AsAny().(type) {
+			case anthropic.MessageStartEvent:
+				usage = &providers.Usage{
+					InputTokens:  int(eventVariant.Message.Usage.InputTokens),
+					OutputTokens: int(eventVariant.Message.Usage.OutputTokens),
+				}
+			case anthropic.MessageDeltaEvent:
+				if usage == nil {
+					usage = &providers.Usage{}
+				}
+				usage.OutputTokens = int(eventVariant.Usage.OutputTokens)
+				if reason := eventVariant.Delta.StopReason; reason != "" {
+					ch <- providers.StreamChunk{FinishReason: string(reason)}
+				}
 			case anthropic.ContentBlockDeltaEvent:
 				switch deltaVariant := eventVariant.Delta.AsAny().(type) {
 				case anthropic.TextDelta:
@@ -206,15 +386,15 @@ func (p *Provider) handleStreaming(
 		}
 
 		if err := stream.Err(); err != nil {
-			// У вас нет error-канала в StreamChunk, поэтому шлём как финальный chunk.
-			ch <- providers.StreamChunk{
-				Content:      fmt.Sprintf("[stream error] %v", err),
-				FinishReason: "error",
-			}
+			ch <- providers.StreamChunk{Err: err}
+			return
+		}
+		if usage != nil {
+			ch <- providers.StreamChunk{Usage: usage}
 		}
 	}()
 
-	return &providers.ProxyResponse{Stream: ch}, nil
+	return &providers.ProxyResponse{Stream: ch, Headers: providers.RateLimitHeaders(httpResp)}, nil
 }
 
 func (p *Provider) requestOptions(overrideKey string) ([]option.RequestOption, error) {
@@ -228,29 +408,34 @@ func (p *Provider) requestOptions(overrideKey string) ([]option.RequestOption, e
 	return []option.RequestOption{option.WithAPIKey(key)}, nil
 }
 
-// ProviderError is a structured error returned by the Anthropic API.
-type ProviderError struct {
-	StatusCode int
-	Message    string
-	Type       string
-	Code       string
-}
-
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("anthropic: %s (status=%d, type=%s)", e.Message, e.StatusCode, e.Type)
-}
-
-// HTTPStatus implements providers.StatusCoder.
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func toProviderError(err error) error {
 	var apierr *anthropic.Error
 	if errors.As(err, &apierr) {
-		return &ProviderError{
+		pe := &providers.Error{
+			Provider:   "anthropic",
 			StatusCode: apierr.StatusCode,
 			Message:    apierr.Error(),
-			Type:       "anthropic_error",
+			Type:       anthropicErrorType(apierr.RawJSON()),
+		}
+		if apierr.Response != nil {
+			pe.RetryAfter = providers.ParseRetryAfter(apierr.Response.Header.Get("Retry-After"))
 		}
+		return pe
 	}
 	return err
 }
+
+// anthropicErrorType returns error.type from an Anthropic error body such as
+// {"type":"error","error":{"type":"overloaded_error","message":"..."}}, or ""
+// when the body is not one. Anthropic sends no separate error code.
+func anthropicErrorType(raw string) string {
+	var body struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(raw), &body) != nil {
+		return ""
+	}
+	return body.Error.Type
+}
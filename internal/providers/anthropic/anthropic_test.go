@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 )
@@ -101,14 +102,14 @@ func respondErrorJSON(w http.ResponseWriter, status int, errType, msg string) {
 	})
 }
 
-func requireProviderError(t *testing.T, err error, wantStatus int) *ProviderError {
+func requireProviderError(t *testing.T, err error, wantStatus int, wantType string) *providers.Error {
 	t.Helper()
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
-	var pe *ProviderError
+	var pe *providers.Error
 	if !errors.As(err, &pe) {
-		t.Fatalf("expected error to be *ProviderError (via errors.As), got %T: %v", err, err)
+		t.Fatalf("expected error to be *providers.Error (via errors.As), got %T: %v", err, err)
 	}
 	if pe.StatusCode != wantStatus {
 		t.Fatalf("expected status=%d, got %d", wantStatus, pe.StatusCode)
@@ -116,8 +117,11 @@ func requireProviderError(t *testing.T, err error, wantStatus int) *ProviderErro
 	if pe.HTTPStatus() != wantStatus {
 		t.Fatalf("expected HTTPStatus()=%d, got %d", wantStatus, pe.HTTPStatus())
 	}
-	if pe.Type != "anthropic_error" {
-		t.Fatalf("expected Type='anthropic_error', got %q", pe.Type)
+	if pe.Provider != "anthropic" {
+		t.Fatalf("expected Provider='anthropic', got %q", pe.Provider)
+	}
+	if pe.Type != wantType {
+		t.Fatalf("expected Type=%q, got %q", wantType, pe.Type)
 	}
 	return pe
 }
@@ -256,6 +260,202 @@ func TestProvider_Request_SystemMessageExtraction(t *testing.T) {
 	}
 }
 
+func TestProvider_Request_StopSequences(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := decodeJSONMap(t, r)
+
+		stop, ok := body["stop_sequences"].([]any)
+		if !ok || len(stop) != 1 || stop[0] != "END" {
+			t.Fatalf("expected stop_sequences=[END], got %#v", body["stop_sequences"])
+		}
+
+		respondMessageJSON(w, "msg-stop", "claude-3-5-sonnet", "ok", 1, 1)
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Stop = []string{"END"}
+
+	p := newTestProvider(srv)
+	if _, err := p.Request(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Request_ImageContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := decodeJSONMap(t, r)
+
+		msgs, ok := body["messages"].([]any)
+		if !ok || len(msgs) != 1 {
+			t.Fatalf("expected 1 message, got %#v", body["messages"])
+		}
+		blocks, ok := msgs[0].(map[string]any)["content"].([]any)
+		if !ok || len(blocks) != 3 {
+			t.Fatalf("expected 3 content blocks, got %#v", msgs[0])
+		}
+
+		text := blocks[0].(map[string]any)
+		if text["type"] != "text" || text["text"] != "Compare these" {
+			t.Fatalf("unexpected text block: %#v", text)
+		}
+
+		inline := blocks[1].(map[string]any)
+		src, _ := inline["source"].(map[string]any)
+		if inline["type"] != "image" || src["type"] != "base64" ||
+			src["media_type"] != "image/png" || src["data"] != "iVBORw0KGgo=" {
+			t.Fatalf("unexpected base64 image block: %#v", inline)
+		}
+
+		remote := blocks[2].(map[string]any)
+		src, _ = remote["source"].(map[string]any)
+		if remote["type"] != "image" || src["type"] != "url" || src["url"] != "https://example.com/cat.jpg" {
+			t.Fatalf("unexpected url image block: %#v", remote)
+		}
+
+		respondMessageJSON(w, "msg-img", "claude-3-5-sonnet", "Two images.", 20, 3)
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Messages = []providers.Message{
+		{Role: "user", Content: "Compare these", Parts: []providers.ContentPart{
+			{Type: providers.ContentPartText, Text: "Compare these"},
+			{Type: providers.ContentPartImageURL, ImageURL: "data:image/png;base64,iVBORw0KGgo="},
+			{Type: providers.ContentPartImageURL, ImageURL: "https://example.com/cat.jpg"},
+		}},
+	}
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "Two images." {
+		t.Fatalf("expected content 'Two images.', got %q", resp.Content)
+	}
+}
+
+func TestProvider_Request_ToolCallRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := decodeJSONMap(t, r)
+
+		tools, _ := body["tools"].([]any)
+		if len(tools) != 1 {
+			t.Fatalf("expected 1 tool, got %#v", body["tools"])
+		}
+		tool := tools[0].(map[string]any)
+		schema, _ := tool["input_schema"].(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		if tool["name"] != "get_weather" || tool["description"] != "Current weather" ||
+			schema["type"] != "object" || props["city"] == nil || len(required) != 1 ||
+			schema["additionalProperties"] != false {
+			t.Fatalf("unexpected tool translation: %#v", tool)
+		}
+		choice, _ := body["tool_choice"].(map[string]any)
+		if choice["type"] != "tool" || choice["name"] != "get_weather" {
+			t.Fatalf("expected tool_choice {type:tool,name:get_weather}, got %#v", body["tool_choice"])
+		}
+
+		msgs, _ := body["messages"].([]any)
+		if len(msgs) != 3 {
+			t.Fatalf("expected 3 messages, got %#v", body["messages"])
+		}
+		assistant := msgs[1].(map[string]any)
+		blocks, _ := assistant["content"].([]any)
+		if assistant["role"] != "assistant" || len(blocks) != 2 {
+			t.Fatalf("expected text + tool_use blocks, got %#v", assistant)
+		}
+		use := blocks[1].(map[string]any)
+		input, _ := use["input"].(map[string]any)
+		if use["type"] != "tool_use" || use["id"] != "toolu_1" || use["name"] != "get_weather" || input["city"] != "Paris" {
+			t.Fatalf("unexpected tool_use block: %#v", use)
+		}
+		result := msgs[2].(map[string]any)
+		results, _ := result["content"].([]any)
+		if result["role"] != "user" || len(results) != 1 {
+			t.Fatalf("expected a user turn with the tool result, got %#v", result)
+		}
+		if rb := results[0].(map[string]any); rb["type"] != "tool_result" || rb["tool_use_id"] != "toolu_1" {
+			t.Fatalf("unexpected tool_result block: %#v", rb)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":    "msg-tool",
+			"type":  "message",
+			"role":  "assistant",
+			"model": "claude-3-5-sonnet",
+			"content": []map[string]any{
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_2", "name": "get_weather", "input": map[string]any{"city": "Lyon"}},
+			},
+			"stop_reason":   "tool_use",
+			"stop_sequence": nil,
+			"usage":         map[string]any{"input_tokens": 30, "output_tokens": 12},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Tools = json.RawMessage(`[{"type":"function","function":{"name":"get_weather","description":"Current weather",` +
+		`"parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"],"additionalProperties":false}}}]`)
+	req.ToolChoice = json.RawMessage(`{"type":"function","function":{"name":"get_weather"}}`)
+	req.Messages = []providers.Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", Content: "Let me look.", ToolCalls: []providers.ToolCall{
+			{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "18C"},
+	}
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "Checking." {
+		t.Errorf("expected content 'Checking.', got %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_2" || resp.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(resp.ToolCalls[0].Arguments), &args); err != nil || args["city"] != "Lyon" {
+		t.Errorf("arguments = %q, want {\"city\":\"Lyon\"}", resp.ToolCalls[0].Arguments)
+	}
+}
+
+func TestProvider_Request_FinishReason(t *testing.T) {
+	for _, reason := range []string{"max_tokens", "refusal"} {
+		t.Run(reason, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"id":            "msg-fr",
+					"type":          "message",
+					"role":          "assistant",
+					"model":         "claude-3-5-sonnet",
+					"content":       []map[string]any{{"type": "text", "text": "cut"}},
+					"stop_reason":   reason,
+					"stop_sequence": nil,
+					"usage":         map[string]any{"input_tokens": 3, "output_tokens": 1},
+				})
+			}))
+			defer srv.Close()
+
+			resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.FinishReason != reason {
+				t.Errorf("FinishReason = %q, want %q", resp.FinishReason, reason)
+			}
+		})
+	}
+}
+
 func TestProvider_Request_Streaming(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost || !isMessagesPath(r.URL.Path) {
@@ -275,6 +475,7 @@ func TestProvider_Request_Streaming(t *testing.T) {
 			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n",
 			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n",
 			"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n",
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"stop_sequence\":null},\"usage\":{\"output_tokens\":2}}\n\n",
 			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
 		}
 
@@ -300,13 +501,27 @@ func TestProvider_Request_Streaming(t *testing.T) {
 	}
 
 	var content strings.Builder
+	var usage *providers.Usage
+	var finish string
 	for chunk := range resp.Stream {
 		content.WriteString(chunk.Content)
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
 	}
 
 	if content.String() != "Hello world" {
 		t.Fatalf("expected %q, got %q", "Hello world", content.String())
 	}
+	if finish != "end_turn" {
+		t.Errorf("finish reason = %q, want end_turn", finish)
+	}
+	if usage == nil || usage.InputTokens != 1 || usage.OutputTokens != 2 {
+		t.Fatalf("expected usage {1 2}, got %+v", usage)
+	}
 }
 
 func TestProvider_Request_RateLimit(t *testing.T) {
@@ -320,11 +535,33 @@ func TestProvider_Request_RateLimit(t *testing.T) {
 
 	p := newTestProvider(srv)
 	_, err := p.Request(context.Background(), baseRequest())
-	pe := requireProviderError(t, err, http.StatusTooManyRequests)
+	pe := requireProviderError(t, err, http.StatusTooManyRequests, "rate_limit_error")
 
 	// Сообщение зависит от SDK, но обычно содержит суть.
 	if pe.Message == "" {
-		t.Fatalf("expected non-empty ProviderError.Message")
+		t.Fatalf("expected non-empty Error.Message")
+	}
+}
+
+func TestProvider_Request_RateLimit_RetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retry-After-Ms keeps the SDK's own retries fast; the gateway only
+		// looks at Retry-After.
+		w.Header().Set("Retry-After-Ms", "1")
+		w.Header().Set("Retry-After", "17")
+		respondErrorJSON(w, http.StatusTooManyRequests, "rate_limit_error", "Rate limit exceeded")
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	_, err := p.Request(context.Background(), baseRequest())
+	pe := requireProviderError(t, err, http.StatusTooManyRequests, "rate_limit_error")
+
+	if pe.RetryAfter != 17*time.Second {
+		t.Fatalf("expected RetryAfter=17s, got %v", pe.RetryAfter)
+	}
+	if pe.RetryAfterDuration() != pe.RetryAfter {
+		t.Fatalf("RetryAfterDuration() = %v, want %v", pe.RetryAfterDuration(), pe.RetryAfter)
 	}
 }
 
@@ -340,7 +577,7 @@ func TestProvider_Request_ServerError_529(t *testing.T) {
 
 	p := newTestProvider(srv)
 	_, err := p.Request(context.Background(), baseRequest())
-	_ = requireProviderError(t, err, 529)
+	_ = requireProviderError(t, err, 529, "overloaded_error")
 }
 
 func TestProvider_Request_ServerError_503(t *testing.T) {
@@ -354,7 +591,21 @@ func TestProvider_Request_ServerError_503(t *testing.T) {
 
 	p := newTestProvider(srv)
 	_, err := p.Request(context.Background(), baseRequest())
-	_ = requireProviderError(t, err, http.StatusServiceUnavailable)
+	_ = requireProviderError(t, err, http.StatusServiceUnavailable, "server_error")
+}
+
+func TestProvider_Request_ResponseFormatUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected upstream call: %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.ResponseFormat = json.RawMessage(`{"type":"json_object"}`)
+
+	p := newTestProvider(srv)
+	_, err := p.Request(context.Background(), req)
+	_ = requireProviderError(t, err, http.StatusBadRequest, "invalid_request_error")
 }
 
 func TestProvider_HealthCheck(t *testing.T) {
@@ -380,10 +631,11 @@ func TestProvider_HealthCheck(t *testing.T) {
 }
 
 func TestProvider_ProviderError_ErrorString(t *testing.T) {
-	e := &ProviderError{
+	e := &providers.Error{
+		Provider:   "anthropic",
 		StatusCode: 429,
 		Message:    "Rate limit exceeded",
-		Type:       "anthropic_error",
+		Type:       "rate_limit_error",
 	}
 	s := e.Error()
 	if s == "" {
@@ -396,3 +648,80 @@ func TestProvider_ProviderError_ErrorString(t *testing.T) {
 		t.Fatalf("Error() should mention status code, got: %s", s)
 	}
 }
+
+func TestProvider_Request_CapturesRateLimitHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("anthropic-ratelimit-requests-remaining", "49")
+		w.Header().Set("anthropic-ratelimit-tokens-reset", "2026-01-01T00:00:30Z")
+		w.Header().Set("request-id", "req_abc")
+		respondMessageJSON(w, "msg-123", "claude-3-5-sonnet", "ok", 1, 1)
+	}))
+	defer srv.Close()
+
+	resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Headers["anthropic-ratelimit-requests-remaining"] != "49" ||
+		resp.Headers["anthropic-ratelimit-tokens-reset"] != "2026-01-01T00:00:30Z" {
+		t.Fatalf("headers = %v", resp.Headers)
+	}
+	if _, ok := resp.Headers["request-id"]; ok {
+		t.Fatalf("non rate-limit header captured: %v", resp.Headers)
+	}
+}
+
+func TestProvider_Request_Thinking(t *testing.T) {
+	tests := []struct {
+		name          string
+		mutate        func(*providers.ProxyRequest)
+		wantBudget    int
+		wantMaxTokens int
+	}{
+		{"explicit budget", func(r *providers.ProxyRequest) { r.ThinkingBudget = 3000 }, 3000, 3000 + defaultMaxTokens},
+		{"from reasoning_effort", func(r *providers.ProxyRequest) { r.ReasoningEffort = "medium" }, 8192, 8192 + defaultMaxTokens},
+		{"client max_tokens kept", func(r *providers.ProxyRequest) { r.ThinkingBudget = 2000; r.MaxTokens = 5000 }, 2000, 5000},
+		{"not requested", func(*providers.ProxyRequest) {}, 0, defaultMaxTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := decodeJSONMap(t, r)
+				if got, _ := jsonFloatToInt(body["max_tokens"]); got != tt.wantMaxTokens {
+					t.Errorf("max_tokens = %v, want %d", body["max_tokens"], tt.wantMaxTokens)
+				}
+				thinking, ok := body["thinking"].(map[string]any)
+				if tt.wantBudget == 0 {
+					if ok {
+						t.Errorf("unexpected thinking field: %v", body["thinking"])
+					}
+				} else if budget, _ := jsonFloatToInt(thinking["budget_tokens"]); !ok ||
+					thinking["type"] != "enabled" || budget != tt.wantBudget {
+					t.Errorf("thinking = %v, want enabled with budget %d", body["thinking"], tt.wantBudget)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"id": "msg-1", "type": "message", "role": "assistant", "model": "claude-sonnet-4",
+					"content": []map[string]any{
+						{"type": "thinking", "thinking": "Let me work it out.", "signature": "sig"},
+						{"type": "text", "text": "42"},
+					},
+					"stop_reason": "end_turn",
+					"usage":       map[string]any{"input_tokens": 1, "output_tokens": 1},
+				})
+			}))
+			defer srv.Close()
+
+			req := baseRequest()
+			tt.mutate(req)
+			resp, err := newTestProvider(srv).Request(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Content != "42" || resp.Reasoning != "Let me work it out." {
+				t.Errorf("content = %q, reasoning = %q", resp.Content, resp.Reasoning)
+			}
+		})
+	}
+}
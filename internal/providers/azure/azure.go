@@ -28,16 +28,37 @@ import (
 const providerName = "azure"
 
 type chatRequest struct {
-	Model       string        `json:"model,omitempty"`
-	Messages    []chatMessage `json:"messages"`
-	Stream      bool          `json:"stream,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Model            string          `json:"model,omitempty"`
+	Messages         []chatMessage   `json:"messages"`
+	Stream           bool            `json:"stream,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Seed             *int64          `json:"seed,omitempty"`
+	LogProbs         *bool           `json:"logprobs,omitempty"`
+	TopLogProbs      *int            `json:"top_logprobs,omitempty"`
+	Tools            json.RawMessage `json:"tools,omitempty"`
+	ToolChoice       json.RawMessage `json:"tool_choice,omitempty"`
+	ResponseFormat   json.RawMessage `json:"response_format,omitempty"`
 }
 
 type chatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type chatResponse struct {
@@ -52,6 +73,8 @@ type choice struct {
 	Message      *chatMessage `json:"message,omitempty"`
 	Delta        *chatMessage `json:"delta,omitempty"`
 	FinishReason string       `json:"finish_reason"`
+	// LogProbs is kept verbatim; "null" when not requested.
+	LogProbs json.RawMessage `json:"logprobs,omitempty"`
 }
 
 type usage struct {
@@ -82,7 +105,7 @@ func New(endpoint, apiKey, apiVersion string, opts ...Option) *Provider {
 		endpoint:   strings.TrimRight(endpoint, "/"),
 		apiKey:     apiKey,
 		apiVersion: apiVersion,
-		client:     &http.Client{Timeout: providers.ProviderTimeout},
+		client:     providers.NewHTTPClient(),
 	}
 	for _, o := range opts {
 		o(p)
@@ -164,7 +187,12 @@ func (p *Provider) completionsURL(deployment string) string {
 func (p *Provider) buildRequest(req *providers.ProxyRequest) ([]byte, error) {
 	msgs := make([]chatMessage, len(req.Messages))
 	for i, m := range req.Messages {
-		msgs[i] = chatMessage{Role: m.Role, Content: m.Content}
+		msgs[i] = chatMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCalls:  toChatToolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
+		}
 	}
 	cr := chatRequest{Messages: msgs}
 	if req.Stream {
@@ -176,6 +204,16 @@ func (p *Provider) buildRequest(req *providers.ProxyRequest) ([]byte, error) {
 	if req.MaxTokens > 0 {
 		cr.MaxTokens = req.MaxTokens
 	}
+	cr.TopP = req.TopP
+	cr.Stop = req.Stop
+	cr.PresencePenalty = req.PresencePenalty
+	cr.FrequencyPenalty = req.FrequencyPenalty
+	cr.Seed = req.Seed
+	cr.LogProbs = req.LogProbs
+	cr.TopLogProbs = req.TopLogProbs
+	cr.Tools = req.Tools
+	cr.ToolChoice = req.ToolChoice
+	cr.ResponseFormat = req.ResponseFormat
 
 	data, err := json.Marshal(cr)
 	if err != nil {
@@ -190,15 +228,27 @@ func (p *Provider) handleResponse(resp *http.Response) (*providers.ProxyResponse
 		return nil, fmt.Errorf("azure: decode response: %w", err)
 	}
 
-	content := ""
+	content, finish := "", ""
+	var (
+		toolCalls []providers.ToolCall
+		logProbs  json.RawMessage
+	)
 	if len(cr.Choices) > 0 && cr.Choices[0].Message != nil {
 		content = cr.Choices[0].Message.Content
+		toolCalls = fromChatToolCalls(cr.Choices[0].Message.ToolCalls)
+		finish = cr.Choices[0].FinishReason
+		if lp := cr.Choices[0].LogProbs; len(lp) > 0 && string(lp) != "null" {
+			logProbs = lp
+		}
 	}
 
 	return &providers.ProxyResponse{
-		ID:      cr.ID,
-		Model:   cr.Model,
-		Content: content,
+		ID:           cr.ID,
+		Model:        cr.Model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
+		LogProbs:     logProbs,
 		Usage: providers.Usage{
 			InputTokens:  cr.Usage.PromptTokens,
 			OutputTokens: cr.Usage.CompletionTokens,
@@ -206,6 +256,33 @@ func (p *Provider) handleResponse(resp *http.Response) (*providers.ProxyResponse
 	}, nil
 }
 
+// toChatToolCalls converts an assistant turn's tool calls to the wire format.
+func toChatToolCalls(calls []providers.ToolCall) []toolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]toolCall, len(calls))
+	for i, c := range calls {
+		out[i].ID = c.ID
+		out[i].Type = "function"
+		out[i].Function.Name = c.Name
+		out[i].Function.Arguments = c.Arguments
+	}
+	return out
+}
+
+// fromChatToolCalls converts response tool calls to the provider form.
+func fromChatToolCalls(calls []toolCall) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = providers.ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments}
+	}
+	return out
+}
+
 func (p *Provider) handleStreaming(resp *http.Response) (*providers.ProxyResponse, error) {
 	ch := make(chan providers.StreamChunk, 64)
 
@@ -237,42 +314,34 @@ func (p *Provider) handleStreaming(resp *http.Response) (*providers.ProxyRespons
 				FinishReason: cr.Choices[0].FinishReason,
 			}
 		}
+		if err := scanner.Err(); err != nil {
+			ch <- providers.StreamChunk{Err: err}
+		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
 }
 
-// ProviderError is a structured error returned by the Azure OpenAI API.
-type ProviderError struct {
-	StatusCode int
-	Message    string
-	Type       string
-	Code       string
-}
-
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("azure: %s (status=%d, type=%s)", e.Message, e.StatusCode, e.Type)
-}
-
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func (p *Provider) parseError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
 	var cr chatResponse
 	if json.Unmarshal(body, &cr) == nil && cr.Error != nil {
-		return &ProviderError{
+		return &providers.Error{
+			Provider:   "azure",
 			StatusCode: resp.StatusCode,
 			Message:    cr.Error.Message,
 			Type:       cr.Error.Type,
 			Code:       cr.Error.Code,
+			RetryAfter: providers.ParseRetryAfter(resp.Header.Get("Retry-After")),
 		}
 	}
 
-	return &ProviderError{
+	return &providers.Error{
+		Provider:   "azure",
 		StatusCode: resp.StatusCode,
 		Message:    fmt.Sprintf("unexpected status %d", resp.StatusCode),
-		Type:       "azure_error",
+		RetryAfter: providers.ParseRetryAfter(resp.Header.Get("Retry-After")),
 	}
 }
 
@@ -8,6 +8,12 @@
 //
 // Optional:
 //   - AWS_SESSION_TOKEN — for temporary credentials (IAM roles, STS).
+//   - BEDROCK_GUARDRAIL_ID / BEDROCK_GUARDRAIL_VERSION — apply a Bedrock
+//     Guardrail to every Converse call.
+//   - BEDROCK_INFERENCE_PROFILES — send models through inference profiles.
+//
+// The model may also be an inference profile ID or ARN; it is
+// percent-encoded into the /model/{modelId}/converse path either way.
 package bedrock
 
 import (
@@ -21,6 +27,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -41,6 +49,13 @@ type Provider struct {
 	region       string
 	endpointURL  string // optional override for the base endpoint (testing)
 	client       *http.Client
+
+	guardrailID      string
+	guardrailVersion string
+
+	// inferenceProfiles maps model IDs to the inference profile ID or ARN
+	// they are invoked through.
+	inferenceProfiles map[string]string
 }
 
 // Option configures a Provider.
@@ -57,13 +72,31 @@ func WithEndpointURL(u string) Option {
 	return func(p *Provider) { p.endpointURL = u }
 }
 
+// WithGuardrail applies the Bedrock Guardrail identified by id (its ID or
+// ARN) at version (e.g. "1" or "DRAFT") to every request. Responses the
+// guardrail blocks finish with reason "content_filter".
+func WithGuardrail(id, version string) Option {
+	return func(p *Provider) {
+		p.guardrailID = id
+		p.guardrailVersion = version
+	}
+}
+
+// WithInferenceProfiles invokes the mapped models through the given
+// inference profile IDs or ARNs, e.g. "anthropic.claude-3-7-sonnet-20250219-v1:0"
+// through "us.anthropic.claude-3-7-sonnet-20250219-v1:0". Responses still
+// report the requested model.
+func WithInferenceProfiles(profiles map[string]string) Option {
+	return func(p *Provider) { p.inferenceProfiles = profiles }
+}
+
 // New creates a new AWS Bedrock Provider.
 func New(accessKey, secretKey, region string, opts ...Option) *Provider {
 	p := &Provider{
 		accessKey: accessKey,
 		secretKey: secretKey,
 		region:    region,
-		client:    &http.Client{Timeout: providers.ProviderTimeout},
+		client:    providers.NewHTTPClient(),
 	}
 	for _, o := range opts {
 		o(p)
@@ -99,6 +132,18 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+	// The Converse API has no structured-output mode; refuse rather than
+	// return free-form text to a client that asked for JSON.
+	if providers.WantsStructuredOutput(req.ResponseFormat) {
+		return nil, &providers.Error{
+			Provider:   "bedrock",
+			StatusCode: 400,
+			Message:    "response_format json_object and json_schema are not supported",
+		}
+	}
+	if providers.WantsLogProbs(req) {
+		return nil, &providers.Error{Provider: "bedrock", StatusCode: 400, Message: "logprobs are not supported"}
+	}
 	if req.Stream {
 		return p.handleStreaming(ctx, req)
 	}
@@ -111,6 +156,12 @@ type converseRequest struct {
 	Messages        []converseMessage `json:"messages"`
 	System          []systemContent   `json:"system,omitempty"`
 	InferenceConfig *inferenceConfig  `json:"inferenceConfig,omitempty"`
+	GuardrailConfig *guardrailConfig  `json:"guardrailConfig,omitempty"`
+}
+
+type guardrailConfig struct {
+	GuardrailIdentifier string `json:"guardrailIdentifier"`
+	GuardrailVersion    string `json:"guardrailVersion"`
 }
 
 type converseMessage struct {
@@ -119,7 +170,29 @@ type converseMessage struct {
 }
 
 type contentBlock struct {
-	Text string `json:"text"`
+	Text  string
+	Image *imageBlock
+}
+
+// MarshalJSON emits exactly one member: Converse content blocks are a union.
+func (b contentBlock) MarshalJSON() ([]byte, error) {
+	if b.Image != nil {
+		return json.Marshal(struct {
+			Image *imageBlock `json:"image"`
+		}{b.Image})
+	}
+	return json.Marshal(struct {
+		Text string `json:"text"`
+	}{b.Text})
+}
+
+type imageBlock struct {
+	Format string      `json:"format"`
+	Source imageSource `json:"source"`
+}
+
+type imageSource struct {
+	Bytes string `json:"bytes"` // base64
 }
 
 type systemContent struct {
@@ -127,13 +200,16 @@ type systemContent struct {
 }
 
 type inferenceConfig struct {
-	MaxTokens   int     `json:"maxTokens,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   float64  `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
 }
 
 type converseResponse struct {
-	Output converseOutput `json:"output"`
-	Usage  converseUsage  `json:"usage"`
+	Output     converseOutput `json:"output"`
+	StopReason string         `json:"stopReason"`
+	Usage      converseUsage  `json:"usage"`
 }
 
 type converseOutput struct {
@@ -160,9 +236,17 @@ func (p *Provider) buildConverseRequest(req *providers.ProxyRequest) (converseRe
 			if strings.ToLower(m.Role) == "assistant" {
 				role = "assistant"
 			}
+			content := []contentBlock{{Text: m.Content}}
+			if len(m.Parts) > 0 {
+				blocks, err := toContentBlocks(m.Parts)
+				if err != nil {
+					return converseRequest{}, err
+				}
+				content = blocks
+			}
 			msgs = append(msgs, converseMessage{
 				Role:    role,
-				Content: []contentBlock{{Text: m.Content}},
+				Content: content,
 			})
 		}
 	}
@@ -172,16 +256,51 @@ func (p *Provider) buildConverseRequest(req *providers.ProxyRequest) (converseRe
 		System:   systemTexts,
 	}
 
-	if req.MaxTokens > 0 || req.Temperature > 0 {
+	if p.guardrailID != "" {
+		cr.GuardrailConfig = &guardrailConfig{
+			GuardrailIdentifier: p.guardrailID,
+			GuardrailVersion:    p.guardrailVersion,
+		}
+	}
+
+	if req.MaxTokens > 0 || req.Temperature > 0 || req.TopP != nil || len(req.Stop) > 0 {
 		cr.InferenceConfig = &inferenceConfig{
-			MaxTokens:   req.MaxTokens,
-			Temperature: req.Temperature,
+			MaxTokens:     req.MaxTokens,
+			Temperature:   req.Temperature,
+			TopP:          req.TopP,
+			StopSequences: req.Stop,
 		}
 	}
 
 	return cr, nil
 }
 
+// toContentBlocks maps multimodal parts onto Converse content blocks. The
+// Converse API only takes inline image bytes, so remote image URLs are
+// rejected with a 400.
+func toContentBlocks(parts []providers.ContentPart) ([]contentBlock, error) {
+	out := make([]contentBlock, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != providers.ContentPartImageURL {
+			out = append(out, contentBlock{Text: part.Text})
+			continue
+		}
+		mediaType, data, ok := providers.ParseDataURL(part.ImageURL)
+		if !ok {
+			return nil, &providers.Error{
+				Provider:   "bedrock",
+				StatusCode: http.StatusBadRequest,
+				Message:    "only base64 data URLs are supported for images",
+			}
+		}
+		out = append(out, contentBlock{Image: &imageBlock{
+			Format: strings.TrimPrefix(mediaType, "image/"),
+			Source: imageSource{Bytes: data},
+		}})
+	}
+	return out, nil
+}
+
 // ─── Non-streaming ────────────────────────────────────────────────────────────
 
 func (p *Provider) handleResponse(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
@@ -195,7 +314,7 @@ func (p *Provider) handleResponse(ctx context.Context, req *providers.ProxyReque
 		return nil, fmt.Errorf("bedrock: marshal: %w", err)
 	}
 
-	endpoint := p.converseEndpoint(req.Model)
+	endpoint := p.converseEndpoint(p.modelTarget(req.Model))
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("bedrock: %w", err)
@@ -226,15 +345,20 @@ func (p *Provider) handleResponse(ctx context.Context, req *providers.ProxyReque
 		content = cr.Output.Message.Content[0].Text
 	}
 
-	return &providers.ProxyResponse{
+	out := &providers.ProxyResponse{
 		ID:      req.RequestID,
 		Model:   req.Model,
 		Content: content,
+		// A blocked response carries the guardrail's configured message as
+		// its content and stops with "guardrail_intervened", which the
+		// gateway reports as "content_filter".
+		FinishReason: cr.StopReason,
 		Usage: providers.Usage{
 			InputTokens:  cr.Usage.InputTokens,
 			OutputTokens: cr.Usage.OutputTokens,
 		},
-	}, nil
+	}
+	return out, nil
 }
 
 // ─── Streaming ────────────────────────────────────────────────────────────────
@@ -261,7 +385,7 @@ func (p *Provider) handleStreaming(ctx context.Context, req *providers.ProxyRequ
 		return nil, fmt.Errorf("bedrock: marshal: %w", err)
 	}
 
-	endpoint := p.converseStreamEndpoint(req.Model)
+	endpoint := p.converseStreamEndpoint(p.modelTarget(req.Model))
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("bedrock: %w", err)
@@ -309,6 +433,9 @@ func (p *Provider) handleStreaming(ctx context.Context, req *providers.ProxyRequ
 				ch <- providers.StreamChunk{FinishReason: ev.MessageStop.StopReason}
 			}
 		}
+		if err := scanner.Err(); err != nil {
+			ch <- providers.StreamChunk{Err: err}
+		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
@@ -316,6 +443,15 @@ func (p *Provider) handleStreaming(ctx context.Context, req *providers.ProxyRequ
 
 // ─── Endpoints ───────────────────────────────────────────────────────────────
 
+// modelTarget returns the model ID or inference profile a request for model
+// is sent to.
+func (p *Provider) modelTarget(model string) string {
+	if profile, ok := p.inferenceProfiles[model]; ok {
+		return profile
+	}
+	return model
+}
+
 // baseEndpoint returns the root URL for a given Bedrock sub-service.
 // When endpointURL is set (e.g. for testing), it is used for all services.
 func (p *Provider) baseEndpoint(subservice string) string {
@@ -327,21 +463,21 @@ func (p *Provider) baseEndpoint(subservice string) string {
 
 func (p *Provider) converseEndpoint(modelID string) string {
 	if p.endpointURL != "" {
-		return fmt.Sprintf("%s/model/%s/converse", strings.TrimRight(p.endpointURL, "/"), modelID)
+		return fmt.Sprintf("%s/model/%s/converse", strings.TrimRight(p.endpointURL, "/"), uriEncode(modelID))
 	}
 	return fmt.Sprintf(
 		"https://bedrock-runtime.%s.amazonaws.com/model/%s/converse",
-		p.region, modelID,
+		p.region, uriEncode(modelID),
 	)
 }
 
 func (p *Provider) converseStreamEndpoint(modelID string) string {
 	if p.endpointURL != "" {
-		return fmt.Sprintf("%s/model/%s/converse-stream", strings.TrimRight(p.endpointURL, "/"), modelID)
+		return fmt.Sprintf("%s/model/%s/converse-stream", strings.TrimRight(p.endpointURL, "/"), uriEncode(modelID))
 	}
 	return fmt.Sprintf(
 		"https://bedrock-runtime.%s.amazonaws.com/model/%s/converse-stream",
-		p.region, modelID,
+		p.region, uriEncode(modelID),
 	)
 }
 
@@ -387,19 +523,7 @@ func (p *Provider) signRequest(req *http.Request, payload []byte) error {
 		)
 	}
 
-	canonicalURI := req.URL.Path
-	if canonicalURI == "" {
-		canonicalURI = "/"
-	}
-
-	canonicalRequest := strings.Join([]string{
-		req.Method,
-		canonicalURI,
-		req.URL.RawQuery,
-		canonicalHeaders,
-		signedHeaders,
-		payloadHash,
-	}, "\n")
+	canonicalRequest := buildCanonicalRequest(req, canonicalHeaders, signedHeaders, payloadHash)
 
 	// Credential scope
 	credentialScope := fmt.Sprintf("%s/%s/%s/aws4_request", datestamp, p.region, service)
@@ -425,6 +549,78 @@ func (p *Provider) signRequest(req *http.Request, payload []byte) error {
 	return nil
 }
 
+// buildCanonicalRequest returns the SigV4 canonical request for req. The
+// path and query are canonicalized with canonicalURI and canonicalQuery.
+func buildCanonicalRequest(req *http.Request, canonicalHeaders, signedHeaders, payloadHash string) string {
+	return strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.RawQuery),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalURI returns the SigV4 canonical path of u. Services other than S3
+// expect every segment of the path as sent on the wire, which is already
+// percent-encoded, to be URI-encoded again — so a model ID sent as
+// "…-v2%3A0" is signed as "…-v2%253A0". An empty path is "/".
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the SigV4 canonical query string: every name and
+// value URI-encoded, sorted by name and then value, joined with "&".
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, _ := url.ParseQuery(rawQuery)
+	type pair struct{ name, value string }
+	pairs := make([]pair, 0, len(values))
+	for name, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, pair{uriEncode(name), uriEncode(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].name != pairs[j].name {
+			return pairs[i].name < pairs[j].name
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode percent-encodes every byte of s except the RFC 3986 unreserved
+// characters, with upper-case hex digits, as SigV4 requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
 func deriveSigningKey(secretKey, date, region, svc string) []byte {
 	kDate := hmacSHA256([]byte("AWS4"+secretKey), date)
 	kRegion := hmacSHA256(kDate, region)
@@ -450,28 +646,34 @@ type bedrockError struct {
 	Type    string `json:"__type"`
 }
 
-// ProviderError is a structured error returned by the Bedrock API.
-type ProviderError struct {
-	StatusCode int
-	Message    string
-}
-
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("bedrock: %s (status=%d)", e.Message, e.StatusCode)
+// bedrockErrorType trims the namespace AWS may prefix to an error's __type,
+// as in "com.amazon.coral.service#ThrottlingException".
+func bedrockErrorType(t string) string {
+	if _, name, ok := strings.Cut(t, "#"); ok {
+		return name
+	}
+	return t
 }
 
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func (p *Provider) parseError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
+	retryAfter := providers.ParseRetryAfter(resp.Header.Get("Retry-After"))
 
 	var be bedrockError
 	if json.Unmarshal(body, &be) == nil && be.Message != "" {
-		return &ProviderError{StatusCode: resp.StatusCode, Message: be.Message}
+		return &providers.Error{
+			Provider:   "bedrock",
+			StatusCode: resp.StatusCode,
+			Message:    be.Message,
+			Type:       bedrockErrorType(be.Type),
+			RetryAfter: retryAfter,
+		}
 	}
 
-	return &ProviderError{
+	return &providers.Error{
+		Provider:   "bedrock",
 		StatusCode: resp.StatusCode,
 		Message:    fmt.Sprintf("unexpected status %d", resp.StatusCode),
+		RetryAfter: retryAfter,
 	}
 }
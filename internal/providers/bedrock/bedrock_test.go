@@ -0,0 +1,259 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func baseRequest() *providers.ProxyRequest {
+	return &providers.ProxyRequest{
+		Model:     "anthropic.claude-3-5-sonnet-20241022-v2:0",
+		Messages:  []providers.Message{{Role: "user", Content: "Hello"}},
+		RequestID: "req-mock-1",
+	}
+}
+
+func respondConverse(w http.ResponseWriter, text, stopReason string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"output": map[string]any{
+			"message": map[string]any{
+				"role":    "assistant",
+				"content": []map[string]any{{"text": text}},
+			},
+		},
+		"stopReason": stopReason,
+		"usage":      map[string]any{"inputTokens": 3, "outputTokens": 2},
+	})
+}
+
+func TestProvider_Request_Guardrail(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/converse") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, algorithm+" ") {
+			t.Errorf("request is not signed: Authorization = %q", auth)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		respondConverse(w, "Sorry, I can't help with that.", "guardrail_intervened")
+	}))
+	defer srv.Close()
+
+	p := New("AKIDEXAMPLE", "secret", "us-east-1",
+		WithEndpointURL(srv.URL), WithGuardrail("gr-abc123", "2"))
+	resp, err := p.Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gc, ok := body["guardrailConfig"].(map[string]any)
+	if !ok || gc["guardrailIdentifier"] != "gr-abc123" || gc["guardrailVersion"] != "2" {
+		t.Fatalf("guardrailConfig = %v", body["guardrailConfig"])
+	}
+	if resp.FinishReason != "guardrail_intervened" || resp.Content != "Sorry, I can't help with that." {
+		t.Errorf("resp = %+v, want the guardrail message stopped by guardrail_intervened", resp)
+	}
+}
+
+func TestProvider_Request_NoGuardrail(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		respondConverse(w, "Hi!", "end_turn")
+	}))
+	defer srv.Close()
+
+	resp, err := New("AKIDEXAMPLE", "secret", "us-east-1", WithEndpointURL(srv.URL)).
+		Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := body["guardrailConfig"]; ok {
+		t.Errorf("guardrailConfig sent without a configured guardrail: %v", body["guardrailConfig"])
+	}
+	if resp.Content != "Hi!" || resp.Choices != nil {
+		t.Errorf("resp = %+v", resp)
+	}
+}
+
+func TestProvider_Request_MaxTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondConverse(w, "Once upon a", "max_tokens")
+	}))
+	defer srv.Close()
+
+	resp, err := New("AKIDEXAMPLE", "secret", "us-east-1", WithEndpointURL(srv.URL)).
+		Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.FinishReason != "max_tokens" {
+		t.Errorf("FinishReason = %q, want max_tokens", resp.FinishReason)
+	}
+}
+
+func TestProvider_Request_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"__type":"com.amazon.coral.service#ThrottlingException","message":"Too many requests"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New("AKIDEXAMPLE", "secret", "us-east-1", WithEndpointURL(srv.URL)).
+		Request(context.Background(), baseRequest())
+	pe, ok := err.(*providers.Error)
+	if !ok {
+		t.Fatalf("err = %T (%v), want *providers.Error", err, err)
+	}
+	want := providers.Error{
+		Provider:   "bedrock",
+		StatusCode: http.StatusTooManyRequests,
+		Message:    "Too many requests",
+		Type:       "ThrottlingException",
+		RetryAfter: 3 * time.Second,
+	}
+	if *pe != want {
+		t.Errorf("err = %+v, want %+v", *pe, want)
+	}
+}
+
+func TestCanonicalRequest_ColonModelID(t *testing.T) {
+	p := New("AKIDEXAMPLE", "secret", "us-east-1")
+	req, err := http.NewRequest(http.MethodPost, p.converseEndpoint("anthropic.claude-3-5-sonnet-20241022-v2:0"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// On the wire the colon is percent-encoded, as the AWS SDKs send it…
+	if got, want := req.URL.EscapedPath(), "/model/anthropic.claude-3-5-sonnet-20241022-v2%3A0/converse"; got != want {
+		t.Errorf("request path = %q, want %q", got, want)
+	}
+
+	const payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	got := buildCanonicalRequest(req,
+		"content-type:application/json\nhost:bedrock-runtime.us-east-1.amazonaws.com\nx-amz-date:20260101T000000Z\n",
+		"content-type;host;x-amz-date", payloadHash)
+	// …and encoded once more in the canonical request.
+	want := strings.Join([]string{
+		"POST",
+		"/model/anthropic.claude-3-5-sonnet-20241022-v2%253A0/converse",
+		"",
+		"content-type:application/json",
+		"host:bedrock-runtime.us-east-1.amazonaws.com",
+		"x-amz-date:20260101T000000Z",
+		"",
+		"content-type;host;x-amz-date",
+		payloadHash,
+	}, "\n")
+	if got != want {
+		t.Errorf("canonical request =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalRequest_Query(t *testing.T) {
+	// AWS Signature Version 4 test suite: get-vanilla-query-order-key-case.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buildCanonicalRequest(req,
+		"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n", "host;x-amz-date",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	want := "GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+		"host;x-amz-date\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got != want {
+		t.Errorf("canonical request =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct{ raw, want string }{
+		{"", ""},
+		{"b=2&a=1", "a=1&b=2"},
+		{"a=2&a=1", "a=1&a=2"},
+		{"a-b=2&a=1", "a=1&a-b=2"}, // sorted by name, not by the joined pair
+		{"q=x+y&r=a%2Fb&s=~ok", "q=x%20y&r=a%2Fb&s=~ok"},
+		{"flag", "flag="},
+	}
+	for _, tt := range tests {
+		if got := canonicalQuery(tt.raw); got != tt.want {
+			t.Errorf("canonicalQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://example.amazonaws.com":                   "/",
+		"https://example.amazonaws.com/":                  "/",
+		"https://example.amazonaws.com/foundation-models": "/foundation-models",
+		"https://example.amazonaws.com/model/a%3Ab/x":     "/model/a%253Ab/x",
+		"https://example.amazonaws.com/a%20b/":            "/a%2520b/",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := canonicalURI(u); got != want {
+			t.Errorf("canonicalURI(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestConverseEndpoint_InferenceProfile(t *testing.T) {
+	p := New("AKIDEXAMPLE", "secret", "us-east-1")
+	cases := []struct{ model, want string }{
+		{
+			"us.anthropic.claude-3-7-sonnet-20250219-v1:0",
+			"https://bedrock-runtime.us-east-1.amazonaws.com/model/us.anthropic.claude-3-7-sonnet-20250219-v1%3A0/converse",
+		},
+		{
+			"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.meta.llama3-3-70b-instruct-v1:0",
+			"https://bedrock-runtime.us-east-1.amazonaws.com/model/arn%3Aaws%3Abedrock%3Aus-east-1%3A123456789012%3Ainference-profile%2Fus.meta.llama3-3-70b-instruct-v1%3A0/converse",
+		},
+	}
+	for _, tc := range cases {
+		if got := p.converseEndpoint(tc.model); got != tc.want {
+			t.Errorf("converseEndpoint(%q) = %q, want %q", tc.model, got, tc.want)
+		}
+	}
+}
+
+func TestProvider_Request_InferenceProfileMapping(t *testing.T) {
+	const profile = "us.anthropic.claude-3-5-sonnet-20241022-v2:0"
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		respondConverse(w, "Hi!", "end_turn")
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	p := New("AKIDEXAMPLE", "secret", "us-east-1", WithEndpointURL(srv.URL),
+		WithInferenceProfiles(map[string]string{req.Model: profile}))
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "/model/us.anthropic.claude-3-5-sonnet-20241022-v2%3A0/converse"; path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if resp.Model != req.Model {
+		t.Errorf("resp.Model = %q, want the requested %q", resp.Model, req.Model)
+	}
+}
@@ -0,0 +1,20 @@
+package providers
+
+import "strings"
+
+// noSamplingPrefixes are model-name prefixes of reasoning models that
+// reject the sampling parameters temperature and top_p: OpenAI's o-series.
+var noSamplingPrefixes = []string{"o1", "o3", "o4"}
+
+// SupportsSampling reports whether model accepts temperature and top_p.
+// Azure aliases ("azure-o3-mini") are matched by their deployment name.
+// Models not known to reject them are assumed to accept them.
+func SupportsSampling(model string) bool {
+	model = strings.TrimPrefix(model, "azure-")
+	for _, prefix := range noSamplingPrefixes {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package providers
+
+import "testing"
+
+func TestSupportsSampling(t *testing.T) {
+	for model, want := range map[string]bool{
+		"gpt-4o":            true,
+		"gpt-4.1-mini":      true,
+		"claude-sonnet-4-5": true,
+		"o1":                false,
+		"o1-mini":           false,
+		"o3-mini":           false,
+		"o4-mini":           false,
+		"azure-o1":          false,
+		"azure-o3-mini":     false,
+		"azure-gpt-4o":      true,
+		"o10-preview":       true,
+		"gemini-2.5-flash":  true,
+	} {
+		if got := SupportsSampling(model); got != want {
+			t.Errorf("SupportsSampling(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package providers
+
+// ContextWindows maps chat model names to their context window in tokens —
+// the combined budget for the prompt and the completion. It backs the
+// gateway's optional pre-flight context length check; models missing from
+// it are never rejected.
+var ContextWindows = map[string]int{
+	// OpenAI
+	"gpt-4":                  8192,
+	"gpt-4-0613":             8192,
+	"gpt-4o":                 128000,
+	"gpt-4o-2024-11-20":      128000,
+	"gpt-4o-2024-08-06":      128000,
+	"gpt-4o-2024-05-13":      128000,
+	"gpt-4o-mini":            128000,
+	"gpt-4o-mini-2024-07-18": 128000,
+	"gpt-4-turbo":            128000,
+	"gpt-4-turbo-2024-04-09": 128000,
+	"gpt-4-turbo-preview":    128000,
+	"gpt-3.5-turbo":          16385,
+	"gpt-3.5-turbo-0125":     16385,
+	"gpt-3.5-turbo-1106":     16385,
+	"o1":                     200000,
+	"o1-mini":                128000,
+	"o1-preview":             128000,
+	"o1-2024-12-17":          200000,
+	"o3":                     200000,
+	"o3-mini":                200000,
+	"o3-mini-2025-01-31":     200000,
+	"o4-mini":                200000,
+	"gpt-4.1":                1047576,
+	"gpt-4.1-mini":           1047576,
+	"gpt-4.1-nano":           1047576,
+
+	// Anthropic
+	"claude-3-5-sonnet":          200000,
+	"claude-3-5-sonnet-20241022": 200000,
+	"claude-3-5-haiku":           200000,
+	"claude-3-5-haiku-20241022":  200000,
+	"claude-3-opus":              200000,
+	"claude-3-opus-20240229":     200000,
+	"claude-3-haiku":             200000,
+	"claude-3-haiku-20240307":    200000,
+	"claude-3-sonnet-20240229":   200000,
+	"claude-3-7-sonnet-20250219": 200000,
+	"claude-3-7-sonnet":          200000,
+	"claude-opus-4":              200000,
+	"claude-sonnet-4":            200000,
+
+	// Google Gemini
+	"gemini-1.5-pro":        2097152,
+	"gemini-1.5-pro-002":    2097152,
+	"gemini-1.5-flash":      1048576,
+	"gemini-1.5-flash-002":  1048576,
+	"gemini-1.5-flash-8b":   1048576,
+	"gemini-2.0-flash":      1048576,
+	"gemini-2.0-flash-lite": 1048576,
+	"gemini-2.5-pro":        1048576,
+	"gemini-2.5-flash":      1048576,
+
+	// Mistral
+	"mistral-large-latest": 131072,
+	"mistral-large-2411":   131072,
+	"mistral-small-latest": 32768,
+}
+
+// ApplyContextWindows merges user-configured model → window sizes over
+// ContextWindows. It is not safe for concurrent use and must run before
+// serving requests.
+func ApplyContextWindows(overrides map[string]int) {
+	for model, tokens := range overrides {
+		ContextWindows[model] = tokens
+	}
+}
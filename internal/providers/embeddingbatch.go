@@ -0,0 +1,20 @@
+package providers
+
+// EmbeddingBatchSizes maps provider names to the most inputs their embeddings
+// API accepts in one request. The gateway splits larger batches into chunks
+// of this size; providers missing from it receive every batch whole.
+var EmbeddingBatchSizes = map[string]int{
+	"openai":   2048,
+	"azure":    2048,
+	"gemini":   100,
+	"vertexai": 250,
+}
+
+// ApplyEmbeddingBatchSizes merges user-configured provider → batch sizes over
+// EmbeddingBatchSizes. It is not safe for concurrent use and must run before
+// serving requests.
+func ApplyEmbeddingBatchSizes(overrides map[string]int) {
+	for provider, size := range overrides {
+		EmbeddingBatchSizes[provider] = size
+	}
+}
@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error is the structured error every provider adapter returns for a failed
+// upstream call or a request it refuses before calling upstream. It
+// implements StatusCoder and RetryAfterer, so callers that only need the
+// status or the backoff hint keep working through those interfaces.
+type Error struct {
+	// Provider is the name of the provider that produced the error.
+	Provider   string
+	StatusCode int
+	Message    string
+	// Type and Code are the provider's error type and code, when it sends
+	// them.
+	Type string
+	Code string
+	// RetryAfter is the upstream Retry-After hint (0 if absent).
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("%s: %s (status=%d)", e.Provider, e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (status=%d, type=%s)", e.Provider, e.Message, e.StatusCode, e.Type)
+}
+
+// HTTPStatus implements StatusCoder.
+func (e *Error) HTTPStatus() int { return e.StatusCode }
+
+// RetryAfterDuration implements RetryAfterer.
+func (e *Error) RetryAfterDuration() time.Duration { return e.RetryAfter }
+
+// AsError returns the provider error in err's chain. Errors that only
+// implement StatusCoder (and optionally RetryAfterer) are adapted into an
+// Error carrying err's text as the message. ok is false when err carries no
+// HTTP status.
+func AsError(err error) (*Error, bool) {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe, true
+	}
+	var sc StatusCoder
+	if !errors.As(err, &sc) {
+		return nil, false
+	}
+	pe = &Error{StatusCode: sc.HTTPStatus(), Message: err.Error()}
+	var ra RetryAfterer
+	if errors.As(err, &ra) {
+		pe.RetryAfter = ra.RetryAfterDuration()
+	}
+	return pe, true
+}
@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type statusOnlyError struct{ status int }
+
+func (e statusOnlyError) Error() string   { return fmt.Sprintf("status %d", e.status) }
+func (e statusOnlyError) HTTPStatus() int { return e.status }
+
+func TestError_Error(t *testing.T) {
+	e := &Error{Provider: "openai", StatusCode: 429, Message: "slow down", Type: "rate_limit_error"}
+	if got, want := e.Error(), "openai: slow down (status=429, type=rate_limit_error)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	e.Type = ""
+	if got, want := e.Error(), "openai: slow down (status=429)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestAsError(t *testing.T) {
+	pe := &Error{Provider: "anthropic", StatusCode: 529, RetryAfter: time.Second}
+	got, ok := AsError(fmt.Errorf("all providers failed: %w", pe))
+	if !ok || got != pe {
+		t.Errorf("AsError(wrapped) = %v, %v; want the wrapped *Error", got, ok)
+	}
+
+	got, ok = AsError(fmt.Errorf("embed: %w", statusOnlyError{status: 404}))
+	if !ok || got.StatusCode != 404 || !strings.Contains(got.Message, "status 404") {
+		t.Errorf("AsError(StatusCoder) = %+v, %v; want status 404", got, ok)
+	}
+
+	if _, ok := AsError(fmt.Errorf("dial tcp: refused")); ok {
+		t.Error("AsError(plain error) reported a provider error")
+	}
+}
@@ -0,0 +1,59 @@
+package providers
+
+import "strings"
+
+// OpenAI finish reasons: the only values clients see in "finish_reason".
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool_calls"
+	FinishContentFilter = "content_filter"
+)
+
+// finishReasons maps provider-native stop reasons, lower-cased, to their
+// OpenAI equivalent.
+var finishReasons = map[string]string{
+	// OpenAI and OpenAI-compatible APIs.
+	"stop":           FinishStop,
+	"length":         FinishLength,
+	"tool_calls":     FinishToolCalls,
+	"function_call":  FinishToolCalls,
+	"content_filter": FinishContentFilter,
+
+	// Anthropic stop_reason and Bedrock Converse stopReason.
+	"end_turn":             FinishStop,
+	"stop_sequence":        FinishStop,
+	"pause_turn":           FinishStop,
+	"max_tokens":           FinishLength,
+	"tool_use":             FinishToolCalls,
+	"refusal":              FinishContentFilter,
+	"guardrail_intervened": FinishContentFilter,
+	"content_filtered":     FinishContentFilter,
+
+	// Gemini and Vertex AI finishReason.
+	"model_context_window_exceeded": FinishLength,
+	"safety":                        FinishContentFilter,
+	"recitation":                    FinishContentFilter,
+	"blocklist":                     FinishContentFilter,
+	"prohibited_content":            FinishContentFilter,
+	"spii":                          FinishContentFilter,
+	"image_safety":                  FinishContentFilter,
+
+	// Mistral.
+	"model_length": FinishLength,
+}
+
+// NormalizeFinishReason maps a provider-native stop reason (Anthropic
+// "end_turn", Gemini "STOP", Bedrock "max_tokens", …) to the OpenAI value
+// "stop", "length", "tool_calls" or "content_filter". The empty string stays
+// empty; any other unrecognised reason becomes "stop", so strict clients
+// never see a value outside the OpenAI enum.
+func NormalizeFinishReason(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	if r, ok := finishReasons[strings.ToLower(reason)]; ok {
+		return r
+	}
+	return FinishStop
+}
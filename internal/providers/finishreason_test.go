@@ -0,0 +1,29 @@
+package providers
+
+import "testing"
+
+func TestNormalizeFinishReason(t *testing.T) {
+	for reason, want := range map[string]string{
+		"":                     "",
+		"stop":                 "stop",
+		"length":               "length",
+		"tool_calls":           "tool_calls",
+		"content_filter":       "content_filter",
+		"end_turn":             "stop",
+		"stop_sequence":        "stop",
+		"max_tokens":           "length",
+		"tool_use":             "tool_calls",
+		"guardrail_intervened": "content_filter",
+		"content_filtered":     "content_filter",
+		"STOP":                 "stop",
+		"MAX_TOKENS":           "length",
+		"SAFETY":               "content_filter",
+		"RECITATION":           "content_filter",
+		"model_length":         "length",
+		"FINISH_REASON_OTHER":  "stop",
+	} {
+		if got := NormalizeFinishReason(reason); got != want {
+			t.Errorf("NormalizeFinishReason(%q) = %q, want %q", reason, got, want)
+		}
+	}
+}
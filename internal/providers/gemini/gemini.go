@@ -2,12 +2,17 @@ package gemini
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
+	"time"
 
 	"google.golang.org/genai"
 
@@ -50,7 +55,7 @@ func New(ctx context.Context, apiKey string, opts ...Option) *Provider {
 		o(p)
 	}
 
-	httpClient := &http.Client{Timeout: providers.ProviderTimeout}
+	httpClient := providers.NewHTTPClient()
 	p.httpClient = httpClient
 
 	base, ver := splitBaseURLAndVersion(p.baseURL)
@@ -83,7 +88,19 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
-	contents, cfg := p.buildContentsAndConfig(req)
+	if providers.WantsLogProbs(req) {
+		return nil, &providers.Error{
+			Provider:   "gemini",
+			StatusCode: 400,
+			Message:    "logprobs are not supported",
+			Type:       "INVALID_ARGUMENT",
+		}
+	}
+
+	contents, cfg, err := BuildContentsAndConfig(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: %w", err)
+	}
 
 	client, err := p.clientForKey(ctx, req.APIKey)
 	if err != nil {
@@ -96,9 +113,15 @@ func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*p
 	return p.handleResponse(ctx, client, req, contents, cfg)
 }
 
-func (p *Provider) buildContentsAndConfig(req *providers.ProxyRequest) ([]*genai.Content, *genai.GenerateContentConfig) {
+// BuildContentsAndConfig translates req into genai contents and generation
+// config. It is shared with the Vertex AI provider, which talks to the same
+// API through the same SDK.
+func BuildContentsAndConfig(req *providers.ProxyRequest) ([]*genai.Content, *genai.GenerateContentConfig, error) {
 	var systemPrompt string
 	contents := make([]*genai.Content, 0, len(req.Messages))
+	// Gemini function responses are matched by name, OpenAI tool results by
+	// call ID; remember which function each call ID invoked.
+	callNames := make(map[string]string)
 
 	for _, m := range req.Messages {
 		switch strings.ToLower(m.Role) {
@@ -109,18 +132,32 @@ func (p *Provider) buildContentsAndConfig(req *providers.ProxyRequest) ([]*genai
 			systemPrompt += m.Content
 
 		case "assistant":
+			if len(m.ToolCalls) > 0 {
+				contents = append(contents, genai.NewContentFromParts(toFunctionCallParts(m, callNames), genai.RoleModel))
+				continue
+			}
 			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleModel))
 
+		case "tool":
+			part := genai.NewPartFromFunctionResponse(callNames[m.ToolCallID], functionResponse(m.Content))
+			part.FunctionResponse.ID = m.ToolCallID
+			contents = append(contents, genai.NewContentFromParts([]*genai.Part{part}, genai.RoleUser))
+
 		case "model":
 			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleModel))
 
 		default: // user / unknown
+			if len(m.Parts) > 0 {
+				contents = append(contents, genai.NewContentFromParts(toGenaiParts(m.Parts), genai.RoleUser))
+				continue
+			}
 			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleUser))
 		}
 	}
 
 	var cfg *genai.GenerateContentConfig
-	if systemPrompt != "" || req.Temperature > 0 || req.MaxTokens > 0 {
+	if systemPrompt != "" || req.Temperature > 0 || req.MaxTokens > 0 || req.TopP != nil || len(req.Stop) > 0 ||
+		len(req.Tools) > 0 || len(req.ToolChoice) > 0 || len(req.ResponseFormat) > 0 {
 		cfg = &genai.GenerateContentConfig{}
 	}
 
@@ -138,7 +175,106 @@ func (p *Provider) buildContentsAndConfig(req *providers.ProxyRequest) ([]*genai
 		cfg.MaxOutputTokens = int32(req.MaxTokens)
 	}
 
-	return contents, cfg
+	if cfg != nil && req.TopP != nil {
+		cfg.TopP = genai.Ptr[float32](float32(*req.TopP))
+	}
+
+	if cfg != nil && len(req.Stop) > 0 {
+		cfg.StopSequences = req.Stop
+	}
+
+	tools, err := providers.ParseFunctionTools(req.Tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tools) > 0 {
+		decls := make([]*genai.FunctionDeclaration, len(tools))
+		for i, t := range tools {
+			decls[i] = &genai.FunctionDeclaration{Name: t.Name, Description: t.Description}
+			if len(t.Parameters) > 0 {
+				decls[i].ParametersJsonSchema = t.Parameters
+			}
+		}
+		cfg.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	}
+
+	if len(req.ToolChoice) > 0 {
+		mode, name, err := providers.ParseToolChoice(req.ToolChoice)
+		if err != nil {
+			return nil, nil, err
+		}
+		fc := &genai.FunctionCallingConfig{}
+		switch mode {
+		case providers.ToolChoiceAuto:
+			fc.Mode = genai.FunctionCallingConfigModeAuto
+		case providers.ToolChoiceNone:
+			fc.Mode = genai.FunctionCallingConfigModeNone
+		case providers.ToolChoiceRequired:
+			fc.Mode = genai.FunctionCallingConfigModeAny
+		case providers.ToolChoiceFunction:
+			fc.Mode = genai.FunctionCallingConfigModeAny
+			fc.AllowedFunctionNames = []string{name}
+		}
+		cfg.ToolConfig = &genai.ToolConfig{FunctionCallingConfig: fc}
+	}
+
+	if err := applyResponseFormat(cfg, req.ResponseFormat); err != nil {
+		return nil, nil, err
+	}
+
+	return contents, cfg, nil
+}
+
+// toFunctionCallParts converts an assistant turn's tool calls into
+// functionCall parts, recording each call's function name in callNames.
+func toFunctionCallParts(m providers.Message, callNames map[string]string) []*genai.Part {
+	parts := make([]*genai.Part, 0, len(m.ToolCalls)+1)
+	if m.Content != "" {
+		parts = append(parts, genai.NewPartFromText(m.Content))
+	}
+	for _, c := range m.ToolCalls {
+		var args map[string]any
+		_ = json.Unmarshal([]byte(c.Arguments), &args)
+		part := genai.NewPartFromFunctionCall(c.Name, args)
+		part.FunctionCall.ID = c.ID
+		parts = append(parts, part)
+		callNames[c.ID] = c.Name
+	}
+	return parts
+}
+
+// functionResponse wraps a tool result for Gemini, which wants an object:
+// JSON objects are passed as-is, anything else under "output".
+func functionResponse(content string) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(content), &obj); err == nil && obj != nil {
+		return obj
+	}
+	return map[string]any{"output": content}
+}
+
+// FunctionCalls extracts the function calls of a candidate. Gemini may
+// omit call IDs, so missing ones are generated.
+func FunctionCalls(c *genai.Candidate) []providers.ToolCall {
+	if c == nil || c.Content == nil {
+		return nil
+	}
+	var out []providers.ToolCall
+	for _, part := range c.Content.Parts {
+		if part == nil || part.FunctionCall == nil {
+			continue
+		}
+		args, _ := json.Marshal(part.FunctionCall.Args)
+		if part.FunctionCall.Args == nil {
+			args = []byte("{}")
+		}
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%x", rand.Int63())
+		}
+		out = append(out, providers.ToolCall{ID: id, Name: part.FunctionCall.Name, Arguments: string(args)})
+	}
+	return out
 }
 
 func (p *Provider) handleResponse(
@@ -162,9 +298,14 @@ func (p *Provider) handleResponse(
 		}
 	}
 
-	out := ""
+	out, finish := "", ""
+	var toolCalls []providers.ToolCall
 	if resp != nil {
 		out = resp.Text()
+		if len(resp.Candidates) > 0 && resp.Candidates[0] != nil {
+			toolCalls = FunctionCalls(resp.Candidates[0])
+			finish = string(resp.Candidates[0].FinishReason)
+		}
 	}
 
 	var inTok, outTok int
@@ -174,9 +315,11 @@ func (p *Provider) handleResponse(
 	}
 
 	return &providers.ProxyResponse{
-		ID:      id,
-		Model:   req.Model,
-		Content: out,
+		ID:           id,
+		Model:        req.Model,
+		Content:      out,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
 		Usage: providers.Usage{
 			InputTokens:  inTok,
 			OutputTokens: outTok,
@@ -196,20 +339,27 @@ func (p *Provider) handleStreaming(
 	go func() {
 		defer close(ch)
 
+		// usageMetadata is cumulative; the last one seen is reported once the
+		// stream ends.
+		var usage *providers.Usage
+
 		for resp, err := range client.Models.GenerateContentStream(ctx, model, contents, cfg) {
 			if err != nil {
-				ch <- providers.StreamChunk{
-					Content:      fmt.Sprintf("[stream error] %v", err),
-					FinishReason: "error",
-				}
+				ch <- providers.StreamChunk{Err: err}
 				return
 			}
+			if resp != nil && resp.UsageMetadata != nil {
+				usage = &providers.Usage{
+					InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
+					OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+				}
+			}
 			if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
 				continue
 			}
 
 			c := resp.Candidates[0]
-			text := firstCandidateText(c)
+			text := CandidateText(c)
 			finish := ""
 			if c.FinishReason != "" {
 				finish = string(c.FinishReason)
@@ -222,6 +372,9 @@ func (p *Provider) handleStreaming(
 				}
 			}
 		}
+		if usage != nil {
+			ch <- providers.StreamChunk{Usage: usage}
+		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
@@ -240,7 +393,7 @@ func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (
 		return nil, err
 	}
 
-	resp, err := client.Models.EmbedContent(ctx, req.Model, contents, nil)
+	resp, err := client.Models.EmbedContent(ctx, req.Model, contents, embedConfig(req.Dimensions))
 	if err != nil {
 		return nil, fmt.Errorf("gemini: embed: %w", toProviderError(err))
 	}
@@ -265,6 +418,15 @@ func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (
 	}, nil
 }
 
+// embedConfig forwards the requested output dimensionality, if any.
+func embedConfig(dimensions *int) *genai.EmbedContentConfig {
+	if dimensions == nil {
+		return nil
+	}
+	n := int32(*dimensions)
+	return &genai.EmbedContentConfig{OutputDimensionality: &n}
+}
+
 func (p *Provider) clientForKey(ctx context.Context, overrideKey string) (*genai.Client, error) {
 	key := overrideKey
 	if key == "" {
@@ -288,7 +450,8 @@ func (p *Provider) clientForKey(ctx context.Context, overrideKey string) (*genai
 	return client, nil
 }
 
-func firstCandidateText(c *genai.Candidate) string {
+// CandidateText concatenates the text parts of a candidate.
+func CandidateText(c *genai.Candidate) string {
 	if c == nil || c.Content == nil || len(c.Content.Parts) == 0 {
 		return ""
 	}
@@ -349,30 +512,90 @@ func generateID() string {
 	return fmt.Sprintf("gemini-%x", rand.Int63())
 }
 
-// ProviderError is a structured error returned by the Gemini API (SDK wrapper).
-type ProviderError struct {
-	StatusCode int
-	Message    string
-	Type       string
-	Code       string
+// toGenaiParts maps multimodal parts onto genai parts: data URLs become
+// inlineData, remote URLs fileData with a MIME type guessed from the path.
+// Undecodable data URLs are dropped.
+func toGenaiParts(parts []providers.ContentPart) []*genai.Part {
+	out := make([]*genai.Part, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case providers.ContentPartImageURL:
+			if mediaType, data, ok := providers.ParseDataURL(part.ImageURL); ok {
+				raw, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					continue
+				}
+				out = append(out, genai.NewPartFromBytes(raw, mediaType))
+				continue
+			}
+			out = append(out, genai.NewPartFromURI(part.ImageURL, imageMIMEType(part.ImageURL)))
+		default:
+			out = append(out, genai.NewPartFromText(part.Text))
+		}
+	}
+	return out
 }
 
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("gemini: %s (status=%d, type=%s)", e.Message, e.StatusCode, e.Type)
+// imageMIMEType guesses an image's MIME type from its URL path, defaulting
+// to JPEG.
+func imageMIMEType(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+	return "image/jpeg"
 }
 
-// HTTPStatus implements providers.StatusCoder.
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func toProviderError(err error) error {
 	var apiErr genai.APIError
 	if errors.As(err, &apiErr) {
-		return &ProviderError{
+		return &providers.Error{
+			Provider:   "gemini",
 			StatusCode: apiErr.Code,
 			Message:    apiErr.Message,
 			Type:       apiErr.Status,
 			Code:       fmt.Sprintf("%d", apiErr.Code),
+			RetryAfter: retryInfoDelay(apiErr.Details),
 		}
 	}
 	return err
 }
+
+// retryInfoDelay extracts the retryDelay from a google.rpc.RetryInfo error
+// detail. Google APIs send the backoff hint there rather than as a
+// Retry-After header, which the genai SDK does not expose anyway.
+func retryInfoDelay(details []map[string]any) time.Duration {
+	for _, d := range details {
+		if t, _ := d["@type"].(string); !strings.HasSuffix(t, "google.rpc.RetryInfo") {
+			continue
+		}
+		if s, _ := d["retryDelay"].(string); s != "" {
+			if dur, err := time.ParseDuration(s); err == nil && dur > 0 {
+				return dur
+			}
+		}
+	}
+	return 0
+}
+
+// applyResponseFormat translates an OpenAI-format response_format onto cfg:
+// json_object and json_schema both request an application/json response,
+// and json_schema additionally constrains it with the client's schema.
+func applyResponseFormat(cfg *genai.GenerateContentConfig, raw json.RawMessage) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	kind, schema, err := providers.ParseResponseFormat(raw)
+	if err != nil {
+		return err
+	}
+	if kind == providers.ResponseFormatText {
+		return nil
+	}
+	cfg.ResponseMIMEType = "application/json"
+	if len(schema) > 0 {
+		cfg.ResponseJsonSchema = schema
+	}
+	return nil
+}
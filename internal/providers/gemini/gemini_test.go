@@ -232,9 +232,12 @@ func TestProvider_Request_RateLimit(t *testing.T) {
 		t.Fatal("expected error for 429, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
+	}
+	if provErr.Provider != "gemini" {
+		t.Errorf("expected provider 'gemini', got %q", provErr.Provider)
 	}
 	if provErr.StatusCode != http.StatusTooManyRequests {
 		t.Errorf("expected status 429, got %d", provErr.StatusCode)
@@ -265,9 +268,9 @@ func TestProvider_Request_ServerError(t *testing.T) {
 		t.Fatal("expected error for 500, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
 	}
 	if provErr.StatusCode != http.StatusInternalServerError {
 		t.Errorf("expected status 500, got %d", provErr.StatusCode)
@@ -281,7 +284,7 @@ func TestProvider_Request_Streaming(t *testing.T) {
 	chunks := []string{
 		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"finishReason":""}]}`,
 		`{"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]},"finishReason":""}]}`,
-		`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"STOP"}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2,"totalTokenCount":6}}`,
 	}
 
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -324,11 +327,15 @@ func TestProvider_Request_Streaming(t *testing.T) {
 
 	var content string
 	var finalReason string
+	var usage *providers.Usage
 	for chunk := range resp.Stream {
 		content += chunk.Content
 		if chunk.FinishReason != "" {
 			finalReason = chunk.FinishReason
 		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
 	}
 
 	if content != "Hello world" {
@@ -337,6 +344,31 @@ func TestProvider_Request_Streaming(t *testing.T) {
 	if finalReason != "STOP" {
 		t.Errorf("expected finish reason 'STOP', got %q", finalReason)
 	}
+	if usage == nil || usage.InputTokens != 4 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage {4 2}, got %+v", usage)
+	}
+}
+
+func TestProvider_Request_FinishReason(t *testing.T) {
+	for _, reason := range []string{"MAX_TOKENS", "SAFETY"} {
+		t.Run(reason, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := successResponse("cut")
+				body.Candidates[0].FinishReason = reason
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer srv.Close()
+
+			resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.FinishReason != reason {
+				t.Errorf("FinishReason = %q, want %q", resp.FinishReason, reason)
+			}
+		})
+	}
 }
 
 func TestProvider_Request_NoIDFallback(t *testing.T) {
@@ -382,6 +414,7 @@ func TestProvider_Request_GenerationConfig(t *testing.T) {
 	req := baseRequest()
 	req.Temperature = 0.7
 	req.MaxTokens = 1000
+	req.Stop = []string{"END"}
 
 	p := newTestProvider(srv)
 	if p == nil {
@@ -402,6 +435,60 @@ func TestProvider_Request_GenerationConfig(t *testing.T) {
 	if capturedBody.GenerationConfig.MaxOutputTokens == nil || *capturedBody.GenerationConfig.MaxOutputTokens != 1000 {
 		t.Errorf("expected maxOutputTokens 1000, got %v", capturedBody.GenerationConfig.MaxOutputTokens)
 	}
+	if len(capturedBody.GenerationConfig.StopSequences) != 1 || capturedBody.GenerationConfig.StopSequences[0] != "END" {
+		t.Errorf("expected stopSequences [END], got %v", capturedBody.GenerationConfig.StopSequences)
+	}
+}
+
+func TestProvider_Request_ResponseFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		wantMIME   string
+		wantSchema bool
+	}{
+		{name: "text", format: `{"type":"text"}`},
+		{name: "json_object", format: `{"type":"json_object"}`, wantMIME: "application/json"},
+		{
+			name:       "json_schema",
+			format:     `{"type":"json_schema","json_schema":{"name":"city","schema":{"type":"object"}}}`,
+			wantMIME:   "application/json",
+			wantSchema: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capturedBody generateRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&capturedBody); err != nil {
+					t.Errorf("failed to decode request body: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(successResponse(`{"name":"Paris"}`))
+			}))
+			defer srv.Close()
+
+			req := baseRequest()
+			req.ResponseFormat = json.RawMessage(tt.format)
+
+			p := newTestProvider(srv)
+			if _, err := p.Request(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var gc generationConfig
+			if capturedBody.GenerationConfig != nil {
+				gc = *capturedBody.GenerationConfig
+			}
+			if gc.ResponseMIMEType != tt.wantMIME {
+				t.Errorf("responseMimeType = %q, want %q", gc.ResponseMIMEType, tt.wantMIME)
+			}
+			if (gc.ResponseJSONSchema != nil) != tt.wantSchema {
+				t.Errorf("responseJsonSchema = %s, want present=%v", gc.ResponseJSONSchema, tt.wantSchema)
+			}
+		})
+	}
 }
 
 func TestProvider_Request_NoGenerationConfig_WhenZero(t *testing.T) {
@@ -443,7 +530,8 @@ func TestProvider_Request_NoGenerationConfig_WhenZero(t *testing.T) {
 }
 
 func TestProviderError_Error(t *testing.T) {
-	e := &ProviderError{
+	e := &providers.Error{
+		Provider:   "gemini",
 		StatusCode: 429,
 		Message:    "Rate limit exceeded",
 		Type:       "RESOURCE_EXHAUSTED",
@@ -467,8 +555,11 @@ type generateRequest struct {
 }
 
 type generationConfig struct {
-	Temperature     *float32 `json:"temperature,omitempty"`
-	MaxOutputTokens *int32   `json:"maxOutputTokens,omitempty"`
+	Temperature        *float32        `json:"temperature,omitempty"`
+	MaxOutputTokens    *int32          `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	ResponseMIMEType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
 }
 
 type generateResponse struct {
@@ -0,0 +1,34 @@
+package providers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// rateLimitHeaderPrefixes select the upstream response headers that
+// RateLimitHeaders keeps: OpenAI-style x-ratelimit-*, Anthropic's
+// anthropic-ratelimit-*, and retry-after / retry-after-ms.
+var rateLimitHeaderPrefixes = []string{"x-ratelimit-", "anthropic-ratelimit-", "retry-after"}
+
+// RateLimitHeaders returns the rate-limit headers of an upstream response,
+// keyed by lower-case name, for ProxyResponse.Headers. It returns nil when
+// resp is nil or carries none.
+func RateLimitHeaders(resp *http.Response) map[string]string {
+	if resp == nil {
+		return nil
+	}
+	var out map[string]string
+	for name, values := range resp.Header {
+		lower := strings.ToLower(name)
+		for _, prefix := range rateLimitHeaderPrefixes {
+			if strings.HasPrefix(lower, prefix) && len(values) > 0 {
+				if out == nil {
+					out = make(map[string]string)
+				}
+				out[lower] = values[0]
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package providers
+
+import "strings"
+
+// bedrockProfileGeos are the geography prefixes of Bedrock cross-region
+// inference profile IDs, e.g. "us." in
+// "us.anthropic.claude-3-7-sonnet-20250219-v1:0".
+var bedrockProfileGeos = []string{"us.", "us-gov.", "eu.", "apac.", "jp.", "au.", "ca.", "global."}
+
+// bedrockVendors are the model-provider prefixes of Bedrock model IDs.
+var bedrockVendors = []string{
+	"anthropic.", "meta.", "amazon.", "mistral.", "ai21.", "cohere.", "deepseek.", "writer.",
+}
+
+// IsBedrockInferenceProfile reports whether model names a Bedrock inference
+// profile: a cross-region profile ID (a geography prefix followed by a
+// Bedrock model ID, e.g. "us.anthropic.claude-3-7-sonnet-20250219-v1:0") or
+// a Bedrock ARN such as
+// "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.meta.llama3-3-70b-instruct-v1:0".
+func IsBedrockInferenceProfile(model string) bool {
+	if strings.HasPrefix(model, "arn:aws") && strings.Contains(model, ":bedrock:") {
+		return true
+	}
+	for _, geo := range bedrockProfileGeos {
+		rest, ok := strings.CutPrefix(model, geo)
+		if !ok {
+			continue
+		}
+		for _, vendor := range bedrockVendors {
+			if strings.HasPrefix(rest, vendor) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyPool spreads requests for one provider across several API keys,
+// round-robin. A key whose request fails with 401 or 429 is benched for a
+// cooldown (or the upstream Retry-After, if longer) and skipped meanwhile.
+// It is safe for concurrent use.
+type KeyPool struct {
+	keys     []string
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	next    int
+	benched []time.Time // per key; zero when usable
+}
+
+// NewKeyPool returns a pool over keys that benches failing keys for cooldown.
+func NewKeyPool(keys []string, cooldown time.Duration) *KeyPool {
+	return &KeyPool{
+		keys:     keys,
+		cooldown: cooldown,
+		now:      time.Now,
+		benched:  make([]time.Time, len(keys)),
+	}
+}
+
+// Pick returns the next key that is not cooling down. When every key is
+// benched it returns the one that recovers soonest rather than failing.
+func (kp *KeyPool) Pick() string {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := kp.now()
+	soonest := -1
+	for i := range kp.keys {
+		idx := (kp.next + i) % len(kp.keys)
+		if !kp.benched[idx].After(now) {
+			kp.next = idx + 1
+			return kp.keys[idx]
+		}
+		if soonest < 0 || kp.benched[idx].Before(kp.benched[soonest]) {
+			soonest = idx
+		}
+	}
+	kp.next = soonest + 1
+	return kp.keys[soonest]
+}
+
+// Report records the outcome of a request made with key, benching it when
+// err is a 401 or 429.
+func (kp *KeyPool) Report(key string, err error) {
+	var sc StatusCoder
+	if !errors.As(err, &sc) {
+		return
+	}
+	status := sc.HTTPStatus()
+	if status != http.StatusUnauthorized && status != http.StatusTooManyRequests {
+		return
+	}
+	cooldown := kp.cooldown
+	var ra RetryAfterer
+	if status == http.StatusTooManyRequests && errors.As(err, &ra) && ra.RetryAfterDuration() > cooldown {
+		cooldown = ra.RetryAfterDuration()
+	}
+
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	for i, k := range kp.keys {
+		if k == key {
+			kp.benched[i] = kp.now().Add(cooldown)
+		}
+	}
+}
+
+// WithKeyPool wraps p so that requests without a client-supplied key are
+// sent with a key from pool. The result implements EmbeddingProvider and
+// RerankProvider when p does.
+func WithKeyPool(p Provider, pool *KeyPool) Provider {
+	kp := &keyPoolProvider{Provider: p, pool: pool}
+	ep, embeds := p.(EmbeddingProvider)
+	rp, reranks := p.(RerankProvider)
+	switch {
+	case embeds && reranks:
+		return &keyPoolEmbedReranker{
+			keyPoolEmbedder: &keyPoolEmbedder{keyPoolProvider: kp, embedder: ep},
+			reranker:        &keyPoolReranker{keyPoolProvider: kp, reranker: rp},
+		}
+	case embeds:
+		return &keyPoolEmbedder{keyPoolProvider: kp, embedder: ep}
+	case reranks:
+		return &keyPoolReranker{keyPoolProvider: kp, reranker: rp}
+	}
+	return kp
+}
+
+type keyPoolProvider struct {
+	Provider
+	pool *KeyPool
+}
+
+// Unwrap returns the pooled provider, so optional interfaces the wrapper
+// does not forward stay reachable through AsModerationProvider.
+func (p *keyPoolProvider) Unwrap() Provider { return p.Provider }
+
+func (p *keyPoolProvider) Request(ctx context.Context, req *ProxyRequest) (*ProxyResponse, error) {
+	if req.APIKey != "" {
+		return p.Provider.Request(ctx, req)
+	}
+	keyed := *req
+	keyed.APIKey = p.pool.Pick()
+	resp, err := p.Provider.Request(ctx, &keyed)
+	p.pool.Report(keyed.APIKey, err)
+	return resp, err
+}
+
+type keyPoolEmbedder struct {
+	*keyPoolProvider
+	embedder EmbeddingProvider
+}
+
+func (p *keyPoolEmbedder) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if req.APIKey != "" {
+		return p.embedder.Embed(ctx, req)
+	}
+	keyed := *req
+	keyed.APIKey = p.pool.Pick()
+	resp, err := p.embedder.Embed(ctx, &keyed)
+	p.pool.Report(keyed.APIKey, err)
+	return resp, err
+}
+
+type keyPoolReranker struct {
+	*keyPoolProvider
+	reranker RerankProvider
+}
+
+func (p *keyPoolReranker) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	if req.APIKey != "" {
+		return p.reranker.Rerank(ctx, req)
+	}
+	keyed := *req
+	keyed.APIKey = p.pool.Pick()
+	resp, err := p.reranker.Rerank(ctx, &keyed)
+	p.pool.Report(keyed.APIKey, err)
+	return resp, err
+}
+
+// keyPoolEmbedReranker wraps a provider that both embeds and reranks.
+type keyPoolEmbedReranker struct {
+	*keyPoolEmbedder
+	reranker *keyPoolReranker
+}
+
+func (p *keyPoolEmbedReranker) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	return p.reranker.Rerank(ctx, req)
+}
@@ -0,0 +1,183 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type statusErr struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *statusErr) Error() string                     { return http.StatusText(e.status) }
+func (e *statusErr) HTTPStatus() int                   { return e.status }
+func (e *statusErr) RetryAfterDuration() time.Duration { return e.retryAfter }
+
+// keyRecorder answers every request with the error configured for its key.
+type keyRecorder struct {
+	used []string
+	errs map[string]error
+}
+
+func (r *keyRecorder) Name() string                          { return "recorder" }
+func (r *keyRecorder) HealthCheck(ctx context.Context) error { return nil }
+func (r *keyRecorder) Request(ctx context.Context, req *ProxyRequest) (*ProxyResponse, error) {
+	r.used = append(r.used, req.APIKey)
+	if err := r.errs[req.APIKey]; err != nil {
+		return nil, err
+	}
+	return &ProxyResponse{}, nil
+}
+
+func newTestPool(keys ...string) (*KeyPool, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	pool := NewKeyPool(keys, time.Minute)
+	pool.now = func() time.Time { return now }
+	return pool, &now
+}
+
+func TestKeyPool_RotatesKeys(t *testing.T) {
+	pool, _ := newTestPool("k1", "k2", "k3")
+	rec := &keyRecorder{}
+	p := WithKeyPool(rec, pool)
+
+	for range 6 {
+		if _, err := p.Request(context.Background(), &ProxyRequest{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{"k1", "k2", "k3", "k1", "k2", "k3"}
+	for i := range want {
+		if rec.used[i] != want[i] {
+			t.Fatalf("keys used = %v, want %v", rec.used, want)
+		}
+	}
+}
+
+func TestKeyPool_SkipsRateLimitedKeyForCooldown(t *testing.T) {
+	pool, now := newTestPool("k1", "k2")
+	rec := &keyRecorder{errs: map[string]error{"k1": &statusErr{status: http.StatusTooManyRequests}}}
+	p := WithKeyPool(rec, pool)
+
+	if _, err := p.Request(context.Background(), &ProxyRequest{}); err == nil {
+		t.Fatal("expected the k1 request to fail")
+	}
+	delete(rec.errs, "k1")
+	rec.used = nil
+	for range 3 {
+		_, _ = p.Request(context.Background(), &ProxyRequest{})
+	}
+	for _, k := range rec.used {
+		if k != "k2" {
+			t.Fatalf("keys used during cooldown = %v, want only k2", rec.used)
+		}
+	}
+
+	*now = now.Add(time.Minute)
+	rec.used = nil
+	_, _ = p.Request(context.Background(), &ProxyRequest{})
+	_, _ = p.Request(context.Background(), &ProxyRequest{})
+	if len(rec.used) != 2 || rec.used[0] == rec.used[1] {
+		t.Errorf("keys used after cooldown = %v, want both keys", rec.used)
+	}
+}
+
+func TestKeyPool_Report(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		bench time.Duration
+	}{
+		{"unauthorized", &statusErr{status: http.StatusUnauthorized}, time.Minute},
+		{"rate limited", &statusErr{status: http.StatusTooManyRequests}, time.Minute},
+		{"longer retry-after", &statusErr{status: http.StatusTooManyRequests, retryAfter: 5 * time.Minute}, 5 * time.Minute},
+		{"server error", &statusErr{status: http.StatusInternalServerError}, 0},
+		{"success", nil, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, now := newTestPool("k1", "k2")
+			pool.Report("k1", tc.err)
+			var want time.Time
+			if tc.bench > 0 {
+				want = now.Add(tc.bench)
+			}
+			if !pool.benched[0].Equal(want) {
+				t.Errorf("k1 benched until %v, want %v", pool.benched[0], want)
+			}
+		})
+	}
+}
+
+func TestKeyPool_AllBenchedPicksSoonest(t *testing.T) {
+	pool, _ := newTestPool("k1", "k2")
+	pool.Report("k1", &statusErr{status: http.StatusTooManyRequests, retryAfter: 10 * time.Minute})
+	pool.Report("k2", &statusErr{status: http.StatusTooManyRequests})
+
+	if got := pool.Pick(); got != "k2" {
+		t.Errorf("Pick() = %q, want k2, which recovers first", got)
+	}
+}
+
+func TestWithKeyPool_ClientKeyWins(t *testing.T) {
+	pool, _ := newTestPool("k1", "k2")
+	rec := &keyRecorder{}
+	_, _ = WithKeyPool(rec, pool).Request(context.Background(), &ProxyRequest{APIKey: "client"})
+
+	if len(rec.used) != 1 || rec.used[0] != "client" {
+		t.Errorf("keys used = %v, want the client key", rec.used)
+	}
+}
+
+// rerankRecorder is a keyRecorder that also reranks.
+type rerankRecorder struct{ keyRecorder }
+
+func (r *rerankRecorder) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	r.used = append(r.used, req.APIKey)
+	return &RerankResponse{}, nil
+}
+
+func TestWithKeyPool_Reranker(t *testing.T) {
+	pool, _ := newTestPool("k1", "k2")
+	rec := &rerankRecorder{}
+	wrapped := WithKeyPool(rec, pool)
+
+	if _, ok := wrapped.(EmbeddingProvider); ok {
+		t.Error("wrapper of a non-embedder must not implement EmbeddingProvider")
+	}
+	rp, ok := wrapped.(RerankProvider)
+	if !ok {
+		t.Fatal("wrapper of a reranker must implement RerankProvider")
+	}
+	for range 2 {
+		_, _ = rp.Rerank(context.Background(), &RerankRequest{})
+	}
+	if len(rec.used) != 2 || rec.used[0] == rec.used[1] {
+		t.Errorf("keys used = %v, want both pool keys in turn", rec.used)
+	}
+}
+
+type moderatorRecorder struct{ keyRecorder }
+
+func (r *moderatorRecorder) Moderate(ctx context.Context, req *ModerationRequest) (*ModerationResponse, error) {
+	return &ModerationResponse{Flagged: true}, nil
+}
+
+func TestAsModerationProvider_ThroughKeyPool(t *testing.T) {
+	pool, _ := newTestPool("k1", "k2")
+	wrapped := WithKeyPool(&moderatorRecorder{}, pool)
+
+	mp, ok := AsModerationProvider(wrapped)
+	if !ok {
+		t.Fatal("pooled moderator not found through the wrapper")
+	}
+	if res, err := mp.Moderate(context.Background(), &ModerationRequest{Input: []string{"x"}}); err != nil || !res.Flagged {
+		t.Errorf("Moderate = %+v, %v; want the wrapped provider's verdict", res, err)
+	}
+	if _, ok := AsModerationProvider(WithKeyPool(&keyRecorder{}, pool)); ok {
+		t.Error("non-moderator reported as a ModerationProvider")
+	}
+}
@@ -0,0 +1,8 @@
+package providers
+
+// WantsLogProbs reports whether req asks for token log-probabilities, i.e.
+// sets logprobs to true. Providers without logprob support reject such
+// requests with a 400 rather than silently dropping the field.
+func WantsLogProbs(req *ProxyRequest) bool {
+	return req.LogProbs != nil && *req.LogProbs
+}
@@ -19,16 +19,36 @@ const (
 )
 
 type chatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []chatMessage `json:"messages"`
-	Stream      bool          `json:"stream,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Model            string          `json:"model"`
+	Messages         []chatMessage   `json:"messages"`
+	Stream           bool            `json:"stream,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	N                int             `json:"n,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	RandomSeed       *int64          `json:"random_seed,omitempty"`
+	Tools            json.RawMessage `json:"tools,omitempty"`
+	ToolChoice       json.RawMessage `json:"tool_choice,omitempty"`
+	ResponseFormat   json.RawMessage `json:"response_format,omitempty"`
 }
 
 type chatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type chatResponse struct {
@@ -40,6 +60,7 @@ type chatResponse struct {
 }
 
 type choice struct {
+	Index        int          `json:"index"`
 	Message      *chatMessage `json:"message,omitempty"`
 	Delta        *chatMessage `json:"delta,omitempty"`
 	FinishReason string       `json:"finish_reason"`
@@ -57,8 +78,9 @@ type apiErr struct {
 }
 
 type embeddingRequest struct {
-	Model string   `json:"model"`
-	Input []string `json:"input"`
+	Model           string   `json:"model"`
+	Input           []string `json:"input"`
+	OutputDimension *int     `json:"output_dimension,omitempty"`
 }
 
 type embeddingData struct {
@@ -96,7 +118,7 @@ func New(apiKey string, opts ...Option) *Provider {
 	p := &Provider{
 		apiKey:  apiKey,
 		baseURL: defaultBaseURL,
-		client:  &http.Client{Timeout: providers.ProviderTimeout},
+		client:  providers.NewHTTPClient(),
 	}
 	for _, o := range opts {
 		o(p)
@@ -126,6 +148,15 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+	if providers.WantsLogProbs(req) {
+		return nil, &providers.Error{
+			Provider:   "mistral",
+			StatusCode: 400,
+			Message:    "logprobs are not supported",
+			Type:       "invalid_request_error",
+		}
+	}
+
 	body, err := p.buildRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("mistral: %w", err)
@@ -167,7 +198,12 @@ func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*p
 func (p *Provider) buildRequest(req *providers.ProxyRequest) ([]byte, error) {
 	msgs := make([]chatMessage, len(req.Messages))
 	for i, m := range req.Messages {
-		msgs[i] = chatMessage{Role: m.Role, Content: m.Content}
+		msgs[i] = chatMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCalls:  toChatToolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
+		}
 	}
 	cr := chatRequest{
 		Model:    req.Model,
@@ -182,6 +218,17 @@ func (p *Provider) buildRequest(req *providers.ProxyRequest) ([]byte, error) {
 	if req.MaxTokens > 0 {
 		cr.MaxTokens = req.MaxTokens
 	}
+	if req.N > 1 {
+		cr.N = req.N
+	}
+	cr.TopP = req.TopP
+	cr.Stop = req.Stop
+	cr.PresencePenalty = req.PresencePenalty
+	cr.FrequencyPenalty = req.FrequencyPenalty
+	cr.RandomSeed = req.Seed
+	cr.Tools = req.Tools
+	cr.ToolChoice = req.ToolChoice
+	cr.ResponseFormat = req.ResponseFormat
 
 	data, err := json.Marshal(cr)
 	if err != nil {
@@ -196,15 +243,37 @@ func (p *Provider) handleResponse(resp *http.Response) (*providers.ProxyResponse
 		return nil, fmt.Errorf("mistral: decode response: %w", err)
 	}
 
-	content := ""
+	content, finish := "", ""
+	var toolCalls []providers.ToolCall
 	if len(cr.Choices) > 0 && cr.Choices[0].Message != nil {
 		content = cr.Choices[0].Message.Content
+		toolCalls = fromChatToolCalls(cr.Choices[0].Message.ToolCalls)
+		finish = cr.Choices[0].FinishReason
+	}
+
+	var choices []providers.Choice
+	if len(cr.Choices) > 1 {
+		choices = make([]providers.Choice, 0, len(cr.Choices))
+		for _, c := range cr.Choices {
+			if c.Message == nil {
+				continue
+			}
+			choices = append(choices, providers.Choice{
+				Index:        c.Index,
+				Content:      c.Message.Content,
+				ToolCalls:    fromChatToolCalls(c.Message.ToolCalls),
+				FinishReason: c.FinishReason,
+			})
+		}
 	}
 
 	return &providers.ProxyResponse{
-		ID:      cr.ID,
-		Model:   cr.Model,
-		Content: content,
+		ID:           cr.ID,
+		Model:        cr.Model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
+		Choices:      choices,
 		Usage: providers.Usage{
 			InputTokens:  cr.Usage.PromptTokens,
 			OutputTokens: cr.Usage.CompletionTokens,
@@ -212,6 +281,33 @@ func (p *Provider) handleResponse(resp *http.Response) (*providers.ProxyResponse
 	}, nil
 }
 
+// toChatToolCalls converts an assistant turn's tool calls to the wire format.
+func toChatToolCalls(calls []providers.ToolCall) []toolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]toolCall, len(calls))
+	for i, c := range calls {
+		out[i].ID = c.ID
+		out[i].Type = "function"
+		out[i].Function.Name = c.Name
+		out[i].Function.Arguments = c.Arguments
+	}
+	return out
+}
+
+// fromChatToolCalls converts response tool calls to the provider form.
+func fromChatToolCalls(calls []toolCall) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = providers.ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments}
+	}
+	return out
+}
+
 func (p *Provider) handleStreaming(resp *http.Response) (*providers.ProxyResponse, error) {
 	ch := make(chan providers.StreamChunk, 64)
 
@@ -243,6 +339,9 @@ func (p *Provider) handleStreaming(resp *http.Response) (*providers.ProxyRespons
 				FinishReason: cr.Choices[0].FinishReason,
 			}
 		}
+		if err := scanner.Err(); err != nil {
+			ch <- providers.StreamChunk{Err: err}
+		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
@@ -251,8 +350,9 @@ func (p *Provider) handleStreaming(resp *http.Response) (*providers.ProxyRespons
 // Embed implements providers.EmbeddingProvider.
 func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
 	body, err := json.Marshal(embeddingRequest{
-		Model: req.Model,
-		Input: req.Input,
+		Model:           req.Model,
+		Input:           req.Input,
+		OutputDimension: req.Dimensions,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("mistral: embed: marshal request: %w", err)
@@ -307,36 +407,25 @@ func (p *Provider) parseError(resp *http.Response) error {
 
 	var cr chatResponse
 	if json.Unmarshal(body, &cr) == nil && cr.Error != nil {
-		return &ProviderError{
+		return &providers.Error{
+			Provider:   "mistral",
 			StatusCode: resp.StatusCode,
 			Message:    cr.Error.Message,
 			Type:       cr.Error.Type,
 			Code:       cr.Error.Code,
+			RetryAfter: providers.ParseRetryAfter(resp.Header.Get("Retry-After")),
 		}
 	}
 
-	return &ProviderError{
+	return &providers.Error{
+		Provider:   "mistral",
 		StatusCode: resp.StatusCode,
 		Message:    fmt.Sprintf("unexpected status %d", resp.StatusCode),
 		Type:       "provider_error",
+		RetryAfter: providers.ParseRetryAfter(resp.Header.Get("Retry-After")),
 	}
 }
 
-type ProviderError struct {
-	StatusCode int
-	Message    string
-	Type       string
-	Code       string
-}
-
-// Error implements the error interface.
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("mistral: %s (status=%d, type=%s)", e.Message, e.StatusCode, e.Type)
-}
-
-// HTTPStatus implements providers.StatusCoder.
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func (p *Provider) effectiveAPIKey(override string) (string, error) {
 	if override != "" {
 		return override, nil
@@ -93,6 +93,69 @@ func TestProvider_Request_Success(t *testing.T) {
 	}
 }
 
+func TestProvider_Request_FinishReason(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chatResponse{
+			ID:    "cmpl-len",
+			Model: "mistral-large-latest",
+			Choices: []choice{
+				{Index: 0, Message: &chatMessage{Role: "assistant", Content: "cut"}, FinishReason: "length"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.FinishReason != "length" {
+		t.Errorf("FinishReason = %q, want length", resp.FinishReason)
+	}
+}
+
+func TestProvider_Request_MultipleChoices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if body.N != 2 {
+			t.Errorf("expected n=2, got %d", body.N)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chatResponse{
+			ID:    "cmpl-n",
+			Model: "mistral-large-latest",
+			Choices: []choice{
+				{Index: 0, Message: &chatMessage{Role: "assistant", Content: "one"}, FinishReason: "stop"},
+				{Index: 1, Message: &chatMessage{Role: "assistant", Content: "two"}, FinishReason: "length"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	req := baseRequest()
+	req.N = 2
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Content != "one" {
+		t.Errorf("expected Content to mirror choice 0, got %q", resp.Content)
+	}
+	if len(resp.Choices) != 2 {
+		t.Fatalf("expected 2 choices, got %d", len(resp.Choices))
+	}
+	if resp.Choices[1].Index != 1 || resp.Choices[1].Content != "two" || resp.Choices[1].FinishReason != "length" {
+		t.Errorf("unexpected second choice: %+v", resp.Choices[1])
+	}
+}
+
 func TestProvider_Request_Streaming(t *testing.T) {
 	chunks := []string{
 		`{"id":"cmpl-1","model":"mistral-large-latest","choices":[{"delta":{"role":"assistant","content":"Bonjour"},"finish_reason":null}]}`,
@@ -169,9 +232,12 @@ func TestProvider_Request_RateLimit(t *testing.T) {
 		t.Fatal("expected error for 429, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
+	}
+	if provErr.Provider != "mistral" {
+		t.Errorf("expected provider 'mistral', got %q", provErr.Provider)
 	}
 	if provErr.StatusCode != http.StatusTooManyRequests {
 		t.Errorf("expected status 429, got %d", provErr.StatusCode)
@@ -206,9 +272,9 @@ func TestProvider_Request_ServerError(t *testing.T) {
 		t.Fatal("expected error for 500, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
 	}
 	if provErr.StatusCode != http.StatusInternalServerError {
 		t.Errorf("expected status 500, got %d", provErr.StatusCode)
@@ -237,6 +303,18 @@ func TestProvider_Request_OnlyIncludesFieldsWhenSet(t *testing.T) {
 		if _, ok := body["stream"]; ok {
 			t.Errorf("stream should not be present when false")
 		}
+		if _, ok := body["top_p"]; ok {
+			t.Errorf("top_p should not be present when omitted")
+		}
+		if _, ok := body["stop"]; ok {
+			t.Errorf("stop should not be present when empty")
+		}
+		if _, ok := body["presence_penalty"]; ok {
+			t.Errorf("presence_penalty should not be present when omitted")
+		}
+		if _, ok := body["frequency_penalty"]; ok {
+			t.Errorf("frequency_penalty should not be present when omitted")
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(chatResponse{
@@ -270,6 +348,9 @@ func TestProvider_Request_IncludesOptionalFieldsWhenSet(t *testing.T) {
 		if maxTok, ok := body["max_tokens"]; !ok || maxTok.(float64) != 512 {
 			t.Errorf("expected max_tokens=512, got %v (present=%v)", maxTok, ok)
 		}
+		if stop, ok := body["stop"].([]interface{}); !ok || len(stop) != 1 || stop[0] != "END" {
+			t.Errorf("expected stop=[END], got %v", body["stop"])
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(chatResponse{
@@ -286,6 +367,39 @@ func TestProvider_Request_IncludesOptionalFieldsWhenSet(t *testing.T) {
 	req := baseRequest()
 	req.Temperature = 0.9
 	req.MaxTokens = 512
+	req.Stop = []string{"END"}
+	_, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Request_TopPZeroIsSent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+
+		if topP, ok := body["top_p"]; !ok || topP.(float64) != 0 {
+			t.Errorf("expected top_p=0 to be sent, got %v (present=%v)", topP, ok)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chatResponse{
+			ID:    "id-3",
+			Model: "mistral-large-latest",
+			Choices: []choice{
+				{Message: &chatMessage{Role: "assistant", Content: "ok"}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	req := baseRequest()
+	zero := 0.0
+	req.TopP = &zero
 	_, err := p.Request(context.Background(), req)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -2,15 +2,18 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 	openaiSDK "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
 )
 
 const (
@@ -40,9 +43,9 @@ func New(apiKey string, opts ...Option) *Provider {
 		o(p)
 	}
 
-	httpClient := &http.Client{Timeout: providers.ProviderTimeout}
+	httpClient := providers.NewHTTPClient()
 	if p.baseURL != "" && p.baseURL != defaultBaseURL {
-		httpClient.Transport = newBaseURLTransport(http.DefaultTransport, p.baseURL)
+		httpClient.Transport = newBaseURLTransport(httpClient.Transport, p.baseURL)
 	}
 
 	p.client = openaiSDK.NewClient(
@@ -83,7 +86,7 @@ func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*p
 func (p *Provider) buildChatCompletionParams(req *providers.ProxyRequest) (openaiSDK.ChatCompletionNewParams, error) {
 	msgs := make([]openaiSDK.ChatCompletionMessageParamUnion, 0, len(req.Messages))
 	for _, m := range req.Messages {
-		msgs = append(msgs, toSDKMessage(m.Role, m.Content))
+		msgs = append(msgs, toSDKMessage(m))
 	}
 
 	params := openaiSDK.ChatCompletionNewParams{
@@ -99,6 +102,60 @@ func (p *Provider) buildChatCompletionParams(req *providers.ProxyRequest) (opena
 		params.MaxCompletionTokens = openaiSDK.Int(int64(req.MaxTokens))
 	}
 
+	if req.TopP != nil {
+		params.TopP = openaiSDK.Float(*req.TopP)
+	}
+
+	if req.N > 1 {
+		params.N = openaiSDK.Int(int64(req.N))
+	}
+
+	if len(req.Stop) > 0 {
+		params.Stop = openaiSDK.ChatCompletionNewParamsStopUnion{OfStringArray: req.Stop}
+	}
+
+	if req.PresencePenalty != nil {
+		params.PresencePenalty = openaiSDK.Float(*req.PresencePenalty)
+	}
+
+	if req.FrequencyPenalty != nil {
+		params.FrequencyPenalty = openaiSDK.Float(*req.FrequencyPenalty)
+	}
+
+	if req.Seed != nil {
+		params.Seed = openaiSDK.Int(*req.Seed)
+	}
+
+	if req.LogProbs != nil {
+		params.Logprobs = openaiSDK.Bool(*req.LogProbs)
+	}
+
+	if req.TopLogProbs != nil {
+		params.TopLogprobs = openaiSDK.Int(int64(*req.TopLogProbs))
+	}
+
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
+	}
+
+	if len(req.Tools) > 0 {
+		if err := json.Unmarshal(req.Tools, &params.Tools); err != nil {
+			return params, fmt.Errorf("invalid tools: %w", err)
+		}
+	}
+
+	if len(req.ToolChoice) > 0 {
+		if err := json.Unmarshal(req.ToolChoice, &params.ToolChoice); err != nil {
+			return params, fmt.Errorf("invalid tool_choice: %w", err)
+		}
+	}
+
+	if len(req.ResponseFormat) > 0 {
+		if err := json.Unmarshal(req.ResponseFormat, &params.ResponseFormat); err != nil {
+			return params, fmt.Errorf("invalid response_format: %w", err)
+		}
+	}
+
 	return params, nil
 }
 
@@ -107,27 +164,63 @@ func (p *Provider) handleResponse(
 	params openaiSDK.ChatCompletionNewParams,
 	opts ...option.RequestOption,
 ) (*providers.ProxyResponse, error) {
-	resp, err := p.client.Chat.Completions.New(ctx, params, opts...)
+	var httpResp *http.Response
+	resp, err := p.client.Chat.Completions.New(ctx, params, append(opts, option.WithResponseInto(&httpResp))...)
 	if err != nil {
 		return nil, toProviderError(err)
 	}
 
-	content := ""
+	content, finish := "", ""
+	var (
+		toolCalls []providers.ToolCall
+		logProbs  json.RawMessage
+	)
 	if len(resp.Choices) > 0 {
 		content = resp.Choices[0].Message.Content
+		toolCalls = fromSDKToolCalls(resp.Choices[0].Message.ToolCalls)
+		logProbs = choiceLogProbs(resp.Choices[0])
+		finish = resp.Choices[0].FinishReason
+	}
+
+	var choices []providers.Choice
+	if len(resp.Choices) > 1 {
+		choices = make([]providers.Choice, len(resp.Choices))
+		for i, c := range resp.Choices {
+			choices[i] = providers.Choice{
+				Index:        int(c.Index),
+				Content:      c.Message.Content,
+				ToolCalls:    fromSDKToolCalls(c.Message.ToolCalls),
+				FinishReason: c.FinishReason,
+				LogProbs:     choiceLogProbs(c),
+			}
+		}
 	}
 
 	return &providers.ProxyResponse{
-		ID:      resp.ID,
-		Model:   resp.Model,
-		Content: content,
+		ID:           resp.ID,
+		Model:        resp.Model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
+		Choices:      choices,
+		LogProbs:     logProbs,
 		Usage: providers.Usage{
 			InputTokens:  int(resp.Usage.PromptTokens),
 			OutputTokens: int(resp.Usage.CompletionTokens),
 		},
+		Headers: providers.RateLimitHeaders(httpResp),
 	}, nil
 }
 
+// choiceLogProbs returns c's "logprobs" object verbatim, or nil when the
+// response carries none.
+func choiceLogProbs(c openaiSDK.ChatCompletionChoice) json.RawMessage {
+	if !c.JSON.Logprobs.Valid() {
+		return nil
+	}
+	return json.RawMessage(c.Logprobs.RawJSON())
+}
+
 func (p *Provider) handleStreaming(
 	ctx context.Context,
 	params openaiSDK.ChatCompletionNewParams,
@@ -135,13 +228,28 @@ func (p *Provider) handleStreaming(
 ) (*providers.ProxyResponse, error) {
 	ch := make(chan providers.StreamChunk, 64)
 
-	stream := p.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	// Ask for the trailing usage chunk so streamed requests are accounted
+	// with real token counts instead of an estimate.
+	params.StreamOptions = openaiSDK.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openaiSDK.Bool(true),
+	}
+
+	var httpResp *http.Response
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params, append(opts, option.WithResponseInto(&httpResp))...)
 
 	go func() {
 		defer close(ch)
 
 		for stream.Next() {
 			chunk := stream.Current()
+			if chunk.JSON.Usage.Valid() {
+				ch <- providers.StreamChunk{
+					Usage: &providers.Usage{
+						InputTokens:  int(chunk.Usage.PromptTokens),
+						OutputTokens: int(chunk.Usage.CompletionTokens),
+					},
+				}
+			}
 			if len(chunk.Choices) == 0 {
 				continue
 			}
@@ -165,14 +273,11 @@ func (p *Provider) handleStreaming(
 		}
 
 		if err := stream.Err(); err != nil {
-			ch <- providers.StreamChunk{
-				Content:      fmt.Sprintf("[stream error] %v", err),
-				FinishReason: "error",
-			}
+			ch <- providers.StreamChunk{Err: err}
 		}
 	}()
 
-	return &providers.ProxyResponse{Stream: ch}, nil
+	return &providers.ProxyResponse{Stream: ch, Headers: providers.RateLimitHeaders(httpResp)}, nil
 }
 
 // Embed implements providers.EmbeddingProvider.
@@ -183,6 +288,9 @@ func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (
 			OfArrayOfStrings: req.Input,
 		},
 	}
+	if req.Dimensions != nil {
+		params.Dimensions = openaiSDK.Int(int64(*req.Dimensions))
+	}
 
 	opts, err := p.requestOptions(req.APIKey)
 	if err != nil {
@@ -215,6 +323,41 @@ func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (
 	}, nil
 }
 
+// Moderate implements providers.ModerationProvider using POST /moderations.
+func (p *Provider) Moderate(ctx context.Context, req *providers.ModerationRequest) (*providers.ModerationResponse, error) {
+	params := openaiSDK.ModerationNewParams{
+		Input: openaiSDK.ModerationNewParamsInputUnion{OfStringArray: req.Input},
+		Model: openaiSDK.ModerationModel(req.Model),
+	}
+
+	opts, err := p.requestOptions("")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Moderations.New(ctx, params, opts...)
+	if err != nil {
+		return nil, toProviderError(err)
+	}
+
+	out := &providers.ModerationResponse{}
+	for _, r := range resp.Results {
+		out.Flagged = out.Flagged || r.Flagged
+		var cats map[string]bool
+		if err := json.Unmarshal([]byte(r.Categories.RawJSON()), &cats); err != nil {
+			continue
+		}
+		for name, flagged := range cats {
+			if flagged {
+				out.Categories = append(out.Categories, name)
+			}
+		}
+	}
+	slices.Sort(out.Categories)
+	out.Categories = slices.Compact(out.Categories)
+	return out, nil
+}
+
 func (p *Provider) requestOptions(overrideKey string) ([]option.RequestOption, error) {
 	key := overrideKey
 	if key == "" {
@@ -226,27 +369,20 @@ func (p *Provider) requestOptions(overrideKey string) ([]option.RequestOption, e
 	return []option.RequestOption{option.WithAPIKey(key)}, nil
 }
 
-type ProviderError struct {
-	StatusCode int
-	Message    string
-	Type       string
-	Code       string
-}
-
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("openai: %s (status=%d, type=%s)", e.Message, e.StatusCode, e.Type)
-}
-
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
-
 func toProviderError(err error) error {
 	var apierr *openaiSDK.Error
 	if errors.As(err, &apierr) {
-		return &ProviderError{
+		pe := &providers.Error{
+			Provider:   "openai",
 			StatusCode: apierr.StatusCode,
 			Message:    apierr.Error(),
-			Type:       "openai_error",
+			Type:       apierr.Type,
+			Code:       apierr.Code,
 		}
+		if apierr.Response != nil {
+			pe.RetryAfter = providers.ParseRetryAfter(apierr.Response.Header.Get("Retry-After"))
+		}
+		return pe
 	}
 	return err
 }
@@ -284,18 +420,80 @@ func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return t.rt.RoundTrip(r2)
 }
 
-func toSDKMessage(role, content string) openaiSDK.ChatCompletionMessageParamUnion {
-	switch strings.ToLower(role) {
+func toSDKMessage(m providers.Message) openaiSDK.ChatCompletionMessageParamUnion {
+	content := m.Content
+	switch strings.ToLower(m.Role) {
 	case "developer":
 		return openaiSDK.DeveloperMessage(content)
 	case "system":
 		return openaiSDK.SystemMessage(content)
 	case "assistant":
 
+		if len(m.ToolCalls) > 0 {
+			return toSDKAssistantToolCalls(m)
+		}
 		return openaiSDK.AssistantMessage(content)
+	case "tool":
+		return openaiSDK.ToolMessage(content, m.ToolCallID)
 	case "user":
 		fallthrough
 	default:
+		if len(m.Parts) > 0 {
+			return openaiSDK.UserMessage(toSDKContentParts(m.Parts))
+		}
 		return openaiSDK.UserMessage(content)
 	}
 }
+
+// toSDKAssistantToolCalls builds an assistant turn that requested tool calls.
+func toSDKAssistantToolCalls(m providers.Message) openaiSDK.ChatCompletionMessageParamUnion {
+	var msg openaiSDK.ChatCompletionAssistantMessageParam
+	if m.Content != "" {
+		msg.Content.OfString = openaiSDK.String(m.Content)
+	}
+	msg.ToolCalls = make([]openaiSDK.ChatCompletionMessageToolCallUnionParam, len(m.ToolCalls))
+	for i, c := range m.ToolCalls {
+		msg.ToolCalls[i] = openaiSDK.ChatCompletionMessageToolCallUnionParam{
+			OfFunction: &openaiSDK.ChatCompletionMessageFunctionToolCallParam{
+				ID: c.ID,
+				Function: openaiSDK.ChatCompletionMessageFunctionToolCallFunctionParam{
+					Name:      c.Name,
+					Arguments: c.Arguments,
+				},
+			},
+		}
+	}
+	return openaiSDK.ChatCompletionMessageParamUnion{OfAssistant: &msg}
+}
+
+// fromSDKToolCalls extracts the function calls of a response message.
+func fromSDKToolCalls(calls []openaiSDK.ChatCompletionMessageToolCallUnion) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, 0, len(calls))
+	for _, c := range calls {
+		if c.Type != "function" {
+			continue
+		}
+		out = append(out, providers.ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return out
+}
+
+// toSDKContentParts maps multimodal parts onto the native content array.
+func toSDKContentParts(parts []providers.ContentPart) []openaiSDK.ChatCompletionContentPartUnionParam {
+	out := make([]openaiSDK.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case providers.ContentPartImageURL:
+			out = append(out, openaiSDK.ImageContentPart(openaiSDK.ChatCompletionContentPartImageImageURLParam{
+				URL:    part.ImageURL,
+				Detail: part.Detail,
+			}))
+		default:
+			out = append(out, openaiSDK.TextContentPart(part.Text))
+		}
+	}
+	return out
+}
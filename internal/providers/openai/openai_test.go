@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 )
@@ -91,15 +92,457 @@ func TestProvider_Request_Success(t *testing.T) {
 	}
 }
 
+func TestProvider_Request_StopSequences(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		stop, ok := body["stop"].([]any)
+		if !ok || len(stop) != 2 || stop[0] != "END" || stop[1] != "STOP" {
+			t.Errorf("expected stop=[END STOP], got %#v", body["stop"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-stop",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "gpt-4o",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": "ok"},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Stop = []string{"END", "STOP"}
+
+	p := newTestProvider(srv)
+	if _, err := p.Request(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Request_ImageContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		msgs, _ := body["messages"].([]any)
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %#v", body["messages"])
+		}
+		// Text-only messages keep the plain string form.
+		if sys := msgs[0].(map[string]any); sys["content"] != "Be brief." {
+			t.Errorf("expected string system content, got %#v", sys["content"])
+		}
+		parts, ok := msgs[1].(map[string]any)["content"].([]any)
+		if !ok || len(parts) != 2 {
+			t.Fatalf("expected 2 content parts, got %#v", msgs[1])
+		}
+		text := parts[0].(map[string]any)
+		if text["type"] != "text" || text["text"] != "What is this?" {
+			t.Errorf("unexpected text part: %#v", text)
+		}
+		image := parts[1].(map[string]any)
+		imageURL, _ := image["image_url"].(map[string]any)
+		if image["type"] != "image_url" || imageURL["url"] != "data:image/png;base64,iVBORw0KGgo=" || imageURL["detail"] != "high" {
+			t.Errorf("unexpected image part: %#v", image)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-img",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "gpt-4o",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": "A pixel."},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Messages = []providers.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "What is this?", Parts: []providers.ContentPart{
+			{Type: providers.ContentPartText, Text: "What is this?"},
+			{Type: providers.ContentPartImageURL, ImageURL: "data:image/png;base64,iVBORw0KGgo=", Detail: "high"},
+		}},
+	}
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "A pixel." {
+		t.Errorf("expected 'A pixel.', got %q", resp.Content)
+	}
+}
+
+func TestProvider_Request_ToolCallRoundTrip(t *testing.T) {
+	tools := `[{"type":"function","function":{"name":"get_weather","description":"Current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}]`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+
+		sentTools, _ := body["tools"].([]any)
+		if len(sentTools) != 1 {
+			t.Fatalf("expected 1 tool, got %#v", body["tools"])
+		}
+		fn, _ := sentTools[0].(map[string]any)["function"].(map[string]any)
+		if fn["name"] != "get_weather" || fn["parameters"] == nil {
+			t.Errorf("tool not forwarded verbatim: %#v", sentTools[0])
+		}
+		if body["tool_choice"] != "auto" {
+			t.Errorf("expected tool_choice=auto, got %#v", body["tool_choice"])
+		}
+
+		msgs, _ := body["messages"].([]any)
+		if len(msgs) != 3 {
+			t.Fatalf("expected 3 messages, got %#v", body["messages"])
+		}
+		assistant := msgs[1].(map[string]any)
+		calls, _ := assistant["tool_calls"].([]any)
+		if len(calls) != 1 {
+			t.Fatalf("expected assistant tool_calls, got %#v", assistant)
+		}
+		call := calls[0].(map[string]any)
+		callFn, _ := call["function"].(map[string]any)
+		if call["id"] != "call_1" || callFn["name"] != "get_weather" || callFn["arguments"] != `{"city":"Paris"}` {
+			t.Errorf("unexpected tool call: %#v", call)
+		}
+		tool := msgs[2].(map[string]any)
+		if tool["role"] != "tool" || tool["tool_call_id"] != "call_1" || tool["content"] != "18C" {
+			t.Errorf("unexpected tool message: %#v", tool)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-tool",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "gpt-4o",
+			"choices": []any{
+				map[string]any{
+					"index": 0,
+					"message": map[string]any{
+						"role":    "assistant",
+						"content": nil,
+						"tool_calls": []any{
+							map[string]any{
+								"id":       "call_2",
+								"type":     "function",
+								"function": map[string]any{"name": "get_weather", "arguments": `{"city":"Lyon"}`},
+							},
+						},
+					},
+					"finish_reason": "tool_calls",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Tools = json.RawMessage(tools)
+	req.ToolChoice = json.RawMessage(`"auto"`)
+	req.Messages = []providers.Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "18C"},
+	}
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := providers.ToolCall{ID: "call_2", Name: "get_weather", Arguments: `{"city":"Lyon"}`}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != want {
+		t.Errorf("ToolCalls = %+v, want [%+v]", resp.ToolCalls, want)
+	}
+}
+
+func TestProvider_Request_Penalties(t *testing.T) {
+	tests := []struct {
+		name     string
+		presence *float64
+		freq     *float64
+	}{
+		{name: "omitted"},
+		{name: "set", presence: ptr(0.5), freq: ptr(-0.25)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode request body: %v", err)
+				}
+				checkOptionalFloat(t, body, "presence_penalty", tt.presence)
+				checkOptionalFloat(t, body, "frequency_penalty", tt.freq)
+
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"id":      "chatcmpl-pen",
+					"object":  "chat.completion",
+					"created": 0,
+					"model":   "gpt-4o",
+					"choices": []any{
+						map[string]any{
+							"index":         0,
+							"message":       map[string]any{"role": "assistant", "content": "ok"},
+							"finish_reason": "stop",
+						},
+					},
+				})
+			}))
+			defer srv.Close()
+
+			req := baseRequest()
+			req.PresencePenalty = tt.presence
+			req.FrequencyPenalty = tt.freq
+
+			p := newTestProvider(srv)
+			if _, err := p.Request(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestProvider_Request_ResponseFormat(t *testing.T) {
+	const format = `{"type":"json_schema","json_schema":{"name":"city","strict":true,"schema":{"type":"object","properties":{"name":{"type":"string"}}}}}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		rf, _ := body["response_format"].(map[string]any)
+		schema, _ := rf["json_schema"].(map[string]any)
+		if rf["type"] != "json_schema" || schema["name"] != "city" || schema["strict"] != true || schema["schema"] == nil {
+			t.Errorf("response_format not forwarded verbatim: %#v", body["response_format"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-rf",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "gpt-4o",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": `{"name":"Paris"}`},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.ResponseFormat = json.RawMessage(format)
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != `{"name":"Paris"}` {
+		t.Errorf("unexpected content %q", resp.Content)
+	}
+}
+
+func TestProvider_Request_Seed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if body["seed"] != float64(42) {
+			t.Errorf("expected seed=42, got %v", body["seed"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-seed",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "gpt-4o",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": "ok"},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	seed := int64(42)
+	req := baseRequest()
+	req.Seed = &seed
+
+	p := newTestProvider(srv)
+	if _, err := p.Request(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Request_MaxCompletionTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if body["max_completion_tokens"] != float64(300) {
+			t.Errorf("expected max_completion_tokens=300, got %v", body["max_completion_tokens"])
+		}
+		if _, ok := body["max_tokens"]; ok {
+			t.Errorf("deprecated max_tokens sent: %v", body["max_tokens"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-max",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "o3-mini",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": "ok"},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Model = "o3-mini"
+	req.MaxTokens = 300
+
+	p := newTestProvider(srv)
+	if _, err := p.Request(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Request_LogProbs(t *testing.T) {
+	logprobs := `{"content":[{"token":"ok","logprob":-0.01,"bytes":[111,107],"top_logprobs":[{"token":"ok","logprob":-0.01,"bytes":[111,107]}]}],"refusal":null}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if body["logprobs"] != true {
+			t.Errorf("expected logprobs=true, got %v", body["logprobs"])
+		}
+		if body["top_logprobs"] != float64(1) {
+			t.Errorf("expected top_logprobs=1, got %v", body["top_logprobs"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"chatcmpl-lp","object":"chat.completion","created":0,"model":"gpt-4o",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"logprobs":%s,"finish_reason":"stop"}]}`,
+			logprobs)
+	}))
+	defer srv.Close()
+
+	on, top := true, 1
+	req := baseRequest()
+	req.LogProbs = &on
+	req.TopLogProbs = &top
+
+	p := newTestProvider(srv)
+	resp, err := p.Request(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.LogProbs) != logprobs {
+		t.Errorf("LogProbs = %s, want %s", resp.LogProbs, logprobs)
+	}
+}
+
+func TestProvider_Request_FinishReason(t *testing.T) {
+	for _, reason := range []string{"length", "content_filter"} {
+		t.Run(reason, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"id":"chatcmpl-fr","object":"chat.completion","created":0,"model":"gpt-4o",`+
+					`"choices":[{"index":0,"message":{"role":"assistant","content":"cut"},"finish_reason":%q}]}`, reason)
+			}))
+			defer srv.Close()
+
+			resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.FinishReason != reason {
+				t.Errorf("FinishReason = %q, want %q", resp.FinishReason, reason)
+			}
+		})
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+// checkOptionalFloat asserts that key is absent from body when want is nil
+// and present with the expected value otherwise.
+func checkOptionalFloat(t *testing.T, body map[string]any, key string, want *float64) {
+	t.Helper()
+	got, ok := body[key]
+	if want == nil {
+		if ok {
+			t.Errorf("%s should be omitted, got %v", key, got)
+		}
+		return
+	}
+	if f, isFloat := got.(float64); !ok || !isFloat || f != *want {
+		t.Errorf("expected %s=%v, got %v (present=%v)", key, *want, got, ok)
+	}
+}
+
 func TestProvider_Request_Streaming(t *testing.T) {
 	// Minimal chat.completion.chunk payloads for SSE streaming.
 	chunks := []string{
 		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]}`,
 		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":null}]}`,
 		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
 	}
 
+	var includeUsage any
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if so, ok := body["stream_options"].(map[string]any); ok {
+			includeUsage = so["include_usage"]
+		}
+
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
@@ -128,13 +571,23 @@ func TestProvider_Request_Streaming(t *testing.T) {
 	}
 
 	var content string
+	var usage *providers.Usage
 	for chunk := range resp.Stream {
 		content += chunk.Content
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
 	}
 
 	if content != "Hello world" {
 		t.Errorf("expected 'Hello world', got %q", content)
 	}
+	if includeUsage != true {
+		t.Errorf("expected stream_options.include_usage=true, got %v", includeUsage)
+	}
+	if usage == nil || usage.InputTokens != 9 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage {9 2}, got %+v", usage)
+	}
 }
 
 func TestProvider_Request_RateLimit(t *testing.T) {
@@ -160,17 +613,22 @@ func TestProvider_Request_RateLimit(t *testing.T) {
 		t.Fatal("expected error for 429, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
+	}
+	if provErr.Provider != "openai" {
+		t.Errorf("expected provider 'openai', got %q", provErr.Provider)
 	}
 	if provErr.StatusCode != http.StatusTooManyRequests {
 		t.Errorf("expected status 429, got %d", provErr.StatusCode)
 	}
 
-	// NOTE: current implementation sets Type to "openai_error" for all API errors.
-	if provErr.Type != "openai_error" {
-		t.Errorf("expected type 'openai_error', got %q", provErr.Type)
+	if provErr.Type != "rate_limit_error" {
+		t.Errorf("expected type 'rate_limit_error', got %q", provErr.Type)
+	}
+	if provErr.Code != "rate_limit_exceeded" {
+		t.Errorf("expected code 'rate_limit_exceeded', got %q", provErr.Code)
 	}
 
 	if !strings.Contains(strings.ToLower(provErr.Message), "rate limit") {
@@ -178,6 +636,30 @@ func TestProvider_Request_RateLimit(t *testing.T) {
 	}
 }
 
+func TestProvider_Request_RateLimit_RetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retry-After-Ms keeps the SDK's own retries fast; the gateway only
+		// looks at Retry-After.
+		w.Header().Set("Retry-After-Ms", "1")
+		w.Header().Set("Retry-After", "42")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"Rate limit exceeded","type":"rate_limit_error"}}`))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	_, err := p.Request(context.Background(), baseRequest())
+
+	provErr, ok := err.(*providers.Error)
+	if !ok {
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
+	}
+	if provErr.RetryAfter != 42*time.Second {
+		t.Errorf("expected RetryAfter=42s, got %v", provErr.RetryAfter)
+	}
+}
+
 func TestProvider_Request_ServerError(t *testing.T) {
 	errBody := map[string]any{
 		"error": map[string]any{
@@ -199,16 +681,154 @@ func TestProvider_Request_ServerError(t *testing.T) {
 		t.Fatal("expected error for 503, got nil")
 	}
 
-	provErr, ok := err.(*ProviderError)
+	provErr, ok := err.(*providers.Error)
 	if !ok {
-		t.Fatalf("expected *ProviderError, got %T: %v", err, err)
+		t.Fatalf("expected *providers.Error, got %T: %v", err, err)
 	}
 	if provErr.StatusCode != http.StatusServiceUnavailable {
 		t.Errorf("expected status 503, got %d", provErr.StatusCode)
 	}
 
-	// NOTE: current implementation sets Type to "openai_error" for all API errors.
-	if provErr.Type != "openai_error" {
-		t.Errorf("expected type 'openai_error', got %q", provErr.Type)
+	if provErr.Type != "server_error" {
+		t.Errorf("expected type 'server_error', got %q", provErr.Type)
+	}
+}
+
+func TestProvider_Request_CapturesRateLimitHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "59")
+		w.Header().Set("X-Ratelimit-Remaining-Tokens", "149000")
+		w.Header().Set("Openai-Processing-Ms", "12")
+		_, _ = fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	resp, err := newTestProvider(srv).Request(context.Background(), baseRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Headers["x-ratelimit-remaining-requests"] != "59" || resp.Headers["x-ratelimit-remaining-tokens"] != "149000" {
+		t.Fatalf("headers = %v", resp.Headers)
+	}
+	if _, ok := resp.Headers["openai-processing-ms"]; ok {
+		t.Fatalf("non rate-limit header captured: %v", resp.Headers)
+	}
+}
+
+func TestProvider_Request_ReasoningEffort(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if body["reasoning_effort"] != "high" {
+			t.Errorf("expected reasoning_effort=high, got %v", body["reasoning_effort"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-o3",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "o3",
+			"choices": []any{
+				map[string]any{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": "ok"},
+					"finish_reason": "stop",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	req := baseRequest()
+	req.Model = "o3"
+	req.ReasoningEffort = "high"
+	req.ThinkingBudget = 4096 // budget-only setting; OpenAI ignores it
+
+	if _, err := newTestProvider(srv).Request(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_Embed_Dimensions(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		bodies = append(bodies, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  "text-embedding-3-small",
+			"data":   []any{map[string]any{"object": "embedding", "index": 0, "embedding": []float64{0.1, 0.2}}},
+			"usage":  map[string]any{"prompt_tokens": 1, "total_tokens": 1},
+		})
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	dims := 256
+	for _, d := range []*int{&dims, nil} {
+		if _, err := p.Embed(context.Background(), &providers.EmbeddingRequest{
+			Model:      "text-embedding-3-small",
+			Input:      []string{"hello"},
+			Dimensions: d,
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if bodies[0]["dimensions"] != float64(256) {
+		t.Errorf("expected dimensions=256, got %v", bodies[0]["dimensions"])
+	}
+	if _, ok := bodies[1]["dimensions"]; ok {
+		t.Errorf("dimensions sent although unset: %v", bodies[1]["dimensions"])
+	}
+}
+
+func TestProvider_Moderate(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/moderations" {
+			t.Errorf("path = %s, want /v1/moderations", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":    "modr-1",
+			"model": "omni-moderation-latest",
+			"results": []any{
+				map[string]any{"flagged": false, "categories": map[string]bool{"violence": false}},
+				map[string]any{"flagged": true, "categories": map[string]bool{"violence": true, "harassment": true}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv)
+	resp, err := p.Moderate(context.Background(), &providers.ModerationRequest{
+		Model: "omni-moderation-latest",
+		Input: []string{"hello", "something violent"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Flagged {
+		t.Error("expected flagged verdict")
+	}
+	if got := strings.Join(resp.Categories, ","); got != "harassment,violence" {
+		t.Errorf("categories = %q, want harassment,violence", got)
+	}
+	if body["model"] != "omni-moderation-latest" {
+		t.Errorf("model = %v, want omni-moderation-latest", body["model"])
 	}
 }
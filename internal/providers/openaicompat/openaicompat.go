@@ -5,9 +5,10 @@ package openaicompat
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
@@ -17,10 +18,28 @@ import (
 
 // Provider is a configurable OpenAI-compatible LLM provider.
 type Provider struct {
-	name    string
-	apiKey  string
-	baseURL string
-	client  openaiSDK.Client
+	name        string
+	apiKey      string
+	baseURL     string
+	modelPrefix string
+	noAuth      bool
+	client      openaiSDK.Client
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithModelPrefix strips prefix from request models before they are sent,
+// so "mylocal/llama3" reaches the endpoint as "llama3".
+func WithModelPrefix(prefix string) Option {
+	return func(p *Provider) { p.modelPrefix = prefix }
+}
+
+// WithNoAuth allows requests without an API key, for endpoints such as a
+// local vLLM or Ollama server that need none. They are sent without an
+// Authorization header.
+func WithNoAuth() Option {
+	return func(p *Provider) { p.noAuth = true }
 }
 
 // New creates a new OpenAI-compatible Provider.
@@ -28,22 +47,28 @@ type Provider struct {
 //   - name    — unique provider identifier used for routing and logs.
 //   - apiKey  — API key sent as "Authorization: Bearer <key>".
 //   - baseURL — API base URL, e.g. "https://api.x.ai/v1".
-func New(name, apiKey, baseURL string) *Provider {
+func New(name, apiKey, baseURL string, opts ...Option) *Provider {
 	p := &Provider{
 		name:    name,
 		apiKey:  apiKey,
 		baseURL: baseURL,
 	}
+	for _, o := range opts {
+		o(p)
+	}
 
-	opts := []option.RequestOption{
+	clientOpts := []option.RequestOption{
 		option.WithAPIKey(p.apiKey),
-		option.WithHTTPClient(&http.Client{Timeout: providers.ProviderTimeout}),
+		option.WithHTTPClient(providers.NewHTTPClient()),
+	}
+	if p.apiKey == "" && p.noAuth {
+		clientOpts = append(clientOpts, option.WithHeaderDel("authorization"))
 	}
 	if p.baseURL != "" {
-		opts = append(opts, option.WithBaseURL(p.baseURL))
+		clientOpts = append(clientOpts, option.WithBaseURL(p.baseURL))
 	}
 
-	p.client = openaiSDK.NewClient(opts...)
+	p.client = openaiSDK.NewClient(clientOpts...)
 	return p
 }
 
@@ -58,7 +83,10 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
-	params := p.buildParams(req)
+	params, err := p.buildParams(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.name, err)
+	}
 	opts, err := p.requestOptions(req.APIKey)
 	if err != nil {
 		return nil, err
@@ -69,15 +97,15 @@ func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*p
 	return p.handleResponse(ctx, params, opts...)
 }
 
-func (p *Provider) buildParams(req *providers.ProxyRequest) openaiSDK.ChatCompletionNewParams {
+func (p *Provider) buildParams(req *providers.ProxyRequest) (openaiSDK.ChatCompletionNewParams, error) {
 	msgs := make([]openaiSDK.ChatCompletionMessageParamUnion, 0, len(req.Messages))
 	for _, m := range req.Messages {
-		msgs = append(msgs, toSDKMessage(m.Role, m.Content))
+		msgs = append(msgs, toSDKMessage(m))
 	}
 
 	params := openaiSDK.ChatCompletionNewParams{
 		Messages: msgs,
-		Model:    req.Model,
+		Model:    strings.TrimPrefix(req.Model, p.modelPrefix),
 	}
 
 	if req.Temperature != 0 {
@@ -86,8 +114,44 @@ func (p *Provider) buildParams(req *providers.ProxyRequest) openaiSDK.ChatComple
 	if req.MaxTokens > 0 {
 		params.MaxCompletionTokens = openaiSDK.Int(int64(req.MaxTokens))
 	}
+	if req.TopP != nil {
+		params.TopP = openaiSDK.Float(*req.TopP)
+	}
+	if len(req.Stop) > 0 {
+		params.Stop = openaiSDK.ChatCompletionNewParamsStopUnion{OfStringArray: req.Stop}
+	}
+	if req.PresencePenalty != nil {
+		params.PresencePenalty = openaiSDK.Float(*req.PresencePenalty)
+	}
+	if req.FrequencyPenalty != nil {
+		params.FrequencyPenalty = openaiSDK.Float(*req.FrequencyPenalty)
+	}
+	if req.Seed != nil {
+		params.Seed = openaiSDK.Int(*req.Seed)
+	}
+	if req.LogProbs != nil {
+		params.Logprobs = openaiSDK.Bool(*req.LogProbs)
+	}
+	if req.TopLogProbs != nil {
+		params.TopLogprobs = openaiSDK.Int(int64(*req.TopLogProbs))
+	}
+	if len(req.Tools) > 0 {
+		if err := json.Unmarshal(req.Tools, &params.Tools); err != nil {
+			return params, fmt.Errorf("invalid tools: %w", err)
+		}
+	}
+	if len(req.ToolChoice) > 0 {
+		if err := json.Unmarshal(req.ToolChoice, &params.ToolChoice); err != nil {
+			return params, fmt.Errorf("invalid tool_choice: %w", err)
+		}
+	}
+	if len(req.ResponseFormat) > 0 {
+		if err := json.Unmarshal(req.ResponseFormat, &params.ResponseFormat); err != nil {
+			return params, fmt.Errorf("invalid response_format: %w", err)
+		}
+	}
 
-	return params
+	return params, nil
 }
 
 func (p *Provider) handleResponse(
@@ -100,15 +164,28 @@ func (p *Provider) handleResponse(
 		return nil, p.toProviderError(err)
 	}
 
-	content := ""
+	content, finish := "", ""
+	var (
+		toolCalls []providers.ToolCall
+		logProbs  json.RawMessage
+	)
 	if len(resp.Choices) > 0 {
-		content = resp.Choices[0].Message.Content
+		c := resp.Choices[0]
+		content = c.Message.Content
+		finish = c.FinishReason
+		toolCalls = fromSDKToolCalls(c.Message.ToolCalls)
+		if c.JSON.Logprobs.Valid() {
+			logProbs = json.RawMessage(c.Logprobs.RawJSON())
+		}
 	}
 
 	return &providers.ProxyResponse{
-		ID:      resp.ID,
-		Model:   resp.Model,
-		Content: content,
+		ID:           resp.ID,
+		Model:        resp.Model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
+		LogProbs:     logProbs,
 		Usage: providers.Usage{
 			InputTokens:  int(resp.Usage.PromptTokens),
 			OutputTokens: int(resp.Usage.CompletionTokens),
@@ -147,37 +224,128 @@ func (p *Provider) handleStreaming(
 		}
 
 		if err := stream.Err(); err != nil {
-			ch <- providers.StreamChunk{
-				Content:      fmt.Sprintf("[stream error] %v", err),
-				FinishReason: "error",
-			}
+			ch <- providers.StreamChunk{Err: err}
 		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
 }
 
-// ProviderError is a structured error returned by an OpenAI-compatible API.
-type ProviderError struct {
-	Name       string
-	StatusCode int
-	Message    string
+// rerankRequest is the body of POST /rerank, the Cohere/Jina schema that
+// Together AI, Jina, vLLM and similar servers accept.
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n,omitempty"`
 }
 
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("%s: %s (status=%d)", e.Name, e.Message, e.StatusCode)
+type rerankResponse struct {
+	Model   string `json:"model"`
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
 }
 
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
+// Rerank implements providers.RerankProvider for endpoints that serve
+// POST /rerank next to the chat completions API.
+func (p *Provider) Rerank(ctx context.Context, req *providers.RerankRequest) (*providers.RerankResponse, error) {
+	opts, err := p.requestOptions(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	model := strings.TrimPrefix(req.Model, p.modelPrefix)
+	var res rerankResponse
+	err = p.client.Post(ctx, "rerank", rerankRequest{
+		Model:     model,
+		Query:     req.Query,
+		Documents: req.Documents,
+		TopN:      req.TopN,
+	}, &res, opts...)
+	if err != nil {
+		return nil, p.toProviderError(err)
+	}
+
+	out := &providers.RerankResponse{
+		Model:   res.Model,
+		Results: make([]providers.RerankResult, 0, len(res.Results)),
+		Usage:   providers.Usage{InputTokens: res.Usage.PromptTokens},
+	}
+	if out.Model == "" {
+		out.Model = model
+	}
+	if out.Usage.InputTokens == 0 {
+		out.Usage.InputTokens = res.Usage.TotalTokens
+	}
+	for _, r := range res.Results {
+		out.Results = append(out.Results, providers.RerankResult{Index: r.Index, RelevanceScore: r.RelevanceScore})
+	}
+	return out, nil
+}
+
+// moderationRequest is the body of POST /moderations in the OpenAI schema,
+// which self-hosted classifiers such as Llama Guard servers also expose.
+type moderationRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate implements providers.ModerationProvider for endpoints that serve
+// POST /moderations next to the chat completions API.
+func (p *Provider) Moderate(ctx context.Context, req *providers.ModerationRequest) (*providers.ModerationResponse, error) {
+	opts, err := p.requestOptions("")
+	if err != nil {
+		return nil, err
+	}
+	var res moderationResponse
+	err = p.client.Post(ctx, "moderations", moderationRequest{
+		Model: strings.TrimPrefix(req.Model, p.modelPrefix),
+		Input: req.Input,
+	}, &res, opts...)
+	if err != nil {
+		return nil, p.toProviderError(err)
+	}
+
+	out := &providers.ModerationResponse{}
+	for _, r := range res.Results {
+		out.Flagged = out.Flagged || r.Flagged
+		for name, flagged := range r.Categories {
+			if flagged {
+				out.Categories = append(out.Categories, name)
+			}
+		}
+	}
+	slices.Sort(out.Categories)
+	out.Categories = slices.Compact(out.Categories)
+	return out, nil
+}
 
 func (p *Provider) toProviderError(err error) error {
 	var apierr *openaiSDK.Error
 	if errors.As(err, &apierr) {
-		return &ProviderError{
-			Name:       p.name,
+		pe := &providers.Error{
+			Provider:   p.name,
 			StatusCode: apierr.StatusCode,
 			Message:    apierr.Error(),
+			Type:       apierr.Type,
+			Code:       apierr.Code,
+		}
+		if apierr.Response != nil {
+			pe.RetryAfter = providers.ParseRetryAfter(apierr.Response.Header.Get("Retry-After"))
 		}
+		return pe
 	}
 	return err
 }
@@ -188,20 +356,85 @@ func (p *Provider) requestOptions(overrideKey string) ([]option.RequestOption, e
 		key = p.apiKey
 	}
 	if key == "" {
+		if p.noAuth {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("%s: no API key configured", p.name)
 	}
 	return []option.RequestOption{option.WithAPIKey(key)}, nil
 }
 
-func toSDKMessage(role, content string) openaiSDK.ChatCompletionMessageParamUnion {
-	switch strings.ToLower(role) {
+func toSDKMessage(m providers.Message) openaiSDK.ChatCompletionMessageParamUnion {
+	content := m.Content
+	switch strings.ToLower(m.Role) {
 	case "developer":
 		return openaiSDK.DeveloperMessage(content)
 	case "system":
 		return openaiSDK.SystemMessage(content)
 	case "assistant":
+		if len(m.ToolCalls) > 0 {
+			return toSDKAssistantToolCalls(m)
+		}
 		return openaiSDK.AssistantMessage(content)
+	case "tool":
+		return openaiSDK.ToolMessage(content, m.ToolCallID)
 	default:
+		if len(m.Parts) > 0 {
+			return openaiSDK.UserMessage(toSDKContentParts(m.Parts))
+		}
 		return openaiSDK.UserMessage(content)
 	}
 }
+
+// toSDKAssistantToolCalls builds an assistant turn that requested tool calls.
+func toSDKAssistantToolCalls(m providers.Message) openaiSDK.ChatCompletionMessageParamUnion {
+	var msg openaiSDK.ChatCompletionAssistantMessageParam
+	if m.Content != "" {
+		msg.Content.OfString = openaiSDK.String(m.Content)
+	}
+	msg.ToolCalls = make([]openaiSDK.ChatCompletionMessageToolCallUnionParam, len(m.ToolCalls))
+	for i, c := range m.ToolCalls {
+		msg.ToolCalls[i] = openaiSDK.ChatCompletionMessageToolCallUnionParam{
+			OfFunction: &openaiSDK.ChatCompletionMessageFunctionToolCallParam{
+				ID: c.ID,
+				Function: openaiSDK.ChatCompletionMessageFunctionToolCallFunctionParam{
+					Name:      c.Name,
+					Arguments: c.Arguments,
+				},
+			},
+		}
+	}
+	return openaiSDK.ChatCompletionMessageParamUnion{OfAssistant: &msg}
+}
+
+// fromSDKToolCalls extracts the function calls of a response message.
+func fromSDKToolCalls(calls []openaiSDK.ChatCompletionMessageToolCallUnion) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, 0, len(calls))
+	for _, c := range calls {
+		if c.Type != "function" {
+			continue
+		}
+		out = append(out, providers.ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return out
+}
+
+// toSDKContentParts maps multimodal parts onto the native content array.
+func toSDKContentParts(parts []providers.ContentPart) []openaiSDK.ChatCompletionContentPartUnionParam {
+	out := make([]openaiSDK.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case providers.ContentPartImageURL:
+			out = append(out, openaiSDK.ImageContentPart(openaiSDK.ChatCompletionContentPartImageImageURLParam{
+				URL:    part.ImageURL,
+				Detail: part.Detail,
+			}))
+		default:
+			out = append(out, openaiSDK.TextContentPart(part.Text))
+		}
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package providers
+
+import "strings"
+
+// ModelPrefixes maps model-name prefixes to the providers that serve every
+// model under them, such as the custom OpenAI-compatible endpoints of
+// CUSTOM_PROVIDERS. Empty by default. Like ModelAliases it is filled at
+// startup and must not change while serving requests.
+var ModelPrefixes = map[string]string{}
+
+// ProviderForPrefix returns the provider of the longest entry in
+// ModelPrefixes that model starts with.
+func ProviderForPrefix(model string) (string, bool) {
+	var (
+		provider string
+		longest  = -1
+	)
+	for prefix, name := range ModelPrefixes {
+		if len(prefix) > longest && strings.HasPrefix(model, prefix) {
+			provider, longest = name, len(prefix)
+		}
+	}
+	return provider, longest >= 0
+}
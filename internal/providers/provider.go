@@ -3,11 +3,16 @@
 //
 // Each provider lives in its own sub-package and implements the Provider
 // interface. Providers that support vector embeddings additionally implement
-// EmbeddingProvider.
+// EmbeddingProvider, those that rank documents RerankProvider, and those
+// that classify content ModerationProvider.
 package providers
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,12 +21,58 @@ type (
 	StreamChunk struct {
 		Content      string
 		FinishReason string
+		// Usage is the provider-reported token usage. Only the final chunk of
+		// a stream may carry it, and only when the provider reports usage;
+		// it may arrive on a chunk with no Content.
+		Usage *Usage
+		// Err is set on the last chunk when the stream failed part-way; such
+		// a chunk carries nothing else.
+		Err error
 	}
 
 	// Message is a single turn in a conversation (role + text content).
+	// Multimodal messages additionally carry Parts; Content then holds the
+	// concatenated text parts so text-only consumers keep working.
 	Message struct {
 		Role    string
 		Content string
+		// Parts is nil for plain-text messages.
+		Parts []ContentPart
+
+		// ToolCalls are the calls an assistant turn requested.
+		ToolCalls []ToolCall
+		// ToolCallID links a "tool" message to the call it answers.
+		ToolCallID string
+	}
+
+	// ToolCall is a function call requested by the model.
+	ToolCall struct {
+		ID        string
+		Name      string
+		Arguments string // JSON-encoded arguments, as produced by the model
+	}
+
+	// ContentPart is one element of a multimodal message, mirroring the
+	// OpenAI content-array format.
+	ContentPart struct {
+		Type string // ContentPartText or ContentPartImageURL
+		Text string
+		// ImageURL is an http(s) URL or a base64 data: URL.
+		ImageURL string
+		// Detail is OpenAI's image fidelity hint ("auto", "low", "high");
+		// other providers ignore it.
+		Detail string
+	}
+
+	// Choice is one completion alternative in a non-streaming response.
+	Choice struct {
+		Index        int
+		Content      string
+		ToolCalls    []ToolCall
+		FinishReason string
+		// LogProbs is the choice's OpenAI-format "logprobs" object, kept
+		// verbatim; nil when not requested.
+		LogProbs json.RawMessage
 	}
 
 	// Usage — token usage stats.
@@ -37,6 +88,49 @@ type (
 		Stream      bool
 		Temperature float64
 		MaxTokens   int
+		// TemperatureSet reports whether the client sent temperature, so an
+		// explicit 0 can be told apart from an omitted one.
+		TemperatureSet bool
+		// N is the number of completions to generate. 0 means provider default (1).
+		N int
+
+		// TopP is the nucleus sampling threshold. nil means "not set by the
+		// client" so providers can omit it; 0.0 is a valid explicit value.
+		TopP *float64
+		// Stop lists sequences at which the provider should stop generating.
+		Stop []string
+		// PresencePenalty and FrequencyPenalty are honoured by OpenAI-compatible
+		// backends only; other providers ignore them.
+		PresencePenalty  *float64
+		FrequencyPenalty *float64
+		// Seed requests deterministic sampling from backends that support it
+		// (OpenAI, Azure, Mistral, OpenAI-compatible); others ignore it.
+		Seed *int64
+		// LogProbs and TopLogProbs request token log-probabilities. They are
+		// forwarded by OpenAI-compatible backends; other providers reject a
+		// request that asks for them (see WantsLogProbs).
+		LogProbs    *bool
+		TopLogProbs *int
+
+		// Tools and ToolChoice are the client's OpenAI-format "tools" and
+		// "tool_choice" values, kept verbatim; nil when absent. Providers
+		// with a different schema translate them (see ParseFunctionTools).
+		Tools      json.RawMessage
+		ToolChoice json.RawMessage
+		// ResponseFormat is the client's OpenAI-format "response_format",
+		// kept verbatim; nil when absent. Providers without structured
+		// output reject json_object and json_schema with a 400.
+		ResponseFormat json.RawMessage
+
+		// ReasoningEffort is the client's OpenAI-format "reasoning_effort"
+		// ("minimal" … "high"); empty when unset. OpenAI forwards it as is;
+		// Anthropic converts it to a thinking budget (see ThinkingBudgetFor).
+		ReasoningEffort string
+		// ThinkingBudget is the extended-thinking budget in tokens from the
+		// gateway's "thinking" field; 0 when unset. It takes precedence over
+		// ReasoningEffort for providers that take a budget.
+		ThinkingBudget int
+
 		WorkspaceID string
 		APIKey      string
 		APIKeyID    string
@@ -47,9 +141,28 @@ type (
 	ProxyResponse struct {
 		ID      string
 		Model   string
-		Content string
+		Content string // text of choice 0; kept for providers that return one choice
+		// ToolCalls are the function calls of choice 0.
+		ToolCalls []ToolCall
+		// FinishReason is choice 0's stop reason as the provider reported it
+		// (e.g. Anthropic "end_turn", Gemini "MAX_TOKENS"); the gateway maps
+		// it with NormalizeFinishReason. Empty when the provider sent none.
+		FinishReason string
+		// Choices holds every alternative when the provider returned more than
+		// one (see ProxyRequest.N). Nil means a single choice carried in Content.
+		Choices []Choice
 		Usage   Usage
 		Stream  <-chan StreamChunk // nil if it's not a stream.
+		// Reasoning is the model's extended-thinking text for choice 0, when
+		// the provider returns it; empty otherwise.
+		Reasoning string
+		// LogProbs is the OpenAI-format "logprobs" object of choice 0, kept
+		// verbatim; nil when not requested.
+		LogProbs json.RawMessage
+		// Headers are selected upstream response headers (see
+		// RateLimitHeaders), keyed by lower-case name; nil when the
+		// provider does not capture them.
+		Headers map[string]string
 	}
 
 	// EmbeddingRequest — normalized embedding request.
@@ -58,6 +171,9 @@ type (
 		Input []string
 		// Model is the provider-native model name (e.g. "text-embedding-3-small").
 		Model       string
+		// Dimensions truncates the output vectors to this many components.
+		// Nil leaves the model's default size.
+		Dimensions  *int
 		WorkspaceID string
 		APIKey      string
 		APIKeyID    string
@@ -76,6 +192,56 @@ type (
 		Data  []EmbeddingData
 		Usage Usage
 	}
+
+	// RerankRequest — normalized rerank request.
+	RerankRequest struct {
+		// Query is the text the documents are ranked against.
+		Query string
+		// Documents are the texts to rank. Always at least one element.
+		Documents []string
+		// TopN is the number of best results to return; 0 returns all.
+		TopN int
+		// Model is the provider-native model name.
+		Model       string
+		WorkspaceID string
+		APIKey      string
+		APIKeyID    string
+		RequestID   string
+	}
+
+	// RerankResult — the relevance of one document.
+	RerankResult struct {
+		// Index is the document's position in RerankRequest.Documents.
+		Index          int
+		RelevanceScore float64
+	}
+
+	// RerankResponse — normalized rerank response. Results are ordered by
+	// descending relevance.
+	RerankResponse struct {
+		Model   string
+		Results []RerankResult
+		Usage   Usage
+	}
+
+	// ModerationRequest — normalized content moderation request.
+	ModerationRequest struct {
+		// Input is the list of texts to classify. Always at least one element.
+		Input []string
+		// Model is the provider-native moderation model; empty uses the
+		// provider's default.
+		Model     string
+		RequestID string
+	}
+
+	// ModerationResponse — normalized moderation verdict over all inputs.
+	ModerationResponse struct {
+		// Flagged reports whether any input was flagged.
+		Flagged bool
+		// Categories lists the flagged categories, sorted and without
+		// duplicates.
+		Categories []string
+	}
 )
 
 // Provider — LLM provider interface.
@@ -91,6 +257,44 @@ type EmbeddingProvider interface {
 	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
 }
 
+// RerankProvider is an optional interface implemented by providers that
+// support a Cohere/Jina-style rerank API. Check with a type assertion before
+// calling.
+type RerankProvider interface {
+	Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error)
+}
+
+// ModerationProvider is an optional interface implemented by providers that
+// classify content against a usage policy. Check with a type assertion
+// before calling.
+type ModerationProvider interface {
+	Moderate(ctx context.Context, req *ModerationRequest) (*ModerationResponse, error)
+}
+
+// AsModerationProvider returns p as a ModerationProvider, looking through
+// wrappers such as key pools that expose the wrapped provider with an
+// Unwrap method. Moderation calls made this way use the provider's own key.
+func AsModerationProvider(p Provider) (ModerationProvider, bool) {
+	for p != nil {
+		if mp, ok := p.(ModerationProvider); ok {
+			return mp, true
+		}
+		u, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			break
+		}
+		p = u.Unwrap()
+	}
+	return nil, false
+}
+
+// RerankModelAliases maps rerank model names to provider names.
+// Used by the proxy to route POST /v1/rerank requests.
+var RerankModelAliases = map[string]string{
+	// Together AI
+	"Salesforce/Llama-Rank-V1": "together",
+}
+
 // EmbeddingModelAliases maps embedding model names to provider names.
 // Used by the proxy to route POST /v1/embeddings requests.
 var EmbeddingModelAliases = map[string]string{
@@ -103,6 +307,29 @@ var EmbeddingModelAliases = map[string]string{
 	// Google Gemini
 	"text-embedding-004": "gemini",
 	"embedding-001":      "gemini",
+	// Google Vertex AI
+	"vertexai-text-embedding-004":      "vertexai",
+	"vertexai-textembedding-gecko":     "vertexai",
+	"vertexai-textembedding-gecko@003": "vertexai",
+}
+
+// ApplyModelAliases merges user-configured model → provider routes over the
+// built-in tables. A model already listed in EmbeddingModelAliases or
+// RerankModelAliases is remapped there; every other entry overrides or
+// extends ModelAliases. It is not safe for concurrent use and must run
+// before serving requests.
+func ApplyModelAliases(overrides map[string]string) {
+	for model, provider := range overrides {
+		if _, ok := EmbeddingModelAliases[model]; ok {
+			EmbeddingModelAliases[model] = provider
+			continue
+		}
+		if _, ok := RerankModelAliases[model]; ok {
+			RerankModelAliases[model] = provider
+			continue
+		}
+		ModelAliases[model] = provider
+	}
 }
 
 // ModelAliases maps model names to provider names.
@@ -397,8 +624,67 @@ const (
 	CBHalfOpenTimeout = 30 * time.Second
 	MaxRetries        = 3
 	ProviderTimeout   = 30 * time.Second
+
+	CBHalfOpenMaxProbes        = 1
+	CBHalfOpenSuccessThreshold = 1
+
+	BackoffBaseDelay  = 50 * time.Millisecond
+	BackoffMaxDelay   = 2 * time.Second
+	BackoffMultiplier = 2.0
+)
+
+// Content part types; see ContentPart.
+const (
+	ContentPartText     = "text"
+	ContentPartImageURL = "image_url"
 )
 
 type StatusCoder interface {
 	HTTPStatus() int
 }
+
+// RetryAfterer is implemented by provider errors that carry the upstream
+// Retry-After hint. A zero duration means the provider did not send one.
+type RetryAfterer interface {
+	RetryAfterDuration() time.Duration
+}
+
+// ParseRetryAfter parses a Retry-After header value, which is either a number
+// of seconds or an HTTP-date. Returns 0 for empty, invalid or past values.
+func ParseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ParseDataURL splits a base64 data URL ("data:image/png;base64,....") into
+// its media type and base64 payload. ok is false for anything else,
+// including remote URLs.
+func ParseDataURL(u string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(u, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	if !found || mediaType == "" {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MinThinkingBudget is the smallest thinking budget, in tokens, Anthropic
+// accepts.
+const MinThinkingBudget = 1024
+
+// reasoningBudgets maps each OpenAI-format "reasoning_effort" level to the
+// thinking budget used by providers that take a token budget instead.
+var reasoningBudgets = map[string]int{
+	"none":    0,
+	"minimal": MinThinkingBudget,
+	"low":     2048,
+	"medium":  8192,
+	"high":    16384,
+	"xhigh":   32768,
+}
+
+// ValidReasoningEffort reports whether effort is an OpenAI-format
+// "reasoning_effort" level ("none", "minimal", "low", "medium", "high" or
+// "xhigh"). The empty string, meaning unset, is valid.
+func ValidReasoningEffort(effort string) bool {
+	if effort == "" {
+		return true
+	}
+	_, ok := reasoningBudgets[effort]
+	return ok
+}
+
+// ParseThinking decodes the gateway's Anthropic-style "thinking" field,
+// {"type":"enabled","budget_tokens":N} or {"type":"disabled"}, and returns
+// the budget in tokens; 0 when absent or disabled.
+func ParseThinking(raw json.RawMessage) (int, error) {
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	var t struct {
+		Type         string `json:"type"`
+		BudgetTokens int    `json:"budget_tokens"`
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return 0, fmt.Errorf("invalid thinking: %w", err)
+	}
+	switch t.Type {
+	case "disabled":
+		return 0, nil
+	case "enabled":
+		if t.BudgetTokens < MinThinkingBudget {
+			return 0, fmt.Errorf("thinking.budget_tokens must be at least %d", MinThinkingBudget)
+		}
+		return t.BudgetTokens, nil
+	default:
+		return 0, fmt.Errorf(`thinking.type must be "enabled" or "disabled", got %q`, t.Type)
+	}
+}
+
+// ThinkingBudgetFor returns the thinking budget for req: ThinkingBudget when
+// the client set one, otherwise the budget matching ReasoningEffort, or 0
+// when neither asks for extended reasoning.
+func ThinkingBudgetFor(req *ProxyRequest) int {
+	if req.ThinkingBudget > 0 {
+		return req.ThinkingBudget
+	}
+	return reasoningBudgets[req.ReasoningEffort]
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Response format types accepted in an OpenAI-format "response_format".
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ParseResponseFormat decodes an OpenAI-format "response_format" for
+// providers that translate it. schema is the JSON Schema of a json_schema
+// format and nil otherwise. An absent value yields ResponseFormatText.
+func ParseResponseFormat(raw json.RawMessage) (kind string, schema json.RawMessage, err error) {
+	if len(raw) == 0 {
+		return ResponseFormatText, nil, nil
+	}
+	var rf struct {
+		Type       string `json:"type"`
+		JSONSchema *struct {
+			Name   string          `json:"name"`
+			Schema json.RawMessage `json:"schema"`
+		} `json:"json_schema"`
+	}
+	if err := json.Unmarshal(raw, &rf); err != nil {
+		return "", nil, fmt.Errorf("invalid response_format: %w", err)
+	}
+	switch rf.Type {
+	case ResponseFormatText, ResponseFormatJSONObject:
+		return rf.Type, nil, nil
+	case ResponseFormatJSONSchema:
+		if rf.JSONSchema == nil || rf.JSONSchema.Name == "" {
+			return "", nil, fmt.Errorf("response_format json_schema requires a name")
+		}
+		return rf.Type, rf.JSONSchema.Schema, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported response_format type %q", rf.Type)
+	}
+}
+
+// WantsStructuredOutput reports whether raw asks for JSON output, i.e. is a
+// json_object or json_schema format. Invalid values report false; they are
+// rejected before reaching providers.
+func WantsStructuredOutput(raw json.RawMessage) bool {
+	kind, _, err := ParseResponseFormat(raw)
+	return err == nil && kind != ResponseFormatText
+}
@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FunctionTool is the provider-neutral form of an OpenAI "function" tool,
+// for providers whose tool schema differs from OpenAI's.
+type FunctionTool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the arguments; nil when omitted.
+	Parameters json.RawMessage
+}
+
+// Tool choice modes returned by ParseToolChoice.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+	ToolChoiceFunction = "function" // a specific function, see the name result
+)
+
+// ParseFunctionTools decodes an OpenAI-format "tools" array. Only function
+// tools are supported.
+func ParseFunctionTools(raw json.RawMessage) ([]FunctionTool, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var tools []struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			Parameters  json.RawMessage `json:"parameters"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &tools); err != nil {
+		return nil, fmt.Errorf("invalid tools: %w", err)
+	}
+	out := make([]FunctionTool, len(tools))
+	for i, t := range tools {
+		if t.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type %q", t.Type)
+		}
+		if t.Function.Name == "" {
+			return nil, fmt.Errorf("tool %d has no function name", i)
+		}
+		out[i] = FunctionTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		}
+	}
+	return out, nil
+}
+
+// ParseToolChoice decodes an OpenAI-format "tool_choice": one of the strings
+// "auto", "none" and "required", or {"type":"function","function":{"name":…}}.
+// An absent value yields ToolChoiceAuto.
+func ParseToolChoice(raw json.RawMessage) (mode, name string, err error) {
+	if len(raw) == 0 {
+		return ToolChoiceAuto, "", nil
+	}
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+			return mode, "", nil
+		}
+		return "", "", fmt.Errorf("unsupported tool_choice %q", mode)
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return "", "", fmt.Errorf("invalid tool_choice")
+	}
+	return ToolChoiceFunction, named.Function.Name, nil
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// HTTPTransportConfig tunes the connection pool shared by the providers'
+// HTTP clients.
+type HTTPTransportConfig struct {
+	// MaxIdleConns caps idle connections across all upstream hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps idle connections kept per upstream host.
+	// Go's default of 2 makes most concurrent calls dial a new connection.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps all connections per upstream host; 0 means no
+	// limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes connections left idle for longer than this.
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 keeps upstream connections on HTTP/1.1.
+	DisableHTTP2 bool
+}
+
+// DefaultHTTPTransportConfig sizes the pool for many concurrent calls to a
+// handful of upstream hosts.
+var DefaultHTTPTransportConfig = HTTPTransportConfig{
+	MaxIdleConns:        512,
+	MaxIdleConnsPerHost: 128,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// HTTPTransport is the transport behind every client NewHTTPClient returns,
+// so providers share one connection pool.
+var HTTPTransport = newHTTPTransport(DefaultHTTPTransportConfig)
+
+// ApplyHTTPTransport replaces HTTPTransport with one built from cfg. It is
+// not safe for concurrent use and must run before providers are built;
+// clients created earlier keep the previous transport.
+func ApplyHTTPTransport(cfg HTTPTransportConfig) {
+	HTTPTransport = newHTTPTransport(cfg)
+}
+
+// NewHTTPClient returns a client for provider API calls that uses
+// HTTPTransport and times out after ProviderTimeout.
+func NewHTTPClient() *http.Client {
+	return &http.Client{Timeout: ProviderTimeout, Transport: HTTPTransport}
+}
+
+func newHTTPTransport(cfg HTTPTransportConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = cfg.MaxIdleConns
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	if cfg.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto turns off HTTP/2 negotiation.
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
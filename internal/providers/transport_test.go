@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApplyHTTPTransport(t *testing.T) {
+	prev := HTTPTransport
+	t.Cleanup(func() { HTTPTransport = prev })
+
+	ApplyHTTPTransport(HTTPTransportConfig{
+		MaxIdleConns:        64,
+		MaxIdleConnsPerHost: 32,
+		MaxConnsPerHost:     48,
+		IdleConnTimeout:     30 * time.Second,
+		DisableHTTP2:        true,
+	})
+
+	c := NewHTTPClient()
+	if c.Timeout != ProviderTimeout {
+		t.Errorf("Timeout = %v, want %v", c.Timeout, ProviderTimeout)
+	}
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport = %T, want *http.Transport", c.Transport)
+	}
+	if tr != HTTPTransport {
+		t.Error("client does not share HTTPTransport")
+	}
+	if tr.MaxIdleConns != 64 || tr.MaxIdleConnsPerHost != 32 || tr.MaxConnsPerHost != 48 {
+		t.Errorf("pool sizes = %d/%d/%d, want 64/32/48", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost)
+	}
+	if tr.IdleConnTimeout != 30*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want 30s", tr.IdleConnTimeout)
+	}
+	if tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Error("HTTP/2 still enabled")
+	}
+	if NewHTTPClient().Transport != tr {
+		t.Error("clients do not share one transport")
+	}
+}
+
+func TestDefaultHTTPTransportKeepsHTTP2(t *testing.T) {
+	tr := newHTTPTransport(DefaultHTTPTransportConfig)
+	if !tr.ForceAttemptHTTP2 || tr.TLSNextProto != nil {
+		t.Error("default transport should negotiate HTTP/2")
+	}
+	if tr.MaxIdleConnsPerHost != DefaultHTTPTransportConfig.MaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d, want %d", tr.MaxIdleConnsPerHost, DefaultHTTPTransportConfig.MaxIdleConnsPerHost)
+	}
+}
+
+// BenchmarkHTTPTransport compares sustained parallel throughput against a
+// single upstream host with Go's default pool and the gateway default.
+func BenchmarkHTTPTransport(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	run := func(b *testing.B, tr *http.Transport) {
+		defer tr.CloseIdleConnections()
+		c := &http.Client{Transport: tr}
+		b.SetParallelism(16)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				resp, err := c.Get(srv.URL)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+
+	b.Run("go-default", func(b *testing.B) {
+		run(b, http.DefaultTransport.(*http.Transport).Clone())
+	})
+	b.Run("gateway-default", func(b *testing.B) {
+		run(b, newHTTPTransport(DefaultHTTPTransportConfig))
+	})
+}
@@ -9,6 +9,11 @@
 // Authentication is handled via ADC:
 //   - GOOGLE_APPLICATION_CREDENTIALS pointing to a service account key file, or
 //   - Workload Identity / GCE metadata server when running on GCP.
+//
+// Model routing: model names with the "vertexai-" prefix have the prefix
+// stripped to derive the Vertex model ID. E.g. "vertexai-gemini-2.0-flash" →
+// "gemini-2.0-flash". Requests are built by the Gemini provider's translation
+// logic; embeddings go through the SDK's predict/embedContent calls.
 package vertexai
 
 import (
@@ -16,23 +21,29 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"strings"
+	"time"
 
 	"google.golang.org/genai"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/providers/gemini"
 )
 
 const (
 	defaultLocation = "us-central1"
 	providerName    = "vertexai"
+	modelPrefix     = "vertexai-"
 )
 
 // Provider implements providers.Provider for Google Vertex AI.
 type Provider struct {
-	project  string
-	location string
-	client   *genai.Client
+	project    string
+	location   string
+	baseURL    string
+	httpClient *http.Client
+	client     *genai.Client
 }
 
 // Option configures a Provider.
@@ -43,6 +54,18 @@ func WithLocation(loc string) Option {
 	return func(p *Provider) { p.location = loc }
 }
 
+// WithBaseURL overrides the regional Vertex AI endpoint (useful for testing).
+func WithBaseURL(u string) Option {
+	return func(p *Provider) { p.baseURL = u }
+}
+
+// WithHTTPClient sets the HTTP client used for API calls. The client must
+// authenticate requests itself; Application Default Credentials are not
+// consulted when one is set.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) { p.httpClient = c }
+}
+
 // New creates a new Vertex AI Provider.
 // Auth is resolved via Application Default Credentials — no API key needed.
 func New(ctx context.Context, project string, opts ...Option) (*Provider, error) {
@@ -55,9 +78,11 @@ func New(ctx context.Context, project string, opts ...Option) (*Provider, error)
 	}
 
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		Project:  p.project,
-		Location: p.location,
-		Backend:  genai.BackendVertexAI,
+		Project:     p.project,
+		Location:    p.location,
+		Backend:     genai.BackendVertexAI,
+		HTTPClient:  p.httpClient,
+		HTTPOptions: genai.HTTPOptions{BaseURL: p.baseURL},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("vertexai: create client: %w", err)
@@ -78,49 +103,19 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 }
 
 func (p *Provider) Request(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
-	contents, cfg := buildContentsAndConfig(req)
-
-	if req.Stream {
-		return p.handleStreaming(ctx, req.Model, contents, cfg)
+	if providers.WantsLogProbs(req) {
+		return nil, &providers.Error{Provider: "vertexai", StatusCode: 400, Message: "logprobs are not supported"}
 	}
-	return p.handleResponse(ctx, req, contents, cfg)
-}
 
-func buildContentsAndConfig(req *providers.ProxyRequest) ([]*genai.Content, *genai.GenerateContentConfig) {
-	var systemPrompt string
-	contents := make([]*genai.Content, 0, len(req.Messages))
-
-	for _, m := range req.Messages {
-		switch strings.ToLower(m.Role) {
-		case "system", "developer":
-			if systemPrompt != "" {
-				systemPrompt += "\n"
-			}
-			systemPrompt += m.Content
-		case "assistant", "model":
-			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleModel))
-		default:
-			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleUser))
-		}
+	contents, cfg, err := gemini.BuildContentsAndConfig(req)
+	if err != nil {
+		return nil, fmt.Errorf("vertexai: %w", err)
 	}
 
-	var cfg *genai.GenerateContentConfig
-	if systemPrompt != "" || req.Temperature > 0 || req.MaxTokens > 0 {
-		cfg = &genai.GenerateContentConfig{}
-	}
-	if cfg != nil && systemPrompt != "" {
-		cfg.SystemInstruction = &genai.Content{
-			Parts: []*genai.Part{{Text: systemPrompt}},
-		}
-	}
-	if cfg != nil && req.Temperature > 0 {
-		cfg.Temperature = genai.Ptr[float32](float32(req.Temperature))
-	}
-	if cfg != nil && req.MaxTokens > 0 {
-		cfg.MaxOutputTokens = int32(req.MaxTokens)
+	if req.Stream {
+		return p.handleStreaming(ctx, modelID(req.Model), contents, cfg)
 	}
-
-	return contents, cfg
+	return p.handleResponse(ctx, req, contents, cfg)
 }
 
 func (p *Provider) handleResponse(
@@ -129,7 +124,7 @@ func (p *Provider) handleResponse(
 	contents []*genai.Content,
 	cfg *genai.GenerateContentConfig,
 ) (*providers.ProxyResponse, error) {
-	resp, err := p.client.Models.GenerateContent(ctx, req.Model, contents, cfg)
+	resp, err := p.client.Models.GenerateContent(ctx, modelID(req.Model), contents, cfg)
 	if err != nil {
 		return nil, toProviderError(err)
 	}
@@ -143,9 +138,14 @@ func (p *Provider) handleResponse(
 		}
 	}
 
-	out := ""
+	out, finish := "", ""
+	var toolCalls []providers.ToolCall
 	if resp != nil {
 		out = resp.Text()
+		if len(resp.Candidates) > 0 && resp.Candidates[0] != nil {
+			toolCalls = gemini.FunctionCalls(resp.Candidates[0])
+			finish = string(resp.Candidates[0].FinishReason)
+		}
 	}
 
 	var inTok, outTok int
@@ -155,9 +155,11 @@ func (p *Provider) handleResponse(
 	}
 
 	return &providers.ProxyResponse{
-		ID:      id,
-		Model:   req.Model,
-		Content: out,
+		ID:           id,
+		Model:        req.Model,
+		Content:      out,
+		ToolCalls:    toolCalls,
+		FinishReason: finish,
 		Usage: providers.Usage{
 			InputTokens:  inTok,
 			OutputTokens: outTok,
@@ -176,67 +178,135 @@ func (p *Provider) handleStreaming(
 	go func() {
 		defer close(ch)
 
+		// usageMetadata is cumulative; the last one seen is reported once the
+		// stream ends.
+		var usage *providers.Usage
+
 		for resp, err := range p.client.Models.GenerateContentStream(ctx, model, contents, cfg) {
 			if err != nil {
-				ch <- providers.StreamChunk{
-					Content:      fmt.Sprintf("[stream error] %v", err),
-					FinishReason: "error",
-				}
+				ch <- providers.StreamChunk{Err: err}
 				return
 			}
+			if resp != nil && resp.UsageMetadata != nil {
+				usage = &providers.Usage{
+					InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
+					OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+				}
+			}
 			if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
 				continue
 			}
 
 			c := resp.Candidates[0]
-			text := firstCandidateText(c)
+			text := gemini.CandidateText(c)
 			finish := string(c.FinishReason)
 
 			if text != "" || finish != "" {
 				ch <- providers.StreamChunk{Content: text, FinishReason: finish}
 			}
 		}
+		if usage != nil {
+			ch <- providers.StreamChunk{Usage: usage}
+		}
 	}()
 
 	return &providers.ProxyResponse{Stream: ch}, nil
 }
 
-func firstCandidateText(c *genai.Candidate) string {
-	if c == nil || c.Content == nil || len(c.Content.Parts) == 0 {
-		return ""
+// Embed implements providers.EmbeddingProvider.
+// Text embedding models (text-embedding-004, textembedding-gecko) accept a
+// batch per predict call; Gemini embedding models take one input per call,
+// so those are embedded one at a time.
+func (p *Provider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	model := modelID(req.Model)
+	contents := make([]*genai.Content, len(req.Input))
+	for i, text := range req.Input {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
 	}
-	var sb strings.Builder
-	for _, p := range c.Content.Parts {
-		if p != nil && p.Text != "" {
-			sb.WriteString(p.Text)
+
+	batches := [][]*genai.Content{contents}
+	if strings.Contains(model, "gemini") {
+		batches = make([][]*genai.Content, len(contents))
+		for i, c := range contents {
+			batches[i] = []*genai.Content{c}
 		}
 	}
-	return sb.String()
-}
 
-func generateID() string {
-	return fmt.Sprintf("vertexai-%x", rand.Int63())
+	data := make([]providers.EmbeddingData, 0, len(contents))
+	var tokens int
+	for _, batch := range batches {
+		resp, err := p.client.Models.EmbedContent(ctx, model, batch, embedConfig(req.Dimensions))
+		if err != nil {
+			return nil, fmt.Errorf("vertexai: embed: %w", toProviderError(err))
+		}
+		if resp == nil || len(resp.Embeddings) == 0 {
+			return nil, fmt.Errorf("vertexai: embed: empty response")
+		}
+		for _, emb := range resp.Embeddings {
+			d := providers.EmbeddingData{Index: len(data)}
+			if emb != nil {
+				d.Embedding = emb.Values
+				if emb.Statistics != nil {
+					tokens += int(emb.Statistics.TokenCount)
+				}
+			}
+			data = append(data, d)
+		}
+	}
+
+	return &providers.EmbeddingResponse{
+		Model: req.Model,
+		Data:  data,
+		Usage: providers.Usage{InputTokens: tokens},
+	}, nil
 }
 
-// ProviderError wraps a Vertex AI API error.
-type ProviderError struct {
-	StatusCode int
-	Message    string
+// embedConfig forwards the requested output dimensionality, if any.
+func embedConfig(dimensions *int) *genai.EmbedContentConfig {
+	if dimensions == nil {
+		return nil
+	}
+	n := int32(*dimensions)
+	return &genai.EmbedContentConfig{OutputDimensionality: &n}
 }
 
-func (e *ProviderError) Error() string {
-	return fmt.Sprintf("vertexai: %s (status=%d)", e.Message, e.StatusCode)
+// modelID strips the "vertexai-" routing prefix, yielding the Vertex model ID.
+func modelID(model string) string {
+	return strings.TrimPrefix(model, modelPrefix)
 }
 
-func (e *ProviderError) HTTPStatus() int { return e.StatusCode }
+func generateID() string {
+	return fmt.Sprintf("vertexai-%x", rand.Int63())
+}
 
 func toProviderError(err error) error {
 	var apiErr genai.APIError
 	if errors.As(err, &apiErr) {
-		return &ProviderError{
+		return &providers.Error{
+			Provider:   "vertexai",
 			StatusCode: apiErr.Code,
 			Message:    apiErr.Message,
+			Type:       apiErr.Status,
+			Code:       fmt.Sprintf("%d", apiErr.Code),
+			RetryAfter: retryInfoDelay(apiErr.Details),
 		}
 	}
 	return err
 }
+
+// retryInfoDelay extracts the retryDelay from a google.rpc.RetryInfo error
+// detail. Google APIs send the backoff hint there rather than as a
+// Retry-After header, which the genai SDK does not expose anyway.
+func retryInfoDelay(details []map[string]any) time.Duration {
+	for _, d := range details {
+		if t, _ := d["@type"].(string); !strings.HasSuffix(t, "google.rpc.RetryInfo") {
+			continue
+		}
+		if s, _ := d["retryDelay"].(string); s != "" {
+			if dur, err := time.ParseDuration(s); err == nil && dur > 0 {
+				return dur
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package vertexai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+const modelsPath = "/v1beta1/projects/test-project/locations/us-central1/publishers/google/models/"
+
+func newTestProvider(t *testing.T, srv *httptest.Server) *Provider {
+	t.Helper()
+	p, err := New(context.Background(), "test-project",
+		WithBaseURL(srv.URL), WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestProvider_Request_Streaming(t *testing.T) {
+	chunks := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2,"totalTokenCount":6}}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := modelsPath + "gemini-2.0-flash:streamGenerateContent"; r.URL.Path != want {
+			t.Errorf("path = %q, want %q", r.URL.Path, want)
+		}
+		if r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("expected alt=sse query param, got %q", r.URL.Query().Get("alt"))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := newTestProvider(t, srv).Request(context.Background(), &providers.ProxyRequest{
+		Model:    "vertexai-gemini-2.0-flash",
+		Messages: []providers.Message{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Stream == nil {
+		t.Fatal("expected non-nil Stream channel")
+	}
+
+	var content, finish string
+	var usage *providers.Usage
+	for chunk := range resp.Stream {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", content)
+	}
+	if finish != "STOP" {
+		t.Errorf("expected finish reason 'STOP', got %q", finish)
+	}
+	if usage == nil || usage.InputTokens != 4 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage {4 2}, got %+v", usage)
+	}
+}
+
+func TestProvider_Request_ToolCalls(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"id":"call_1","name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}]}`)
+	}))
+	defer srv.Close()
+
+	resp, err := newTestProvider(t, srv).Request(context.Background(), &providers.ProxyRequest{
+		Model:    "vertexai-gemini-2.0-flash",
+		Messages: []providers.Message{{Role: "user", Content: "Weather in Paris?"}},
+		Tools:    json.RawMessage(`[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}]`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tools, _ := body["tools"].([]any); len(tools) != 1 {
+		t.Errorf("tools = %v, want one function declaration", body["tools"])
+	}
+	if resp.Model != "vertexai-gemini-2.0-flash" {
+		t.Errorf("Model = %q, want the requested model", resp.Model)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" ||
+		resp.ToolCalls[0].Arguments != `{"city":"Paris"}` {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+}
+
+func TestProvider_Embed(t *testing.T) {
+	var instances []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := modelsPath + "text-embedding-004:predict"; r.URL.Path != want {
+			t.Errorf("path = %q, want %q", r.URL.Path, want)
+		}
+		var body struct {
+			Instances []any `json:"instances"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		instances = body.Instances
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"predictions":[`+
+			`{"embeddings":{"values":[0.1,0.2],"statistics":{"token_count":2}}},`+
+			`{"embeddings":{"values":[0.3,0.4],"statistics":{"token_count":3}}}]}`)
+	}))
+	defer srv.Close()
+
+	var _ providers.EmbeddingProvider = (*Provider)(nil)
+	resp, err := newTestProvider(t, srv).Embed(context.Background(), &providers.EmbeddingRequest{
+		Model: "vertexai-text-embedding-004",
+		Input: []string{"hello", "world"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(instances) != 2 {
+		t.Errorf("sent %d instances, want 2 in one batch", len(instances))
+	}
+	if resp.Model != "vertexai-text-embedding-004" {
+		t.Errorf("Model = %q, want the requested model", resp.Model)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].Index != 1 || resp.Data[1].Embedding[1] != 0.4 {
+		t.Errorf("Data = %+v", resp.Data)
+	}
+	if resp.Usage.InputTokens != 5 {
+		t.Errorf("InputTokens = %d, want 5", resp.Usage.InputTokens)
+	}
+}
+
+func TestProvider_Embed_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer srv.Close()
+
+	_, err := newTestProvider(t, srv).Embed(context.Background(), &providers.EmbeddingRequest{
+		Model: "text-embedding-004",
+		Input: []string{"hello"},
+	})
+	var pe *providers.Error
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("err = %v, want quota error", err)
+	}
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %T, want *providers.Error", err)
+	}
+	if pe.Provider != "vertexai" || pe.HTTPStatus() != http.StatusTooManyRequests ||
+		pe.Type != "RESOURCE_EXHAUSTED" || pe.Code != "429" {
+		t.Errorf("err = %+v, want vertexai 429 RESOURCE_EXHAUSTED", pe)
+	}
+}
+
+func TestModelID(t *testing.T) {
+	cases := map[string]string{
+		"vertexai-gemini-2.5-pro":     "gemini-2.5-pro",
+		"vertexai-text-embedding-004": "text-embedding-004",
+		"gemini-2.5-pro":              "gemini-2.5-pro",
+	}
+	for in, want := range cases {
+		if got := modelID(in); got != want {
+			t.Errorf("modelID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
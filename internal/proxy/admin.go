@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// handleCircuitBreakerReset handles POST /admin/circuit-breaker/reset. With a
+// {"provider":"openai"} body it closes that provider's breakers, including
+// its per-model ones, and {"provider":"openai/gpt-4o"} closes just one model's
+// breaker; with no body or no provider it closes all of them.
+func (g *Gateway) handleCircuitBreakerReset(ctx *fasthttp.RequestCtx) {
+	var req struct {
+		Provider string `json:"provider"`
+	}
+	if body := ctx.PostBody(); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			apierr.Write(ctx, fasthttp.StatusBadRequest,
+				fmt.Sprintf("invalid JSON: %s", err.Error()),
+				apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+			return
+		}
+	}
+
+	var reset []string
+	if req.Provider == "" {
+		reset = g.cb.ResetAll()
+	} else {
+		reset = g.cb.Reset(req.Provider)
+		if len(reset) == 0 {
+			apierr.Write(ctx, fasthttp.StatusNotFound,
+				fmt.Sprintf("no circuit breaker for provider %q", req.Provider),
+				apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+			return
+		}
+	}
+
+	if g.metrics != nil {
+		for _, key := range reset {
+			g.setCircuitBreakerGauge(key)
+		}
+	}
+	g.log.InfoContext(ctx, "circuit_breaker_reset", slog.Any("providers", reset))
+
+	body, _ := json.Marshal(struct {
+		Reset []string `json:"reset"`
+		State string   `json:"state"`
+	}{Reset: reset, State: "closed"})
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body)
+}
+
+// cacheStatsResponse is the body of GET /admin/cache/stats.
+type cacheStatsResponse struct {
+	cache.Stats
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// handleCacheStats handles GET /admin/cache/stats: the backend's entry count
+// and memory use plus the hit and miss counts since startup.
+func (g *Gateway) handleCacheStats(ctx *fasthttp.RequestCtx) {
+	out := cacheStatsResponse{
+		Stats:  cache.Stats{Backend: "none"},
+		Hits:   g.cacheHits.Load(),
+		Misses: g.cacheMisses.Load(),
+	}
+	if total := out.Hits + out.Misses; total > 0 {
+		out.HitRate = float64(out.Hits) / float64(total)
+	}
+	if g.cache != nil {
+		st, err := g.cache.Stats(ctx)
+		if err != nil {
+			g.log.ErrorContext(ctx, "cache_stats_error", slog.String("error", err.Error()))
+			apierr.Write(ctx, fasthttp.StatusInternalServerError,
+				"failed to read cache stats", apierr.TypeServerError, apierr.CodeInternalError)
+			return
+		}
+		out.Stats = st
+	}
+
+	body, _ := json.Marshal(out)
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body)
+}
+
+// handleCacheFlush handles DELETE /admin/cache. With ?key=<cache key> it
+// deletes that entry only; otherwise it flushes the whole cache.
+func (g *Gateway) handleCacheFlush(ctx *fasthttp.RequestCtx) {
+	if g.cache == nil {
+		apierr.Write(ctx, fasthttp.StatusNotFound,
+			"cache is disabled", apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	key := string(ctx.QueryArgs().Peek("key"))
+	var (
+		resp any
+		err  error
+	)
+	if key != "" {
+		err = g.cache.Delete(ctx, key)
+		resp = struct {
+			Deleted string `json:"deleted"`
+		}{Deleted: key}
+	} else {
+		err = g.cache.Flush(ctx)
+		resp = struct {
+			Flushed bool `json:"flushed"`
+		}{Flushed: true}
+	}
+	if err != nil {
+		g.log.ErrorContext(ctx, "cache_flush_error", slog.String("error", err.Error()))
+		apierr.Write(ctx, fasthttp.StatusInternalServerError,
+			"failed to flush cache", apierr.TypeServerError, apierr.CodeInternalError)
+		return
+	}
+	g.log.InfoContext(ctx, "cache_flushed", slog.String("key", key))
+
+	body, _ := json.Marshal(resp)
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body)
+}
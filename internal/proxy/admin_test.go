@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+func tripBreaker(gw *Gateway, name string) {
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		gw.cb.RecordFailure(name)
+	}
+}
+
+func TestHandleCircuitBreakerReset_Provider(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	defer gw.health.Close()
+	tripBreaker(gw, "openai")
+	tripBreaker(gw, "anthropic")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"provider":"openai"}`)
+	gw.handleCircuitBreakerReset(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d, body = %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if !gw.cb.Allow("openai") {
+		t.Error("openai breaker should allow requests after reset")
+	}
+	if gw.cb.State("anthropic") != cbOpen {
+		t.Error("resetting one provider must not close the others")
+	}
+}
+
+func TestHandleCircuitBreakerReset_All(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	defer gw.health.Close()
+	tripBreaker(gw, "openai")
+	tripBreaker(gw, "anthropic")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	gw.handleCircuitBreakerReset(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d", ctx.Response.StatusCode())
+	}
+	for _, name := range []string{"openai", "anthropic"} {
+		if !gw.cb.Allow(name) {
+			t.Errorf("%s breaker should allow requests after reset", name)
+		}
+	}
+}
+
+func TestHandleCircuitBreakerReset_UnknownProvider(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	defer gw.health.Close()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{"provider":"nope"}`)
+	gw.handleCircuitBreakerReset(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("status = %d, want 404", ctx.Response.StatusCode())
+	}
+}
+
+func TestHandleCacheStats(t *testing.T) {
+	c := newStubCache()
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, c)
+	defer gw.health.Close()
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	for range 2 {
+		readBody(t, doPost(t, client, "/v1/chat/completions", body))
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleCacheStats(ctx)
+	var out cacheStatsResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Backend != "stub" || out.Entries != 1 || out.Hits != 1 || out.Misses != 1 || out.HitRate != 0.5 {
+		t.Errorf("stats = %+v, want 1 entry, 1 hit, 1 miss", out)
+	}
+}
+
+func TestHandleCacheFlush(t *testing.T) {
+	c := newStubCache()
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, c)
+	defer gw.health.Close()
+	ctx := context.Background()
+	_ = c.Set(ctx, "cache:a", []byte("1"), 0)
+	_ = c.Set(ctx, "cache:b", []byte("2"), 0)
+	_ = c.Set(ctx, "cache:c", []byte("3"), 0)
+
+	req := &fasthttp.RequestCtx{}
+	req.Request.SetRequestURI("/admin/cache?key=cache:a")
+	gw.handleCacheFlush(req)
+	if req.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d", req.Response.StatusCode())
+	}
+	if _, ok := c.Get(ctx, "cache:a"); ok {
+		t.Error("cache:a should be deleted")
+	}
+	if len(c.store) != 2 {
+		t.Fatalf("entries = %d, want 2 after single-key delete", len(c.store))
+	}
+
+	req = &fasthttp.RequestCtx{}
+	req.Request.SetRequestURI("/admin/cache")
+	gw.handleCacheFlush(req)
+	if req.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d", req.Response.StatusCode())
+	}
+	if len(c.store) != 0 {
+		t.Errorf("entries = %d, want 0 after flush", len(c.store))
+	}
+}
+
+func TestHandleCacheFlush_CacheDisabled(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	defer gw.health.Close()
+
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleCacheFlush(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("status = %d, want 404", ctx.Response.StatusCode())
+	}
+}
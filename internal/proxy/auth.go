@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// GatewayKeyHashPrefix marks a GATEWAY_API_KEYS entry given as the hex SHA-256
+// of the key rather than the key itself.
+const GatewayKeyHashPrefix = "sha256:"
+
+// gatewayKeyHeader carries the gateway key when Authorization is needed for
+// a client-supplied provider key (AllowClientAPIKeys).
+const gatewayKeyHeader = "X-Gateway-API-Key"
+
+// User values set by authenticate for downstream handlers.
+const (
+	callerIDKey      = "caller_id"
+	gatewayAuthInKey = "gateway_key_in_authorization"
+)
+
+// gatewayKeySet holds the accepted gateway keys as hex SHA-256 digests, so
+// plaintext keys are not kept in memory longer than needed.
+type gatewayKeySet map[string]struct{}
+
+// newGatewayKeySet builds the key set from plaintext keys and "sha256:<hex>"
+// digests. It returns nil when keys is empty, which disables authentication.
+func newGatewayKeySet(keys []string) gatewayKeySet {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(gatewayKeySet, len(keys))
+	for _, k := range keys {
+		if digest, ok := strings.CutPrefix(k, GatewayKeyHashPrefix); ok {
+			set[strings.ToLower(digest)] = struct{}{}
+			continue
+		}
+		set[hashKey(k)] = struct{}{}
+	}
+	return set
+}
+
+// lookup reports whether token is an accepted key and returns the caller ID
+// derived from it: a short digest prefix that is safe to log.
+func (s gatewayKeySet) lookup(token string) (callerID string, ok bool) {
+	digest := hashKey(token)
+	if _, ok := s[digest]; !ok {
+		return "", false
+	}
+	return "gk_" + digest[:16], true
+}
+
+func hashKey(k string) string {
+	sum := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate rejects /v1/ requests that do not carry a valid gateway or
+// virtual key, read from X-Gateway-API-Key or else the Authorization bearer
+// token. /admin/ routes accept gateway keys only and are refused outright
+// when none are configured; /stats also accepts gateway keys only, but is
+// open when none are configured. Health endpoints stay open, and metrics
+// are guarded separately (see metricsAuth). /v1/ is open when neither
+// gateway nor virtual keys are set.
+func (g *Gateway) authenticate(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		path := string(ctx.Path())
+		admin := strings.HasPrefix(path, "/admin/")
+		masterOnly := admin || (path == "/stats" && g.gatewayKeys != nil)
+		switch {
+		case admin && g.gatewayKeys == nil:
+			apierr.Write(ctx, fasthttp.StatusForbidden,
+				"admin API is disabled; set GATEWAY_API_KEYS to enable it",
+				apierr.TypePermissionError, apierr.CodeInvalidAPIKey)
+			return
+		case !masterOnly && (!strings.HasPrefix(path, "/v1/") || (g.gatewayKeys == nil && g.virtualKeys == nil)):
+			next(ctx)
+			return
+		}
+
+		token := strings.TrimSpace(string(ctx.Request.Header.Peek(gatewayKeyHeader)))
+		fromAuthorization := false
+		if token == "" {
+			token = parseBearerToken(strings.TrimSpace(string(ctx.Request.Header.Peek("Authorization"))))
+			fromAuthorization = true
+		}
+		if token == "" {
+			apierr.Write(ctx, fasthttp.StatusUnauthorized,
+				"missing gateway API key", apierr.TypeAuthenticationErr, apierr.CodeInvalidAPIKey)
+			return
+		}
+
+		callerID, ok := g.gatewayKeys.lookup(token)
+		if !ok && !masterOnly {
+			if vk := g.virtualKeys[hashKey(token)]; vk != nil {
+				callerID, ok = vk.callerID(), true
+				ctx.SetUserValue(virtualKeyUserValue, vk)
+			}
+		}
+		if !ok {
+			apierr.Write(ctx, fasthttp.StatusUnauthorized,
+				"invalid gateway API key", apierr.TypeAuthenticationErr, apierr.CodeInvalidAPIKey)
+			return
+		}
+
+		ctx.SetUserValue(callerIDKey, callerID)
+		ctx.SetUserValue(gatewayAuthInKey, fromAuthorization)
+		next(ctx)
+	}
+}
+
+// callerID returns the caller identified by authenticate, or "" when gateway
+// authentication is disabled.
+func callerID(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(callerIDKey).(string)
+	return id
+}
+
+// MetricsAuth holds the credentials required by GET /metrics. Token is
+// checked as an Authorization bearer token, User and Password as HTTP basic
+// credentials; either is accepted when both are set. The zero value leaves
+// the endpoint open.
+type MetricsAuth struct {
+	Token    string
+	User     string
+	Password string
+}
+
+func (a MetricsAuth) enabled() bool { return a.Token != "" || a.User != "" }
+
+// metricsAuth guards next with auth, answering 401 when the request does not
+// carry a configured credential. Credentials are compared in constant time.
+func metricsAuth(auth MetricsAuth, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !auth.enabled() {
+		return next
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		header := strings.TrimSpace(string(ctx.Request.Header.Peek("Authorization")))
+		if auth.Token != "" && secretEqual(parseBearerToken(header), auth.Token) {
+			next(ctx)
+			return
+		}
+		if auth.User != "" {
+			if user, password, ok := parseBasicAuth(header); ok &&
+				secretEqual(user, auth.User) && secretEqual(password, auth.Password) {
+				next(ctx)
+				return
+			}
+			ctx.Response.Header.Set("WWW-Authenticate", `Basic realm="metrics"`)
+		} else {
+			ctx.Response.Header.Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		}
+		apierr.Write(ctx, fasthttp.StatusUnauthorized,
+			"metrics require authentication", apierr.TypeAuthenticationErr, apierr.CodeInvalidAPIKey)
+	}
+}
+
+// parseBasicAuth decodes an "Authorization: Basic" header value.
+func parseBasicAuth(header string) (user, password string, ok bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// secretEqual compares digests of a and b in constant time, so neither the
+// content nor the length of a secret leaks through timing.
+func secretEqual(a, b string) bool {
+	da, db := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(da[:], db[:]) == 1
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+// authCtx builds a request for path carrying the given headers.
+func authCtx(path string, headers map[string]string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	for k, v := range headers {
+		ctx.Request.Header.Set(k, v)
+	}
+	return ctx
+}
+
+func TestAuthenticate(t *testing.T) {
+	gw := &Gateway{gatewayKeys: newGatewayKeySet([]string{
+		"gw-secret",
+		GatewayKeyHashPrefix + hashKey("hashed-secret"),
+	})}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"valid bearer", "/v1/chat/completions", map[string]string{"Authorization": "Bearer gw-secret"}, fasthttp.StatusOK},
+		{"valid hashed key", "/v1/chat/completions", map[string]string{"Authorization": "Bearer hashed-secret"}, fasthttp.StatusOK},
+		{"valid header", "/v1/models", map[string]string{gatewayKeyHeader: "gw-secret"}, fasthttp.StatusOK},
+		{"invalid key", "/v1/chat/completions", map[string]string{"Authorization": "Bearer nope"}, fasthttp.StatusUnauthorized},
+		{"missing key", "/v1/chat/completions", nil, fasthttp.StatusUnauthorized},
+		{"malformed header", "/v1/chat/completions", map[string]string{"Authorization": "gw-secret"}, fasthttp.StatusUnauthorized},
+		{"health stays open", "/health", nil, fasthttp.StatusOK},
+		{"stats needs a key", "/stats", nil, fasthttp.StatusUnauthorized},
+		{"stats with gateway key", "/stats", map[string]string{"Authorization": "Bearer gw-secret"}, fasthttp.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := gw.authenticate(func(ctx *fasthttp.RequestCtx) {
+				called = true
+				ctx.SetStatusCode(fasthttp.StatusOK)
+			})
+			ctx := authCtx(tt.path, tt.headers)
+			handler(ctx)
+
+			if got := ctx.Response.StatusCode(); got != tt.want {
+				t.Fatalf("status = %d, want %d; body = %s", got, tt.want, ctx.Response.Body())
+			}
+			if called != (tt.want == fasthttp.StatusOK) {
+				t.Errorf("next called = %v", called)
+			}
+			if tt.want == fasthttp.StatusUnauthorized && !containsStr(string(ctx.Response.Body()), "authentication_error") {
+				t.Errorf("expected an authentication_error body, got %s", ctx.Response.Body())
+			}
+		})
+	}
+}
+
+func TestAuthenticate_SetsCallerID(t *testing.T) {
+	gw := &Gateway{gatewayKeys: newGatewayKeySet([]string{"gw-a", "gw-b"})}
+	var ids []string
+	handler := gw.authenticate(func(ctx *fasthttp.RequestCtx) {
+		ids = append(ids, callerID(ctx))
+	})
+	handler(authCtx("/v1/chat/completions", map[string]string{"Authorization": "Bearer gw-a"}))
+	handler(authCtx("/v1/chat/completions", map[string]string{"Authorization": "Bearer gw-b"}))
+
+	if len(ids) != 2 || ids[0] == "" || ids[0] == ids[1] {
+		t.Fatalf("caller IDs = %q, want two distinct non-empty IDs", ids)
+	}
+	if containsStr(ids[0], "gw-a") {
+		t.Errorf("caller ID %q must not contain the key", ids[0])
+	}
+}
+
+func TestAuthenticate_DisabledWithoutKeys(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), nil, nil, nil, GatewayOptions{})
+	called := false
+	gw.authenticate(func(*fasthttp.RequestCtx) { called = true })(authCtx("/v1/chat/completions", nil))
+	if !called {
+		t.Error("requests should pass through when no gateway keys are configured")
+	}
+}
+
+// A gateway key sent as the bearer token must not be forwarded upstream as a
+// client provider key; one sent in X-Gateway-API-Key leaves Authorization
+// free for passthrough.
+func TestAuthenticate_ClientAPIKeyPassthroughIndependent(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{}, nil, nil, GatewayOptions{
+		AllowClientAPIKeys: true,
+		GatewayAPIKeys:     []string{"gw-secret"},
+	})
+
+	var token string
+	handler := gw.authenticate(func(ctx *fasthttp.RequestCtx) {
+		token, _ = gw.extractClientAPIKey(ctx)
+	})
+
+	handler(authCtx("/v1/chat/completions", map[string]string{"Authorization": "Bearer gw-secret"}))
+	if token != "" {
+		t.Errorf("gateway key forwarded as provider key: %q", token)
+	}
+
+	handler(authCtx("/v1/chat/completions", map[string]string{
+		gatewayKeyHeader: "gw-secret",
+		"Authorization":  "Bearer sk-provider",
+	}))
+	if token != "sk-provider" {
+		t.Errorf("provider key = %q, want sk-provider", token)
+	}
+
+	// With the gateway key in Authorization, the provider key may still come
+	// from X-API-Key.
+	handler(authCtx("/v1/chat/completions", map[string]string{
+		"Authorization": "Bearer gw-secret",
+		"X-API-Key":     "sk-provider",
+	}))
+	if token != "sk-provider" {
+		t.Errorf("provider key = %q, want sk-provider", token)
+	}
+}
+
+func TestMetricsAuth(t *testing.T) {
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	tests := []struct {
+		name   string
+		auth   MetricsAuth
+		header string
+		want   int
+	}{
+		{"open by default", MetricsAuth{}, "", fasthttp.StatusOK},
+		{"token missing", MetricsAuth{Token: "m-secret"}, "", fasthttp.StatusUnauthorized},
+		{"token wrong", MetricsAuth{Token: "m-secret"}, "Bearer nope", fasthttp.StatusUnauthorized},
+		{"token valid", MetricsAuth{Token: "m-secret"}, "Bearer m-secret", fasthttp.StatusOK},
+		{"basic valid", MetricsAuth{User: "prom", Password: "pw"}, basic("prom", "pw"), fasthttp.StatusOK},
+		{"basic wrong password", MetricsAuth{User: "prom", Password: "pw"}, basic("prom", "nope"), fasthttp.StatusUnauthorized},
+		{"basic not accepted for token", MetricsAuth{Token: "m-secret"}, basic("prom", "m-secret"), fasthttp.StatusUnauthorized},
+		{"either accepted", MetricsAuth{Token: "m-secret", User: "prom", Password: "pw"}, basic("prom", "pw"), fasthttp.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := metricsAuth(tt.auth, func(ctx *fasthttp.RequestCtx) {
+				ctx.SetStatusCode(fasthttp.StatusOK)
+			})
+			headers := map[string]string{}
+			if tt.header != "" {
+				headers["Authorization"] = tt.header
+			}
+			ctx := authCtx("/metrics", headers)
+			handler(ctx)
+
+			if got := ctx.Response.StatusCode(); got != tt.want {
+				t.Fatalf("status = %d, want %d; body = %s", got, tt.want, ctx.Response.Body())
+			}
+			if tt.want == fasthttp.StatusUnauthorized && len(ctx.Response.Header.Peek("WWW-Authenticate")) == 0 {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
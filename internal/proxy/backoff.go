@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// BackoffConfig holds the delay applied between failover attempts. Zero
+// values fall back to the package-level defaults defined in
+// providers/provider.go.
+type BackoffConfig struct {
+	// BaseDelay is the upper bound of the delay before the first retry.
+	// Default: providers.BackoffBaseDelay (50ms).
+	BaseDelay time.Duration
+
+	// MaxDelay caps the exponential growth of the delay.
+	// Default: providers.BackoffMaxDelay (2s).
+	MaxDelay time.Duration
+
+	// Multiplier is the growth factor applied per retry. Must be ≥ 1.
+	// Default: providers.BackoffMultiplier (2).
+	Multiplier float64
+}
+
+func (c *BackoffConfig) baseDelay() time.Duration {
+	if c.BaseDelay > 0 {
+		return c.BaseDelay
+	}
+	return providers.BackoffBaseDelay
+}
+
+func (c *BackoffConfig) maxDelay() time.Duration {
+	if c.MaxDelay > 0 {
+		return c.MaxDelay
+	}
+	return providers.BackoffMaxDelay
+}
+
+func (c *BackoffConfig) multiplier() float64 {
+	if c.Multiplier >= 1 {
+		return c.Multiplier
+	}
+	return providers.BackoffMultiplier
+}
+
+// ceiling returns the maximum delay before retry number n (0-based):
+// min(MaxDelay, BaseDelay × Multiplier^n).
+func (c *BackoffConfig) ceiling(n int) time.Duration {
+	d := float64(c.baseDelay()) * math.Pow(c.multiplier(), float64(n))
+	if max := float64(c.maxDelay()); d > max {
+		return c.maxDelay()
+	}
+	return time.Duration(d)
+}
+
+// delay returns a "full jitter" delay for retry number n: a uniformly random
+// duration in [0, ceiling(n)). Spreading retries this way keeps concurrent
+// requests from stampeding a recovering provider in lockstep.
+func (c *BackoffConfig) delay(n int) time.Duration {
+	ceil := c.ceiling(n)
+	if ceil <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceil)))
+}
+
+// sleepCtx waits for d or until ctx is done, whichever comes first.
+// Returns ctx.Err() if the context ended the wait.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -114,7 +114,7 @@ func benchDispatchChat(b *testing.B, gw *Gateway, concurrency int) {
 				Messages:  []providers.Message{{Role: "user", Content: "hello"}},
 				RequestID: "bench",
 			}
-			resp, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+			resp, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 			elapsed := time.Since(start)
 
 			if err != nil {
@@ -175,7 +175,7 @@ func TestProxyOverheadSLA(t *testing.T) {
 			RequestID: fmt.Sprintf("sla-%d", i),
 		}
 		start := time.Now()
-		_, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+		_, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 		elapsed := time.Since(start)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
@@ -219,7 +219,7 @@ func TestCircuitBreakerIntegration(t *testing.T) {
 
 // TestFailoverCandidateList checks buildCandidateList deduplication.
 func TestFailoverCandidateList(t *testing.T) {
-	candidates := buildCandidateList("anthropic")
+	candidates := buildCandidateList("anthropic", nil)
 	if candidates[0] != "anthropic" {
 		t.Errorf("primary should be first, got %s", candidates[0])
 	}
@@ -261,7 +261,7 @@ func TestFailoverRetries(t *testing.T) {
 		Model: "gpt-4o", Messages: []providers.Message{{Role: "user", Content: "hi"}},
 		RequestID: "mock-failover",
 	}
-	resp, usedProv, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 
 	if err != nil {
 		t.Fatalf("expected successful failover, got error: %v", err)
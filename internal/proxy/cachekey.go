@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// cacheKeyInput is everything in a request that can change its response.
+// buildCacheKey hashes its JSON encoding, so two requests share a cache entry
+// only when every field matches. A providers.ProxyRequest field that affects
+// the output must be added here; TestCacheKeyInput_CoversProxyRequest fails
+// until it is, or until it is listed there as irrelevant to the response.
+//
+// Stream is deliberately absent: a stream:true request is served from, and
+// warms, the entry of its non-streaming equivalent.
+type cacheKeyInput struct {
+	// The scope: responses are never shared across workspaces or client
+	// keys, nor across providers that happen to share a model name.
+	WorkspaceID string `json:"w"`
+	APIKeyID    string `json:"k"`
+	Provider    string `json:"p"`
+	Model       string `json:"m"`
+
+	// Messages are encoded in full and in order; new Message fields are
+	// picked up automatically.
+	Messages []providers.Message `json:"msgs"`
+
+	Temperature      float64  `json:"t"`
+	MaxTokens        int      `json:"mt"`
+	N                int      `json:"n,omitempty"`
+	TopP             *float64 `json:"tp,omitempty"`
+	Stop             []string `json:"s,omitempty"`
+	PresencePenalty  *float64 `json:"pp,omitempty"`
+	FrequencyPenalty *float64 `json:"fp,omitempty"`
+	Seed             *int64   `json:"sd,omitempty"`
+	LogProbs         *bool    `json:"lp,omitempty"`
+	TopLogProbs      *int     `json:"tlp,omitempty"`
+
+	// Client JSON is canonicalised (see canonicalJSON) so that key order
+	// and whitespace do not split otherwise identical requests.
+	Tools          json.RawMessage `json:"tl,omitempty"`
+	ToolChoice     json.RawMessage `json:"tc,omitempty"`
+	ResponseFormat json.RawMessage `json:"rf,omitempty"`
+
+	ReasoningEffort string `json:"re,omitempty"`
+	ThinkingBudget  int    `json:"tb,omitempty"`
+}
+
+// newCacheKeyInput extracts the cache-relevant fields of req.
+func newCacheKeyInput(req *providers.ProxyRequest) cacheKeyInput {
+	return cacheKeyInput{
+		WorkspaceID:      req.WorkspaceID,
+		APIKeyID:         req.APIKeyID,
+		Provider:         resolveProvider(req.Model),
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		N:                req.N,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		LogProbs:         req.LogProbs,
+		TopLogProbs:      req.TopLogProbs,
+		Tools:            canonicalJSON(req.Tools),
+		ToolChoice:       canonicalJSON(req.ToolChoice),
+		ResponseFormat:   canonicalJSON(req.ResponseFormat),
+		ReasoningEffort:  req.ReasoningEffort,
+		ThinkingBudget:   req.ThinkingBudget,
+	}
+}
+
+// buildCacheKey returns a deterministic SHA-256 cache key for the request,
+// prefixed with namespace (GatewayOptions.KeyNamespace).
+func buildCacheKey(namespace string, req *providers.ProxyRequest) string {
+	data, _ := json.Marshal(newCacheKeyInput(req))
+	h := sha256.Sum256(data)
+	return namespace + "cache:" + hex.EncodeToString(h[:])
+}
+
+// canonicalJSON re-encodes raw with object keys sorted and insignificant
+// whitespace removed. Numbers keep their literal form. Invalid JSON, which
+// validation normally rejects earlier, is kept as a JSON string so it still
+// contributes to the key.
+func canonicalJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		quoted, _ := json.Marshal(string(raw))
+		return quoted
+	}
+	out, err := json.Marshal(v) // encoding/json sorts map keys
+	if err != nil {
+		quoted, _ := json.Marshal(string(raw))
+		return quoted
+	}
+	return out
+}
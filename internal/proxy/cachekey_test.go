@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/redis/go-redis/v9"
+)
+
+func cacheKeyBaseRequest() *providers.ProxyRequest {
+	topP, pp, fp := 0.9, 0.1, 0.2
+	seed := int64(7)
+	logProbs, topLogProbs := true, 2
+	return &providers.ProxyRequest{
+		Model: "gpt-4o",
+		Messages: []providers.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+		},
+		Temperature:      0.7,
+		MaxTokens:        100,
+		N:                1,
+		TopP:             &topP,
+		Stop:             []string{"END"},
+		PresencePenalty:  &pp,
+		FrequencyPenalty: &fp,
+		Seed:             &seed,
+		LogProbs:         &logProbs,
+		TopLogProbs:      &topLogProbs,
+		Tools:            json.RawMessage(`[{"type":"function","function":{"name":"f"}}]`),
+		ToolChoice:       json.RawMessage(`"auto"`),
+		ResponseFormat:   json.RawMessage(`{"type":"json_object"}`),
+		ReasoningEffort:  "low",
+		ThinkingBudget:   1024,
+		WorkspaceID:      "ws-1",
+		APIKeyID:         "key-1",
+	}
+}
+
+func TestBuildCacheKey_EachParamChangesKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(r *providers.ProxyRequest)
+	}{
+		{"workspace", func(r *providers.ProxyRequest) { r.WorkspaceID = "ws-2" }},
+		{"api key", func(r *providers.ProxyRequest) { r.APIKeyID = "key-2" }},
+		{"model", func(r *providers.ProxyRequest) { r.Model = "gpt-4o-mini" }},
+		{"provider", func(r *providers.ProxyRequest) { r.Model = "claude-3-5-sonnet" }},
+		{"message role", func(r *providers.ProxyRequest) { r.Messages[0].Role = "user" }},
+		{"message content", func(r *providers.ProxyRequest) { r.Messages[1].Content = "hello" }},
+		{"message order", func(r *providers.ProxyRequest) { r.Messages[0], r.Messages[1] = r.Messages[1], r.Messages[0] }},
+		{"message parts", func(r *providers.ProxyRequest) {
+			r.Messages[1].Parts = []providers.ContentPart{{Type: providers.ContentPartImageURL, ImageURL: "https://example.com/a.png"}}
+		}},
+		{"tool calls", func(r *providers.ProxyRequest) {
+			r.Messages[0].ToolCalls = []providers.ToolCall{{ID: "c1", Name: "f", Arguments: `{}`}}
+		}},
+		{"tool call id", func(r *providers.ProxyRequest) { r.Messages[1].ToolCallID = "c1" }},
+		{"temperature", func(r *providers.ProxyRequest) { r.Temperature = 0.701 }},
+		{"max tokens", func(r *providers.ProxyRequest) { r.MaxTokens = 200 }},
+		{"n", func(r *providers.ProxyRequest) { r.N = 2 }},
+		{"top_p", func(r *providers.ProxyRequest) { v := 0.95; r.TopP = &v }},
+		{"top_p unset", func(r *providers.ProxyRequest) { r.TopP = nil }},
+		{"stop", func(r *providers.ProxyRequest) { r.Stop = []string{"STOP"} }},
+		{"presence penalty", func(r *providers.ProxyRequest) { v := 0.5; r.PresencePenalty = &v }},
+		{"frequency penalty", func(r *providers.ProxyRequest) { v := 0.5; r.FrequencyPenalty = &v }},
+		{"seed", func(r *providers.ProxyRequest) { v := int64(8); r.Seed = &v }},
+		{"logprobs", func(r *providers.ProxyRequest) { v := false; r.LogProbs = &v }},
+		{"top_logprobs", func(r *providers.ProxyRequest) { v := 3; r.TopLogProbs = &v }},
+		{"tools", func(r *providers.ProxyRequest) {
+			r.Tools = json.RawMessage(`[{"type":"function","function":{"name":"g"}}]`)
+		}},
+		{"tool_choice", func(r *providers.ProxyRequest) { r.ToolChoice = json.RawMessage(`"none"`) }},
+		{"response_format", func(r *providers.ProxyRequest) { r.ResponseFormat = json.RawMessage(`{"type":"text"}`) }},
+		{"reasoning effort", func(r *providers.ProxyRequest) { r.ReasoningEffort = "high" }},
+		{"thinking budget", func(r *providers.ProxyRequest) { r.ThinkingBudget = 2048 }},
+	}
+
+	base := buildCacheKey("", cacheKeyBaseRequest())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := cacheKeyBaseRequest()
+			tt.mutate(req)
+			if buildCacheKey("", req) == base {
+				t.Errorf("changing %s did not change the cache key", tt.name)
+			}
+		})
+	}
+}
+
+func TestBuildCacheKey_IgnoresIrrelevantFields(t *testing.T) {
+	base := buildCacheKey("", cacheKeyBaseRequest())
+
+	req := cacheKeyBaseRequest()
+	req.Stream = true
+	req.RequestID = "req-2"
+	req.APIKey = "sk-other"
+	if got := buildCacheKey("", req); got != base {
+		t.Error("stream, request ID and raw API key must not change the cache key")
+	}
+}
+
+func TestBuildCacheKey_CanonicalJSON(t *testing.T) {
+	a := cacheKeyBaseRequest()
+	a.ResponseFormat = json.RawMessage(`{"type":"json_schema","json_schema":{"name":"x","strict":true}}`)
+	b := cacheKeyBaseRequest()
+	b.ResponseFormat = json.RawMessage(`{ "json_schema": {"strict": true, "name": "x"}, "type": "json_schema" }`)
+
+	if buildCacheKey("", a) != buildCacheKey("", b) {
+		t.Error("key order and whitespace in client JSON must not change the cache key")
+	}
+}
+
+// TestCacheKeyInput_CoversProxyRequest fails when a field is added to
+// providers.ProxyRequest without deciding whether it belongs in the cache key.
+func TestCacheKeyInput_CoversProxyRequest(t *testing.T) {
+	notInKey := map[string]bool{
+		"Stream":    true, // streams share the non-streaming entry
+		"APIKey":    true, // identified by APIKeyID
+		"RequestID": true, // unique per request
+		// Only read by the gateway's own policies; providers are sent the
+		// same temperature either way.
+		"TemperatureSet": true,
+	}
+	keyFields := reflect.TypeOf(cacheKeyInput{})
+	reqFields := reflect.TypeOf(providers.ProxyRequest{})
+	for i := 0; i < reqFields.NumField(); i++ {
+		name := reqFields.Field(i).Name
+		if _, ok := keyFields.FieldByName(name); !ok && !notInKey[name] {
+			t.Errorf("ProxyRequest.%s is neither in cacheKeyInput nor listed as irrelevant to the response", name)
+		}
+	}
+}
+
+func TestDispatchChat_KeyNamespacesIsolateSharedRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	newNamespaced := func(namespace string, got **providers.ProxyRequest) (*cache.ExactCache, *http.Client) {
+		store := cache.NewExactCacheFromClient(rdb)
+		store.SetNamespace(namespace)
+		gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+			"openai": capturingProvider("openai", got),
+		}, store, nil, GatewayOptions{KeyNamespace: namespace})
+		t.Cleanup(gw.health.Close)
+		client, cleanup := serveGateway(t, gw)
+		t.Cleanup(cleanup)
+		return store, client
+	}
+	var gotA, gotB *providers.ProxyRequest
+	storeA, clientA := newNamespaced("prod:", &gotA)
+	_, clientB := newNamespaced("staging:", &gotB)
+
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	send := func(client *http.Client) {
+		t.Helper()
+		resp := doPost(t, client, "/v1/chat/completions", body)
+		if b := readBody(t, resp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", resp.StatusCode, b)
+		}
+	}
+
+	send(clientA)
+	send(clientB)
+	if gotB == nil {
+		t.Fatal("staging was served prod's cached response")
+	}
+	for _, k := range mr.Keys() {
+		if !strings.HasPrefix(k, "prod:cache:") && !strings.HasPrefix(k, "staging:cache:") {
+			t.Errorf("key %q is outside both namespaces", k)
+		}
+	}
+
+	// Flushing prod leaves staging's entry in place.
+	if err := storeA.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	gotB = nil
+	send(clientB)
+	if gotB != nil {
+		t.Error("staging missed after prod's cache was flushed")
+	}
+}
@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,6 +36,20 @@ type CBConfig struct {
 	// HalfOpenTimeout is how long the breaker stays open before allowing a
 	// single probe request. Default: providers.CBHalfOpenTimeout (30s).
 	HalfOpenTimeout time.Duration
+
+	// HalfOpenMaxProbes is how many probe requests may be in flight at once
+	// while half-open. Default: providers.CBHalfOpenMaxProbes (1).
+	HalfOpenMaxProbes int
+
+	// HalfOpenSuccessThreshold is how many consecutive probe successes close
+	// a half-open breaker. Default: providers.CBHalfOpenSuccessThreshold (1).
+	HalfOpenSuccessThreshold int
+
+	// PerModel keeps a breaker per provider and model instead of per
+	// provider, so one failing model (say, a retired deployment) does not
+	// cut off the provider's other models. Only chat requests pass through
+	// the breaker, so per-route keys are not needed.
+	PerModel bool
 }
 
 func (c *CBConfig) errorThreshold() int {
@@ -57,15 +73,30 @@ func (c *CBConfig) halfOpenTimeout() time.Duration {
 	return providers.CBHalfOpenTimeout
 }
 
+func (c *CBConfig) halfOpenMaxProbes() int {
+	if c.HalfOpenMaxProbes > 0 {
+		return c.HalfOpenMaxProbes
+	}
+	return providers.CBHalfOpenMaxProbes
+}
+
+func (c *CBConfig) halfOpenSuccessThreshold() int {
+	if c.HalfOpenSuccessThreshold > 0 {
+		return c.HalfOpenSuccessThreshold
+	}
+	return providers.CBHalfOpenSuccessThreshold
+}
+
 // providerCB holds per-provider circuit breaker state.
 type providerCB struct {
 	mu sync.Mutex
 
-	state         cbState
-	errorCount    int
-	windowStart   time.Time // start of the current error-counting window
-	openedAt      time.Time // when the breaker was tripped (for half-open timer)
-	probeInflight bool      // true while a half-open probe is in flight
+	state          cbState
+	errorCount     int
+	windowStart    time.Time // start of the current error-counting window
+	openedAt       time.Time // when the breaker was tripped (for half-open timer)
+	probesInflight int       // half-open probes currently in flight
+	probeSuccesses int       // consecutive half-open probe successes
 }
 
 // CircuitBreaker manages independent circuit breakers for each LLM provider.
@@ -89,6 +120,9 @@ func NewCircuitBreakerWithConfig(cfg CBConfig) *CircuitBreaker {
 		breakers: make(map[string]*providerCB),
 		cfg:      cfg,
 	}
+	if cfg.PerModel {
+		return cb
+	}
 	for _, name := range providers.DefaultFallbackOrder {
 		cb.breakers[name] = &providerCB{
 			state:       cbClosed,
@@ -98,12 +132,23 @@ func NewCircuitBreakerWithConfig(cfg CBConfig) *CircuitBreaker {
 	return cb
 }
 
+// Key returns the breaker key for requests to model on provider: the
+// provider name, or "provider/model" with CBConfig.PerModel. Every other
+// method takes such a key.
+func (cb *CircuitBreaker) Key(provider, model string) string {
+	if !cb.cfg.PerModel {
+		return provider
+	}
+	return provider + "/" + model
+}
+
 // Allow reports whether the named provider should receive the next request.
 //
 //   - Closed  → always true.
 //   - Open    → false, unless the half-open timeout has elapsed, in which case
 //     the breaker transitions to HalfOpen and allows one probe.
-//   - HalfOpen → true only if no probe is currently in flight.
+//   - HalfOpen → true only while fewer than HalfOpenMaxProbes probes are in
+//     flight.
 //
 // Returns true for unknown providers (the breaker is not tracking them yet).
 func (cb *CircuitBreaker) Allow(provider string) bool {
@@ -121,27 +166,49 @@ func (cb *CircuitBreaker) Allow(provider string) bool {
 
 	case cbOpen:
 		if time.Since(pcb.openedAt) >= cb.cfg.halfOpenTimeout() {
-			// Transition to half-open: allow exactly one probe request.
+			// Transition to half-open and let this request probe.
 			pcb.state = cbHalfOpen
-			pcb.probeInflight = true
+			pcb.probesInflight = 1
+			pcb.probeSuccesses = 0
 			return true
 		}
 		return false
 
 	case cbHalfOpen:
-		if pcb.probeInflight {
-			// A probe is already in flight — reject other requests.
+		if pcb.probesInflight >= cb.cfg.halfOpenMaxProbes() {
+			// Enough probes are in flight — reject other requests.
 			return false
 		}
-		pcb.probeInflight = true
+		pcb.probesInflight++
 		return true
 	}
 
 	return true
 }
 
-// RecordSuccess marks a successful response for provider and resets the
-// breaker to Closed regardless of its previous state.
+// Admits reports whether Allow would let a request to provider through,
+// without claiming a probe slot or changing state.
+func (cb *CircuitBreaker) Admits(provider string) bool {
+	pcb := cb.get(provider)
+	if pcb == nil {
+		return true
+	}
+
+	pcb.mu.Lock()
+	defer pcb.mu.Unlock()
+
+	switch pcb.state {
+	case cbOpen:
+		return time.Since(pcb.openedAt) >= cb.cfg.halfOpenTimeout()
+	case cbHalfOpen:
+		return pcb.probesInflight < cb.cfg.halfOpenMaxProbes()
+	}
+	return true
+}
+
+// RecordSuccess marks a successful response for provider. A half-open
+// breaker closes once HalfOpenSuccessThreshold consecutive probes have
+// succeeded; in any other state the breaker resets to Closed.
 func (cb *CircuitBreaker) RecordSuccess(provider string) {
 	pcb := cb.get(provider)
 	if pcb == nil {
@@ -151,18 +218,28 @@ func (cb *CircuitBreaker) RecordSuccess(provider string) {
 	pcb.mu.Lock()
 	defer pcb.mu.Unlock()
 
-	pcb.state = cbClosed
-	pcb.errorCount = 0
-	pcb.probeInflight = false
-	pcb.windowStart = time.Now()
+	if pcb.state == cbHalfOpen {
+		if pcb.probesInflight > 0 {
+			pcb.probesInflight--
+		}
+		pcb.probeSuccesses++
+		if pcb.probeSuccesses < cb.cfg.halfOpenSuccessThreshold() {
+			return
+		}
+	}
+	pcb.reset()
 }
 
 // RecordFailure increments the error counter for provider. When the counter
-// reaches ErrorThreshold within TimeWindow the breaker opens.
+// reaches ErrorThreshold within TimeWindow the breaker opens. With
+// CBConfig.PerModel, breakers are created on a key's first failure.
 func (cb *CircuitBreaker) RecordFailure(provider string) {
 	pcb := cb.get(provider)
 	if pcb == nil {
-		return
+		if !cb.cfg.PerModel {
+			return
+		}
+		pcb = cb.getOrCreate(provider)
 	}
 
 	pcb.mu.Lock()
@@ -177,14 +254,91 @@ func (cb *CircuitBreaker) RecordFailure(provider string) {
 	}
 
 	pcb.errorCount++
-	pcb.probeInflight = false
+	pcb.probesInflight = 0
+	pcb.probeSuccesses = 0
 
-	if pcb.errorCount >= cb.cfg.errorThreshold() {
+	// A failed probe reopens the breaker straight away.
+	if pcb.state == cbHalfOpen || pcb.errorCount >= cb.cfg.errorThreshold() {
 		pcb.state = cbOpen
 		pcb.openedAt = now
 	}
 }
 
+// Release returns a half-open probe slot taken by Allow without recording
+// an outcome, for attempts abandoned before the provider answered (the
+// client went away). In any other state it does nothing.
+func (cb *CircuitBreaker) Release(provider string) {
+	pcb := cb.get(provider)
+	if pcb == nil {
+		return
+	}
+
+	pcb.mu.Lock()
+	defer pcb.mu.Unlock()
+
+	if pcb.state == cbHalfOpen && pcb.probesInflight > 0 {
+		pcb.probesInflight--
+	}
+}
+
+// Reset forces provider's breaker back to Closed and clears its error count,
+// skipping the half-open wait. A bare provider name also resets that
+// provider's per-model breakers. It returns the keys reset, sorted, or nil
+// for untracked providers.
+func (cb *CircuitBreaker) Reset(provider string) []string {
+	cb.mu.RLock()
+	var keys []string
+	for key := range cb.breakers {
+		if key == provider || strings.HasPrefix(key, provider+"/") {
+			keys = append(keys, key)
+		}
+	}
+	cb.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		cb.close(key)
+	}
+	return keys
+}
+
+// ResetAll resets every tracked breaker and returns their keys, sorted.
+func (cb *CircuitBreaker) ResetAll() []string {
+	cb.mu.RLock()
+	names := make([]string, 0, len(cb.breakers))
+	for name := range cb.breakers {
+		names = append(names, name)
+	}
+	cb.mu.RUnlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		cb.close(name)
+	}
+	return names
+}
+
+// close returns key's breaker to Closed.
+func (cb *CircuitBreaker) close(key string) {
+	pcb := cb.get(key)
+	if pcb == nil {
+		return
+	}
+
+	pcb.mu.Lock()
+	defer pcb.mu.Unlock()
+	pcb.reset()
+}
+
+// reset returns the breaker to Closed. The caller must hold pcb.mu.
+func (pcb *providerCB) reset() {
+	pcb.state = cbClosed
+	pcb.errorCount = 0
+	pcb.probesInflight = 0
+	pcb.probeSuccesses = 0
+	pcb.windowStart = time.Now()
+}
+
 // State returns the current cbState for provider (useful for metrics export).
 func (cb *CircuitBreaker) State(provider string) cbState {
 	pcb := cb.get(provider)
@@ -213,3 +367,21 @@ func (cb *CircuitBreaker) get(provider string) *providerCB {
 	defer cb.mu.RUnlock()
 	return cb.breakers[provider]
 }
+
+func (cb *CircuitBreaker) getOrCreate(key string) *providerCB {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	pcb, ok := cb.breakers[key]
+	if !ok {
+		pcb = &providerCB{state: cbClosed, windowStart: time.Now()}
+		cb.breakers[key] = pcb
+	}
+	return pcb
+}
+
+// setCircuitBreakerGauge publishes the state of the breaker under key. The
+// gauge's model label is empty for provider-level breakers.
+func (g *Gateway) setCircuitBreakerGauge(key string) {
+	provider, model, _ := strings.Cut(key, "/")
+	g.metrics.SetCircuitBreaker(provider, model, int64(g.cb.State(key)))
+}
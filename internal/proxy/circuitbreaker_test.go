@@ -183,6 +183,62 @@ func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
 	}
 }
 
+// tripToHalfOpen opens provider's breaker and fast-forwards it past the
+// half-open timeout.
+func tripToHalfOpen(t *testing.T, cb *CircuitBreaker, provider string) {
+	t.Helper()
+	for i := 0; i < cb.cfg.errorThreshold(); i++ {
+		cb.RecordFailure(provider)
+	}
+	pcb := cb.get(provider)
+	pcb.mu.Lock()
+	pcb.openedAt = time.Now().Add(-cb.cfg.halfOpenTimeout() - time.Second)
+	pcb.mu.Unlock()
+}
+
+func TestCircuitBreaker_HalfOpenSuccessThreshold(t *testing.T) {
+	cb := NewCircuitBreakerWithConfig(CBConfig{HalfOpenSuccessThreshold: 3})
+	tripToHalfOpen(t, cb, "openai")
+
+	for i := 1; i <= 3; i++ {
+		if !cb.Allow("openai") {
+			t.Fatalf("probe %d should be allowed", i)
+		}
+		cb.RecordSuccess("openai")
+		if i < 3 && cb.State("openai") != cbHalfOpen {
+			t.Fatalf("after %d successes state = %s, want half_open", i, cb.StateLabel("openai"))
+		}
+	}
+	if cb.State("openai") != cbClosed {
+		t.Errorf("after 3 successes state = %s, want closed", cb.StateLabel("openai"))
+	}
+}
+
+func TestCircuitBreaker_HalfOpenFailureResetsSuccesses(t *testing.T) {
+	cb := NewCircuitBreakerWithConfig(CBConfig{HalfOpenSuccessThreshold: 2})
+	tripToHalfOpen(t, cb, "openai")
+
+	cb.Allow("openai")
+	cb.RecordSuccess("openai")
+	cb.Allow("openai")
+	cb.RecordFailure("openai")
+	if cb.State("openai") != cbOpen {
+		t.Errorf("a failed probe should reopen the breaker, got %s", cb.StateLabel("openai"))
+	}
+}
+
+func TestCircuitBreaker_HalfOpenMaxProbes(t *testing.T) {
+	cb := NewCircuitBreakerWithConfig(CBConfig{HalfOpenMaxProbes: 2})
+	tripToHalfOpen(t, cb, "openai")
+
+	if !cb.Allow("openai") || !cb.Allow("openai") {
+		t.Fatal("two concurrent probes should be allowed")
+	}
+	if cb.Allow("openai") {
+		t.Error("a third concurrent probe should be rejected")
+	}
+}
+
 func TestCircuitBreaker_IndependentProviders(t *testing.T) {
 	cb := NewCircuitBreaker()
 
@@ -202,6 +258,28 @@ func TestCircuitBreaker_IndependentProviders(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_PerModel(t *testing.T) {
+	cb := NewCircuitBreakerWithConfig(CBConfig{PerModel: true})
+	bad, good := cb.Key("openai", "gpt-4o"), cb.Key("openai", "gpt-4o-mini")
+
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		cb.RecordFailure(bad)
+	}
+	if cb.State(bad) != cbOpen {
+		t.Errorf("%s should be open", bad)
+	}
+	if !cb.Allow(good) {
+		t.Errorf("%s should still allow requests", good)
+	}
+
+	if got := cb.Reset("openai"); len(got) != 1 || got[0] != bad {
+		t.Errorf("Reset(openai) = %q, want [%s]", got, bad)
+	}
+	if cb.State(bad) != cbClosed {
+		t.Errorf("%s should be closed after resetting its provider", bad)
+	}
+}
+
 func TestCircuitBreaker_RecordOnUnknownProvider(t *testing.T) {
 	cb := NewCircuitBreaker()
 	// Should not panic.
@@ -237,3 +315,46 @@ func TestCircuitBreaker_StateLabel(t *testing.T) {
 		t.Errorf("expected 'half_open', got %s", cb.StateLabel("openai"))
 	}
 }
+
+func TestCircuitBreaker_Reset(t *testing.T) {
+	cb := NewCircuitBreaker()
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		cb.RecordFailure("openai")
+	}
+	if cb.Allow("openai") {
+		t.Fatal("breaker should be open")
+	}
+
+	if got := cb.Reset("openai"); len(got) != 1 || got[0] != "openai" {
+		t.Fatalf("Reset = %q, want [openai]", got)
+	}
+	if cb.State("openai") != cbClosed || !cb.Allow("openai") {
+		t.Error("breaker should be closed and allow requests after Reset")
+	}
+	if got := cb.Reset("unknown-provider"); got != nil {
+		t.Errorf("Reset = %q, want nil for an untracked provider", got)
+	}
+}
+
+func TestCircuitBreaker_ReleaseFreesProbe(t *testing.T) {
+	cb := NewCircuitBreaker()
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		cb.RecordFailure("openai")
+	}
+	pcb := cb.breakers["openai"]
+	pcb.mu.Lock()
+	pcb.openedAt = time.Now().Add(-providers.CBHalfOpenTimeout - time.Second)
+	pcb.mu.Unlock()
+
+	if !cb.Allow("openai") {
+		t.Fatal("expected a probe to be admitted")
+	}
+	cb.Release("openai")
+
+	if cb.State("openai") != cbHalfOpen {
+		t.Errorf("state = %s, want half_open: Release must not record an outcome", cb.StateLabel("openai"))
+	}
+	if !cb.Admits("openai") || !cb.Allow("openai") {
+		t.Error("released probe slot was not handed to the next request")
+	}
+}
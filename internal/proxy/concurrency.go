@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+// globalConcurrencyScope labels the gateway-wide limiter in gateway_queue_depth.
+const globalConcurrencyScope = "global"
+
+// errSaturated is returned by concurrencyLimiter.acquire when no slot frees
+// up: the wait queue is full or the queue timeout elapsed.
+var errSaturated = errors.New("concurrency limit reached")
+
+// concurrencyLimiter caps the number of in-flight provider calls. A request
+// that finds every slot taken waits in a bounded queue for up to wait; once
+// the queue is full, further requests are rejected immediately.
+//
+// A freed slot goes to the waiter with the highest priority. Waiters age by
+// one tier per aging interval, so a low-priority request eventually outranks
+// newly queued high-priority ones instead of starving.
+type concurrencyLimiter struct {
+	scope    string
+	limit    int
+	maxQueue int
+	wait     time.Duration
+	aging    time.Duration
+	metrics  *metrics.Registry
+
+	mu      sync.Mutex
+	inUse   int
+	waiters []*slotWaiter
+}
+
+// slotWaiter is a request queued on a concurrencyLimiter. ready is closed
+// once release hands it a slot.
+type slotWaiter struct {
+	prio     priority
+	queuedAt time.Time
+	ready    chan struct{}
+	granted  bool
+}
+
+// newConcurrencyLimiter returns a limiter allowing limit concurrent holders
+// and up to queueDepth waiters. It returns nil when limit is not positive,
+// and a nil limiter never blocks. A non-positive aging disables aging.
+func newConcurrencyLimiter(scope string, limit, queueDepth int, wait, aging time.Duration, m *metrics.Registry) *concurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{
+		scope:    scope,
+		limit:    limit,
+		maxQueue: max(queueDepth, 0),
+		wait:     wait,
+		aging:    aging,
+		metrics:  m,
+	}
+}
+
+// acquire takes a slot, queueing at prio if none is free. Every successful
+// acquire must be paired with a release.
+func (l *concurrencyLimiter) acquire(ctx context.Context, prio priority) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.inUse < l.limit {
+		l.inUse++
+		l.mu.Unlock()
+		return nil
+	}
+	if len(l.waiters) >= l.maxQueue {
+		l.mu.Unlock()
+		return errSaturated
+	}
+	w := &slotWaiter{prio: prio, queuedAt: time.Now(), ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+	if l.metrics != nil {
+		l.metrics.IncQueueDepth(l.scope, prio.String())
+	}
+	defer func() {
+		if l.metrics != nil {
+			l.metrics.DecQueueDepth(l.scope, prio.String())
+		}
+	}()
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		err = errSaturated
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// release handed over a slot as we gave up; keep it.
+		return nil
+	}
+	for i, q := range l.waiters {
+		if q == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+	return err
+}
+
+// release returns a slot taken by acquire, handing it straight to the
+// highest-ranked waiter if there is one.
+func (l *concurrencyLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) == 0 {
+		l.inUse--
+		return
+	}
+	now := time.Now()
+	best := 0
+	for i := 1; i < len(l.waiters); i++ {
+		if l.outranks(l.waiters[i], l.waiters[best], now) {
+			best = i
+		}
+	}
+	w := l.waiters[best]
+	l.waiters = append(l.waiters[:best], l.waiters[best+1:]...)
+	w.granted = true
+	close(w.ready)
+}
+
+// outranks reports whether a should be admitted before b. The effective
+// priority is the requested tier plus one per aging interval waited; ties go
+// to the earlier arrival.
+func (l *concurrencyLimiter) outranks(a, b *slotWaiter, now time.Time) bool {
+	ea, eb := l.effectivePriority(a, now), l.effectivePriority(b, now)
+	if ea != eb {
+		return ea > eb
+	}
+	return a.queuedAt.Before(b.queuedAt)
+}
+
+func (l *concurrencyLimiter) effectivePriority(w *slotWaiter, now time.Time) int64 {
+	p := int64(w.prio)
+	if l.aging > 0 {
+		p += int64(now.Sub(w.queuedAt) / l.aging)
+	}
+	return p
+}
+
+// queueLen returns the number of requests waiting for a slot.
+func (l *concurrencyLimiter) queueLen() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiters)
+}
+
+// acquireConcurrency takes a slot from the global limiter and from the
+// primary provider's limiter, in that order, queueing at the request's
+// priority. The per-provider cap is keyed by the primary: a request keeps its
+// slot while failing over to other providers. The returned release is safe to
+// call more than once.
+func (g *Gateway) acquireConcurrency(ctx *fasthttp.RequestCtx, provider string) (func(), error) {
+	prio := requestPriority(ctx)
+	if err := g.concurrency.acquire(ctx, prio); err != nil {
+		return nil, err
+	}
+	provLimiter := g.providerConcurrency[provider]
+	if err := provLimiter.acquire(ctx, prio); err != nil {
+		g.concurrency.release()
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			provLimiter.release()
+			g.concurrency.release()
+		})
+	}, nil
+}
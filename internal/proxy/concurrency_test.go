@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+// blockingProvider answers only after release is closed, signalling started
+// each time a call begins.
+func blockingProvider(name string, started chan<- struct{}, release <-chan struct{}) *funcProvider {
+	return &funcProvider{
+		name: name,
+		requestFn: func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return &providers.ProxyResponse{ID: "ok", Model: req.Model, Content: "hello from " + name}, nil
+		},
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+const concurrencyBody = `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+
+func TestDispatchChat_ConcurrencySaturatedReturns503(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": blockingProvider("openai", started, release),
+	}, nil, nil, GatewayOptions{MaxConcurrency: 1, QueueTimeout: 2 * time.Second})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	first := make(chan int, 1)
+	go func() {
+		resp := doPost(t, client, "/v1/chat/completions", []byte(concurrencyBody))
+		readBody(t, resp)
+		first <- resp.StatusCode
+	}()
+	<-started
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(concurrencyBody))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503; body = %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	close(release)
+	if status := <-first; status != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want 200", status)
+	}
+}
+
+func TestDispatchChat_ConcurrencyQueuedThenServed(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": blockingProvider("openai", started, release),
+	}, nil, nil, GatewayOptions{MaxConcurrency: 1, QueueDepth: 1, QueueTimeout: 2 * time.Second})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	statuses := make(chan int, 2)
+	post := func() {
+		resp := doPost(t, client, "/v1/chat/completions", []byte(concurrencyBody))
+		readBody(t, resp)
+		statuses <- resp.StatusCode
+	}
+	go post()
+	<-started
+	go post()
+	waitFor(t, func() bool { return gw.concurrency.queueLen() == 1 })
+
+	close(release)
+	for range 2 {
+		if status := <-statuses; status != http.StatusOK {
+			t.Errorf("status = %d, want 200", status)
+		}
+	}
+	if n := gw.concurrency.queueLen(); n != 0 {
+		t.Errorf("queued = %d after drain, want 0", n)
+	}
+}
+
+func TestDispatchChat_ProviderConcurrencyLimit(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    blockingProvider("openai", started, release),
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{ProviderMaxConcurrency: map[string]int{"openai": 1}})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	defer close(release)
+
+	go func() {
+		readBody(t, doPost(t, client, "/v1/chat/completions", []byte(concurrencyBody)))
+	}()
+	<-started
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(concurrencyBody))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("openai status = %d, want 503", resp.StatusCode)
+	}
+
+	// Other providers are not bound by openai's limit.
+	resp = doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"claude-3-5-sonnet-20241022","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("anthropic status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestConcurrencyLimiter_NilIsUnlimited(t *testing.T) {
+	l := newConcurrencyLimiter(globalConcurrencyScope, 0, 10, time.Second, time.Second, nil)
+	if l != nil {
+		t.Fatal("limit 0 should yield a nil limiter")
+	}
+	if err := l.acquire(context.Background(), priorityNormal); err != nil {
+		t.Fatalf("nil limiter acquire: %v", err)
+	}
+	l.release()
+}
+
+// queueAt starts an acquire at prio on l and reports name on admitted once it
+// holds a slot, releasing it straight away.
+func queueAt(t *testing.T, l *concurrencyLimiter, prio priority, name string, admitted chan<- string) {
+	t.Helper()
+	go func() {
+		if err := l.acquire(context.Background(), prio); err != nil {
+			admitted <- "error: " + err.Error()
+			return
+		}
+		admitted <- name
+		l.release()
+	}()
+}
+
+func TestConcurrencyLimiter_HighPriorityJumpsQueue(t *testing.T) {
+	l := newConcurrencyLimiter(globalConcurrencyScope, 1, 3, 2*time.Second, time.Hour, nil)
+	if err := l.acquire(context.Background(), priorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan string, 3)
+	queueAt(t, l, priorityLow, "low-1", admitted)
+	waitFor(t, func() bool { return l.queueLen() == 1 })
+	queueAt(t, l, priorityLow, "low-2", admitted)
+	waitFor(t, func() bool { return l.queueLen() == 2 })
+	queueAt(t, l, priorityHigh, "high", admitted)
+	waitFor(t, func() bool { return l.queueLen() == 3 })
+
+	l.release()
+	for i, want := range []string{"high", "low-1", "low-2"} {
+		if got := <-admitted; got != want {
+			t.Errorf("admission %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestConcurrencyLimiter_AgingPreventsStarvation(t *testing.T) {
+	l := newConcurrencyLimiter(globalConcurrencyScope, 1, 2, 2*time.Second, 10*time.Millisecond, nil)
+	if err := l.acquire(context.Background(), priorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan string, 2)
+	queueAt(t, l, priorityLow, "low", admitted)
+	waitFor(t, func() bool { return l.queueLen() == 1 })
+	// Three aging intervals lift the low request above a fresh high one.
+	time.Sleep(30 * time.Millisecond)
+	queueAt(t, l, priorityHigh, "high", admitted)
+	waitFor(t, func() bool { return l.queueLen() == 2 })
+
+	l.release()
+	if got := <-admitted; got != "low" {
+		t.Errorf("first admitted = %q, want the aged low-priority request", got)
+	}
+	<-admitted
+}
+
+func TestRequestPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		vk     *VirtualKey
+		want   priority
+	}{
+		{"default", "", nil, priorityNormal},
+		{"header", "HIGH", nil, priorityHigh},
+		{"unknown header", "urgent", nil, priorityNormal},
+		{"key default", "", &VirtualKey{Priority: "low"}, priorityLow},
+		{"header below key", "low", &VirtualKey{Priority: "high"}, priorityLow},
+		{"key caps header", "high", &VirtualKey{Priority: "normal"}, priorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx fasthttp.RequestCtx
+			if tt.header != "" {
+				ctx.Request.Header.Set(priorityHeader, tt.header)
+			}
+			if tt.vk != nil {
+				ctx.SetUserValue(virtualKeyUserValue, tt.vk)
+			}
+			if got := requestPriority(&ctx); got != tt.want {
+				t.Errorf("requestPriority = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
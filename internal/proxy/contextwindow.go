@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// checkContextWindow rejects req with 400 context_length_exceeded when its
+// estimated prompt plus max_tokens cannot fit the model's context window,
+// sparing a round trip that the provider would refuse anyway. It reports
+// whether the request may proceed. The check is off unless
+// GatewayOptions.CheckContextWindow is set, and models without a known
+// window always pass.
+func (g *Gateway) checkContextWindow(ctx *fasthttp.RequestCtx, req *providers.ProxyRequest) bool {
+	if !g.checkContextWindows {
+		return true
+	}
+	window, ok := providers.ContextWindows[req.Model]
+	if !ok {
+		return true
+	}
+	prompt := estimateInputTokens(req)
+	if prompt+req.MaxTokens <= window {
+		return true
+	}
+	apierr.Write(ctx, fasthttp.StatusBadRequest,
+		fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested about %d tokens "+
+			"(about %d in the messages, %d in the completion). Please reduce the length of the messages or completion.",
+			window, prompt+req.MaxTokens, prompt, req.MaxTokens),
+		apierr.TypeInvalidRequest, apierr.CodeContextLengthExceeded)
+	return false
+}
+
+// contextLengthMarkers are lower-case fragments of the messages providers
+// use when a prompt does not fit the model's context window.
+var contextLengthMarkers = []string{
+	"context_length_exceeded",              // OpenAI, Azure, OpenAI-compatible
+	"maximum context length",               // OpenAI, Mistral
+	"prompt is too long",                   // Anthropic
+	"exceeds the maximum number of tokens", // Gemini, Vertex AI
+	"input is too long",                    // Bedrock
+}
+
+// isContextLengthError reports whether err is a provider refusing a request
+// because it does not fit the model's context window. Only 400 and 413
+// responses whose message names that condition qualify, so other invalid
+// requests are not mistaken for it.
+func isContextLengthError(err error) bool {
+	var sc providers.StatusCoder
+	if !errors.As(err, &sc) {
+		return false
+	}
+	if status := sc.HTTPStatus(); status != fasthttp.StatusBadRequest && status != fasthttp.StatusRequestEntityTooLarge {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
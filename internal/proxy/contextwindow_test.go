@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestDispatchChat_ContextWindow(t *testing.T) {
+	var calls atomic.Int32
+	counting := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls.Add(1)
+			return &providers.ProxyResponse{Model: "gpt-4", Content: "ok"}, nil
+		},
+	}
+	newGW := func(check bool) *Gateway {
+		gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+			"openai": counting,
+		}, nil, nil, GatewayOptions{CheckContextWindow: check})
+		t.Cleanup(gw.health.Close)
+		return gw
+	}
+	body := func(model string, promptChars, maxTokens int) []byte {
+		b, _ := json.Marshal(map[string]any{
+			"model":      model,
+			"max_tokens": maxTokens,
+			"messages":   []map[string]string{{"role": "user", "content": strings.Repeat("a", promptChars)}},
+		})
+		return b
+	}
+
+	// gpt-4 has an 8192-token window; 4 characters estimate one token.
+	tests := []struct {
+		name       string
+		check      bool
+		req        []byte
+		wantStatus int
+	}{
+		{"over-limit prompt", true, body("gpt-4", 40_000, 0), http.StatusBadRequest},
+		{"prompt plus max_tokens over limit", true, body("gpt-4", 20_000, 4_000), http.StatusBadRequest},
+		{"under limit", true, body("gpt-4", 20_000, 1_000), http.StatusOK},
+		{"unknown model", true, body("my-finetune", 40_000, 0), http.StatusOK},
+		{"check disabled", false, body("gpt-4", 40_000, 0), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			client, cleanup := serveGateway(t, newGW(tt.check))
+			defer cleanup()
+
+			resp := doPost(t, client, "/v1/chat/completions", tt.req)
+			respBody := readBody(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body = %s", resp.StatusCode, tt.wantStatus, respBody)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if calls.Load() != 1 {
+					t.Errorf("provider called %d times, want 1", calls.Load())
+				}
+				return
+			}
+			if calls.Load() != 0 {
+				t.Error("a rejected request must not reach the provider")
+			}
+			var e struct {
+				Error struct {
+					Message string `json:"message"`
+					Code    string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(respBody, &e); err != nil || e.Error.Code != "context_length_exceeded" ||
+				!strings.Contains(e.Error.Message, "8192 tokens") {
+				t.Errorf("body = %s", respBody)
+			}
+		})
+	}
+}
+
+func TestRequestWithFailover_ContextLengthFallback(t *testing.T) {
+	var models []string
+	openai := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			models = append(models, req.Model)
+			switch req.Model {
+			case "gpt-4":
+				return nil, &providerError{status: 400, msg: "This model's maximum context length is 8192 tokens (code=context_length_exceeded)"}
+			case "gpt-4o-mini":
+				return nil, &providerError{status: 400, msg: "Invalid value for 'temperature'"}
+			}
+			return &providers.ProxyResponse{Model: req.Model, Content: "ok"}, nil
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": openai,
+	}, nil, nil, GatewayOptions{
+		ContextFallbackModels: map[string]string{"gpt-4": "gpt-4-32k", "gpt-4o-mini": "gpt-4o"},
+		FallbackChains:        map[string][]string{"openai": {}},
+	})
+	t.Cleanup(gw.health.Close)
+
+	req := &providers.ProxyRequest{Model: "gpt-4", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	resp, usedProv, tried, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usedProv != "openai" || resp.Model != "gpt-4-32k" {
+		t.Errorf("served by %s/%s, want openai/gpt-4-32k", usedProv, resp.Model)
+	}
+	if strings.Join(models, ",") != "gpt-4,gpt-4-32k" || len(tried) != 2 {
+		t.Errorf("models = %v, tried = %v", models, tried)
+	}
+	if req.Model != "gpt-4" {
+		t.Errorf("caller's request was modified: model = %s", req.Model)
+	}
+
+	// Other 400s are returned as-is even when a fallback is configured.
+	models = nil
+	req = &providers.ProxyRequest{Model: "gpt-4o-mini", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	if _, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions"); err == nil {
+		t.Fatal("expected the provider's 400")
+	}
+	if len(models) != 1 {
+		t.Errorf("models = %v, want only the primary model", models)
+	}
+}
+
+func TestIsContextLengthError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&providerError{status: 400, msg: "openai: error code: context_length_exceeded"}, true},
+		{&providerError{status: 400, msg: "anthropic: prompt is too long: 210000 tokens > 200000 maximum"}, true},
+		{&providerError{status: 400, msg: "gemini: The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."}, true},
+		{&providerError{status: 413, msg: "Input is too long for requested model."}, true},
+		{fmt.Errorf("failover: all providers failed after 1 attempt(s): %w",
+			&providerError{status: 400, msg: "This model's maximum context length is 8192 tokens"}), true},
+		{&providerError{status: 400, msg: "invalid model"}, false},
+		{&providerError{status: 500, msg: "maximum context length"}, false},
+		{errors.New("context_length_exceeded"), false},
+	}
+	for _, tt := range tests {
+		if got := isContextLengthError(tt.err); got != tt.want {
+			t.Errorf("isContextLengthError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
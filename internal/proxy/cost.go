@@ -0,0 +1,43 @@
+package proxy
+
+import "strings"
+
+// ModelPrice is a model's token pricing in USD per 1,000 tokens.
+type ModelPrice struct {
+	InputPer1K  float64
+	OutputPer1K float64
+}
+
+// pricingTable maps model names to their prices.
+type pricingTable map[string]ModelPrice
+
+// lookup returns the price of model: its exact entry, else the longest entry
+// that prefixes it, so "gpt-4o" also prices "gpt-4o-2024-08-06".
+func (t pricingTable) lookup(model string) (ModelPrice, bool) {
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+	var (
+		best    ModelPrice
+		longest = -1
+	)
+	for name, p := range t {
+		if len(name) > longest && strings.HasPrefix(model, name) {
+			best, longest = p, len(name)
+		}
+	}
+	return best, longest >= 0
+}
+
+// cost returns the USD cost of a request to model that used the given
+// tokens. Cache hits and unpriced models cost nothing.
+func (t pricingTable) cost(model string, inputTokens, outputTokens int, cached bool) float64 {
+	if cached {
+		return 0
+	}
+	p, ok := t.lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*p.InputPer1K + float64(outputTokens)*p.OutputPer1K) / 1000
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+)
+
+var testPricing = map[string]ModelPrice{
+	"gpt-4o":      {InputPer1K: 0.0025, OutputPer1K: 0.01},
+	"gpt-4o-mini": {InputPer1K: 0.00015, OutputPer1K: 0.0006},
+}
+
+func TestPricingTable_Cost(t *testing.T) {
+	table := pricingTable(testPricing)
+	cases := []struct {
+		name    string
+		model   string
+		in, out int
+		cached  bool
+		want    float64
+	}{
+		{"exact", "gpt-4o", 1000, 500, false, 0.0075},
+		{"dated snapshot uses prefix", "gpt-4o-2024-08-06", 2000, 0, false, 0.005},
+		{"longest prefix wins", "gpt-4o-mini-2024-07-18", 1000, 1000, false, 0.00075},
+		{"unpriced model", "claude-3-opus", 1000, 1000, false, 0},
+		{"cache hit", "gpt-4o", 1000, 500, true, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := table.cost(tc.model, tc.in, tc.out, tc.cached)
+			if math.Abs(got-tc.want) > 1e-12 {
+				t.Errorf("cost = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPricingTable_NilCostsNothing(t *testing.T) {
+	var table pricingTable
+	if got := table.cost("gpt-4o", 1000, 1000, false); got != 0 {
+		t.Errorf("cost without a pricing table = %v, want 0", got)
+	}
+}
+
+func TestLogRequest_Cost(t *testing.T) {
+	sink := &captureSink{}
+	l, err := logger.NewWithSink(context.Background(), sink, logger.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := NewGatewayWithOptions(context.Background(), nil, nil, nil, GatewayOptions{Pricing: testPricing})
+	gw.SetLogger(l)
+
+	gw.logRequest(nil, "req-1", "", "", "openai", "openai", "gpt-4o", 1, 1000, 500, time.Millisecond, 200, false)
+	gw.logRequest(nil, "req-2", "", "", "openai", "openai", "gpt-4o", 0, 1000, 500, time.Millisecond, 200, true)
+	_ = l.Close()
+
+	if len(sink.entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(sink.entries))
+	}
+	if got := sink.entries[0].CostUSD; math.Abs(got-0.0075) > 1e-12 {
+		t.Errorf("CostUSD = %v, want 0.0075", got)
+	}
+	if got := sink.entries[1].CostUSD; got != 0 {
+		t.Errorf("cached CostUSD = %v, want 0", got)
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// disconnectPollInterval is how often watchDisconnect checks whether the
+// client of a non-streaming request is still connected.
+const disconnectPollInterval = 100 * time.Millisecond
+
+// watchDisconnect calls cancel as soon as the client of ctx closes its
+// connection, so an upstream call nobody will read stops generating (and
+// billing) tokens. fasthttp offers no close notification while a handler
+// runs, so the socket is peeked periodically without consuming any bytes.
+// Where the connection cannot be peeked (non-unix platforms, in-memory
+// listeners) it does nothing. Call the returned stop once the upstream call
+// has finished.
+func watchDisconnect(ctx *fasthttp.RequestCtx, cancel context.CancelFunc) (stop func()) {
+	conn := ctx.Conn()
+	if tc, ok := conn.(interface{ NetConn() net.Conn }); ok { // *tls.Conn
+		conn = tc.NetConn()
+	}
+	closed := peerClosedFunc(conn)
+	if closed == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if closed() {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
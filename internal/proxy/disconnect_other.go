@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package proxy
+
+import "net"
+
+// peerClosedFunc is unsupported on this platform; see disconnect_unix.go.
+func peerClosedFunc(net.Conn) func() bool { return nil }
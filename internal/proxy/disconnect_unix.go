@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package proxy
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// peerClosedFunc returns a probe reporting whether the peer of conn has
+// closed it, or nil when conn exposes no file descriptor. The probe peeks
+// with MSG_PEEK|MSG_DONTWAIT, so it neither blocks nor consumes the bytes
+// of a pipelined request.
+func peerClosedFunc(conn net.Conn) func() bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	return func() bool {
+		var closed bool
+		buf := make([]byte, 1)
+		err := raw.Read(func(fd uintptr) bool {
+			n, _, rerr := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			switch {
+			case rerr == nil:
+				closed = n == 0 // orderly shutdown (EOF)
+			case errors.Is(rerr, syscall.EAGAIN), errors.Is(rerr, syscall.EINTR):
+				// Nothing to read: still connected.
+			default:
+				closed = true // e.g. ECONNRESET
+			}
+			return true
+		})
+		return closed || err != nil
+	}
+}
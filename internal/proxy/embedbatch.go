@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"sort"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"golang.org/x/sync/errgroup"
+)
+
+// embedChunkWorkers bounds how many chunks of one embeddings batch are in
+// flight at once.
+const embedChunkWorkers = 4
+
+// embedInChunks sends req to embedder, split into chunks of at most size
+// inputs when it holds more. Chunks run concurrently, at most
+// embedChunkWorkers at a time, and the first failure cancels the rest. The
+// vectors are reassembled in input order with indexes into the whole batch,
+// and the chunks' usage is summed. A size below 1 sends req whole.
+func embedInChunks(
+	ctx context.Context,
+	embedder providers.EmbeddingProvider,
+	req *providers.EmbeddingRequest,
+	size int,
+) (*providers.EmbeddingResponse, error) {
+	if size < 1 || len(req.Input) <= size {
+		return embedder.Embed(ctx, req)
+	}
+
+	chunks := (len(req.Input) + size - 1) / size
+	resps := make([]*providers.EmbeddingResponse, chunks)
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(embedChunkWorkers)
+	for i := range chunks {
+		chunk := *req
+		chunk.Input = req.Input[i*size : min((i+1)*size, len(req.Input))]
+		eg.Go(func() error {
+			resp, err := embedder.Embed(egCtx, &chunk)
+			resps[i] = resp
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	out := &providers.EmbeddingResponse{
+		Model: resps[0].Model,
+		Data:  make([]providers.EmbeddingData, 0, len(req.Input)),
+	}
+	for i, resp := range resps {
+		for _, d := range resp.Data {
+			d.Index += i * size
+			out.Data = append(out.Data, d)
+		}
+		out.Usage.InputTokens += resp.Usage.InputTokens
+		out.Usage.OutputTokens += resp.Usage.OutputTokens
+	}
+	sort.SliceStable(out.Data, func(a, b int) bool { return out.Data[a].Index < out.Data[b].Index })
+	return out, nil
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// embedProvider is a funcProvider that also implements EmbeddingProvider.
+type embedProvider struct {
+	*funcProvider
+	embedFn func(ctx context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error)
+}
+
+func (p *embedProvider) Embed(ctx context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	return p.embedFn(ctx, req)
+}
+
+// withEmbeddingBatchSize sets the batch size of provider for the duration of
+// the test.
+func withEmbeddingBatchSize(t *testing.T, provider string, size int) {
+	t.Helper()
+	saved, had := providers.EmbeddingBatchSizes[provider]
+	t.Cleanup(func() {
+		if had {
+			providers.EmbeddingBatchSizes[provider] = saved
+		} else {
+			delete(providers.EmbeddingBatchSizes, provider)
+		}
+	})
+	providers.EmbeddingBatchSizes[provider] = size
+}
+
+func TestDispatchEmbeddings_ChunksLargeBatches(t *testing.T) {
+	withEmbeddingBatchSize(t, "openai", 3)
+
+	var mu sync.Mutex
+	var chunkSizes []int
+	openai := &embedProvider{
+		funcProvider: okProvider("openai"),
+		embedFn: func(_ context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+			mu.Lock()
+			chunkSizes = append(chunkSizes, len(req.Input))
+			mu.Unlock()
+			// Each vector encodes its input; answer in reverse order to
+			// check that the gateway reorders by index.
+			data := make([]providers.EmbeddingData, 0, len(req.Input))
+			for i := len(req.Input) - 1; i >= 0; i-- {
+				v, _ := strconv.Atoi(req.Input[i])
+				data = append(data, providers.EmbeddingData{Index: i, Embedding: []float32{float32(v)}})
+			}
+			return &providers.EmbeddingResponse{
+				Model: req.Model,
+				Data:  data,
+				Usage: providers.Usage{InputTokens: len(req.Input)},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": openai}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	inputs := make([]string, 10)
+	for i := range inputs {
+		inputs[i] = strconv.Itoa(i)
+	}
+	reqBody, _ := json.Marshal(map[string]any{"model": "text-embedding-3-small", "input": inputs})
+	resp := doPost(t, client, "/v1/embeddings", reqBody)
+	raw := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, raw)
+	}
+
+	if len(chunkSizes) != 4 {
+		t.Errorf("provider called with chunks %v, want 4 chunks of at most 3", chunkSizes)
+	}
+	for _, n := range chunkSizes {
+		if n > 3 {
+			t.Errorf("chunk of %d inputs exceeds the batch size", n)
+		}
+	}
+
+	var body struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != len(inputs) {
+		t.Fatalf("got %d vectors, want %d", len(body.Data), len(inputs))
+	}
+	for i, d := range body.Data {
+		if d.Index != i || len(d.Embedding) != 1 || d.Embedding[0] != float32(i) {
+			t.Errorf("data[%d] = index %d embedding %v, want index %d embedding [%d]", i, d.Index, d.Embedding, i, i)
+		}
+	}
+	if body.Usage.PromptTokens != len(inputs) {
+		t.Errorf("prompt_tokens = %d, want the chunks' sum %d", body.Usage.PromptTokens, len(inputs))
+	}
+}
+
+func TestEmbedInChunks_FailedChunkFailsBatch(t *testing.T) {
+	embedder := &embedProvider{
+		funcProvider: okProvider("openai"),
+		embedFn: func(_ context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+			if req.Input[0] == "c" {
+				return nil, &providerError{status: 400, msg: "too many tokens"}
+			}
+			data := make([]providers.EmbeddingData, len(req.Input))
+			for i := range data {
+				data[i].Index = i
+			}
+			return &providers.EmbeddingResponse{Data: data}, nil
+		},
+	}
+
+	_, err := embedInChunks(context.Background(), embedder,
+		&providers.EmbeddingRequest{Input: []string{"a", "b", "c", "d"}}, 2)
+	var pe *providerError
+	if !errors.As(err, &pe) || pe.status != 400 {
+		t.Fatalf("err = %v, want the failing chunk's provider error", err)
+	}
+}
+
+func TestDispatchEmbeddings_RejectsStream(t *testing.T) {
+	called := false
+	openai := &embedProvider{
+		funcProvider: okProvider("openai"),
+		embedFn: func(context.Context, *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+			called = true
+			return &providers.EmbeddingResponse{}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": openai}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/embeddings",
+		[]byte(`{"model":"text-embedding-3-small","input":"hi","stream":true}`))
+	raw := readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(raw), "'stream'") {
+		t.Errorf("status = %d, body = %s; want 400 naming 'stream'", resp.StatusCode, raw)
+	}
+	if called {
+		t.Error("provider was called for a streaming embeddings request")
+	}
+}
@@ -2,11 +2,14 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
 )
 
 // failoverEvent records one failover attempt for observability.
@@ -17,33 +20,102 @@ type failoverEvent struct {
 	LatencyMs int64
 }
 
-// requestWithFailover tries the primary provider and, on retryable errors,
-// walks through providers.DefaultFallbackOrder until one succeeds or
+// errAllCircuitsOpen is returned by requestWithChain, without contacting any
+// provider, when the circuit breaker of every configured candidate is open.
+var errAllCircuitsOpen = errors.New("all providers unavailable")
+
+// requestWithFailover sends req through requestWithChain and, when every
+// candidate fails with a context-length error (see isContextLengthError) and
+// GatewayOptions.ContextFallbackModels names a larger sibling for req.Model,
+// retries once against that model and its own provider. The returned tried
+// list covers both passes.
+func (g *Gateway) requestWithFailover(
+	ctx context.Context,
+	req *providers.ProxyRequest,
+	primary string,
+	route string,
+) (*providers.ProxyResponse, string, []string, error) {
+	resp, name, tried, err := g.requestWithChain(ctx, req, primary, route)
+	if err == nil || !isContextLengthError(err) {
+		return resp, name, tried, err
+	}
+	larger, ok := g.contextFallbacks[req.Model]
+	if !ok || larger == req.Model {
+		return resp, name, tried, err
+	}
+
+	fallbackPrimary := resolveProvider(larger)
+	g.log.InfoContext(ctx, "context_length_fallback",
+		slog.String("request_id", req.RequestID),
+		slog.String("model", req.Model),
+		slog.String("fallback_model", larger),
+		slog.String("provider", fallbackPrimary),
+	)
+	fallbackReq := *req
+	fallbackReq.Model = larger
+	resp, name, retried, err := g.requestWithChain(ctx, &fallbackReq, fallbackPrimary, route)
+	return resp, name, append(tried, retried...), err
+}
+
+// requestWithChain tries the primary provider and, on retryable errors,
+// walks through its fallback chain (see fallbackChain) until one succeeds or
 // g.maxRetries is exhausted.
 //
-// It skips providers whose circuit breaker is in the Open state.
+// It skips providers whose circuit breaker is in the Open state. Between
+// attempts it waits a jittered exponential backoff (see BackoffConfig); the
+// wait aborts as soon as ctx is cancelled. With HedgeAfter set, a slow first
+// attempt is raced against the next candidate (see hedgedRequest).
 // Returns the successful response, the name of the provider that served it,
-// and nil — or nil, "", and an error if every candidate fails.
-func (g *Gateway) requestWithFailover(
+// the providers tried in order (hedged attempts included), and nil — or nil,
+// "", the providers tried, and an error if every candidate fails.
+func (g *Gateway) requestWithChain(
 	ctx context.Context,
 	req *providers.ProxyRequest,
 	primary string,
 	route string,
-) (*providers.ProxyResponse, string, error) {
+) (*providers.ProxyResponse, string, []string, error) {
+	ctx, span := g.startSpan(ctx, "provider.failover", tracing.String("gateway.primary", primary))
+	defer span.End()
 
-	candidates := buildCandidateList(primary)
+	chain := g.fallbackChain(primary, req.Model)
+	candidates := buildCandidateList(primary, chain)
+	if g.latencyRouting && chain != nil && g.health != nil {
+		g.health.sortByLatency(candidates)
+	}
+	if g.healthRouting && chain != nil && g.health != nil {
+		g.health.deprioritizeUnhealthy(candidates)
+	}
+	if !g.anyAdmitted(candidates, req.Model) {
+		g.log.WarnContext(ctx, "all_circuits_open",
+			slog.String("request_id", req.RequestID),
+			slog.String("primary", primary),
+			slog.String("model", req.Model),
+		)
+		if g.metrics != nil {
+			g.metrics.RecordFailoverExhausted(primary, "circuit_open")
+		}
+		span.RecordError(errAllCircuitsOpen)
+		return nil, "", nil, fmt.Errorf("failover: %w: every circuit breaker is open", errAllCircuitsOpen)
+	}
 
-	var lastErr error
+	var (
+		lastErr error
+		tried   []string
+	)
 
 	prevProvider := ""
 	prevReason := ""
 	havePrevFailure := false
 	attempts := 0
+	hedged := make(map[string]bool)
 
-	for _, name := range candidates {
+	for i, name := range candidates {
 		if attempts >= g.maxRetries {
 			break
 		}
+		if hedged[name] {
+			continue // already raced as a hedge
+		}
 
 		prov, ok := g.providers[name]
 		if !ok {
@@ -51,79 +123,93 @@ func (g *Gateway) requestWithFailover(
 		}
 
 		// Skip providers whose circuit breaker is open.
-		if g.cb != nil && !g.cb.Allow(name) {
+		if g.cb != nil && !g.cb.Allow(g.cb.Key(name, req.Model)) {
 			g.log.WarnContext(ctx, "circuit_breaker_open",
 				slog.String("request_id", req.RequestID),
 				slog.String("provider", name),
+				slog.String("model", req.Model),
 			)
 			if g.metrics != nil {
-				g.metrics.RecordCircuitBreakerRejection(name, g.cb.StateLabel(name))
-				g.metrics.SetCircuitBreaker(name, int64(g.cb.State(name)))
-				g.metrics.ObserveUpstreamAttempt(name, route, "circuit_reject", 0)
+				key := g.cb.Key(name, req.Model)
+				g.metrics.RecordCircuitBreakerRejection(name, g.cb.StateLabel(key))
+				g.setCircuitBreakerGauge(key)
+				g.metrics.ObserveUpstreamAttempt(name, route, "circuit_open", 0)
 			}
+			prevProvider = name
+			prevReason = "circuit_open"
 			continue
 		}
 
-		// We are switching to a different provider after a failure.
-		if havePrevFailure && prevProvider != "" && prevProvider != name {
+		// Back off before retrying. Only retryable failures reach this point —
+		// non-retryable ones break out of the loop below. Allow may have
+		// taken a half-open probe slot, so hand it back if we never send.
+		if havePrevFailure {
+			if err := sleepCtx(ctx, g.backoff.delay(attempts-1)); err != nil {
+				if g.cb != nil {
+					g.cb.Release(g.cb.Key(name, req.Model))
+				}
+				lastErr = err
+				break
+			}
+		}
+
+		// We are switching to a different provider after a failure or an
+		// open circuit.
+		if prevProvider != "" && prevProvider != name {
 			if g.metrics != nil {
 				g.metrics.RecordFailover(primary, prevProvider, name, prevReason)
 			}
 		}
 
-		start := time.Now()
-		resp, err := prov.Request(ctx, req)
-		dur := time.Since(start)
-		latencyMs := dur.Milliseconds()
+		var (
+			resp *providers.ProxyResponse
+			err  error
+			dur  time.Duration
+		)
+		// Only the first attempt is hedged, and only if the hedge still
+		// fits in the retry budget.
+		hedge := ""
+		if attempts == 0 && g.maxRetries > 1 {
+			hedge = g.hedgeCandidate(req, name, candidates[i+1:])
+		}
+		if hedge != "" {
+			var launched bool
+			tried = append(tried, name)
+			name, resp, err, dur, launched = g.hedgedRequest(ctx, req, primary, route, name, hedge)
+			if launched {
+				hedged[hedge] = true
+				tried = append(tried, hedge)
+				attempts++
+			}
+		} else {
+			tried = append(tried, name)
+			attemptCtx, attemptSpan := g.startSpan(ctx, "provider.attempt",
+				tracing.String(attrProvider, name), tracing.Int(attrAttempt, attempts+1))
+			start := time.Now()
+			resp, err = prov.Request(attemptCtx, req)
+			dur = time.Since(start)
+			endAttemptSpan(attemptSpan, err)
+		}
 		attempts++
 
 		if err == nil {
-			if g.metrics != nil {
-				g.metrics.ObserveUpstreamAttempt(name, route, "success", dur)
-			}
-			// ── Success ───────────────────────────────────────────────────────
-			if g.cb != nil {
-				g.cb.RecordSuccess(name)
-				if g.metrics != nil {
-					g.metrics.SetCircuitBreaker(name, int64(g.cb.State(name)))
-				}
-			}
-			if name != primary {
-				g.log.InfoContext(ctx, "failover_success",
-					slog.String("request_id", req.RequestID),
-					slog.String("from", primary),
-					slog.String("to", name),
-					slog.Int64("latency_ms", latencyMs),
-				)
-				if g.metrics != nil {
-					g.metrics.RecordFailoverSuccess(primary, name)
-				}
-			}
-			return resp, name, nil
+			g.recordAttemptSuccess(ctx, req, primary, route, name, dur)
+			span.SetAttributes(tracing.String(attrProvider, name), tracing.Int("gateway.attempts", attempts))
+			return resp, name, tried, nil
 		}
 
-		// ── Failure ───────────────────────────────────────────────────────────
-		if g.cb != nil {
-			g.cb.RecordFailure(name)
-			if g.metrics != nil {
-				g.metrics.SetCircuitBreaker(name, int64(g.cb.State(name)))
+		// The caller gave up (e.g. the client disconnected): the provider is
+		// not at fault and nobody is waiting for another attempt. Hand back
+		// the probe slot so a half-open breaker can admit the next request.
+		if errors.Is(ctx.Err(), context.Canceled) {
+			if g.cb != nil {
+				g.cb.Release(g.cb.Key(name, req.Model))
 			}
+			span.RecordError(ctx.Err())
+			return nil, "", tried, ctx.Err()
 		}
 
-		reason := classifyError(err)
-		if g.metrics != nil {
-			g.metrics.ObserveUpstreamAttempt(name, route, reason, dur)
-			g.metrics.RecordError(name, reason)
-		}
-		g.log.WarnContext(ctx, "provider_attempt_failed",
-			slog.String("request_id", req.RequestID),
-			slog.String("from", primary),
-			slog.String("to", name),
-			slog.String("reason", reason),
-			slog.Int64("latency_ms", latencyMs),
-			slog.String("error", err.Error()),
-		)
-
+		reason := g.recordAttemptFailure(ctx, req, primary, route, name, err, dur)
 		lastErr = err
 		prevProvider = name
 		prevReason = reason
@@ -141,17 +227,101 @@ func (g *Gateway) requestWithFailover(
 		lastErr = fmt.Errorf("no providers available")
 	}
 	if g.metrics != nil {
-		g.metrics.RecordFailoverExhausted(primary)
+		g.metrics.RecordFailoverExhausted(primary, "attempts")
+	}
+	span.SetAttributes(tracing.Int("gateway.attempts", attempts))
+	span.RecordError(lastErr)
+	return nil, "", tried, fmt.Errorf("failover: all providers failed after %d attempt(s): %w", attempts, lastErr)
+}
+
+// anyAdmitted reports whether the circuit breaker of at least one configured
+// candidate would admit a request for model.
+func (g *Gateway) anyAdmitted(candidates []string, model string) bool {
+	if g.cb == nil {
+		return true
+	}
+	for _, name := range candidates {
+		if _, ok := g.providers[name]; ok && g.cb.Admits(g.cb.Key(name, model)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAttemptSuccess updates the circuit breaker, metrics and logs after
+// provider name served the request.
+func (g *Gateway) recordAttemptSuccess(
+	ctx context.Context,
+	req *providers.ProxyRequest,
+	primary, route, name string,
+	dur time.Duration,
+) {
+	if g.metrics != nil {
+		g.metrics.ObserveUpstreamAttempt(name, route, "success", dur)
+	}
+	if g.cb != nil {
+		key := g.cb.Key(name, req.Model)
+		g.cb.RecordSuccess(key)
+		if g.metrics != nil {
+			g.setCircuitBreakerGauge(key)
+		}
+	}
+	if name != primary {
+		g.log.InfoContext(ctx, "failover_success",
+			slog.String("request_id", req.RequestID),
+			slog.String("from", primary),
+			slog.String("to", name),
+			slog.Int64("latency_ms", dur.Milliseconds()),
+		)
+		if g.metrics != nil {
+			g.metrics.RecordFailoverSuccess(primary, name)
+		}
+	}
+}
+
+// recordAttemptFailure updates the circuit breaker, metrics and logs after
+// provider name failed with err, and returns the error's classification.
+func (g *Gateway) recordAttemptFailure(
+	ctx context.Context,
+	req *providers.ProxyRequest,
+	primary, route, name string,
+	err error,
+	dur time.Duration,
+) string {
+	if g.cb != nil {
+		key := g.cb.Key(name, req.Model)
+		g.cb.RecordFailure(key)
+		if g.metrics != nil {
+			g.setCircuitBreakerGauge(key)
+		}
+	}
+
+	reason := classifyError(err)
+	if g.metrics != nil {
+		g.metrics.ObserveUpstreamAttempt(name, route, reason, dur)
+		g.metrics.RecordError(name, reason)
 	}
-	return nil, "", fmt.Errorf("failover: all providers failed after %d attempt(s): %w", attempts, lastErr)
+	g.log.WarnContext(ctx, "provider_attempt_failed",
+		slog.String("request_id", req.RequestID),
+		slog.String("from", primary),
+		slog.String("to", name),
+		slog.String("reason", reason),
+		slog.Int64("latency_ms", dur.Milliseconds()),
+		slog.String("error", err.Error()),
+	)
+	return reason
 }
 
 // buildCandidateList returns an ordered slice starting with primary, followed
-// by the remaining providers in DefaultFallbackOrder (deduped).
-func buildCandidateList(primary string) []string {
+// by the providers in chain (deduped). A nil chain means
+// providers.DefaultFallbackOrder; an empty one yields only primary.
+func buildCandidateList(primary string, chain []string) []string {
+	if chain == nil {
+		chain = providers.DefaultFallbackOrder
+	}
 	seen := map[string]bool{primary: true}
 	out := []string{primary}
-	for _, name := range providers.DefaultFallbackOrder {
+	for _, name := range chain {
 		if !seen[name] {
 			seen[name] = true
 			out = append(out, name)
@@ -160,6 +330,23 @@ func buildCandidateList(primary string) []string {
 	return out
 }
 
+// fallbackChain returns the configured fallback chain for a request: the
+// chain of the longest model prefix matching model, else the chain of
+// primary, else nil (the default order).
+func (g *Gateway) fallbackChain(primary, model string) []string {
+	var chain []string
+	longest := -1
+	for prefix, c := range g.modelFallbackChains {
+		if len(prefix) > longest && strings.HasPrefix(model, prefix) {
+			chain, longest = c, len(prefix)
+		}
+	}
+	if longest >= 0 {
+		return chain
+	}
+	return g.fallbackChains[primary]
+}
+
 // isRetryable returns true for errors that should trigger provider failover.
 //
 //   - 5xx provider errors → retryable (infrastructure failure)
@@ -170,9 +357,8 @@ func isRetryable(err error) bool {
 	if err == context.DeadlineExceeded {
 		return true
 	}
-	if sc, ok := err.(providers.StatusCoder); ok {
-		status := sc.HTTPStatus()
-		return status >= 500 && status < 600
+	if pe, ok := providers.AsError(err); ok {
+		return pe.StatusCode >= 500 && pe.StatusCode < 600
 	}
 	return true // unknown errors are treated as retryable
 }
@@ -180,11 +366,11 @@ func isRetryable(err error) bool {
 // classifyError converts an error into a short human-readable category string
 // used in log fields and metrics labels.
 func classifyError(err error) string {
-	if err == context.DeadlineExceeded {
+	if errors.Is(err, context.DeadlineExceeded) {
 		return "timeout"
 	}
-	if sc, ok := err.(providers.StatusCoder); ok {
-		return fmt.Sprintf("http_%d", sc.HTTPStatus())
+	if pe, ok := providers.AsError(err); ok {
+		return fmt.Sprintf("http_%d", pe.StatusCode)
 	}
 	return "unknown"
 }
@@ -1,16 +1,25 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+	"github.com/nulpointcorp/llm-gateway/internal/metrics"
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 )
 
 func TestBuildCandidateList_PrimaryFirst(t *testing.T) {
-	candidates := buildCandidateList("anthropic")
+	candidates := buildCandidateList("anthropic", nil)
 	if candidates[0] != "anthropic" {
 		t.Errorf("expected primary first, got %s", candidates[0])
 	}
@@ -19,7 +28,7 @@ func TestBuildCandidateList_PrimaryFirst(t *testing.T) {
 func TestBuildCandidateList_NoDuplicates(t *testing.T) {
 	for _, primary := range []string{"openai", "anthropic", "gemini", "mistral"} {
 		t.Run(primary, func(t *testing.T) {
-			candidates := buildCandidateList(primary)
+			candidates := buildCandidateList(primary, nil)
 			seen := make(map[string]bool)
 			for _, c := range candidates {
 				if seen[c] {
@@ -32,7 +41,7 @@ func TestBuildCandidateList_NoDuplicates(t *testing.T) {
 }
 
 func TestBuildCandidateList_ContainsAllDefaults(t *testing.T) {
-	candidates := buildCandidateList("openai")
+	candidates := buildCandidateList("openai", nil)
 	set := make(map[string]bool)
 	for _, c := range candidates {
 		set[c] = true
@@ -45,7 +54,7 @@ func TestBuildCandidateList_ContainsAllDefaults(t *testing.T) {
 }
 
 func TestBuildCandidateList_UnknownPrimary(t *testing.T) {
-	candidates := buildCandidateList("custom-provider")
+	candidates := buildCandidateList("custom-provider", nil)
 	if candidates[0] != "custom-provider" {
 		t.Errorf("primary should still be first, got %s", candidates[0])
 	}
@@ -111,6 +120,17 @@ func TestClassifyError_HTTPStatus(t *testing.T) {
 	}
 }
 
+func TestClassifyError_WrappedProviderError(t *testing.T) {
+	err := fmt.Errorf("failover: all providers failed after 2 attempt(s): %w",
+		&providers.Error{Provider: "openai", StatusCode: 429, Message: "rate limited"})
+	if got := classifyError(err); got != "http_429" {
+		t.Errorf("expected 'http_429', got %q", got)
+	}
+	if isRetryable(err) {
+		t.Error("wrapped 429 should NOT be retryable")
+	}
+}
+
 func TestClassifyError_Unknown(t *testing.T) {
 	err := fmt.Errorf("some error")
 	if got := classifyError(err); got != "unknown" {
@@ -118,6 +138,204 @@ func TestClassifyError_Unknown(t *testing.T) {
 	}
 }
 
+func TestBuildCandidateList_CustomChain(t *testing.T) {
+	got := buildCandidateList("anthropic", []string{"bedrock", "anthropic", "gemini"})
+	want := []string{"anthropic", "bedrock", "gemini"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("candidates = %v, want %v", got, want)
+	}
+
+	if got := buildCandidateList("anthropic", []string{}); len(got) != 1 {
+		t.Errorf("empty chain should disable failover, got %v", got)
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), nil, nil, nil, GatewayOptions{
+		FallbackChains: map[string][]string{"anthropic": {"bedrock", "gemini"}},
+		ModelFallbackChains: map[string][]string{
+			"claude-":       {"vertexai"},
+			"claude-3-opus": {"bedrock"},
+		},
+	})
+
+	tests := []struct {
+		primary, model string
+		want           []string
+	}{
+		{"anthropic", "claude-3-opus", []string{"bedrock"}},
+		{"anthropic", "claude-3-haiku", []string{"vertexai"}},
+		{"anthropic", "my-anthropic-alias", []string{"bedrock", "gemini"}},
+		{"openai", "gpt-4o", nil},
+	}
+	for _, tt := range tests {
+		got := gw.fallbackChain(tt.primary, tt.model)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("fallbackChain(%q, %q) = %v, want %v", tt.primary, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackChain_DefaultWhenUnconfigured(t *testing.T) {
+	gw := NewGateway(context.Background(), nil, nil)
+	chain := gw.fallbackChain("anthropic", "claude-3-opus")
+	if chain != nil {
+		t.Fatalf("expected nil chain, got %v", chain)
+	}
+	got := buildCandidateList("anthropic", chain)
+	want := buildCandidateList("anthropic", providers.DefaultFallbackOrder)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("candidates = %v, want default order %v", got, want)
+	}
+}
+
+func TestRequestWithFailover_CustomChainSkipsDefault(t *testing.T) {
+	var openaiCalls int32
+	failing := &funcProvider{
+		name: "anthropic",
+		requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return nil, &providerError{status: 503, msg: "overloaded"}
+		},
+	}
+	openai := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			atomic.AddInt32(&openaiCalls, 1)
+			return &providers.ProxyResponse{ID: "oa", Model: req.Model, Content: "from openai"}, nil
+		},
+	}
+	bedrock := &funcProvider{
+		name: "bedrock",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return &providers.ProxyResponse{ID: "br", Model: req.Model, Content: "from bedrock"}, nil
+		},
+	}
+
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"anthropic": failing,
+		"openai":    openai,
+		"bedrock":   bedrock,
+	}, nil, nil, GatewayOptions{
+		Backoff:             BackoffConfig{BaseDelay: time.Millisecond},
+		ModelFallbackChains: map[string][]string{"claude-": {"bedrock"}},
+	})
+
+	req := &providers.ProxyRequest{
+		Model:     "claude-3-haiku",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "custom-chain",
+	}
+
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "anthropic", "chat_completions")
+	if err != nil {
+		t.Fatalf("expected successful failover, got: %v", err)
+	}
+	if usedProv != "bedrock" || resp.Content != "from bedrock" {
+		t.Errorf("expected bedrock to serve the request, got %s (%q)", usedProv, resp.Content)
+	}
+	if n := atomic.LoadInt32(&openaiCalls); n != 0 {
+		t.Errorf("openai is not in the chain and must not be tried, got %d calls", n)
+	}
+}
+
+func TestRequestWithFailover_LatencyRoutingPrefersFastProvider(t *testing.T) {
+	provs := map[string]providers.Provider{
+		"openai": &slowHealthProvider{name: "openai", delay: 50 * time.Millisecond},
+		"azure":  &slowHealthProvider{name: "azure"},
+	}
+	chains := map[string][]string{"openai": {"azure"}}
+
+	for _, tt := range []struct {
+		latencyRouting bool
+		want           string
+	}{
+		{latencyRouting: true, want: "azure"},
+		{latencyRouting: false, want: "openai"},
+	} {
+		gw := NewGatewayWithOptions(context.Background(), provs, nil, nil, GatewayOptions{
+			FallbackChains: chains,
+			LatencyRouting: tt.latencyRouting,
+		})
+		defer gw.health.Close()
+
+		req := &providers.ProxyRequest{
+			Model:     "gpt-4o",
+			Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+			RequestID: "latency-routing",
+		}
+		_, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if usedProv != tt.want {
+			t.Errorf("latencyRouting=%v: served by %s, want %s", tt.latencyRouting, usedProv, tt.want)
+		}
+	}
+}
+
+// downProvider fails its health probes but still serves requests, counting them.
+type downProvider struct {
+	failingHealthProvider
+	calls int32
+}
+
+func (p *downProvider) Request(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return &providers.ProxyResponse{ID: p.name, Content: "from " + p.name}, nil
+}
+
+func TestRequestWithFailover_HealthRoutingSkipsDownPrimary(t *testing.T) {
+	primary := &downProvider{failingHealthProvider: failingHealthProvider{name: "openai"}}
+	provs := map[string]providers.Provider{
+		"openai": primary,
+		"azure":  &slowHealthProvider{name: "azure"},
+	}
+	gw := NewGatewayWithOptions(context.Background(), provs, nil, nil, GatewayOptions{
+		FallbackChains: map[string][]string{"openai": {"azure"}},
+		HealthRouting:  true,
+	})
+	t.Cleanup(gw.health.Close)
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "health-routing",
+	}
+	_, usedProv, tried, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usedProv != "azure" {
+		t.Errorf("served by %s, want the healthy fallback azure", usedProv)
+	}
+	if n := atomic.LoadInt32(&primary.calls); n != 0 {
+		t.Errorf("health-down primary was tried %d times, want 0 (tried %v)", n, tried)
+	}
+}
+
+func TestRequestWithFailover_HealthRoutingOnlyCandidate(t *testing.T) {
+	primary := &downProvider{failingHealthProvider: failingHealthProvider{name: "openai"}}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{"openai": primary}, nil, nil,
+		GatewayOptions{
+			FallbackChains: map[string][]string{"openai": {"azure"}},
+			HealthRouting:  true,
+		})
+	t.Cleanup(gw.health.Close)
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "health-routing-only",
+	}
+	_, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usedProv != "openai" {
+		t.Errorf("served by %q, want the unhealthy but only candidate openai", usedProv)
+	}
+}
+
 func TestRequestWithFailover_PrimarySuccess(t *testing.T) {
 	var callCount int32
 	primary := &funcProvider{
@@ -140,7 +358,7 @@ func TestRequestWithFailover_PrimarySuccess(t *testing.T) {
 		RequestID: "mock-primary",
 	}
 
-	resp, usedProv, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -182,7 +400,7 @@ func TestRequestWithFailover_FallbackOnFailure(t *testing.T) {
 		RequestID: "mock-fallback",
 	}
 
-	resp, usedProv, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err != nil {
 		t.Fatalf("expected successful failover, got: %v", err)
 	}
@@ -194,6 +412,80 @@ func TestRequestWithFailover_FallbackOnFailure(t *testing.T) {
 	}
 }
 
+// counterValue returns the value of the counter name with the given labels
+// in reg, or 0 when no such series exists.
+func counterValue(t *testing.T, reg *metrics.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.PromRegistry().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+	series:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v != lp.GetValue() {
+					continue series
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestRequestWithFailover_RecordsAttemptMetrics(t *testing.T) {
+	reg := metrics.New()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				return nil, &providerError{status: 500, msg: "down"}
+			},
+		},
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{Metrics: reg, CBConfig: CBConfig{ErrorThreshold: 2}})
+	t.Cleanup(gw.health.Close)
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-attempt-metrics",
+	}
+	send := func() {
+		t.Helper()
+		if _, name, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions"); err != nil || name != "anthropic" {
+			t.Fatalf("served by %q, err = %v; want anthropic", name, err)
+		}
+	}
+
+	// Two failures trip the openai breaker; the third request skips it.
+	send()
+	send()
+	send()
+
+	checks := []struct {
+		metric string
+		labels map[string]string
+		want   float64
+	}{
+		{"gateway_upstream_attempts_total", map[string]string{"provider": "openai", "route": "chat_completions", "outcome": "http_500"}, 2},
+		{"gateway_upstream_attempts_total", map[string]string{"provider": "openai", "route": "chat_completions", "outcome": "circuit_open"}, 1},
+		{"gateway_upstream_attempts_total", map[string]string{"provider": "anthropic", "route": "chat_completions", "outcome": "success"}, 3},
+		{"gateway_failover_events_total", map[string]string{"primary": "openai", "from": "openai", "to": "anthropic", "reason": "http_500"}, 2},
+		{"gateway_failover_events_total", map[string]string{"primary": "openai", "from": "openai", "to": "anthropic", "reason": "circuit_open"}, 1},
+		{"gateway_failover_success_total", map[string]string{"primary": "openai", "to": "anthropic"}, 3},
+	}
+	for _, c := range checks {
+		if got := counterValue(t, reg, c.metric, c.labels); got != c.want {
+			t.Errorf("%s%v = %v, want %v", c.metric, c.labels, got, c.want)
+		}
+	}
+}
+
 func TestRequestWithFailover_AllProvidersFail(t *testing.T) {
 	failing := &funcProvider{
 		name: "openai",
@@ -212,7 +504,7 @@ func TestRequestWithFailover_AllProvidersFail(t *testing.T) {
 		RequestID: "mock-allfail",
 	}
 
-	_, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	_, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err == nil {
 		t.Fatal("expected error when all providers fail")
 	}
@@ -246,7 +538,7 @@ func TestRequestWithFailover_NonRetryableStopsImmediately(t *testing.T) {
 		RequestID: "mock-nonretry",
 	}
 
-	_, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	_, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err == nil {
 		t.Fatal("expected error for 401")
 	}
@@ -277,7 +569,7 @@ func TestRequestWithFailover_CircuitBreakerSkipsOpenProvider(t *testing.T) {
 		RequestID: "mock-cb-skip",
 	}
 
-	resp, usedProv, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err != nil {
 		t.Fatalf("should fallback past open circuit: %v", err)
 	}
@@ -289,6 +581,127 @@ func TestRequestWithFailover_CircuitBreakerSkipsOpenProvider(t *testing.T) {
 	}
 }
 
+func TestRequestWithFailover_AllCircuitsOpenFailsFast(t *testing.T) {
+	calls := 0
+	failing := func(name string) *funcProvider {
+		return &funcProvider{
+			name: name,
+			requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				calls++
+				return nil, &providerError{status: 500, msg: "down"}
+			},
+		}
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai":    failing("openai"),
+		"anthropic": failing("anthropic"),
+	}, nil)
+
+	for _, name := range []string{"openai", "anthropic"} {
+		for i := 0; i < providers.CBErrorThreshold; i++ {
+			gw.cb.RecordFailure(name)
+		}
+	}
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-cb-all-open",
+	}
+	_, _, tried, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if !errors.Is(err, errAllCircuitsOpen) {
+		t.Fatalf("err = %v, want errAllCircuitsOpen", err)
+	}
+	if calls != 0 || len(tried) != 0 {
+		t.Errorf("provider calls = %d, tried = %v; want none", calls, tried)
+	}
+}
+
+func TestDispatchChat_AllCircuitsOpen(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	t.Cleanup(gw.health.Close)
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		gw.cb.RecordFailure("openai")
+	}
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), "all providers unavailable") {
+		t.Errorf("status = %d, body = %s; want 503 all providers unavailable", resp.StatusCode, body)
+	}
+}
+
+func TestDispatchChat_AllCircuitsOpenLogged(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	t.Cleanup(gw.health.Close)
+	sink := &captureSink{}
+	l, err := logger.NewWithSink(context.Background(), sink, logger.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.SetLogger(l)
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		gw.cb.RecordFailure("openai")
+	}
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	_ = l.Close()
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(sink.entries))
+	}
+	if got := sink.entries[0].Status; got != http.StatusServiceUnavailable {
+		t.Errorf("logged status = %d, want 503 as sent to the client", got)
+	}
+}
+
+func TestRequestWithFailover_PerModelCircuitBreaker(t *testing.T) {
+	calls := map[string]int{}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				calls[req.Model]++
+				if req.Model == "gpt-4o" {
+					return nil, &providerError{status: 500, msg: "down"}
+				}
+				return &providers.ProxyResponse{Model: req.Model, Content: "ok"}, nil
+			},
+		},
+	}, nil, nil, GatewayOptions{MaxRetries: 1, CBConfig: CBConfig{PerModel: true}})
+	t.Cleanup(gw.health.Close)
+
+	send := func(model string) error {
+		req := &providers.ProxyRequest{
+			Model:     model,
+			Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+			RequestID: "mock-cb-model",
+		}
+		_, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+		return err
+	}
+
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		if err := send("gpt-4o"); err == nil {
+			t.Fatal("gpt-4o should fail")
+		}
+	}
+	if err := send("gpt-4o"); err == nil {
+		t.Fatal("gpt-4o should be rejected by its open breaker")
+	}
+	if calls["gpt-4o"] != providers.CBErrorThreshold {
+		t.Errorf("gpt-4o calls = %d, want %d (breaker open)", calls["gpt-4o"], providers.CBErrorThreshold)
+	}
+	if err := send("gpt-4o-mini"); err != nil {
+		t.Errorf("gpt-4o-mini should not be affected by the gpt-4o breaker: %v", err)
+	}
+}
+
 func TestRequestWithFailover_MaxRetriesRespected(t *testing.T) {
 	var callCount int32
 	failing := &funcProvider{
@@ -313,7 +726,7 @@ func TestRequestWithFailover_MaxRetriesRespected(t *testing.T) {
 		RequestID: "mock-maxretries",
 	}
 
-	_, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	_, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -322,3 +735,315 @@ func TestRequestWithFailover_MaxRetriesRespected(t *testing.T) {
 			providers.MaxRetries, callCount)
 	}
 }
+
+func TestBackoffConfig_CeilingGrowsAndCaps(t *testing.T) {
+	cfg := BackoffConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2}
+
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for n, w := range want {
+		if got := cfg.ceiling(n); got != w*time.Millisecond {
+			t.Errorf("ceiling(%d) = %v, want %v", n, got, w*time.Millisecond)
+		}
+	}
+}
+
+func TestBackoffConfig_Defaults(t *testing.T) {
+	var cfg BackoffConfig
+	if got := cfg.ceiling(0); got != providers.BackoffBaseDelay {
+		t.Errorf("ceiling(0) = %v, want %v", got, providers.BackoffBaseDelay)
+	}
+	if got := cfg.ceiling(100); got != providers.BackoffMaxDelay {
+		t.Errorf("ceiling(100) = %v, want %v", got, providers.BackoffMaxDelay)
+	}
+}
+
+func TestBackoffConfig_DelayWithinCeiling(t *testing.T) {
+	cfg := BackoffConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second, Multiplier: 3}
+	for n := 0; n < 5; n++ {
+		for i := 0; i < 100; i++ {
+			d := cfg.delay(n)
+			if d < 0 || d >= cfg.ceiling(n) {
+				t.Fatalf("delay(%d) = %v, want in [0, %v)", n, d, cfg.ceiling(n))
+			}
+		}
+	}
+}
+
+func TestRequestWithFailover_BackoffAbortsOnCancel(t *testing.T) {
+	var callCount int32
+	ctx, cancel := context.WithCancel(context.Background())
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			atomic.AddInt32(&callCount, 1)
+			cancel() // client goes away while we would be backing off
+			return nil, &providerError{status: 503, msg: "overloaded"}
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    failing,
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		Backoff: BackoffConfig{BaseDelay: time.Minute, MaxDelay: time.Minute},
+	})
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-backoff-cancel",
+	}
+
+	start := time.Now()
+	_, _, _, err := gw.requestWithFailover(ctx, req, "openai", "chat_completions")
+	if err == nil {
+		t.Fatal("expected error after cancellation")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled request should abort backoff immediately, took %v", elapsed)
+	}
+	if atomic.LoadInt32(&callCount) != 1 {
+		t.Errorf("expected 1 upstream call, got %d", callCount)
+	}
+}
+
+func TestRequestWithFailover_NoBackoffForNonRetryable(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				return nil, &providerError{status: 400, msg: "bad request"}
+			},
+		},
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		Backoff: BackoffConfig{BaseDelay: time.Minute, MaxDelay: time.Minute},
+	})
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-backoff-4xx",
+	}
+
+	start := time.Now()
+	if _, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions"); err == nil {
+		t.Fatal("expected error for 400")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("4xx must not back off, took %v", elapsed)
+	}
+}
+
+func TestDispatchChat_FailoverRecordedInRequestLog(t *testing.T) {
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return nil, &providerError{status: 503, msg: "unavailable"}
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    failing,
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		FallbackChains: map[string][]string{"openai": {"anthropic"}},
+		Backoff:        BackoffConfig{BaseDelay: 1},
+	})
+	t.Cleanup(gw.health.Close)
+
+	var buf bytes.Buffer
+	reqLogger, err := logger.New(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.SetLogger(reqLogger)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	_ = reqLogger.Close() // flushes the entry
+
+	var entry struct {
+		Provider        string `json:"provider"`
+		PrimaryProvider string `json:"primary_provider"`
+		Attempts        int    `json:"attempts"`
+		FailedOver      bool   `json:"failed_over"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", buf.String(), err)
+	}
+	if !entry.FailedOver || entry.Attempts != 2 || entry.PrimaryProvider != "openai" || entry.Provider != "anthropic" {
+		t.Errorf("log entry = %+v, want failover openai -> anthropic in 2 attempts", entry)
+	}
+}
+
+func TestDispatchChat_FailoverReportedInHeaders(t *testing.T) {
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return nil, &providerError{status: 503, msg: "unavailable"}
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    failing,
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		FallbackChains: map[string][]string{"openai": {"anthropic"}},
+		Backoff:        BackoffConfig{BaseDelay: 1},
+	})
+	t.Cleanup(gw.health.Close)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Provider"); got != "anthropic" {
+		t.Errorf("X-Provider = %q, want the fallback anthropic", got)
+	}
+	if got := resp.Header.Get("X-Model"); got != "gpt-4o" {
+		t.Errorf("X-Model = %q, want gpt-4o", got)
+	}
+}
+
+func TestDispatchChat_RedactedLogsOmitContent(t *testing.T) {
+	const secret = "my card is 4111 1111 1111 1111"
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			// Upstream errors commonly quote the offending input.
+			return nil, &providerError{status: 503, msg: "cannot process: " + req.Messages[0].Content}
+		},
+	}
+
+	var buf bytes.Buffer
+	slogger := slog.New(logger.NewRedactingHandler(
+		slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), logger.RedactAll{}))
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    failing,
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		Logger:         slogger,
+		FallbackChains: map[string][]string{"openai": {"anthropic"}},
+		Backoff:        BackoffConfig{BaseDelay: 1},
+	})
+	t.Cleanup(gw.health.Close)
+	reqLogger, err := logger.New(context.Background(), slogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.SetLogger(reqLogger)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"`+secret+`"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	_ = reqLogger.Close()
+
+	out := buf.String()
+	if bytes.Contains(buf.Bytes(), []byte("4111")) {
+		t.Errorf("message content leaked into logs:\n%s", out)
+	}
+	for _, want := range []string{"provider_attempt_failed", logger.RedactedText, `"model":"gpt-4o"`} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("logs missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRequestWithFailover_CancelledProbeReleasesBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(ctx context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				cancel() // the client disconnects mid-probe
+				return nil, ctx.Err()
+			},
+		},
+	}, nil)
+	t.Cleanup(gw.health.Close)
+
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		gw.cb.RecordFailure("openai")
+	}
+	pcb := gw.cb.breakers["openai"]
+	pcb.mu.Lock()
+	pcb.openedAt = time.Now().Add(-providers.CBHalfOpenTimeout - time.Second)
+	pcb.mu.Unlock()
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-cancel-probe",
+	}
+	if _, _, _, err := gw.requestWithFailover(ctx, req, "openai", "chat_completions"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	if st := gw.cb.StateLabel("openai"); st != "half_open" {
+		t.Errorf("breaker = %s, want half_open: a cancelled probe is not a failure", st)
+	}
+	if !gw.cb.Admits("openai") {
+		t.Fatal("cancelled probe stranded its slot; the breaker admits nothing")
+	}
+
+	gw.providers["openai"] = okProvider("openai")
+	if _, _, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions"); err != nil {
+		t.Errorf("next request after a cancelled probe: %v", err)
+	}
+}
+
+func TestRequestWithFailover_BackoffDeadlineReleasesProbe(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				return nil, &providerError{status: 503, msg: "overloaded"}
+			},
+		},
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{
+		Backoff: BackoffConfig{BaseDelay: time.Minute, MaxDelay: time.Minute},
+	})
+	t.Cleanup(gw.health.Close)
+
+	// anthropic is due a half-open probe; the deadline expires while the
+	// gateway backs off before sending it.
+	for i := 0; i < providers.CBErrorThreshold; i++ {
+		gw.cb.RecordFailure("anthropic")
+	}
+	pcb := gw.cb.breakers["anthropic"]
+	pcb.mu.Lock()
+	pcb.openedAt = time.Now().Add(-providers.CBHalfOpenTimeout - time.Second)
+	pcb.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "mock-backoff-probe",
+	}
+	if _, _, _, err := gw.requestWithFailover(ctx, req, "openai", "chat_completions"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	if !gw.cb.Admits("anthropic") {
+		t.Errorf("breaker = %s and admits nothing; the probe slot was stranded", gw.cb.StateLabel("anthropic"))
+	}
+}
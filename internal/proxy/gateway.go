@@ -9,19 +9,26 @@
 //   - Proxy overhead < 2 ms P50 (SLA). No blocking I/O on the hot path.
 //   - Logger, cache, and rate limiter are optional and nil-safe.
 //   - All I/O uses context.Context so timeouts propagate correctly.
-//   - Streaming responses are pass-through (SSE); they are never cached.
+//   - Streaming responses are pass-through (SSE); they are cached only when
+//     GatewayOptions.CacheStreams is set, after the stream completes. Cache
+//     hits are replayed to streaming clients as SSE.
 package proxy
 
 import (
 	"bufio"
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -30,8 +37,11 @@ import (
 	"github.com/nulpointcorp/llm-gateway/internal/metrics"
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/internal/webhook"
 	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -42,6 +52,32 @@ const (
 	// information is available in the request context. Real limits are enforced
 	// by the billing layer; this prevents runaway token consumption.
 	defaultTPMLimit = 2_000_000
+
+	// defaultReplayChunkSize is the number of characters per SSE delta when a
+	// cached response is replayed as a stream.
+	defaultReplayChunkSize = 20
+
+	// defaultQueueTimeout bounds how long a request waits for a concurrency
+	// slot before being rejected with 503.
+	defaultQueueTimeout = 5 * time.Second
+
+	// defaultQueueAging is how long a queued request waits before it is
+	// ranked one priority tier higher.
+	defaultQueueAging = time.Second
+
+	// defaultMaxRequestBytes caps request bodies; larger ones get 413.
+	defaultMaxRequestBytes = 10 << 20
+
+	// defaultCompressMinBytes is the smallest response body compressed when
+	// response compression is enabled.
+	defaultCompressMinBytes = 1024
+
+	// maxTopLogProbs is the largest top_logprobs OpenAI accepts.
+	maxTopLogProbs = 20
+
+	// sseKeepAlive is the comment line writeSSE sends while the provider is
+	// idle.
+	sseKeepAlive = ": keepalive\n\n"
 )
 
 // GatewayOptions holds optional tuning parameters for a Gateway. All fields
@@ -63,17 +99,208 @@ type GatewayOptions struct {
 	// Zero values use the package-level defaults.
 	CBConfig CBConfig
 
+	// Backoff configures the jittered delay between failover attempts.
+	// Zero values use the package-level defaults.
+	Backoff BackoffConfig
+
+	// FallbackChains maps a primary provider to the ordered providers tried
+	// after it, replacing providers.DefaultFallbackOrder for that primary.
+	// An empty chain disables failover. Primaries not listed keep the default.
+	FallbackChains map[string][]string
+
+	// ModelFallbackChains maps a model-name prefix (e.g. "claude-") to a
+	// fallback chain. The longest matching prefix wins and takes precedence
+	// over FallbackChains.
+	ModelFallbackChains map[string][]string
+
+	// LatencyRouting orders the primary and its configured fallback chain by
+	// the health checker's rolling P50 latency, fastest first. Requests
+	// without a configured chain keep the static order, since the default
+	// chain spans providers that do not serve the same models.
+	LatencyRouting bool
+
+	// HealthRouting moves providers that failed their latest health probe to
+	// the end of the primary's configured fallback chain, so a known-down
+	// provider is not tried first on every request. They are still tried,
+	// last, so a request whose every candidate is unhealthy is attempted
+	// anyway. Like LatencyRouting it leaves the default chain alone.
+	HealthRouting bool
+
+	// HedgeAfter, when positive, starts the next candidate in parallel if the
+	// first provider has not answered a non-streaming request within this
+	// delay; the first success wins and the other attempt is cancelled.
+	// Zero disables hedging.
+	HedgeAfter time.Duration
+
+	// ShadowProvider names a provider that receives a copy of a sample of
+	// served chat requests in the background. Its responses are discarded and
+	// only its latency, tokens and errors are recorded, under the "shadow"
+	// route label. Empty disables shadowing.
+	ShadowProvider string
+
+	// ShadowSampleRate is the fraction of requests, in [0, 1], mirrored to
+	// ShadowProvider.
+	ShadowSampleRate float64
+
+	// ModerationProvider names a provider implementing
+	// providers.ModerationProvider that screens every chat prompt before
+	// the cache lookup and dispatch. Flagged prompts get 400 content_filter.
+	// Empty disables moderation.
+	ModerationProvider string
+
+	// ModerationModel is the moderation model requested from
+	// ModerationProvider. Empty uses the provider's default.
+	ModerationModel string
+
+	// ModerationFailClosed rejects requests with 503 when the moderation
+	// call fails. By default such requests proceed unscreened.
+	ModerationFailClosed bool
+
+	// MaxConcurrency caps the number of in-flight provider calls across the
+	// gateway. Zero means unlimited.
+	MaxConcurrency int
+
+	// ProviderMaxConcurrency caps in-flight calls per primary provider, on
+	// top of MaxConcurrency. Providers not listed are only bound by the
+	// global cap.
+	ProviderMaxConcurrency map[string]int
+
+	// QueueDepth is the number of requests allowed to wait for a free slot
+	// once a concurrency limit is reached. Requests beyond it, and queued
+	// requests still waiting after QueueTimeout, get 503 with Retry-After.
+	// Zero rejects immediately.
+	QueueDepth int
+
+	// QueueTimeout bounds how long a queued request waits for a slot.
+	// Default: defaultQueueTimeout.
+	QueueTimeout time.Duration
+
+	// QueueAging is how long a queued request waits before it is ranked one
+	// priority tier higher, so low-priority requests are not starved by a
+	// steady stream of high-priority ones. Default: defaultQueueAging.
+	QueueAging time.Duration
+
 	// AllowClientAPIKeys enables forwarding Authorization headers from clients
 	// directly to upstream providers. When false, client headers are ignored and
 	// only configured keys are used.
 	AllowClientAPIKeys bool
 
+	// GatewayAPIKeys, when non-empty, requires every /v1/ request to present
+	// one of these keys; others get 401. Entries are plaintext keys or
+	// GatewayKeyHashPrefix followed by the key's hex SHA-256. The matched key
+	// identifies the caller for token budgets and request logs.
+	GatewayAPIKeys []string
+
+	// VirtualKeys are scoped client keys accepted alongside GatewayAPIKeys,
+	// each limited to its own models, rates and token budget. Budgets and
+	// per-key rates are tracked in process unless SetVirtualKeyStores is
+	// called.
+	VirtualKeys []VirtualKey
+
+	// Pricing maps models to their token prices. Each logged request carries
+	// its cost, which also feeds gateway_cost_usd_total. A model without an
+	// exact entry uses the longest entry that prefixes it; unpriced models
+	// and cache hits cost nothing.
+	Pricing map[string]ModelPrice
+
 	// Metrics enables Prometheus metrics collection. When nil, metrics are disabled.
 	Metrics *metrics.Registry
 
+	// Tracer records request spans. When nil, tracing is disabled.
+	Tracer tracing.Tracer
+
 	// CacheTTL controls the default TTL for cached responses.
 	// Default: 1h.
 	CacheTTL time.Duration
+
+	// CacheErrors enables negative caching: deterministic 4xx provider errors
+	// are cached like responses and replayed with their original status.
+	// 401, 403, 408, 429 and 5xx are never cached.
+	CacheErrors bool
+
+	// ErrorCacheTTL is the TTL for negatively cached errors. Default: 1m.
+	ErrorCacheTTL time.Duration
+
+	// CacheStreams caches the response reconstructed from a streaming request
+	// under the same key as its non-streaming equivalent, so streaming clients
+	// warm the cache. The live stream is unaffected.
+	CacheStreams bool
+
+	// ReplayChunkSize is the number of characters per SSE delta when a cached
+	// response is replayed to a stream:true request. Default: 20.
+	ReplayChunkSize int
+
+	// KeyNamespace is prepended to every cache and idempotency key, so
+	// deployments sharing a Redis database never serve each other's
+	// entries. Pass the same prefix to the Redis limiters
+	// (ratelimit.WithKeyPrefix) and cache.ExactCache.SetNamespace.
+	KeyNamespace string
+
+	// StreamKeepAlive, when positive, writes an SSE comment line to a
+	// streaming client whenever the provider has sent nothing for this long,
+	// so proxies and load balancers do not drop a connection that is waiting
+	// on a slow first token. Zero disables keep-alives.
+	StreamKeepAlive time.Duration
+
+	// TPMLimit is the tokens-per-minute budget per workspace (or API key)
+	// enforced when a TPM limiter is injected. Default: defaultTPMLimit.
+	TPMLimit int
+
+	// MaxRequestBytes is the largest request body the server accepts; larger
+	// bodies are rejected with 413 before they are buffered or parsed.
+	// Default: defaultMaxRequestBytes (10 MiB).
+	MaxRequestBytes int
+
+	// CompressResponses gzip- or deflate-encodes non-streaming response
+	// bodies of at least CompressMinBytes for clients that accept it.
+	CompressResponses bool
+
+	// CompressMinBytes is the smallest body CompressResponses encodes.
+	// Default: defaultCompressMinBytes (1 KiB).
+	CompressMinBytes int
+
+	// PassthroughUnavailable answers 503 Service Unavailable, with the
+	// upstream's Retry-After when it sent one, when the last provider tried
+	// returned 503, instead of the default 502. Clients can then tell a
+	// temporarily overloaded upstream from a broken gateway.
+	PassthroughUnavailable bool
+
+	// PassthroughHeaders lists the upstream response headers copied onto
+	// the gateway's response when the provider captures them (OpenAI and
+	// Anthropic capture their rate-limit headers). Names are
+	// case-insensitive; a trailing "*" matches a prefix, e.g.
+	// "x-ratelimit-*". Empty passes nothing.
+	PassthroughHeaders []string
+
+	// SamplingPolicy decides what happens to temperature and top_p sent to a
+	// model that rejects them, such as OpenAI's o-series:
+	// SamplingPolicyStrip (the default) drops them with a warning header,
+	// SamplingPolicyReject answers 400.
+	SamplingPolicy string
+
+	// StrictValidation rejects chat, embeddings and rerank requests that
+	// carry unknown fields or values of the wrong type with a 400 naming
+	// every offending field. By default unknown fields are ignored.
+	StrictValidation bool
+
+	// CheckContextWindow rejects chat requests whose estimated prompt plus
+	// max_tokens exceeds the model's entry in providers.ContextWindows with
+	// 400 context_length_exceeded instead of forwarding them.
+	CheckContextWindow bool
+
+	// ContextFallbackModels maps a model to a larger-context sibling. When a
+	// provider rejects a request to the model with a context-length error,
+	// it is retried once against the sibling (routed by its own name)
+	// instead of failing.
+	ContextFallbackModels map[string]string
+
+	// RequestTransformers rewrite each chat request, in order, before the
+	// cache lookup and provider dispatch.
+	RequestTransformers []RequestTransformer
+
+	// ResponseTransformers rewrite each non-streaming chat response, in
+	// order, before it is serialized and cached.
+	ResponseTransformers []ResponseTransformer
 }
 
 // Gateway is the main proxy — all dependencies are injected via the constructor
@@ -86,21 +313,86 @@ type Gateway struct {
 	baseCtx   context.Context
 	log       *slog.Logger
 	metrics   *metrics.Registry
+	tracer    tracing.Tracer
 
 	// Configurable failover parameters (set from GatewayOptions).
 	maxRetries      int
 	providerTimeout time.Duration
 	cacheTTL        time.Duration
+	maxRequestBytes int
+	cacheErrors     bool
+	errorCacheTTL   time.Duration
+	cacheStreams    bool
+	replayChunkSize int
+	keyNamespace    string
+	streamKeepAlive time.Duration
+	backoff         BackoffConfig
+	tpmLimit        int
+
+	samplingPolicy      string
+	strictValidation    bool
+	checkContextWindows bool
+	contextFallbacks    map[string]string
+
+	requestTransformers  []RequestTransformer
+	responseTransformers []ResponseTransformer
+
+	moderator            providers.ModerationProvider
+	moderationModel      string
+	moderationFailClosed bool
+
+	compressResponses bool
+	compressMinBytes  int
+
+	passthroughHeaders     *headerPassthrough
+	passthroughUnavailable bool
+
+	fallbackChains      map[string][]string
+	modelFallbackChains map[string][]string
+	latencyRouting      bool
+	healthRouting       bool
+	hedgeAfter          time.Duration
+	shadowProvider      string
+	shadowSampleRate    float64
+
+	// Concurrency limiters — nil when unlimited.
+	concurrency         *concurrencyLimiter
+	providerConcurrency map[string]*concurrencyLimiter
+	queueTimeout        time.Duration
 
 	// Optional dependencies — nil-safe when not configured.
-	rpmLimiter      *ratelimit.RPMLimiter
+	rpmLimiter      ratelimit.Limiter
+	tpmLimiter      *ratelimit.TPMLimiter
 	reqLogger       *logger.Logger
+	webhook         *webhook.Notifier
 	cacheExclusions *cache.ExclusionList
+	cacheTTLs       *cache.TTLOverrides
+	idempotency     *idempotencyStore
+
+	// cacheHits and cacheMisses count lookups for GET /admin/cache/stats,
+	// independently of whether Prometheus metrics are enabled.
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	// flight coalesces concurrent cache misses for the same cache key into a
+	// single upstream call (see dispatchChat).
+	flight singleflight.Group
 
 	// CORS allowed origins. Empty slice means deny all; ["*"] means allow all.
 	corsOrigins []string
 
 	allowClientAPIKeys bool
+
+	// gatewayKeys authenticates inbound requests; nil disables authentication.
+	gatewayKeys gatewayKeySet
+
+	// virtualKeys maps a key digest to its scope; nil when none are set.
+	virtualKeys map[string]*VirtualKey
+	vkBudgets   ratelimit.BudgetStore
+	vkLimiter   ratelimit.KeyedLimiter
+
+	// pricing prices requests for the request log and cost metric.
+	pricing pricingTable
 }
 
 // SetCORSOrigins configures the allowed CORS origins for the gateway.
@@ -157,23 +449,110 @@ func NewGatewayWithOptions(
 		cacheTTL = time.Hour
 	}
 
+	errorCacheTTL := opts.ErrorCacheTTL
+	if errorCacheTTL <= 0 {
+		errorCacheTTL = defaultErrorCacheTTL
+	}
+
+	replayChunkSize := opts.ReplayChunkSize
+	if replayChunkSize <= 0 {
+		replayChunkSize = defaultReplayChunkSize
+	}
+
+	tpmLimit := opts.TPMLimit
+	if tpmLimit <= 0 {
+		tpmLimit = defaultTPMLimit
+	}
+
+	queueTimeout := opts.QueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultQueueTimeout
+	}
+	queueAging := opts.QueueAging
+	if queueAging <= 0 {
+		queueAging = defaultQueueAging
+	}
+
+	maxRequestBytes := opts.MaxRequestBytes
+	if maxRequestBytes <= 0 {
+		maxRequestBytes = defaultMaxRequestBytes
+	}
+
+	compressMinBytes := opts.CompressMinBytes
+	if compressMinBytes <= 0 {
+		compressMinBytes = defaultCompressMinBytes
+	}
+
 	gw := &Gateway{
-		providers:          provs,
-		cache:              c,
-		cb:                 NewCircuitBreakerWithConfig(opts.CBConfig),
-		baseCtx:            baseCtx,
-		log:                log,
-		maxRetries:         maxRetries,
-		providerTimeout:    providerTimeout,
-		cacheTTL:           cacheTTL,
-		metrics:            opts.Metrics,
-		allowClientAPIKeys: opts.AllowClientAPIKeys,
+		providers:              provs,
+		cache:                  c,
+		cb:                     NewCircuitBreakerWithConfig(opts.CBConfig),
+		baseCtx:                baseCtx,
+		log:                    log,
+		maxRetries:             maxRetries,
+		providerTimeout:        providerTimeout,
+		cacheTTL:               cacheTTL,
+		maxRequestBytes:        maxRequestBytes,
+		keyNamespace:           opts.KeyNamespace,
+		samplingPolicy:         opts.SamplingPolicy,
+		strictValidation:       opts.StrictValidation,
+		checkContextWindows:    opts.CheckContextWindow,
+		contextFallbacks:       opts.ContextFallbackModels,
+		requestTransformers:    opts.RequestTransformers,
+		responseTransformers:   opts.ResponseTransformers,
+		compressResponses:      opts.CompressResponses,
+		compressMinBytes:       compressMinBytes,
+		passthroughHeaders:     newHeaderPassthrough(opts.PassthroughHeaders),
+		passthroughUnavailable: opts.PassthroughUnavailable,
+		cacheErrors:            opts.CacheErrors,
+		errorCacheTTL:          errorCacheTTL,
+		cacheStreams:           opts.CacheStreams,
+		replayChunkSize:        replayChunkSize,
+		streamKeepAlive:        opts.StreamKeepAlive,
+		backoff:                opts.Backoff,
+		tpmLimit:               tpmLimit,
+		fallbackChains:         opts.FallbackChains,
+		modelFallbackChains:    opts.ModelFallbackChains,
+		latencyRouting:         opts.LatencyRouting,
+		healthRouting:          opts.HealthRouting,
+		hedgeAfter:             opts.HedgeAfter,
+		shadowProvider:         opts.ShadowProvider,
+		shadowSampleRate:       opts.ShadowSampleRate,
+		concurrency:            newConcurrencyLimiter(globalConcurrencyScope, opts.MaxConcurrency, opts.QueueDepth, queueTimeout, queueAging, opts.Metrics),
+		queueTimeout:           queueTimeout,
+		metrics:                opts.Metrics,
+		tracer:                 opts.Tracer,
+		allowClientAPIKeys:     opts.AllowClientAPIKeys,
+		gatewayKeys:            newGatewayKeySet(opts.GatewayAPIKeys),
+		virtualKeys:            newVirtualKeyIndex(opts.VirtualKeys),
+		pricing:                opts.Pricing,
+	}
+
+	if len(opts.ProviderMaxConcurrency) > 0 {
+		gw.providerConcurrency = make(map[string]*concurrencyLimiter, len(opts.ProviderMaxConcurrency))
+		for name, limit := range opts.ProviderMaxConcurrency {
+			if l := newConcurrencyLimiter(name, limit, opts.QueueDepth, queueTimeout, queueAging, opts.Metrics); l != nil {
+				gw.providerConcurrency[name] = l
+			}
+		}
+	}
+
+	if name := opts.ModerationProvider; name != "" {
+		gw.moderator, _ = providers.AsModerationProvider(provs[name])
+		gw.moderationModel = opts.ModerationModel
+		gw.moderationFailClosed = opts.ModerationFailClosed
+	}
+
+	if gw.virtualKeys != nil {
+		gw.vkBudgets = ratelimit.NewMemoryBudgetStore()
+		gw.vkLimiter = ratelimit.NewMemoryKeyedRPMLimiter()
 	}
 
 	// Initialise circuit breaker gauges (closed) for known providers.
-	if gw.metrics != nil && gw.cb != nil {
+	// Per-model breakers only appear once a model first fails.
+	if gw.metrics != nil && gw.cb != nil && !opts.CBConfig.PerModel {
 		for _, name := range providers.DefaultFallbackOrder {
-			gw.metrics.SetCircuitBreaker(name, int64(gw.cb.State(name)))
+			gw.setCircuitBreakerGauge(name)
 		}
 	}
 
@@ -184,22 +563,47 @@ func NewGatewayWithOptions(
 	return gw
 }
 
-// SetRateLimiters injects the RPM rate limiter.
-func (g *Gateway) SetRateLimiters(rpm *ratelimit.RPMLimiter) {
+// SetRateLimiters injects the RPM rate limiter (Redis-backed or in-memory).
+func (g *Gateway) SetRateLimiters(rpm ratelimit.Limiter) {
 	g.rpmLimiter = rpm
 }
 
+// SetTPMLimiter injects the tokens-per-minute limiter.
+func (g *Gateway) SetTPMLimiter(tpm *ratelimit.TPMLimiter) {
+	g.tpmLimiter = tpm
+}
+
 // SetLogger injects the async request logger (e.g. for ClickHouse or stdout).
 func (g *Gateway) SetLogger(l *logger.Logger) {
 	g.reqLogger = l
 }
 
+// SetWebhook injects the notifier that posts a summary of each completed or
+// failed request to a callback URL.
+func (g *Gateway) SetWebhook(n *webhook.Notifier) {
+	g.webhook = n
+}
+
 // SetCacheExclusions injects the cache exclusion list.
 // Requests whose model name matches any rule skip both cache GET and SET.
 func (g *Gateway) SetCacheExclusions(el *cache.ExclusionList) {
 	g.cacheExclusions = el
 }
 
+// SetCacheTTLOverrides injects per-model cache TTLs. Models without an
+// override are cached for the global CacheTTL.
+func (g *Gateway) SetCacheTTLOverrides(o *cache.TTLOverrides) {
+	g.cacheTTLs = o
+}
+
+// cacheTTLFor returns the TTL responses for model are cached with.
+func (g *Gateway) cacheTTLFor(model string) time.Duration {
+	if ttl, ok := g.cacheTTLs.TTL(model); ok {
+		return ttl
+	}
+	return g.cacheTTL
+}
+
 // ── Internal request / response types ─────────────────────────────────────────
 
 type (
@@ -210,12 +614,18 @@ type (
 		Model          string          `json:"model"`
 		Input          json.RawMessage `json:"input"`
 		EncodingFormat string          `json:"encoding_format"`
+		Dimensions     *int            `json:"dimensions"`
+		// Stream is accepted only to be rejected: embeddings are not
+		// streamable, and ignoring it would hide a client bug.
+		Stream bool `json:"stream"`
 	}
 
+	// outboundEmbeddingData carries its vector as []float32, or as a string
+	// when the client asked for encoding_format "base64".
 	outboundEmbeddingData struct {
-		Object    string    `json:"object"`
-		Index     int       `json:"index"`
-		Embedding []float32 `json:"embedding"`
+		Object    string `json:"object"`
+		Index     int    `json:"index"`
+		Embedding any    `json:"embedding"`
 	}
 
 	outboundEmbeddingUsage struct {
@@ -231,6 +641,16 @@ type (
 	}
 )
 
+// encodeEmbeddingBase64 renders a vector as OpenAI does for encoding_format
+// "base64": its little-endian float32 bytes, base64-encoded.
+func encodeEmbeddingBase64(vec []float32) string {
+	buf := make([]byte, 4*len(vec))
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
 // parseEmbeddingInput converts the raw JSON "input" field into []string.
 // The OpenAI API accepts either a bare string or an array of strings.
 func parseEmbeddingInput(raw json.RawMessage) ([]string, error) {
@@ -288,14 +708,21 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 		g.metrics.AddTokens(servedProvider, route, inputTokens, outputTokens, cached)
 	}()
 
+	tctx, span := g.startRequestSpan(ctx, route)
+	defer func() {
+		endRequestSpan(span, ctx.Response.StatusCode(), servedProvider, cacheLabel)
+	}()
+
 	reqID, _ := ctx.UserValue("request_id").(string)
+	caller := callerID(ctx)
+	vk := requestVirtualKey(ctx)
 	clientKey, clientKeyID := g.extractClientAPIKey(ctx)
+	ev := requestWebhookEvent(ctx)
 
 	// 1. Parse request.
 	var req inboundEmbeddingRequest
-	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
-		apierr.Write(ctx, fasthttp.StatusBadRequest,
-			fmt.Sprintf("invalid JSON: %s", err.Error()),
+	if err := g.decodeRequest(ctx.PostBody(), &req); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
 			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
 		return
 	}
@@ -307,6 +734,13 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if req.Stream {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			"'stream' is not supported for embeddings",
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
 	inputs, err := parseEmbeddingInput(req.Input)
 	if err != nil {
 		apierr.Write(ctx, fasthttp.StatusBadRequest,
@@ -314,12 +748,29 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			"'encoding_format' must be \"float\" or \"base64\"",
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	if req.Dimensions != nil && *req.Dimensions < 1 {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			"'dimensions' must be at least 1",
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
 	// 2. Resolve provider.
 	providerName := resolveEmbeddingProvider(req.Model)
 	servedProvider = providerName
+	ev.route(providerName, providerName, req.Model)
+	span.SetAttributes(tracing.String(attrModel, req.Model))
 
 	g.log.InfoContext(ctx, "embedding_request",
 		slog.String("request_id", reqID),
+		slog.String("caller", caller),
 		slog.String("model", req.Model),
 		slog.String("provider", providerName),
 		slog.Int("inputs", len(inputs)),
@@ -332,6 +783,10 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	if !g.enforceVirtualKey(ctx, vk, req.Model) {
+		return
+	}
+
 	// 3. Find a provider that implements EmbeddingProvider.
 	prov, ok := g.providers[providerName]
 	if !ok {
@@ -343,6 +798,7 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 	}
 	if prov != nil {
 		servedProvider = prov.Name()
+		ev.route(providerName, servedProvider, req.Model)
 	}
 
 	embedder, ok := prov.(providers.EmbeddingProvider)
@@ -354,19 +810,34 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 	}
 
 	// 4. Call the provider.
-	provCtx, cancel := context.WithTimeout(ctx, g.providerTimeout)
+	releaseSlot, err := g.acquireConcurrency(ctx, servedProvider)
+	if err != nil {
+		g.log.WarnContext(ctx, "concurrency_limit_exceeded",
+			slog.String("request_id", reqID),
+			slog.String("provider", servedProvider),
+		)
+		apierr.WriteOverloaded(ctx, g.queueTimeout)
+		return
+	}
+	defer releaseSlot()
+
+	provCtx, cancel := context.WithTimeout(tctx, g.providerTimeout)
 	defer cancel()
 
 	embReq := &providers.EmbeddingRequest{
-		Input:     inputs,
-		Model:     req.Model,
-		RequestID: reqID,
-		APIKey:    clientKey,
-		APIKeyID:  clientKeyID,
+		Input:      inputs,
+		Model:      req.Model,
+		Dimensions: req.Dimensions,
+		RequestID:  reqID,
+		APIKey:     clientKey,
+		APIKeyID:   clientKeyID,
 	}
 
 	upStart := time.Now()
-	embResp, err := embedder.Embed(provCtx, embReq)
+	attemptCtx, attemptSpan := g.startSpan(provCtx, "provider.attempt",
+		tracing.String(attrProvider, servedProvider), tracing.Int(attrAttempt, 1))
+	embResp, err := embedInChunks(attemptCtx, embedder, embReq, providers.EmbeddingBatchSizes[servedProvider])
+	endAttemptSpan(attemptSpan, err)
 	upDur := time.Since(upStart)
 	if err != nil {
 		if g.metrics != nil {
@@ -380,12 +851,13 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 			slog.String("error", err.Error()),
 			slog.Duration("elapsed", time.Since(start)),
 		)
-		handleProviderError(ctx, err)
+		g.handleProviderError(ctx, err)
 		return
 	}
 	if g.metrics != nil {
 		g.metrics.ObserveUpstreamAttempt(servedProvider, route, "success", upDur)
 	}
+	g.chargeVirtualKey(vk, embResp.Usage.InputTokens)
 
 	// 5. Build OpenAI-compatible response.
 	outData := make([]outboundEmbeddingData, len(embResp.Data))
@@ -395,6 +867,9 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 			Index:     d.Index,
 			Embedding: d.Embedding,
 		}
+		if req.EncodingFormat == "base64" {
+			outData[i].Embedding = encodeEmbeddingBase64(d.Embedding)
+		}
 	}
 
 	out := outboundEmbeddingResponse{
@@ -407,6 +882,7 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 		},
 	}
 	inputTokens = embResp.Usage.InputTokens
+	ev.usage(inputTokens, 0)
 
 	body, err := json.Marshal(out)
 	if err != nil {
@@ -430,17 +906,28 @@ func (g *Gateway) dispatchEmbeddings(ctx *fasthttp.RequestCtx) {
 	respBytes = len(body)
 }
 
-// extractClientAPIKey returns the Authorization bearer token (if allowed and present)
-// and a deterministic SHA-256 hash suitable for cache partitioning.
+// clientKeyHeaders are the headers, after Authorization, that may carry a
+// client-supplied provider key, in order of precedence. Azure SDKs send
+// api-key; other tooling commonly sends X-API-Key.
+var clientKeyHeaders = []string{"X-API-Key", "api-key"}
+
+// extractClientAPIKey returns the client-supplied provider key (if allowed and
+// present) and a deterministic SHA-256 hash suitable for cache partitioning.
+// The key is taken from the first of Authorization: Bearer, X-API-Key and
+// api-key that is set; Authorization is skipped when it carried the gateway key.
 func (g *Gateway) extractClientAPIKey(ctx *fasthttp.RequestCtx) (token string, tokenID string) {
 	if !g.allowClientAPIKeys {
 		return "", ""
 	}
-	raw := strings.TrimSpace(string(ctx.Request.Header.Peek("Authorization")))
-	if raw == "" {
-		return "", ""
+	// A gateway key sent as the bearer token is not a provider key.
+	if fromAuth, _ := ctx.UserValue(gatewayAuthInKey).(bool); !fromAuth {
+		token = parseBearerToken(strings.TrimSpace(string(ctx.Request.Header.Peek("Authorization"))))
+	}
+	for _, h := range clientKeyHeaders {
+		if token == "" {
+			token = strings.TrimSpace(string(ctx.Request.Header.Peek(h)))
+		}
 	}
-	token = parseBearerToken(raw)
 	if token == "" {
 		return "", ""
 	}
@@ -468,15 +955,76 @@ func parseBearerToken(header string) string {
 
 type (
 	inboundMessage struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
+		Role       string         `json:"role"`
+		Content    messageContent `json:"content"`
+		ToolCalls  []wireToolCall `json:"tool_calls"`
+		ToolCallID string         `json:"tool_call_id"`
+		// Name and Refusal are accepted for OpenAI compatibility so strict
+		// validation does not reject them; they are not forwarded.
+		Name    string          `json:"name"`
+		Refusal json.RawMessage `json:"refusal"`
+	}
+
+	// wireToolCall is a tool call in the OpenAI wire format, both on
+	// assistant messages sent by the client and in responses.
+	wireToolCall struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
 	}
 	inboundRequest struct {
-		Model       string           `json:"model"`
-		Messages    []inboundMessage `json:"messages"`
-		Stream      bool             `json:"stream"`
-		Temperature float64          `json:"temperature"`
-		MaxTokens   int              `json:"max_tokens"`
+		Model            string           `json:"model"`
+		Messages         []inboundMessage `json:"messages"`
+		Stream           bool             `json:"stream"`
+		Temperature      *float64         `json:"temperature"`
+		MaxTokens        int              `json:"max_tokens"`
+		N                int              `json:"n"`
+		TopP             *float64         `json:"top_p"`
+		Stop             stopSequences    `json:"stop"`
+		PresencePenalty  *float64         `json:"presence_penalty"`
+		FrequencyPenalty *float64         `json:"frequency_penalty"`
+		Seed             *int64           `json:"seed"`
+		LogProbs         *bool            `json:"logprobs"`
+		TopLogProbs      *int             `json:"top_logprobs"`
+		StreamOptions    *streamOptions   `json:"stream_options"`
+		Tools            json.RawMessage  `json:"tools"`
+		ToolChoice       json.RawMessage  `json:"tool_choice"`
+		ResponseFormat   json.RawMessage  `json:"response_format"`
+		ReasoningEffort  string           `json:"reasoning_effort"`
+		// MaxCompletionTokens is OpenAI's newer name for max_tokens, which
+		// o-series models require. It wins when both are sent.
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+		// Thinking is the gateway's Anthropic-style extended-thinking
+		// request, {"type":"enabled","budget_tokens":N}.
+		Thinking json.RawMessage `json:"thinking"`
+
+		// Standard OpenAI fields the gateway accepts, so strict validation
+		// does not reject requests from the official SDKs, but does not
+		// forward to providers.
+		User              string             `json:"user"`
+		ParallelToolCalls *bool              `json:"parallel_tool_calls"`
+		LogitBias         map[string]float64 `json:"logit_bias"`
+		Metadata          map[string]string  `json:"metadata"`
+		Store             *bool              `json:"store"`
+		ServiceTier       string             `json:"service_tier"`
+		PromptCacheKey    string             `json:"prompt_cache_key"`
+		SafetyIdentifier  string             `json:"safety_identifier"`
+		Modalities        []string           `json:"modalities"`
+		Audio             json.RawMessage    `json:"audio"`
+		Prediction        json.RawMessage    `json:"prediction"`
+		Verbosity         string             `json:"verbosity"`
+		WebSearchOptions  json.RawMessage    `json:"web_search_options"`
+	}
+
+	// streamOptions is the "stream_options" field of a chat request.
+	streamOptions struct {
+		// IncludeUsage asks for a final chunk carrying usage and no choices.
+		IncludeUsage bool `json:"include_usage"`
+		// IncludeObfuscation is accepted for OpenAI compatibility and ignored.
+		IncludeObfuscation *bool `json:"include_obfuscation"`
 	}
 
 	outboundUsage struct {
@@ -486,13 +1034,17 @@ type (
 	}
 
 	outboundMessage struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
+		Role      string         `json:"role"`
+		Content   string         `json:"content"`
+		ToolCalls []wireToolCall `json:"tool_calls,omitempty"`
+		// ReasoningContent carries the model's extended-thinking text.
+		ReasoningContent string `json:"reasoning_content,omitempty"`
 	}
 
 	outboundChoice struct {
 		Index        int             `json:"index"`
 		Message      outboundMessage `json:"message"`
+		LogProbs     json.RawMessage `json:"logprobs,omitempty"`
 		FinishReason string          `json:"finish_reason"`
 	}
 
@@ -506,13 +1058,142 @@ type (
 	}
 )
 
+// stopSequences is the "stop" field of a chat request. Like "input" on the
+// embeddings route, OpenAI accepts either a bare string or an array of strings.
+type stopSequences []string
+
+// UnmarshalJSON normalises a string or array of strings into stopSequences.
+func (s *stopSequences) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = nil
+		return nil
+	}
+	var arr []string
+	if err := json.Unmarshal(data, &arr); err == nil {
+		*s = arr
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		if str == "" {
+			*s = nil
+		} else {
+			*s = stopSequences{str}
+		}
+		return nil
+	}
+	return fmt.Errorf("'stop' must be a string or array of strings")
+}
+
+// validateMessages rejects a chat request with no messages, or whose
+// messages are all blank: no non-whitespace text, parts or tool calls.
+func validateMessages(msgs []inboundMessage) error {
+	if len(msgs) == 0 {
+		return fmt.Errorf("'messages' must contain at least one message")
+	}
+	for _, m := range msgs {
+		if strings.TrimSpace(m.Content.Text) != "" || len(m.Content.Parts) > 0 || len(m.ToolCalls) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("'messages' must contain at least one message with content")
+}
+
+// temperature returns the requested temperature, or 0 when it was omitted.
+func (r *inboundRequest) temperature() float64 {
+	if r.Temperature == nil {
+		return 0
+	}
+	return *r.Temperature
+}
+
+// maxTokens returns the completion-token cap, preferring
+// max_completion_tokens over the legacy max_tokens.
+func (r *inboundRequest) maxTokens() int {
+	if r.MaxCompletionTokens > 0 {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// includeUsage reports whether the client asked for a trailing usage chunk
+// on a streaming response.
+func (r *inboundRequest) includeUsage() bool {
+	return r.StreamOptions != nil && r.StreamOptions.IncludeUsage
+}
+
+// messageContent is the "content" field of a chat message: either a plain
+// string or an array of typed parts ("text", "image_url").
+type messageContent struct {
+	Text  string
+	Parts []providers.ContentPart // nil for a plain string
+}
+
+// inboundContentPart is one element of a content array.
+type inboundContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL    string `json:"url"`
+		Detail string `json:"detail"`
+	} `json:"image_url"`
+}
+
+// UnmarshalJSON accepts a string or an array of content parts. Text parts are
+// also joined into Text so text-only code paths see the whole prompt.
+func (c *messageContent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*c = messageContent{}
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*c = messageContent{Text: str}
+		return nil
+	}
+	var raw []inboundContentPart
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("'content' must be a string or array of content parts")
+	}
+	parts := make([]providers.ContentPart, 0, len(raw))
+	var sb strings.Builder
+	for _, p := range raw {
+		switch p.Type {
+		case providers.ContentPartText:
+			parts = append(parts, providers.ContentPart{Type: p.Type, Text: p.Text})
+			sb.WriteString(p.Text)
+		case providers.ContentPartImageURL:
+			if p.ImageURL == nil || p.ImageURL.URL == "" {
+				return fmt.Errorf("'image_url' content part requires a url")
+			}
+			if strings.HasPrefix(p.ImageURL.URL, "data:") {
+				if _, _, ok := providers.ParseDataURL(p.ImageURL.URL); !ok {
+					return fmt.Errorf("'image_url' data URLs must be base64-encoded")
+				}
+			}
+			parts = append(parts, providers.ContentPart{
+				Type:     p.Type,
+				ImageURL: p.ImageURL.URL,
+				Detail:   p.ImageURL.Detail,
+			})
+		default:
+			return fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+	}
+	*c = messageContent{Text: sb.String(), Parts: parts}
+	return nil
+}
+
 // dispatchChat is the core handler for /v1/chat/completions and /v1/completions.
 func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 	start := time.Now()
 	path := string(ctx.Path())
 	route := "chat_completions"
-	if path == "/v1/completions" {
+	switch path {
+	case "/v1/completions":
 		route = "completions"
+	case "/v1/responses":
+		route = "responses"
 	}
 	reqBytes := len(ctx.PostBody())
 	servedProvider := "unknown"
@@ -544,14 +1225,22 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 		g.metrics.AddTokens(servedProvider, route, inputTokens, outputTokens, cached)
 	}()
 
+	tctx, span := g.startRequestSpan(ctx, route)
+	defer func() {
+		if !streaming {
+			endRequestSpan(span, ctx.Response.StatusCode(), servedProvider, cacheLabel)
+		}
+	}()
+
 	reqID, _ := ctx.UserValue("request_id").(string)
+	caller := callerID(ctx)
 	clientKey, clientKeyID := g.extractClientAPIKey(ctx)
+	ev := requestWebhookEvent(ctx)
 
 	// 1. Parse request body.
 	var req inboundRequest
-	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
-		apierr.Write(ctx, fasthttp.StatusBadRequest,
-			fmt.Sprintf("invalid JSON: %s", err.Error()),
+	if err := g.decodeRequest(ctx.PostBody(), &req); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
 			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
 		return
 	}
@@ -562,13 +1251,66 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
 		return
 	}
+	if err := validateMessages(req.Messages); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	// Tools and response_format are forwarded verbatim to OpenAI-style
+	// providers, but validate them once here so every provider sees a
+	// well-formed definition.
+	if _, err := providers.ParseFunctionTools(nullToNil(req.Tools)); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	if _, _, err := providers.ParseToolChoice(nullToNil(req.ToolChoice)); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	if _, _, err := providers.ParseResponseFormat(nullToNil(req.ResponseFormat)); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	if req.TopLogProbs != nil {
+		if *req.TopLogProbs < 0 || *req.TopLogProbs > maxTopLogProbs {
+			apierr.Write(ctx, fasthttp.StatusBadRequest,
+				fmt.Sprintf("'top_logprobs' must be between 0 and %d", maxTopLogProbs),
+				apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+			return
+		}
+		if req.LogProbs == nil || !*req.LogProbs {
+			apierr.Write(ctx, fasthttp.StatusBadRequest,
+				"'top_logprobs' requires 'logprobs' to be true",
+				apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+			return
+		}
+	}
+	if !providers.ValidReasoningEffort(req.ReasoningEffort) {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			fmt.Sprintf("invalid reasoning_effort %q", req.ReasoningEffort),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	thinkingBudget, err := providers.ParseThinking(nullToNil(req.Thinking))
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
 
 	// 2. Route to provider based on model name.
 	providerName := resolveProvider(req.Model)
 	servedProvider = providerName
+	ev.route(providerName, providerName, req.Model)
+	span.SetAttributes(tracing.String(attrModel, req.Model))
 
 	g.log.InfoContext(ctx, "request",
 		slog.String("request_id", reqID),
+		slog.String("caller", caller),
 		slog.String("model", req.Model),
 		slog.String("provider", providerName),
 		slog.Bool("stream", req.Stream),
@@ -581,9 +1323,24 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	// 2b. Virtual key scope: model allow-list, per-key RPM and token budget.
+	vk := requestVirtualKey(ctx)
+	if !g.enforceVirtualKey(ctx, vk, req.Model) {
+		return
+	}
+	workspace, err := requestWorkspace(ctx)
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
 	// 3. Rate limit check (RPM).
 	if g.rpmLimiter != nil {
+		_, rlSpan := g.startSpan(tctx, "ratelimit.rpm")
 		allowed, err := g.rpmLimiter.Allow(ctx)
+		rlSpan.SetAttributes(tracing.String(attrRateResult, rateLimitResult(allowed, err)))
+		rlSpan.End()
 		if err == nil && !allowed {
 			if g.metrics != nil {
 				g.metrics.RecordRateLimit("blocked")
@@ -592,7 +1349,7 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 				slog.String("request_id", reqID),
 				slog.String("provider", providerName),
 			)
-			apierr.WriteRateLimit(ctx)
+			apierr.WriteRateLimit(ctx, 0)
 			return
 		}
 		if g.metrics != nil {
@@ -607,30 +1364,92 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 	// 4. Build the normalized ProxyRequest.
 	msgs := make([]providers.Message, len(req.Messages))
 	for i, m := range req.Messages {
-		msgs[i] = providers.Message{Role: m.Role, Content: m.Content}
+		msgs[i] = providers.Message{
+			Role:       m.Role,
+			Content:    m.Content.Text,
+			Parts:      m.Content.Parts,
+			ToolCalls:  fromWireToolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
+		}
 	}
 
 	proxyReq := &providers.ProxyRequest{
-		Model:       req.Model,
-		Messages:    msgs,
-		Stream:      req.Stream,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		RequestID:   reqID,
-		APIKey:      clientKey,
-		APIKeyID:    clientKeyID,
-	}
-
-	// 5. Cache lookup — non-streaming only; skip excluded models.
-	cacheEligible := !req.Stream && g.cache != nil && (g.cacheExclusions == nil || !g.cacheExclusions.Matches(req.Model))
-	if g.metrics != nil && !cacheEligible {
+		Model:            req.Model,
+		Messages:         msgs,
+		Stream:           req.Stream,
+		Temperature:      req.temperature(),
+		TemperatureSet:   req.Temperature != nil,
+		MaxTokens:        req.maxTokens(),
+		N:                req.N,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		LogProbs:         req.LogProbs,
+		TopLogProbs:      req.TopLogProbs,
+		Tools:            nullToNil(req.Tools),
+		ToolChoice:       nullToNil(req.ToolChoice),
+		ResponseFormat:   nullToNil(req.ResponseFormat),
+		ReasoningEffort:  req.ReasoningEffort,
+		ThinkingBudget:   thinkingBudget,
+		WorkspaceID:      workspace,
+		RequestID:        reqID,
+		APIKey:           clientKey,
+		APIKeyID:         clientKeyID,
+	}
+
+	// 4a. Operator-configured rewrites, before anything reads the request.
+	// The key's own system prompt goes first so it wins over a global one.
+	if vk != nil {
+		proxyReq, _ = DefaultSystemPrompt{Prompt: vk.SystemPrompt}.TransformRequest(tctx, proxyReq)
+	}
+	proxyReq, err = g.transformRequest(tctx, proxyReq)
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	// 4b. Strip or refuse sampling parameters the model rejects.
+	if !g.checkSamplingParams(ctx, proxyReq) {
+		return
+	}
+
+	// 4c. Refuse prompts that cannot fit the model's context window.
+	if !g.checkContextWindow(ctx, proxyReq) {
+		return
+	}
+
+	// 4d. Screen the prompt before it is cached or reaches a paid provider.
+	if !g.moderate(ctx, tctx, proxyReq) {
+		return
+	}
+
+	// 5. Cache lookup — skip excluded models. Only non-streaming responses are
+	// written back here (cacheEligible), but a stream:true request is served a
+	// cached response replayed as SSE.
+	cacheable := g.cache != nil && (g.cacheExclusions == nil || !g.cacheExclusions.Matches(req.Model))
+	cacheEligible := !req.Stream && cacheable
+	if g.metrics != nil && !cacheable {
 		g.metrics.CacheGetBypass()
 	}
-	if cacheEligible {
-		cacheKey := buildCacheKey(proxyReq)
-		if cachedBody, ok := g.cache.Get(ctx, cacheKey); ok {
+	cacheKey := ""
+	similar, _ := g.cache.(cache.SimilarityCache)
+	if cacheable {
+		cacheKey = buildCacheKey(g.keyNamespace, proxyReq)
+		cctx, cacheSpan := g.startSpan(tctx, "cache.get")
+		cachedBody, remaining, ok := g.cache.GetWithTTL(cctx, cacheKey)
+		if !ok && similar != nil && !req.Stream {
+			// Semantic mode: fall back to the closest paraphrase of this prompt.
+			cachedBody, ok = similar.GetSimilar(cctx, semanticScope(proxyReq), semanticPrompt(proxyReq))
+		}
+		cacheSpan.SetAttributes(tracing.Bool("cache.hit", ok))
+		cacheSpan.End()
+		if ok {
 			cacheLabel = "hit"
 			cached = true
+			g.cacheHits.Add(1)
 			respBytes = len(cachedBody)
 			if g.metrics != nil {
 				g.metrics.CacheGetHit()
@@ -639,10 +1458,31 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 				slog.String("request_id", reqID),
 				slog.String("model", req.Model),
 			)
-			ctx.Response.Header.Set("X-Cache", xCacheHIT)
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(fasthttp.StatusOK)
-			ctx.SetBody(cachedBody)
+			ctx.Response.Header.Set("X-Cache", xCacheHitValue(remaining))
+			setServedBy(ctx, providerName, req.Model)
+
+			// Negatively cached provider error — replay it verbatim.
+			if status, errBody, ok := decodeNegativeEntry(cachedBody); ok {
+				respBytes = len(errBody)
+				ctx.SetContentType("application/json")
+				ctx.SetStatusCode(status)
+				ctx.SetBody(errBody)
+				g.logRequest(ev, reqID, caller, workspace, providerName, providerName, req.Model,
+					0, 0, 0, time.Since(start), status, true)
+				return
+			}
+
+			if req.Stream {
+				if err := writeCachedSSE(ctx, cachedBody, g.replayChunkSize, req.includeUsage()); err != nil {
+					apierr.Write(ctx, fasthttp.StatusInternalServerError,
+						"failed to replay cached response", apierr.TypeServerError, apierr.CodeInternalError)
+					return
+				}
+			} else {
+				ctx.SetContentType("application/json")
+				ctx.SetStatusCode(fasthttp.StatusOK)
+				ctx.SetBody(cachedBody)
+			}
 
 			// Best-effort token extraction from cached payload.
 			var cu struct {
@@ -655,73 +1495,232 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 			if err := json.Unmarshal(cachedBody, &cu); err == nil {
 				inputTokens = cu.Usage.PromptTokens
 				outputTokens = cu.Usage.CompletionTokens
+				if cu.Model != "" {
+					ctx.Response.Header.Set("X-Model", cu.Model)
+				}
 			}
 
-			g.logRequest(reqID, providerName, req.Model,
-				inputTokens, outputTokens, time.Since(start), fasthttp.StatusOK, true)
+			g.logRequest(ev, reqID, caller, workspace, providerName, providerName, req.Model,
+				0, inputTokens, outputTokens, time.Since(start), fasthttp.StatusOK, true)
 			return
 		}
 		cacheLabel = "miss"
+		g.cacheMisses.Add(1)
 		if g.metrics != nil {
 			g.metrics.CacheGetMiss()
 		}
 	}
 
-	// 6. Call provider with automatic failover.
-	provCtx, cancel := context.WithTimeout(ctx, g.providerTimeout)
-	defer cancel()
+	// 6. Token budget check (TPM). Done after the cache lookup so cache hits
+	// never consume budget; the estimate is reconciled once usage is known.
+	var tpmRes ratelimit.Reservation
+	tpmLimit := g.tpmLimit
+	if vk != nil && vk.TPM > 0 {
+		tpmLimit = vk.TPM
+	}
+	if g.tpmLimiter != nil {
+		tpmEstimate := estimateRequestTokens(proxyReq)
+		_, rlSpan := g.startSpan(tctx, "ratelimit.tpm", tracing.Int("gateway.estimated_tokens", tpmEstimate))
+		res, allowed, err := g.tpmLimiter.Reserve(ctx, tpmLimitKey(proxyReq, caller), tpmEstimate, tpmLimit)
+		tpmRes = res
+		rlSpan.SetAttributes(tracing.String(attrRateResult, rateLimitResult(allowed, err)))
+		rlSpan.End()
+		if err == nil && !allowed {
+			if g.metrics != nil {
+				g.metrics.RecordTPM("blocked")
+			}
+			g.log.WarnContext(ctx, "tpm_limit_exceeded",
+				slog.String("request_id", reqID),
+				slog.String("provider", providerName),
+				slog.Int("estimated_tokens", tpmEstimate),
+			)
+			apierr.Write(ctx, fasthttp.StatusTooManyRequests,
+				fmt.Sprintf("tokens per minute limit of %d exceeded", tpmLimit),
+				apierr.TypeRateLimitError, apierr.CodeRateLimitExceeded)
+			ctx.Response.Header.Set("Retry-After", "60")
+			return
+		}
+		if g.metrics != nil {
+			if err != nil {
+				g.metrics.RecordTPM("error")
+			} else {
+				g.metrics.RecordTPM("allowed")
+				g.metrics.AddTPMTokens("estimated", tpmEstimate)
+			}
+		}
+	}
 
-	resp, usedProvider, err := g.requestWithFailover(provCtx, proxyReq, providerName, route)
+	// 6b. Concurrency limit. Taken after the cache lookup and budget checks
+	// so only requests that will reach a provider hold a slot. A stream keeps
+	// its slot until it is drained.
+	releaseSlot, err := g.acquireConcurrency(ctx, providerName)
 	if err != nil {
+		g.reconcileTPM(tpmRes, 0)
+		g.log.WarnContext(ctx, "concurrency_limit_exceeded",
+			slog.String("request_id", reqID),
+			slog.String("provider", providerName),
+		)
+		apierr.WriteOverloaded(ctx, g.queueTimeout)
+		return
+	}
+	defer func() {
+		if !streaming {
+			releaseSlot()
+		}
+	}()
+
+	// 7. Call provider with automatic failover.
+	//
+	// A stream outlives this handler (fasthttp runs the body writer after we
+	// return), so its context hangs off baseCtx and writeSSE owns the cancel:
+	// it fires as soon as a write to the client fails. Non-streaming calls are
+	// cancelled when the handler returns and, unless coalesced, as soon as
+	// watchDisconnect sees the client hang up. A coalesced call may be serving
+	// other requests and its response is cached, so it runs to completion.
+	provParent := tctx
+	if req.Stream {
+		provParent = tracing.ContextWithSpan(g.baseCtx, span)
+	}
+	provCtx, cancel := context.WithTimeout(provParent, g.providerTimeout)
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
+
+	// Cache-eligible requests are coalesced: concurrent misses for the same key
+	// share one upstream call instead of stampeding the provider. Streams are
+	// never coalesced.
+	var (
+		resp         *providers.ProxyResponse
+		usedProvider string
+		tried        []string // providers attempted, in order
+		shared       bool     // served by another request's upstream call
+	)
+	if cacheEligible {
+		var v any
+		shared = true
+		v, err, _ = g.flight.Do(cacheKey, func() (any, error) {
+			shared = false
+			r, name, attempted, ferr := g.requestWithFailover(provCtx, proxyReq, providerName, route)
+			if ferr == nil {
+				r, ferr = g.transformResponse(provCtx, proxyReq, r)
+			}
+			return flightResult{resp: r, provider: name, tried: attempted}, ferr
+		})
+		fr, _ := v.(flightResult)
+		resp, usedProvider, tried = fr.resp, fr.provider, fr.tried
+	} else {
+		stopWatch := func() {}
+		if !req.Stream {
+			stopWatch = watchDisconnect(ctx, cancel)
+		}
+		resp, usedProvider, tried, err = g.requestWithFailover(provCtx, proxyReq, providerName, route)
+		if err == nil {
+			resp, err = g.transformResponse(provCtx, proxyReq, resp)
+		}
+		stopWatch()
+	}
+	if err != nil {
+		g.reconcileTPM(tpmRes, 0)
 		g.log.ErrorContext(ctx, "provider_error",
 			slog.String("request_id", reqID),
 			slog.String("primary_provider", providerName),
 			slog.String("error", err.Error()),
 			slog.Duration("elapsed", time.Since(start)),
 		)
-		handleProviderError(ctx, err)
-		g.logRequest(reqID, providerName, req.Model,
-			0, 0, time.Since(start), fasthttp.StatusBadGateway, false)
+		g.handleProviderError(ctx, err)
+		if cacheEligible && !shared && g.cacheErrors && isNegativelyCacheable(err) {
+			entry := encodeNegativeEntry(ctx.Response.StatusCode(), ctx.Response.Body())
+			if err := g.cache.Set(ctx, cacheKey, entry, g.errorCacheTTL); err != nil {
+				if g.metrics != nil {
+					g.metrics.CacheSetError()
+				}
+			} else if g.metrics != nil {
+				g.metrics.CacheSetOK()
+			}
+		}
+		g.logRequest(ev, reqID, caller, workspace, providerName, providerName, req.Model,
+			len(tried), 0, 0, time.Since(start), ctx.Response.StatusCode(), false)
 		return
 	}
 	servedProvider = usedProvider
+	g.copyUpstreamHeaders(ctx, resp)
+	servedModel := resp.Model
+	if servedModel == "" {
+		servedModel = req.Model
+	}
+	setServedBy(ctx, usedProvider, servedModel)
+
+	// Mirror a sample of served traffic to the shadow provider. Coalesced
+	// followers skip it; the request that made the upstream call covers them.
+	if !shared {
+		g.maybeShadow(proxyReq, usedProvider)
+	}
 
-	// 7a. Streaming — SSE pass-through. Responses are never cached for streams.
+	// 8a. Streaming — SSE pass-through. With CacheStreams the reconstructed
+	// response is cached once the stream completes; nothing is added to the
+	// live path beyond accumulating the text.
 	if req.Stream && resp.Stream != nil {
 		streaming = true
+		if ev != nil {
+			ev.streaming = true
+		}
 		capturedStart := start
 		capturedReqBytes := reqBytes
 		capturedRoute := route
 		capturedProvider := usedProvider
-		writeSSE(ctx, resp, func(outputTokens int) {
-			g.logRequest(reqID, usedProvider, resp.Model,
-				0, outputTokens, time.Since(capturedStart), fasthttp.StatusOK, false)
+		cacheStream := cacheable && g.cacheStreams
+		var usageFrame func(res streamResult) outboundUsage
+		if req.includeUsage() {
+			usageFrame = func(res streamResult) outboundUsage { return streamUsage(proxyReq, res) }
+		}
+		writeSSE(ctx, resp, cancel, g.streamKeepAlive, usageFrame, func(res streamResult) {
+			releaseSlot()
+			// Prefer the provider-reported usage; fall back to estimates.
+			inputTokens, outputTokens := 0, res.outputTokens
+			tpmInput := estimateInputTokens(proxyReq)
+			if res.usage != nil {
+				inputTokens = res.usage.InputTokens
+				tpmInput = inputTokens
+			}
+			if cacheStream && res.complete && res.finishReason != "error" {
+				g.cacheStreamResult(tracing.ContextWithSpan(g.baseCtx, span), proxyReq, resp, res)
+			}
+			g.reconcileTPM(tpmRes, tpmInput+outputTokens)
+			g.chargeVirtualKey(vk, tpmInput+outputTokens)
+			// The 200 header is long gone when the upstream fails mid-stream;
+			// the client got an error frame instead, so record a 502.
+			status := fasthttp.StatusOK
+			if res.finishReason == "error" {
+				status = fasthttp.StatusBadGateway
+			}
+			g.logRequest(ev, reqID, caller, workspace, providerName, usedProvider, resp.Model,
+				len(tried), inputTokens, outputTokens, time.Since(capturedStart), status, false)
+			if ev != nil {
+				g.webhook.Notify(ev.entry)
+			}
 			if g.metrics != nil {
 				// End-to-end duration is measured until stream drain.
 				dur := time.Since(capturedStart)
-				g.metrics.ObserveHTTP(capturedRoute, fasthttp.StatusOK, dur, capturedReqBytes, -1)
-				g.metrics.RecordRequest(capturedProvider, fasthttp.StatusOK, dur.Milliseconds())
+				g.metrics.ObserveHTTP(capturedRoute, status, dur, capturedReqBytes, -1)
+				g.metrics.RecordRequest(capturedProvider, status, dur.Milliseconds())
 				g.metrics.ObserveGatewayRequest(capturedProvider, capturedRoute, "bypass", dur)
-				g.metrics.AddTokens(capturedProvider, capturedRoute, 0, outputTokens, false)
+				g.metrics.AddTokens(capturedProvider, capturedRoute, inputTokens, outputTokens, false)
 				g.metrics.DecInFlight()
 			}
+			endRequestSpan(span, status, capturedProvider, "bypass")
 		})
 		return
 	}
 
-	// 7b. Non-streaming — build an OpenAI-compatible response envelope.
+	// 8b. Non-streaming — build an OpenAI-compatible response envelope.
 	out := outboundResponse{
 		ID:      resp.ID,
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
 		Model:   resp.Model,
-		Choices: []outboundChoice{
-			{
-				Index:        0,
-				Message:      outboundMessage{Role: "assistant", Content: resp.Content},
-				FinishReason: "stop",
-			},
-		},
+		Choices: buildOutboundChoices(resp),
 		Usage: outboundUsage{
 			PromptTokens:     resp.Usage.InputTokens,
 			CompletionTokens: resp.Usage.OutputTokens,
@@ -736,23 +1735,22 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	// 8. Populate cache for future identical requests.
-	if cacheEligible {
-		cacheKey := buildCacheKey(proxyReq)
-		if err := g.cache.Set(ctx, cacheKey, body, g.cacheTTL); err != nil {
-			if g.metrics != nil {
-				g.metrics.CacheSetError()
-			}
-		} else {
-			if g.metrics != nil {
-				g.metrics.CacheSetOK()
-			}
-		}
+	// 9. Populate cache for future identical requests. A coalesced follower
+	// leaves this to the request that made the upstream call.
+	if cacheEligible && !shared {
+		g.storeCached(tctx, proxyReq, cacheKey, body)
 	}
 
-	// 9. Emit request log entry asynchronously.
-	g.logRequest(reqID, usedProvider, resp.Model,
-		resp.Usage.InputTokens, resp.Usage.OutputTokens,
+	// 10. Emit request log entry asynchronously. Usage is charged once, to the
+	// request that made the upstream call; coalesced followers release theirs.
+	consumed := resp.Usage.InputTokens + resp.Usage.OutputTokens
+	if shared {
+		consumed = 0
+	}
+	g.reconcileTPM(tpmRes, consumed)
+	g.chargeVirtualKey(vk, consumed)
+	g.logRequest(ev, reqID, caller, workspace, providerName, usedProvider, resp.Model,
+		len(tried), resp.Usage.InputTokens, resp.Usage.OutputTokens,
 		time.Since(start), fasthttp.StatusOK, false)
 	inputTokens = resp.Usage.InputTokens
 	outputTokens = resp.Usage.OutputTokens
@@ -772,103 +1770,458 @@ func (g *Gateway) dispatchChat(ctx *fasthttp.RequestCtx) {
 	)
 
 	ctx.Response.Header.Set("X-Cache", xCacheMISS)
+	if cacheEligible {
+		// How long this response stays cached, in seconds.
+		ctx.Response.Header.Set("X-Cache-TTL", strconv.Itoa(int(g.cacheTTLFor(req.Model).Seconds())))
+	}
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetContentType("application/json")
 	ctx.SetBody(body)
 	respBytes = len(body)
 }
 
-// logRequest enqueues a RequestLog entry to the async logger. Never blocks.
+// setServedBy sets X-Provider and X-Model to the provider and model that
+// answered, so clients can tell when failover served them from a fallback.
+func setServedBy(ctx *fasthttp.RequestCtx, provider, model string) {
+	ctx.Response.Header.Set("X-Provider", provider)
+	ctx.Response.Header.Set("X-Model", model)
+}
+
+// xCacheHitValue is the X-Cache header of a hit: "HIT", followed by
+// "; ttl=<seconds>" when the entry's remaining lifetime is known.
+func xCacheHitValue(remaining time.Duration) string {
+	if remaining <= 0 {
+		return xCacheHIT
+	}
+	secs := (remaining + time.Second - 1) / time.Second // round up: 0.4s left is still cached
+	return xCacheHIT + "; ttl=" + strconv.FormatInt(int64(secs), 10)
+}
+
+// storeCached writes a response body to the cache under key, indexing the
+// prompt as well when the cache supports similarity lookups.
+func (g *Gateway) storeCached(ctx context.Context, req *providers.ProxyRequest, key string, body []byte) {
+	ctx, span := g.startSpan(ctx, "cache.set")
+	defer span.End()
+	ttl := g.cacheTTLFor(req.Model)
+	var err error
+	if similar, ok := g.cache.(cache.SimilarityCache); ok {
+		err = similar.SetSimilar(ctx, semanticScope(req), semanticPrompt(req), key, body, ttl)
+	} else {
+		err = g.cache.Set(ctx, key, body, ttl)
+	}
+	span.RecordError(err)
+	if g.metrics == nil {
+		return
+	}
+	if err != nil {
+		g.metrics.CacheSetError()
+	} else {
+		g.metrics.CacheSetOK()
+	}
+}
+
+// cacheStreamResult caches the non-streaming envelope reconstructed from a
+// completed stream under the key a non-streaming request would use.
+// Token counts come from the provider's usage report when the stream carried
+// one, and are the usual ≈ chars/4 estimates otherwise. The request context
+// is gone by the time the stream drains, so ctx hangs off the base context.
+func (g *Gateway) cacheStreamResult(ctx context.Context, req *providers.ProxyRequest, resp *providers.ProxyResponse, res streamResult) {
+	id := resp.ID
+	if id == "" {
+		id = "chatcmpl-stream"
+	}
+	finish := res.finishReason
+	if finish == "" {
+		finish = "stop"
+	}
+	inputTokens := estimateInputTokens(req)
+	if res.usage != nil {
+		inputTokens = res.usage.InputTokens
+	}
+	out := outboundResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []outboundChoice{{
+			Index:        0,
+			Message:      outboundMessage{Role: "assistant", Content: res.content},
+			FinishReason: finish,
+		}},
+		Usage: outboundUsage{
+			PromptTokens:     inputTokens,
+			CompletionTokens: res.outputTokens,
+			TotalTokens:      inputTokens + res.outputTokens,
+		},
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		return
+	}
+	g.storeCached(ctx, req, buildCacheKey(g.keyNamespace, req), body)
+}
+
+// flightResult is the value shared between coalesced callers of
+// requestWithFailover.
+type flightResult struct {
+	resp     *providers.ProxyResponse
+	provider string
+	tried    []string
+}
+
+// buildOutboundChoices converts the provider's choices into the OpenAI
+// envelope. Providers that only fill Content produce a single choice at index 0.
+func buildOutboundChoices(resp *providers.ProxyResponse) []outboundChoice {
+	if len(resp.Choices) == 0 {
+		finish := outboundFinishReason(resp.FinishReason, len(resp.ToolCalls) > 0)
+		return []outboundChoice{
+			{
+				Index: 0,
+				Message: outboundMessage{
+					Role:             "assistant",
+					Content:          resp.Content,
+					ToolCalls:        toWireToolCalls(resp.ToolCalls),
+					ReasoningContent: resp.Reasoning,
+				},
+				LogProbs:     resp.LogProbs,
+				FinishReason: finish,
+			},
+		}
+	}
+
+	out := make([]outboundChoice, len(resp.Choices))
+	for i, c := range resp.Choices {
+		finish := outboundFinishReason(c.FinishReason, len(c.ToolCalls) > 0)
+		out[i] = outboundChoice{
+			Index: c.Index,
+			Message: outboundMessage{
+				Role:      "assistant",
+				Content:   c.Content,
+				ToolCalls: toWireToolCalls(c.ToolCalls),
+			},
+			LogProbs:     c.LogProbs,
+			FinishReason: finish,
+		}
+	}
+	return out
+}
+
+// outboundFinishReason maps a provider's finish reason to its OpenAI value.
+// A choice that requested tool calls reports "tool_calls" even when the
+// provider said it simply stopped (Gemini does), and a missing reason
+// defaults to "stop".
+func outboundFinishReason(reason string, hasToolCalls bool) string {
+	finish := providers.NormalizeFinishReason(reason)
+	if hasToolCalls && (finish == "" || finish == providers.FinishStop) {
+		return providers.FinishToolCalls
+	}
+	if finish == "" {
+		return providers.FinishStop
+	}
+	return finish
+}
+
+// fromWireToolCalls converts client-sent tool calls to the provider form.
+func fromWireToolCalls(calls []wireToolCall) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = providers.ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments}
+	}
+	return out
+}
+
+// toWireToolCalls converts provider tool calls to the OpenAI wire format.
+func toWireToolCalls(calls []providers.ToolCall) []wireToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]wireToolCall, len(calls))
+	for i, c := range calls {
+		out[i].ID = c.ID
+		out[i].Type = "function"
+		out[i].Function.Name = c.Name
+		out[i].Function.Arguments = c.Arguments
+	}
+	return out
+}
+
+// nullToNil drops an explicit JSON null so absent and null fields are
+// treated alike downstream.
+func nullToNil(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// tpmLimitKey identifies whose token budget a request draws from: the
+// authenticated gateway caller, then the workspace, then the client API key,
+// otherwise the shared global budget (""). The caller comes first so a
+// client cannot escape its budget by choosing an X-Workspace-ID.
+func tpmLimitKey(req *providers.ProxyRequest, caller string) string {
+	if caller != "" {
+		return "caller:" + caller
+	}
+	if req.WorkspaceID != "" {
+		return "ws:" + req.WorkspaceID
+	}
+	if req.APIKeyID != "" {
+		return "key:" + req.APIKeyID
+	}
+	return ""
+}
+
+// estimateInputTokens approximates the prompt size at ≈ 4 characters per
+// token, the same heuristic writeSSE uses for streamed output.
+func estimateInputTokens(req *providers.ProxyRequest) int {
+	chars := 0
+	for _, m := range req.Messages {
+		chars += len(m.Content)
+	}
+	return (chars + 3) / 4
+}
+
+// estimateRequestTokens is the TPM reservation for req: the estimated prompt
+// plus the completion budget the client asked for (max_tokens × n).
+func estimateRequestTokens(req *providers.ProxyRequest) int {
+	return estimateInputTokens(req) + req.MaxTokens*max(req.N, 1)
+}
+
+// reconcileTPM settles a TPM reservation with the actual usage. It runs off
+// the request path; a failure only leaves the estimate in the window.
+func (g *Gateway) reconcileTPM(res ratelimit.Reservation, actual int) {
+	if g.tpmLimiter == nil || !res.Recorded() {
+		return
+	}
+	if g.metrics != nil {
+		g.metrics.AddTPMTokens("actual", actual)
+	}
+	go func() {
+		_ = g.tpmLimiter.Reconcile(g.baseCtx, res, actual)
+	}()
+}
+
+// logRequest enqueues a RequestLog entry to the async logger and records it
+// as the request's webhook event (see notifyWebhook). Never blocks.
+// primary is the provider the model routed to and provider the one that
+// served the request; attempts counts upstream calls (0 for cache hits).
 func (g *Gateway) logRequest(
-	requestID, provider, model string,
-	inputTokens, outputTokens int,
+	ev *webhookEvent,
+	requestID, caller, workspace, primary, provider, model string,
+	attempts, inputTokens, outputTokens int,
 	latency time.Duration,
 	status int,
 	isCached bool,
 ) {
-	if g.reqLogger == nil {
+	cost := g.pricing.cost(model, inputTokens, outputTokens, isCached)
+	if cost > 0 && g.metrics != nil {
+		g.metrics.AddCost(provider, model, cost)
+	}
+	if g.reqLogger == nil && ev == nil {
 		return
 	}
 
 	reqUUID, _ := uuid.Parse(requestID)
 
-	// Clamp to uint16 max so we don't overflow the field.
-	latencyMs := uint16(latency.Milliseconds())
-	if latency.Milliseconds() > 65535 {
-		latencyMs = 65535
-	}
-
-	g.reqLogger.Log(logger.RequestLog{
-		ID:           reqUUID,
-		Provider:     provider,
-		Model:        model,
-		InputTokens:  uint32(inputTokens),
-		OutputTokens: uint32(outputTokens),
-		LatencyMs:    latencyMs,
-		Status:       uint16(status),
-		Cached:       isCached,
-		CreatedAt:    time.Now(),
-	})
+	entry := logger.RequestLog{
+		ID:              reqUUID,
+		Caller:          caller,
+		Workspace:       workspace,
+		Provider:        provider,
+		PrimaryProvider: primary,
+		Attempts:        uint8(min(attempts, 255)),
+		FailedOver:      provider != primary,
+		Model:           model,
+		InputTokens:     uint32(inputTokens),
+		OutputTokens:    uint32(outputTokens),
+		LatencyMs:       uint32(latency.Milliseconds()),
+		Status:          uint16(status),
+		Cached:          isCached,
+		CostUSD:         cost,
+		CreatedAt:       time.Now(),
+	}
+	if g.reqLogger != nil {
+		g.reqLogger.Log(entry)
+	}
+	if ev != nil {
+		ev.entry = entry
+	}
 }
 
-// buildCacheKey returns a deterministic SHA-256 cache key for the request.
-// The provider name is included to prevent cross-provider key collisions when
-// two providers share a model name.
-func buildCacheKey(req *providers.ProxyRequest) string {
-	type msg struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	}
-	msgs := make([]msg, len(req.Messages))
-	for i, m := range req.Messages {
-		msgs[i] = msg{Role: m.Role, Content: m.Content}
-	}
-	data, _ := json.Marshal(struct {
-		W    string `json:"w"`
-		K    string `json:"k"`
-		P    string `json:"p"`
-		M    string `json:"m"`
-		T    string `json:"t"`
-		MT   int    `json:"mt"`
-		Msgs []msg  `json:"msgs"`
-	}{
-		req.WorkspaceID,
-		req.APIKeyID,
-		resolveProvider(req.Model),
-		req.Model,
-		fmt.Sprintf("%.2f", req.Temperature),
-		req.MaxTokens,
-		msgs,
-	})
-	h := sha256.Sum256(data)
-	return "cache:" + hex.EncodeToString(h[:])
+// semanticScope partitions the semantic cache index: requests only match
+// paraphrases sent with identical parameters (workspace, key, model, sampling).
+// It is the exact cache key computed without the messages; the index is in
+// process, so no namespace is needed.
+func semanticScope(req *providers.ProxyRequest) string {
+	scoped := *req
+	scoped.Messages = nil
+	return buildCacheKey("", &scoped)
+}
+
+// semanticPrompt renders the conversation as the text that is embedded for
+// semantic cache lookups.
+func semanticPrompt(req *providers.ProxyRequest) string {
+	var sb strings.Builder
+	for _, m := range req.Messages {
+		sb.WriteString(m.Role)
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
 }
 
 // handleProviderError maps provider errors to the appropriate HTTP response.
 //
-//	statusCoder (providers that return HTTP codes) → passed through with remapping
+//	providers.StatusCoder (possibly wrapped)       → passed through with remapping
+//	upstream 503 with passthroughUnavailable       → 503 (+ Retry-After if hinted)
 //	context.DeadlineExceeded                       → 504 Gateway Timeout
 //	all other errors                               → 502 Bad Gateway
-func handleProviderError(ctx *fasthttp.RequestCtx, err error) {
-	type statusCoder interface{ HTTPStatus() int }
-
-	if sc, ok := err.(statusCoder); ok {
-		apierr.WriteProviderError(ctx, sc.HTTPStatus(), err.Error())
+func (g *Gateway) handleProviderError(ctx *fasthttp.RequestCtx, err error) {
+	// Failover wraps the last provider error, so unwrap rather than assert.
+	if pe, ok := providers.AsError(err); ok {
+		if g.passthroughUnavailable && pe.StatusCode == fasthttp.StatusServiceUnavailable {
+			apierr.WriteProviderUnavailable(ctx, err.Error(), pe.RetryAfter)
+			return
+		}
+		apierr.WriteProviderError(ctx, pe.StatusCode, err.Error(), pe.Type, pe.Code, pe.RetryAfter)
 		return
 	}
 	if errors.Is(err, context.DeadlineExceeded) {
 		apierr.WriteTimeout(ctx)
 		return
 	}
+	if errors.Is(err, errAllCircuitsOpen) {
+		apierr.WriteProviderUnavailable(ctx, err.Error(), 0)
+		return
+	}
 
 	apierr.Write(ctx, fasthttp.StatusBadGateway,
 		err.Error(), apierr.TypeProviderError, apierr.CodeProviderError)
 }
 
+// writeCachedSSE replays a cached chat completion as a Server-Sent Events
+// stream in the same chunk format writeSSE produces: for each choice a role
+// delta, its content split into deltas of chunkSize characters and a
+// finish_reason chunk, then [DONE]. Returns an error, without touching the
+// response, if body is not a cached completion.
+func writeCachedSSE(ctx *fasthttp.RequestCtx, body []byte, chunkSize int, includeUsage bool) error {
+	var cachedResp outboundResponse
+	if err := json.Unmarshal(body, &cachedResp); err != nil {
+		return fmt.Errorf("decode cached response: %w", err)
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultReplayChunkSize
+	}
+
+	id := cachedResp.ID
+	if id == "" {
+		id = "chatcmpl-stream"
+	}
+	created := time.Now().Unix()
+	event := func(index int, delta map[string]string, finishReason any) []byte {
+		return streamChunk(id, created, cachedResp.Model, index, delta, finishReason)
+	}
+
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		for _, c := range cachedResp.Choices {
+			fmt.Fprintf(w, "data: %s\n\n", event(c.Index, roleDelta, nil))
+			runes := []rune(c.Message.Content)
+			for i := 0; i < len(runes); i += chunkSize {
+				end := min(i+chunkSize, len(runes))
+				fmt.Fprintf(w, "data: %s\n\n", event(c.Index, map[string]string{"content": string(runes[i:end])}, nil))
+				if err := w.Flush(); err != nil {
+					return // client disconnected
+				}
+			}
+			finish := c.FinishReason
+			if finish == "" {
+				finish = providers.FinishStop
+			}
+			fmt.Fprintf(w, "data: %s\n\n", event(c.Index, map[string]string{}, finish))
+		}
+		if includeUsage {
+			fmt.Fprintf(w, "data: %s\n\n", usageChunk(id, created, cachedResp.Usage))
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		w.Flush() //nolint:errcheck
+	})
+	return nil
+}
+
+// roleDelta is the delta of a stream's first chunk. Strict OpenAI clients
+// expect the role there before any content.
+var roleDelta = map[string]string{"role": "assistant"}
+
+// streamChunk renders one chat.completion.chunk event for a single choice.
+// finishReason is nil on every chunk but the last, which carries an empty
+// delta.
+func streamChunk(id string, created int64, model string, index int, delta map[string]string, finishReason any) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]any{
+			{
+				"index":         index,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			},
+		},
+	})
+	return data
+}
+
+// streamResult summarises a finished SSE stream for writeSSE's onComplete.
+type streamResult struct {
+	content      string // concatenated delta text
+	finishReason string // last non-empty finish_reason seen, in OpenAI form
+	outputTokens int    // real output tokens when usage was reported, else ≈ chars/4
+	complete     bool   // false when the client disconnected mid-stream
+	// usage is the provider-reported token usage, nil when the provider
+	// sent none (or the stream ended before it arrived).
+	usage *providers.Usage
+}
+
 // writeSSE streams response chunks from the provider as Server-Sent Events.
-// onComplete is called once the stream drains with an estimated output token
-// count (≈ chars/4), enabling async logging for streaming requests.
-func writeSSE(ctx *fasthttp.RequestCtx, resp *providers.ProxyResponse, onComplete func(outputTokens int)) {
+// onComplete is called exactly once when the stream ends — drained or
+// abandoned by the client — enabling async logging for streaming requests.
+//
+// When usageFrame is non-nil (the client set stream_options.include_usage),
+// its result is sent as a final chunk with empty choices before [DONE].
+//
+// cancel aborts the upstream request. It is called when the stream ends and,
+// crucially, as soon as a write fails because the client disconnected — so the
+// provider stops generating (and billing) tokens nobody will read.
+//
+// The events follow the OpenAI wire format: a first chunk whose delta holds
+// only the role, one content-only delta per provider chunk, and a final chunk
+// with an empty delta carrying the finish_reason, normalised to the OpenAI
+// values ("stop" when the provider reported none).
+//
+// A chunk carrying Err ends the stream with an OpenAI-style error event
+// (data: {"error":{...}}) in place of the finish chunk and [DONE].
+//
+// When keepAlive is positive, a ": keepalive" comment is written each time the
+// provider stays silent for that long. SSE clients ignore comment lines, so
+// the heartbeats only keep idle intermediaries from closing the connection.
+func writeSSE(
+	ctx *fasthttp.RequestCtx,
+	resp *providers.ProxyResponse,
+	cancel context.CancelFunc,
+	keepAlive time.Duration,
+	usageFrame func(res streamResult) outboundUsage,
+	onComplete func(res streamResult),
+) {
 	ctx.SetContentType("text/event-stream")
 	ctx.Response.Header.Set("Cache-Control", "no-cache")
 	ctx.Response.Header.Set("Connection", "keep-alive")
@@ -876,43 +2229,143 @@ func writeSSE(ctx *fasthttp.RequestCtx, resp *providers.ProxyResponse, onComplet
 
 	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
 		defer func() { recover() }() //nolint:errcheck // panic recovery in stream writer
+		defer func() {
+			if cancel != nil {
+				cancel()
+			}
+			// Unblock the provider goroutine if it is mid-send on the channel;
+			// it closes the channel once it observes the cancelled context.
+			go func() {
+				for range resp.Stream { //nolint:revive // drain
+				}
+			}()
+		}()
 
 		var sb strings.Builder
-		for chunk := range resp.Stream {
-			sb.WriteString(chunk.Content)
-
-			delta := map[string]any{
-				"id":      "chatcmpl-stream",
-				"object":  "chat.completion.chunk",
-				"created": time.Now().Unix(),
-				"choices": []map[string]any{
-					{
-						"index": 0,
-						"delta": map[string]string{"content": chunk.Content},
-						"finish_reason": func() any {
-							if chunk.FinishReason != "" {
-								return chunk.FinishReason
-							}
-							return nil
-						}(),
-					},
-				},
+		res := streamResult{}
+		defer func() {
+			res.settle(sb.String())
+			if onComplete != nil {
+				onComplete(res)
 			}
-			data, _ := json.Marshal(delta)
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			w.Flush() //nolint:errcheck
+		}()
+
+		created := time.Now().Unix()
+		event := func(delta map[string]string, finishReason any) {
+			fmt.Fprintf(w, "data: %s\n\n", streamChunk("chatcmpl-stream", created, resp.Model, 0, delta, finishReason))
+		}
+		event(roleDelta, nil)
+		if err := w.Flush(); err != nil {
+			return // client disconnected
 		}
 
-		fmt.Fprint(w, "data: [DONE]\n\n")
-		w.Flush() //nolint:errcheck
+		var heartbeat <-chan time.Time
+		var ticker *time.Ticker
+		if keepAlive > 0 {
+			ticker = time.NewTicker(keepAlive)
+			defer ticker.Stop()
+			heartbeat = ticker.C
+		}
 
-		// Estimate output tokens: ~4 characters per token (GPT-style heuristic).
-		estimated := sb.Len() / 4
-		if estimated == 0 {
-			estimated = 1
+	stream:
+		for {
+			var chunk providers.StreamChunk
+			select {
+			case c, ok := <-resp.Stream:
+				if !ok {
+					break stream
+				}
+				chunk = c
+			case <-heartbeat:
+				fmt.Fprint(w, sseKeepAlive)
+				if err := w.Flush(); err != nil {
+					return // client disconnected
+				}
+				continue
+			}
+			if ticker != nil {
+				ticker.Reset(keepAlive)
+			}
+
+			if chunk.Err != nil {
+				// The provider failed part-way: end with an error event in the
+				// OpenAI format rather than a content delta, and no [DONE].
+				res.finishReason = "error"
+				fmt.Fprintf(w, "data: %s\n\n", apierr.Encode("upstream stream failed: "+chunk.Err.Error(),
+					apierr.TypeProviderError, apierr.CodeProviderError))
+				_ = w.Flush()
+				return
+			}
+			if chunk.Usage != nil {
+				usage := *chunk.Usage
+				res.usage = &usage
+			}
+			if chunk.FinishReason != "" {
+				res.finishReason = providers.NormalizeFinishReason(chunk.FinishReason)
+			}
+			if chunk.Content == "" {
+				continue
+			}
+			sb.WriteString(chunk.Content)
+			event(map[string]string{"content": chunk.Content}, nil)
+			if err := w.Flush(); err != nil {
+				return // client disconnected
+			}
+		}
+
+		if res.finishReason == "" {
+			res.finishReason = providers.FinishStop
 		}
-		if onComplete != nil {
-			onComplete(estimated)
+		event(map[string]string{}, res.finishReason)
+
+		if usageFrame != nil {
+			res.settle(sb.String())
+			fmt.Fprintf(w, "data: %s\n\n", usageChunk("chatcmpl-stream", created, usageFrame(res)))
 		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		res.complete = w.Flush() == nil
+	})
+}
+
+// settle records the streamed text and fixes the output token count:
+// the provider-reported value when present, otherwise ≈ chars/4.
+func (r *streamResult) settle(content string) {
+	r.content = content
+	if r.usage != nil {
+		r.outputTokens = r.usage.OutputTokens
+		return
+	}
+	// Estimate output tokens: ~4 characters per token (GPT-style heuristic).
+	r.outputTokens = len(content) / 4
+	if r.outputTokens == 0 {
+		r.outputTokens = 1
+	}
+}
+
+// streamUsage is the usage reported to a client that asked for it with
+// stream_options.include_usage: the provider's counts when it sent them,
+// otherwise the same estimates used for accounting.
+func streamUsage(req *providers.ProxyRequest, res streamResult) outboundUsage {
+	input := estimateInputTokens(req)
+	if res.usage != nil {
+		input = res.usage.InputTokens
+	}
+	return outboundUsage{
+		PromptTokens:     input,
+		CompletionTokens: res.outputTokens,
+		TotalTokens:      input + res.outputTokens,
+	}
+}
+
+// usageChunk renders the trailing stream_options.include_usage chunk: the
+// OpenAI spec gives it a populated usage object and an empty choices array.
+func usageChunk(id string, created int64, usage outboundUsage) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"choices": []any{},
+		"usage":   usage,
 	})
+	return data
 }
@@ -6,27 +6,43 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+	"github.com/nulpointcorp/llm-gateway/internal/metrics"
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/internal/webhook"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/redis/go-redis/v9"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 // --- helpers ----------------------------------------------------------------
 
-// stubCache is a simple in-memory cache for tests.
+// stubCache is a simple in-memory cache for tests. It records the TTL of
+// every Set.
 type stubCache struct {
 	store map[string][]byte
+	ttls  map[string]time.Duration
 }
 
 func newStubCache() *stubCache {
-	return &stubCache{store: make(map[string][]byte)}
+	return &stubCache{store: make(map[string][]byte), ttls: make(map[string]time.Duration)}
 }
 
 func (c *stubCache) Get(_ context.Context, key string) ([]byte, bool) {
@@ -34,8 +50,14 @@ func (c *stubCache) Get(_ context.Context, key string) ([]byte, bool) {
 	return v, ok
 }
 
-func (c *stubCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+func (c *stubCache) GetWithTTL(_ context.Context, key string) ([]byte, time.Duration, bool) {
+	v, ok := c.store[key]
+	return v, c.ttls[key], ok
+}
+
+func (c *stubCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
 	c.store[key] = value
+	c.ttls[key] = ttl
 	return nil
 }
 
@@ -44,6 +66,15 @@ func (c *stubCache) Delete(_ context.Context, key string) error {
 	return nil
 }
 
+func (c *stubCache) Flush(_ context.Context) error {
+	clear(c.store)
+	return nil
+}
+
+func (c *stubCache) Stats(_ context.Context) (cache.Stats, error) {
+	return cache.Stats{Backend: "stub", Entries: int64(len(c.store))}, nil
+}
+
 // okProvider always returns a successful response.
 func okProvider(name string) *funcProvider {
 	return &funcProvider{
@@ -71,6 +102,8 @@ func serveGateway(t *testing.T, gw *Gateway) (*http.Client, func()) {
 			switch string(ctx.Path()) {
 			case "/v1/chat/completions", "/v1/completions":
 				gw.dispatchChat(ctx)
+			case "/v1/responses":
+				gw.dispatchResponses(ctx)
 			default:
 				ctx.SetStatusCode(404)
 			}
@@ -78,6 +111,8 @@ func serveGateway(t *testing.T, gw *Gateway) (*http.Client, func()) {
 		recovery,
 		requestID,
 		timing,
+		gw.notifyWebhook,
+		gw.authenticate,
 	)
 
 	go func() {
@@ -121,6 +156,11 @@ func readBody(t *testing.T, resp *http.Response) []byte {
 	return data
 }
 
+// isCacheHit reports whether resp was served from the cache.
+func isCacheHit(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("X-Cache"), xCacheHIT)
+}
+
 // --- NewGateway tests -------------------------------------------------------
 
 func TestNewGateway_PanicsOnNilContext(t *testing.T) {
@@ -174,6 +214,11 @@ func TestGateway_Setters(t *testing.T) {
 		t.Error("expected nil rpm limiter")
 	}
 
+	gw.SetTPMLimiter(nil)
+	if gw.tpmLimiter != nil {
+		t.Error("expected nil tpm limiter")
+	}
+
 	gw.SetLogger(nil)
 	if gw.reqLogger != nil {
 		t.Error("expected nil logger")
@@ -242,6 +287,38 @@ func TestDispatchChat_MissingModel(t *testing.T) {
 	}
 }
 
+func TestDispatchChat_EmptyMessages(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"empty array", `{"model":"gpt-4o","messages":[]}`, http.StatusBadRequest},
+		{"missing", `{"model":"gpt-4o"}`, http.StatusBadRequest},
+		{"all whitespace", `{"model":"gpt-4o","messages":[{"role":"system","content":" "},{"role":"user","content":"\n\t"}]}`, http.StatusBadRequest},
+		{"one valid message", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/chat/completions", []byte(tt.body))
+			body := string(readBody(t, resp))
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("status = %d, body = %s; want %d", resp.StatusCode, body, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusBadRequest && !contains(body, "'messages' must contain") {
+				t.Errorf("error should explain the messages requirement, got: %s", body)
+			}
+		})
+	}
+}
+
 func TestDispatchChat_NoProviders(t *testing.T) {
 	gw := NewGateway(context.Background(), map[string]providers.Provider{}, nil)
 
@@ -346,6 +423,43 @@ func TestDispatchChat_ClientAPIKeyIgnoredWhenDisabled(t *testing.T) {
 	}
 }
 
+func TestExtractClientAPIKey_Headers(t *testing.T) {
+	gw := &Gateway{allowClientAPIKeys: true}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"authorization", map[string]string{"Authorization": "Bearer sk-auth"}, "sk-auth"},
+		{"x-api-key", map[string]string{"X-API-Key": "sk-x"}, "sk-x"},
+		{"api-key", map[string]string{"api-key": "sk-azure"}, "sk-azure"},
+		{"authorization wins", map[string]string{
+			"Authorization": "Bearer sk-auth", "X-API-Key": "sk-x", "api-key": "sk-azure",
+		}, "sk-auth"},
+		{"x-api-key over api-key", map[string]string{"X-API-Key": "sk-x", "api-key": "sk-azure"}, "sk-x"},
+		{"non-bearer authorization falls through", map[string]string{
+			"Authorization": "Basic dXNlcjpwYXNz", "api-key": "sk-azure",
+		}, "sk-azure"},
+		{"none", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, id := gw.extractClientAPIKey(authCtx("/v1/chat/completions", tt.headers))
+			if token != tt.want {
+				t.Fatalf("token = %q, want %q", token, tt.want)
+			}
+			wantID := ""
+			if tt.want != "" {
+				wantID = hashKey(tt.want)
+			}
+			if id != wantID {
+				t.Errorf("APIKeyID = %q, want %q", id, wantID)
+			}
+		})
+	}
+}
+
 // Tests that reach provider calls need a real fasthttp server context.
 
 func TestDispatchChat_Success(t *testing.T) {
@@ -386,6 +500,195 @@ func TestDispatchChat_Success(t *testing.T) {
 	}
 }
 
+func TestDispatchChat_MultipleChoices(t *testing.T) {
+	var gotN int
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			gotN = req.N
+			return &providers.ProxyResponse{
+				ID:      "resp-n",
+				Model:   req.Model,
+				Content: "first",
+				Choices: []providers.Choice{
+					{Index: 0, Content: "first", FinishReason: "stop"},
+					{Index: 1, Content: "second", FinishReason: "length"},
+				},
+				Usage: providers.Usage{InputTokens: 3, OutputTokens: 4},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","n":2,"messages":[{"role":"user","content":"hello"}]}`))
+	body := readBody(t, resp)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if gotN != 2 {
+		t.Errorf("expected provider to receive n=2, got %d", gotN)
+	}
+
+	var out outboundResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(out.Choices) != 2 {
+		t.Fatalf("expected 2 choices, got %d", len(out.Choices))
+	}
+	for i, want := range []string{"first", "second"} {
+		if out.Choices[i].Index != i {
+			t.Errorf("choice %d: expected index %d, got %d", i, i, out.Choices[i].Index)
+		}
+		if out.Choices[i].Message.Content != want {
+			t.Errorf("choice %d: expected content %q, got %q", i, want, out.Choices[i].Message.Content)
+		}
+	}
+	if out.Choices[1].FinishReason != "length" {
+		t.Errorf("expected finish_reason=length on choice 1, got %s", out.Choices[1].FinishReason)
+	}
+}
+
+func TestDispatchChat_FinishReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		reason    string
+		toolCalls []providers.ToolCall
+		want      string
+	}{
+		{"openai length", "length", nil, "length"},
+		{"anthropic end_turn", "end_turn", nil, "stop"},
+		{"anthropic max_tokens", "max_tokens", nil, "length"},
+		{"anthropic tool_use", "tool_use", []providers.ToolCall{{ID: "t1", Name: "f", Arguments: "{}"}}, "tool_calls"},
+		{"gemini SAFETY", "SAFETY", nil, "content_filter"},
+		{"gemini STOP with function call", "STOP", []providers.ToolCall{{ID: "t1", Name: "f", Arguments: "{}"}}, "tool_calls"},
+		{"bedrock guardrail", "guardrail_intervened", nil, "content_filter"},
+		{"none reported", "", nil, "stop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prov := &funcProvider{
+				name: "openai",
+				requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+					return &providers.ProxyResponse{
+						ID:           "resp-fr",
+						Model:        req.Model,
+						Content:      "partial",
+						ToolCalls:    tt.toolCalls,
+						FinishReason: tt.reason,
+					}, nil
+				},
+			}
+			gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+
+			client, cleanup := serveGateway(t, gw)
+			defer cleanup()
+
+			body := readBody(t, doPost(t, client, "/v1/chat/completions",
+				[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`)))
+			var out outboundResponse
+			if err := json.Unmarshal(body, &out); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if len(out.Choices) != 1 || out.Choices[0].FinishReason != tt.want {
+				t.Errorf("choices = %+v, want finish_reason %q", out.Choices, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchChat_MaxCompletionTokens(t *testing.T) {
+	var got *providers.ProxyRequest
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": capturingProvider("openai", &got)}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		fields string
+		want   int
+	}{
+		{"max_completion_tokens", `"max_completion_tokens":300`, 300},
+		{"max_tokens", `"max_tokens":200`, 200},
+		{"both prefers max_completion_tokens", `"max_tokens":200,"max_completion_tokens":300`, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/chat/completions",
+				[]byte(`{"model":"gpt-4o",`+tt.fields+`,"messages":[{"role":"user","content":"hi"}]}`))
+			if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+			}
+			if got.MaxTokens != tt.want {
+				t.Errorf("MaxTokens = %d, want %d", got.MaxTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchChat_TPMLimitExceeded(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	var calls int
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls++
+			return &providers.ProxyResponse{ID: "r", Model: req.Model, Content: "ok"}, nil
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{"openai": prov},
+		nil, nil, GatewayOptions{TPMLimit: 100})
+	gw.SetTPMLimiter(ratelimit.NewTPMLimiter(rdb))
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","max_tokens":500,"messages":[{"role":"user","content":"hello"}]}`))
+	body := readBody(t, resp)
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", resp.StatusCode, body)
+	}
+	if !contains(string(body), "tokens per minute") {
+		t.Errorf("expected a TPM-specific message, got %s", body)
+	}
+	if calls != 0 {
+		t.Errorf("provider must not be called when over budget, got %d calls", calls)
+	}
+
+	// A request that fits the budget goes through.
+	resp = doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","max_tokens":50,"messages":[{"role":"user","content":"hello"}]}`))
+	body = readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestEstimateRequestTokens(t *testing.T) {
+	req := &providers.ProxyRequest{
+		Messages:  []providers.Message{{Role: "user", Content: "12345678"}, {Role: "user", Content: "1"}},
+		MaxTokens: 10,
+		N:         2,
+	}
+	if got := estimateInputTokens(req); got != 3 {
+		t.Errorf("estimateInputTokens = %d, want 3", got)
+	}
+	if got := estimateRequestTokens(req); got != 23 {
+		t.Errorf("estimateRequestTokens = %d, want 23", got)
+	}
+}
+
 func TestDispatchChat_CacheHit(t *testing.T) {
 	sc := newStubCache()
 	gw := NewGateway(context.Background(), map[string]providers.Provider{
@@ -409,12 +712,109 @@ func TestDispatchChat_CacheHit(t *testing.T) {
 	resp2 := doPost(t, client, "/v1/chat/completions", reqBody)
 	readBody(t, resp2)
 
-	if resp2.Header.Get("X-Cache") != xCacheHIT {
+	if !isCacheHit(resp2) {
 		t.Error("second request should be a cache HIT")
 	}
 	if resp2.StatusCode != http.StatusOK {
 		t.Errorf("expected 200 on cache hit, got %d", resp2.StatusCode)
 	}
+	if p, m := resp2.Header.Get("X-Provider"), resp2.Header.Get("X-Model"); p != "openai" || m != "gpt-4o" {
+		t.Errorf("cache hit X-Provider/X-Model = %q/%q, want openai/gpt-4o", p, m)
+	}
+}
+
+func TestDispatchChat_CoalescesConcurrentCacheMisses(t *testing.T) {
+	var calls atomic.Int32
+	slow := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls.Add(1)
+			time.Sleep(200 * time.Millisecond)
+			return &providers.ProxyResponse{
+				ID: "resp", Model: req.Model, Content: "shared",
+				Usage: providers.Usage{InputTokens: 10, OutputTokens: 5},
+			}, nil
+		},
+	}
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": slow,
+	}, mc)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	reqBody := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stampede"}]}`)
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := doPost(t, client, "/v1/chat/completions", reqBody)
+			body := readBody(t, resp)
+			if resp.StatusCode != http.StatusOK || !contains(string(body), "shared") {
+				errs <- fmt.Sprintf("status %d: %s", resp.StatusCode, body)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for e := range errs {
+		t.Error(e)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
+
+func TestDispatchChat_SemanticCacheHit(t *testing.T) {
+	var calls atomic.Int32
+	prov := okProvider("openai")
+	inner := prov.requestFn
+	prov.requestFn = func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+		calls.Add(1)
+		return inner(ctx, req)
+	}
+
+	vecs := map[string][]float32{
+		"user: what is the capital of france\n": {1, 0.1},
+		"user: capital of france?\n":            {1, 0.11},
+	}
+	embedder := cache.EmbedderFunc(func(_ context.Context, text string) ([]float32, error) {
+		if v, ok := vecs[text]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unexpected prompt %q", text)
+	})
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	sc := cache.NewSemanticCache(mc, embedder, cache.SemanticOptions{Threshold: 0.99})
+
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, sc)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp1 := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"what is the capital of france"}]}`))
+	readBody(t, resp1)
+	if resp1.Header.Get("X-Cache") != xCacheMISS {
+		t.Fatal("first request should be a cache MISS")
+	}
+
+	resp2 := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"capital of france?"}]}`))
+	readBody(t, resp2)
+	if !isCacheHit(resp2) {
+		t.Error("paraphrased request should be a semantic cache HIT")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
 }
 
 func TestDispatchChat_CacheExcludedModel(t *testing.T) {
@@ -445,12 +845,94 @@ func TestDispatchChat_CacheExcludedModel(t *testing.T) {
 	resp2 := doPost(t, client, "/v1/chat/completions", reqBody)
 	readBody(t, resp2)
 
-	xCache := resp2.Header.Get("X-Cache")
-	if xCache == xCacheHIT {
+	if isCacheHit(resp2) {
 		t.Error("excluded model should never produce a cache HIT")
 	}
 }
 
+func TestDispatchChat_CacheHitReportsRemainingTTL(t *testing.T) {
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, mc, nil, GatewayOptions{CacheTTL: 15 * time.Minute})
+	t.Cleanup(gw.health.Close)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	reqBody := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"fresh?"}]}`)
+	readBody(t, doPost(t, client, "/v1/chat/completions", reqBody))
+
+	resp := doPost(t, client, "/v1/chat/completions", reqBody)
+	readBody(t, resp)
+	var ttl int
+	if _, err := fmt.Sscanf(resp.Header.Get("X-Cache"), "HIT; ttl=%d", &ttl); err != nil {
+		t.Fatalf("X-Cache = %q, want HIT; ttl=<seconds>", resp.Header.Get("X-Cache"))
+	}
+	if ttl < 890 || ttl > 900 {
+		t.Errorf("remaining ttl = %ds, want just under 900s", ttl)
+	}
+}
+
+func TestXCacheHitValue(t *testing.T) {
+	for remaining, want := range map[time.Duration]string{
+		0:                      "HIT",
+		400 * time.Millisecond: "HIT; ttl=1",
+		842 * time.Second:      "HIT; ttl=842",
+	} {
+		if got := xCacheHitValue(remaining); got != want {
+			t.Errorf("xCacheHitValue(%v) = %q, want %q", remaining, got, want)
+		}
+	}
+}
+
+func TestDispatchChat_CacheTTLOverrides(t *testing.T) {
+	sc := newStubCache()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, sc, nil, GatewayOptions{CacheTTL: time.Hour})
+	t.Cleanup(gw.health.Close)
+
+	o, err := cache.NewTTLOverrides(
+		map[string]time.Duration{"gpt-4o-mini": 24 * time.Hour},
+		map[string]time.Duration{"^gpt-4o": 5 * time.Minute},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.SetCacheTTLOverrides(o)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		model  string
+		want   time.Duration
+		header string
+	}{
+		{"gpt-4o-mini", 24 * time.Hour, "86400"}, // exact match
+		{"gpt-4o", 5 * time.Minute, "300"},       // pattern match
+		{"gpt-4-turbo", time.Hour, "3600"},       // global TTL
+	}
+	for _, tt := range tests {
+		clear(sc.ttls)
+		resp := doPost(t, client, "/v1/chat/completions",
+			[]byte(`{"model":"`+tt.model+`","messages":[{"role":"user","content":"ttl"}]}`))
+		readBody(t, resp)
+		if got := resp.Header.Get("X-Cache-TTL"); got != tt.header {
+			t.Errorf("%s: X-Cache-TTL = %q, want %q", tt.model, got, tt.header)
+		}
+		if len(sc.ttls) != 1 {
+			t.Fatalf("%s: %d cache writes, want 1", tt.model, len(sc.ttls))
+		}
+		for _, ttl := range sc.ttls {
+			if ttl != tt.want {
+				t.Errorf("%s: cached with TTL %v, want %v", tt.model, ttl, tt.want)
+			}
+		}
+	}
+}
+
 func TestDispatchChat_ProviderError(t *testing.T) {
 	failing := &funcProvider{
 		name: "openai",
@@ -474,6 +956,94 @@ func TestDispatchChat_ProviderError(t *testing.T) {
 	}
 }
 
+func TestDispatchChat_NegativeCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCache bool
+	}{
+		{"bad request cached", 400, true},
+		{"not found cached", 404, true},
+		{"unauthorized not cached", 401, false},
+		{"rate limited not cached", 429, false},
+		{"server error not cached", 500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			failing := &funcProvider{
+				name: "openai",
+				requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+					calls.Add(1)
+					return nil, &providerError{status: tt.status, msg: "upstream rejected"}
+				},
+			}
+			sc := newStubCache()
+			gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+				"openai": failing,
+			}, sc, nil, GatewayOptions{CacheErrors: true, MaxRetries: 1})
+
+			client, cleanup := serveGateway(t, gw)
+			defer cleanup()
+
+			reqBody := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"bad"}]}`)
+
+			resp1 := doPost(t, client, "/v1/chat/completions", reqBody)
+			body1 := readBody(t, resp1)
+
+			resp2 := doPost(t, client, "/v1/chat/completions", reqBody)
+			body2 := readBody(t, resp2)
+
+			wantCalls := int32(2)
+			if tt.wantCache {
+				wantCalls = 1
+			}
+			if got := calls.Load(); got != wantCalls {
+				t.Errorf("provider called %d times, want %d", got, wantCalls)
+			}
+
+			hit := isCacheHit(resp2)
+			if hit != tt.wantCache {
+				t.Errorf("second request X-Cache HIT = %v, want %v", hit, tt.wantCache)
+			}
+			if tt.wantCache {
+				if resp2.StatusCode != resp1.StatusCode {
+					t.Errorf("replayed status = %d, want %d", resp2.StatusCode, resp1.StatusCode)
+				}
+				if string(body2) != string(body1) {
+					t.Errorf("replayed body = %s, want %s", body2, body1)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchChat_NegativeCacheDisabledByDefault(t *testing.T) {
+	var calls atomic.Int32
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls.Add(1)
+			return nil, &providerError{status: 400, msg: "bad request"}
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": failing,
+	}, newStubCache())
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	reqBody := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"bad"}]}`)
+	readBody(t, doPost(t, client, "/v1/chat/completions", reqBody))
+	readBody(t, doPost(t, client, "/v1/chat/completions", reqBody))
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2", got)
+	}
+}
+
 func TestDispatchChat_StreamingResponse(t *testing.T) {
 	streamProv := &funcProvider{
 		name: "openai",
@@ -532,22 +1102,938 @@ func TestDispatchChat_StreamingResponse(t *testing.T) {
 	}
 }
 
-// --- buildCacheKey tests ----------------------------------------------------
-
-func TestBuildCacheKey_Deterministic(t *testing.T) {
-	req := &providers.ProxyRequest{
-		Model:       "gpt-4o",
-		Messages:    []providers.Message{{Role: "user", Content: "hello"}},
-		Temperature: 0.7,
-		MaxTokens:   100,
-		WorkspaceID: "ws-1",
+func TestDispatchChat_StreamChunkSequence(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunks     []providers.StreamChunk
+		wantFinish string
+	}{
+		{"anthropic end_turn", []providers.StreamChunk{{Content: "hel"}, {Content: "lo"}, {FinishReason: "end_turn"}}, "stop"},
+		{"gemini content with STOP", []providers.StreamChunk{{Content: "hel"}, {Content: "lo", FinishReason: "STOP"}}, "stop"},
+		{"max tokens", []providers.StreamChunk{{Content: "hel"}, {Content: "lo"}, {FinishReason: "max_tokens"}}, "length"},
+		{"tool use", []providers.StreamChunk{{Content: "hel"}, {Content: "lo"}, {FinishReason: "tool_use"}}, "tool_calls"},
+		{"gemini SAFETY", []providers.StreamChunk{{Content: "hel"}, {Content: "lo"}, {FinishReason: "SAFETY"}}, "content_filter"},
+		{"no finish reason", []providers.StreamChunk{{Content: "hel"}, {Content: "lo"}}, "stop"},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamProv := &funcProvider{
+				name: "openai",
+				requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+					ch := make(chan providers.StreamChunk, len(tt.chunks))
+					for _, c := range tt.chunks {
+						ch <- c
+					}
+					close(ch)
+					return &providers.ProxyResponse{Model: req.Model, Stream: ch}, nil
+				},
+			}
+			gw := NewGateway(context.Background(), map[string]providers.Provider{
+				"openai": streamProv,
+			}, nil)
 
-	key1 := buildCacheKey(req)
-	key2 := buildCacheKey(req)
+			client, cleanup := serveGateway(t, gw)
+			defer cleanup()
 
-	if key1 != key2 {
-		t.Errorf("cache key should be deterministic: %s != %s", key1, key2)
+			body := string(readBody(t, doPost(t, client, "/v1/chat/completions",
+				[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stream"}],"stream":true}`))))
+
+			frames := strings.Split(strings.TrimSpace(body), "\n\n")
+			if len(frames) != 5 || frames[4] != "data: [DONE]" {
+				t.Fatalf("frames = %q, want role, two content, finish, [DONE]", frames)
+			}
+			type chunk struct {
+				Object  string `json:"object"`
+				Choices []struct {
+					Index        int               `json:"index"`
+					Delta        map[string]string `json:"delta"`
+					FinishReason *string           `json:"finish_reason"`
+				} `json:"choices"`
+			}
+			var chunks []chunk
+			for _, f := range frames[:4] {
+				var c chunk
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(f, "data: ")), &c); err != nil {
+					t.Fatalf("decode frame %q: %v", f, err)
+				}
+				if c.Object != "chat.completion.chunk" || len(c.Choices) != 1 || c.Choices[0].Index != 0 {
+					t.Fatalf("frame %q is not a single-choice chat.completion.chunk", f)
+				}
+				chunks = append(chunks, c)
+			}
+
+			first := chunks[0].Choices[0]
+			if len(first.Delta) != 1 || first.Delta["role"] != "assistant" || first.FinishReason != nil {
+				t.Errorf("first delta = %v (finish %v), want only role assistant", first.Delta, first.FinishReason)
+			}
+			for i, want := range []string{"hel", "lo"} {
+				c := chunks[i+1].Choices[0]
+				if len(c.Delta) != 1 || c.Delta["content"] != want || c.FinishReason != nil {
+					t.Errorf("content delta %d = %v (finish %v), want only content %q", i, c.Delta, c.FinishReason, want)
+				}
+			}
+			last := chunks[3].Choices[0]
+			if len(last.Delta) != 0 || last.FinishReason == nil || *last.FinishReason != tt.wantFinish {
+				t.Errorf("final chunk = %v (finish %v), want empty delta and finish_reason %q", last.Delta, last.FinishReason, tt.wantFinish)
+			}
+		})
+	}
+}
+
+func TestDispatchChat_StreamKeepAlive(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk)
+			go func() {
+				defer close(ch)
+				time.Sleep(100 * time.Millisecond) // slow first token
+				ch <- providers.StreamChunk{Content: "hello"}
+				ch <- providers.StreamChunk{FinishReason: "stop"}
+			}()
+			return &providers.ProxyResponse{ID: "stream-resp", Model: req.Model, Stream: ch}, nil
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, nil, nil, GatewayOptions{StreamKeepAlive: 10 * time.Millisecond})
+	t.Cleanup(gw.health.Close)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stream"}],"stream":true}`))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var keepAlives int
+	var dataLines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == ": keepalive":
+			keepAlives++
+		case strings.HasPrefix(line, "data: "):
+			dataLines = append(dataLines, strings.TrimPrefix(line, "data: "))
+		case line != "":
+			t.Errorf("unexpected SSE line %q", line)
+		}
+	}
+
+	if keepAlives == 0 {
+		t.Error("expected at least one keep-alive comment before the first chunk")
+	}
+	if len(dataLines) != 4 || dataLines[3] != "[DONE]" {
+		t.Fatalf("data lines = %q, want role, content and finish chunks then [DONE]", dataLines)
+	}
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(dataLines[1]), &chunk); err != nil {
+		t.Fatalf("content chunk is not JSON: %v", err)
+	}
+	if len(chunk.Choices) != 1 || chunk.Choices[0].Delta.Content != "hello" {
+		t.Errorf("content chunk = %s, want content \"hello\"", dataLines[1])
+	}
+}
+
+func TestDispatchChat_StreamErrorFrame(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk, 2)
+			ch <- providers.StreamChunk{Content: "partial"}
+			ch <- providers.StreamChunk{Err: errors.New("connection reset by peer")}
+			close(ch)
+			return &providers.ProxyResponse{ID: "stream-resp", Model: req.Model, Stream: ch}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, nil)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stream"}],"stream":true}`))
+	defer resp.Body.Close()
+
+	var dataLines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			dataLines = append(dataLines, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(dataLines) != 3 {
+		t.Fatalf("data lines = %q, want role and content chunks and one error event", dataLines)
+	}
+	if strings.Contains(dataLines[1], "connection reset") {
+		t.Errorf("error text leaked into content delta: %s", dataLines[1])
+	}
+
+	var frame struct {
+		Error   *apierr.APIError `json:"error"`
+		Choices []any            `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(dataLines[2]), &frame); err != nil {
+		t.Fatalf("error event is not JSON: %v", err)
+	}
+	if frame.Error == nil || frame.Choices != nil {
+		t.Fatalf("last event = %s, want an error object and no choices", dataLines[2])
+	}
+	if frame.Error.Type != apierr.TypeProviderError || frame.Error.Code != apierr.CodeProviderError ||
+		!strings.Contains(frame.Error.Message, "connection reset by peer") {
+		t.Errorf("error = %+v, want provider_error mentioning the upstream failure", *frame.Error)
+	}
+}
+
+func TestDispatchChat_StreamErrorRecordedAsFailure(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk, 2)
+			ch <- providers.StreamChunk{Content: "partial"}
+			ch <- providers.StreamChunk{Err: errors.New("connection reset by peer")}
+			close(ch)
+			return &providers.ProxyResponse{ID: "stream-resp", Model: req.Model, Stream: ch}, nil
+		},
+	}
+	reg := metrics.New()
+	rec := tracing.NewRecorder()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, nil, nil, GatewayOptions{Metrics: reg, Tracer: tracing.New(rec)})
+	t.Cleanup(gw.health.Close)
+	sink := &captureSink{}
+	l, err := logger.NewWithSink(context.Background(), sink, logger.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.SetLogger(l)
+	n, got := newWebhookReceiver(t)
+	gw.SetWebhook(n)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stream"}],"stream":true}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d; the header is sent before the upstream fails", resp.StatusCode)
+	}
+
+	// The stream is finalised after the body drains; the root span ends last.
+	var root *tracing.SpanData
+	for deadline := time.Now().Add(2 * time.Second); root == nil && time.Now().Before(deadline); {
+		for _, s := range rec.Ended() {
+			if s.Name == "gateway.dispatch" {
+				root = &s
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if root == nil {
+		t.Fatal("request span never ended")
+	}
+	if root.Attr(attrStatus) != int64(502) || root.Err == "" {
+		t.Errorf("root span status = %v, err = %q; want 502 and an error", root.Attr(attrStatus), root.Err)
+	}
+
+	ev := awaitWebhook(t, got)
+	if ev.Type != webhook.EventFailed || ev.Status != http.StatusBadGateway {
+		t.Errorf("webhook event = %+v, want request.failed with status 502", ev)
+	}
+	_ = l.Close()
+	if len(sink.entries) != 1 || sink.entries[0].Status != http.StatusBadGateway {
+		t.Errorf("request log = %+v, want one entry with status 502", sink.entries)
+	}
+	if v := counterValue(t, reg, "gateway_requests_total", map[string]string{"provider": "openai", "status": "502"}); v != 1 {
+		t.Errorf("gateway_requests_total{status=502} = %v, want 1", v)
+	}
+}
+
+func TestDispatchChat_StreamClientDisconnectCancelsProvider(t *testing.T) {
+	cancelled := make(chan struct{})
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk)
+			go func() {
+				defer close(ch)
+				for {
+					select {
+					case <-ctx.Done():
+						close(cancelled)
+						return
+					case ch <- providers.StreamChunk{Content: "token "}:
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}()
+			return &providers.ProxyResponse{ID: "stream-resp", Model: req.Model, Stream: ch}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, nil)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"stream"}],"stream":true}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	// Read one event, then hang up mid-stream.
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("read first event: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("provider context was not cancelled after client disconnect")
+	}
+}
+
+func TestDispatchChat_ClientDisconnectCancelsProvider(t *testing.T) {
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+	t.Cleanup(gw.health.Close)
+
+	// A real TCP socket: disconnects are detected by peeking at it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fasthttp.Server{Handler: gw.dispatchChat}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Shutdown() //nolint:errcheck
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	fmt.Fprintf(conn, "POST /v1/chat/completions HTTP/1.1\r\nHost: test\r\n"+
+		"Content-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("provider was not called")
+	}
+	conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("provider context was not cancelled after client disconnect")
+	}
+}
+
+func TestDispatchChat_StreamWarmsCache(t *testing.T) {
+	var calls atomic.Int32
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls.Add(1)
+			ch := make(chan providers.StreamChunk, 3)
+			ch <- providers.StreamChunk{Content: "hello "}
+			ch <- providers.StreamChunk{Content: "world"}
+			ch <- providers.StreamChunk{FinishReason: "stop"}
+			close(ch)
+			return &providers.ProxyResponse{ID: "stream-resp", Model: req.Model, Stream: ch}, nil
+		},
+	}
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, mc, nil, GatewayOptions{CacheStreams: true})
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp1 := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"warm"}],"stream":true}`))
+	readBody(t, resp1)
+
+	// The cache is written by the stream writer after [DONE]; wait for it.
+	key := buildCacheKey("", &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "warm"}},
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := mc.Get(context.Background(), key); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not populate the cache")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resp2 := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"warm"}]}`))
+	body := readBody(t, resp2)
+
+	if !isCacheHit(resp2) {
+		t.Error("non-streaming request should hit the cache warmed by the stream")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+
+	var out outboundResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode cached body: %v", err)
+	}
+	if len(out.Choices) != 1 || out.Choices[0].Message.Content != "hello world" {
+		t.Errorf("cached choices = %+v, want single choice %q", out.Choices, "hello world")
+	}
+	if out.Choices[0].FinishReason != "stop" {
+		t.Errorf("finish_reason = %q, want stop", out.Choices[0].FinishReason)
+	}
+}
+
+func TestDispatchChat_StreamNotCachedByDefault(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk, 1)
+			ch <- providers.StreamChunk{Content: "hi", FinishReason: "stop"}
+			close(ch)
+			return &providers.ProxyResponse{Model: req.Model, Stream: ch}, nil
+		},
+	}
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, mc)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	readBody(t, doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"x"}],"stream":true}`)))
+	time.Sleep(20 * time.Millisecond)
+
+	if mc.Len() != 0 {
+		t.Errorf("cache has %d entries, want 0 when CacheStreams is off", mc.Len())
+	}
+}
+
+func TestDispatchChat_StreamPrefersProviderUsage(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk, 3)
+			ch <- providers.StreamChunk{Content: "hello world"}
+			ch <- providers.StreamChunk{FinishReason: "stop"}
+			ch <- providers.StreamChunk{Usage: &providers.Usage{InputTokens: 42, OutputTokens: 7}}
+			close(ch)
+			return &providers.ProxyResponse{Model: req.Model, Stream: ch}, nil
+		},
+	}
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, mc, nil, GatewayOptions{CacheStreams: true})
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"usage"}],"stream":true}`))
+	body := string(readBody(t, resp))
+
+	// The usage-only chunk is accounted for, not forwarded as an empty delta.
+	if n := strings.Count(body, "data: "); n != 4 {
+		t.Errorf("got %d SSE frames, want 4 (role, content, finish, [DONE]):\n%s", n, body)
+	}
+
+	key := buildCacheKey("", &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "usage"}},
+	})
+	var cached []byte
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var ok bool
+		if cached, ok = mc.Get(context.Background(), key); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not populate the cache")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var out outboundResponse
+	if err := json.Unmarshal(cached, &out); err != nil {
+		t.Fatalf("decode cached body: %v", err)
+	}
+	if out.Usage.PromptTokens != 42 || out.Usage.CompletionTokens != 7 {
+		t.Errorf("usage = %+v, want provider-reported 42/7", out.Usage)
+	}
+}
+
+func TestDispatchChat_StreamIncludeUsage(t *testing.T) {
+	streamProv := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			ch := make(chan providers.StreamChunk, 3)
+			ch <- providers.StreamChunk{Content: "hi"}
+			ch <- providers.StreamChunk{FinishReason: "stop"}
+			ch <- providers.StreamChunk{Usage: &providers.Usage{InputTokens: 12, OutputTokens: 3}}
+			close(ch)
+			return &providers.ProxyResponse{Model: req.Model, Stream: ch}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": streamProv,
+	}, nil)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantUsage int
+	}{
+		{"requested", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`, 1},
+		{"not requested", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":true}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/chat/completions", []byte(tt.body))
+			body := string(readBody(t, resp))
+
+			frames := strings.Split(strings.TrimSpace(body), "\n\n")
+			var usageFrames []map[string]any
+			for _, f := range frames {
+				data := strings.TrimPrefix(f, "data: ")
+				if data == "[DONE]" {
+					continue
+				}
+				var chunk map[string]any
+				if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+					t.Fatalf("decode frame %q: %v", f, err)
+				}
+				if _, ok := chunk["usage"]; ok {
+					usageFrames = append(usageFrames, chunk)
+				}
+			}
+			if len(usageFrames) != tt.wantUsage {
+				t.Fatalf("got %d usage frames, want %d:\n%s", len(usageFrames), tt.wantUsage, body)
+			}
+			if tt.wantUsage == 0 {
+				return
+			}
+			if frames[len(frames)-1] != "data: [DONE]" {
+				t.Errorf("last frame = %q, want [DONE]", frames[len(frames)-1])
+			}
+			if !strings.Contains(frames[len(frames)-2], `"usage"`) {
+				t.Errorf("usage frame should directly precede [DONE]")
+			}
+			chunk := usageFrames[0]
+			if choices, _ := chunk["choices"].([]any); choices == nil || len(choices) != 0 {
+				t.Errorf("usage frame choices = %v, want empty array", chunk["choices"])
+			}
+			usage, _ := chunk["usage"].(map[string]any)
+			if usage["prompt_tokens"] != float64(12) || usage["completion_tokens"] != float64(3) ||
+				usage["total_tokens"] != float64(15) {
+				t.Errorf("usage = %v, want 12/3/15", usage)
+			}
+		})
+	}
+}
+
+func TestDispatchChat_ToolCalls(t *testing.T) {
+	var captured *providers.ProxyRequest
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			captured = req
+			return &providers.ProxyResponse{
+				ID:    "resp-tools",
+				Model: req.Model,
+				ToolCalls: []providers.ToolCall{
+					{ID: "call_2", Name: "get_weather", Arguments: `{"city":"Lyon"}`},
+				},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o",
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}],
+		"tool_choice":"required",
+		"messages":[
+			{"role":"user","content":"Weather?"},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"18C"}
+		]}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+
+	if !contains(string(captured.Tools), "get_weather") || string(captured.ToolChoice) != `"required"` {
+		t.Errorf("tools not forwarded: tools=%s tool_choice=%s", captured.Tools, captured.ToolChoice)
+	}
+	if len(captured.Messages) != 3 {
+		t.Fatalf("messages = %+v", captured.Messages)
+	}
+	wantCall := providers.ToolCall{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}
+	if calls := captured.Messages[1].ToolCalls; len(calls) != 1 || calls[0] != wantCall {
+		t.Errorf("assistant tool calls = %+v, want [%+v]", calls, wantCall)
+	}
+	if captured.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("tool message ToolCallID = %q, want call_1", captured.Messages[2].ToolCallID)
+	}
+
+	var out outboundResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(out.Choices) != 1 || out.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("choices = %+v, want one choice with finish_reason tool_calls", out.Choices)
+	}
+	calls := out.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_2" || calls[0].Type != "function" ||
+		calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Lyon"}` {
+		t.Errorf("tool_calls = %+v", calls)
+	}
+}
+
+func TestDispatchChat_InvalidToolsAndResponseFormat(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"model":"gpt-4o","messages":[],"tools":[{"type":"retrieval"}]}`,
+		`{"model":"gpt-4o","messages":[],"tool_choice":"sometimes"}`,
+		`{"model":"gpt-4o","messages":[],"response_format":{"type":"yaml"}}`,
+		`{"model":"gpt-4o","messages":[],"response_format":{"type":"json_schema"}}`,
+	} {
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		readBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestDispatchChat_Reasoning(t *testing.T) {
+	var captured *providers.ProxyRequest
+	prov := &funcProvider{
+		name: "anthropic",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			captured = req
+			return &providers.ProxyResponse{Model: req.Model, Content: "42", Reasoning: "Six times seven."}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"anthropic": prov}, nil)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"claude-sonnet-4-0",
+		"messages":[{"role":"user","content":"6*7?"}],
+		"reasoning_effort":"low","thinking":{"type":"enabled","budget_tokens":2048}}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	if captured.ReasoningEffort != "low" || captured.ThinkingBudget != 2048 {
+		t.Errorf("forwarded reasoning_effort = %q, thinking budget = %d", captured.ReasoningEffort, captured.ThinkingBudget)
+	}
+	var out outboundResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := out.Choices[0].Message.ReasoningContent; got != "Six times seven." {
+		t.Errorf("reasoning_content = %q", got)
+	}
+
+	for _, body := range []string{
+		`{"model":"claude-sonnet-4-0","messages":[],"reasoning_effort":"extreme"}`,
+		`{"model":"claude-sonnet-4-0","messages":[],"thinking":{"type":"on"}}`,
+		`{"model":"claude-sonnet-4-0","messages":[],"thinking":{"type":"enabled","budget_tokens":100}}`,
+	} {
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		readBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestDispatchChat_LogProbs(t *testing.T) {
+	logprobs := `{"content":[{"token":"ok","logprob":-0.01}]}`
+	var captured *providers.ProxyRequest
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			captured = req
+			return &providers.ProxyResponse{Model: req.Model, Content: "ok", LogProbs: json.RawMessage(logprobs)}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o",
+		"messages":[{"role":"user","content":"hi"}],"logprobs":true,"top_logprobs":2}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	if captured.LogProbs == nil || !*captured.LogProbs || captured.TopLogProbs == nil || *captured.TopLogProbs != 2 {
+		t.Errorf("forwarded logprobs = %v, top_logprobs = %v", captured.LogProbs, captured.TopLogProbs)
+	}
+	var out outboundResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := string(out.Choices[0].LogProbs); got != logprobs {
+		t.Errorf("logprobs = %s, want %s", got, logprobs)
+	}
+
+	for _, body := range []string{
+		`{"model":"gpt-4o","messages":[],"top_logprobs":2}`,
+		`{"model":"gpt-4o","messages":[],"logprobs":true,"top_logprobs":21}`,
+		`{"model":"gpt-4o","messages":[],"logprobs":true,"top_logprobs":-1}`,
+	} {
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		readBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestDispatchChat_StreamReplaysCachedResponse(t *testing.T) {
+	var calls atomic.Int32
+	prov := okProvider("openai")
+	inner := prov.requestFn
+	prov.requestFn = func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+		calls.Add(1)
+		return inner(ctx, req)
+	}
+	sc := newStubCache()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": prov,
+	}, sc, nil, GatewayOptions{ReplayChunkSize: 4})
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	// Warm the cache with a non-streaming request.
+	readBody(t, doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"replay"}]}`)))
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"replay"}],"stream":true}`))
+	defer resp.Body.Close()
+
+	if !isCacheHit(resp) {
+		t.Error("stream request should be served from cache")
+	}
+	if ct := resp.Header.Get("Content-Type"); !contains(ct, "text/event-stream") {
+		t.Errorf("expected text/event-stream content type, got %s", ct)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+
+	var content, finish string
+	var deltas int
+	var last string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) <= 6 || line[:5] != "data:" {
+			continue
+		}
+		last = line[6:]
+		if last == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta        struct{ Content string } `json:"delta"`
+				FinishReason *string                  `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(last), &chunk); err != nil {
+			t.Fatalf("decode chunk %q: %v", last, err)
+		}
+		for _, c := range chunk.Choices {
+			content += c.Delta.Content
+			if c.Delta.Content != "" {
+				deltas++
+			}
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+	}
+
+	if content != "hello from openai" {
+		t.Errorf("replayed content = %q, want %q", content, "hello from openai")
+	}
+	if deltas != 5 { // 17 chars in chunks of 4
+		t.Errorf("got %d content deltas, want 5", deltas)
+	}
+	if finish != "stop" {
+		t.Errorf("finish_reason = %q, want stop", finish)
+	}
+	if last != "[DONE]" {
+		t.Errorf("expected last SSE line to be [DONE], got %q", last)
+	}
+}
+
+// --- stopSequences tests ----------------------------------------------------
+
+func TestStopSequences_Unmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"bare string", `{"stop":"END"}`, []string{"END"}},
+		{"array", `{"stop":["END","\n\n"]}`, []string{"END", "\n\n"}},
+		{"null", `{"stop":null}`, nil},
+		{"empty string", `{"stop":""}`, nil},
+		{"omitted", `{}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req inboundRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(req.Stop) != len(tt.want) {
+				t.Fatalf("Stop = %v, want %v", req.Stop, tt.want)
+			}
+			for i := range tt.want {
+				if req.Stop[i] != tt.want[i] {
+					t.Errorf("Stop[%d] = %q, want %q", i, req.Stop[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStopSequences_UnmarshalInvalid(t *testing.T) {
+	var req inboundRequest
+	if err := json.Unmarshal([]byte(`{"stop":42}`), &req); err == nil {
+		t.Fatal("expected error for non-string stop value")
+	}
+}
+
+func TestMessageContent_Unmarshal(t *testing.T) {
+	var msg inboundMessage
+	body := `{"role":"user","content":[
+		{"type":"text","text":"What is "},
+		{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"low"}},
+		{"type":"text","text":"this?"}
+	]}`
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Content.Text != "What is this?" {
+		t.Errorf("Text = %q, want joined text parts", msg.Content.Text)
+	}
+	want := []providers.ContentPart{
+		{Type: "text", Text: "What is "},
+		{Type: "image_url", ImageURL: "https://example.com/cat.png", Detail: "low"},
+		{Type: "text", Text: "this?"},
+	}
+	if len(msg.Content.Parts) != len(want) {
+		t.Fatalf("Parts = %+v, want %+v", msg.Content.Parts, want)
+	}
+	for i := range want {
+		if msg.Content.Parts[i] != want[i] {
+			t.Errorf("Parts[%d] = %+v, want %+v", i, msg.Content.Parts[i], want[i])
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"role":"user","content":"plain"}`), &msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Content.Text != "plain" || msg.Content.Parts != nil {
+		t.Errorf("plain string content = %+v, want text only", msg.Content)
+	}
+}
+
+func TestMessageContent_UnmarshalInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"number", `42`},
+		{"unknown part type", `[{"type":"input_audio"}]`},
+		{"image without url", `[{"type":"image_url","image_url":{}}]`},
+		{"non-base64 data url", `[{"type":"image_url","image_url":{"url":"data:image/png,raw"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c messageContent
+			if err := json.Unmarshal([]byte(tt.body), &c); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// --- buildCacheKey tests ----------------------------------------------------
+
+func TestBuildCacheKey_Deterministic(t *testing.T) {
+	req := &providers.ProxyRequest{
+		Model:       "gpt-4o",
+		Messages:    []providers.Message{{Role: "user", Content: "hello"}},
+		Temperature: 0.7,
+		MaxTokens:   100,
+		WorkspaceID: "ws-1",
+	}
+
+	key1 := buildCacheKey("", req)
+	key2 := buildCacheKey("", req)
+
+	if key1 != key2 {
+		t.Errorf("cache key should be deterministic: %s != %s", key1, key2)
 	}
 	if !contains(key1, "cache:") {
 		t.Errorf("cache key should have prefix 'cache:', got %s", key1)
@@ -566,7 +2052,7 @@ func TestBuildCacheKey_DifferentModels(t *testing.T) {
 		Temperature: 0.5,
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different models should produce different cache keys")
 	}
 }
@@ -581,7 +2067,7 @@ func TestBuildCacheKey_DifferentMessages(t *testing.T) {
 		Messages: []providers.Message{{Role: "user", Content: "world"}},
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different messages should produce different cache keys")
 	}
 }
@@ -598,7 +2084,7 @@ func TestBuildCacheKey_DifferentWorkspaces(t *testing.T) {
 		WorkspaceID: "ws-2",
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different workspace IDs should produce different cache keys")
 	}
 }
@@ -615,7 +2101,7 @@ func TestBuildCacheKey_DifferentTemperatures(t *testing.T) {
 		Temperature: 1.0,
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different temperatures should produce different cache keys")
 	}
 }
@@ -632,7 +2118,7 @@ func TestBuildCacheKey_DifferentAPIKeys(t *testing.T) {
 		APIKeyID: "hash-b",
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different API key hashes should produce different cache keys")
 	}
 }
@@ -649,38 +2135,298 @@ func TestBuildCacheKey_DifferentMaxTokens(t *testing.T) {
 		MaxTokens: 200,
 	}
 
-	if buildCacheKey(req1) == buildCacheKey(req2) {
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
 		t.Error("different max_tokens should produce different cache keys")
 	}
 }
 
+func TestBuildCacheKey_DifferentN(t *testing.T) {
+	req1 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+		N:        1,
+	}
+	req2 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+		N:        3,
+	}
+
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
+		t.Error("different n should produce different cache keys")
+	}
+}
+
+func TestBuildCacheKey_DifferentTopP(t *testing.T) {
+	low, high := 0.1, 0.9
+	req1 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+		TopP:     &low,
+	}
+	req2 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+		TopP:     &high,
+	}
+	req3 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
+		t.Error("different top_p should produce different cache keys")
+	}
+	if buildCacheKey("", req1) == buildCacheKey("", req3) {
+		t.Error("omitted top_p should not collide with an explicit value")
+	}
+}
+
+func TestBuildCacheKey_DifferentStop(t *testing.T) {
+	req1 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+		Stop:     []string{"END"},
+	}
+	req2 := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+
+	if buildCacheKey("", req1) == buildCacheKey("", req2) {
+		t.Error("different stop sequences should produce different cache keys")
+	}
+}
+
+func TestBuildCacheKey_DifferentImages(t *testing.T) {
+	withImage := func(url string) *providers.ProxyRequest {
+		return &providers.ProxyRequest{
+			Model: "gpt-4o",
+			Messages: []providers.Message{{Role: "user", Content: "describe", Parts: []providers.ContentPart{
+				{Type: providers.ContentPartText, Text: "describe"},
+				{Type: providers.ContentPartImageURL, ImageURL: url},
+			}}},
+		}
+	}
+	textOnly := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "describe"}},
+	}
+
+	a, b := buildCacheKey("", withImage("https://example.com/a.png")), buildCacheKey("", withImage("https://example.com/b.png"))
+	if a == b {
+		t.Error("different images should produce different cache keys")
+	}
+	if a == buildCacheKey("", textOnly) {
+		t.Error("an image message should not share a key with its text alone")
+	}
+}
+
+func TestBuildCacheKey_DifferentTools(t *testing.T) {
+	base := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	withTools := *base
+	withTools.Tools = json.RawMessage(`[{"type":"function","function":{"name":"a"}}]`)
+	withChoice := withTools
+	withChoice.ToolChoice = json.RawMessage(`"required"`)
+
+	keys := map[string]bool{}
+	for _, r := range []*providers.ProxyRequest{base, &withTools, &withChoice} {
+		keys[buildCacheKey("", r)] = true
+	}
+	if len(keys) != 3 {
+		t.Error("tools and tool_choice should be part of the cache key")
+	}
+}
+
+func TestBuildCacheKey_DifferentSeeds(t *testing.T) {
+	one, two := int64(1), int64(2)
+	base := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	seeded1 := *base
+	seeded1.Seed = &one
+	seeded2 := *base
+	seeded2.Seed = &two
+
+	keys := map[string]bool{}
+	for _, r := range []*providers.ProxyRequest{base, &seeded1, &seeded2} {
+		keys[buildCacheKey("", r)] = true
+	}
+	if len(keys) != 3 {
+		t.Error("seed should be part of the cache key")
+	}
+}
+
+func TestBuildCacheKey_DifferentResponseFormats(t *testing.T) {
+	base := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	jsonMode := *base
+	jsonMode.ResponseFormat = json.RawMessage(`{"type":"json_object"}`)
+
+	if buildCacheKey("", base) == buildCacheKey("", &jsonMode) {
+		t.Error("response_format should be part of the cache key")
+	}
+}
+
+func TestBuildCacheKey_DifferentLogProbs(t *testing.T) {
+	base := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	on, top := true, 3
+	withLogProbs := *base
+	withLogProbs.LogProbs = &on
+	withTop := withLogProbs
+	withTop.TopLogProbs = &top
+
+	keys := map[string]bool{buildCacheKey("", base): true, buildCacheKey("", &withLogProbs): true, buildCacheKey("", &withTop): true}
+	if len(keys) != 3 {
+		t.Error("logprobs and top_logprobs should be part of the cache key")
+	}
+}
+
+func TestBuildCacheKey_DifferentReasoning(t *testing.T) {
+	base := &providers.ProxyRequest{
+		Model:    "claude-sonnet-4-0",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	effort := *base
+	effort.ReasoningEffort = "high"
+	budget := *base
+	budget.ThinkingBudget = 4096
+
+	keys := map[string]bool{buildCacheKey("", base): true, buildCacheKey("", &effort): true, buildCacheKey("", &budget): true}
+	if len(keys) != 3 {
+		t.Error("reasoning_effort and the thinking budget should be part of the cache key")
+	}
+}
+
+func TestBuildCacheKey_DifferentPenalties(t *testing.T) {
+	half := 0.5
+	base := &providers.ProxyRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	}
+	withPresence := *base
+	withPresence.PresencePenalty = &half
+	withFrequency := *base
+	withFrequency.FrequencyPenalty = &half
+
+	keys := map[string]string{
+		"base":      buildCacheKey("", base),
+		"presence":  buildCacheKey("", &withPresence),
+		"frequency": buildCacheKey("", &withFrequency),
+	}
+	seen := make(map[string]string)
+	for name, k := range keys {
+		if other, dup := seen[k]; dup {
+			t.Errorf("%s and %s produced the same cache key", name, other)
+		}
+		seen[k] = name
+	}
+}
+
 // --- handleProviderError tests ----------------------------------------------
 
 func TestHandleProviderError_StatusCoder(t *testing.T) {
 	tests := []struct {
-		name       string
-		err        error
-		wantStatus int
+		name           string
+		passthrough    bool
+		err            error
+		wantStatus     int
+		wantRetryAfter string
 	}{
-		{"429 rate limit", &providerError{status: 429, msg: "rate limited"}, 429},
-		{"503 service unavailable", &providerError{status: 503, msg: "unavailable"}, 502},
-		{"500 internal", &providerError{status: 500, msg: "internal"}, 502},
+		{"429 rate limit", false, &providerError{status: 429, msg: "rate limited"}, 429, "60"},
+		{"503 service unavailable", false, &providerError{status: 503, msg: "unavailable"}, 502, ""},
+		{"500 internal", false, &providerError{status: 500, msg: "internal"}, 502, ""},
+		{"passthrough 503", true, &providerError{status: 503, msg: "unavailable"}, 503, ""},
+		{"passthrough 503 with hint", true, &retryAfterError{providerError{503, "unavailable"}, 20 * time.Second}, 503, "20"},
+		{"passthrough 503 wrapped by failover", true, fmt.Errorf("failover: all providers failed after 2 attempt(s): %w",
+			&providerError{status: 503, msg: "unavailable"}), 503, ""},
+		{"passthrough keeps 500 as 502", true, &providerError{status: 500, msg: "internal"}, 502, ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := &fasthttp.RequestCtx{}
-			handleProviderError(ctx, tt.err)
+			(&Gateway{passthroughUnavailable: tt.passthrough}).handleProviderError(ctx, tt.err)
 			if ctx.Response.StatusCode() != tt.wantStatus {
 				t.Errorf("expected %d, got %d", tt.wantStatus, ctx.Response.StatusCode())
 			}
+			if got := string(ctx.Response.Header.Peek("Retry-After")); got != tt.wantRetryAfter {
+				t.Errorf("expected Retry-After=%q, got %q", tt.wantRetryAfter, got)
+			}
+		})
+	}
+}
+
+type retryAfterError struct {
+	providerError
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) RetryAfterDuration() time.Duration { return e.retryAfter }
+
+func TestHandleProviderError_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no hint defaults to 60", &providerError{status: 429, msg: "rate limited"}, "60"},
+		{"upstream hint", &retryAfterError{providerError{429, "rate limited"}, 12 * time.Second}, "12"},
+		{"sub-second rounds up", &retryAfterError{providerError{429, "rate limited"}, 1500 * time.Millisecond}, "2"},
+		{"wrapped by failover", fmt.Errorf("failover: all providers failed after 1 attempt(s): %w",
+			&retryAfterError{providerError{429, "rate limited"}, 30 * time.Second}), "30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			(&Gateway{}).handleProviderError(ctx, tt.err)
+			if ctx.Response.StatusCode() != fasthttp.StatusTooManyRequests {
+				t.Fatalf("expected 429, got %d", ctx.Response.StatusCode())
+			}
+			if got := string(ctx.Response.Header.Peek("Retry-After")); got != tt.want {
+				t.Errorf("expected Retry-After=%s, got %q", tt.want, got)
+			}
 		})
 	}
 }
 
+func TestDispatchChat_ProviderRateLimitPropagatesRetryAfter(t *testing.T) {
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return nil, &retryAfterError{providerError{429, "rate limited"}, 9 * time.Second}
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	body := readBody(t, resp)
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "9" {
+		t.Errorf("expected Retry-After=9, got %q", got)
+	}
+}
+
 func TestHandleProviderError_Timeout(t *testing.T) {
 	ctx := &fasthttp.RequestCtx{}
-	handleProviderError(ctx, context.DeadlineExceeded)
+	(&Gateway{}).handleProviderError(ctx, context.DeadlineExceeded)
 	if ctx.Response.StatusCode() != fasthttp.StatusGatewayTimeout {
 		t.Errorf("expected 504, got %d", ctx.Response.StatusCode())
 	}
@@ -688,7 +2434,7 @@ func TestHandleProviderError_Timeout(t *testing.T) {
 
 func TestHandleProviderError_GenericError(t *testing.T) {
 	ctx := &fasthttp.RequestCtx{}
-	handleProviderError(ctx, context.Canceled)
+	(&Gateway{}).handleProviderError(ctx, context.Canceled)
 	if ctx.Response.StatusCode() != fasthttp.StatusBadGateway {
 		t.Errorf("expected 502, got %d", ctx.Response.StatusCode())
 	}
@@ -699,7 +2445,179 @@ func TestHandleProviderError_GenericError(t *testing.T) {
 func TestLogRequest_NilLogger(t *testing.T) {
 	gw := NewGateway(context.Background(), nil, nil)
 	// Should not panic when logger is nil.
-	gw.logRequest("req-1", "openai", "gpt-4o", 10, 5, time.Millisecond, 200, false)
+	gw.logRequest(nil, "req-1", "", "", "openai", "openai", "gpt-4o", 1, 10, 5, time.Millisecond, 200, false)
+}
+
+// captureSink is a logger.Sink that keeps every entry it is given.
+type captureSink struct {
+	mu      sync.Mutex
+	entries []logger.RequestLog
+}
+
+func (c *captureSink) Write(_ context.Context, batch []logger.RequestLog) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, batch...)
+	return nil
+}
+
+func TestLogRequest_LongLatencyNotClamped(t *testing.T) {
+	sink := &captureSink{}
+	l, err := logger.NewWithSink(context.Background(), sink, logger.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := NewGateway(context.Background(), nil, nil)
+	gw.SetLogger(l)
+
+	gw.logRequest(nil, "req-1", "", "", "openai", "openai", "o1", 1, 10, 5, 120*time.Second, 200, false)
+	_ = l.Close()
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(sink.entries))
+	}
+	if got := sink.entries[0].LatencyMs; got != 120_000 {
+		t.Errorf("LatencyMs = %d, want 120000", got)
+	}
+}
+
+// newWebhookReceiver returns a Notifier posting to a test server and the
+// events the server receives.
+func newWebhookReceiver(t *testing.T) (*webhook.Notifier, <-chan webhook.Event) {
+	t.Helper()
+	got := make(chan webhook.Event, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Error(err)
+		}
+		got <- ev
+	}))
+	t.Cleanup(receiver.Close)
+
+	n, err := webhook.New(webhook.Options{URL: receiver.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = n.Close() })
+	return n, got
+}
+
+// awaitWebhook returns the next event delivered to a newWebhookReceiver.
+func awaitWebhook(t *testing.T, got <-chan webhook.Event) webhook.Event {
+	t.Helper()
+	select {
+	case ev := <-got:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+		return webhook.Event{}
+	}
+}
+
+func TestDispatchChat_NotifiesWebhook(t *testing.T) {
+	n, got := newWebhookReceiver(t)
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil)
+	gw.SetWebhook(n)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+
+	ev := awaitWebhook(t, got)
+	if ev.Type != webhook.EventCompleted || ev.Provider != "openai" || ev.Status != 200 || ev.RequestID == "" {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestDispatchChat_WebhookReportsWrittenStatus(t *testing.T) {
+	n, got := newWebhookReceiver(t)
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				return nil, &providers.Error{Provider: "openai", StatusCode: 429, Message: "rate limited"}
+			},
+		},
+	}, nil)
+	t.Cleanup(gw.health.Close)
+	gw.SetWebhook(n)
+
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+
+	if ev := awaitWebhook(t, got); ev.Type != webhook.EventFailed || ev.Status != http.StatusTooManyRequests || ev.Model != "gpt-4o" {
+		t.Errorf("event = %+v, want request.failed with status 429", ev)
+	}
+}
+
+func TestWebhook_NotifiesEarlyRejections(t *testing.T) {
+	n, got := newWebhookReceiver(t)
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil)
+	t.Cleanup(gw.health.Close)
+	gw.SetWebhook(n)
+	gw.gatewayKeys = newGatewayKeySet([]string{"gw-secret"})
+	client := serveHandler(t, gw.handler(nil))
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		body   string
+		status int
+		model  string
+	}{
+		{"missing gateway key", "/v1/chat/completions", "", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, http.StatusUnauthorized, ""},
+		{"invalid chat request", "/v1/chat/completions", "gw-secret", `{"messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest, ""},
+		{"invalid embeddings request", "/v1/embeddings", "gw-secret", `{"model":"text-embedding-3-small","input":"hi","dimensions":0}`, http.StatusBadRequest, ""},
+		{"unserved rerank model", "/v1/rerank", "gw-secret", `{"model":"rerank-english-v3.0","query":"q","documents":["a"]}`, http.StatusBadRequest, "rerank-english-v3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "http://gateway"+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readBody(t, resp)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			ev := awaitWebhook(t, got)
+			if ev.Type != webhook.EventFailed || int(ev.Status) != tt.status || ev.RequestID == "" || ev.Model != tt.model {
+				t.Errorf("event = %+v, want request.failed with status %d", ev, tt.status)
+			}
+		})
+	}
+
+	// Non-inference routes are not reported.
+	resp, err := client.Get("http://gateway/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	select {
+	case ev := <-got:
+		t.Errorf("unexpected event for GET /health: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
 }
 
 // --- helpers ----------------------------------------------------------------
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,10 +14,19 @@ import (
 const healthProbeInterval = 30 * time.Second
 const healthProbeTimeout = 5 * time.Second
 
+// latencyWindow is the number of recent probe latencies kept per provider
+// for the rolling P50.
+const latencyWindow = 10
+
 // componentStatus holds the last known health result for one component.
 type componentStatus struct {
 	mu     sync.RWMutex
 	status string // "ok" | "degraded" | "down"
+
+	// Details of the latest probe; recorded for providers only.
+	lastError   string
+	lastChecked time.Time
+	latency     time.Duration
 }
 
 func (s *componentStatus) set(v string) {
@@ -24,6 +35,36 @@ func (s *componentStatus) set(v string) {
 	s.mu.Unlock()
 }
 
+// setProbe records the outcome of a probe that took latency. A nil err
+// clears the last error.
+func (s *componentStatus) setProbe(v string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = v
+	s.latency = latency
+	s.lastChecked = time.Now()
+	s.lastError = ""
+	if err != nil {
+		s.lastError = err.Error()
+	}
+}
+
+// details returns the latest probe result.
+func (s *componentStatus) details() ProviderHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := s.status
+	if status == "" {
+		status = "unknown"
+	}
+	return ProviderHealth{
+		Status:      status,
+		LatencyMs:   s.latency.Milliseconds(),
+		LastChecked: s.lastChecked,
+		LastError:   s.lastError,
+	}
+}
+
 func (s *componentStatus) get() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -33,6 +74,37 @@ func (s *componentStatus) get() string {
 	return s.status
 }
 
+// latencyTracker holds a ring of the most recent latency samples.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples [latencyWindow]time.Duration
+	n       int // samples recorded, capped at latencyWindow
+	next    int
+}
+
+func (l *latencyTracker) record(d time.Duration) {
+	l.mu.Lock()
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencyWindow
+	if l.n < latencyWindow {
+		l.n++
+	}
+	l.mu.Unlock()
+}
+
+// p50 returns the median of the recorded samples; ok is false when none
+// have been recorded yet.
+func (l *latencyTracker) p50() (d time.Duration, ok bool) {
+	l.mu.Lock()
+	sorted := slices.Clone(l.samples[:l.n])
+	l.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0, false
+	}
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2], true
+}
+
 // HealthChecker runs background probes and exposes the latest results.
 type HealthChecker struct {
 	providers  map[string]providers.Provider
@@ -42,6 +114,7 @@ type HealthChecker struct {
 	metrics    *metrics.Registry
 
 	providerStatuses map[string]*componentStatus
+	latencies        map[string]*latencyTracker
 	cacheStatus      componentStatus
 	dbStatus         componentStatus
 
@@ -64,6 +137,7 @@ func NewHealthChecker(
 		providers:        provs,
 		cacheReady:       cacheReady,
 		providerStatuses: make(map[string]*componentStatus),
+		latencies:        make(map[string]*latencyTracker),
 		startTime:        time.Now(),
 		done:             make(chan struct{}),
 		baseCtx:          ctx,
@@ -72,6 +146,7 @@ func NewHealthChecker(
 
 	for name := range provs {
 		hc.providerStatuses[name] = &componentStatus{status: "unknown"}
+		hc.latencies[name] = &latencyTracker{}
 	}
 
 	// Run first probe synchronously so health is not "unknown" immediately.
@@ -88,8 +163,23 @@ type HealthSnapshot struct {
 	Status        string            `json:"status"`
 	UptimeSeconds int64             `json:"uptime_seconds"`
 	Providers     map[string]string `json:"providers"`
-	Cache         string            `json:"cache"`
-	Database      string            `json:"database"`
+	// LatencyP50Ms is the rolling median health-probe latency per provider,
+	// in milliseconds. Providers without a successful probe are omitted.
+	LatencyP50Ms map[string]int64 `json:"latency_p50_ms,omitempty"`
+	// ProviderDetails is the latest probe result per provider.
+	ProviderDetails map[string]ProviderHealth `json:"provider_details"`
+	Cache           string                    `json:"cache"`
+	Database        string                    `json:"database"`
+}
+
+// ProviderHealth is the latest health-probe result for one provider.
+type ProviderHealth struct {
+	Status string `json:"status"`
+	// LatencyMs is how long the latest probe took, failed or not.
+	LatencyMs   int64     `json:"latency_ms"`
+	LastChecked time.Time `json:"last_checked,omitzero"`
+	// LastError is the latest probe's error; empty when it passed.
+	LastError string `json:"last_error,omitempty"`
 }
 
 // Snapshot builds a snapshot from the latest probe results.
@@ -97,14 +187,23 @@ func (hc *HealthChecker) Snapshot() HealthSnapshot {
 	overall := "ok"
 
 	providers := make(map[string]string, len(hc.providerStatuses))
+	details := make(map[string]ProviderHealth, len(hc.providerStatuses))
 	for name, s := range hc.providerStatuses {
-		st := s.get()
-		providers[name] = st
-		if st != "ok" {
+		d := s.details()
+		providers[name] = d.Status
+		details[name] = d
+		if d.Status != "ok" {
 			overall = "degraded"
 		}
 	}
 
+	latencies := make(map[string]int64, len(hc.latencies))
+	for name, l := range hc.latencies {
+		if d, ok := l.p50(); ok {
+			latencies[name] = d.Milliseconds()
+		}
+	}
+
 	cache := hc.cacheStatus.get()
 	db := hc.dbStatus.get()
 
@@ -113,14 +212,105 @@ func (hc *HealthChecker) Snapshot() HealthSnapshot {
 	}
 
 	return HealthSnapshot{
-		Status:        overall,
-		UptimeSeconds: int64(time.Since(hc.startTime).Seconds()),
-		Providers:     providers,
-		Cache:         cache,
-		Database:      db,
+		Status:          overall,
+		UptimeSeconds:   int64(time.Since(hc.startTime).Seconds()),
+		Providers:       providers,
+		LatencyP50Ms:    latencies,
+		ProviderDetails: details,
+		Cache:           cache,
+		Database:        db,
 	}
 }
 
+// ProviderStatus returns the latest probe result for provider name; ok is
+// false when the checker does not know the provider.
+func (hc *HealthChecker) ProviderStatus(name string) (h ProviderHealth, ok bool) {
+	s, found := hc.providerStatuses[name]
+	if !found {
+		return ProviderHealth{}, false
+	}
+	return s.details(), true
+}
+
+// ProbeProvider probes provider name now, bounded by the usual probe
+// timeout, and records the result as a background probe would. ok is false
+// when the checker does not know the provider.
+func (hc *HealthChecker) ProbeProvider(ctx context.Context, name string) (h ProviderHealth, ok bool) {
+	prov, found := hc.providers[name]
+	if !found {
+		return ProviderHealth{}, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	hc.probeProvider(ctx, name, prov)
+	return hc.providerStatuses[name].details(), true
+}
+
+// LatencyP50 returns the rolling median health-probe latency for a provider;
+// ok is false when the provider is unknown or has no successful probe yet.
+func (hc *HealthChecker) LatencyP50(name string) (d time.Duration, ok bool) {
+	l, found := hc.latencies[name]
+	if !found {
+		return 0, false
+	}
+	return l.p50()
+}
+
+// sortByLatency stably reorders names by ascending P50 latency. Providers
+// without a latency estimate keep their relative order after the rest.
+func (hc *HealthChecker) sortByLatency(names []string) {
+	type entry struct {
+		d  time.Duration
+		ok bool
+	}
+	est := make(map[string]entry, len(names))
+	for _, name := range names {
+		d, ok := hc.LatencyP50(name)
+		est[name] = entry{d, ok}
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		ea, eb := est[a], est[b]
+		switch {
+		case ea.ok && eb.ok:
+			return cmp.Compare(ea.d, eb.d)
+		case ea.ok:
+			return -1
+		case eb.ok:
+			return 1
+		}
+		return 0
+	})
+}
+
+// Healthy reports whether provider name passed its latest health probe.
+// Providers not probed yet, or unknown to the checker, count as healthy.
+func (hc *HealthChecker) Healthy(name string) bool {
+	s, ok := hc.providerStatuses[name]
+	if !ok {
+		return true
+	}
+	st := s.get()
+	return st == "ok" || st == "unknown"
+}
+
+// deprioritizeUnhealthy stably moves the providers that failed their latest
+// health probe after the healthy ones. Nothing is dropped.
+func (hc *HealthChecker) deprioritizeUnhealthy(names []string) {
+	unhealthy := make(map[string]bool, len(names))
+	for _, name := range names {
+		unhealthy[name] = !hc.Healthy(name)
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		switch {
+		case unhealthy[a] == unhealthy[b]:
+			return 0
+		case unhealthy[a]:
+			return 1
+		}
+		return -1
+	})
+}
+
 // ReadinessOK returns true when the database and cache are reachable
 // (used by GET /readiness for Kubernetes probes).
 func (hc *HealthChecker) ReadinessOK() bool {
@@ -154,22 +344,10 @@ func (hc *HealthChecker) probe() {
 	// Provider probes — run in parallel.
 	var wg sync.WaitGroup
 	for name, prov := range hc.providers {
-		name, prov := name, prov
-		s := hc.providerStatuses[name]
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := prov.HealthCheck(ctx); err != nil {
-				s.set("degraded")
-				if hc.metrics != nil {
-					hc.metrics.SetProviderHealth(name, false)
-				}
-			} else {
-				s.set("ok")
-				if hc.metrics != nil {
-					hc.metrics.SetProviderHealth(name, true)
-				}
-			}
+			hc.probeProvider(ctx, name, prov)
 		}()
 	}
 
@@ -197,3 +375,24 @@ func (hc *HealthChecker) probe() {
 
 	wg.Wait()
 }
+
+// probeProvider runs one health probe for provider name and records the
+// result.
+func (hc *HealthChecker) probeProvider(ctx context.Context, name string, prov providers.Provider) {
+	s := hc.providerStatuses[name]
+	start := time.Now()
+	err := prov.HealthCheck(ctx)
+	took := time.Since(start)
+	if err != nil {
+		s.setProbe("degraded", took, err)
+		if hc.metrics != nil {
+			hc.metrics.SetProviderHealth(name, false)
+		}
+		return
+	}
+	hc.latencies[name].record(took)
+	s.setProbe("ok", took, nil)
+	if hc.metrics != nil {
+		hc.metrics.SetProviderHealth(name, true)
+	}
+}
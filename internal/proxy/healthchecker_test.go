@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 )
@@ -28,6 +29,26 @@ func (p *failingHealthProvider) HealthCheck(_ context.Context) error {
 	return fmt.Errorf("health check failed")
 }
 
+// slowHealthProvider answers health probes after delay and serves requests
+// with "from <name>", so routing tests can tell which provider was tried.
+type slowHealthProvider struct {
+	name  string
+	delay time.Duration
+}
+
+func (p *slowHealthProvider) Name() string { return p.name }
+func (p *slowHealthProvider) Request(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+	return &providers.ProxyResponse{ID: p.name, Content: "from " + p.name}, nil
+}
+func (p *slowHealthProvider) HealthCheck(ctx context.Context) error {
+	select {
+	case <-time.After(p.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // --- NewHealthChecker -------------------------------------------------------
 
 func TestNewHealthChecker_PanicsOnNilContext(t *testing.T) {
@@ -94,6 +115,32 @@ func TestSnapshot_DegradedProvider(t *testing.T) {
 	}
 }
 
+func TestSnapshot_ProviderDetails(t *testing.T) {
+	provs := map[string]providers.Provider{
+		"openai":    &healthyProvider{name: "openai"},
+		"anthropic": &failingHealthProvider{name: "anthropic"},
+	}
+	before := time.Now()
+	hc := NewHealthChecker(context.Background(), provs, nil, nil)
+	defer hc.Close()
+
+	snap := hc.Snapshot()
+	failing := snap.ProviderDetails["anthropic"]
+	if failing.Status != "degraded" || failing.LastError != "health check failed" {
+		t.Errorf("anthropic details = %+v, want degraded with last_error", failing)
+	}
+	if failing.LastChecked.Before(before) {
+		t.Errorf("anthropic last_checked = %v, want after %v", failing.LastChecked, before)
+	}
+	healthy := snap.ProviderDetails["openai"]
+	if healthy.Status != "ok" || healthy.LastError != "" || healthy.LastChecked.IsZero() {
+		t.Errorf("openai details = %+v, want ok without an error", healthy)
+	}
+	if snap.Status != "degraded" {
+		t.Errorf("expected status=degraded, got %s", snap.Status)
+	}
+}
+
 func TestSnapshot_CacheDegraded(t *testing.T) {
 	provs := map[string]providers.Provider{
 		"openai": &healthyProvider{name: "openai"},
@@ -140,6 +187,60 @@ func TestSnapshot_DBDown(t *testing.T) {
 	}
 }
 
+func TestSnapshot_LatencyP50(t *testing.T) {
+	provs := map[string]providers.Provider{
+		"openai":    &slowHealthProvider{name: "openai", delay: 20 * time.Millisecond},
+		"anthropic": &failingHealthProvider{name: "anthropic"},
+	}
+	hc := NewHealthChecker(context.Background(), provs, nil, nil)
+	defer hc.Close()
+
+	snap := hc.Snapshot()
+	if snap.LatencyP50Ms["openai"] < 20 {
+		t.Errorf("expected openai p50 ≥ 20ms, got %d", snap.LatencyP50Ms["openai"])
+	}
+	if _, ok := snap.LatencyP50Ms["anthropic"]; ok {
+		t.Error("failed probes should not produce a latency estimate")
+	}
+}
+
+// --- Latency ----------------------------------------------------------------
+
+func TestLatencyTracker_RollingMedian(t *testing.T) {
+	var l latencyTracker
+	if _, ok := l.p50(); ok {
+		t.Fatal("expected no estimate before any sample")
+	}
+	for _, ms := range []int{5, 100, 10} {
+		l.record(time.Duration(ms) * time.Millisecond)
+	}
+	if d, _ := l.p50(); d != 10*time.Millisecond {
+		t.Errorf("p50 = %v, want 10ms", d)
+	}
+
+	// Old samples roll out of the window.
+	for i := 0; i < latencyWindow; i++ {
+		l.record(time.Second)
+	}
+	if d, _ := l.p50(); d != time.Second {
+		t.Errorf("p50 = %v, want 1s after the window rolled over", d)
+	}
+}
+
+func TestSortByLatency_UnknownLast(t *testing.T) {
+	hc := &HealthChecker{latencies: map[string]*latencyTracker{
+		"slow": {}, "fast": {}, "unprobed": {},
+	}}
+	hc.latencies["slow"].record(300 * time.Millisecond)
+	hc.latencies["fast"].record(10 * time.Millisecond)
+
+	names := []string{"unprobed", "slow", "custom", "fast"}
+	hc.sortByLatency(names)
+	if fmt.Sprint(names) != "[fast slow unprobed custom]" {
+		t.Errorf("sorted = %v, want [fast slow unprobed custom]", names)
+	}
+}
+
 // --- ReadinessOK ------------------------------------------------------------
 
 func TestReadinessOK_DBUp(t *testing.T) {
@@ -201,3 +302,23 @@ func TestHealthChecker_Close(t *testing.T) {
 	// Close should not hang.
 	hc.Close()
 }
+
+func TestDeprioritizeUnhealthy_KeepsOrder(t *testing.T) {
+	provs := map[string]providers.Provider{
+		"openai":    &failingHealthProvider{name: "openai"},
+		"anthropic": &healthyProvider{name: "anthropic"},
+		"gemini":    &failingHealthProvider{name: "gemini"},
+		"mistral":   &healthyProvider{name: "mistral"},
+	}
+	hc := NewHealthChecker(context.Background(), provs, nil, nil)
+	defer hc.Close()
+
+	names := []string{"openai", "anthropic", "gemini", "unknown", "mistral"}
+	hc.deprioritizeUnhealthy(names)
+	want := []string{"anthropic", "unknown", "mistral", "openai", "gemini"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("order = %v, want %v", names, want)
+		}
+	}
+}
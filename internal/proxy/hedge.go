@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+)
+
+// hedgeCandidate returns the provider to race against current if current is
+// slow, or "" when the attempt should not be hedged. Only non-streaming
+// requests are hedged, and only between providers whose circuit breakers
+// are closed, so a cancelled attempt never strands a half-open probe.
+func (g *Gateway) hedgeCandidate(req *providers.ProxyRequest, current string, rest []string) string {
+	if g.hedgeAfter <= 0 || req.Stream {
+		return ""
+	}
+	if g.cb != nil && g.cb.State(g.cb.Key(current, req.Model)) != cbClosed {
+		return ""
+	}
+	for _, name := range rest {
+		if _, ok := g.providers[name]; !ok {
+			continue
+		}
+		if g.cb != nil && g.cb.State(g.cb.Key(name, req.Model)) != cbClosed {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// hedgedRequest sends req to first and, if it has not answered within
+// g.hedgeAfter, also to second, returning whichever succeeds first. The
+// losing attempt's context is cancelled. When the first finisher fails its
+// failure is recorded here and the other attempt's result is returned.
+//
+// It returns the provider whose result is returned, that result, its
+// duration, and whether second was launched.
+func (g *Gateway) hedgedRequest(
+	ctx context.Context,
+	req *providers.ProxyRequest,
+	primary, route, first, second string,
+) (string, *providers.ProxyResponse, error, time.Duration, bool) {
+	type result struct {
+		name string
+		resp *providers.ProxyResponse
+		err  error
+		dur  time.Duration
+	}
+	results := make(chan result, 2)
+	cancels := make(map[string]context.CancelFunc, 2)
+	starts := make(map[string]time.Time, 2)
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	launch := func(name string) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[name] = cancel
+		attemptCtx, span := g.startSpan(attemptCtx, "provider.attempt",
+			tracing.String(attrProvider, name), tracing.Int(attrAttempt, len(starts)+1),
+			tracing.Bool("gateway.hedge", len(starts) > 0))
+		start := time.Now()
+		starts[name] = start
+		prov := g.providers[name]
+		go func() {
+			resp, err := prov.Request(attemptCtx, req)
+			endAttemptSpan(span, err)
+			results <- result{name: name, resp: resp, err: err, dur: time.Since(start)}
+		}()
+	}
+
+	launch(first)
+	timer := time.NewTimer(g.hedgeAfter)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.name, r.resp, r.err, r.dur, false
+	case <-timer.C:
+	}
+
+	if g.cb != nil && !g.cb.Allow(g.cb.Key(second, req.Model)) {
+		r := <-results
+		return r.name, r.resp, r.err, r.dur, false
+	}
+	g.log.InfoContext(ctx, "hedged_request",
+		slog.String("request_id", req.RequestID),
+		slog.String("from", first),
+		slog.String("to", second),
+		slog.Duration("after", g.hedgeAfter),
+	)
+	launch(second)
+
+	r := <-results
+	if r.err != nil {
+		reason := g.recordAttemptFailure(ctx, req, primary, route, r.name, r.err, r.dur)
+		if g.metrics != nil {
+			other := first
+			if r.name == first {
+				other = second
+			}
+			g.metrics.RecordFailover(primary, r.name, other, reason)
+		}
+		r = <-results
+		winner := r.name
+		if r.err != nil {
+			winner = "none"
+		}
+		if g.metrics != nil {
+			g.metrics.RecordHedge(first, second, winner)
+		}
+		return r.name, r.resp, r.err, r.dur, true
+	}
+
+	loser := first
+	if r.name == first {
+		loser = second
+	}
+	cancels[loser]()
+	if g.metrics != nil {
+		g.metrics.ObserveUpstreamAttempt(loser, route, "hedge_cancelled", time.Since(starts[loser]))
+		g.metrics.RecordHedge(first, second, r.name)
+	}
+	return r.name, r.resp, r.err, r.dur, true
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestRequestWithFailover_HedgeFastFallbackWins(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := &funcProvider{
+		name: "openai",
+		requestFn: func(ctx context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		},
+	}
+	fast := &funcProvider{
+		name: "anthropic",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return &providers.ProxyResponse{ID: "fast", Model: req.Model, Content: "from anthropic"}, nil
+		},
+	}
+
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    slow,
+		"anthropic": fast,
+	}, nil, nil, GatewayOptions{HedgeAfter: 20 * time.Millisecond})
+
+	req := &providers.ProxyRequest{
+		Model:     "gpt-4o",
+		Messages:  []providers.Message{{Role: "user", Content: "hi"}},
+		RequestID: "hedge",
+	}
+
+	resp, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usedProv != "anthropic" || resp.Content != "from anthropic" {
+		t.Errorf("expected the hedge to win, got %s (%q)", usedProv, resp.Content)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow primary's context was not cancelled")
+	}
+	if gw.cb.State("openai") != cbClosed {
+		t.Error("a cancelled hedge loser must not count as a provider failure")
+	}
+}
+
+func TestRequestWithFailover_NoHedgeWhenPrimaryFast(t *testing.T) {
+	var hedgeCalls int32
+	primary := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return &providers.ProxyResponse{ID: "ok", Model: req.Model, Content: "from openai"}, nil
+		},
+	}
+	fallback := &funcProvider{
+		name: "anthropic",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			atomic.AddInt32(&hedgeCalls, 1)
+			return &providers.ProxyResponse{ID: "hedge", Model: req.Model}, nil
+		},
+	}
+
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    primary,
+		"anthropic": fallback,
+	}, nil, nil, GatewayOptions{HedgeAfter: time.Second})
+
+	req := &providers.ProxyRequest{Model: "gpt-4o", RequestID: "no-hedge"}
+	_, usedProv, _, err := gw.requestWithFailover(context.Background(), req, "openai", "chat_completions")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usedProv != "openai" {
+		t.Errorf("expected openai, got %s", usedProv)
+	}
+	if n := atomic.LoadInt32(&hedgeCalls); n != 0 {
+		t.Errorf("hedge should not start when the primary answers in time, got %d calls", n)
+	}
+}
+
+func TestHedgeCandidate_SkipsStreaming(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    okProvider("openai"),
+		"anthropic": okProvider("anthropic"),
+	}, nil, nil, GatewayOptions{HedgeAfter: time.Millisecond})
+
+	if got := gw.hedgeCandidate(&providers.ProxyRequest{Stream: true}, "openai", []string{"anthropic"}); got != "" {
+		t.Errorf("streaming requests must not be hedged, got %q", got)
+	}
+	if got := gw.hedgeCandidate(&providers.ProxyRequest{}, "openai", []string{"gemini", "anthropic"}); got != "anthropic" {
+		t.Errorf("expected the next configured provider, got %q", got)
+	}
+}
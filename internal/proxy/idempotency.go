@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// idempotencyKeyHeader carries the client's idempotency key.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed for a repeated key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen bounds the key so it cannot bloat the store.
+	maxIdempotencyKeyLen = 255
+	// idempotencyKeyPrefix namespaces stored entries apart from cached
+	// responses ("cache:…") when both share a Redis database.
+	idempotencyKeyPrefix = "idem:"
+)
+
+// idempotencyReplayHeaders are the response headers stored with an entry
+// and restored on replay, so a replay reports who served the original.
+var idempotencyReplayHeaders = []string{"X-Provider", "X-Model"}
+
+// idempotencyStore remembers the responses to requests sent with an
+// Idempotency-Key, separately from the response cache: a key is replayed
+// regardless of cache settings and only to the caller that sent it.
+type idempotencyStore struct {
+	store cache.Cache
+	ttl   time.Duration
+
+	// inFlight holds keys whose first request is still being served, so a
+	// concurrent retry is refused instead of reaching the provider twice.
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+// idempotencyEntry is a stored response.
+type idempotencyEntry struct {
+	// BodyHash is the digest of the request body the key was first used with.
+	BodyHash    string `json:"body_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	// Headers holds the idempotencyReplayHeaders the response set.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// SetIdempotencyStore enables Idempotency-Key handling on the POST routes:
+// a successful non-streaming response is kept in store for ttl and replayed
+// when the same caller repeats the key with the same body.
+func (g *Gateway) SetIdempotencyStore(store cache.Cache, ttl time.Duration) {
+	g.idempotency = &idempotencyStore{
+		store:    store,
+		ttl:      ttl,
+		inFlight: make(map[string]struct{}),
+	}
+}
+
+func (s *idempotencyStore) begin(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.inFlight[key]; busy {
+		return false
+	}
+	s.inFlight[key] = struct{}{}
+	return true
+}
+
+func (s *idempotencyStore) end(key string) {
+	s.mu.Lock()
+	delete(s.inFlight, key)
+	s.mu.Unlock()
+}
+
+// withIdempotency serves ctx with next, replaying the stored response when
+// the request repeats an Idempotency-Key. Reusing a key with a different
+// body, or while its first request is in flight, gets 409. Requests without
+// a key, streaming requests and gateways without a store go straight to next.
+func (g *Gateway) withIdempotency(ctx *fasthttp.RequestCtx, next fasthttp.RequestHandler) {
+	key := strings.TrimSpace(string(ctx.Request.Header.Peek(idempotencyKeyHeader)))
+	if g.idempotency == nil || key == "" || isStreamRequest(ctx.PostBody()) {
+		next(ctx)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	s := g.idempotency
+	storeKey := g.keyNamespace + idempotencyKeyPrefix + hashKey(callerID(ctx)+"\x00"+string(ctx.Path())+"\x00"+key)
+	bodyHash := hashKey(string(ctx.PostBody()))
+
+	if raw, ok := s.store.Get(ctx, storeKey); ok {
+		var e idempotencyEntry
+		if err := json.Unmarshal(raw, &e); err == nil {
+			if e.BodyHash != bodyHash {
+				apierr.Write(ctx, fasthttp.StatusConflict,
+					fmt.Sprintf("%s was already used with a different request body", idempotencyKeyHeader),
+					apierr.TypeInvalidRequest, apierr.CodeIdempotencyConflict)
+				return
+			}
+			ctx.SetStatusCode(e.Status)
+			ctx.SetContentType(e.ContentType)
+			for name, value := range e.Headers {
+				ctx.Response.Header.Set(name, value)
+			}
+			ctx.Response.Header.Set(idempotentReplayedHeader, "true")
+			ctx.SetBody(e.Body)
+			return
+		}
+	}
+
+	if !s.begin(storeKey) {
+		apierr.Write(ctx, fasthttp.StatusConflict,
+			fmt.Sprintf("a request with this %s is already in progress", idempotencyKeyHeader),
+			apierr.TypeInvalidRequest, apierr.CodeIdempotencyConflict)
+		return
+	}
+	defer s.end(storeKey)
+
+	next(ctx)
+
+	status := ctx.Response.StatusCode()
+	if status < 200 || status >= 300 || ctx.Response.IsBodyStream() {
+		return
+	}
+	var headers map[string]string
+	for _, name := range idempotencyReplayHeaders {
+		if v := ctx.Response.Header.Peek(name); len(v) > 0 {
+			if headers == nil {
+				headers = make(map[string]string, len(idempotencyReplayHeaders))
+			}
+			headers[name] = string(v)
+		}
+	}
+	data, err := json.Marshal(idempotencyEntry{
+		BodyHash:    bodyHash,
+		Status:      status,
+		ContentType: string(ctx.Response.Header.ContentType()),
+		Body:        ctx.Response.Body(),
+		Headers:     headers,
+	})
+	if err != nil {
+		return
+	}
+	_ = s.store.Set(ctx, storeKey, data, s.ttl)
+}
+
+// isStreamRequest reports whether a POST body asks for a streaming response.
+func isStreamRequest(body []byte) bool {
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &req) == nil && req.Stream
+}
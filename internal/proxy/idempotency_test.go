@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// idempotentGateway returns a gateway with an idempotency store whose
+// provider counts its calls, served through the full handlers.
+func idempotentGateway(t *testing.T, requestFn func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error)) (*http.Client, *atomic.Int32) {
+	t.Helper()
+	calls := new(atomic.Int32)
+	prov := &funcProvider{
+		name: "openai",
+		requestFn: func(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls.Add(1)
+			return requestFn(ctx, req)
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+	t.Cleanup(gw.health.Close)
+	gw.SetIdempotencyStore(newStubCache(), time.Minute)
+
+	client, cleanup := serveRouter(t, gw)
+	t.Cleanup(cleanup)
+	return client, calls
+}
+
+// postWithIdempotencyKey posts body to the chat route with the given key.
+func postWithIdempotencyKey(t *testing.T, client *http.Client, key, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://test/v1/chat/completions", bReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyKeyHeader, key)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, readBody(t, resp)
+}
+
+const idempotentBody = `{"model":"gpt-4o","messages":[{"role":"user","content":"charge it"}]}`
+
+func TestIdempotency_ReplaysRepeatedKey(t *testing.T) {
+	client, calls := idempotentGateway(t, okProvider("openai").requestFn)
+
+	first, firstBody := postWithIdempotencyKey(t, client, "key-1", idempotentBody)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first status = %d: %s", first.StatusCode, firstBody)
+	}
+	if first.Header.Get(idempotentReplayedHeader) != "" {
+		t.Error("first response must not be marked as replayed")
+	}
+
+	second, secondBody := postWithIdempotencyKey(t, client, "key-1", idempotentBody)
+	if second.StatusCode != http.StatusOK {
+		t.Fatalf("replay status = %d: %s", second.StatusCode, secondBody)
+	}
+	if second.Header.Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("%s = %q, want true", idempotentReplayedHeader, second.Header.Get(idempotentReplayedHeader))
+	}
+	if string(secondBody) != string(firstBody) {
+		t.Errorf("replayed body = %s, want %s", secondBody, firstBody)
+	}
+	if second.Header.Get("Content-Type") != first.Header.Get("Content-Type") {
+		t.Errorf("replayed Content-Type = %q", second.Header.Get("Content-Type"))
+	}
+	for _, h := range []string{"X-Provider", "X-Model"} {
+		if first.Header.Get(h) == "" || second.Header.Get(h) != first.Header.Get(h) {
+			t.Errorf("replayed %s = %q, want %q", h, second.Header.Get(h), first.Header.Get(h))
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+
+	// A different key is a different request.
+	postWithIdempotencyKey(t, client, "key-2", idempotentBody)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("provider called %d times after a new key, want 2", got)
+	}
+}
+
+func TestIdempotency_KeyReusedWithDifferentBody(t *testing.T) {
+	client, calls := idempotentGateway(t, okProvider("openai").requestFn)
+
+	postWithIdempotencyKey(t, client, "key-1", idempotentBody)
+	resp, body := postWithIdempotencyKey(t, client, "key-1",
+		`{"model":"gpt-4o","messages":[{"role":"user","content":"charge it twice"}]}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", resp.StatusCode, body)
+	}
+	if !containsStr(string(body), "idempotency_conflict") {
+		t.Errorf("body = %s, want an idempotency_conflict error", body)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
+
+func TestIdempotency_FailuresAreNotStored(t *testing.T) {
+	client, calls := idempotentGateway(t, func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+		return nil, &providerError{status: 400, msg: "bad request"}
+	})
+
+	for range 2 {
+		resp, body := postWithIdempotencyKey(t, client, "key-1", idempotentBody)
+		if resp.StatusCode == http.StatusOK || resp.Header.Get(idempotentReplayedHeader) != "" {
+			t.Fatalf("status = %d, replayed = %q: %s", resp.StatusCode, resp.Header.Get(idempotentReplayedHeader), body)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("provider called %d times, want 2 (errors are retried)", got)
+	}
+}
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"bytes"
 	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,21 +79,40 @@ func securityHeaders(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 // corsHandler returns a CORS middleware configured for the given allowed origins.
 //
 //   - nil or []string{"*"} → Access-Control-Allow-Origin: *  (open)
-//   - specific origins      → joined with ", "  (strict allowlist)
+//   - specific origins      → the request's Origin is echoed back when it is
+//     in the allowlist (with Vary: Origin); other origins get no
+//     Access-Control-Allow-Origin and their preflights are refused with 403.
 //
 // OPTIONS preflight requests are answered with 204 No Content and no body.
 func corsHandler(origins []string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
-	origin := "*"
-	if len(origins) > 0 && !(len(origins) == 1 && origins[0] == "*") {
-		origin = strings.Join(origins, ", ")
+	wildcard := len(origins) == 0 || (len(origins) == 1 && origins[0] == "*")
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
 	}
 	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 		return func(ctx *fasthttp.RequestCtx) {
-			ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+			preflight := string(ctx.Method()) == fasthttp.MethodOptions
+			if wildcard {
+				ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// The response depends on Origin, so shared caches must key on it.
+				ctx.Response.Header.Add("Vary", "Origin")
+				origin := string(ctx.Request.Header.Peek("Origin"))
+				if !allowed[origin] {
+					if preflight {
+						ctx.SetStatusCode(fasthttp.StatusForbidden)
+						return
+					}
+					next(ctx)
+					return
+				}
+				ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+			}
 			ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 			ctx.Response.Header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-ID")
 
-			if string(ctx.Method()) == fasthttp.MethodOptions {
+			if preflight {
 				ctx.SetStatusCode(fasthttp.StatusNoContent)
 				return
 			}
@@ -100,6 +121,74 @@ func corsHandler(origins []string) func(fasthttp.RequestHandler) fasthttp.Reques
 	}
 }
 
+// compressResponse returns a middleware that gzip- or deflate-encodes
+// response bodies of at least minBytes when the request's Accept-Encoding
+// allows it. Streamed bodies, Server-Sent Events and bodies that already
+// carry a Content-Encoding are left untouched.
+func compressResponse(minBytes int) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			next(ctx)
+
+			resp := &ctx.Response
+			if resp.IsBodyStream() || len(resp.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 ||
+				bytes.HasPrefix(resp.Header.ContentType(), []byte("text/event-stream")) ||
+				len(resp.Body()) < minBytes {
+				return
+			}
+			// Whether the body is encoded now depends on Accept-Encoding.
+			resp.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderAcceptEncoding)
+
+			encoding := negotiateEncoding(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)))
+			var encoded []byte
+			switch encoding {
+			case "gzip":
+				encoded = fasthttp.AppendGzipBytes(nil, resp.Body())
+			case "deflate":
+				encoded = fasthttp.AppendDeflateBytes(nil, resp.Body())
+			default:
+				return
+			}
+			resp.SetBody(encoded)
+			resp.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+		}
+	}
+}
+
+// negotiateEncoding picks the response encoding for an Accept-Encoding
+// header: "gzip" or "deflate", whichever has the higher q-value (gzip on a
+// tie), or "" when neither is acceptable. "*" stands in for codings not
+// listed explicitly, and q=0 rules a coding out.
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"gzip", "deflate"} {
+		q, ok := weights[enc]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
 // applyMiddleware wraps h with the given middleware chain. The first middleware
 // in the slice becomes the outermost wrapper (executes first on request,
 // last on response). This matches the conventional "left-to-right" ordering:
@@ -1,6 +1,12 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/valyala/fasthttp"
@@ -166,14 +172,48 @@ func TestCORS_SpecificOrigins(t *testing.T) {
 		ctx.SetStatusCode(fasthttp.StatusOK)
 	})
 
+	for _, origin := range origins {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.Header.Set("Origin", origin)
+		handler(ctx)
+
+		if got := string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")); got != origin {
+			t.Errorf("expected %q, got %q", origin, got)
+		}
+		if got := string(ctx.Response.Header.Peek("Vary")); got != "Origin" {
+			t.Errorf("expected Vary: Origin, got %q", got)
+		}
+	}
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	handler := corsHandler([]string{"https://app.nulpoint.com"})(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	})
+
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("Origin", "https://evil.example.com")
 	handler(ctx)
 
-	got := string(ctx.Response.Header.Peek("Access-Control-Allow-Origin"))
-	expected := "https://app.nulpoint.com, https://dashboard.nulpoint.com"
-	if got != expected {
-		t.Errorf("expected %q, got %q", expected, got)
+	if got := ctx.Response.Header.Peek("Access-Control-Allow-Origin"); got != nil {
+		t.Errorf("disallowed origin should get no ACAO header, got %q", got)
+	}
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("non-preflight request should still be served, got %d", ctx.Response.StatusCode())
+	}
+
+	// Preflights from a disallowed origin are refused.
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("OPTIONS")
+	ctx.Request.Header.Set("Origin", "https://evil.example.com")
+	handler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("disallowed preflight status = %d, want 403", ctx.Response.StatusCode())
+	}
+	if got := ctx.Response.Header.Peek("Access-Control-Allow-Origin"); got != nil {
+		t.Errorf("disallowed preflight should get no ACAO header, got %q", got)
 	}
 }
 
@@ -229,6 +269,122 @@ func TestCORS_AllowedMethods(t *testing.T) {
 	}
 }
 
+// --- compressResponse middleware -------------------------------------------
+
+func TestCompressResponse(t *testing.T) {
+	largeJSON := `{"data":[` + strings.Repeat(`{"embedding":[0.1,0.2,0.3]},`, 100) + `{}]}`
+	jsonHandler := func(body string) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("application/json")
+			ctx.SetBodyString(body)
+		}
+	}
+	run := func(h fasthttp.RequestHandler, acceptEncoding string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		if acceptEncoding != "" {
+			ctx.Request.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		compressResponse(1024)(h)(ctx)
+		return ctx
+	}
+
+	t.Run("gzip when accepted", func(t *testing.T) {
+		ctx := run(jsonHandler(largeJSON), "gzip, deflate, br")
+		if got := string(ctx.Response.Header.Peek("Content-Encoding")); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		if got := string(ctx.Response.Header.Peek("Vary")); got != "Accept-Encoding" {
+			t.Errorf("Vary = %q", got)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(ctx.Response.Body()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := io.ReadAll(zr)
+		if err != nil || string(plain) != largeJSON {
+			t.Errorf("decoded body mismatch (err=%v)", err)
+		}
+		if len(ctx.Response.Body()) >= len(largeJSON) {
+			t.Errorf("compressed body is %d bytes, original %d", len(ctx.Response.Body()), len(largeJSON))
+		}
+	})
+
+	t.Run("deflate when only deflate is accepted", func(t *testing.T) {
+		ctx := run(jsonHandler(largeJSON), "deflate")
+		if got := string(ctx.Response.Header.Peek("Content-Encoding")); got != "deflate" {
+			t.Fatalf("Content-Encoding = %q, want deflate", got)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(ctx.Response.Body()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, _ := io.ReadAll(zr); string(plain) != largeJSON {
+			t.Error("decoded body mismatch")
+		}
+	})
+
+	t.Run("untouched without Accept-Encoding", func(t *testing.T) {
+		ctx := run(jsonHandler(largeJSON), "")
+		if got := ctx.Response.Header.Peek("Content-Encoding"); len(got) != 0 {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+		if string(ctx.Response.Body()) != largeJSON {
+			t.Error("body should be left as is")
+		}
+	})
+
+	t.Run("small bodies are not compressed", func(t *testing.T) {
+		ctx := run(jsonHandler(`{"status":"ok"}`), "gzip")
+		if got := ctx.Response.Header.Peek("Content-Encoding"); len(got) != 0 {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+	})
+
+	t.Run("event streams are never compressed", func(t *testing.T) {
+		ctx := run(func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("text/event-stream")
+			ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+				_, _ = w.WriteString("data: " + largeJSON + "\n\n")
+			})
+		}, "gzip")
+		if got := ctx.Response.Header.Peek("Content-Encoding"); len(got) != 0 {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+
+		// A replayed (buffered) stream is not compressed either.
+		ctx = run(func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("text/event-stream")
+			ctx.SetBodyString("data: " + largeJSON + "\n\n")
+		}, "gzip")
+		if got := ctx.Response.Header.Peek("Content-Encoding"); len(got) != 0 {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+	})
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"br", ""},
+		{"*", "gzip"},
+		{"*;q=0.1, deflate;q=0.5", "deflate"},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
 // --- applyMiddleware --------------------------------------------------------
 
 func TestApplyMiddleware_Order(t *testing.T) {
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// moderate screens the user-supplied text of req with the moderation
+// provider and rejects flagged prompts with 400 content_filter. It reports
+// whether the request may proceed. When the moderation call itself fails
+// the request proceeds, unless GatewayOptions.ModerationFailClosed is set,
+// in which case it is rejected with 503. Moderation is off unless
+// GatewayOptions.ModerationProvider names a provider that supports it.
+func (g *Gateway) moderate(ctx *fasthttp.RequestCtx, tctx context.Context, req *providers.ProxyRequest) bool {
+	if g.moderator == nil {
+		return true
+	}
+	input := moderationInput(req)
+	if len(input) == 0 {
+		return true
+	}
+
+	mctx, cancel := context.WithTimeout(tctx, g.providerTimeout)
+	defer cancel()
+	mctx, span := g.startSpan(mctx, "moderation")
+	defer span.End()
+
+	res, err := g.moderator.Moderate(mctx, &providers.ModerationRequest{
+		Input:     input,
+		Model:     g.moderationModel,
+		RequestID: req.RequestID,
+	})
+	if err != nil {
+		span.RecordError(err)
+		g.log.WarnContext(ctx, "moderation_failed",
+			slog.String("request_id", req.RequestID),
+			slog.Bool("fail_closed", g.moderationFailClosed),
+			slog.String("error", err.Error()),
+		)
+		if !g.moderationFailClosed {
+			return true
+		}
+		apierr.Write(ctx, fasthttp.StatusServiceUnavailable,
+			"content moderation is unavailable, retry later",
+			apierr.TypeServerError, apierr.CodeProviderUnavailable)
+		return false
+	}
+	span.SetAttributes(tracing.Bool("moderation.flagged", res.Flagged))
+	if !res.Flagged {
+		return true
+	}
+
+	g.log.WarnContext(ctx, "moderation_flagged",
+		slog.String("request_id", req.RequestID),
+		slog.String("model", req.Model),
+		slog.Any("categories", res.Categories),
+	)
+	msg := "the prompt was flagged by content moderation"
+	if len(res.Categories) > 0 {
+		msg += ": " + strings.Join(res.Categories, ", ")
+	}
+	apierr.Write(ctx, fasthttp.StatusBadRequest, msg,
+		apierr.TypeInvalidRequest, apierr.CodeContentFilter)
+	return false
+}
+
+// moderationInput collects the text of the user messages in req, the part
+// of the prompt a client controls. Images and assistant turns are skipped.
+func moderationInput(req *providers.ProxyRequest) []string {
+	var input []string
+	for _, m := range req.Messages {
+		if m.Role != "user" {
+			continue
+		}
+		if m.Content != "" {
+			input = append(input, m.Content)
+		}
+		for _, p := range m.Parts {
+			if p.Type == providers.ContentPartText && p.Text != "" {
+				input = append(input, p.Text)
+			}
+		}
+	}
+	return input
+}
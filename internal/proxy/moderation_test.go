@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// moderatingProvider serves chat like funcProvider and flags any input
+// containing "forbidden". A non-nil err fails every moderation call.
+type moderatingProvider struct {
+	funcProvider
+	inputs [][]string
+	err    error
+}
+
+func (m *moderatingProvider) Moderate(_ context.Context, req *providers.ModerationRequest) (*providers.ModerationResponse, error) {
+	m.inputs = append(m.inputs, req.Input)
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, in := range req.Input {
+		if strings.Contains(in, "forbidden") {
+			return &providers.ModerationResponse{Flagged: true, Categories: []string{"violence"}}, nil
+		}
+	}
+	return &providers.ModerationResponse{}, nil
+}
+
+func newModerationGateway(t *testing.T, mod *moderatingProvider, failClosed bool) (*Gateway, *int) {
+	t.Helper()
+	calls := 0
+	chat := &funcProvider{
+		name: "openai",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			calls++
+			return &providers.ProxyResponse{Model: req.Model, Content: "ok"}, nil
+		},
+	}
+	mod.funcProvider = funcProvider{name: "moderator"}
+	gw := NewGatewayWithOptions(context.Background(),
+		map[string]providers.Provider{"openai": chat, "moderator": mod}, nil, nil,
+		GatewayOptions{ModerationProvider: "moderator", ModerationFailClosed: failClosed})
+	t.Cleanup(gw.health.Close)
+	return gw, &calls
+}
+
+func TestDispatchChat_Moderation(t *testing.T) {
+	mod := &moderatingProvider{}
+	gw, calls := newModerationGateway(t, mod, false)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"something forbidden"}]}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), `"code":"content_filter"`) {
+		t.Fatalf("flagged prompt: status = %d, body = %s; want 400 content_filter", resp.StatusCode, body)
+	}
+	if !strings.Contains(string(body), "violence") {
+		t.Errorf("flagged prompt: body %s does not name the category", body)
+	}
+	if *calls != 0 {
+		t.Errorf("flagged prompt reached the provider %d times", *calls)
+	}
+
+	resp = doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"system","content":"be nice"},{"role":"user","content":"hello"}]}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Fatalf("clean prompt: status = %d, body = %s", resp.StatusCode, body)
+	}
+	if *calls != 1 {
+		t.Errorf("clean prompt: provider calls = %d, want 1", *calls)
+	}
+	if got := mod.inputs[len(mod.inputs)-1]; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("moderation input = %q, want only the user message", got)
+	}
+}
+
+func TestDispatchChat_ModerationFailure(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`)
+	tests := []struct {
+		name       string
+		failClosed bool
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "fail open", failClosed: false, wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "fail closed", failClosed: true, wantStatus: http.StatusServiceUnavailable, wantCalls: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, calls := newModerationGateway(t, &moderatingProvider{err: errors.New("moderation down")}, tt.failClosed)
+			client, cleanup := serveGateway(t, gw)
+			defer cleanup()
+
+			resp := doPost(t, client, "/v1/chat/completions", body)
+			if b := readBody(t, resp); resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, body = %s; want %d", resp.StatusCode, b, tt.wantStatus)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
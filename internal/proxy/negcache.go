@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// defaultErrorCacheTTL is how long a negatively cached provider error is
+// replayed when GatewayOptions.ErrorCacheTTL is unset.
+const defaultErrorCacheTTL = time.Minute
+
+// negativeEntryPrefix marks a cached provider error. Entries share the key
+// space of successful responses (see buildCacheKey), so the prefix — which can
+// never start a JSON document — tells the two apart with a single lookup.
+//
+// Layout: prefix + 3-digit HTTP status + response body.
+var negativeEntryPrefix = []byte("\x00err:")
+
+// encodeNegativeEntry packs the status and body written to the client into a
+// cache value.
+func encodeNegativeEntry(status int, body []byte) []byte {
+	out := make([]byte, 0, len(negativeEntryPrefix)+3+len(body))
+	out = append(out, negativeEntryPrefix...)
+	out = strconv.AppendInt(out, int64(status), 10)
+	return append(out, body...)
+}
+
+// decodeNegativeEntry unpacks a value produced by encodeNegativeEntry.
+// ok is false for ordinary cached responses.
+func decodeNegativeEntry(v []byte) (status int, body []byte, ok bool) {
+	if !bytes.HasPrefix(v, negativeEntryPrefix) {
+		return 0, nil, false
+	}
+	rest := v[len(negativeEntryPrefix):]
+	if len(rest) < 3 {
+		return 0, nil, false
+	}
+	status, err := strconv.Atoi(string(rest[:3]))
+	if err != nil {
+		return 0, nil, false
+	}
+	return status, rest[3:], true
+}
+
+// isNegativelyCacheable reports whether a provider error is deterministic
+// enough to replay from cache: a 4xx that will not change on retry.
+//
+//	401, 403      → never (credentials may be rotated or fixed at any time)
+//	408, 429      → never (transient by definition)
+//	other 4xx     → cacheable (malformed request, unknown model, …)
+//	5xx, timeouts → never (infrastructure failures)
+func isNegativelyCacheable(err error) bool {
+	var sc providers.StatusCoder
+	if !errors.As(err, &sc) {
+		return false
+	}
+	switch status := sc.HTTPStatus(); status {
+	case 401, 403, 408, 429:
+		return false
+	default:
+		return status >= 400 && status < 500
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+)
+
+func TestNegativeEntry_RoundTrip(t *testing.T) {
+	body := []byte(`{"error":{"message":"bad request"}}`)
+	entry := encodeNegativeEntry(400, body)
+
+	status, got, ok := decodeNegativeEntry(entry)
+	if !ok {
+		t.Fatal("expected entry to decode as negative")
+	}
+	if status != 400 {
+		t.Errorf("status = %d, want 400", status)
+	}
+	if string(got) != string(body) {
+		t.Errorf("body = %s, want %s", got, body)
+	}
+}
+
+func TestNegativeEntry_OrdinaryResponse(t *testing.T) {
+	if _, _, ok := decodeNegativeEntry([]byte(`{"id":"chatcmpl-1"}`)); ok {
+		t.Error("ordinary response must not decode as negative entry")
+	}
+}
+
+func TestIsNegativelyCacheable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&providerError{status: 400}, true},
+		{&providerError{status: 404}, true},
+		{&providerError{status: 422}, true},
+		{&providerError{status: 401}, false},
+		{&providerError{status: 403}, false},
+		{&providerError{status: 408}, false},
+		{&providerError{status: 429}, false},
+		{&providerError{status: 500}, false},
+		{&providerError{status: 503}, false},
+		{fmt.Errorf("failover: %w", &providerError{status: 400}), true},
+		{errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := isNegativelyCacheable(tt.err); got != tt.want {
+			t.Errorf("isNegativelyCacheable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNegativeEntry_CompressingCacheRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	mem := cache.NewMemoryCache(ctx)
+	defer mem.Close()
+	c := cache.NewCompressingCache(mem, 64)
+
+	small := encodeNegativeEntry(400, []byte(`{"error":{"message":"bad request"}}`))
+	large := encodeNegativeEntry(422, []byte(`{"error":{"message":"`+strings.Repeat("invalid ", 100)+`"}}`))
+	for name, entry := range map[string][]byte{"below threshold": small, "compressed": large} {
+		if err := c.Set(ctx, name, entry, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		got, _, ok := c.GetWithTTL(ctx, name)
+		if !ok {
+			t.Fatalf("%s: negative entry read back as a miss", name)
+		}
+		if _, _, ok := decodeNegativeEntry(got); !ok || !bytes.Equal(got, entry) {
+			t.Errorf("%s: got %q, want the original entry", name, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+	"github.com/valyala/fasthttp"
+)
+
+// webhookEventKey is the user value holding a request's *webhookEvent.
+const webhookEventKey = "webhook_event"
+
+// webhookEvent is what the webhook reports about one request. Handlers fill
+// it in as they learn the provider, model and usage; notifyWebhook sends it
+// once the request completes, so requests rejected before reaching a
+// provider are reported too.
+type webhookEvent struct {
+	entry logger.RequestLog
+	// streaming hands the notification to the stream writer, which sends it
+	// once the stream drains.
+	streaming bool
+}
+
+// requestWebhookEvent returns the request's webhook event, or nil when
+// webhooks are disabled. The methods of a nil event do nothing.
+func requestWebhookEvent(ctx *fasthttp.RequestCtx) *webhookEvent {
+	ev, _ := ctx.UserValue(webhookEventKey).(*webhookEvent)
+	return ev
+}
+
+// route records the model, the provider it routes to (primary) and the
+// provider serving the request.
+func (ev *webhookEvent) route(primary, provider, model string) {
+	if ev == nil {
+		return
+	}
+	ev.entry.PrimaryProvider = primary
+	ev.entry.Provider = provider
+	ev.entry.Model = model
+}
+
+// usage records the tokens a provider reported for the request.
+func (ev *webhookEvent) usage(inputTokens, outputTokens int) {
+	if ev == nil {
+		return
+	}
+	ev.entry.InputTokens = uint32(inputTokens)
+	ev.entry.OutputTokens = uint32(outputTokens)
+}
+
+// notifyWebhook sends a webhook event for every inference request (POST
+// /v1/...) on every exit path: authentication, rate-limit and validation
+// rejections included. The event reports the status actually written to
+// the client.
+func (g *Gateway) notifyWebhook(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if g.webhook == nil || !ctx.IsPost() || !bytes.HasPrefix(ctx.Path(), []byte("/v1/")) {
+			next(ctx)
+			return
+		}
+		start := time.Now()
+		ev := &webhookEvent{}
+		ctx.SetUserValue(webhookEventKey, ev)
+		next(ctx)
+		if ev.streaming {
+			return
+		}
+
+		e := ev.entry
+		if e.CreatedAt.IsZero() { // not filled in by logRequest
+			reqID, _ := ctx.UserValue("request_id").(string)
+			e.ID, _ = uuid.Parse(reqID)
+			e.Caller = callerID(ctx)
+			e.LatencyMs = uint32(time.Since(start).Milliseconds())
+			e.CreatedAt = time.Now()
+		}
+		e.Status = uint16(ctx.Response.StatusCode())
+		g.webhook.Notify(e)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+// headerPassthrough is the whitelist of upstream response headers copied onto
+// the gateway's response. Entries are lower-case names; a trailing "*"
+// matches any name with that prefix (e.g. "x-ratelimit-*").
+type headerPassthrough struct {
+	exact    map[string]bool
+	prefixes []string
+}
+
+// newHeaderPassthrough builds a whitelist from header names. It returns nil,
+// which passes nothing, when names is empty.
+func newHeaderPassthrough(names []string) *headerPassthrough {
+	if len(names) == 0 {
+		return nil
+	}
+	h := &headerPassthrough{exact: make(map[string]bool, len(names))}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			h.prefixes = append(h.prefixes, prefix)
+		} else if name != "" {
+			h.exact[name] = true
+		}
+	}
+	return h
+}
+
+// allows reports whether the lower-case header name is whitelisted.
+func (h *headerPassthrough) allows(name string) bool {
+	if h == nil {
+		return false
+	}
+	if h.exact[name] {
+		return true
+	}
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyUpstreamHeaders sets the whitelisted headers the provider captured on
+// resp (see providers.RateLimitHeaders) on the client response, so callers can
+// throttle against the upstream limits.
+func (g *Gateway) copyUpstreamHeaders(ctx *fasthttp.RequestCtx, resp *providers.ProxyResponse) {
+	if g.passthroughHeaders == nil || resp == nil {
+		return
+	}
+	for name, value := range resp.Headers {
+		if g.passthroughHeaders.allows(name) {
+			ctx.Response.Header.Set(name, value)
+		}
+	}
+}
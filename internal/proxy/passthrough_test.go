@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestDispatchChat_PassesThroughWhitelistedHeaders(t *testing.T) {
+	upstream := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return &providers.ProxyResponse{Model: "gpt-4o", Content: "ok", Headers: map[string]string{
+				"x-ratelimit-remaining-requests": "59",
+				"x-ratelimit-remaining-tokens":   "149000",
+				"retry-after":                    "3",
+				"openai-organization":            "org-secret",
+			}}, nil
+		},
+	}
+	newGW := func(passthrough []string) *Gateway {
+		gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+			"openai": upstream,
+		}, nil, nil, GatewayOptions{PassthroughHeaders: passthrough})
+		t.Cleanup(gw.health.Close)
+		return gw
+	}
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+
+	client, cleanup := serveGateway(t, newGW([]string{"X-RateLimit-Remaining-*", "retry-after"}))
+	defer cleanup()
+	resp := doPost(t, client, "/v1/chat/completions", body)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	for name, want := range map[string]string{
+		"X-Ratelimit-Remaining-Requests": "59",
+		"X-Ratelimit-Remaining-Tokens":   "149000",
+		"Retry-After":                    "3",
+		"Openai-Organization":            "",
+	} {
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Without a whitelist nothing is passed through.
+	client, cleanup = serveGateway(t, newGW(nil))
+	defer cleanup()
+	resp = doPost(t, client, "/v1/chat/completions", body)
+	readBody(t, resp)
+	if got := resp.Header.Get("X-Ratelimit-Remaining-Requests"); got != "" {
+		t.Errorf("header passed through without a whitelist: %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// priorityHeader selects the scheduling tier of a request: "high", "normal"
+// or "low".
+const priorityHeader = "X-Priority"
+
+// priority orders requests waiting for a concurrency slot; higher values are
+// admitted first.
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityNormal
+	priorityHigh
+)
+
+// String returns the tier name used in X-Priority and metric labels.
+func (p priority) String() string {
+	switch p {
+	case priorityLow:
+		return "low"
+	case priorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// parsePriority parses a tier name, case-insensitively.
+func parsePriority(s string) (priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return priorityLow, true
+	case "normal":
+		return priorityNormal, true
+	case "high":
+		return priorityHigh, true
+	}
+	return priorityNormal, false
+}
+
+// requestPriority returns the tier of a request. A virtual key's priority is
+// the default for its requests and a ceiling for X-Priority, so a batch key
+// cannot jump the queue by setting the header. Unknown values count as
+// normal.
+func requestPriority(ctx *fasthttp.RequestCtx) priority {
+	ceiling := priorityHigh
+	p := priorityNormal
+	if vk := requestVirtualKey(ctx); vk != nil {
+		if kp, ok := parsePriority(vk.Priority); ok {
+			ceiling, p = kp, kp
+		}
+	}
+	if hp, ok := parsePriority(string(ctx.Request.Header.Peek(priorityHeader))); ok {
+		p = hp
+	}
+	return min(p, ceiling)
+}
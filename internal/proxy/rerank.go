@@ -0,0 +1,268 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// maxRerankDocuments bounds the documents of one rerank request, matching
+// the limit of the Cohere API.
+const maxRerankDocuments = 1000
+
+type (
+	// inboundRerankRequest mirrors the Cohere/Jina POST /v1/rerank body.
+	inboundRerankRequest struct {
+		Model           string   `json:"model"`
+		Query           string   `json:"query"`
+		Documents       []string `json:"documents"`
+		TopN            *int     `json:"top_n"`
+		ReturnDocuments bool     `json:"return_documents"`
+	}
+
+	outboundRerankDocument struct {
+		Text string `json:"text"`
+	}
+
+	outboundRerankResult struct {
+		Index          int                     `json:"index"`
+		RelevanceScore float64                 `json:"relevance_score"`
+		Document       *outboundRerankDocument `json:"document,omitempty"`
+	}
+
+	outboundRerankResponse struct {
+		ID      string                 `json:"id"`
+		Object  string                 `json:"object"`
+		Model   string                 `json:"model"`
+		Results []outboundRerankResult `json:"results"`
+		Usage   outboundEmbeddingUsage `json:"usage"`
+	}
+)
+
+// dispatchRerank handles POST /v1/rerank.
+// It resolves the provider from the model name, delegates to the provider's
+// Rerank method, and returns a Cohere/Jina-compatible results envelope.
+func (g *Gateway) dispatchRerank(ctx *fasthttp.RequestCtx) {
+	start := time.Now()
+	route := "rerank"
+	reqBytes := len(ctx.PostBody())
+	servedProvider := "unknown"
+	inputTokens := 0
+	respBytes := -1
+
+	if g.metrics != nil {
+		g.metrics.IncInFlight()
+	}
+	defer func() {
+		if g.metrics == nil {
+			return
+		}
+		g.metrics.DecInFlight()
+		status := ctx.Response.StatusCode()
+		dur := time.Since(start)
+		if respBytes < 0 {
+			respBytes = len(ctx.Response.Body())
+		}
+		g.metrics.ObserveHTTP(route, status, dur, reqBytes, respBytes)
+		g.metrics.RecordRequest(servedProvider, status, dur.Milliseconds())
+		g.metrics.ObserveGatewayRequest(servedProvider, route, "bypass", dur)
+		g.metrics.AddTokens(servedProvider, route, inputTokens, 0, false)
+	}()
+
+	tctx, span := g.startRequestSpan(ctx, route)
+	defer func() {
+		endRequestSpan(span, ctx.Response.StatusCode(), servedProvider, "bypass")
+	}()
+
+	reqID, _ := ctx.UserValue("request_id").(string)
+	caller := callerID(ctx)
+	vk := requestVirtualKey(ctx)
+	clientKey, clientKeyID := g.extractClientAPIKey(ctx)
+	ev := requestWebhookEvent(ctx)
+
+	// 1. Parse and validate the request.
+	var req inboundRerankRequest
+	if err := g.decodeRequest(ctx.PostBody(), &req); err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	if msg := validateRerankRequest(&req); msg != "" {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, msg,
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	topN := len(req.Documents)
+	if req.TopN != nil && *req.TopN < topN {
+		topN = *req.TopN
+	}
+
+	// 2. Resolve provider.
+	providerName := resolveRerankProvider(req.Model)
+	ev.route(providerName, providerName, req.Model)
+	span.SetAttributes(tracing.String(attrModel, req.Model))
+
+	g.log.InfoContext(ctx, "rerank_request",
+		slog.String("request_id", reqID),
+		slog.String("caller", caller),
+		slog.String("model", req.Model),
+		slog.String("provider", providerName),
+		slog.Int("documents", len(req.Documents)),
+	)
+
+	if !g.enforceVirtualKey(ctx, vk, req.Model) {
+		return
+	}
+
+	prov, ok := g.providers[providerName]
+	if !ok {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			fmt.Sprintf("rerank model %q is not served by any configured provider", req.Model),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	servedProvider = prov.Name()
+
+	reranker, ok := prov.(providers.RerankProvider)
+	if !ok {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			fmt.Sprintf("provider %q does not support rerank", prov.Name()),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+
+	// 3. Call the provider.
+	releaseSlot, err := g.acquireConcurrency(ctx, servedProvider)
+	if err != nil {
+		g.log.WarnContext(ctx, "concurrency_limit_exceeded",
+			slog.String("request_id", reqID),
+			slog.String("provider", servedProvider),
+		)
+		apierr.WriteOverloaded(ctx, g.queueTimeout)
+		return
+	}
+	defer releaseSlot()
+
+	provCtx, cancel := context.WithTimeout(tctx, g.providerTimeout)
+	defer cancel()
+
+	upStart := time.Now()
+	attemptCtx, attemptSpan := g.startSpan(provCtx, "provider.attempt",
+		tracing.String(attrProvider, servedProvider), tracing.Int(attrAttempt, 1))
+	rrResp, err := reranker.Rerank(attemptCtx, &providers.RerankRequest{
+		Query:     req.Query,
+		Documents: req.Documents,
+		TopN:      topN,
+		Model:     req.Model,
+		RequestID: reqID,
+		APIKey:    clientKey,
+		APIKeyID:  clientKeyID,
+	})
+	endAttemptSpan(attemptSpan, err)
+	upDur := time.Since(upStart)
+	if err != nil {
+		if g.metrics != nil {
+			reason := classifyError(err)
+			g.metrics.ObserveUpstreamAttempt(servedProvider, route, reason, upDur)
+			g.metrics.RecordError(servedProvider, reason)
+		}
+		g.log.ErrorContext(ctx, "rerank_error",
+			slog.String("request_id", reqID),
+			slog.String("provider", providerName),
+			slog.String("error", err.Error()),
+			slog.Duration("elapsed", time.Since(start)),
+		)
+		g.handleProviderError(ctx, err)
+		return
+	}
+	if g.metrics != nil {
+		g.metrics.ObserveUpstreamAttempt(servedProvider, route, "success", upDur)
+	}
+	g.chargeVirtualKey(vk, rrResp.Usage.InputTokens)
+	inputTokens = rrResp.Usage.InputTokens
+	ev.usage(inputTokens, 0)
+
+	// 4. Build the response.
+	out := outboundRerankResponse{
+		ID:      reqID,
+		Object:  "list",
+		Model:   rrResp.Model,
+		Results: shapeRerankResults(rrResp.Results, req.Documents, topN, req.ReturnDocuments),
+		Usage: outboundEmbeddingUsage{
+			PromptTokens: inputTokens,
+			TotalTokens:  inputTokens,
+		},
+	}
+	if out.Model == "" {
+		out.Model = req.Model
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusInternalServerError,
+			"failed to serialize response", apierr.TypeServerError, apierr.CodeInternalError)
+		return
+	}
+
+	g.log.DebugContext(ctx, "rerank_ok",
+		slog.String("request_id", reqID),
+		slog.String("provider", prov.Name()),
+		slog.String("model", out.Model),
+		slog.Int("results", len(out.Results)),
+		slog.Int("input_tokens", inputTokens),
+		slog.Duration("elapsed", time.Since(start)),
+	)
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+	respBytes = len(body)
+}
+
+// validateRerankRequest returns a client-facing message describing what is
+// wrong with req, or "" when it is valid.
+func validateRerankRequest(req *inboundRerankRequest) string {
+	switch {
+	case req.Model == "":
+		return "field 'model' is required"
+	case req.Query == "":
+		return "field 'query' is required"
+	case len(req.Documents) == 0:
+		return "'documents' must not be empty"
+	case len(req.Documents) > maxRerankDocuments:
+		return fmt.Sprintf("'documents' must have at most %d entries", maxRerankDocuments)
+	case req.TopN != nil && *req.TopN < 1:
+		return "'top_n' must be at least 1"
+	}
+	return ""
+}
+
+// shapeRerankResults orders results by descending relevance, drops entries
+// whose index does not name a document, and keeps the best topN. Providers
+// differ on whether they sort and truncate, so the gateway always does.
+func shapeRerankResults(results []providers.RerankResult, docs []string, topN int, withDocs bool) []outboundRerankResult {
+	out := make([]outboundRerankResult, 0, len(results))
+	for _, r := range results {
+		if r.Index < 0 || r.Index >= len(docs) {
+			continue
+		}
+		res := outboundRerankResult{Index: r.Index, RelevanceScore: r.RelevanceScore}
+		if withDocs {
+			res.Document = &outboundRerankDocument{Text: docs[r.Index]}
+		}
+		out = append(out, res)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].RelevanceScore > out[j].RelevanceScore })
+	if len(out) > topN {
+		out = out[:topN]
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/providers/openaicompat"
+)
+
+// rerankProvider is a funcProvider that also implements RerankProvider.
+type rerankProvider struct {
+	*funcProvider
+	rerankFn func(ctx context.Context, req *providers.RerankRequest) (*providers.RerankResponse, error)
+}
+
+func (p *rerankProvider) Rerank(ctx context.Context, req *providers.RerankRequest) (*providers.RerankResponse, error) {
+	return p.rerankFn(ctx, req)
+}
+
+type rerankBody struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Model   string `json:"model"`
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+		Document       *struct {
+			Text string `json:"text"`
+		} `json:"document"`
+	} `json:"results"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func TestDispatchRerank_ShapesResults(t *testing.T) {
+	var got *providers.RerankRequest
+	together := &rerankProvider{
+		funcProvider: okProvider("together"),
+		rerankFn: func(_ context.Context, req *providers.RerankRequest) (*providers.RerankResponse, error) {
+			got = req
+			// Unsorted, with an index that names no document.
+			return &providers.RerankResponse{
+				Model: "Salesforce/Llama-Rank-V1",
+				Results: []providers.RerankResult{
+					{Index: 0, RelevanceScore: 0.2},
+					{Index: 7, RelevanceScore: 0.99},
+					{Index: 2, RelevanceScore: 0.9},
+					{Index: 1, RelevanceScore: 0.5},
+				},
+				Usage: providers.Usage{InputTokens: 42},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"together": together}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/rerank", []byte(`{"model":"Salesforce/Llama-Rank-V1","query":"capital of France",`+
+		`"documents":["Berlin","Lyon","Paris"],"top_n":2,"return_documents":true}`))
+	raw := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, raw)
+	}
+
+	if got == nil || got.Query != "capital of France" || len(got.Documents) != 3 || got.TopN != 2 {
+		t.Fatalf("provider request = %+v", got)
+	}
+
+	var body rerankBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Object != "list" || body.Model != "Salesforce/Llama-Rank-V1" || body.Usage.TotalTokens != 42 {
+		t.Errorf("envelope = %+v", body)
+	}
+	if len(body.Results) != 2 {
+		t.Fatalf("results = %+v, want the top 2", body.Results)
+	}
+	for i, want := range []struct {
+		index int
+		doc   string
+	}{{2, "Paris"}, {1, "Lyon"}} {
+		r := body.Results[i]
+		if r.Index != want.index || r.Document == nil || r.Document.Text != want.doc {
+			t.Errorf("results[%d] = %+v, want index %d (%s)", i, r, want.index, want.doc)
+		}
+	}
+}
+
+func TestDispatchRerank_OmitsDocumentsByDefault(t *testing.T) {
+	together := &rerankProvider{
+		funcProvider: okProvider("together"),
+		rerankFn: func(_ context.Context, req *providers.RerankRequest) (*providers.RerankResponse, error) {
+			return &providers.RerankResponse{Results: []providers.RerankResult{{Index: 0, RelevanceScore: 1}}}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"together": together}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/rerank",
+		[]byte(`{"model":"Salesforce/Llama-Rank-V1","query":"q","documents":["a"]}`))
+	var body rerankBody
+	if err := json.Unmarshal(readBody(t, resp), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Results) != 1 || body.Results[0].Document != nil {
+		t.Errorf("results = %+v, want one result without its document", body.Results)
+	}
+	if body.Model != "Salesforce/Llama-Rank-V1" {
+		t.Errorf("model = %q, want the requested model when the provider reports none", body.Model)
+	}
+}
+
+func TestDispatchRerank_Rejects(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"together": okProvider("together"), // no Rerank method
+	}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing model", `{"query":"q","documents":["a"]}`},
+		{"missing query", `{"model":"Salesforce/Llama-Rank-V1","documents":["a"]}`},
+		{"no documents", `{"model":"Salesforce/Llama-Rank-V1","query":"q","documents":[]}`},
+		{"zero top_n", `{"model":"Salesforce/Llama-Rank-V1","query":"q","documents":["a"],"top_n":0}`},
+		{"unknown model", `{"model":"gpt-4o","query":"q","documents":["a"]}`},
+		{"provider cannot rerank", `{"model":"Salesforce/Llama-Rank-V1","query":"q","documents":["a"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/rerank", []byte(tt.body))
+			readBody(t, resp)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestDispatchRerank_CustomEndpoint(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"bge-reranker","usage":{"total_tokens":9},` +
+			`"results":[{"index":1,"relevance_score":0.8},{"index":0,"relevance_score":0.1}]}`))
+	}))
+	defer srv.Close()
+
+	withModelPrefixes(t, map[string]string{"local/": "local"})
+	local := openaicompat.New("local", "", srv.URL+"/v1",
+		openaicompat.WithModelPrefix("local/"), openaicompat.WithNoAuth())
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"local": local}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/rerank",
+		[]byte(`{"model":"local/bge-reranker","query":"q","documents":["a","b"],"top_n":1}`))
+	raw := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, raw)
+	}
+	if gotPath != "/v1/rerank" || gotBody["model"] != "bge-reranker" || gotBody["top_n"] != float64(1) {
+		t.Errorf("upstream got %s %v", gotPath, gotBody)
+	}
+
+	var body rerankBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Results) != 1 || body.Results[0].Index != 1 || body.Usage.TotalTokens != 9 {
+		t.Errorf("body = %+v", body)
+	}
+}
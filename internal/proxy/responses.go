@@ -0,0 +1,302 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// responsesRequest is the subset of the OpenAI Responses API request body
+// (POST /v1/responses) the gateway understands.
+type responsesRequest struct {
+	Model string `json:"model"`
+	// Input is a bare string or an array of message items.
+	Input           json.RawMessage `json:"input"`
+	Instructions    string          `json:"instructions"`
+	Temperature     *float64        `json:"temperature"`
+	TopP            *float64        `json:"top_p"`
+	MaxOutputTokens int             `json:"max_output_tokens"`
+	Stream          bool            `json:"stream"`
+
+	// Rejected until supported, rather than silently ignored.
+	Tools              json.RawMessage `json:"tools"`
+	PreviousResponseID string          `json:"previous_response_id"`
+
+	// Standard fields accepted, so strict validation does not reject
+	// requests from the official SDKs, but not forwarded.
+	User              string            `json:"user"`
+	Metadata          map[string]string `json:"metadata"`
+	Store             *bool             `json:"store"`
+	ServiceTier       string            `json:"service_tier"`
+	ParallelToolCalls *bool             `json:"parallel_tool_calls"`
+	PromptCacheKey    string            `json:"prompt_cache_key"`
+	SafetyIdentifier  string            `json:"safety_identifier"`
+	Truncation        string            `json:"truncation"`
+	Include           []string          `json:"include"`
+}
+
+// responsesInputItem is one element of an "input" array. Only message
+// items are supported; Type is "message" or omitted.
+type responsesInputItem struct {
+	Type    string          `json:"type"`
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// responsesContentPart is one element of a message item's content array.
+type responsesContentPart struct {
+	Type     string `json:"type"` // input_text, output_text or input_image
+	Text     string `json:"text"`
+	ImageURL string `json:"image_url"`
+	Detail   string `json:"detail"`
+}
+
+// Chat completions request body produced from a Responses request.
+type (
+	responsesChatRequest struct {
+		Model       string                 `json:"model"`
+		Messages    []responsesChatMessage `json:"messages"`
+		Temperature *float64               `json:"temperature,omitempty"`
+		TopP        *float64               `json:"top_p,omitempty"`
+		MaxTokens   int                    `json:"max_tokens,omitempty"`
+	}
+
+	responsesChatMessage struct {
+		Role string `json:"role"`
+		// Content is a string or a []responsesChatPart.
+		Content any `json:"content"`
+	}
+
+	responsesChatPart struct {
+		Type     string            `json:"type"`
+		Text     string            `json:"text,omitempty"`
+		ImageURL *responsesChatURL `json:"image_url,omitempty"`
+	}
+
+	responsesChatURL struct {
+		URL    string `json:"url"`
+		Detail string `json:"detail,omitempty"`
+	}
+)
+
+// Responses API response envelope.
+type (
+	responsesResponse struct {
+		ID                string                      `json:"id"`
+		Object            string                      `json:"object"`
+		CreatedAt         int64                       `json:"created_at"`
+		Status            string                      `json:"status"`
+		IncompleteDetails *responsesIncompleteDetails `json:"incomplete_details"`
+		Model             string                      `json:"model"`
+		Output            []responsesOutputItem       `json:"output"`
+		Usage             responsesUsage              `json:"usage"`
+	}
+
+	responsesIncompleteDetails struct {
+		Reason string `json:"reason"`
+	}
+
+	responsesOutputItem struct {
+		Type    string                `json:"type"`
+		ID      string                `json:"id"`
+		Status  string                `json:"status"`
+		Role    string                `json:"role"`
+		Content []responsesOutputText `json:"content"`
+	}
+
+	responsesOutputText struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Annotations []any  `json:"annotations"`
+	}
+
+	responsesUsage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	}
+)
+
+// dispatchResponses handles POST /v1/responses. The request is rewritten
+// into a chat completions body and served by dispatchChat, so routing,
+// failover, caching and limits behave exactly as for /v1/chat/completions;
+// a successful chat completion is then reshaped into a Responses envelope.
+// Error responses use the same format on both routes and pass through.
+// Only non-streaming text is supported for now.
+func (g *Gateway) dispatchResponses(ctx *fasthttp.RequestCtx) {
+	chatBody, err := g.responsesToChat(ctx.PostBody())
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusBadRequest, err.Error(),
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	ctx.Request.SetBody(chatBody)
+
+	g.dispatchChat(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		return
+	}
+	reqID, _ := ctx.UserValue("request_id").(string)
+	out, err := chatToResponses(ctx.Response.Body(), reqID)
+	if err != nil {
+		apierr.Write(ctx, fasthttp.StatusInternalServerError,
+			"failed to build response", apierr.TypeServerError, apierr.CodeInternalError)
+		return
+	}
+	ctx.Response.SetBody(out)
+}
+
+// responsesToChat translates a Responses API request body into the
+// equivalent chat completions request body. Instructions become a leading
+// system message. The body is decoded with decodeRequest, so strict
+// validation covers its top-level fields; input items are checked only as
+// far as the translation needs.
+func (g *Gateway) responsesToChat(body []byte) ([]byte, error) {
+	var req responsesRequest
+	if err := g.decodeRequest(body, &req); err != nil {
+		return nil, err
+	}
+	switch {
+	case req.Stream:
+		return nil, fmt.Errorf("streaming is not supported on /v1/responses")
+	case len(nullToNil(req.Tools)) > 0:
+		return nil, fmt.Errorf("'tools' is not supported on /v1/responses")
+	case req.PreviousResponseID != "":
+		return nil, fmt.Errorf("'previous_response_id' is not supported: the gateway does not store responses")
+	}
+
+	chat := responsesChatRequest{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxOutputTokens,
+	}
+	if req.Instructions != "" {
+		chat.Messages = append(chat.Messages, responsesChatMessage{Role: "system", Content: req.Instructions})
+	}
+	msgs, err := responsesInput(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	chat.Messages = append(chat.Messages, msgs...)
+	return json.Marshal(chat)
+}
+
+// responsesInput converts the "input" field, a string or an array of
+// message items, into chat messages.
+func responsesInput(raw json.RawMessage) ([]responsesChatMessage, error) {
+	raw = nullToNil(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("field 'input' is required")
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []responsesChatMessage{{Role: "user", Content: text}}, nil
+	}
+	var items []responsesInputItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("'input' must be a string or an array of message items")
+	}
+	msgs := make([]responsesChatMessage, 0, len(items))
+	for i, item := range items {
+		if item.Type != "" && item.Type != "message" {
+			return nil, fmt.Errorf("input[%d]: item type %q is not supported", i, item.Type)
+		}
+		switch item.Role {
+		case "user", "assistant", "system", "developer":
+		default:
+			return nil, fmt.Errorf("input[%d]: invalid role %q", i, item.Role)
+		}
+		content, err := responsesContent(item.Content)
+		if err != nil {
+			return nil, fmt.Errorf("input[%d]: %w", i, err)
+		}
+		msgs = append(msgs, responsesChatMessage{Role: item.Role, Content: content})
+	}
+	return msgs, nil
+}
+
+// responsesContent converts a message item's content, a string or an array
+// of input_text, output_text and input_image parts, into chat content.
+func responsesContent(raw json.RawMessage) (any, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []responsesContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("'content' must be a string or an array of content parts")
+	}
+	out := make([]responsesChatPart, 0, len(parts))
+	for _, p := range parts {
+		switch p.Type {
+		case "input_text", "output_text":
+			out = append(out, responsesChatPart{Type: providers.ContentPartText, Text: p.Text})
+		case "input_image":
+			if p.ImageURL == "" {
+				return nil, fmt.Errorf("'input_image' content part requires an image_url")
+			}
+			out = append(out, responsesChatPart{
+				Type:     providers.ContentPartImageURL,
+				ImageURL: &responsesChatURL{URL: p.ImageURL, Detail: p.Detail},
+			})
+		default:
+			return nil, fmt.Errorf("content part type %q is not supported", p.Type)
+		}
+	}
+	return out, nil
+}
+
+// chatToResponses reshapes a chat completion body into a Responses API
+// envelope. fallbackID names the response when the completion has no id.
+func chatToResponses(body []byte, fallbackID string) ([]byte, error) {
+	var chat outboundResponse
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return nil, err
+	}
+	id := strings.TrimPrefix(chat.ID, "chatcmpl-")
+	if id == "" {
+		id = fallbackID
+	}
+
+	out := responsesResponse{
+		ID:        "resp_" + id,
+		Object:    "response",
+		CreatedAt: chat.Created,
+		Status:    "completed",
+		Model:     chat.Model,
+		Output:    []responsesOutputItem{},
+		Usage: responsesUsage{
+			InputTokens:  chat.Usage.PromptTokens,
+			OutputTokens: chat.Usage.CompletionTokens,
+			TotalTokens:  chat.Usage.TotalTokens,
+		},
+	}
+	if len(chat.Choices) > 0 {
+		choice := chat.Choices[0]
+		switch choice.FinishReason {
+		case "length":
+			out.Status = "incomplete"
+			out.IncompleteDetails = &responsesIncompleteDetails{Reason: "max_output_tokens"}
+		case "content_filter":
+			out.Status = "incomplete"
+			out.IncompleteDetails = &responsesIncompleteDetails{Reason: "content_filter"}
+		}
+		out.Output = append(out.Output, responsesOutputItem{
+			Type:   "message",
+			ID:     "msg_" + id,
+			Status: out.Status,
+			Role:   "assistant",
+			Content: []responsesOutputText{{
+				Type:        "output_text",
+				Text:        choice.Message.Content,
+				Annotations: []any{},
+			}},
+		})
+	}
+	return json.Marshal(out)
+}
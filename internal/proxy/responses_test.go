@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestResponsesToChat(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "string input with instructions",
+			body: `{"model":"gpt-4o","instructions":"Be brief.","input":"hi","max_output_tokens":64,"temperature":0.2}`,
+			want: `{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}],"temperature":0.2,"max_tokens":64}`,
+		},
+		{
+			name: "message items",
+			body: `{"model":"gpt-4o","input":[{"role":"user","content":[{"type":"input_text","text":"what is this?"},{"type":"input_image","image_url":"https://x/cat.png"}]},{"type":"message","role":"assistant","content":[{"type":"output_text","text":"a cat"}]},{"role":"user","content":"sure?"}]}`,
+			want: `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"https://x/cat.png"}}]},{"role":"assistant","content":[{"type":"text","text":"a cat"}]},{"role":"user","content":"sure?"}]}`,
+		},
+		{name: "missing input", body: `{"model":"gpt-4o"}`, wantErr: "'input' is required"},
+		{name: "streaming", body: `{"model":"gpt-4o","input":"hi","stream":true}`, wantErr: "streaming"},
+		{name: "function call item", body: `{"model":"gpt-4o","input":[{"type":"function_call_output","call_id":"c1"}]}`, wantErr: "function_call_output"},
+		{name: "stored response", body: `{"model":"gpt-4o","input":"hi","previous_response_id":"resp_1"}`, wantErr: "previous_response_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Gateway{}).responsesToChat([]byte(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("chat body =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchResponses(t *testing.T) {
+	var got *providers.ProxyRequest
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": &funcProvider{
+			name: "openai",
+			requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+				got = req
+				return &providers.ProxyResponse{
+					ID:           "chatcmpl-abc",
+					Model:        req.Model,
+					Content:      "Paris.",
+					FinishReason: "length",
+					Usage:        providers.Usage{InputTokens: 12, OutputTokens: 2},
+				}, nil
+			},
+		},
+	}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/responses",
+		[]byte(`{"model":"gpt-4o","instructions":"Answer in one word.","input":"Capital of France?","max_output_tokens":2}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+	if got == nil || len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.MaxTokens != 2 {
+		t.Fatalf("provider request = %+v, want instructions as system message and max_tokens 2", got)
+	}
+
+	var out responsesResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.Object != "response" || out.ID != "resp_abc" || out.Model != "gpt-4o" {
+		t.Errorf("envelope = %+v", out)
+	}
+	if out.Status != "incomplete" || out.IncompleteDetails == nil || out.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("status = %q, incomplete_details = %+v; want incomplete for max_output_tokens", out.Status, out.IncompleteDetails)
+	}
+	if len(out.Output) != 1 || len(out.Output[0].Content) != 1 || out.Output[0].Content[0].Text != "Paris." {
+		t.Fatalf("output = %+v, want one message with the completion text", out.Output)
+	}
+	if out.Output[0].Content[0].Type != "output_text" || out.Output[0].Role != "assistant" {
+		t.Errorf("output item = %+v", out.Output[0])
+	}
+	if out.Usage.InputTokens != 12 || out.Usage.OutputTokens != 2 || out.Usage.TotalTokens != 14 {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+}
+
+func TestDispatchResponses_ErrorsPassThrough(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/responses", []byte(`{"model":"gpt-4o","input":"hi","stream":true}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), `"error"`) {
+		t.Errorf("stream request: status = %d, body = %s; want 400 error envelope", resp.StatusCode, body)
+	}
+
+	resp = doPost(t, client, "/v1/responses", []byte(`{"input":"hi"}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "model") {
+		t.Errorf("missing model: status = %d, body = %s; want 400 from chat validation", resp.StatusCode, body)
+	}
+}
@@ -2,9 +2,15 @@ package proxy
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
 	"time"
 
 	"github.com/fasthttp/router"
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
 	"github.com/valyala/fasthttp"
 )
 
@@ -15,6 +21,9 @@ type RouteHandler = fasthttp.RequestHandler
 // that are registered alongside the proxy routes.
 type ManagementRoutes struct {
 	Metrics RouteHandler
+	// MetricsAuth, when set, protects Metrics with a bearer token or basic
+	// credentials.
+	MetricsAuth MetricsAuth
 }
 
 // Start starts the HTTP server on addr (e.g. ":8080").
@@ -25,45 +34,137 @@ func (g *Gateway) Start(addr string) error {
 
 // StartWithRoutes starts the HTTP server with optional management routes.
 func (g *Gateway) StartWithRoutes(addr string, mgmt *ManagementRoutes) error {
+	return g.newServer(g.handler(mgmt)).ListenAndServe(addr)
+}
+
+// handler returns the gateway's routes wrapped in its middleware. A known
+// path called with the wrong method gets 405 with an Allow header.
+func (g *Gateway) handler(mgmt *ManagementRoutes) fasthttp.RequestHandler {
 	r := router.New()
+	r.MethodNotAllowed = handleMethodNotAllowed
 
 	r.POST("/v1/chat/completions", g.handleChatCompletions)
 	r.POST("/v1/completions", g.handleCompletions)
+	r.POST("/v1/responses", g.handleResponses)
 	r.POST("/v1/embeddings", g.handleEmbeddings)
+	r.POST("/v1/rerank", g.handleRerank)
+	r.GET("/v1/models", g.handleModels)
 	r.GET("/health", g.handleHealth)
+	r.GET("/health/{provider}", g.handleProviderHealth)
 	r.GET("/readiness", g.handleReadiness)
+	r.GET("/stats", g.handleStats)
+
+	// Admin API — authenticate only admits gateway (master) keys.
+	r.GET("/admin/virtual-keys", g.handleVirtualKeys)
+	r.POST("/admin/circuit-breaker/reset", g.handleCircuitBreakerReset)
+	r.GET("/admin/cache/stats", g.handleCacheStats)
+	r.DELETE("/admin/cache", g.handleCacheFlush)
 
 	if mgmt != nil && mgmt.Metrics != nil {
-		r.GET("/metrics", mgmt.Metrics)
+		r.GET("/metrics", metricsAuth(mgmt.MetricsAuth, mgmt.Metrics))
 	}
 
-	handler := applyMiddleware(r.Handler,
+	mws := []func(fasthttp.RequestHandler) fasthttp.RequestHandler{
 		recovery,
 		requestID,
 		timing,
 		corsHandler(g.corsOrigins),
 		securityHeaders,
-	)
+	}
+	if g.compressResponses {
+		mws = append(mws, compressResponse(g.compressMinBytes))
+	}
+	return applyMiddleware(r.Handler, append(mws, g.notifyWebhook, g.authenticate)...)
+}
+
+// handleMethodNotAllowed answers a known path called with an unsupported
+// method. The router has already set the Allow header.
+func handleMethodNotAllowed(ctx *fasthttp.RequestCtx) {
+	apierr.Write(ctx, fasthttp.StatusMethodNotAllowed,
+		fmt.Sprintf("method %s is not allowed on %s; use %s",
+			ctx.Method(), ctx.Path(), ctx.Response.Header.Peek("Allow")),
+		apierr.TypeInvalidRequest, apierr.CodeMethodNotAllowed)
+}
 
-	srv := &fasthttp.Server{
-		Handler:      handler,
-		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 60 * time.Second,
+// newServer returns the HTTP server for handler. Bodies over maxRequestBytes
+// are refused while the request is read, so they are never buffered whole.
+func (g *Gateway) newServer(handler fasthttp.RequestHandler) *fasthttp.Server {
+	return &fasthttp.Server{
+		Handler:            handler,
+		ErrorHandler:       g.handleServerError,
+		ReadTimeout:        60 * time.Second,
+		WriteTimeout:       60 * time.Second,
+		MaxRequestBodySize: g.maxRequestBytes,
 	}
+}
 
-	return srv.ListenAndServe(addr)
+// handleServerError answers requests fasthttp could not read, replacing its
+// plain-text responses with the OpenAI error format.
+func (g *Gateway) handleServerError(ctx *fasthttp.RequestCtx, err error) {
+	switch {
+	case errors.Is(err, fasthttp.ErrBodyTooLarge):
+		apierr.Write(ctx, fasthttp.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body exceeds the %d byte limit", g.maxRequestBytes),
+			apierr.TypeInvalidRequest, apierr.CodeRequestTooLarge)
+	default:
+		var timeout interface{ Timeout() bool }
+		if errors.As(err, &timeout) && timeout.Timeout() {
+			apierr.Write(ctx, fasthttp.StatusRequestTimeout, "timed out reading the request",
+				apierr.TypeInvalidRequest, apierr.CodeRequestTimeout)
+			return
+		}
+		apierr.Write(ctx, fasthttp.StatusBadRequest, "malformed HTTP request",
+			apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+	}
 }
 
 func (g *Gateway) handleChatCompletions(ctx *fasthttp.RequestCtx) {
-	g.dispatchChat(ctx)
+	g.withIdempotency(ctx, g.dispatchChat)
 }
 
 func (g *Gateway) handleCompletions(ctx *fasthttp.RequestCtx) {
-	g.dispatchChat(ctx)
+	g.withIdempotency(ctx, g.dispatchChat)
+}
+
+func (g *Gateway) handleResponses(ctx *fasthttp.RequestCtx) {
+	g.withIdempotency(ctx, g.dispatchResponses)
 }
 
 func (g *Gateway) handleEmbeddings(ctx *fasthttp.RequestCtx) {
-	g.dispatchEmbeddings(ctx)
+	g.withIdempotency(ctx, g.dispatchEmbeddings)
+}
+
+func (g *Gateway) handleRerank(ctx *fasthttp.RequestCtx) {
+	g.withIdempotency(ctx, g.dispatchRerank)
+}
+
+// modelObject is one entry of the OpenAI-style GET /v1/models list.
+type modelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// handleModels lists every chat, embedding and rerank model alias whose
+// provider is configured in this gateway, sorted by id.
+func (g *Gateway) handleModels(ctx *fasthttp.RequestCtx) {
+	data := make([]modelObject, 0, len(providers.ModelAliases)+len(providers.EmbeddingModelAliases))
+	seen := make(map[string]bool)
+	for _, aliases := range []map[string]string{
+		providers.ModelAliases, providers.EmbeddingModelAliases, providers.RerankModelAliases,
+	} {
+		for model, provider := range aliases {
+			if _, ok := g.providers[provider]; !ok || seen[model] {
+				continue
+			}
+			seen[model] = true
+			data = append(data, modelObject{ID: model, Object: "model", OwnedBy: provider})
+		}
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].ID < data[j].ID })
+
+	writeJSON(ctx, map[string]any{"object": "list", "data": data})
 }
 
 func (g *Gateway) handleHealth(ctx *fasthttp.RequestCtx) {
@@ -75,6 +176,40 @@ func (g *Gateway) handleHealth(ctx *fasthttp.RequestCtx) {
 	writeJSON(ctx, snap)
 }
 
+// providerHealthResponse is the body of GET /health/{provider}.
+type providerHealthResponse struct {
+	Provider string `json:"provider"`
+	ProviderHealth
+}
+
+// handleProviderHealth handles GET /health/{provider}: the provider's latest
+// background probe result, or a fresh probe with ?live=true. It answers 200
+// while the provider is healthy, 503 when its probe failed and 404 for a
+// provider the gateway does not have.
+func (g *Gateway) handleProviderHealth(ctx *fasthttp.RequestCtx) {
+	name, _ := ctx.UserValue("provider").(string)
+	var (
+		h  ProviderHealth
+		ok bool
+	)
+	if g.health != nil {
+		if ctx.QueryArgs().GetBool("live") {
+			h, ok = g.health.ProbeProvider(ctx, name)
+		} else {
+			h, ok = g.health.ProviderStatus(name)
+		}
+	}
+	if !ok {
+		apierr.Write(ctx, fasthttp.StatusNotFound,
+			fmt.Sprintf("unknown provider %q", name), apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	if h.Status != "ok" && h.Status != "unknown" {
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	}
+	writeJSON(ctx, providerHealthResponse{Provider: name, ProviderHealth: h})
+}
+
 func (g *Gateway) handleReadiness(ctx *fasthttp.RequestCtx) {
 	if g.health == nil || g.health.ReadinessOK() {
 		writeJSON(ctx, map[string]string{"status": "ok"})
@@ -84,6 +219,24 @@ func (g *Gateway) handleReadiness(ctx *fasthttp.RequestCtx) {
 	writeJSON(ctx, map[string]string{"status": "unavailable"})
 }
 
+// handleStats handles GET /stats: request, error, cache, circuit-breaker and
+// in-flight counters as JSON, read from the metrics registry.
+func (g *Gateway) handleStats(ctx *fasthttp.RequestCtx) {
+	if g.metrics == nil {
+		apierr.Write(ctx, fasthttp.StatusNotFound,
+			"metrics are disabled", apierr.TypeInvalidRequest, apierr.CodeInvalidRequest)
+		return
+	}
+	stats, err := g.metrics.Stats()
+	if err != nil {
+		g.log.ErrorContext(ctx, "stats_error", slog.String("error", err.Error()))
+		apierr.Write(ctx, fasthttp.StatusInternalServerError,
+			"failed to gather stats", apierr.TypeServerError, apierr.CodeInternalError)
+		return
+	}
+	writeJSON(ctx, stats)
+}
+
 func writeJSON(ctx *fasthttp.RequestCtx, v any) {
 	ctx.SetContentType("application/json")
 	data, _ := json.Marshal(v)
@@ -2,12 +2,19 @@ package proxy
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/nulpointcorp/llm-gateway/internal/metrics"
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttputil"
@@ -28,10 +35,16 @@ func serveRouter(t *testing.T, gw *Gateway) (*http.Client, func()) {
 				gw.handleCompletions(ctx)
 			case "/v1/embeddings":
 				gw.handleEmbeddings(ctx)
+			case "/v1/rerank":
+				gw.handleRerank(ctx)
+			case "/v1/models":
+				gw.handleModels(ctx)
 			case "/health":
 				gw.handleHealth(ctx)
 			case "/readiness":
 				gw.handleReadiness(ctx)
+			case "/stats":
+				gw.handleStats(ctx)
 			default:
 				ctx.SetStatusCode(404)
 			}
@@ -56,6 +69,78 @@ func serveRouter(t *testing.T, gw *Gateway) (*http.Client, func()) {
 	return client, func() { ln.Close() }
 }
 
+// serveHandler serves h, normally the production gateway.handler, through an
+// in-memory listener.
+func serveHandler(t *testing.T, h fasthttp.RequestHandler) *http.Client {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		_ = fasthttp.Serve(ln, h)
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return ln.Dial()
+			},
+		},
+	}
+}
+
+// --- method routing ---------------------------------------------------------
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	t.Cleanup(gw.health.Close)
+	client := serveHandler(t, gw.handler(nil))
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/embeddings"} {
+		resp, err := client.Get("http://test" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := readBody(t, resp)
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s: status = %d, want 405", path, resp.StatusCode)
+		}
+		if allow := resp.Header.Get("Allow"); !strings.Contains(allow, "POST") {
+			t.Errorf("GET %s: Allow = %q, want POST", path, allow)
+		}
+		if !strings.Contains(string(body), `"code":"method_not_allowed"`) {
+			t.Errorf("GET %s: body = %s, want an OpenAI-format error", path, body)
+		}
+	}
+
+	resp, err := client.Get("http://test/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestHandler_OptionsPreflight(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": okProvider("openai")}, nil)
+	t.Cleanup(gw.health.Close)
+	client := serveHandler(t, gw.handler(nil))
+
+	req, _ := http.NewRequest(http.MethodOptions, "http://test/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Methods"); !strings.Contains(got, "POST") {
+		t.Errorf("Access-Control-Allow-Methods = %q, want POST", got)
+	}
+}
+
 // --- handleHealth -----------------------------------------------------------
 
 func TestHandleHealth_NoHealthChecker(t *testing.T) {
@@ -103,6 +188,92 @@ func TestHandleHealth_WithProviders(t *testing.T) {
 	}
 }
 
+// --- handleProviderHealth ---------------------------------------------------
+
+// flakyHealthProvider passes health probes while healthy is set.
+type flakyHealthProvider struct {
+	healthyProvider
+	healthy atomic.Bool
+}
+
+func (p *flakyHealthProvider) HealthCheck(_ context.Context) error {
+	if !p.healthy.Load() {
+		return fmt.Errorf("health check failed")
+	}
+	return nil
+}
+
+func providerHealthRequest(gw *Gateway, name, query string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.SetRequestURI("/health/" + name + query)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&req, nil, nil)
+	ctx.SetUserValue("provider", name)
+	gw.handleProviderHealth(ctx)
+	return ctx
+}
+
+func TestHandleProviderHealth(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai":    &healthyProvider{name: "openai"},
+		"anthropic": &failingHealthProvider{name: "anthropic"},
+	}, nil)
+	defer gw.health.Close()
+
+	tests := []struct {
+		provider   string
+		wantStatus int
+		wantHealth string
+	}{
+		{"openai", fasthttp.StatusOK, "ok"},
+		{"anthropic", fasthttp.StatusServiceUnavailable, "degraded"},
+		{"gemini", fasthttp.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			ctx := providerHealthRequest(gw, tt.provider, "")
+			if got := ctx.Response.StatusCode(); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body = %s", got, tt.wantStatus, ctx.Response.Body())
+			}
+			if tt.wantHealth == "" {
+				return
+			}
+			var resp providerHealthResponse
+			if err := json.Unmarshal(ctx.Response.Body(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Provider != tt.provider || resp.Status != tt.wantHealth {
+				t.Errorf("got provider=%q status=%q, want %q %q", resp.Provider, resp.Status, tt.provider, tt.wantHealth)
+			}
+			if resp.LastChecked.IsZero() {
+				t.Error("last_checked not set")
+			}
+			if tt.wantHealth != "ok" && resp.LastError == "" {
+				t.Error("last_error not set for an unhealthy provider")
+			}
+		})
+	}
+}
+
+func TestHandleProviderHealth_Live(t *testing.T) {
+	prov := &flakyHealthProvider{healthyProvider: healthyProvider{name: "openai"}}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": prov}, nil)
+	defer gw.health.Close()
+
+	prov.healthy.Store(true)
+	// The cached result still holds the failed startup probe.
+	if got := providerHealthRequest(gw, "openai", "").Response.StatusCode(); got != fasthttp.StatusServiceUnavailable {
+		t.Fatalf("cached status = %d, want 503", got)
+	}
+	if got := providerHealthRequest(gw, "openai", "?live=true").Response.StatusCode(); got != fasthttp.StatusOK {
+		t.Fatalf("live status = %d, want 200", got)
+	}
+	// The live probe updates the shared state.
+	if !gw.health.Healthy("openai") {
+		t.Error("live probe result not recorded")
+	}
+}
+
 // --- handleReadiness --------------------------------------------------------
 
 func TestHandleReadiness_NoHealthChecker(t *testing.T) {
@@ -179,6 +350,108 @@ func TestHandleEmbeddings_NoProviders(t *testing.T) {
 	}
 }
 
+func TestHandleEmbeddings_Base64Encoding(t *testing.T) {
+	vec := []float32{0.5, -1.25, 3.0e-7, 42}
+	openai := &embedProvider{
+		funcProvider: okProvider("openai"),
+		embedFn: func(_ context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+			return &providers.EmbeddingResponse{
+				Model: req.Model,
+				Data:  []providers.EmbeddingData{{Index: 0, Embedding: vec}},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": openai}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/embeddings",
+		[]byte(`{"model":"text-embedding-3-small","input":"hello","encoding_format":"base64"}`))
+	raw := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, raw)
+	}
+	var body struct {
+		Data []struct {
+			Embedding string `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || len(body.Data) != 1 {
+		t.Fatalf("want one base64 embedding, got %s (%v)", raw, err)
+	}
+	buf, err := base64.StdEncoding.DecodeString(body.Data[0].Embedding)
+	if err != nil {
+		t.Fatalf("embedding is not base64: %v", err)
+	}
+	if len(buf) != 4*len(vec) {
+		t.Fatalf("decoded %d bytes, want %d", len(buf), 4*len(vec))
+	}
+	for i, want := range vec {
+		if got := math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])); got != want {
+			t.Errorf("component %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// Unknown formats are rejected; float stays the default.
+	resp = doPost(t, client, "/v1/embeddings",
+		[]byte(`{"model":"text-embedding-3-small","input":"hello","encoding_format":"hex"}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("encoding_format hex: status = %d, want 400", resp.StatusCode)
+	}
+	resp = doPost(t, client, "/v1/embeddings", []byte(`{"model":"text-embedding-3-small","input":"hello"}`))
+	var floats struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(readBody(t, resp), &floats); err != nil || len(floats.Data) != 1 || len(floats.Data[0].Embedding) != len(vec) {
+		t.Errorf("default encoding: want a float array, got %+v (%v)", floats, err)
+	}
+}
+
+func TestHandleEmbeddings_Dimensions(t *testing.T) {
+	var got *int
+	openai := &embedProvider{
+		funcProvider: okProvider("openai"),
+		embedFn: func(_ context.Context, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+			got = req.Dimensions
+			return &providers.EmbeddingResponse{
+				Model: req.Model,
+				Data:  []providers.EmbeddingData{{Index: 0, Embedding: []float32{1}}},
+			}, nil
+		},
+	}
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"openai": openai}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/embeddings",
+		[]byte(`{"model":"text-embedding-3-small","input":"hello","dimensions":256}`))
+	if raw := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, raw)
+	}
+	if got == nil || *got != 256 {
+		t.Errorf("provider got dimensions %v, want 256", got)
+	}
+
+	got = nil
+	resp = doPost(t, client, "/v1/embeddings", []byte(`{"model":"text-embedding-3-small","input":"hello"}`))
+	readBody(t, resp)
+	if got != nil {
+		t.Errorf("provider got dimensions %d, want none when the client sent none", *got)
+	}
+
+	resp = doPost(t, client, "/v1/embeddings",
+		[]byte(`{"model":"text-embedding-3-small","input":"hello","dimensions":0}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("dimensions 0: status = %d, want 400", resp.StatusCode)
+	}
+}
+
 // --- handleChatCompletions / handleCompletions (via in-memory server) --------
 
 func TestHandleChatCompletions_DelegatesToDispatch(t *testing.T) {
@@ -227,8 +500,171 @@ func TestHandleCompletions_DelegatesToDispatch(t *testing.T) {
 	}
 }
 
+// --- handleModels -----------------------------------------------------------
+
+func TestHandleModels_OnlyConfiguredProviders(t *testing.T) {
+	gw := NewGateway(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleModels(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("expected 200, got %d", ctx.Response.StatusCode())
+	}
+
+	var resp struct {
+		Object string        `json:"object"`
+		Data   []modelObject `json:"data"`
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &resp); err != nil {
+		t.Fatalf("failed to parse models response: %v", err)
+	}
+	if resp.Object != "list" {
+		t.Errorf("expected object=list, got %q", resp.Object)
+	}
+
+	ids := make(map[string]bool, len(resp.Data))
+	for _, m := range resp.Data {
+		if m.OwnedBy != "openai" {
+			t.Errorf("model %q owned_by %q, want only openai", m.ID, m.OwnedBy)
+		}
+		if m.Object != "model" {
+			t.Errorf("model %q object = %q, want model", m.ID, m.Object)
+		}
+		ids[m.ID] = true
+	}
+	if !ids["gpt-4o"] || !ids["text-embedding-3-small"] {
+		t.Errorf("expected chat and embedding models, got %v", ids)
+	}
+}
+
+func TestHandleModels_NoProviders(t *testing.T) {
+	gw := NewGateway(context.Background(), nil, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleModels(ctx)
+
+	var resp struct {
+		Data []modelObject `json:"data"`
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &resp); err != nil {
+		t.Fatalf("failed to parse models response: %v", err)
+	}
+	if resp.Data == nil || len(resp.Data) != 0 {
+		t.Errorf("expected empty data array, got %v", resp.Data)
+	}
+}
+
+// --- request body limit -----------------------------------------------------
+
+func TestServer_RejectsOversizedBody(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil, nil, GatewayOptions{MaxRequestBytes: 1024})
+	t.Cleanup(gw.health.Close)
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	handler := func(ctx *fasthttp.RequestCtx) {
+		switch string(ctx.Path()) {
+		case "/v1/chat/completions", "/v1/completions":
+			gw.dispatchChat(ctx)
+		case "/v1/embeddings":
+			gw.dispatchEmbeddings(ctx)
+		}
+	}
+	go func() { _ = gw.newServer(handler).Serve(ln) }()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return ln.Dial() },
+	}}
+
+	big := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"` +
+		strings.Repeat("a", 2048) + `"}]}`)
+	for _, path := range []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"} {
+		resp := doPost(t, client, path, big)
+		body := readBody(t, resp)
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: status = %d, want 413", path, resp.StatusCode)
+		}
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+				Code    string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(body, &e); err != nil || e.Error.Code != "request_too_large" ||
+			!strings.Contains(e.Error.Message, "1024 byte limit") {
+			t.Errorf("%s: body = %s", path, body)
+		}
+	}
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("under-limit request: status = %d, want 200", resp.StatusCode)
+	}
+}
+
 // --- writeJSON --------------------------------------------------------------
 
+// --- handleStats ------------------------------------------------------------
+
+func TestHandleStats(t *testing.T) {
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil, nil, GatewayOptions{Metrics: metrics.New()})
+	t.Cleanup(gw.health.Close)
+
+	client, cleanup := serveRouter(t, gw)
+	defer cleanup()
+
+	for range 3 {
+		resp := doPost(t, client, "/v1/chat/completions",
+			[]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"mock"}]}`))
+		readBody(t, resp)
+	}
+
+	resp, err := client.Get("http://test/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+
+	var stats map[string]any
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"requests_by_provider", "errors_by_provider", "cache", "circuit_breakers", "in_flight"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("stats missing %q: %s", key, body)
+		}
+	}
+	if got := stats["requests_by_provider"].(map[string]any)["openai"]; got != float64(3) {
+		t.Errorf("requests_by_provider.openai = %v, want 3", got)
+	}
+	if got := stats["circuit_breakers"].(map[string]any)["openai"]; got != "closed" {
+		t.Errorf("circuit_breakers.openai = %v, want closed", got)
+	}
+	if _, ok := stats["cache"].(map[string]any)["hit_rate"]; !ok {
+		t.Errorf("cache section missing hit_rate: %s", body)
+	}
+}
+
+func TestHandleStats_MetricsDisabled(t *testing.T) {
+	gw := NewGateway(context.Background(), nil, nil)
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleStats(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("status = %d, want 404", ctx.Response.StatusCode())
+	}
+}
+
 func TestWriteJSON(t *testing.T) {
 	ctx := &fasthttp.RequestCtx{}
 	writeJSON(ctx, map[string]string{"key": "value"})
@@ -1,18 +1,49 @@
 package proxy
 
 import (
+	"strings"
+
 	"github.com/nulpointcorp/llm-gateway/internal/providers"
 )
 
+// vertexPrefix routes any model to Vertex AI, which strips it to derive the
+// Vertex model ID.
+const vertexPrefix = "vertexai-"
+
 // resolveProvider returns the provider name for the given chat/completion model.
-// Falls back to "openai" if the model is unknown.
+// Models under a configured prefix (providers.ModelPrefixes) route to its
+// provider, those with the "vertexai-" prefix to "vertexai", Bedrock
+// inference profile IDs and ARNs to "bedrock". Falls back to "openai" if the
+// model is unknown.
 func resolveProvider(model string) string {
 	if name, ok := providers.ModelAliases[model]; ok {
 		return name
 	}
+	if name, ok := providers.ProviderForPrefix(model); ok {
+		return name
+	}
+	if strings.HasPrefix(model, vertexPrefix) {
+		return "vertexai"
+	}
+	if providers.IsBedrockInferenceProfile(model) {
+		return "bedrock"
+	}
 	return "openai"
 }
 
+// resolveRerankProvider returns the provider name for the given rerank model:
+// its RerankModelAliases entry, else the provider of a configured model
+// prefix. Any other model yields "", as there is no default reranker.
+func resolveRerankProvider(model string) string {
+	if name, ok := providers.RerankModelAliases[model]; ok {
+		return name
+	}
+	if name, ok := providers.ProviderForPrefix(model); ok {
+		return name
+	}
+	return ""
+}
+
 // resolveEmbeddingProvider returns the provider name for the given embedding model.
 // It checks EmbeddingModelAliases first, then ModelAliases for provider detection,
 // and falls back to "openai".
@@ -25,5 +56,8 @@ func resolveEmbeddingProvider(model string) string {
 	if name, ok := providers.ModelAliases[model]; ok {
 		return name
 	}
+	if strings.HasPrefix(model, vertexPrefix) {
+		return "vertexai"
+	}
 	return "openai"
 }
@@ -1,7 +1,15 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/providers/openaicompat"
 )
 
 func TestResolveProvider_KnownModels(t *testing.T) {
@@ -51,3 +59,150 @@ func TestResolveProvider_EmptyString(t *testing.T) {
 		t.Errorf("resolveProvider('') = %q, want 'openai'", got)
 	}
 }
+
+// withModelAliases applies overrides to the global routing tables and
+// restores the built-in tables when the test ends.
+func withModelAliases(t *testing.T, overrides map[string]string) {
+	t.Helper()
+	chat, embed := maps.Clone(providers.ModelAliases), maps.Clone(providers.EmbeddingModelAliases)
+	t.Cleanup(func() {
+		providers.ModelAliases, providers.EmbeddingModelAliases = chat, embed
+	})
+	providers.ApplyModelAliases(overrides)
+}
+
+func TestApplyModelAliases_OverridesExistingAlias(t *testing.T) {
+	withModelAliases(t, map[string]string{
+		"gpt-4o":                 "azure",
+		"text-embedding-3-small": "mistral",
+	})
+
+	if got := resolveProvider("gpt-4o"); got != "azure" {
+		t.Errorf("resolveProvider(gpt-4o) = %q, want azure", got)
+	}
+	if got := resolveEmbeddingProvider("text-embedding-3-small"); got != "mistral" {
+		t.Errorf("resolveEmbeddingProvider(text-embedding-3-small) = %q, want mistral", got)
+	}
+	if got := resolveProvider("gpt-4"); got != "openai" {
+		t.Errorf("unrelated alias changed: resolveProvider(gpt-4) = %q", got)
+	}
+}
+
+func TestApplyModelAliases_AddsNewAlias(t *testing.T) {
+	withModelAliases(t, map[string]string{"acme-chat-v2": "groq"})
+
+	if got := resolveProvider("acme-chat-v2"); got != "groq" {
+		t.Errorf("resolveProvider(acme-chat-v2) = %q, want groq", got)
+	}
+	if got := resolveEmbeddingProvider("acme-chat-v2"); got != "groq" {
+		t.Errorf("resolveEmbeddingProvider(acme-chat-v2) = %q, want groq", got)
+	}
+}
+
+func TestResolveProvider_BedrockInferenceProfile(t *testing.T) {
+	for _, model := range []string{
+		"us.anthropic.claude-3-7-sonnet-20250219-v1:0",
+		"eu.meta.llama3-2-3b-instruct-v1:0",
+		"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.amazon.nova-pro-v1:0",
+	} {
+		if got := resolveProvider(model); got != "bedrock" {
+			t.Errorf("resolveProvider(%q) = %q, want 'bedrock'", model, got)
+		}
+	}
+	if got := resolveProvider("us.unknown-model"); got != "openai" {
+		t.Errorf("resolveProvider(us.unknown-model) = %q, want 'openai'", got)
+	}
+}
+
+func TestResolveProvider_VertexPrefix(t *testing.T) {
+	if got := resolveProvider("vertexai-gemini-2.0-pro-exp"); got != "vertexai" {
+		t.Errorf("resolveProvider(vertexai-gemini-2.0-pro-exp) = %q, want 'vertexai'", got)
+	}
+	for _, model := range []string{"vertexai-text-embedding-004", "vertexai-text-multilingual-embedding-002"} {
+		if got := resolveEmbeddingProvider(model); got != "vertexai" {
+			t.Errorf("resolveEmbeddingProvider(%q) = %q, want 'vertexai'", model, got)
+		}
+	}
+}
+
+// withModelPrefixes installs prefixes as the prefix routing table for the
+// duration of the test.
+func withModelPrefixes(t *testing.T, prefixes map[string]string) {
+	t.Helper()
+	saved := providers.ModelPrefixes
+	t.Cleanup(func() { providers.ModelPrefixes = saved })
+	providers.ModelPrefixes = prefixes
+}
+
+func TestResolveProvider_CustomPrefix(t *testing.T) {
+	withModelPrefixes(t, map[string]string{"mylocal/": "mylocal", "mylocal/big/": "bigbox"})
+
+	tests := []struct {
+		model    string
+		expected string
+	}{
+		{"mylocal/llama3", "mylocal"},
+		{"mylocal/big/llama3-70b", "bigbox"},
+		{"gpt-4o", "openai"},
+		{"other/llama3", "openai"},
+	}
+	for _, tt := range tests {
+		if got := resolveProvider(tt.model); got != tt.expected {
+			t.Errorf("resolveProvider(%q) = %q, want %q", tt.model, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveRerankProvider(t *testing.T) {
+	withModelPrefixes(t, map[string]string{"jina/": "jina"})
+
+	tests := []struct {
+		model    string
+		expected string
+	}{
+		{"Salesforce/Llama-Rank-V1", "together"},
+		{"jina/jina-reranker-v2-base-multilingual", "jina"},
+		{"gpt-4o", ""},
+		{"rerank-unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveRerankProvider(tt.model); got != tt.expected {
+			t.Errorf("resolveRerankProvider(%q) = %q, want %q", tt.model, got, tt.expected)
+		}
+	}
+}
+
+func TestDispatchChat_CustomEndpointByPrefix(t *testing.T) {
+	var gotModel, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel, _ = body["model"].(string)
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","created":0,"model":"llama3",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	withModelPrefixes(t, map[string]string{"mylocal/": "mylocal"})
+	local := openaicompat.New("mylocal", "", srv.URL,
+		openaicompat.WithModelPrefix("mylocal/"), openaicompat.WithNoAuth())
+	gw := NewGateway(context.Background(), map[string]providers.Provider{"mylocal": local}, nil)
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"mylocal/llama3","messages":[{"role":"user","content":"hi"}]}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	if gotModel != "llama3" {
+		t.Errorf("upstream model = %q, want llama3 (prefix stripped)", gotModel)
+	}
+	if gotAuth != "" {
+		t.Errorf("keyless endpoint got Authorization %q", gotAuth)
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// Policies for sampling parameters sent to a model that rejects them.
+const (
+	// SamplingPolicyStrip drops the parameters and reports them in the
+	// X-Gateway-Warning response header.
+	SamplingPolicyStrip = "strip"
+	// SamplingPolicyReject answers 400 unsupported_parameter.
+	SamplingPolicyReject = "reject"
+)
+
+// checkSamplingParams applies the sampling policy to a request for a model
+// that does not accept temperature or top_p (see
+// providers.SupportsSampling), sparing an upstream 400. It reports whether
+// the request may proceed; on rejection the error response is written.
+func (g *Gateway) checkSamplingParams(ctx *fasthttp.RequestCtx, req *providers.ProxyRequest) bool {
+	if providers.SupportsSampling(req.Model) {
+		return true
+	}
+	var set []string
+	if req.TemperatureSet || req.Temperature != 0 {
+		set = append(set, "temperature")
+	}
+	if req.TopP != nil {
+		set = append(set, "top_p")
+	}
+	if len(set) == 0 {
+		return true
+	}
+
+	if g.samplingPolicy == SamplingPolicyReject {
+		apierr.Write(ctx, fasthttp.StatusBadRequest,
+			fmt.Sprintf("model %q does not support %s", req.Model, strings.Join(quoteAll(set), " or ")),
+			apierr.TypeInvalidRequest, apierr.CodeUnsupportedParameter)
+		return false
+	}
+	req.Temperature = 0
+	req.TemperatureSet = false
+	req.TopP = nil
+	ctx.Response.Header.Set("X-Gateway-Warning",
+		fmt.Sprintf("removed %s: not supported by model %s", strings.Join(set, ", "), req.Model))
+	return true
+}
+
+// quoteAll wraps each name in single quotes, as in the gateway's other
+// parameter errors.
+func quoteAll(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = "'" + n + "'"
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestDispatchChat_SamplingPolicy(t *testing.T) {
+	const body = `{"model":"o3-mini","temperature":0.7,"top_p":0.9,"messages":[{"role":"user","content":"hi"}]}`
+
+	t.Run("strip and warn", func(t *testing.T) {
+		var got *providers.ProxyRequest
+		gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{})
+		client, cleanup := serveGateway(t, gw)
+		defer cleanup()
+
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		if b := readBody(t, resp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", resp.StatusCode, b)
+		}
+		if got.Temperature != 0 || got.TopP != nil {
+			t.Errorf("provider got temperature %v, top_p %v; want both removed", got.Temperature, got.TopP)
+		}
+		if w := resp.Header.Get("X-Gateway-Warning"); !strings.Contains(w, "temperature, top_p") {
+			t.Errorf("X-Gateway-Warning = %q, want the removed parameters", w)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		var got *providers.ProxyRequest
+		gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{SamplingPolicy: SamplingPolicyReject})
+		client, cleanup := serveGateway(t, gw)
+		defer cleanup()
+
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		b := readBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(b), "unsupported_parameter") {
+			t.Fatalf("status = %d, body = %s; want 400 unsupported_parameter", resp.StatusCode, b)
+		}
+		if got != nil {
+			t.Error("a rejected request must not reach the provider")
+		}
+	})
+
+	t.Run("other models keep sampling parameters", func(t *testing.T) {
+		var got *providers.ProxyRequest
+		gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{SamplingPolicy: SamplingPolicyReject})
+		client, cleanup := serveGateway(t, gw)
+		defer cleanup()
+
+		resp := doPost(t, client, "/v1/chat/completions", []byte(strings.Replace(body, "o3-mini", "gpt-4o", 1)))
+		if b := readBody(t, resp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", resp.StatusCode, b)
+		}
+		if got.Temperature != 0.7 || got.TopP == nil {
+			t.Errorf("provider got temperature %v, top_p %v; want them forwarded", got.Temperature, got.TopP)
+		}
+	})
+}
+
+func TestDispatchChat_SamplingPolicyExplicitZeroTemperature(t *testing.T) {
+	const body = `{"model":"o3-mini","temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+
+	t.Run("reject", func(t *testing.T) {
+		var got *providers.ProxyRequest
+		gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{SamplingPolicy: SamplingPolicyReject})
+		client, cleanup := serveGateway(t, gw)
+		defer cleanup()
+
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		b := readBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(b), "'temperature'") {
+			t.Fatalf("status = %d, body = %s; want 400 naming temperature", resp.StatusCode, b)
+		}
+		if got != nil {
+			t.Error("a rejected request must not reach the provider")
+		}
+	})
+
+	t.Run("strip and warn", func(t *testing.T) {
+		var got *providers.ProxyRequest
+		gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{})
+		client, cleanup := serveGateway(t, gw)
+		defer cleanup()
+
+		resp := doPost(t, client, "/v1/chat/completions", []byte(body))
+		if b := readBody(t, resp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", resp.StatusCode, b)
+		}
+		if w := resp.Header.Get("X-Gateway-Warning"); !strings.Contains(w, "removed temperature") {
+			t.Errorf("X-Gateway-Warning = %q, want temperature reported as removed", w)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// shadowRoute is the route label under which shadow attempts are recorded,
+// keeping them apart from live traffic in the upstream metrics.
+const shadowRoute = "shadow"
+
+// maybeShadow mirrors a sample of successfully served requests to the shadow
+// provider in the background. The shadow response is discarded; only its
+// latency, token usage and errors are recorded. Requests already served by
+// the shadow provider are not mirrored.
+func (g *Gateway) maybeShadow(req *providers.ProxyRequest, servedBy string) {
+	if g.shadowProvider == "" || g.shadowProvider == servedBy || g.shadowSampleRate <= 0 {
+		return
+	}
+	prov, ok := g.providers[g.shadowProvider]
+	if !ok {
+		return
+	}
+	if g.shadowSampleRate < 1 && rand.Float64() >= g.shadowSampleRate {
+		return
+	}
+
+	// The shadow always runs non-streaming and with the configured key: a
+	// client-supplied key belongs to the live provider, not the shadow.
+	shadowReq := *req
+	shadowReq.Stream = false
+	shadowReq.APIKey = ""
+
+	go g.runShadow(prov, &shadowReq)
+}
+
+// runShadow sends req to the shadow provider under its own timeout, derived
+// from the gateway's base context so it never holds up the client.
+func (g *Gateway) runShadow(prov providers.Provider, req *providers.ProxyRequest) {
+	ctx, cancel := context.WithTimeout(g.baseCtx, g.providerTimeout)
+	defer cancel()
+
+	name := prov.Name()
+	start := time.Now()
+	resp, err := prov.Request(ctx, req)
+	dur := time.Since(start)
+
+	if err != nil {
+		reason := classifyError(err)
+		if g.metrics != nil {
+			g.metrics.ObserveUpstreamAttempt(name, shadowRoute, reason, dur)
+		}
+		g.log.WarnContext(ctx, "shadow_request_failed",
+			slog.String("request_id", req.RequestID),
+			slog.String("provider", name),
+			slog.String("reason", reason),
+			slog.Int64("latency_ms", dur.Milliseconds()),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	if g.metrics != nil {
+		g.metrics.ObserveUpstreamAttempt(name, shadowRoute, "success", dur)
+		g.metrics.AddTokens(name, shadowRoute, resp.Usage.InputTokens, resp.Usage.OutputTokens, false)
+	}
+	g.log.DebugContext(ctx, "shadow_request_ok",
+		slog.String("request_id", req.RequestID),
+		slog.String("provider", name),
+		slog.Int("input_tokens", resp.Usage.InputTokens),
+		slog.Int("output_tokens", resp.Usage.OutputTokens),
+		slog.Int64("latency_ms", dur.Milliseconds()),
+	)
+}
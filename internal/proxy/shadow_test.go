@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+func TestDispatchChat_ShadowProviderInvokedButNotReturned(t *testing.T) {
+	shadowed := make(chan *providers.ProxyRequest, 1)
+	shadow := &funcProvider{
+		name: "groq",
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			shadowed <- req
+			return &providers.ProxyResponse{ID: "shadow", Model: req.Model, Content: "SHADOW OUTPUT"}, nil
+		},
+	}
+
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+		"groq":   shadow,
+	}, nil, nil, GatewayOptions{ShadowProvider: "groq", ShadowSampleRate: 1})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	body := string(readBody(t, resp))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+	if strings.Contains(body, "SHADOW") {
+		t.Errorf("shadow output leaked to the client: %s", body)
+	}
+	if !strings.Contains(body, "hello from openai") {
+		t.Errorf("expected the live response, got %s", body)
+	}
+
+	select {
+	case req := <-shadowed:
+		if req.Stream {
+			t.Error("shadow request should be sent non-streaming")
+		}
+		if req.Model != "gpt-4o" {
+			t.Errorf("shadow model = %q, want gpt-4o", req.Model)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shadow provider was not invoked")
+	}
+}
+
+func TestMaybeShadow_Disabled(t *testing.T) {
+	called := make(chan struct{}, 1)
+	shadow := &funcProvider{
+		name: "groq",
+		requestFn: func(_ context.Context, _ *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			called <- struct{}{}
+			return &providers.ProxyResponse{}, nil
+		},
+	}
+	provs := map[string]providers.Provider{"groq": shadow}
+	req := &providers.ProxyRequest{Model: "gpt-4o"}
+
+	for _, opts := range []GatewayOptions{
+		{ShadowProvider: "groq", ShadowSampleRate: 0},
+		{ShadowProvider: "", ShadowSampleRate: 1},
+	} {
+		gw := NewGatewayWithOptions(context.Background(), provs, nil, nil, opts)
+		gw.maybeShadow(req, "openai")
+		gw.health.Close()
+	}
+	// A request served by the shadow provider itself is not mirrored.
+	gw := NewGatewayWithOptions(context.Background(), provs, nil, nil,
+		GatewayOptions{ShadowProvider: "groq", ShadowSampleRate: 1})
+	defer gw.health.Close()
+	gw.maybeShadow(req, "groq")
+
+	select {
+	case <-called:
+		t.Error("shadow provider should not have been invoked")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// decodeRequest unmarshals a request body into v, a pointer to one of the
+// inbound request structs. With GatewayOptions.StrictValidation, unknown
+// fields and values of the wrong type are rejected; see decodeStrict. The
+// error is ready to be sent to the client.
+func (g *Gateway) decodeRequest(body []byte, v any) error {
+	if g.strictValidation {
+		return decodeStrict(body, v)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+	return nil
+}
+
+// decodeStrict is json.Unmarshal that also rejects fields v does not
+// declare, matched case-sensitively and at any depth, so a typo such as
+// "temperatur" is reported rather than dropped. Every offending top-level
+// field is named in one error instead of only the first.
+func decodeStrict(body []byte, v any) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+	var unknown, invalid []string
+	for name, raw := range fields {
+		typ, ok := known[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(reflect.New(typ).Interface()); err != nil {
+			invalid = append(invalid, describeFieldError(name, err))
+		}
+	}
+	if len(unknown) > 0 || len(invalid) > 0 {
+		sort.Strings(unknown)
+		sort.Strings(invalid)
+		var problems []string
+		if len(unknown) > 0 {
+			problems = append(problems, "unknown fields: "+strings.Join(unknown, ", "))
+		}
+		if len(invalid) > 0 {
+			problems = append(problems, "invalid fields: "+strings.Join(invalid, ", "))
+		}
+		return fmt.Errorf("request validation failed: %s", strings.Join(problems, "; "))
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+	return nil
+}
+
+// describeFieldError explains why the value of the top-level field name
+// could not be decoded.
+func describeFieldError(name string, err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			name += "." + typeErr.Field
+		}
+		return fmt.Sprintf("%s (expected %s, got %s)", name, jsonTypeName(typeErr.Type), typeErr.Value)
+	}
+	if unknown, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return fmt.Sprintf("%s (unknown field %s)", name, unknown)
+	}
+	return fmt.Sprintf("%s (%s)", name, err.Error())
+}
+
+// jsonTypeName names the JSON type a Go type decodes from.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonFieldCache maps a struct type to its jsonFields.
+var jsonFieldCache sync.Map // reflect.Type → map[string]reflect.Type
+
+// jsonFields returns the JSON field names of struct type t and their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if cached, ok := jsonFieldCache.Load(t); ok {
+		return cached.(map[string]reflect.Type)
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
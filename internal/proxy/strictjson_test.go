@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+func TestDispatchChat_StrictValidation(t *testing.T) {
+	gw := newTransformGateway(t, okProvider("openai"), GatewayOptions{StrictValidation: true})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     []string // substrings of the error message
+	}{
+		{
+			name:     "unknown fields",
+			body:     `{"model":"gpt-4o","temperatur":2,"Max_Tokens":5,"messages":[{"role":"user","content":"hi"}]}`,
+			wantCode: http.StatusBadRequest,
+			want:     []string{"unknown fields: Max_Tokens, temperatur"},
+		},
+		{
+			name:     "wrong types",
+			body:     `{"model":"gpt-4o","temperature":"hot","max_tokens":1.5,"messages":[{"role":"user","content":"hi"}]}`,
+			wantCode: http.StatusBadRequest,
+			want: []string{
+				"max_tokens (expected integer, got number 1.5)",
+				"temperature (expected number, got string)",
+			},
+		},
+		{
+			name:     "nested unknown field",
+			body:     `{"model":"gpt-4o","stream_options":{"include_usag":true},"messages":[{"role":"user","content":"hi"}]}`,
+			wantCode: http.StatusBadRequest,
+			want:     []string{`stream_options (unknown field \"include_usag\")`},
+		},
+		{
+			name:     "valid",
+			body:     `{"model":"gpt-4o","temperature":0.5,"messages":[{"role":"user","content":"hi"}]}`,
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/chat/completions", []byte(tt.body))
+			body := readBody(t, resp)
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("status = %d, body = %s; want %d", resp.StatusCode, body, tt.wantCode)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(body), w) {
+					t.Errorf("body %s does not contain %q", body, w)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchChat_LenientByDefault(t *testing.T) {
+	gw := newTransformGateway(t, okProvider("openai"), GatewayOptions{})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","temperatur":2,"messages":[{"role":"user","content":"hi"}]}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, body = %s; unknown fields should be ignored by default", resp.StatusCode, body)
+	}
+}
+
+// TestDispatchChat_StrictAcceptsOpenAISDK sends a request built by the
+// official OpenAI SDK, using standard fields the gateway does not forward,
+// and expects strict validation to accept it.
+func TestDispatchChat_StrictAcceptsOpenAISDK(t *testing.T) {
+	gw := newTransformGateway(t, okProvider("openai"), GatewayOptions{StrictValidation: true})
+	httpClient, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	client := openai.NewClient(
+		option.WithBaseURL("http://test/v1"),
+		option.WithAPIKey("sk-test"),
+		option.WithHTTPClient(httpClient),
+		option.WithMaxRetries(0),
+	)
+	resp, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model: openai.ChatModelGPT4o,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Be brief."),
+			{OfUser: &openai.ChatCompletionUserMessageParam{
+				Content: openai.ChatCompletionUserMessageParamContentUnion{OfString: openai.String("hi")},
+				Name:    openai.String("alice"),
+			}},
+		},
+		User:              openai.String("user-123"),
+		ParallelToolCalls: openai.Bool(false),
+		LogitBias:         map[string]int64{"50256": -100},
+		Metadata:          shared.Metadata{"team": "search"},
+		Store:             openai.Bool(false),
+		ServiceTier:       openai.ChatCompletionNewParamsServiceTierAuto,
+	})
+	if err != nil {
+		t.Fatalf("strict validation rejected an OpenAI SDK request: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "hello from openai" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestDispatchResponses_StrictValidation(t *testing.T) {
+	gw := newTransformGateway(t, okProvider("openai"), GatewayOptions{StrictValidation: true})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/responses",
+		[]byte(`{"model":"gpt-4o","input":"hi","temperatur":2,"max_output_token":5}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "unknown fields: max_output_token, temperatur") {
+		t.Errorf("status = %d, body = %s; want 400 naming the unknown fields", resp.StatusCode, body)
+	}
+
+	resp = doPost(t, client, "/v1/responses",
+		[]byte(`{"model":"gpt-4o","input":"hi","user":"user-123","store":false,"metadata":{"team":"search"}}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, body = %s; standard fields should be accepted", resp.StatusCode, body)
+	}
+}
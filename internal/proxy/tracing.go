@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+	"github.com/valyala/fasthttp"
+)
+
+// Span attribute keys.
+const (
+	attrRoute      = "http.route"
+	attrStatus     = "http.status_code"
+	attrModel      = "gateway.model"
+	attrProvider   = "gateway.provider"
+	attrCache      = "gateway.cache"
+	attrAttempt    = "gateway.attempt"
+	attrOutcome    = "gateway.outcome"
+	attrRateResult = "gateway.ratelimit"
+)
+
+// startSpan starts a span under ctx, or returns ctx and a no-op span when
+// tracing is disabled.
+func (g *Gateway) startSpan(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, tracing.Span) {
+	if g.tracer == nil {
+		return tracing.Noop().Start(ctx, name)
+	}
+	return g.tracer.Start(ctx, name, attrs...)
+}
+
+// startRequestSpan starts the root span for an inbound request, continuing
+// the caller's trace when it sent a valid traceparent header.
+func (g *Gateway) startRequestSpan(ctx *fasthttp.RequestCtx, route string) (context.Context, tracing.Span) {
+	if g.tracer == nil {
+		return tracing.Noop().Start(ctx, "")
+	}
+	var parent context.Context = ctx
+	if sc, ok := tracing.ParseTraceparent(string(ctx.Request.Header.Peek("traceparent"))); ok {
+		parent = tracing.ContextWithRemoteParent(ctx, sc)
+	}
+	return g.tracer.Start(parent, "gateway.dispatch", tracing.String(attrRoute, route))
+}
+
+// endRequestSpan records the outcome of a request on its root span and ends
+// it. 5xx responses mark the span as failed.
+func endRequestSpan(span tracing.Span, status int, provider, cacheLabel string) {
+	span.SetAttributes(
+		tracing.Int(attrStatus, status),
+		tracing.String(attrProvider, provider),
+		tracing.String(attrCache, cacheLabel),
+	)
+	if status >= fasthttp.StatusInternalServerError {
+		span.RecordError(fmt.Errorf("HTTP %d", status))
+	}
+	span.End()
+}
+
+// endAttemptSpan ends a provider attempt span with its outcome: "success"
+// or the classifyError category.
+func endAttemptSpan(span tracing.Span, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = classifyError(err)
+		span.RecordError(err)
+	}
+	span.SetAttributes(tracing.String(attrOutcome, outcome))
+	span.End()
+}
+
+// rateLimitResult is the attrRateResult value for a limiter decision.
+func rateLimitResult(allowed bool, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case !allowed:
+		return "blocked"
+	default:
+		return "allowed"
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/tracing"
+)
+
+func TestTracing_FailoverSpanTree(t *testing.T) {
+	rec := tracing.NewRecorder()
+	failing := &funcProvider{
+		name: "openai",
+		requestFn: func(context.Context, *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			return nil, &providerError{status: 503, msg: "unavailable"}
+		},
+	}
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai":    failing,
+		"anthropic": okProvider("anthropic"),
+	}, newStubCache(), nil, GatewayOptions{
+		Tracer:         tracing.New(rec),
+		FallbackChains: map[string][]string{"openai": {"anthropic"}},
+		Backoff:        BackoffConfig{BaseDelay: 1},
+	})
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, err := http.NewRequest("POST", "http://test/v1/chat/completions",
+		readerFromBytes([]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", traceparent)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+
+	byName := map[string][]tracing.SpanData{}
+	for _, s := range rec.Ended() {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	one := func(name string) tracing.SpanData {
+		t.Helper()
+		if len(byName[name]) != 1 {
+			t.Fatalf("want one %q span, got %d", name, len(byName[name]))
+		}
+		return byName[name][0]
+	}
+
+	// The root continues the caller's trace.
+	root := one("gateway.dispatch")
+	remote, _ := tracing.ParseTraceparent(traceparent)
+	if root.TraceID != remote.TraceID || root.ParentSpanID != remote.SpanID {
+		t.Errorf("root trace/parent = %s/%s, want %s/%s",
+			root.TraceID, root.ParentSpanID, remote.TraceID, remote.SpanID)
+	}
+	for key, want := range map[string]any{
+		attrRoute:    "chat_completions",
+		attrModel:    "gpt-4o",
+		attrProvider: "anthropic",
+		attrCache:    "miss",
+		attrStatus:   int64(200),
+	} {
+		if got := root.Attr(key); got != want {
+			t.Errorf("root %s = %v, want %v", key, got, want)
+		}
+	}
+
+	for _, name := range []string{"cache.get", "cache.set", "provider.failover"} {
+		if s := one(name); s.ParentSpanID != root.SpanID || s.TraceID != root.TraceID {
+			t.Errorf("%s is not a child of the root span", name)
+		}
+	}
+
+	failover := one("provider.failover")
+	attempts := byName["provider.attempt"]
+	if len(attempts) != 2 {
+		t.Fatalf("want 2 provider.attempt spans, got %d", len(attempts))
+	}
+	want := []struct {
+		provider, outcome string
+		failed            bool
+	}{
+		{"openai", "http_503", true},
+		{"anthropic", "success", false},
+	}
+	for i, a := range attempts {
+		if a.ParentSpanID != failover.SpanID {
+			t.Errorf("attempt %d is not a child of provider.failover", i)
+		}
+		if a.Attr(attrProvider) != want[i].provider || a.Attr(attrOutcome) != want[i].outcome {
+			t.Errorf("attempt %d = %v/%v, want %s/%s", i,
+				a.Attr(attrProvider), a.Attr(attrOutcome), want[i].provider, want[i].outcome)
+		}
+		if a.Attr(attrAttempt) != int64(i+1) {
+			t.Errorf("attempt %d numbered %v", i, a.Attr(attrAttempt))
+		}
+		if (a.Err != "") != want[i].failed {
+			t.Errorf("attempt %d error = %q", i, a.Err)
+		}
+	}
+}
+
+func TestTracing_DisabledByDefault(t *testing.T) {
+	gw := &Gateway{}
+	ctx := context.Background()
+	spanCtx, span := gw.startSpan(ctx, "x")
+	if spanCtx != ctx || span.SpanContext().IsValid() {
+		t.Error("without a tracer spans should be no-ops")
+	}
+	span.End()
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// RequestTransformer rewrites a chat request after it is parsed and before
+// the cache lookup and provider dispatch, so the cache key reflects the
+// rewritten request. It may modify req in place and return it, or return a
+// new request. An error rejects the request with 400.
+type RequestTransformer interface {
+	TransformRequest(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyRequest, error)
+}
+
+// ResponseTransformer rewrites a non-streaming chat response before it is
+// serialized and cached. Concurrent identical requests may share one
+// response, so it should return a modified copy rather than change resp in
+// place. An error fails the request with 502. Streaming responses are not
+// transformed.
+type ResponseTransformer interface {
+	TransformResponse(ctx context.Context, req *providers.ProxyRequest, resp *providers.ProxyResponse) (*providers.ProxyResponse, error)
+}
+
+// transformRequest runs the request transformers in order.
+func (g *Gateway) transformRequest(ctx context.Context, req *providers.ProxyRequest) (*providers.ProxyRequest, error) {
+	for _, t := range g.requestTransformers {
+		var err error
+		if req, err = t.TransformRequest(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// transformResponse runs the response transformers in order. Streams pass
+// through untouched.
+func (g *Gateway) transformResponse(ctx context.Context, req *providers.ProxyRequest, resp *providers.ProxyResponse) (*providers.ProxyResponse, error) {
+	if resp.Stream != nil {
+		return resp, nil
+	}
+	for _, t := range g.responseTransformers {
+		var err error
+		if resp, err = t.TransformResponse(ctx, req, resp); err != nil {
+			return nil, fmt.Errorf("response transform: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// DefaultSystemPrompt is a RequestTransformer that prepends a system message
+// to requests that carry none. Requests with a system message of their own
+// are left alone.
+type DefaultSystemPrompt struct {
+	Prompt string
+}
+
+// TransformRequest implements RequestTransformer.
+func (d DefaultSystemPrompt) TransformRequest(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyRequest, error) {
+	if d.Prompt == "" {
+		return req, nil
+	}
+	for _, m := range req.Messages {
+		if m.Role == "system" || m.Role == "developer" {
+			return req, nil
+		}
+	}
+	msgs := make([]providers.Message, 0, len(req.Messages)+1)
+	msgs = append(msgs, providers.Message{Role: "system", Content: d.Prompt})
+	req.Messages = append(msgs, req.Messages...)
+	return req, nil
+}
+
+// ParamClamp is a RequestTransformer that bounds sampling parameters. Nil
+// bounds and a zero MaxTokens leave the parameter alone.
+type ParamClamp struct {
+	// MinTemperature and MaxTemperature bound temperature, including an
+	// explicit 0. A request that sends no temperature is left alone, so
+	// MaxTemperature 0 forces greedy sampling.
+	MinTemperature *float64
+	MaxTemperature *float64
+
+	// MaxTopP caps top_p when the request sets it.
+	MaxTopP *float64
+
+	// MaxTokens caps max_tokens, and is sent as max_tokens when the request
+	// sets none.
+	MaxTokens int
+}
+
+// TransformRequest implements RequestTransformer.
+func (c ParamClamp) TransformRequest(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyRequest, error) {
+	if req.TemperatureSet || req.Temperature != 0 {
+		if c.MinTemperature != nil {
+			req.Temperature = max(req.Temperature, *c.MinTemperature)
+		}
+		if c.MaxTemperature != nil {
+			req.Temperature = min(req.Temperature, *c.MaxTemperature)
+		}
+	}
+	if c.MaxTopP != nil && req.TopP != nil && *req.TopP > *c.MaxTopP {
+		topP := *c.MaxTopP
+		req.TopP = &topP
+	}
+	if c.MaxTokens > 0 && (req.MaxTokens == 0 || req.MaxTokens > c.MaxTokens) {
+		req.MaxTokens = c.MaxTokens
+	}
+	return req, nil
+}
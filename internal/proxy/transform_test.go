@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+)
+
+// capturingProvider records the last request it served.
+func capturingProvider(name string, got **providers.ProxyRequest) *funcProvider {
+	return &funcProvider{
+		name: name,
+		requestFn: func(_ context.Context, req *providers.ProxyRequest) (*providers.ProxyResponse, error) {
+			*got = req
+			return &providers.ProxyResponse{Model: req.Model, Content: "ok"}, nil
+		},
+	}
+}
+
+func newTransformGateway(t *testing.T, prov providers.Provider, opts GatewayOptions) *Gateway {
+	t.Helper()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{"openai": prov}, nil, nil, opts)
+	t.Cleanup(gw.health.Close)
+	return gw
+}
+
+func TestDispatchChat_DefaultSystemPrompt(t *testing.T) {
+	var got *providers.ProxyRequest
+	gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{
+		RequestTransformers: []RequestTransformer{DefaultSystemPrompt{Prompt: "Be concise."}},
+	})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		body string
+		want []string // roles and contents, in order
+	}{
+		{
+			name: "injected when absent",
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{"system:Be concise.", "user:hi"},
+		},
+		{
+			name: "kept when present",
+			body: `{"model":"gpt-4o","messages":[{"role":"system","content":"Be verbose."},{"role":"user","content":"hi"}]}`,
+			want: []string{"system:Be verbose.", "user:hi"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doPost(t, client, "/v1/chat/completions", []byte(tt.body))
+			if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+			}
+			var msgs []string
+			for _, m := range got.Messages {
+				msgs = append(msgs, m.Role+":"+m.Content)
+			}
+			if strings.Join(msgs, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("messages = %q, want %q", msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchChat_ParamClamp(t *testing.T) {
+	maxTemp, maxTopP := 0.7, 0.5
+	var got *providers.ProxyRequest
+	gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{
+		RequestTransformers: []RequestTransformer{ParamClamp{MaxTemperature: &maxTemp, MaxTopP: &maxTopP, MaxTokens: 256}},
+	})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","temperature":1.5,"top_p":0.9,"messages":[{"role":"user","content":"hi"}]}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+	if got.Temperature != 0.7 {
+		t.Errorf("temperature = %v, want 0.7", got.Temperature)
+	}
+	if got.TopP == nil || *got.TopP != 0.5 {
+		t.Errorf("top_p = %v, want 0.5", got.TopP)
+	}
+	if got.MaxTokens != 256 {
+		t.Errorf("max_tokens = %d, want 256", got.MaxTokens)
+	}
+}
+
+func TestParamClamp_LeavesUnsetAndInRangeValues(t *testing.T) {
+	minTemp, maxTemp := 0.2, 1.0
+	c := ParamClamp{MinTemperature: &minTemp, MaxTemperature: &maxTemp, MaxTokens: 100}
+
+	req, _ := c.TransformRequest(context.Background(), &providers.ProxyRequest{MaxTokens: 50})
+	if req.Temperature != 0 || req.MaxTokens != 50 {
+		t.Errorf("got temperature %v, max_tokens %d; want 0 and 50", req.Temperature, req.MaxTokens)
+	}
+	req, _ = c.TransformRequest(context.Background(), &providers.ProxyRequest{Temperature: 0.1})
+	if req.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want raised to 0.2", req.Temperature)
+	}
+}
+
+func TestDispatchChat_ParamClampRaisesExplicitZeroTemperature(t *testing.T) {
+	minTemp := 0.2
+	var got *providers.ProxyRequest
+	gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{
+		RequestTransformers: []RequestTransformer{ParamClamp{MinTemperature: &minTemp}},
+	})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions",
+		[]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+	}
+	if got.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want an explicit 0 raised to 0.2", got.Temperature)
+	}
+}
+
+type rejectTransformer struct{}
+
+func (rejectTransformer) TransformRequest(context.Context, *providers.ProxyRequest) (*providers.ProxyRequest, error) {
+	return nil, errors.New("parameter 'seed' is not allowed")
+}
+
+type suffixTransformer struct{ suffix string }
+
+func (s suffixTransformer) TransformResponse(_ context.Context, _ *providers.ProxyRequest, resp *providers.ProxyResponse) (*providers.ProxyResponse, error) {
+	out := *resp
+	out.Content += s.suffix
+	return &out, nil
+}
+
+func TestDispatchChat_TransformerErrorRejects(t *testing.T) {
+	var got *providers.ProxyRequest
+	gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{
+		RequestTransformers: []RequestTransformer{rejectTransformer{}},
+	})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "seed") {
+		t.Errorf("status = %d, body = %s; want 400 naming the parameter", resp.StatusCode, body)
+	}
+	if got != nil {
+		t.Error("a rejected request must not reach the provider")
+	}
+}
+
+func TestDispatchChat_ResponseTransformers(t *testing.T) {
+	var got *providers.ProxyRequest
+	gw := newTransformGateway(t, capturingProvider("openai", &got), GatewayOptions{
+		ResponseTransformers: []ResponseTransformer{suffixTransformer{" one"}, suffixTransformer{" two"}},
+	})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPost(t, client, "/v1/chat/completions", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	var out outboundResponse
+	if err := json.Unmarshal(readBody(t, resp), &out); err != nil || len(out.Choices) == 0 {
+		t.Fatalf("decode response: %v", err)
+	}
+	if c := out.Choices[0].Message.Content; c != "ok one two" {
+		t.Errorf("content = %q, want transformers applied in order", c)
+	}
+}
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+	"github.com/nulpointcorp/llm-gateway/pkg/apierr"
+	"github.com/valyala/fasthttp"
+)
+
+// virtualKeyUserValue holds the *VirtualKey matched by authenticate.
+const virtualKeyUserValue = "virtual_key"
+
+// VirtualKey is a scoped client key: it authenticates like a gateway key but
+// is limited to a set of models, its own request and token rates, and a
+// token budget per period.
+type VirtualKey struct {
+	// Name identifies the key in logs, budgets and the admin API.
+	Name string
+	// Key is the plaintext key, or GatewayKeyHashPrefix and its hex SHA-256.
+	Key string
+	// Models lists the allowed model names. A trailing "*" matches a prefix;
+	// empty allows every model.
+	Models []string
+	// RPM and TPM cap requests and tokens per minute. Zero means no per-key
+	// limit; TPM is only enforced when a TPM limiter is configured.
+	RPM int
+	TPM int
+	// TokenBudget caps input+output tokens per BudgetPeriod. Zero means
+	// unlimited; a zero BudgetPeriod never resets.
+	TokenBudget  int64
+	BudgetPeriod time.Duration
+	// Priority is the scheduling tier ("high", "normal" or "low") of the
+	// key's requests when the gateway is saturated, and the highest tier its
+	// X-Priority header may ask for. Empty means normal with no ceiling.
+	Priority string
+	// SystemPrompt is prepended as a system message to the key's chat
+	// requests that carry none. It takes precedence over a global
+	// DefaultSystemPrompt transformer.
+	SystemPrompt string
+	// Workspace is the tenant the key's requests belong to. It overrides the
+	// X-Workspace-ID header, so the key cannot reach other tenants' cache
+	// entries.
+	Workspace string
+}
+
+// allowsModel reports whether the key may call model.
+func (k *VirtualKey) allowsModel(model string) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	for _, m := range k.Models {
+		if prefix, ok := strings.CutSuffix(m, "*"); ok {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		} else if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// callerID identifies the key's requests in logs and token budgets.
+func (k *VirtualKey) callerID() string { return "vk_" + k.Name }
+
+// newVirtualKeyIndex indexes keys by the hex SHA-256 of their key.
+func newVirtualKeyIndex(keys []VirtualKey) map[string]*VirtualKey {
+	if len(keys) == 0 {
+		return nil
+	}
+	index := make(map[string]*VirtualKey, len(keys))
+	for i := range keys {
+		k := &keys[i]
+		digest, ok := strings.CutPrefix(k.Key, GatewayKeyHashPrefix)
+		if !ok {
+			digest = hashKey(k.Key)
+		}
+		index[strings.ToLower(digest)] = k
+	}
+	return index
+}
+
+// SetVirtualKeyStores injects where virtual-key budgets and per-key request
+// rates are tracked. Without it both are kept in process.
+func (g *Gateway) SetVirtualKeyStores(budgets ratelimit.BudgetStore, rpm ratelimit.KeyedLimiter) {
+	g.vkBudgets = budgets
+	g.vkLimiter = rpm
+}
+
+// requestVirtualKey returns the virtual key that authenticated the request,
+// or nil.
+func requestVirtualKey(ctx *fasthttp.RequestCtx) *VirtualKey {
+	vk, _ := ctx.UserValue(virtualKeyUserValue).(*VirtualKey)
+	return vk
+}
+
+// enforceVirtualKey applies vk's model allow-list, request rate and token
+// budget to a request for model. On rejection it writes the error response
+// (403 for a disallowed model, 429 otherwise) and returns false. Store errors
+// fail open, like the other rate limiters.
+func (g *Gateway) enforceVirtualKey(ctx *fasthttp.RequestCtx, vk *VirtualKey, model string) bool {
+	if vk == nil {
+		return true
+	}
+	if !vk.allowsModel(model) {
+		apierr.Write(ctx, fasthttp.StatusForbidden,
+			fmt.Sprintf("model %q is not allowed for this key", model),
+			apierr.TypePermissionError, apierr.CodeModelNotAllowed)
+		return false
+	}
+	if vk.RPM > 0 && g.vkLimiter != nil {
+		if allowed, err := g.vkLimiter.AllowKey(ctx, vk.Name, vk.RPM); err == nil && !allowed {
+			g.log.WarnContext(ctx, "virtual_key_rate_limited", slog.String("key", vk.Name))
+			apierr.WriteRateLimit(ctx, 0)
+			return false
+		}
+	}
+	if vk.TokenBudget > 0 && g.vkBudgets != nil {
+		if spent, err := g.vkBudgets.Spent(ctx, vk.Name); err == nil && spent >= vk.TokenBudget {
+			g.log.WarnContext(ctx, "virtual_key_budget_exhausted",
+				slog.String("key", vk.Name),
+				slog.Int64("budget", vk.TokenBudget),
+			)
+			apierr.Write(ctx, fasthttp.StatusTooManyRequests,
+				fmt.Sprintf("token budget of %d exhausted for this key", vk.TokenBudget),
+				apierr.TypeRateLimitError, apierr.CodeBudgetExceeded)
+			return false
+		}
+	}
+	return true
+}
+
+// chargeVirtualKey records tokens against vk's budget. Like reconcileTPM it
+// runs off the request path.
+func (g *Gateway) chargeVirtualKey(vk *VirtualKey, tokens int) {
+	if vk == nil || vk.TokenBudget <= 0 || tokens <= 0 || g.vkBudgets == nil {
+		return
+	}
+	go func() {
+		_ = g.vkBudgets.Add(g.baseCtx, vk.Name, int64(tokens), vk.BudgetPeriod)
+	}()
+}
+
+// virtualKeyStatus is one entry of GET /admin/virtual-keys. Keys themselves
+// are never returned.
+type virtualKeyStatus struct {
+	Name         string   `json:"name"`
+	Models       []string `json:"models,omitempty"`
+	RPM          int      `json:"rpm,omitempty"`
+	TPM          int      `json:"tpm,omitempty"`
+	TokenBudget  int64    `json:"token_budget,omitempty"`
+	BudgetPeriod string   `json:"budget_period,omitempty"`
+	Spent        int64    `json:"spent"`
+	Remaining    *int64   `json:"remaining,omitempty"` // nil when unlimited
+}
+
+// handleVirtualKeys handles GET /admin/virtual-keys: every virtual key with
+// its limits and the budget spent and remaining in the current period.
+func (g *Gateway) handleVirtualKeys(ctx *fasthttp.RequestCtx) {
+	data := make([]virtualKeyStatus, 0, len(g.virtualKeys))
+	for _, vk := range g.virtualKeys {
+		st := virtualKeyStatus{
+			Name:        vk.Name,
+			Models:      vk.Models,
+			RPM:         vk.RPM,
+			TPM:         vk.TPM,
+			TokenBudget: vk.TokenBudget,
+		}
+		if vk.BudgetPeriod > 0 {
+			st.BudgetPeriod = vk.BudgetPeriod.String()
+		}
+		if g.vkBudgets != nil {
+			spent, err := g.vkBudgets.Spent(ctx, vk.Name)
+			if err != nil {
+				apierr.Write(ctx, fasthttp.StatusInternalServerError,
+					"failed to read virtual key budgets", apierr.TypeServerError, apierr.CodeInternalError)
+				return
+			}
+			st.Spent = spent
+		}
+		if vk.TokenBudget > 0 {
+			remaining := max(vk.TokenBudget-st.Spent, 0)
+			st.Remaining = &remaining
+		}
+		data = append(data, st)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+
+	body, _ := json.Marshal(struct {
+		Object string             `json:"object"`
+		Data   []virtualKeyStatus `json:"data"`
+	}{Object: "list", Data: data})
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body)
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+// doPostWithKey sends a chat request authenticated with key as a bearer token.
+func doPostWithKey(t *testing.T, client *http.Client, key, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://test/v1/chat/completions", readerFromBytes([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func newVirtualKeyGateway(t *testing.T, keys ...VirtualKey) *Gateway {
+	t.Helper()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": okProvider("openai"),
+	}, nil, nil, GatewayOptions{GatewayAPIKeys: []string{"master"}, VirtualKeys: keys})
+	t.Cleanup(gw.health.Close)
+	return gw
+}
+
+func TestVirtualKey_ModelRestriction(t *testing.T) {
+	gw := newVirtualKeyGateway(t,
+		VirtualKey{Name: "team-a", Key: "vk-a", Models: []string{"gpt-4o-mini"}},
+		VirtualKey{Name: "team-b", Key: "vk-b", Models: []string{"gpt-4o*"}},
+	)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		key, model string
+		want       int
+	}{
+		{"vk-a", "gpt-4o-mini", http.StatusOK},
+		{"vk-a", "gpt-4o", http.StatusForbidden},
+		{"vk-b", "gpt-4o-2024-08-06", http.StatusOK},
+		{"vk-b", "gpt-3.5-turbo", http.StatusForbidden},
+		{"master", "gpt-3.5-turbo", http.StatusOK},
+		{"unknown", "gpt-4o-mini", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		resp := doPostWithKey(t, client, tt.key,
+			`{"model":"`+tt.model+`","messages":[{"role":"user","content":"hi"}]}`)
+		body := readBody(t, resp)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s/%s: status = %d, want %d; body = %s", tt.key, tt.model, resp.StatusCode, tt.want, body)
+		}
+		if tt.want == http.StatusForbidden && !containsStr(string(body), "model_not_allowed") {
+			t.Errorf("%s/%s: expected model_not_allowed, got %s", tt.key, tt.model, body)
+		}
+	}
+}
+
+func TestVirtualKey_BudgetExhaustion(t *testing.T) {
+	// okProvider reports 15 tokens per request, so one request spends the budget.
+	gw := newVirtualKeyGateway(t, VirtualKey{Name: "team-a", Key: "vk-a", TokenBudget: 10, BudgetPeriod: time.Hour})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	const body = `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hi"}]}`
+	resp := doPostWithKey(t, client, "vk-a", body)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", resp.StatusCode)
+	}
+	waitFor(t, func() bool {
+		spent, _ := gw.vkBudgets.Spent(context.Background(), "team-a")
+		return spent == 15
+	})
+
+	resp = doPostWithKey(t, client, "vk-a", body)
+	respBody := readBody(t, resp)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status after budget = %d, want 429; body = %s", resp.StatusCode, respBody)
+	}
+	if !containsStr(string(respBody), "budget_exceeded") {
+		t.Errorf("expected budget_exceeded, got %s", respBody)
+	}
+
+	// The master key is not bound by the virtual key's budget.
+	resp = doPostWithKey(t, client, "master", body)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("master key status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestVirtualKey_SystemPrompt(t *testing.T) {
+	var got *providers.ProxyRequest
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": capturingProvider("openai", &got),
+	}, mc, nil, GatewayOptions{GatewayAPIKeys: []string{"master"}, VirtualKeys: []VirtualKey{
+		{Name: "team-a", Key: "vk-a", SystemPrompt: "You work for team A."},
+		{Name: "team-b", Key: "vk-b", SystemPrompt: "You work for team B."},
+	}})
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	tests := []struct {
+		name, key, body string
+		wantSystem      string
+	}{
+		{
+			name:       "injected when absent",
+			key:        "vk-a",
+			body:       `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+			wantSystem: "You work for team A.",
+		},
+		{
+			// Same body as above: a shared cache key would replay team A's
+			// response without calling the provider.
+			name:       "distinct default is not served from cache",
+			key:        "vk-b",
+			body:       `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+			wantSystem: "You work for team B.",
+		},
+		{
+			name:       "not injected when present",
+			key:        "vk-a",
+			body:       `{"model":"gpt-4o","messages":[{"role":"system","content":"Custom."},{"role":"user","content":"hi"}]}`,
+			wantSystem: "Custom.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			resp := doPostWithKey(t, client, tt.key, tt.body)
+			if body := readBody(t, resp); resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", resp.StatusCode, body)
+			}
+			if got == nil {
+				t.Fatal("request was served from cache")
+			}
+			if len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[0].Content != tt.wantSystem {
+				t.Errorf("messages = %+v, want system prompt %q first", got.Messages, tt.wantSystem)
+			}
+		})
+	}
+}
+
+func TestHandleVirtualKeys(t *testing.T) {
+	gw := newVirtualKeyGateway(t,
+		VirtualKey{Name: "team-b", Key: "vk-b"},
+		VirtualKey{Name: "team-a", Key: "vk-a", Models: []string{"gpt-4o-mini"}, TokenBudget: 100, BudgetPeriod: 24 * time.Hour},
+	)
+	_ = gw.vkBudgets.Add(context.Background(), "team-a", 30, 24*time.Hour)
+
+	ctx := &fasthttp.RequestCtx{}
+	gw.handleVirtualKeys(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d", ctx.Response.StatusCode())
+	}
+	var out struct {
+		Data []virtualKeyStatus `json:"data"`
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Data) != 2 || out.Data[0].Name != "team-a" || out.Data[1].Name != "team-b" {
+		t.Fatalf("data = %+v, want team-a then team-b", out.Data)
+	}
+	a := out.Data[0]
+	if a.Spent != 30 || a.Remaining == nil || *a.Remaining != 70 || a.BudgetPeriod != "24h0m0s" {
+		t.Errorf("team-a = %+v, want spent 30, remaining 70", a)
+	}
+	if out.Data[1].Remaining != nil {
+		t.Error("an unlimited key should not report remaining budget")
+	}
+	if containsStr(string(ctx.Response.Body()), "vk-a") {
+		t.Error("admin listing must not expose keys")
+	}
+}
+
+func TestAuthenticate_AdminRoutesRequireMasterKey(t *testing.T) {
+	gw := &Gateway{
+		gatewayKeys: newGatewayKeySet([]string{"master"}),
+		virtualKeys: newVirtualKeyIndex([]VirtualKey{{Name: "team-a", Key: "vk-a"}}),
+	}
+	handler := gw.authenticate(func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusOK) })
+
+	for key, want := range map[string]int{
+		"Bearer master": fasthttp.StatusOK,
+		"Bearer vk-a":   fasthttp.StatusUnauthorized,
+		"":              fasthttp.StatusUnauthorized,
+	} {
+		ctx := authCtx("/admin/virtual-keys", map[string]string{"Authorization": key})
+		handler(ctx)
+		if got := ctx.Response.StatusCode(); got != want {
+			t.Errorf("Authorization %q: status = %d, want %d", key, got, want)
+		}
+	}
+
+	// Without master keys the admin API is closed entirely.
+	gw.gatewayKeys = nil
+	ctx := authCtx("/admin/virtual-keys", map[string]string{"Authorization": "Bearer vk-a"})
+	handler(ctx)
+	if got := ctx.Response.StatusCode(); got != fasthttp.StatusForbidden {
+		t.Errorf("status without master keys = %d, want 403", got)
+	}
+}
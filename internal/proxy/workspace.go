@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// workspaceHeader names the tenant a request belongs to. Cache entries, TPM
+// budgets and request logs are partitioned by workspace.
+const workspaceHeader = "X-Workspace-ID"
+
+// maxWorkspaceIDLen bounds a workspace ID, which ends up in cache keys and
+// log rows.
+const maxWorkspaceIDLen = 128
+
+// requestWorkspace returns the workspace of a request, or "" when it has
+// none. A virtual key's workspace is authoritative, so a key cannot reach
+// another tenant's cache entries by setting X-Workspace-ID; otherwise the
+// header is used. It returns an error for a malformed header.
+func requestWorkspace(ctx *fasthttp.RequestCtx) (string, error) {
+	if vk := requestVirtualKey(ctx); vk != nil && vk.Workspace != "" {
+		return vk.Workspace, nil
+	}
+	id := string(ctx.Request.Header.Peek(workspaceHeader))
+	if id == "" {
+		return "", nil
+	}
+	if len(id) > maxWorkspaceIDLen {
+		return "", fmt.Errorf("%s must be at most %d characters", workspaceHeader, maxWorkspaceIDLen)
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == ':':
+		default:
+			return "", fmt.Errorf("%s may only contain letters, digits and '-', '_', '.', ':'", workspaceHeader)
+		}
+	}
+	return id, nil
+}
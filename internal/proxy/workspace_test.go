@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/cache"
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/valyala/fasthttp"
+)
+
+func doPostWithWorkspace(t *testing.T, client *http.Client, workspace, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://test/v1/chat/completions", readerFromBytes([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(workspaceHeader, workspace)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDispatchChat_WorkspaceIsolatesCache(t *testing.T) {
+	var got *providers.ProxyRequest
+	mc := cache.NewMemoryCache(context.Background())
+	defer mc.Close()
+	gw := NewGatewayWithOptions(context.Background(), map[string]providers.Provider{
+		"openai": capturingProvider("openai", &got),
+	}, mc, nil, GatewayOptions{})
+	t.Cleanup(gw.health.Close)
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	tests := []struct {
+		workspace  string
+		wantUpcall bool
+	}{
+		{workspace: "ws-a", wantUpcall: true},
+		{workspace: "ws-b", wantUpcall: true}, // same prompt, other tenant
+		{workspace: "ws-a", wantUpcall: false},
+	}
+	for _, tt := range tests {
+		got = nil
+		resp := doPostWithWorkspace(t, client, tt.workspace, body)
+		if b := readBody(t, resp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", tt.workspace, resp.StatusCode, b)
+		}
+		if (got != nil) != tt.wantUpcall {
+			t.Errorf("%s: provider called = %v, want %v", tt.workspace, got != nil, tt.wantUpcall)
+		}
+		if got != nil && got.WorkspaceID != tt.workspace {
+			t.Errorf("WorkspaceID = %q, want %q", got.WorkspaceID, tt.workspace)
+		}
+	}
+}
+
+func TestDispatchChat_InvalidWorkspace(t *testing.T) {
+	gw := newTransformGateway(t, okProvider("openai"), GatewayOptions{})
+	client, cleanup := serveGateway(t, gw)
+	defer cleanup()
+
+	resp := doPostWithWorkspace(t, client, "ws a", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), workspaceHeader) {
+		t.Errorf("status = %d, body = %s; want 400 naming the header", resp.StatusCode, body)
+	}
+}
+
+func TestRequestWorkspace_VirtualKeyOverridesHeader(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(workspaceHeader, "ws-other")
+	if got, err := requestWorkspace(ctx); err != nil || got != "ws-other" {
+		t.Fatalf("header only: got %q, %v; want ws-other", got, err)
+	}
+
+	ctx.SetUserValue(virtualKeyUserValue, &VirtualKey{Name: "team-a", Workspace: "ws-a"})
+	if got, err := requestWorkspace(ctx); err != nil || got != "ws-a" {
+		t.Errorf("with virtual key: got %q, %v; want ws-a", got, err)
+	}
+}
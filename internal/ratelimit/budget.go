@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BudgetStore tracks cumulative token spend per key over a fixed period
+// (e.g. a daily budget). The period starts with the first spend and the
+// counter resets once it elapses. A zero period never resets.
+// RedisBudgetStore (shared across replicas) and MemoryBudgetStore
+// (in-process, single node) both implement it.
+type BudgetStore interface {
+	// Spent returns the tokens recorded against key in the current period.
+	Spent(ctx context.Context, key string) (int64, error)
+	// Add records tokens against key, starting a new period if none is open.
+	Add(ctx context.Context, key string, tokens int64, period time.Duration) error
+}
+
+var (
+	_ BudgetStore = (*RedisBudgetStore)(nil)
+	_ BudgetStore = (*MemoryBudgetStore)(nil)
+)
+
+const budgetKeyPrefix = "ratelimit:budget:"
+
+// budgetAddScript increments a budget counter and sets its expiry when the
+// increment created it, so the period is anchored at the first spend.
+// KEYS[1] = Redis key
+// ARGV[1] = tokens to add
+// ARGV[2] = period in milliseconds (0 = never expires)
+// Returns: the new total.
+var budgetAddScript = redis.NewScript(`
+		local total = redis.call('INCRBY', KEYS[1], ARGV[1])
+		local period = tonumber(ARGV[2])
+		if period > 0 and redis.call('PTTL', KEYS[1]) < 0 then
+			redis.call('PEXPIRE', KEYS[1], period)
+		end
+		return total
+`)
+
+// RedisBudgetStore keeps token budgets in Redis counters.
+type RedisBudgetStore struct {
+	rdb       redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisBudgetStore creates a Redis-backed budget store. Only
+// WithKeyPrefix applies.
+func NewRedisBudgetStore(rdb redis.UniversalClient, opts ...Option) *RedisBudgetStore {
+	return &RedisBudgetStore{rdb: rdb, keyPrefix: applyOptions(opts).keyPrefix}
+}
+
+// Spent returns the tokens spent by key in the current period.
+func (s *RedisBudgetStore) Spent(ctx context.Context, key string) (int64, error) {
+	n, err := s.rdb.Get(ctx, s.keyPrefix+budgetKeyPrefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Add records tokens against key.
+func (s *RedisBudgetStore) Add(ctx context.Context, key string, tokens int64, period time.Duration) error {
+	return budgetAddScript.Run(ctx, s.rdb,
+		[]string{s.keyPrefix + budgetKeyPrefix + key},
+		tokens, period.Milliseconds(),
+	).Err()
+}
+
+// MemoryBudgetStore is an in-process budget store for single-node
+// deployments without Redis. Each replica tracks its own spend.
+type MemoryBudgetStore struct {
+	mu      sync.Mutex
+	budgets map[string]*memoryBudget
+	now     func() time.Time
+}
+
+type memoryBudget struct {
+	spent   int64
+	resetAt time.Time // zero = never
+}
+
+// NewMemoryBudgetStore creates an empty in-process budget store.
+func NewMemoryBudgetStore() *MemoryBudgetStore {
+	return &MemoryBudgetStore{
+		budgets: make(map[string]*memoryBudget),
+		now:     time.Now,
+	}
+}
+
+// Spent returns the tokens spent by key in the current period.
+func (m *MemoryBudgetStore) Spent(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b := m.current(key); b != nil {
+		return b.spent, nil
+	}
+	return 0, nil
+}
+
+// Add records tokens against key.
+func (m *MemoryBudgetStore) Add(_ context.Context, key string, tokens int64, period time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.current(key)
+	if b == nil {
+		b = &memoryBudget{}
+		if period > 0 {
+			b.resetAt = m.now().Add(period)
+		}
+		m.budgets[key] = b
+	}
+	b.spent += tokens
+	return nil
+}
+
+// current returns key's open budget period, dropping it once expired.
+// Callers must hold m.mu.
+func (m *MemoryBudgetStore) current(key string) *memoryBudget {
+	b, ok := m.budgets[key]
+	if !ok {
+		return nil
+	}
+	if !b.resetAt.IsZero() && !m.now().Before(b.resetAt) {
+		delete(m.budgets, key)
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+)
+
+func TestBudgetStores_AccumulateSpend(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	stores := map[string]ratelimit.BudgetStore{
+		"redis":  ratelimit.NewRedisBudgetStore(rdb),
+		"memory": ratelimit.NewMemoryBudgetStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if spent, err := store.Spent(ctx, "team-a"); err != nil || spent != 0 {
+				t.Fatalf("Spent before any usage = %d, %v; want 0, nil", spent, err)
+			}
+			for _, n := range []int64{100, 250} {
+				if err := store.Add(ctx, "team-a", n, time.Hour); err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+			}
+			if spent, _ := store.Spent(ctx, "team-a"); spent != 350 {
+				t.Errorf("Spent = %d, want 350", spent)
+			}
+			if spent, _ := store.Spent(ctx, "team-b"); spent != 0 {
+				t.Errorf("other key Spent = %d, want 0", spent)
+			}
+		})
+	}
+}
+
+func TestKeyedRPMLimiters_PerKeyLimits(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	limiters := map[string]ratelimit.KeyedLimiter{
+		"redis":  ratelimit.NewRPMLimiter(rdb, 1),
+		"memory": ratelimit.NewMemoryKeyedRPMLimiter(),
+	}
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 2; i++ {
+				if allowed, _ := limiter.AllowKey(ctx, "a", 2); !allowed {
+					t.Fatalf("request %d for key a should be allowed", i)
+				}
+			}
+			if allowed, _ := limiter.AllowKey(ctx, "a", 2); allowed {
+				t.Error("third request for key a should be limited")
+			}
+			if allowed, _ := limiter.AllowKey(ctx, "b", 2); !allowed {
+				t.Error("key b should have its own window")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether one more request fits the global requests-per-minute
+// budget. RPMLimiter (Redis, shared across replicas) and MemoryRPMLimiter
+// (in-process, single node) both implement it.
+type Limiter interface {
+	Allow(ctx context.Context) (bool, error)
+}
+
+// KeyedLimiter applies a requests-per-minute limit per key, with the limit
+// passed per call so each key can carry its own (e.g. per virtual key).
+type KeyedLimiter interface {
+	AllowKey(ctx context.Context, key string, limit int) (bool, error)
+}
+
+var (
+	_ Limiter      = (*RPMLimiter)(nil)
+	_ Limiter      = (*MemoryRPMLimiter)(nil)
+	_ KeyedLimiter = (*RPMLimiter)(nil)
+	_ KeyedLimiter = (*MemoryKeyedRPMLimiter)(nil)
+)
+
+// MemoryRPMLimiter is an in-process RPM limiter for single-node deployments
+// without Redis. Each replica enforces its own limit.
+type MemoryRPMLimiter struct {
+	mu        sync.Mutex
+	rpmLimit  int
+	window    time.Duration
+	algorithm Algorithm
+	now       func() time.Time
+
+	// SlidingWindow state.
+	hits []time.Time // request timestamps within the window, oldest first
+
+	// TokenBucket state; filled is false until the first request.
+	tokens float64
+	refill time.Time
+	filled bool
+}
+
+// NewMemoryRPMLimiter creates an in-process limiter with the given RPM limit.
+// rpmLimit must be > 0; values ≤ 0 will block every request.
+func NewMemoryRPMLimiter(rpmLimit int, opts ...Option) *MemoryRPMLimiter {
+	o := applyOptions(opts)
+	return &MemoryRPMLimiter{
+		rpmLimit:  rpmLimit,
+		window:    time.Minute,
+		algorithm: o.algorithm,
+		now:       time.Now,
+	}
+}
+
+// Allow returns true if the current request is within the rate limit.
+func (m *MemoryRPMLimiter) Allow(_ context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.algorithm == TokenBucket {
+		return m.takeToken(), nil
+	}
+
+	now := m.now()
+	cutoff := now.Add(-m.window)
+
+	// Drop expired entries.
+	i := 0
+	for i < len(m.hits) && !m.hits[i].After(cutoff) {
+		i++
+	}
+	m.hits = m.hits[i:]
+
+	if len(m.hits) >= m.rpmLimit {
+		return false, nil
+	}
+	m.hits = append(m.hits, now)
+	return true, nil
+}
+
+// takeToken refills the bucket for the time since the last call and spends
+// one token if there is one. m.mu must be held.
+func (m *MemoryRPMLimiter) takeToken() bool {
+	now := m.now()
+	limit := float64(m.rpmLimit)
+	if !m.filled {
+		m.tokens, m.refill, m.filled = limit, now, true
+	}
+	if elapsed := now.Sub(m.refill); elapsed > 0 {
+		m.tokens = min(limit, m.tokens+limit*float64(elapsed)/float64(m.window))
+		m.refill = now
+	}
+	if m.tokens < 1 {
+		return false
+	}
+	m.tokens--
+	return true
+}
+
+// MemoryKeyedRPMLimiter keeps one in-process sliding window per key.
+type MemoryKeyedRPMLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*MemoryRPMLimiter
+}
+
+// NewMemoryKeyedRPMLimiter creates an empty in-process keyed limiter.
+func NewMemoryKeyedRPMLimiter() *MemoryKeyedRPMLimiter {
+	return &MemoryKeyedRPMLimiter{limiters: make(map[string]*MemoryRPMLimiter)}
+}
+
+// AllowKey returns true if the request is within key's limit.
+func (m *MemoryKeyedRPMLimiter) AllowKey(ctx context.Context, key string, limit int) (bool, error) {
+	m.mu.Lock()
+	l, ok := m.limiters[key]
+	if !ok {
+		l = NewMemoryRPMLimiter(limit)
+		m.limiters[key] = l
+	}
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	l.rpmLimit = limit
+	l.mu.Unlock()
+	return l.Allow(ctx)
+}
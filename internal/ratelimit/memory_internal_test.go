@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRPMLimiter_WindowSlides(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewMemoryRPMLimiter(2)
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	limiter.Allow(ctx)
+	now = now.Add(30 * time.Second)
+	limiter.Allow(ctx)
+
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected limit to be reached within the window")
+	}
+
+	// The first hit falls out of the window; one slot frees up.
+	now = now.Add(31 * time.Second)
+	if allowed, _ := limiter.Allow(ctx); !allowed {
+		t.Fatal("expected a slot after the oldest request expired")
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected only one slot to free up")
+	}
+}
+
+func TestMemoryRPMLimiter_RejectsBurstAcrossMinuteBoundary(t *testing.T) {
+	// Two full bursts one second either side of a minute boundary: a fixed
+	// one-minute window would reset in between and admit both.
+	now := time.Unix(1_700_000_000, 0).Truncate(time.Minute).Add(59 * time.Second)
+	limiter := NewMemoryRPMLimiter(5)
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow(ctx); !allowed {
+			t.Fatalf("first burst: request %d rejected", i)
+		}
+	}
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow(ctx); allowed {
+			t.Fatalf("second burst: request %d admitted across the boundary", i)
+		}
+	}
+}
+
+func TestMemoryRPMLimiter_TokenBucketRefillsEvenly(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewMemoryRPMLimiter(6, WithAlgorithm(TokenBucket)) // one token per 10s
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 6; i++ {
+		if allowed, _ := limiter.Allow(ctx); !allowed {
+			t.Fatalf("burst: request %d rejected", i)
+		}
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected the bucket to be empty after the burst")
+	}
+
+	now = now.Add(9 * time.Second)
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected no token before a full refill interval")
+	}
+	now = now.Add(time.Second)
+	if allowed, _ := limiter.Allow(ctx); !allowed {
+		t.Fatal("expected one token after 10s")
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected only one token to refill")
+	}
+}
+
+func TestMemoryBudgetStore_ResetsAfterPeriod(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryBudgetStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = store.Add(ctx, "k", 500, 24*time.Hour)
+	now = now.Add(23 * time.Hour)
+	_ = store.Add(ctx, "k", 100, 24*time.Hour)
+	if spent, _ := store.Spent(ctx, "k"); spent != 600 {
+		t.Fatalf("Spent within period = %d, want 600", spent)
+	}
+
+	// The period is anchored at the first spend, not the latest.
+	now = now.Add(time.Hour)
+	if spent, _ := store.Spent(ctx, "k"); spent != 0 {
+		t.Fatalf("Spent after period = %d, want 0", spent)
+	}
+}
@@ -1,5 +1,5 @@
 // Package ratelimit implements per-workspace and per-key rate limiting using
-// Redis sliding window counters with atomic Lua scripts.
+// Redis sliding window counters or token buckets with atomic Lua scripts.
 package ratelimit
 
 import (
@@ -37,20 +37,106 @@ var slidingWindowScript = redis.NewScript(`
 		return 1
 `)
 
+// tokenBucketScript is an atomic Lua script that implements a token bucket
+// holding up to limit tokens and refilled at limit tokens per window.
+// KEYS[1] = Redis key (a hash of tokens and last refill time)
+// ARGV[1] = current unix timestamp in milliseconds
+// ARGV[2] = window size in milliseconds
+// ARGV[3] = limit (bucket size and tokens added per window)
+// Returns: 1 if allowed, 0 if rate limited.
+var tokenBucketScript = redis.NewScript(`
+		local key    = KEYS[1]
+		local now    = tonumber(ARGV[1])
+		local window = tonumber(ARGV[2])
+		local limit  = tonumber(ARGV[3])
+
+		local state  = redis.call('HMGET', key, 'tokens', 'ts')
+		local tokens = tonumber(state[1]) or limit
+		local ts     = tonumber(state[2]) or now
+
+		-- Refill for the time elapsed since the last request.
+		if now > ts then
+			tokens = math.min(limit, tokens + (now - ts) * limit / window)
+			ts = now
+		end
+
+		local allowed = 0
+		if tokens >= 1 then
+			tokens = tokens - 1
+			allowed = 1
+		end
+
+		redis.call('HSET', key, 'tokens', tokens, 'ts', ts)
+		redis.call('PEXPIRE', key, window)
+		return allowed
+`)
+
+// Algorithm selects how an RPM limiter counts requests.
+type Algorithm string
+
+const (
+	// SlidingWindow admits at most limit requests in any rolling minute.
+	// It keeps one entry per admitted request.
+	SlidingWindow Algorithm = "sliding_window"
+
+	// TokenBucket admits a burst of up to limit requests and then one more
+	// every minute/limit, spreading traffic evenly. It keeps constant state
+	// per key, however high the limit.
+	TokenBucket Algorithm = "token_bucket"
+)
+
+// Option configures a rate limiter or budget store.
+type Option func(*limiterOptions)
+
+type limiterOptions struct {
+	algorithm Algorithm
+	keyPrefix string
+}
+
+// WithAlgorithm selects the counting algorithm of an RPM limiter. Default:
+// SlidingWindow.
+func WithAlgorithm(a Algorithm) Option {
+	return func(o *limiterOptions) { o.algorithm = a }
+}
+
+// WithKeyPrefix prepends prefix to every Redis key, so deployments sharing
+// a Redis database keep separate counters. In-process limiters ignore it.
+func WithKeyPrefix(prefix string) Option {
+	return func(o *limiterOptions) { o.keyPrefix = prefix }
+}
+
+func applyOptions(opts []Option) limiterOptions {
+	o := limiterOptions{algorithm: SlidingWindow}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 const (
-	rateLimitKey = "ratelimit:ws:rpm"
+	rateLimitKey     = "ratelimit:ws:rpm"
+	keyRateLimitBase = "ratelimit:key:rpm:"
+
+	// bucketKeySuffix keeps token buckets apart from sliding-window sorted
+	// sets, so switching algorithms never hits a key of the wrong type.
+	bucketKeySuffix = ":bucket"
 )
 
-// RPMLimiter checks a global requests-per-minute limit using a Redis sliding window.
+// RPMLimiter checks a global requests-per-minute limit in Redis, so the
+// limit is shared by every replica.
 type RPMLimiter struct {
-	rdb      *redis.Client
-	rpmLimit int
+	rdb       redis.UniversalClient
+	rpmLimit  int
+	algorithm Algorithm
+	keyPrefix string
+	now       func() time.Time
 }
 
 // NewRPMLimiter creates a new RPMLimiter with the given global RPM limit.
 // rpmLimit must be > 0; values ≤ 0 will block every request.
-func NewRPMLimiter(rdb *redis.Client, rpmLimit int) *RPMLimiter {
-	return &RPMLimiter{rdb: rdb, rpmLimit: rpmLimit}
+func NewRPMLimiter(rdb redis.UniversalClient, rpmLimit int, opts ...Option) *RPMLimiter {
+	o := applyOptions(opts)
+	return &RPMLimiter{rdb: rdb, rpmLimit: rpmLimit, algorithm: o.algorithm, keyPrefix: o.keyPrefix, now: time.Now}
 }
 
 // Allow returns true if the current request is within the rate limit.
@@ -58,14 +144,29 @@ func (r *RPMLimiter) Allow(ctx context.Context) (bool, error) {
 	return r.check(ctx, rateLimitKey, r.rpmLimit)
 }
 
+// AllowKey returns true if the request is within key's own limit. The
+// global limit passed to NewRPMLimiter does not apply.
+func (r *RPMLimiter) AllowKey(ctx context.Context, key string, limit int) (bool, error) {
+	return r.check(ctx, keyRateLimitBase+key, limit)
+}
+
 func (r *RPMLimiter) check(ctx context.Context, key string, limit int) (bool, error) {
-	now := time.Now().UnixNano()
-	window := time.Minute.Nanoseconds()
+	now := r.now()
+	key = r.keyPrefix + key
 
-	result, err := slidingWindowScript.Run(ctx, r.rdb,
-		[]string{key},
-		now, window, limit,
-	).Int()
+	var result int
+	var err error
+	if r.algorithm == TokenBucket {
+		result, err = tokenBucketScript.Run(ctx, r.rdb,
+			[]string{key + bucketKeySuffix},
+			now.UnixMilli(), time.Minute.Milliseconds(), limit,
+		).Int()
+	} else {
+		result, err = slidingWindowScript.Run(ctx, r.rdb,
+			[]string{key},
+			now.UnixNano(), time.Minute.Nanoseconds(), limit,
+		).Int()
+	}
 	if err != nil {
 		// Redis unavailable — allow request (graceful degradation).
 		return true, nil
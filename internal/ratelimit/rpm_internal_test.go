@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newInternalTestLimiter(t *testing.T, limit int, now *time.Time, opts ...Option) *RPMLimiter {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	l := NewRPMLimiter(rdb, limit, opts...)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestRPMLimiter_RejectsBurstAcrossMinuteBoundary(t *testing.T) {
+	// Two full bursts one second either side of a minute boundary: a fixed
+	// one-minute window would reset in between and admit both.
+	now := time.Unix(1_700_000_000, 0).Truncate(time.Minute).Add(59 * time.Second)
+	limiter := newInternalTestLimiter(t, 5, &now)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow(ctx); !allowed {
+			t.Fatalf("first burst: request %d rejected", i)
+		}
+	}
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow(ctx); allowed {
+			t.Fatalf("second burst: request %d admitted across the boundary", i)
+		}
+	}
+
+	// A full minute after the first burst its slots are free again.
+	now = now.Add(58 * time.Second)
+	if allowed, _ := limiter.Allow(ctx); !allowed {
+		t.Fatal("expected a slot once the first burst left the window")
+	}
+}
+
+func TestRPMLimiter_TokenBucket(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := newInternalTestLimiter(t, 6, &now, WithAlgorithm(TokenBucket)) // one token per 10s
+	ctx := context.Background()
+
+	for i := 0; i < 6; i++ {
+		if allowed, _ := limiter.Allow(ctx); !allowed {
+			t.Fatalf("burst: request %d rejected", i)
+		}
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected the bucket to be empty after the burst")
+	}
+
+	now = now.Add(9 * time.Second)
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected no token before a full refill interval")
+	}
+	now = now.Add(time.Second)
+	if allowed, _ := limiter.Allow(ctx); !allowed {
+		t.Fatal("expected one token after 10s")
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Fatal("expected only one token to refill")
+	}
+
+	// Per-key buckets are independent of the global one.
+	if allowed, _ := limiter.AllowKey(ctx, "vk", 1); !allowed {
+		t.Fatal("expected a fresh bucket for the key")
+	}
+}
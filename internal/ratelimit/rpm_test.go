@@ -85,3 +85,68 @@ func TestRPMLimiter_DegradedGracefully_WhenRedisDown(t *testing.T) {
 		t.Error("expected allowed=true when Redis is unavailable (graceful degradation)")
 	}
 }
+
+func TestRPMLimiter_SharedAcrossInstances(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	// Two limiters simulate two gateway replicas pointing at the same Redis.
+	const limit = 4
+	a := ratelimit.NewRPMLimiter(rdb, limit)
+	b := ratelimit.NewRPMLimiter(rdb, limit)
+	ctx := context.Background()
+
+	for i := 0; i < limit; i++ {
+		l := a
+		if i%2 == 1 {
+			l = b
+		}
+		allowed, err := l.Allow(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error at iteration %d: %v", i, err)
+		}
+		if !allowed {
+			t.Fatalf("expected allowed=true at iteration %d", i)
+		}
+	}
+
+	for name, l := range map[string]*ratelimit.RPMLimiter{"a": a, "b": b} {
+		if allowed, _ := l.Allow(ctx); allowed {
+			t.Errorf("limiter %s: expected the shared limit to be exhausted", name)
+		}
+	}
+}
+
+func TestMemoryRPMLimiter_BlocksOverLimit(t *testing.T) {
+	const limit = 3
+	limiter := ratelimit.NewMemoryRPMLimiter(limit)
+	ctx := context.Background()
+
+	for i := 0; i < limit; i++ {
+		if allowed, _ := limiter.Allow(ctx); !allowed {
+			t.Fatalf("expected allowed=true at iteration %d", i)
+		}
+	}
+	if allowed, _ := limiter.Allow(ctx); allowed {
+		t.Error("expected allowed=false after limit exceeded")
+	}
+}
+
+func TestRPMLimiter_KeyPrefixSeparatesDeployments(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	prod := ratelimit.NewRPMLimiter(rdb, 1, ratelimit.WithKeyPrefix("prod:"))
+	staging := ratelimit.NewRPMLimiter(rdb, 1, ratelimit.WithKeyPrefix("staging:"))
+	ctx := context.Background()
+
+	if allowed, _ := prod.Allow(ctx); !allowed {
+		t.Fatal("prod: first request rejected")
+	}
+	if allowed, _ := prod.Allow(ctx); allowed {
+		t.Error("prod: second request allowed over the limit")
+	}
+	if allowed, _ := staging.Allow(ctx); !allowed {
+		t.Error("staging: first request rejected; it shares prod's counter")
+	}
+}
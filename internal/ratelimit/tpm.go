@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenWindowScript is an atomic Lua script that implements a sliding window
+// token budget as a hash of one-second buckets: field = unix second, value =
+// tokens recorded in it. A check reads at most one window of buckets, so it
+// costs the same however many requests the window holds. Values may be
+// negative so an estimate can be corrected in the bucket it was recorded in,
+// and the correction leaves the window together with the estimate.
+// KEYS[1] = Redis key
+// ARGV[1] = current unix second
+// ARGV[2] = window size in seconds
+// ARGV[3] = limit (max tokens per window)
+// ARGV[4] = tokens to record
+// ARGV[5] = bucket (unix second) to record them in
+// ARGV[6] = 1 to enforce the limit before recording, 0 to record unconditionally
+// Returns: 1 if recorded (or nothing was left to correct), 0 if the budget
+// would be exceeded.
+var tokenWindowScript = redis.NewScript(`
+		local key    = KEYS[1]
+		local now    = tonumber(ARGV[1])
+		local window = tonumber(ARGV[2])
+		local limit  = tonumber(ARGV[3])
+		local tokens = tonumber(ARGV[4])
+		local bucket = tonumber(ARGV[5])
+		local check  = tonumber(ARGV[6])
+
+		-- Drop expired buckets and total the rest.
+		local used = 0
+		local fields = redis.call('HGETALL', key)
+		for i = 1, #fields, 2 do
+			if tonumber(fields[i]) <= now - window then
+				redis.call('HDEL', key, fields[i])
+			else
+				used = used + tonumber(fields[i + 1])
+			end
+		end
+
+		-- A correction for an estimate that has already left the window has
+		-- nothing to correct.
+		if bucket <= now - window then
+			return 1
+		end
+
+		if check == 1 and used + tokens > limit then
+			return 0
+		end
+
+		if tokens ~= 0 then
+			redis.call('HINCRBY', key, bucket, tokens)
+			redis.call('EXPIRE', key, window + 1)
+		end
+		return 1
+`)
+
+const (
+	tpmKeyPrefix = "ratelimit:tpm:"
+	tpmGlobalKey = "global"
+	tpmWindow    = time.Minute
+)
+
+// TPMLimiter enforces a tokens-per-minute budget per workspace or API key
+// using a Redis sliding window of one-second buckets.
+//
+// Token usage is unknown until the provider responds, so callers Reserve an
+// estimate on entry and Reconcile it with the actual usage afterwards.
+type TPMLimiter struct {
+	rdb       redis.UniversalClient
+	keyPrefix string
+	now       func() time.Time
+}
+
+// Reservation is an estimate recorded by Reserve. Pass it to Reconcile once
+// the actual usage is known.
+type Reservation struct {
+	key    string
+	bucket int64 // unix second the estimate was recorded in
+	tokens int
+}
+
+// Recorded reports whether the reservation holds an estimate that Reconcile
+// can correct. It is false when Reserve rejected the request or Redis was
+// unavailable.
+func (r Reservation) Recorded() bool { return r.key != "" }
+
+// NewTPMLimiter creates a new TPMLimiter. The limit itself is passed per call
+// so callers can apply per-workspace plans. Only WithKeyPrefix applies.
+func NewTPMLimiter(rdb redis.UniversalClient, opts ...Option) *TPMLimiter {
+	return &TPMLimiter{rdb: rdb, keyPrefix: applyOptions(opts).keyPrefix, now: time.Now}
+}
+
+// Reserve records estimated tokens against key if doing so keeps the rolling
+// one-minute total within limit. Returns false when the budget is exhausted;
+// nothing is recorded in that case. An empty key uses a shared global budget.
+// If Redis is unavailable the request is allowed and the error is returned
+// for observability only.
+func (t *TPMLimiter) Reserve(ctx context.Context, key string, estimated, limit int) (Reservation, bool, error) {
+	if key == "" {
+		key = tpmGlobalKey
+	}
+	r := Reservation{key: key, bucket: t.now().Unix(), tokens: estimated}
+	ok, err := t.run(ctx, r.key, r.bucket, estimated, limit, true)
+	if err != nil || !ok {
+		return Reservation{}, ok, err
+	}
+	return r, true, nil
+}
+
+// Reconcile corrects the estimate r recorded to the actual token usage, in
+// the bucket the estimate went to, so the correction expires with it. It
+// never rejects: the request has already been served. Once the estimate has
+// left the window there is nothing to correct and Reconcile does nothing.
+func (t *TPMLimiter) Reconcile(ctx context.Context, r Reservation, actual int) error {
+	if !r.Recorded() || actual == r.tokens {
+		return nil
+	}
+	_, err := t.run(ctx, r.key, r.bucket, actual-r.tokens, 0, false)
+	return err
+}
+
+func (t *TPMLimiter) run(ctx context.Context, key string, bucket int64, tokens, limit int, check bool) (bool, error) {
+	enforce := 0
+	if check {
+		enforce = 1
+	}
+
+	result, err := tokenWindowScript.Run(ctx, t.rdb,
+		[]string{t.keyPrefix + tpmKeyPrefix + key},
+		t.now().Unix(), int64(tpmWindow/time.Second), limit, tokens, bucket, enforce,
+	).Int()
+	if err != nil {
+		// Redis unavailable — allow request (graceful degradation).
+		return true, err
+	}
+
+	return result == 1, nil
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newInternalTPMLimiter(t *testing.T, now *time.Time) (*TPMLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	l := NewTPMLimiter(rdb)
+	l.now = func() time.Time { return *now }
+	return l, mr
+}
+
+func TestTPMLimiter_CorrectionExpiresWithReservation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter, _ := newInternalTPMLimiter(t, &now)
+	ctx := context.Background()
+
+	res, allowed, _ := limiter.Reserve(ctx, "ws:a", 900, 1000)
+	if !allowed {
+		t.Fatal("expected reservation to be allowed")
+	}
+	// A long request: usage is known half a window later.
+	now = now.Add(30 * time.Second)
+	if err := limiter.Reconcile(ctx, res, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the reservation has left the window, so has its correction:
+	// no budget is handed back beyond the limit.
+	now = now.Add(31 * time.Second)
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 1000, 1000); !allowed {
+		t.Fatal("expected the full budget once the reservation expired")
+	}
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 1, 1000); allowed {
+		t.Error("a stale correction left budget in the window")
+	}
+}
+
+func TestTPMLimiter_ReconcileAfterExpiryDoesNothing(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter, _ := newInternalTPMLimiter(t, &now)
+	ctx := context.Background()
+
+	res, allowed, _ := limiter.Reserve(ctx, "ws:a", 900, 1000)
+	if !allowed {
+		t.Fatal("expected reservation to be allowed")
+	}
+	// A stream longer than the window: the estimate is gone before usage
+	// is known.
+	now = now.Add(90 * time.Second)
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 1000, 1000); !allowed {
+		t.Fatal("expected the full budget once the reservation expired")
+	}
+	if err := limiter.Reconcile(ctx, res, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 1, 1000); allowed {
+		t.Error("reconciling an expired reservation handed back budget")
+	}
+}
+
+func TestTPMLimiter_StateIsBoundedByWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter, mr := newInternalTPMLimiter(t, &now)
+	ctx := context.Background()
+
+	// Many requests a second for three minutes.
+	for i := 0; i < 3*60*10; i++ {
+		if i%10 == 0 {
+			now = now.Add(time.Second)
+		}
+		if _, _, err := limiter.Reserve(ctx, "ws:a", 1, 1_000_000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fields, err := mr.HKeys(tpmKeyPrefix + "ws:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) > 60 {
+		t.Errorf("window holds %d buckets, want at most 60", len(fields))
+	}
+	total := 0
+	for _, f := range fields {
+		v, _ := strconv.Atoi(mr.HGet(tpmKeyPrefix+"ws:a", f))
+		total += v
+	}
+	if total != 600 {
+		t.Errorf("tokens in window = %d, want 600 (60 s × 10 requests)", total)
+	}
+}
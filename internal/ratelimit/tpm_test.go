@@ -0,0 +1,99 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nulpointcorp/llm-gateway/internal/ratelimit"
+)
+
+func TestTPMLimiter_AllowsWithinBudget(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	limiter := ratelimit.NewTPMLimiter(rdb)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		_, allowed, err := limiter.Reserve(ctx, "ws:a", 250, 1000)
+		if err != nil {
+			t.Fatalf("unexpected error at iteration %d: %v", i, err)
+		}
+		if !allowed {
+			t.Fatalf("expected allowed=true at iteration %d", i)
+		}
+	}
+}
+
+func TestTPMLimiter_BlocksOverBudget(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	limiter := ratelimit.NewTPMLimiter(rdb)
+	ctx := context.Background()
+
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 800, 1000); !allowed {
+		t.Fatal("expected first reservation to be allowed")
+	}
+	_, allowed, err := limiter.Reserve(ctx, "ws:a", 300, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected allowed=false when budget would be exceeded")
+	}
+
+	// A rejected reservation must not consume budget.
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 200, 1000); !allowed {
+		t.Error("expected remaining budget of 200 to be available")
+	}
+}
+
+func TestTPMLimiter_ReconcileReturnsUnusedTokens(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	limiter := ratelimit.NewTPMLimiter(rdb)
+	ctx := context.Background()
+
+	res, allowed, _ := limiter.Reserve(ctx, "ws:a", 900, 1000)
+	if !allowed {
+		t.Fatal("expected reservation to be allowed")
+	}
+	if err := limiter.Reconcile(ctx, res, 100); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 800, 1000); !allowed {
+		t.Error("expected reconciled tokens to be released")
+	}
+}
+
+func TestTPMLimiter_KeysAreIsolated(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	defer cleanup()
+
+	limiter := ratelimit.NewTPMLimiter(rdb)
+	ctx := context.Background()
+
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:a", 1000, 1000); !allowed {
+		t.Fatal("expected reservation to be allowed")
+	}
+	if _, allowed, _ := limiter.Reserve(ctx, "ws:b", 1000, 1000); !allowed {
+		t.Error("a different key must have its own budget")
+	}
+}
+
+func TestTPMLimiter_DegradedGracefully_WhenRedisDown(t *testing.T) {
+	rdb, cleanup := newTestRedis(t)
+	cleanup()
+
+	limiter := ratelimit.NewTPMLimiter(rdb)
+	_, allowed, err := limiter.Reserve(context.Background(), "", 10, 1)
+	if !allowed {
+		t.Error("expected allowed=true when Redis is unavailable")
+	}
+	if err == nil {
+		t.Error("expected the Redis error to be reported")
+	}
+}
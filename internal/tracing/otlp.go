@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	exportBuffer        = 10_000
+	exportBatchSize     = 512
+	exportFlushInterval = 5 * time.Second
+	exportTimeout       = 10 * time.Second
+)
+
+// OTLPExporter is a Processor that batches ended spans and sends them to an
+// OpenTelemetry collector with OTLP/HTTP (JSON encoding). Like the request
+// logger it never blocks the request path: when its buffer is full, spans
+// are dropped and counted in DroppedSpans.
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	log         *slog.Logger
+
+	ch        chan SpanData
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	droppedSpans atomic.Int64
+}
+
+var _ Processor = (*OTLPExporter)(nil)
+
+// NewOTLPExporter starts an exporter that posts to endpoint + "/v1/traces"
+// (e.g. http://otel-collector:4318). serviceName is reported as the
+// service.name resource attribute. Close flushes and stops it.
+func NewOTLPExporter(endpoint, serviceName string, log *slog.Logger) *OTLPExporter {
+	if log == nil {
+		log = slog.Default()
+	}
+	e := &OTLPExporter{
+		url:         strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		log:         log,
+		ch:          make(chan SpanData, exportBuffer),
+		done:        make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+// OnEnd queues s for export.
+func (e *OTLPExporter) OnEnd(s SpanData) {
+	select {
+	case e.ch <- s:
+	default:
+		e.droppedSpans.Add(1)
+	}
+}
+
+// DroppedSpans returns the number of spans discarded because the buffer was
+// full.
+func (e *OTLPExporter) DroppedSpans() int64 { return e.droppedSpans.Load() }
+
+// Close exports any queued spans and stops the exporter.
+func (e *OTLPExporter) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return nil
+}
+
+func (e *OTLPExporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(exportFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.log.Warn("trace_export_failed",
+				slog.Int("spans", len(batch)),
+				slog.String("error", err.Error()),
+			)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-e.ch:
+			batch = append(batch, s)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-e.done:
+			for {
+				select {
+				case s := <-e.ch:
+					batch = append(batch, s)
+					if len(batch) >= exportBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) export(batch []SpanData) error {
+	body, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ── OTLP JSON encoding ───────────────────────────────────────────────────────
+//
+// See opentelemetry-proto's trace/v1 ExportTraceServiceRequest. In the JSON
+// mapping trace and span IDs are hex strings and 64-bit integers are decimal
+// strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+const otlpSpanKindInternal = 1
+
+func (e *OTLPExporter) encode(batch []SpanData) otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		out := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.Attributes),
+		}
+		if s.ParentSpanID != (SpanID{}) {
+			out.ParentSpanID = s.ParentSpanID.String()
+		}
+		if s.Err != "" {
+			out.Status = otlpStatus{Code: 2, Message: s.Err}
+		}
+		spans[i] = out
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes([]Attribute{
+			String("service.name", e.serviceName),
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/nulpointcorp/llm-gateway"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v otlpAnyValue
+		switch val := a.Value.(type) {
+		case string:
+			v.StringValue = &val
+		case int64:
+			s := strconv.FormatInt(val, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &val
+		case bool:
+			v.BoolValue = &val
+		default:
+			s := fmt.Sprint(val)
+			v.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
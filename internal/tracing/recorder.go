@@ -0,0 +1,28 @@
+package tracing
+
+import "sync"
+
+// Recorder is a Processor that keeps ended spans in memory, for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+var _ Processor = (*Recorder)(nil)
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder { return &Recorder{} }
+
+// OnEnd records s.
+func (r *Recorder) OnEnd(s SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+// Ended returns the spans ended so far, in the order they ended.
+func (r *Recorder) Ended() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
@@ -0,0 +1,303 @@
+// Package tracing records request spans for distributed tracing.
+//
+// The API mirrors the subset of OpenTelemetry the gateway needs: a Tracer
+// starts Spans that nest through context.Context, incoming W3C traceparent
+// headers are continued, and ended spans go to a Processor — the OTLP/HTTP
+// exporter in production, a Recorder in tests. Noop is used when no exporter
+// is configured and costs nothing beyond an interface call.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the lower-case hex encoding of id.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// String returns the lower-case hex encoding of id.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero trace and span IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+// ("00-<trace-id>-<parent-id>-<flags>"). It reports false for malformed
+// values and all-zero IDs, in which case a new trace should be started.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	var (
+		sc    SpanContext
+		flags [1]byte
+	)
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 != 0
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Attribute is a key/value pair attached to a span. Values are strings,
+// int64s, float64s or bools.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(k, v string) Attribute { return Attribute{Key: k, Value: v} }
+
+// Int returns an integer attribute.
+func Int(k string, v int) Attribute { return Attribute{Key: k, Value: int64(v)} }
+
+// Bool returns a boolean attribute.
+func Bool(k string, v bool) Attribute { return Attribute{Key: k, Value: v} }
+
+// Span is one timed operation. Methods are safe to call after End; they
+// have no effect.
+type Span interface {
+	// SetAttributes adds attributes, replacing any with the same key.
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the span as failed with err's message. A nil err is
+	// ignored.
+	RecordError(err error)
+	// End finishes the span. Only the first call has an effect.
+	End()
+	// SpanContext returns the span's identity.
+	SpanContext() SpanContext
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start begins a span named name as a child of the span in ctx, or of a
+	// remote parent set with ContextWithRemoteParent, or as a new trace. The
+	// returned context carries the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// ── Context ──────────────────────────────────────────────────────────────────
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, so spans started from
+// it become its children. A nil span returns ctx unchanged.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a copy of ctx whose next span continues
+// the trace described by sc, typically parsed from an incoming traceparent.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return ContextWithSpan(ctx, remoteSpan{sc: sc})
+}
+
+// remoteSpan stands in for a parent span owned by another process.
+type remoteSpan struct{ sc SpanContext }
+
+func (remoteSpan) SetAttributes(...Attribute) {}
+func (remoteSpan) RecordError(error)          {}
+func (remoteSpan) End()                       {}
+
+func (s remoteSpan) SpanContext() SpanContext { return s.sc }
+
+// ── Noop ─────────────────────────────────────────────────────────────────────
+
+// Noop returns a Tracer that records nothing. Its spans carry no IDs and
+// Start returns ctx unchanged.
+func Noop() Tracer { return noopTracer{} }
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+func (noopSpan) SpanContext() SpanContext   { return SpanContext{} }
+
+// ── Recording tracer ─────────────────────────────────────────────────────────
+
+// SpanData is a finished span as handed to a Processor.
+type SpanData struct {
+	Name         string
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID // zero for a root span
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	// Err is the message recorded by RecordError; empty when the span
+	// succeeded.
+	Err string
+}
+
+// Attr returns the value of the attribute named key, or nil.
+func (d SpanData) Attr(key string) any {
+	for _, a := range d.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// Processor receives every span when it ends. OnEnd must not block.
+type Processor interface {
+	OnEnd(SpanData)
+}
+
+// New returns a Tracer that records every span and passes it to p when it
+// ends.
+func New(p Processor) Tracer {
+	return &tracer{proc: p}
+}
+
+type tracer struct {
+	proc Processor
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	s := &span{proc: t.proc}
+	s.data.Name = name
+	s.data.Start = time.Now()
+	s.data.SpanID = newSpanID()
+	if parent := SpanFromContext(ctx); parent != nil && parent.SpanContext().IsValid() {
+		psc := parent.SpanContext()
+		s.data.TraceID = psc.TraceID
+		s.data.ParentSpanID = psc.SpanID
+	} else {
+		s.data.TraceID = newTraceID()
+	}
+	s.SetAttributes(attrs...)
+	return ContextWithSpan(ctx, s), s
+}
+
+type span struct {
+	proc Processor
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+outer:
+	for _, a := range attrs {
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == a.Key {
+				s.data.Attributes[i].Value = a.Value
+				continue outer
+			}
+		}
+		s.data.Attributes = append(s.data.Attributes, a)
+	}
+}
+
+func (s *span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Err = err.Error()
+	}
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.proc.OnEnd(data)
+}
+
+func (s *span) SpanContext() SpanContext {
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Sampled: true}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
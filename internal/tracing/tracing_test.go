@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+	}
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.header)
+		if ok != tt.ok {
+			t.Errorf("ParseTraceparent(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+			continue
+		}
+		if ok && tt.header[:2] == "00" && sc.Traceparent() != tt.header {
+			t.Errorf("round trip = %q, want %q", sc.Traceparent(), tt.header)
+		}
+	}
+}
+
+func TestTracer_ParentChain(t *testing.T) {
+	rec := NewRecorder()
+	tr := New(rec)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tr.Start(ContextWithRemoteParent(context.Background(), remote), "root", String("k", "v"))
+	_, child := tr.Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.SetAttributes(String("k", "w"), Int("n", 1))
+	root.End()
+	root.End() // second End is ignored
+
+	_, orphan := tr.Start(context.Background(), "orphan")
+	orphan.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended %d spans, want 3", len(spans))
+	}
+	c, r, o := spans[0], spans[1], spans[2]
+	if r.TraceID != remote.TraceID || r.ParentSpanID != remote.SpanID {
+		t.Errorf("root did not continue the remote trace: %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID {
+		t.Errorf("child is not under root: %+v", c)
+	}
+	if c.Err != "boom" || r.Err != "" {
+		t.Errorf("errors = %q / %q", c.Err, r.Err)
+	}
+	if r.Attr("k") != "w" || r.Attr("n") != int64(1) || len(r.Attributes) != 2 {
+		t.Errorf("root attributes = %+v", r.Attributes)
+	}
+	if o.TraceID == r.TraceID || o.ParentSpanID != (SpanID{}) {
+		t.Errorf("orphan should start a new trace: %+v", o)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	got := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got <- req
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(srv.URL+"/", "llm-gateway", nil)
+	tr := New(exp)
+	ctx, root := tr.Start(context.Background(), "root", Int("n", 7), Bool("b", true))
+	_, child := tr.Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-got
+	rs := req.ResourceSpans[0]
+	if v := rs.Resource.Attributes[0]; v.Key != "service.name" || *v.Value.StringValue != "llm-gateway" {
+		t.Errorf("resource = %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.ParentSpanID != r.SpanID || c.TraceID != r.TraceID || r.ParentSpanID != "" {
+		t.Errorf("parent links wrong: child %+v root %+v", c, r)
+	}
+	if c.Status.Code != 2 || c.Status.Message != "boom" {
+		t.Errorf("child status = %+v", c.Status)
+	}
+	if *r.Attributes[0].Value.IntValue != "7" || !*r.Attributes[1].Value.BoolValue {
+		t.Errorf("root attributes = %+v", r.Attributes)
+	}
+}
@@ -0,0 +1,263 @@
+// Package webhook posts a JSON summary of every completed or failed request
+// to a callback URL.
+//
+// Deliveries are queued and sent by background workers, so notifying never
+// blocks the proxy hot path. When the queue is full, events are dropped and
+// counted in DroppedEvents. Each request body is signed with HMAC-SHA256 so
+// receivers can verify it came from the gateway (see Sign).
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+)
+
+const (
+	// SignatureHeader carries "sha256=" followed by the hex HMAC-SHA256 of
+	// TimestampHeader + "." + body, keyed with the shared secret.
+	SignatureHeader = "X-Gateway-Signature"
+	// TimestampHeader carries the Unix time the delivery was signed at.
+	TimestampHeader = "X-Gateway-Timestamp"
+	// EventHeader repeats the event type.
+	EventHeader = "X-Gateway-Event"
+
+	EventCompleted = "request.completed"
+	EventFailed    = "request.failed"
+
+	workers = 4
+)
+
+// Options configures a Notifier. Zero values use the defaults.
+type Options struct {
+	// URL receives the callbacks. Required.
+	URL string
+	// Secret keys the HMAC signature. Deliveries are unsigned when empty.
+	Secret string
+	// BaseURL is the gateway's public URL (APP_BASE_URL), reported in every
+	// event so receivers serving several gateways can tell them apart.
+	BaseURL string
+	// MaxRetries is the number of redeliveries after a failed attempt.
+	// Default: 0 (no retries).
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// further one. Default: 1s.
+	RetryBackoff time.Duration
+	// Timeout bounds each delivery attempt. Default: 5s.
+	Timeout time.Duration
+	// QueueSize is the number of events queued before Notify starts dropping
+	// them. Default: 1000.
+	QueueSize int
+}
+
+// Event is the JSON body of a callback.
+type Event struct {
+	Type            string    `json:"type"`
+	RequestID       string    `json:"request_id"`
+	Provider        string    `json:"provider"`
+	PrimaryProvider string    `json:"primary_provider"`
+	Model           string    `json:"model"`
+	InputTokens     uint32    `json:"input_tokens"`
+	OutputTokens    uint32    `json:"output_tokens"`
+	LatencyMs       uint32    `json:"latency_ms"`
+	Status          uint16    `json:"status"`
+	Cached          bool      `json:"cached"`
+	CostUSD         float64   `json:"cost_usd"`
+	CreatedAt       time.Time `json:"created_at"`
+	Gateway         string    `json:"gateway,omitempty"`
+}
+
+// Notifier delivers Events to a callback URL.
+type Notifier struct {
+	opts   Options
+	client *http.Client
+	log    *slog.Logger
+
+	ch        chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New starts a Notifier. Close drains the queue and stops it.
+func New(opts Options, log *slog.Logger) (*Notifier, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("webhook: URL is required")
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	n := &Notifier{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		log:    log,
+		ch:     make(chan Event, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	n.wg.Add(workers)
+	for range workers {
+		go n.run()
+	}
+	return n, nil
+}
+
+// Notify queues a callback for the request described by e.
+func (n *Notifier) Notify(e logger.RequestLog) {
+	typ := EventCompleted
+	if e.Status >= 400 {
+		typ = EventFailed
+	}
+	ev := Event{
+		Type:            typ,
+		RequestID:       e.ID.String(),
+		Provider:        e.Provider,
+		PrimaryProvider: e.PrimaryProvider,
+		Model:           e.Model,
+		InputTokens:     e.InputTokens,
+		OutputTokens:    e.OutputTokens,
+		LatencyMs:       e.LatencyMs,
+		Status:          e.Status,
+		Cached:          e.Cached,
+		CostUSD:         e.CostUSD,
+		CreatedAt:       e.CreatedAt.UTC(),
+		Gateway:         n.opts.BaseURL,
+	}
+	select {
+	case n.ch <- ev:
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// DroppedEvents returns the number of events discarded because the queue was
+// full.
+func (n *Notifier) DroppedEvents() int64 { return n.dropped.Load() }
+
+// FailedEvents returns the number of events that could not be delivered
+// after all retries.
+func (n *Notifier) FailedEvents() int64 { return n.failed.Load() }
+
+// Close delivers the queued events and stops the workers. Pending retries
+// are abandoned.
+func (n *Notifier) Close() error {
+	n.closeOnce.Do(func() { close(n.done) })
+	n.wg.Wait()
+	return nil
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for {
+		select {
+		case ev := <-n.ch:
+			n.deliver(ev)
+		case <-n.done:
+			for {
+				select {
+				case ev := <-n.ch:
+					n.deliver(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver posts ev, retrying transport errors, 429s and 5xx responses with
+// exponential backoff.
+func (n *Notifier) deliver(ev Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		n.failed.Add(1)
+		return
+	}
+
+	backoff := n.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := n.post(ev.Type, body)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt >= n.opts.MaxRetries {
+			n.failed.Add(1)
+			n.log.Warn("webhook_delivery_failed",
+				slog.String("request_id", ev.RequestID),
+				slog.Int("attempts", attempt+1),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-n.done:
+			n.failed.Add(1)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(eventType string, body []byte) (retryable bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	if n.opts.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(n.opts.Secret, ts, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("receiver returned %s", resp.Status)
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp.
+// Receivers recompute it from the TimestampHeader and the raw body and
+// compare with hmac.Equal.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
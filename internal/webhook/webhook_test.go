@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nulpointcorp/llm-gateway/internal/logger"
+)
+
+func TestNotifier_SignsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		want := Sign("s3cret", r.Header.Get(TimestampHeader), body)
+		if !hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(want)) {
+			t.Errorf("signature = %q, want %q", r.Header.Get(SignatureHeader), want)
+		}
+		if r.Header.Get(EventHeader) != EventFailed {
+			t.Errorf("event header = %q", r.Header.Get(EventHeader))
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Error(err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+
+	n, err := New(Options{
+		URL: srv.URL, Secret: "s3cret", BaseURL: "https://gw.example.com",
+		MaxRetries: 2, RetryBackoff: time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	id := uuid.New()
+	n.Notify(logger.RequestLog{ID: id, Provider: "anthropic", PrimaryProvider: "openai",
+		Model: "gpt-4o", InputTokens: 3, LatencyMs: 42, Status: 502})
+
+	select {
+	case ev := <-got:
+		if ev.Type != EventFailed || ev.RequestID != id.String() || ev.Provider != "anthropic" ||
+			ev.Model != "gpt-4o" || ev.LatencyMs != 42 || ev.Status != 502 || ev.Gateway != "https://gw.example.com" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not redelivered after a 503")
+	}
+	if calls.Load() != 2 || n.FailedEvents() != 0 {
+		t.Errorf("calls = %d, failed = %d", calls.Load(), n.FailedEvents())
+	}
+}
+
+func TestNotifier_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		status := http.StatusInternalServerError
+		if r.Header.Get(EventHeader) == EventCompleted {
+			status = http.StatusBadRequest // not retried
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n, err := New(Options{URL: srv.URL, MaxRetries: 2, RetryBackoff: time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(logger.RequestLog{Status: 500})
+	n.Notify(logger.RequestLog{Status: 200})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for n.FailedEvents() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("failed = %d, want 2", n.FailedEvents())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_ = n.Close()
+	if got := calls.Load(); got != 4 {
+		t.Errorf("calls = %d, want 3 for the 500 and 1 for the 400", got)
+	}
+}
+
+func TestNotifier_DropsWhenQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	n, err := New(Options{URL: srv.URL, QueueSize: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for range 50 {
+		n.Notify(logger.RequestLog{Status: 200})
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Notify blocked on a slow receiver")
+	}
+	if n.DroppedEvents() == 0 {
+		t.Error("events should be dropped once the queue is full")
+	}
+	close(release)
+	_ = n.Close()
+}
+
+func TestNew_RequiresURL(t *testing.T) {
+	if _, err := New(Options{}, nil); err == nil {
+		t.Error("New without a URL should fail")
+	}
+}
@@ -20,15 +20,18 @@ func newAnthropicHandler(cfg Config) http.Handler {
 			return
 		}
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeAnthropicError(w, http.StatusInternalServerError, "mock internal error", "overloaded_error")
+		if status, ok := injectError(w, cfg); ok {
+			writeAnthropicError(w, status, "mock injected error", anthropicErrorType(status))
 			return
 		}
 
 		var req struct {
-			Model     string `json:"model"`
-			MaxTokens int    `json:"max_tokens"`
-			Stream    bool   `json:"stream"`
+			Model       string            `json:"model"`
+			MaxTokens   *int              `json:"max_tokens"`
+			Stream      bool              `json:"stream"`
+			Temperature *float64          `json:"temperature"`
+			TopP        *float64          `json:"top_p"`
+			Messages    []json.RawMessage `json:"messages"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeAnthropicError(w, http.StatusBadRequest, "invalid request body", "invalid_request_error")
@@ -41,16 +44,22 @@ func newAnthropicHandler(cfg Config) http.Handler {
 		}
 
 		id := fmt.Sprintf("msg_%x", rand.Int64())
-		content := fakeSentence(cfg.StreamWords)
+		received := receivedParams{
+			Temperature: req.Temperature,
+			MaxTokens:   req.MaxTokens,
+			TopP:        req.TopP,
+			Messages:    len(req.Messages),
+		}
+		content := replyText(cfg, received)
 		inTokens := 15
 		outTokens := cfg.StreamWords
 
 		if req.Stream {
-			serveAnthropicStream(w, id, model, content, inTokens, outTokens)
+			serveAnthropicStream(w, cfg, id, model, content, inTokens, outTokens)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, map[string]any{
+		writeJSON(w, http.StatusOK, withReceived(cfg, map[string]any{
 			"id":           id,
 			"type":         "message",
 			"role":         "assistant",
@@ -64,7 +73,7 @@ func newAnthropicHandler(cfg Config) http.Handler {
 				"input_tokens":  inTokens,
 				"output_tokens": outTokens,
 			},
-		})
+		}, received))
 	})
 
 	// GET /v1/models — used by health check
@@ -97,8 +106,32 @@ func writeAnthropicError(w http.ResponseWriter, status int, msg, typ string) {
 	})
 }
 
+// anthropicErrorType returns the Anthropic error type for status.
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	}
+	if status >= 500 {
+		return "api_error"
+	}
+	return "invalid_request_error"
+}
+
 // serveAnthropicStream writes SSE events in the Anthropic streaming format.
-func serveAnthropicStream(w http.ResponseWriter, id, model, content string, inTokens, outTokens int) {
+func serveAnthropicStream(w http.ResponseWriter, cfg Config, id, model, content string, inTokens, outTokens int) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -148,6 +181,7 @@ func serveAnthropicStream(w http.ResponseWriter, id, model, content string, inTo
 	// content_block_delta events for each word
 	words := strings.Fields(content)
 	for _, word := range words {
+		applyStreamDelay(cfg)
 		send("content_block_delta", map[string]any{
 			"type":  "content_block_delta",
 			"index": 0,
@@ -167,9 +201,9 @@ func serveAnthropicStream(w http.ResponseWriter, id, model, content string, inTo
 	// message_delta
 	send("message_delta", map[string]any{
 		"type": "message_delta",
-		"delta": map[string]string{
+		"delta": map[string]any{
 			"stop_reason":   "end_turn",
-			"stop_sequence": "",
+			"stop_sequence": nil,
 		},
 		"usage": map[string]int{
 			"output_tokens": outTokens,
@@ -30,15 +30,31 @@ func newBedrockHandler(cfg Config) http.Handler {
 		isStream := strings.HasSuffix(path, "/converse-stream")
 
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeBedrockError(w, http.StatusInternalServerError, "mock internal error", "ServiceUnavailableException")
+		if status, ok := injectError(w, cfg); ok {
+			writeBedrockError(w, status, "mock injected error", bedrockErrorType(status))
 			return
 		}
 
+		var req struct {
+			Messages        []json.RawMessage `json:"messages"`
+			InferenceConfig struct {
+				MaxTokens   *int     `json:"maxTokens"`
+				Temperature *float64 `json:"temperature"`
+				TopP        *float64 `json:"topP"`
+			} `json:"inferenceConfig"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		received := receivedParams{
+			Temperature: req.InferenceConfig.Temperature,
+			MaxTokens:   req.InferenceConfig.MaxTokens,
+			TopP:        req.InferenceConfig.TopP,
+			Messages:    len(req.Messages),
+		}
+
 		if isStream {
-			serveBedrockStream(w, modelID, cfg)
+			serveBedrockStream(w, modelID, cfg, received)
 		} else {
-			serveBedrockConverse(w, modelID, cfg)
+			serveBedrockConverse(w, modelID, cfg, received)
 		}
 	})
 
@@ -67,10 +83,10 @@ func newBedrockHandler(cfg Config) http.Handler {
 	return mux
 }
 
-func serveBedrockConverse(w http.ResponseWriter, modelID string, cfg Config) {
-	content := fakeSentence(cfg.StreamWords)
+func serveBedrockConverse(w http.ResponseWriter, modelID string, cfg Config, received receivedParams) {
+	content := replyText(cfg, received)
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	writeJSON(w, http.StatusOK, withReceived(cfg, map[string]any{
 		"output": map[string]any{
 			"message": map[string]any{
 				"role": "assistant",
@@ -91,17 +107,17 @@ func serveBedrockConverse(w http.ResponseWriter, modelID string, cfg Config) {
 		"additionalModelResponseFields": nil,
 		// Returned for identification in tests
 		"model": modelID,
-	})
+	}, received))
 }
 
-func serveBedrockStream(w http.ResponseWriter, _ string, cfg Config) {
+func serveBedrockStream(w http.ResponseWriter, _ string, cfg Config, received receivedParams) {
 	// Bedrock streaming uses HTTP/1.1 chunked responses where each line is
 	// a newline-delimited JSON event (simplified from the actual binary framing).
 	w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
 	w.WriteHeader(http.StatusOK)
 
 	flusher, _ := w.(http.Flusher)
-	content := fakeSentence(cfg.StreamWords)
+	content := replyText(cfg, received)
 
 	sendEvent := func(ev any) {
 		data, _ := json.Marshal(ev)
@@ -127,6 +143,7 @@ func serveBedrockStream(w http.ResponseWriter, _ string, cfg Config) {
 	// contentBlockDelta for each word
 	words := strings.Fields(content)
 	for _, word := range words {
+		applyStreamDelay(cfg)
 		sendEvent(map[string]any{
 			"contentBlockDelta": map[string]any{
 				"delta": map[string]string{"text": word + " "},
@@ -174,6 +191,26 @@ func writeBedrockError(w http.ResponseWriter, status int, msg, errType string) {
 	})
 }
 
+// bedrockErrorType returns the Bedrock runtime exception name for status.
+func bedrockErrorType(status int) string {
+	switch status {
+	case http.StatusForbidden:
+		return "AccessDeniedException"
+	case http.StatusNotFound:
+		return "ResourceNotFoundException"
+	case http.StatusRequestTimeout:
+		return "ModelTimeoutException"
+	case http.StatusTooManyRequests:
+		return "ThrottlingException"
+	case http.StatusServiceUnavailable:
+		return "ServiceUnavailableException"
+	}
+	if status >= 500 {
+		return "InternalServerException"
+	}
+	return "ValidationException"
+}
+
 // extractBedrockModel extracts the model ID from a path like
 // /model/anthropic.claude-3-5-sonnet-20241022-v2:0/converse
 func extractBedrockModel(path string) string {
@@ -34,6 +34,36 @@ func fakeEmbedding(dim int) []float32 {
 	return v
 }
 
+// receivedParams are the request parameters a mock reports back when
+// MOCK_ECHO_PARAMS is set, whatever their name in the provider's own format.
+// Unset parameters are null.
+type receivedParams struct {
+	Temperature *float64 `json:"temperature"`
+	MaxTokens   *int     `json:"max_tokens"`
+	TopP        *float64 `json:"top_p"`
+	Messages    int      `json:"messages"`
+}
+
+// replyText returns the text a mock answers with: fake words, or in echo
+// mode the received parameters as JSON. Carrying them in the text lets a
+// test read them back through the gateway, which rebuilds every response.
+func replyText(cfg Config, received receivedParams) string {
+	if !cfg.EchoParams {
+		return fakeSentence(cfg.StreamWords)
+	}
+	data, _ := json.Marshal(received)
+	return string(data)
+}
+
+// withReceived adds the received parameters to a native response body as
+// "_received" in echo mode, for tests that call a mock directly.
+func withReceived(cfg Config, resp map[string]any, received receivedParams) map[string]any {
+	if cfg.EchoParams {
+		resp["_received"] = received
+	}
+	return resp
+}
+
 // applyLatency sleeps for the configured latency.
 func applyLatency(cfg Config) {
 	if cfg.LatencyMS > 0 {
@@ -41,12 +71,45 @@ func applyLatency(cfg Config) {
 	}
 }
 
-// shouldError returns true if this request should simulate an error.
-func shouldError(cfg Config) bool {
-	if cfg.ErrorRate <= 0 {
-		return false
+// applyStreamDelay sleeps for the configured pause before a streamed word.
+func applyStreamDelay(cfg Config) {
+	if cfg.StreamDelayMS > 0 {
+		time.Sleep(time.Duration(cfg.StreamDelayMS) * time.Millisecond)
+	}
+}
+
+// injectError reports whether this request should fail, and with which
+// status. Like the real APIs it sets Retry-After on 429 and 503.
+func injectError(w http.ResponseWriter, cfg Config) (status int, ok bool) {
+	if cfg.ErrorRate <= 0 || rand.Float64() >= cfg.ErrorRate {
+		return 0, false
+	}
+	status = cfg.ErrorStatus
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	return status, true
+}
+
+// openAIErrorType returns the OpenAI error type for status, also used by
+// Mistral.
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "server_error"
 	}
-	return rand.Float64() < cfg.ErrorRate
+	return "invalid_request_error"
 }
 
 // writeJSON writes v as JSON with the given status code.
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulpointcorp/llm-gateway/internal/providers"
+	"github.com/nulpointcorp/llm-gateway/internal/providers/anthropic"
+	"github.com/nulpointcorp/llm-gateway/internal/providers/openai"
+	"github.com/nulpointcorp/llm-gateway/internal/proxy"
+)
+
+// startGateway serves a gateway over provs on a free local port and returns
+// its base URL once /health answers.
+func startGateway(t *testing.T, provs map[string]providers.Provider, opts proxy.GatewayOptions) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gw := proxy.NewGatewayWithOptions(ctx, provs, nil, nil, opts)
+	go func() { _ = gw.Start(addr) }()
+
+	base := "http://" + addr
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(base + "/health")
+		if err == nil {
+			resp.Body.Close()
+			return base
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// chatThroughGateway posts body to the gateway's chat endpoint and returns
+// the response with its body replaced by the first choice's message content,
+// or by the raw body on error.
+func chatThroughGateway(t *testing.T, base, body string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Post(base+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(raw, &out); err != nil || len(out.Choices) == 0 {
+			t.Fatalf("unexpected response %s: %v", raw, err)
+		}
+		return resp, out.Choices[0].Message.Content
+	}
+	return resp, string(raw)
+}
+
+func TestEchoParamsThroughGateway(t *testing.T) {
+	mock := httptest.NewServer(newOpenAIHandler(Config{StreamWords: 10, EchoParams: true}))
+	defer mock.Close()
+
+	base := startGateway(t, map[string]providers.Provider{
+		"openai": openai.New("mock-key", openai.WithBaseURL(mock.URL+"/v1")),
+	}, proxy.GatewayOptions{})
+
+	resp, content := chatThroughGateway(t, base,
+		`{"model":"gpt-4o","temperature":0.3,"max_tokens":64,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, content)
+	}
+
+	var got receivedParams
+	if err := json.Unmarshal([]byte(content), &got); err != nil {
+		t.Fatalf("content %q is not echoed params: %v", content, err)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.3 {
+		t.Errorf("temperature = %v, want 0.3", got.Temperature)
+	}
+	if got.MaxTokens == nil || *got.MaxTokens != 64 {
+		t.Errorf("max_tokens = %v, want 64", got.MaxTokens)
+	}
+	if got.TopP != nil {
+		t.Errorf("top_p = %v, want unset", *got.TopP)
+	}
+	if got.Messages != 2 {
+		t.Errorf("messages = %d, want 2", got.Messages)
+	}
+}
+
+func TestEchoParamsReceivedField(t *testing.T) {
+	srv := httptest.NewServer(newAnthropicHandler(Config{StreamWords: 10, EchoParams: true}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/messages", "application/json",
+		strings.NewReader(`{"model":"claude-3-5-sonnet-20241022","max_tokens":128,"top_p":0.9,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Received receivedParams `json:"_received"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Received.MaxTokens == nil || *out.Received.MaxTokens != 128 {
+		t.Errorf("max_tokens = %v, want 128", out.Received.MaxTokens)
+	}
+	if out.Received.TopP == nil || *out.Received.TopP != 0.9 {
+		t.Errorf("top_p = %v, want 0.9", out.Received.TopP)
+	}
+	if out.Received.Temperature != nil {
+		t.Errorf("temperature = %v, want unset", *out.Received.Temperature)
+	}
+}
+
+func TestProviderErrorRateFailsOver(t *testing.T) {
+	t.Setenv("MOCK_ERROR_RATE", "0")
+	t.Setenv("MOCK_ERROR_RATE_OPENAI", "1")
+	cfg := loadConfig()
+
+	var openaiHits atomic.Int32
+	openaiHandler := newOpenAIHandler(cfg.forProvider("openai"))
+	openaiMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/chat/completions" {
+			openaiHits.Add(1)
+		}
+		openaiHandler.ServeHTTP(w, r)
+	}))
+	defer openaiMock.Close()
+	anthropicMock := httptest.NewServer(newAnthropicHandler(cfg.forProvider("anthropic")))
+	defer anthropicMock.Close()
+
+	base := startGateway(t, map[string]providers.Provider{
+		"openai":    openai.New("mock-key", openai.WithBaseURL(openaiMock.URL+"/v1")),
+		"anthropic": anthropic.New("mock-key", anthropic.WithBaseURL(anthropicMock.URL)),
+	}, proxy.GatewayOptions{FallbackChains: map[string][]string{"openai": {"anthropic"}}})
+
+	resp, content := chatThroughGateway(t, base, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, content)
+	}
+	if got := resp.Header.Get("X-Provider"); got != "anthropic" {
+		t.Errorf("X-Provider = %q, want anthropic", got)
+	}
+	if openaiHits.Load() == 0 {
+		t.Error("openai mock was never tried")
+	}
+}
+
+func TestInjectedErrorUsesNativeBody(t *testing.T) {
+	cfg := Config{StreamWords: 10, ErrorRate: 1, ErrorStatus: http.StatusTooManyRequests}
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+		want    string
+	}{
+		{"openai", newOpenAIHandler(cfg), "/v1/chat/completions", `"type":"rate_limit_error"`},
+		{"anthropic", newAnthropicHandler(cfg), "/v1/messages", `"type":"rate_limit_error"`},
+		{"gemini", newGeminiHandler(cfg), "/v1beta/models/gemini-1.5-pro:generateContent", `"status":"RESOURCE_EXHAUSTED"`},
+		{"mistral", newMistralHandler(cfg), "/v1/chat/completions", `"type":"rate_limit_error"`},
+		{"bedrock", newBedrockHandler(cfg), "/model/amazon.titan-text-express-v1/converse", `"__type":"ThrottlingException"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{}`)))
+			if rec.Code != http.StatusTooManyRequests {
+				t.Errorf("status = %d, want 429", rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got == "" {
+				t.Error("Retry-After not set on 429")
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestNativeErrorReachesClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider func(url string) providers.Provider
+		status   int
+		body     string
+		model    string
+		wantType string
+		wantCode string
+	}{
+		{
+			name:     "openai model_not_found",
+			provider: func(url string) providers.Provider { return openai.New("mock-key", openai.WithBaseURL(url+"/v1")) },
+			status:   http.StatusNotFound,
+			body:     `{"error":{"message":"The model 'gpt-9' does not exist","type":"invalid_request_error","param":null,"code":"model_not_found"}}`,
+			model:    "gpt-4o",
+			wantType: "invalid_request_error",
+			wantCode: "model_not_found",
+		},
+		{
+			name:     "anthropic overloaded_error",
+			provider: func(url string) providers.Provider { return anthropic.New("mock-key", anthropic.WithBaseURL(url)) },
+			status:   529,
+			body:     `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			model:    "claude-3-5-sonnet-20241022",
+			wantType: "overloaded_error",
+			wantCode: "provider_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Keep the SDKs from retrying the overloaded response.
+				w.Header().Set("X-Should-Retry", "false")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer upstream.Close()
+
+			name, _, _ := strings.Cut(tt.name, " ")
+			base := startGateway(t, map[string]providers.Provider{name: tt.provider(upstream.URL)}, proxy.GatewayOptions{})
+
+			resp, body := chatThroughGateway(t, base, `{"model":"`+tt.model+`","messages":[{"role":"user","content":"hi"}]}`)
+			if resp.StatusCode != http.StatusBadGateway {
+				t.Errorf("status = %d, want 502", resp.StatusCode)
+			}
+			var out struct {
+				Error struct {
+					Type string `json:"type"`
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(body), &out); err != nil {
+				t.Fatalf("body %s: %v", body, err)
+			}
+			if out.Error.Type != tt.wantType || out.Error.Code != tt.wantCode {
+				t.Errorf("error type/code = %q/%q, want %q/%q", out.Error.Type, out.Error.Code, tt.wantType, tt.wantCode)
+			}
+		})
+	}
+}
@@ -33,8 +33,8 @@ func newGeminiHandler(cfg Config) http.Handler {
 				return
 			}
 			applyLatency(cfg)
-			if shouldError(cfg) {
-				writeGeminiError(w, http.StatusInternalServerError, "mock internal error")
+			if status, ok := injectError(w, cfg); ok {
+				writeGeminiError(w, status, "mock injected error")
 				return
 			}
 			handleGeminiGenerate(w, r, cfg, model, false)
@@ -45,8 +45,8 @@ func newGeminiHandler(cfg Config) http.Handler {
 				return
 			}
 			applyLatency(cfg)
-			if shouldError(cfg) {
-				writeGeminiError(w, http.StatusInternalServerError, "mock internal error")
+			if status, ok := injectError(w, cfg); ok {
+				writeGeminiError(w, status, "mock injected error")
 				return
 			}
 			handleGeminiGenerate(w, r, cfg, model, true)
@@ -97,12 +97,39 @@ func newGeminiHandler(cfg Config) http.Handler {
 	return mux
 }
 
-func handleGeminiGenerate(w http.ResponseWriter, _ *http.Request, cfg Config, model string, stream bool) {
+func handleGeminiGenerate(w http.ResponseWriter, r *http.Request, cfg Config, model string, stream bool) {
+	var req struct {
+		Contents         []json.RawMessage `json:"contents"`
+		GenerationConfig struct {
+			Temperature     *float64 `json:"temperature"`
+			MaxOutputTokens *int     `json:"maxOutputTokens"`
+			TopP            *float64 `json:"topP"`
+		} `json:"generationConfig"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	received := receivedParams{
+		Temperature: req.GenerationConfig.Temperature,
+		MaxTokens:   req.GenerationConfig.MaxOutputTokens,
+		TopP:        req.GenerationConfig.TopP,
+		Messages:    len(req.Contents),
+	}
+
 	id := fmt.Sprintf("gemini-%x", rand.Int64())
-	content := fakeSentence(cfg.StreamWords)
+	content := replyText(cfg, received)
 	inTokens := 10
 	outTokens := cfg.StreamWords
 
+	usage := map[string]int{
+		"promptTokenCount":     inTokens,
+		"candidatesTokenCount": outTokens,
+		"totalTokenCount":      inTokens + outTokens,
+	}
+
+	if stream {
+		serveGeminiStream(w, cfg, id, model, content, usage)
+		return
+	}
+
 	candidate := map[string]any{
 		"content": map[string]any{
 			"role": "model",
@@ -116,25 +143,51 @@ func handleGeminiGenerate(w http.ResponseWriter, _ *http.Request, cfg Config, mo
 
 	resp := map[string]any{
 		"candidates": []any{candidate},
-		"usageMetadata": map[string]int{
-			"promptTokenCount":     inTokens,
-			"candidatesTokenCount": outTokens,
-			"totalTokenCount":      inTokens + outTokens,
-		},
+		"usageMetadata": usage,
 		"responseId": id,
 		"modelVersion": model,
 	}
 
-	if stream {
-		// Gemini streaming returns a JSON array of GenerateContentResponse objects.
-		// In practice the SDK uses SSE; the genai package uses newline-delimited JSON.
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode([]any{resp})
-		return
-	}
+	writeJSON(w, http.StatusOK, withReceived(cfg, resp, received))
+}
+
+// serveGeminiStream writes the SSE stream the genai SDK reads with alt=sse:
+// one GenerateContentResponse per word, the last carrying finishReason and
+// usageMetadata.
+func serveGeminiStream(w http.ResponseWriter, cfg Config, id, model, content string, usage map[string]int) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	words := strings.Fields(content)
+	for i, word := range words {
+		applyStreamDelay(cfg)
 
-	writeJSON(w, http.StatusOK, resp)
+		candidate := map[string]any{
+			"content": map[string]any{
+				"role":  "model",
+				"parts": []map[string]string{{"text": word + " "}},
+			},
+			"index": 0,
+		}
+		chunk := map[string]any{
+			"candidates":   []any{candidate},
+			"responseId":   id,
+			"modelVersion": model,
+		}
+		if i == len(words)-1 {
+			candidate["finishReason"] = "STOP"
+			chunk["usageMetadata"] = usage
+		}
+
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
 }
 
 func handleGeminiEmbed(w http.ResponseWriter, _ *http.Request, _ string) {
@@ -175,11 +228,35 @@ func writeGeminiError(w http.ResponseWriter, status int, msg string) {
 		"error": map[string]any{
 			"code":    status,
 			"message": msg,
-			"status":  "INTERNAL",
+			"status":  geminiErrorStatus(status),
 		},
 	})
 }
 
+// geminiErrorStatus returns the Google API status string for status.
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	if status < 500 {
+		return "FAILED_PRECONDITION"
+	}
+	return "INTERNAL"
+}
+
 // extractModel pulls the model name out of a path like
 // /v1beta/models/gemini-1.5-pro:generateContent
 func extractModel(path string) string {
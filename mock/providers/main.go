@@ -16,8 +16,15 @@
 // Behaviour flags (via env):
 //
 //	MOCK_LATENCY_MS   — artificial latency added to every response (default 0)
-//	MOCK_ERROR_RATE   — fraction [0,1] of requests that return HTTP 500 (default 0)
+//	MOCK_ERROR_RATE   — fraction [0,1] of requests that fail (default 0)
+//	MOCK_ERROR_RATE_<PROVIDER> — per-provider override of MOCK_ERROR_RATE,
+//	                    e.g. MOCK_ERROR_RATE_OPENAI=1
+//	MOCK_ERROR_STATUS — HTTP status of injected errors, sent with the
+//	                    provider's native error body (default 500)
 //	MOCK_STREAM_WORDS — words in streaming response (default 10)
+//	MOCK_STREAM_DELAY_MS — pause before each streamed word (default 0)
+//	MOCK_ECHO_PARAMS  — reply with the temperature, max_tokens, top_p and
+//	                    message count received, as JSON (default false)
 package main
 
 import (
@@ -28,6 +35,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -35,13 +43,22 @@ import (
 
 // Config holds runtime configuration shared across all mock servers.
 type Config struct {
-	LatencyMS   int
-	ErrorRate   float64
-	StreamWords int
+	LatencyMS int
+	ErrorRate float64
+	// ProviderErrorRates overrides ErrorRate per provider name.
+	ProviderErrorRates map[string]float64
+	ErrorStatus        int
+	StreamWords        int
+	StreamDelayMS      int
+	EchoParams         bool
 }
 
+// mockProviders names the simulated providers, as used in
+// MOCK_ERROR_RATE_<PROVIDER> and log fields.
+var mockProviders = []string{"openai", "anthropic", "gemini", "mistral", "bedrock"}
+
 func loadConfig() Config {
-	c := Config{StreamWords: 10}
+	c := Config{StreamWords: 10, ErrorStatus: http.StatusInternalServerError}
 
 	if v := os.Getenv("MOCK_LATENCY_MS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
@@ -53,11 +70,45 @@ func loadConfig() Config {
 			c.ErrorRate = f
 		}
 	}
+	for _, name := range mockProviders {
+		v := os.Getenv("MOCK_ERROR_RATE_" + strings.ToUpper(name))
+		if v == "" {
+			continue
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			if c.ProviderErrorRates == nil {
+				c.ProviderErrorRates = make(map[string]float64)
+			}
+			c.ProviderErrorRates[name] = f
+		}
+	}
+	if v := os.Getenv("MOCK_ERROR_STATUS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 400 && n <= 599 {
+			c.ErrorStatus = n
+		}
+	}
 	if v := os.Getenv("MOCK_STREAM_WORDS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			c.StreamWords = n
 		}
 	}
+	if v := os.Getenv("MOCK_STREAM_DELAY_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.StreamDelayMS = n
+		}
+	}
+	if v := os.Getenv("MOCK_ECHO_PARAMS"); v != "" {
+		c.EchoParams, _ = strconv.ParseBool(v)
+	}
+	return c
+}
+
+// forProvider returns the configuration for one provider's mock, with its
+// error rate override applied.
+func (c Config) forProvider(name string) Config {
+	if rate, ok := c.ProviderErrorRates[name]; ok {
+		c.ErrorRate = rate
+	}
 	return c
 }
 
@@ -92,15 +143,19 @@ func main() {
 	log.Info("starting mock providers",
 		slog.Int("latency_ms", cfg.LatencyMS),
 		slog.Float64("error_rate", cfg.ErrorRate),
+		slog.Any("provider_error_rates", cfg.ProviderErrorRates),
+		slog.Int("error_status", cfg.ErrorStatus),
 		slog.Int("stream_words", cfg.StreamWords),
+		slog.Int("stream_delay_ms", cfg.StreamDelayMS),
+		slog.Bool("echo_params", cfg.EchoParams),
 	)
 
 	servers := []*http.Server{
-		startServer("openai", ":"+portFromEnv("PORT_OPENAI", 19001), newOpenAIHandler(cfg), log),
-		startServer("anthropic", ":"+portFromEnv("PORT_ANTHROPIC", 19002), newAnthropicHandler(cfg), log),
-		startServer("gemini", ":"+portFromEnv("PORT_GEMINI", 19003), newGeminiHandler(cfg), log),
-		startServer("mistral", ":"+portFromEnv("PORT_MISTRAL", 19004), newMistralHandler(cfg), log),
-		startServer("bedrock", ":"+portFromEnv("PORT_BEDROCK", 19005), newBedrockHandler(cfg), log),
+		startServer("openai", ":"+portFromEnv("PORT_OPENAI", 19001), newOpenAIHandler(cfg.forProvider("openai")), log),
+		startServer("anthropic", ":"+portFromEnv("PORT_ANTHROPIC", 19002), newAnthropicHandler(cfg.forProvider("anthropic")), log),
+		startServer("gemini", ":"+portFromEnv("PORT_GEMINI", 19003), newGeminiHandler(cfg.forProvider("gemini")), log),
+		startServer("mistral", ":"+portFromEnv("PORT_MISTRAL", 19004), newMistralHandler(cfg.forProvider("mistral")), log),
+		startServer("bedrock", ":"+portFromEnv("PORT_BEDROCK", 19005), newBedrockHandler(cfg.forProvider("bedrock")), log),
 	}
 
 	// Print readiness
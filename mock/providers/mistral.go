@@ -21,14 +21,18 @@ func newMistralHandler(cfg Config) http.Handler {
 			return
 		}
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeMistralError(w, http.StatusInternalServerError, "mock internal error", "server_error")
+		if status, ok := injectError(w, cfg); ok {
+			writeMistralError(w, status, "mock injected error", openAIErrorType(status))
 			return
 		}
 
 		var req struct {
-			Model  string `json:"model"`
-			Stream bool   `json:"stream"`
+			Model       string            `json:"model"`
+			Stream      bool              `json:"stream"`
+			Temperature *float64          `json:"temperature"`
+			MaxTokens   *int              `json:"max_tokens"`
+			TopP        *float64          `json:"top_p"`
+			Messages    []json.RawMessage `json:"messages"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeMistralError(w, http.StatusBadRequest, "invalid request body", "invalid_request")
@@ -41,16 +45,22 @@ func newMistralHandler(cfg Config) http.Handler {
 		}
 
 		id := fmt.Sprintf("cmpl-%x", rand.Int64())
-		content := fakeSentence(cfg.StreamWords)
+		received := receivedParams{
+			Temperature: req.Temperature,
+			MaxTokens:   req.MaxTokens,
+			TopP:        req.TopP,
+			Messages:    len(req.Messages),
+		}
+		content := replyText(cfg, received)
 		inTokens := 10
 		outTokens := cfg.StreamWords
 
 		if req.Stream {
-			serveMistralStream(w, id, model, content)
+			serveMistralStream(w, cfg, id, model, content, inTokens, outTokens)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, map[string]any{
+		writeJSON(w, http.StatusOK, withReceived(cfg, map[string]any{
 			"id":      id,
 			"object":  "chat.completion",
 			"created": time.Now().Unix(),
@@ -70,7 +80,7 @@ func newMistralHandler(cfg Config) http.Handler {
 				"completion_tokens": outTokens,
 				"total_tokens":      inTokens + outTokens,
 			},
-		})
+		}, received))
 	})
 
 	// POST /v1/embeddings
@@ -80,8 +90,8 @@ func newMistralHandler(cfg Config) http.Handler {
 			return
 		}
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeMistralError(w, http.StatusInternalServerError, "mock internal error", "server_error")
+		if status, ok := injectError(w, cfg); ok {
+			writeMistralError(w, status, "mock injected error", openAIErrorType(status))
 			return
 		}
 
@@ -152,40 +162,43 @@ func writeMistralError(w http.ResponseWriter, status int, msg, typ string) {
 	})
 }
 
-func serveMistralStream(w http.ResponseWriter, id, model, content string) {
+// serveMistralStream writes an SSE stream in Mistral's format: the role on
+// the first chunk only, one chunk per word, and finish_reason plus usage on
+// the last.
+func serveMistralStream(w http.ResponseWriter, cfg Config, id, model, content string, inTokens, outTokens int) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.WriteHeader(http.StatusOK)
 
 	flusher, _ := w.(http.Flusher)
+	created := time.Now().Unix()
 
 	words := strings.Fields(content)
 	for i, word := range words {
-		finishReason := ""
-		if i == len(words)-1 {
-			finishReason = "stop"
-		}
-
-		var deltaContent *string
-		wordWithSpace := word + " "
-		deltaContent = &wordWithSpace
+		applyStreamDelay(cfg)
 
+		delta := map[string]any{"content": word + " "}
+		if i == 0 {
+			delta["role"] = "assistant"
+		}
+		var finishReason any
 		chunk := map[string]any{
 			"id":      id,
 			"object":  "chat.completion.chunk",
-			"created": time.Now().Unix(),
+			"created": created,
 			"model":   model,
-			"choices": []map[string]any{
-				{
-					"index": 0,
-					"delta": map[string]any{
-						"role":    "assistant",
-						"content": *deltaContent,
-					},
-					"finish_reason": finishReason,
-				},
-			},
+		}
+		if i == len(words)-1 {
+			finishReason = "stop"
+			chunk["usage"] = map[string]int{
+				"prompt_tokens":     inTokens,
+				"completion_tokens": outTokens,
+				"total_tokens":      inTokens + outTokens,
+			}
+		}
+		chunk["choices"] = []map[string]any{
+			{"index": 0, "delta": delta, "finish_reason": finishReason},
 		}
 
 		data, _ := json.Marshal(chunk)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"math/rand/v2"
@@ -21,15 +22,22 @@ func newOpenAIHandler(cfg Config) http.Handler {
 			return
 		}
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeError(w, http.StatusInternalServerError, "mock internal server error", "server_error")
+		if status, ok := injectError(w, cfg); ok {
+			writeError(w, status, "mock injected error", openAIErrorType(status))
 			return
 		}
 
 		var req struct {
-			Model    string `json:"model"`
-			Stream   bool   `json:"stream"`
-			Messages []struct {
+			Model         string `json:"model"`
+			Stream        bool   `json:"stream"`
+			StreamOptions *struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+			Temperature         *float64 `json:"temperature"`
+			MaxTokens           *int     `json:"max_tokens"`
+			MaxCompletionTokens *int     `json:"max_completion_tokens"`
+			TopP                *float64 `json:"top_p"`
+			Messages            []struct {
 				Role    string `json:"role"`
 				Content string `json:"content"`
 			} `json:"messages"`
@@ -45,16 +53,31 @@ func newOpenAIHandler(cfg Config) http.Handler {
 		}
 
 		id := fmt.Sprintf("chatcmpl-mock%x", rand.Int64())
-		content := fakeSentence(cfg.StreamWords)
+		received := receivedParams{
+			Temperature: req.Temperature,
+			MaxTokens:   cmp.Or(req.MaxCompletionTokens, req.MaxTokens),
+			TopP:        req.TopP,
+			Messages:    len(req.Messages),
+		}
+		content := replyText(cfg, received)
 		inTokens := 10
 		outTokens := cfg.StreamWords
 
+		usage := map[string]int{
+			"prompt_tokens":     inTokens,
+			"completion_tokens": outTokens,
+			"total_tokens":      inTokens + outTokens,
+		}
+
 		if req.Stream {
-			serveOpenAIStream(w, id, model, content)
+			if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+				usage = nil
+			}
+			serveOpenAIStream(w, cfg, id, model, content, usage)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, map[string]any{
+		writeJSON(w, http.StatusOK, withReceived(cfg, map[string]any{
 			"id":      id,
 			"object":  "chat.completion",
 			"created": time.Now().Unix(),
@@ -69,12 +92,8 @@ func newOpenAIHandler(cfg Config) http.Handler {
 					"finish_reason": "stop",
 				},
 			},
-			"usage": map[string]int{
-				"prompt_tokens":     inTokens,
-				"completion_tokens": outTokens,
-				"total_tokens":      inTokens + outTokens,
-			},
-		})
+			"usage": usage,
+		}, received))
 	})
 
 	// Embeddings
@@ -84,8 +103,8 @@ func newOpenAIHandler(cfg Config) http.Handler {
 			return
 		}
 		applyLatency(cfg)
-		if shouldError(cfg) {
-			writeError(w, http.StatusInternalServerError, "mock internal server error", "server_error")
+		if status, ok := injectError(w, cfg); ok {
+			writeError(w, status, "mock injected error", openAIErrorType(status))
 			return
 		}
 
@@ -161,31 +180,28 @@ func newOpenAIHandler(cfg Config) http.Handler {
 	return mux
 }
 
-// serveOpenAIStream writes an SSE stream of chat completion chunks.
-func serveOpenAIStream(w http.ResponseWriter, id, model, content string) {
+// serveOpenAIStream writes an SSE stream of chat completion chunks: a role
+// delta, one chunk per word, a finish_reason chunk and, when the client asked
+// for stream_options.include_usage, a usage chunk with no choices.
+func serveOpenAIStream(w http.ResponseWriter, cfg Config, id, model, content string, usage map[string]int) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.WriteHeader(http.StatusOK)
 
 	flusher, _ := w.(http.Flusher)
+	created := time.Now().Unix()
 
-	words := strings.Fields(content)
-	for _, word := range words {
+	send := func(choices []map[string]any, extra map[string]any) {
 		chunk := map[string]any{
 			"id":      id,
 			"object":  "chat.completion.chunk",
-			"created": time.Now().Unix(),
+			"created": created,
 			"model":   model,
-			"choices": []map[string]any{
-				{
-					"index": 0,
-					"delta": map[string]string{
-						"content": word + " ",
-					},
-					"finish_reason": nil,
-				},
-			},
+			"choices": choices,
+		}
+		for k, v := range extra {
+			chunk[k] = v
 		}
 		data, _ := json.Marshal(chunk)
 		fmt.Fprintf(w, "data: %s\n\n", data)
@@ -193,23 +209,20 @@ func serveOpenAIStream(w http.ResponseWriter, id, model, content string) {
 			flusher.Flush()
 		}
 	}
+	choice := func(delta map[string]string, finishReason any) []map[string]any {
+		return []map[string]any{{"index": 0, "delta": delta, "finish_reason": finishReason}}
+	}
 
-	// Final chunk with finish_reason
-	finalChunk := map[string]any{
-		"id":      id,
-		"object":  "chat.completion.chunk",
-		"created": time.Now().Unix(),
-		"model":   model,
-		"choices": []map[string]any{
-			{
-				"index":         0,
-				"delta":         map[string]string{},
-				"finish_reason": "stop",
-			},
-		},
+	send(choice(map[string]string{"role": "assistant", "content": ""}, nil), nil)
+	for _, word := range strings.Fields(content) {
+		applyStreamDelay(cfg)
+		send(choice(map[string]string{"content": word + " "}, nil), nil)
 	}
-	data, _ := json.Marshal(finalChunk)
-	fmt.Fprintf(w, "data: %s\n\n", data)
+	send(choice(map[string]string{}, "stop"), nil)
+	if usage != nil {
+		send([]map[string]any{}, map[string]any{"usage": usage})
+	}
+
 	fmt.Fprintf(w, "data: [DONE]\n\n")
 	if flusher != nil {
 		flusher.Flush()
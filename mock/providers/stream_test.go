@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamText extracts the streamed text carried by one event payload, or ""
+// when the event carries none.
+type streamText func(t *testing.T, data []byte) string
+
+func decodeEvent(t *testing.T, data []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("malformed event %s: %v", data, err)
+	}
+}
+
+func openAIText(t *testing.T, data []byte) string {
+	var ev struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	decodeEvent(t, data, &ev)
+	if len(ev.Choices) == 0 {
+		return ""
+	}
+	return ev.Choices[0].Delta.Content
+}
+
+func anthropicText(t *testing.T, data []byte) string {
+	var ev struct {
+		Type  string `json:"type"`
+		Delta struct {
+			Text string `json:"text"`
+		} `json:"delta"`
+	}
+	decodeEvent(t, data, &ev)
+	if ev.Type != "content_block_delta" {
+		return ""
+	}
+	return ev.Delta.Text
+}
+
+func geminiText(t *testing.T, data []byte) string {
+	var ev struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	decodeEvent(t, data, &ev)
+	if len(ev.Candidates) == 0 || len(ev.Candidates[0].Content.Parts) == 0 {
+		return ""
+	}
+	return ev.Candidates[0].Content.Parts[0].Text
+}
+
+func bedrockText(t *testing.T, data []byte) string {
+	var ev struct {
+		ContentBlockDelta *struct {
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		} `json:"contentBlockDelta"`
+	}
+	decodeEvent(t, data, &ev)
+	if ev.ContentBlockDelta == nil {
+		return ""
+	}
+	return ev.ContentBlockDelta.Delta.Text
+}
+
+func TestStreamingIsPaced(t *testing.T) {
+	const words = 4
+	const delay = 25 * time.Millisecond
+	cfg := Config{StreamWords: words, StreamDelayMS: int(delay / time.Millisecond)}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+		body    string
+		text    streamText
+	}{
+		{"openai", newOpenAIHandler(cfg), "/v1/chat/completions", `{"model":"gpt-4o","stream":true}`, openAIText},
+		{"anthropic", newAnthropicHandler(cfg), "/v1/messages", `{"model":"claude-3-5-sonnet-20241022","stream":true}`, anthropicText},
+		{"gemini", newGeminiHandler(cfg), "/v1beta/models/gemini-1.5-pro:streamGenerateContent?alt=sse", `{}`, geminiText},
+		{"mistral", newMistralHandler(cfg), "/v1/chat/completions", `{"model":"mistral-large-latest","stream":true}`, openAIText},
+		{"bedrock", newBedrockHandler(cfg), "/model/anthropic.claude-3-5-sonnet-20241022-v2:0/converse-stream", `{}`, bedrockText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			var arrivals []time.Time
+			var text strings.Builder
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data:")
+				data = strings.TrimSpace(data)
+				if !ok || data == "[DONE]" {
+					continue
+				}
+				if s := tt.text(t, []byte(data)); s != "" {
+					arrivals = append(arrivals, time.Now())
+					text.WriteString(s)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(arrivals) != words {
+				t.Fatalf("got %d word events, want %d (text %q)", len(arrivals), words, text.String())
+			}
+			if n := len(strings.Fields(text.String())); n != words {
+				t.Errorf("streamed %d words, want %d", n, words)
+			}
+			// Words arrive spread out rather than in one burst; allow one
+			// interval of slack for scheduling.
+			if span := arrivals[words-1].Sub(arrivals[0]); span < (words-2)*delay {
+				t.Errorf("words arrived within %v, want at least %v", span, (words-2)*delay)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamShape(t *testing.T) {
+	srv := httptest.NewServer(newOpenAIHandler(Config{StreamWords: 3}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	type chunk struct {
+		Choices []struct {
+			Delta        map[string]string `json:"delta"`
+			FinishReason *string           `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	var chunks []chunk
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var c chunk
+		decodeEvent(t, []byte(data), &c)
+		chunks = append(chunks, c)
+	}
+
+	// role, 3 words, finish, usage
+	if len(chunks) != 6 {
+		t.Fatalf("got %d chunks, want 6", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta["role"] != "assistant" {
+		t.Errorf("first delta = %v, want the assistant role", chunks[0].Choices[0].Delta)
+	}
+	if fr := chunks[4].Choices[0].FinishReason; fr == nil || *fr != "stop" {
+		t.Errorf("finish_reason = %v, want stop", fr)
+	}
+	if last := chunks[5]; len(last.Choices) != 0 || last.Usage == nil || last.Usage.TotalTokens == 0 {
+		t.Errorf("last chunk = %+v, want usage with no choices", last)
+	}
+}
@@ -3,11 +3,17 @@
 package apierr
 
 import (
+	"cmp"
 	"encoding/json"
+	"strconv"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
 
+// DefaultRetryAfter is sent with 429 responses when no better hint is known.
+const DefaultRetryAfter = 60 * time.Second
+
 // ErrorType constants.
 const (
 	TypeProviderError     = "provider_error"
@@ -15,17 +21,28 @@ const (
 	TypeInvalidRequest    = "invalid_request_error"
 	TypeAuthenticationErr = "authentication_error"
 	TypeServerError       = "server_error"
+	TypePermissionError   = "permission_error"
 )
 
 // Code constants.
 const (
-	CodeRateLimitExceeded = "rate_limit_exceeded"
-	CodeInvalidAPIKey     = "invalid_api_key"
-	CodeInternalError     = "internal_error"
-	CodeProviderError     = "provider_error"
-	CodeRequestTimeout    = "request_timeout"
-	CodeNotImplemented    = "not_implemented"
-	CodeInvalidRequest    = "invalid_request"
+	CodeRateLimitExceeded     = "rate_limit_exceeded"
+	CodeInvalidAPIKey         = "invalid_api_key"
+	CodeInternalError         = "internal_error"
+	CodeProviderError         = "provider_error"
+	CodeRequestTimeout        = "request_timeout"
+	CodeNotImplemented        = "not_implemented"
+	CodeInvalidRequest        = "invalid_request"
+	CodeOverloaded            = "overloaded"
+	CodeModelNotAllowed       = "model_not_allowed"
+	CodeBudgetExceeded        = "budget_exceeded"
+	CodeRequestTooLarge       = "request_too_large"
+	CodeContextLengthExceeded = "context_length_exceeded"
+	CodeIdempotencyConflict   = "idempotency_conflict"
+	CodeProviderUnavailable   = "provider_unavailable"
+	CodeContentFilter         = "content_filter"
+	CodeUnsupportedParameter  = "unsupported_parameter"
+	CodeMethodNotAllowed      = "method_not_allowed"
 )
 
 // APIError is the structured error returned to clients.
@@ -44,30 +61,54 @@ type (
 func Write(ctx *fasthttp.RequestCtx, status int, message, errType, code string) {
 	ctx.SetStatusCode(status)
 	ctx.SetContentType("application/json")
+	ctx.SetBody(Encode(message, errType, code))
+}
+
+// Encode returns the error envelope Write sends, for errors delivered
+// outside a response body of their own, such as an SSE error event.
+func Encode(message, errType, code string) []byte {
 	body, _ := json.Marshal(envelope{Error: APIError{
 		Message: message,
 		Type:    errType,
 		Code:    code,
 	}})
-	ctx.SetBody(body)
+	return body
 }
 
 // WriteProviderError maps a provider HTTP status to the appropriate gateway status.
 //
-//	Provider 429  → 429 + Retry-After: 60
+//	Provider 429  → 429 + Retry-After (upstream hint, or 60 if retryAfter is 0)
 //	Provider 5xx  → 502
 //	Timeout       → 504
 //	Default       → 502
-func WriteProviderError(ctx *fasthttp.RequestCtx, providerStatus int, msg string) {
+//
+// errType and code are the provider's own error type and code, passed
+// through so clients can tell e.g. insufficient_quota from model_not_found.
+// When empty, the envelope carries the gateway's generic type and code for
+// the status.
+func WriteProviderError(ctx *fasthttp.RequestCtx, providerStatus int, msg, errType, code string, retryAfter time.Duration) {
 	switch {
 	case providerStatus == fasthttp.StatusTooManyRequests:
-		ctx.Response.Header.Set("Retry-After", "60")
-		Write(ctx, fasthttp.StatusTooManyRequests, msg, TypeRateLimitError, CodeRateLimitExceeded)
+		setRetryAfter(ctx, retryAfter)
+		Write(ctx, fasthttp.StatusTooManyRequests, msg,
+			cmp.Or(errType, TypeRateLimitError), cmp.Or(code, CodeRateLimitExceeded))
 	case providerStatus >= 500 && providerStatus < 600:
-		Write(ctx, fasthttp.StatusBadGateway, msg, TypeProviderError, CodeProviderError)
+		Write(ctx, fasthttp.StatusBadGateway, msg,
+			cmp.Or(errType, TypeProviderError), cmp.Or(code, CodeProviderError))
 	default:
-		Write(ctx, fasthttp.StatusBadGateway, msg, TypeProviderError, CodeProviderError)
+		Write(ctx, fasthttp.StatusBadGateway, msg,
+			cmp.Or(errType, TypeProviderError), cmp.Or(code, CodeProviderError))
+	}
+}
+
+// WriteProviderUnavailable writes a 503 telling the client the upstream is
+// temporarily unavailable, as opposed to the 502 WriteProviderError sends.
+// Retry-After is set only when the upstream gave a hint.
+func WriteProviderUnavailable(ctx *fasthttp.RequestCtx, msg string, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		setRetryAfter(ctx, retryAfter)
 	}
+	Write(ctx, fasthttp.StatusServiceUnavailable, msg, TypeProviderError, CodeProviderUnavailable)
 }
 
 // WriteTimeout writes a 504 timeout error.
@@ -75,8 +116,26 @@ func WriteTimeout(ctx *fasthttp.RequestCtx) {
 	Write(ctx, fasthttp.StatusGatewayTimeout, "provider request timed out", TypeProviderError, CodeRequestTimeout)
 }
 
-// WriteRateLimit writes a 429 rate limit error.
-func WriteRateLimit(ctx *fasthttp.RequestCtx) {
-	ctx.Response.Header.Set("Retry-After", "60")
+// WriteRateLimit writes a 429 rate limit error. A zero retryAfter sends the
+// default of 60 seconds.
+func WriteRateLimit(ctx *fasthttp.RequestCtx, retryAfter time.Duration) {
+	setRetryAfter(ctx, retryAfter)
 	Write(ctx, fasthttp.StatusTooManyRequests, "rate limit exceeded", TypeRateLimitError, CodeRateLimitExceeded)
 }
+
+// WriteOverloaded writes a 503 telling the client the gateway is at capacity.
+// A zero retryAfter sends the default of 60 seconds.
+func WriteOverloaded(ctx *fasthttp.RequestCtx, retryAfter time.Duration) {
+	setRetryAfter(ctx, retryAfter)
+	Write(ctx, fasthttp.StatusServiceUnavailable, "gateway is at capacity, retry later", TypeServerError, CodeOverloaded)
+}
+
+// setRetryAfter writes the Retry-After header in whole seconds, rounding up
+// so clients never retry earlier than the upstream asked.
+func setRetryAfter(ctx *fasthttp.RequestCtx, d time.Duration) {
+	if d <= 0 {
+		d = DefaultRetryAfter
+	}
+	secs := int64((d + time.Second - 1) / time.Second)
+	ctx.Response.Header.Set("Retry-After", strconv.FormatInt(secs, 10))
+}